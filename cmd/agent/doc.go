@@ -2,16 +2,79 @@
 //
 // Usage:
 //
-//   agent -listen 127.0.0.1:8787 -shutdown-secs 5
+//	agent -listen 127.0.0.1:8787 -shutdown-secs 5 -log-level info -log-format text
+//	agent replay [-state state.json]... [-config file] [-until ID] [-step|-json] <journal.jsonl>
+//	agent gen-token [-scope read] <name>
+//	agent secret set [-user name] <name> < password | delete <name> | show <name>
+//	agent helper install [-dest path] <splhelper binary> | verify [-config file]
 //
 // Flags:
-//   -listen          HTTP bind address (default 127.0.0.1:8787)
-//   -shutdown-secs   graceful shutdown timeout in seconds (default 5)
+//
+//	-listen          HTTP bind address (default 127.0.0.1:8787)
+//	-listen-tls      serve -listen over HTTPS (self-signed pair in -data-dir
+//	                 unless -tls-cert and -tls-key are given)
+//	-tls-cert, -tls-key  PEM certificate and key for -listen-tls
+//	-shutdown-secs   graceful shutdown timeout in seconds (default 5)
+//	-log-level       debug, info, warn, or error (default info)
+//	-log-format      text or json (default text)
+//	-display-tz      IANA timezone for *_local timestamp companions (default off)
+//	-unix-socket     additionally serve the API on a unix socket (mode 0600)
+//	-config          shared JSON config file; may define several listeners
+//	-storage         persistence backend: file (default), sqlite, or memory
+//	-data-dir        directory for persisted state, events, and shutdown reports
+//	-tun2socks-pidfile  pidfile consulted for orphaned tun2socks processes
+//	-recovery        orphan handling at startup: report (default) or auto
+//	-pprof           serve net/http/pprof at /debug/pprof/ (admin scope)
 //
 // Behavior:
 //
-// Initializes core state, starts the API server, and blocks on SIGINT/SIGTERM
-// for graceful shutdown. The binary intentionally avoids daemonizing itself;
-// packaging as a launchd service is recommended for persistence.
+// Initializes core state (restoring it from storage when present),
+// starts the API server, and blocks on SIGINT/SIGTERM
+// for graceful shutdown. On exit it writes a shutdown report (drained
+// connections, route restoration outcome, last status) that the next run
+// serves at GET /v1/shutdown-report. The binary intentionally avoids daemonizing itself;
+// running as a launch daemon (macOS; POST /v1/service/install writes and
+// loads one, see package launchd) or a systemd unit (Linux) is
+// recommended for persistence. Under systemd with Type=notify it reports
+// readiness once the API serves and feeds WatchdogSec= (see package
+// sdnotify); packaging/systemd has a unit. Started by socket activation
+// it serves the sockets systemd passes in place of the listeners with the
+// same addresses (see package sdactivate). POST /v1/upgrade restarts
+// the executable with the listeners' sockets and exits once the new
+// process serves them (see package handover). Registered as a Windows
+// service it reports to the service control manager and stops on its
+// request (see package winsvc).
+//
+// Tokens:
+//
+// "agent gen-token" prints a new secret and the api_tokens entry (name,
+// scope, SHA-256 of the secret) to paste into the config file. The agent
+// keeps only the digest; give the secret to the client.
+//
+// Credentials:
+//
+// "agent secret" stores SOCKS credentials in the platform secret store
+// (Keychain or Secret Service, for the user running it) under a name that
+// probe and start requests pass as auth_ref. The password is read from
+// stdin; "show" prints the username and password length only. Run it as
+// the same user as the agent, or the agent will not find the entry.
+//
+// Privileged helper:
+//
+// "agent helper install" copies a splhelper build into place, root-owned
+// and writable only by root, and prints the helper config section with
+// its SHA-256. With that section the agent sends TUN, route, and firewall
+// changes to the running helper and can run unprivileged; it refuses to
+// start if the installed file fails the check. "agent helper verify"
+// checks the file and asks the running helper for its digest (see
+// package privhelper).
+//
+// Replay:
+//
+// "agent replay" rebuilds what an agent went through from a user's event
+// journal (the data directory's events.jsonl or a jsonl exporter file) and
+// optional state records, without touching the host. Events, the timeline,
+// and the final status go through the API mappers; probe results are fed
+// through core's health hysteresis at their original times (use -config
+// for the user's thresholds). -step pauses after each event.
 package main
-
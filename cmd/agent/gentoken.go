@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/sanverite/simple-packet-logger/internal/config"
+	"github.com/sanverite/simple-packet-logger/internal/tokens"
+)
+
+// runGenToken prints a new random secret and the api_tokens entry that
+// accepts it. Only the entry goes into the config file; the secret is given
+// to the client and is not recoverable from the entry.
+func runGenToken(args []string, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("agent gen-token", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	scope := fs.String("scope", "read", "token scope: admin, operate, or read")
+	fs.Usage = func() {
+		fmt.Fprintln(errOut, "usage: agent gen-token [-scope read] <name>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	entry := config.APIToken{Name: fs.Arg(0), Scope: *scope}
+	if err := tokens.ValidName(entry.Name); err != nil {
+		fmt.Fprintf(errOut, "agent gen-token: %v\n", err)
+		return 2
+	}
+	if _, err := configTokens([]config.APIToken{entry}); err != nil {
+		fmt.Fprintf(errOut, "agent gen-token: %v\n", err)
+		return 2
+	}
+	secret, err := tokens.NewSecret()
+	if err != nil {
+		fmt.Fprintf(errOut, "agent gen-token: %v\n", err)
+		return 1
+	}
+	entry.SHA256 = tokens.Digest(secret)
+	b, _ := json.Marshal(entry)
+	fmt.Fprintf(out, "secret: %s\napi_tokens entry: %s\n", secret, b)
+	return 0
+}
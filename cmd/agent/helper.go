@@ -0,0 +1,106 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+
+	"github.com/sanverite/simple-packet-logger/internal/config"
+	"github.com/sanverite/simple-packet-logger/internal/privhelper"
+	"github.com/sanverite/simple-packet-logger/internal/recovery"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+)
+
+// defaultHelperPath is where "agent helper install" puts the helper.
+const defaultHelperPath = "/usr/local/libexec/simple-packet-logger/splhelper"
+
+// runHelper installs the privileged helper executable and checks an
+// installed one: its file integrity and, when it runs, that it answers
+// with the pinned digest.
+func runHelper(args []string, out, errOut io.Writer) int {
+	usage := func() {
+		fmt.Fprintln(errOut, "usage: agent helper install [-dest path] <splhelper binary>")
+		fmt.Fprintln(errOut, "       agent helper verify [-config file]")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	fs := flag.NewFlagSet("agent helper "+args[0], flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.Usage = usage
+	dest := fs.String("dest", defaultHelperPath, "install path (install only)")
+	configPath := fs.String("config", config.DefaultPath(), "config file with the helper section (verify only)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	switch args[0] {
+	case "install":
+		if fs.NArg() != 1 {
+			usage()
+			return 2
+		}
+		digest, err := privhelper.Install(fs.Arg(0), *dest)
+		if err != nil {
+			fmt.Fprintf(errOut, "agent helper install: %v\n", err)
+			return 1
+		}
+		b, _ := json.Marshal(map[string]config.Helper{"helper": {Path: *dest, SHA256: digest}})
+		fmt.Fprintf(out, "installed %s\nsha256: %s\nconfig: %s\n", *dest, digest, b)
+		return 0
+	case "verify":
+		if fs.NArg() != 0 {
+			usage()
+			return 2
+		}
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			fmt.Fprintf(errOut, "agent helper verify: %v\n", err)
+			return 1
+		}
+		if cfg.Helper == nil {
+			fmt.Fprintf(errOut, "agent helper verify: no helper section in %s\n", cmp.Or(*configPath, "(no config)"))
+			return 1
+		}
+		if _, err := privhelper.Verify(cfg.Helper.Path, cfg.Helper.SHA256); err != nil {
+			fmt.Fprintf(errOut, "agent helper verify: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "file: %s ok (sha256 %s)\n", cfg.Helper.Path, cfg.Helper.SHA256)
+		socket := cmp.Or(cfg.Helper.Socket, privhelper.DefaultSocket)
+		h, err := privhelper.NewClient(socket, cfg.Helper.SHA256).Hello()
+		if err != nil {
+			fmt.Fprintf(errOut, "agent helper verify: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "helper: %s ok (protocol %d, tun %s, routes %s, firewall %s)\n",
+			socket, h.Protocol, h.TUN, h.Routes, cmp.Or(h.Firewall, "none"))
+		return 0
+	}
+	usage()
+	return 2
+}
+
+// helperRecovery is a recovery.System that deletes interfaces and restores
+// the default route through the privileged helper, so crash recovery and
+// shutdown work for an unprivileged agent.
+type helperRecovery struct {
+	recovery.System
+	helper *privhelper.Client
+}
+
+func (h helperRecovery) DeleteInterface(name string) error {
+	return h.helper.RemoveTUN(name)
+}
+
+func (h helperRecovery) SetDefaultGateway(gw string) error {
+	via, err := netip.ParseAddr(gw)
+	if err != nil || !via.Is4() {
+		return fmt.Errorf("invalid IPv4 gateway %q", gw)
+	}
+	return h.helper.ApplyRoute(routeplan.Route{Dst: netip.PrefixFrom(netip.IPv4Unspecified(), 0), Via: via})
+}
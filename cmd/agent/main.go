@@ -1,54 +1,1468 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/sanverite/simple-packet-logger/internal/api"
+	"github.com/sanverite/simple-packet-logger/internal/captive"
+	"github.com/sanverite/simple-packet-logger/internal/config"
 	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/discovery"
+	"github.com/sanverite/simple-packet-logger/internal/dnsproxy"
+	"github.com/sanverite/simple-packet-logger/internal/egress"
+	"github.com/sanverite/simple-packet-logger/internal/export"
+	"github.com/sanverite/simple-packet-logger/internal/firewall"
+	"github.com/sanverite/simple-packet-logger/internal/handover"
+	"github.com/sanverite/simple-packet-logger/internal/hooks"
+	"github.com/sanverite/simple-packet-logger/internal/ipv6leak"
+	"github.com/sanverite/simple-packet-logger/internal/launchd"
+	"github.com/sanverite/simple-packet-logger/internal/leaktest"
+	"github.com/sanverite/simple-packet-logger/internal/logging"
+	"github.com/sanverite/simple-packet-logger/internal/metrics"
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+	"github.com/sanverite/simple-packet-logger/internal/netloc"
+	"github.com/sanverite/simple-packet-logger/internal/persist"
+	"github.com/sanverite/simple-packet-logger/internal/policy"
+	"github.com/sanverite/simple-packet-logger/internal/privhelper"
+	"github.com/sanverite/simple-packet-logger/internal/procowner"
+	"github.com/sanverite/simple-packet-logger/internal/profiles"
+	"github.com/sanverite/simple-packet-logger/internal/proxypin"
+	"github.com/sanverite/simple-packet-logger/internal/recovery"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+	"github.com/sanverite/simple-packet-logger/internal/routerepair"
+	"github.com/sanverite/simple-packet-logger/internal/rules"
+	"github.com/sanverite/simple-packet-logger/internal/sdactivate"
+	"github.com/sanverite/simple-packet-logger/internal/sdnotify"
+	"github.com/sanverite/simple-packet-logger/internal/secrets"
+	"github.com/sanverite/simple-packet-logger/internal/storage"
+	"github.com/sanverite/simple-packet-logger/internal/stream"
+	"github.com/sanverite/simple-packet-logger/internal/tokens"
+	"github.com/sanverite/simple-packet-logger/internal/tun2socks"
+	"github.com/sanverite/simple-packet-logger/internal/uplink"
+	"github.com/sanverite/simple-packet-logger/internal/webhooks"
+	"github.com/sanverite/simple-packet-logger/internal/winsvc"
 )
 
 func main() {
+	// "agent replay" inspects a journal offline; see replay.go.
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	// "agent gen-token" prints a secret and its api_tokens entry; see gentoken.go.
+	if len(os.Args) > 1 && os.Args[1] == "gen-token" {
+		os.Exit(runGenToken(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	// "agent secret" manages stored SOCKS credentials; see secret.go.
+	if len(os.Args) > 1 && os.Args[1] == "secret" {
+		os.Exit(runSecret(os.Args[2:], secrets.OSStore(), os.Stdin, os.Stdout, os.Stderr))
+	}
+	// "agent helper" installs and checks the privileged helper; see helper.go.
+	if len(os.Args) > 1 && os.Args[1] == "helper" {
+		os.Exit(runHelper(os.Args[2:], os.Stdout, os.Stderr))
+	}
 	var (
 		addr         = flag.String("listen", api.DefaultAddress, "HTTP listen address")
+		listenTLS    = flag.Bool("listen-tls", false, "serve -listen over HTTPS; without -tls-cert/-tls-key, a self-signed pair is generated in the data dir on first run")
+		tlsCert      = flag.String("tls-cert", "", "PEM certificate for -listen-tls")
+		tlsKey       = flag.String("tls-key", "", "PEM private key for -listen-tls")
 		shutdownSecs = flag.Int("shutdown-secs", 5, "graceful shutdown timeout in seconds")
+		logLevel     = flag.String("log-level", "info", "log level: debug, info, warn, error")
+		logFormat    = flag.String("log-format", logging.FormatText, "log output format: text or json")
+		displayTZ    = flag.String("display-tz", "", "IANA timezone for *_local timestamp fields (e.g. Local, Europe/Berlin); empty disables")
+		unixSocket   = flag.String("unix-socket", "", "additionally serve the API on this unix socket path (admin scope, mode 0600)")
+		storageKind  = flag.String("storage", storage.BackendFile, "persistence backend: file, sqlite (requires -tags sqlite build), or memory")
+		dataDir      = flag.String("data-dir", config.DefaultDataPath(""), "directory for persisted state, events, and reports")
+		pidFile      = flag.String("tun2socks-pidfile", config.DefaultDataPath("tun2socks.pid"), "tun2socks pidfile used for orphan detection")
+		recoveryMode = flag.String("recovery", "report", "orphan handling at startup: report or auto")
+		enablePprof  = flag.Bool("pprof", false, "serve net/http/pprof at /debug/pprof/ to admin callers")
+		configPath   = flag.String("config", config.DefaultPath(), "path to JSON config file (flags override file values)")
 	)
 	flag.Parse()
 
-	logger := log.Default()
+	// Config file values apply only to flags not set on the command line.
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "agent: %v\n", err)
+		os.Exit(2)
+	}
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["listen"] && cfg.Listen != "" {
+		*addr = cfg.Listen
+	}
+	if !set["shutdown-secs"] && cfg.ShutdownSecs > 0 {
+		*shutdownSecs = cfg.ShutdownSecs
+	}
+	if !set["log-level"] && cfg.LogLevel != "" {
+		*logLevel = cfg.LogLevel
+	}
+	if !set["log-format"] && cfg.LogFormat != "" {
+		*logFormat = cfg.LogFormat
+	}
+	if !set["display-tz"] && cfg.DisplayTZ != "" {
+		*displayTZ = cfg.DisplayTZ
+	}
+	if !set["storage"] && cfg.Storage != "" {
+		*storageKind = cfg.Storage
+	}
+	if !set["data-dir"] && cfg.DataDir != "" {
+		*dataDir = cfg.DataDir
+	}
+	if !set["listen-tls"] && cfg.ListenTLS {
+		*listenTLS = true
+	}
+	if !set["tls-cert"] && cfg.TLSCertFile != "" {
+		*tlsCert = cfg.TLSCertFile
+	}
+	if !set["tls-key"] && cfg.TLSKeyFile != "" {
+		*tlsKey = cfg.TLSKeyFile
+	}
+	if !set["pprof"] && cfg.Pprof {
+		*enablePprof = true
+	}
+
+	logger, err := logging.New(logging.Options{Level: *logLevel, Format: *logFormat})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "agent: %v\n", err)
+		os.Exit(2)
+	}
+	// Route stray log.Printf calls (stdlib, third-party) through the same handler.
+	slog.SetDefault(logger)
+
+	var displayLoc *time.Location
+	if *displayTZ != "" {
+		displayLoc, err = time.LoadLocation(*displayTZ)
+		if err != nil {
+			logger.Error("invalid -display-tz", "tz", *displayTZ, "err", err)
+			os.Exit(2)
+		}
+	}
+
+	// Persistence backend shared by state, events, and shutdown reports.
+	location := *dataDir
+	if *storageKind == "sqlite" {
+		location = filepath.Join(*dataDir, "agent.db")
+	}
+	store, storageErr := storage.Open(*storageKind, location)
+	if storageErr != nil {
+		logger.Warn("storage unavailable; persisting in memory only", "backend", *storageKind, "location", location, "err", storageErr)
+		store = storage.NewMemory()
+	} else {
+		logger.Info("storage opened", "backend", *storageKind, "location", location)
+	}
+
+	previous, err := api.LoadShutdownReport(store)
+	if err != nil {
+		logger.Warn("ignoring unreadable shutdown report", "err", err)
+	} else if previous != nil {
+		logger.Info("previous shutdown report found", "reason", previous.Reason,
+			"route_restore", previous.RouteRestore, "finished_at", previous.FinishedAt)
+	}
 
 	// Core state initialization
 	state := core.NewState()
+	state.SetLogger(logging.Component(logger, logging.ComponentCore))
+	if h := cfg.Health; h != nil {
+		state.SetHysteresis(core.Hysteresis{
+			FailThreshold:    h.FailThreshold,
+			RecoverThreshold: h.RecoverThreshold,
+			MinHold:          time.Duration(h.MinHoldMS) * time.Millisecond,
+		})
+		state.SetRegression(core.Regression{
+			Factor:      h.RegressionFactor,
+			MinSamples:  h.RegressionMinSamples,
+			MinIncrease: time.Duration(h.RegressionMinIncreaseMS) * time.Millisecond,
+		})
+		if w := h.ScoreWeights; w != nil {
+			state.SetScoreWeights(core.ScoreWeights{
+				Reachability: w.Reachability,
+				Latency:      w.Latency,
+				Restarts:     w.Restarts,
+				DNS:          w.DNS,
+			})
+		}
+		state.SetWarningLimits(core.WarningLimits{
+			Max: h.MaxWarnings,
+			TTL: time.Duration(h.WarningTTLMS) * time.Millisecond,
+		})
+	}
+
+	// Subsystem health: optional pieces that fail degrade the boot instead of
+	// aborting it; /v1/status reports each one.
+	if storageErr != nil {
+		state.SetSubsystem("storage", core.SubsystemDegraded, "using memory; "+storageErr.Error())
+	} else {
+		state.SetSubsystem("storage", core.SubsystemOK, *storageKind)
+	}
+
+	// Event journal: reload recent events so IDs keep increasing across restarts.
+	journal, events, err := persist.OpenEventJournal(store, core.DefaultEventCapacity)
+	if err != nil {
+		logger.Warn("event journal disabled", "err", err)
+		state.SetSubsystem("event_journal", core.SubsystemFailed, err.Error())
+	} else {
+		state.SetSubsystem("event_journal", core.SubsystemOK, "")
+		state.Events().Seed(events)
+		state.Events().SetSink(journal, func(err error) {
+			logger.Error("event journal write failed", "err", err)
+		})
+	}
+
+	// Restore persisted state, then keep it current in the background.
+	stateStore := persist.NewStateStore(store)
+	rec, ok, err := stateStore.Load()
+	switch {
+	case err != nil:
+		logger.Warn("ignoring unreadable persisted state", "err", err)
+	case ok:
+		if persist.Restore(state, rec) {
+			logger.Warn("previous run ended uncleanly; restored in error state",
+				"previous_state", rec.Agent, "saved_at", rec.SavedAt)
+		} else {
+			logger.Info("state restored")
+		}
+	}
+	// The writer is restartable: an upgrade stops it, with a final save,
+	// before handing over, so the new agent loads the latest state.
+	var (
+		persistMu   sync.Mutex
+		stopPersist = func() {}
+	)
+	startPersist := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			persist.Run(ctx, state, stateStore, persist.DefaultDebounce,
+				logging.Component(logger, logging.ComponentCore))
+		}()
+		persistMu.Lock()
+		stopPersist = func() { cancel(); <-done }
+		persistMu.Unlock()
+	}
+	startPersist()
+
+	// Exporters: stream events to the sinks listed in the config file.
+	exportCtx, stopExport := context.WithCancel(context.Background())
+	exportDone := make(chan struct{})
+	var sinks []export.Named
+	for _, e := range cfg.Exports {
+		name := e.Name
+		if name == "" {
+			name = e.Type
+		}
+		sink, err := export.Open(e.Type, e.Options)
+		if err != nil {
+			logger.Error("export sink disabled", "sink", name, "err", err)
+			state.SetSubsystem("export "+name, core.SubsystemFailed, err.Error())
+			continue
+		}
+		state.SetSubsystem("export "+name, core.SubsystemOK, e.Type)
+		sinks = append(sinks, export.Named{Name: name, Sink: sink})
+		logger.Info("export sink opened", "sink", name, "type", e.Type)
+	}
+	if len(sinks) > 0 {
+		go func() {
+			defer close(exportDone)
+			export.Run(exportCtx, state, sinks, export.DefaultFlushInterval, logger)
+		}()
+	} else {
+		close(exportDone)
+	}
+	// Streaming API clients share one event subscription through the hub,
+	// which buffers per client; flow producers publish to it as well.
+	streamHub := stream.NewHub()
+	go streamHub.Run(exportCtx, state)
+
+	// Lifecycle hooks: user executables run on state transitions, with the
+	// status document on stdin.
+	var lifecycleHooks []hooks.Hook
+	for _, h := range cfg.Hooks {
+		hook := hooks.Hook{
+			Name:    h.Name,
+			On:      core.AgentState(h.On),
+			Command: h.Command,
+			Args:    h.Args,
+			Timeout: time.Duration(h.TimeoutMS) * time.Millisecond,
+		}
+		if err := hook.Validate(); err != nil {
+			logger.Error("invalid config", "err", fmt.Errorf("hooks: %w", err))
+			os.Exit(2)
+		}
+		lifecycleHooks = append(lifecycleHooks, hook)
+	}
+	if len(lifecycleHooks) > 0 {
+		status := func(snap core.Snapshot) any { return api.FromCoreSnapshot(snap) }
+		go hooks.Run(exportCtx, state, lifecycleHooks, status, logging.Component(logger, logging.ComponentOrchestrator))
+	}
+
+	// Webhooks: matching events POSTed to HTTP endpoints, each with its own
+	// queue and retries.
+	var webhookList []webhooks.Webhook
+	for _, h := range cfg.Webhooks {
+		hook := webhooks.Webhook{
+			Name:       h.Name,
+			URL:        h.URL,
+			Secret:     h.Secret,
+			Timeout:    time.Duration(h.TimeoutMS) * time.Millisecond,
+			MaxRetries: h.MaxRetries,
+		}
+		for _, t := range h.Types {
+			hook.Types = append(hook.Types, core.EventType(t))
+		}
+		err := hook.Validate()
+		if err == nil && h.MinSeverity != "" {
+			hook.MinSeverity, err = core.ParseSeverity(h.MinSeverity)
+			if err != nil {
+				err = fmt.Errorf("webhook %q: %w", hook.Name, err)
+			}
+		}
+		if err != nil {
+			logger.Error("invalid config", "err", fmt.Errorf("webhooks: %w", err))
+			os.Exit(2)
+		}
+		webhookList = append(webhookList, hook)
+	}
+	if len(webhookList) > 0 {
+		go webhooks.Run(exportCtx, state, webhookList, nil, logger.With("component", "webhooks"))
+	}
+
+	// tun2socks output: the supervisor attaches the child's stdout and
+	// stderr here; served at /v1/tun2socks/logs.
+	tun2socksLogs := tun2socks.NewLogBuffer(tun2socks.DefaultLogLines)
+
+	// tun2socks engine: the flavor picks the binary's command-line dialect.
+	var t2sConf config.Tun2Socks
+	if cfg.Tun2Socks != nil {
+		t2sConf = *cfg.Tun2Socks
+	}
+	if t2sConf.Engine == "" {
+		t2sConf.Engine = tun2socks.DefaultFlavor
+	}
+	flavor, ok := tun2socks.LookupFlavor(t2sConf.Engine)
+	if !ok {
+		logger.Error("invalid config", "err", fmt.Errorf("tun2socks.engine %q: want one of %v", t2sConf.Engine, tun2socks.Flavors()))
+		os.Exit(2)
+	}
+	t2sEngine := tun2socks.NewProcess(flavor, tun2socks.ProcessOptions{
+		Binary:    t2sConf.Binary,
+		ExtraArgs: t2sConf.ExtraArgs,
+		Logs:      tun2socksLogs,
+		Logger:    logger.With("component", "tun2socks"),
+	})
+	if path, err := exec.LookPath(t2sEngine.Stats().Binary); err != nil {
+		state.SetSubsystem("tun2socks", core.SubsystemDegraded, flavor.Name+": "+err.Error())
+	} else {
+		state.SetSubsystem("tun2socks", core.SubsystemOK, flavor.Name+" ("+path+")")
+	}
+	// The embedded engine exists only in netstack builds; start requests
+	// select it per request.
+	t2sEmbedded, err := tun2socks.NewEmbedded(tun2socks.EmbeddedOptions{
+		Logs:   tun2socksLogs,
+		Logger: logger.With("component", "tun2socks"),
+	})
+	if err != nil {
+		logger.Debug("embedded tun2socks engine unavailable", "err", err)
+	}
+	// WireGuard upstreams run in-process on wireguard-go.
+	t2sWireGuard := tun2socks.NewWireGuard(tun2socks.WireGuardOptions{
+		Logs:   tun2socksLogs,
+		Logger: logger.With("component", "tun2socks"),
+	})
+	// Engines that relay flows in-process report them for
+	// /v1/connections.
+	go tun2socks.RunConnections(exportCtx, state, tun2socks.DefaultConnectionsInterval, t2sEngine, t2sEmbedded, t2sWireGuard)
+	// Traffic totals and rates for status come from the TUN's counters.
+	go tun2socks.RunTraffic(exportCtx, state, tun2socks.DefaultTrafficInterval)
+
+	// Domain split-tunnel rules: restored from storage; routes come from DNS
+	// answers once the data plane feeds packets to the engine.
+	patterns, apps, err := rules.LoadRules(store)
+	if err != nil {
+		logger.Warn("ignoring unreadable split-tunnel rules", "err", err)
+		state.SetSubsystem("rules", core.SubsystemDegraded, "stored rules unreadable: "+err.Error())
+	} else {
+		state.SetSubsystem("rules", core.SubsystemOK, fmt.Sprintf("%d rule(s), %d app rule(s)", len(patterns), len(apps)))
+	}
+	ruleEngine := rules.NewEngine(patterns, apps, rules.Options{
+		KV:     store,
+		Owner:  procowner.Lookup,
+		Logger: logging.Component(logger, logging.ComponentOrchestrator),
+	})
+	rulesCtx, stopRules := context.WithCancel(context.Background())
+	rulesDone := make(chan struct{})
+	go func() {
+		defer close(rulesDone)
+		ruleEngine.Run(rulesCtx, rules.DefaultExpireEvery)
+	}()
+
+	// DNS forwarder: restore resolvers left rewritten by an unclean exit,
+	// whether or not the forwarder is still configured.
+	sysResolvers := dnsproxy.OSResolvers(*dataDir)
+	if restored, err := sysResolvers.Restore(); err != nil {
+		logger.Error("restore system resolvers failed", "err", err)
+		state.AppendWarning(core.NewWarning("dns", "resolvers_not_restored", "system resolvers left rewritten by a previous run could not be restored: "+err.Error()))
+	} else if restored {
+		logger.Warn("restored system resolvers left rewritten by a previous run")
+		state.RecordEvent(core.EventWarning, "restored system resolvers after unclean exit", nil)
+	}
+	// The leak test watches what the forwarder relays.
+	leakObserver := leaktest.NewObserver()
+	var dnsForwarder *dnsproxy.Forwarder
+	if c := cfg.DNS; c == nil {
+		state.SetSubsystem("dns", core.SubsystemDisabled, "no dns section in config")
+	} else if upstreams, err := dnsUpstreams(c); err != nil {
+		logger.Warn("dns forwarder disabled", "err", err)
+		state.SetSubsystem("dns", core.SubsystemFailed, err.Error())
+	} else {
+		observeDNS := func(msg []byte) {
+			ruleEngine.ObserveDNS(msg)
+			leakObserver.ObserveDNS(msg)
+		}
+		fopts := dnsproxy.Options{
+			Upstreams: upstreams,
+			Port:      c.Port,
+			Timeout:   time.Duration(c.TimeoutMS) * time.Millisecond,
+			Observe:   observeDNS,
+			OnChange:  func(st dnsproxy.Status) { state.UpdateDNS(dnsSnapshot(st)) },
+			Logger:    logging.Component(logger, logging.ComponentOrchestrator),
+		}
+		if !c.KeepResolvers {
+			fopts.Resolvers = sysResolvers
+		}
+		dnsForwarder = dnsproxy.New(fopts)
+		state.UpdateDNS(dnsSnapshot(dnsForwarder.Status()))
+		detail := "upstream " + upstreams[0].String()
+		if len(upstreams) > 1 {
+			detail += fmt.Sprintf(" (+%d fallback)", len(upstreams)-1)
+		}
+		state.SetSubsystem("dns", core.SubsystemOK, detail)
+	}
+
+	// Minted API tokens: accepted alongside static tokens on listeners that
+	// require one. Digests only; secrets are shown once at mint time.
+	// Tokens from the config file join them and make every listener
+	// require a token.
+	tokenStore, openErr := tokens.Open(store)
+	configured, err := configTokens(cfg.APITokens)
+	if err == nil {
+		err = tokenStore.Configure(configured)
+	}
+	if err != nil {
+		logger.Error("invalid config", "err", fmt.Errorf("api_tokens: %w", err))
+		os.Exit(2)
+	}
+	if openErr != nil {
+		logger.Warn("ignoring unreadable api tokens", "err", openErr)
+		state.SetSubsystem("tokens", core.SubsystemDegraded, "stored tokens unreadable: "+openErr.Error())
+	} else {
+		state.SetSubsystem("tokens", core.SubsystemOK, fmt.Sprintf("%d token(s), %d from config", len(tokenStore.List()), len(configured)))
+	}
+
+	// Named upstream profiles for /v1/start, persisted like tokens.
+	profileStore, err := profiles.Open(store)
+	if err != nil {
+		logger.Warn("ignoring unreadable profiles", "err", err)
+		state.SetSubsystem("profiles", core.SubsystemDegraded, "stored profiles unreadable: "+err.Error())
+	} else {
+		state.SetSubsystem("profiles", core.SubsystemOK, fmt.Sprintf("%d profile(s)", len(profileStore.List())))
+	}
+
+	// Network location watcher: reports (or applies) the profile whose
+	// match rules fit the attached network, with an event on each switch.
+	var (
+		locWatcher *netloc.Watcher
+		stopLoc    = func() {}
+		locDone    = make(chan struct{})
+		selectConf config.ProfileSelect
+	)
+	if cfg.ProfileSelect != nil {
+		selectConf = *cfg.ProfileSelect
+	}
+	switch selectConf.Mode {
+	case "":
+		selectConf.Mode = profiles.SelectSuggest
+	case profiles.SelectOff, profiles.SelectSuggest, profiles.SelectApply:
+	default:
+		logger.Error("invalid config", "err", fmt.Errorf("profile_select.mode %q: want off, suggest, or apply", selectConf.Mode))
+		os.Exit(2)
+	}
+	if selectConf.Mode == profiles.SelectOff {
+		state.SetSubsystem("location", core.SubsystemDisabled, "disabled in config")
+		close(locDone)
+	} else {
+		selected := ""
+		locWatcher = netloc.New(netloc.Options{
+			Interval: time.Duration(selectConf.IntervalMS) * time.Millisecond,
+			OnChange: func(prev, next netloc.Location) {
+				state.SetSubsystem("location", core.SubsystemOK, next.String())
+				if !prev.IsZero() {
+					state.RecordEvent(core.EventOrchestration, "network location changed: "+next.String(), locationFields(next))
+					state.RecordTimeline(core.TimelineNetworkChange, "network changed", prev.String(), next.String())
+				}
+				p, why, ok := profileStore.Select(next)
+				switch {
+				case ok && p.Name != selected:
+					fields := locationFields(next)
+					fields["profile"], fields["matched"], fields["mode"] = p.Name, strings.Join(why, ", "), selectConf.Mode
+					verb := "suggested"
+					if selectConf.Mode == profiles.SelectApply {
+						verb = "applied"
+					}
+					state.RecordEvent(core.EventOrchestration, "profile "+verb+": "+p.Name+" ("+strings.Join(why, ", ")+")", fields)
+					selected = p.Name
+				case !ok && selected != "":
+					fields := locationFields(next)
+					fields["previous"] = selected
+					state.RecordEvent(core.EventOrchestration, "no profile matches "+next.String(), fields)
+					selected = ""
+				}
+			},
+			Logger: logging.Component(logger, logging.ComponentOrchestrator),
+		})
+		locWatcher.Check()
+		locCtx, cancel := context.WithCancel(context.Background())
+		stopLoc = cancel
+		go func() {
+			defer close(locDone)
+			locWatcher.Run(locCtx)
+		}()
+	}
+
+	// Stored SOCKS credentials for auth_ref; see "agent secret".
+	if secrets.Method() == "unsupported" {
+		state.SetSubsystem("secrets", core.SubsystemDisabled, "no credential store on this platform")
+	} else {
+		state.SetSubsystem("secrets", core.SubsystemOK, secrets.Method())
+	}
+
+	// Listeners: the config file list, or -listen alone; -unix-socket adds one.
+	var listeners []api.ListenerConfig
+	if set["listen"] || len(cfg.Listeners) == 0 {
+		lc := api.ListenerConfig{Network: api.NetworkTCP, Addr: *addr}
+		if *listenTLS {
+			lc.TLSCertFile, lc.TLSKeyFile, err = listenerTLS(*tlsCert, *tlsKey, *dataDir, *addr, logger)
+			if err != nil {
+				logger.Error("invalid -listen-tls", "err", err)
+				os.Exit(2)
+			}
+		}
+		listeners = append(listeners, lc)
+	} else {
+		for _, l := range cfg.Listeners {
+			listeners = append(listeners, api.ListenerConfig{
+				Network:     l.Network,
+				Addr:        l.Addr,
+				Scope:       api.Scope(l.Scope),
+				Token:       l.Token,
+				TLSCertFile: l.TLSCertFile,
+				TLSKeyFile:  l.TLSKeyFile,
+				SocketMode:  os.FileMode(l.SocketMode),
+				Optional:    l.Optional,
+			})
+		}
+	}
+	if *unixSocket != "" {
+		listeners = append(listeners, api.ListenerConfig{Network: api.NetworkUnix, Addr: *unixSocket, Optional: true})
+	}
+	// Under systemd socket activation the .socket unit binds them instead.
+	activated, err := sdactivate.Listeners()
+	if err != nil {
+		logger.Error("socket activation", "err", err)
+		os.Exit(1)
+	}
+	// Started by POST /v1/upgrade, the agent being replaced passes its
+	// listeners and waits for Ready below.
+	inherited, err := handover.Receive()
+	if err != nil {
+		logger.Error("upgrade handover", "err", err)
+		os.Exit(1)
+	}
+	if inherited != nil {
+		logger.Info("taking over listeners", "from_pid", inherited.From(), "listeners", len(inherited.Listeners()))
+		state.RecordEvent(core.EventOrchestration, fmt.Sprintf("upgrade: took over from pid %d", inherited.From()), map[string]string{
+			"kind":     "upgrade",
+			"from_pid": strconv.Itoa(inherited.From()),
+		})
+		activated = append(activated, inherited.Listeners()...)
+	}
+
+	// Privileged helper: with a helper section, route, firewall, and kill
+	// switch changes go to the root helper, so the agent runs unprivileged.
+	// A helper that fails its integrity check stops the agent.
+	var (
+		helper      *privhelper.Client
+		applyRoute  = routerepair.ApplyRoute
+		deleteRoute = routerepair.DeleteRoute
+		blockIPv6   = ipv6leak.Block
+		unblockIPv6 = ipv6leak.Unblock
+	)
+	if cfg.Helper != nil {
+		if cfg.Helper.SHA256 == "" {
+			logger.Error("helper.sha256 is required; see agent helper install")
+			os.Exit(2)
+		}
+		if _, err := privhelper.Verify(cfg.Helper.Path, cfg.Helper.SHA256); err != nil {
+			logger.Error("privileged helper failed its integrity check", "err", err)
+			os.Exit(1)
+		}
+		helper = privhelper.NewClient(cmp.Or(cfg.Helper.Socket, privhelper.DefaultSocket), cfg.Helper.SHA256)
+		applyRoute, deleteRoute = helper.ApplyRoute, helper.DeleteRoute
+		blockIPv6, unblockIPv6 = helper.BlockIPv6, helper.UnblockIPv6
+		if h, err := helper.Hello(); err != nil {
+			logger.Warn("privileged helper unreachable", "err", err)
+			state.SetSubsystem("helper", core.SubsystemDegraded, err.Error())
+		} else {
+			state.SetSubsystem("helper", core.SubsystemOK, fmt.Sprintf("tun %s, routes %s", h.TUN, h.Routes))
+		}
+		if os.Geteuid() == 0 {
+			logger.Warn("agent runs as root; the privileged helper only separates privileges for an unprivileged agent")
+		}
+	}
+
+	// Crash recovery: detect artifacts from a previous run.
+	if *recoveryMode != "report" && *recoveryMode != "auto" {
+		logger.Error("invalid -recovery", "mode", *recoveryMode)
+		os.Exit(2)
+	}
+	// With the helper, interfaces and the default route are changed through
+	// it like the engines' routes.
+	recoverySys := recovery.OSSystem()
+	if helper != nil {
+		recoverySys = helperRecovery{System: recoverySys, helper: helper}
+	}
+	recoverer := recovery.New(recoverySys, state, recovery.Options{
+		PIDFile: *pidFile,
+		Logger:  logging.Component(logger, logging.ComponentOrchestrator),
+	})
+	rep := recoverer.Scan(context.Background())
+	if len(rep.Warnings) > 0 {
+		state.SetSubsystem("recovery", core.SubsystemDegraded, strings.Join(rep.Warnings, "; "))
+	} else {
+		state.SetSubsystem("recovery", core.SubsystemOK, "")
+	}
+	if len(rep.Orphans) > 0 {
+		for _, o := range rep.Orphans {
+			logger.Warn("orphaned artifact", "kind", o.Kind, "resource", o.Resource, "detail", o.Detail)
+		}
+		if *recoveryMode == "auto" {
+			res := recoverer.Cleanup(context.Background())
+			logger.Info("automatic recovery finished", "actions", len(res.Actions),
+				"remaining", len(res.Remaining.Orphans), "reset", res.Reset)
+		} else {
+			state.AppendWarning(core.NewWarning("recovery", "orphaned_artifacts", fmt.Sprintf("%d orphaned artifact(s) from a previous run; see GET /v1/recovery", len(rep.Orphans))))
+		}
+	}
 
 	// API Server
+	// Health sweep probes from the config file.
+	var healthProbes []api.HealthProbe
+	for _, p := range cfg.Probes {
+		healthProbes = append(healthProbes, api.HealthProbe{
+			Name:    p.Name,
+			Type:    p.Type,
+			Target:  p.Target,
+			Timeout: time.Duration(p.TimeoutMS) * time.Millisecond,
+			Options: p.Options,
+		})
+	}
+
+	// Outbound interface preference; start requests may override it.
+	uplinks, err := routeplan.ParseUplinks("outbound_interfaces", cfg.OutboundInterfaces)
+	if err != nil {
+		logger.Error("invalid config", "err", err)
+		os.Exit(2)
+	}
+
+	limits, err := rateLimits(cfg.RateLimits)
+	if err != nil {
+		logger.Error("invalid config", "err", err)
+		os.Exit(2)
+	}
+
+	// Probe policy for non-admin callers; a named file must load.
+	var probePolicy *policy.Policy
+	if cfg.PolicyFile != "" {
+		probePolicy, err = policy.Load(cfg.PolicyFile)
+		if err != nil {
+			logger.Error("invalid config", "err", err)
+			os.Exit(2)
+		}
+		logger.Info("probe policy loaded", "path", cfg.PolicyFile, "rules", probePolicy.Summary())
+	}
+
+	// CORS for local web dashboards; off unless origins are configured.
+	var origins []string
+	for _, o := range cfg.AllowedOrigins {
+		n, err := api.ParseOrigin(o)
+		if err != nil {
+			logger.Error("invalid config", "err", fmt.Errorf("allowed_origins: %w", err))
+			os.Exit(2)
+		}
+		origins = append(origins, n)
+	}
+
+	// Uplink failover: keep the upstream connection on a usable physical
+	// link, preferring the configured order.
+	var (
+		uplinkMon    *uplink.Monitor
+		stopUplinks  = func() {}
+		uplinksDone  = make(chan struct{})
+		failoverConf config.Failover
+	)
+	if cfg.Failover != nil {
+		failoverConf = *cfg.Failover
+	}
+	if _, err := netinfo.GetDefaultRoutes(); errors.Is(err, netinfo.ErrUnsupported) || failoverConf.Disabled {
+		reason := "disabled in config"
+		if err != nil && !failoverConf.Disabled {
+			reason = err.Error()
+		}
+		state.SetSubsystem("uplink", core.SubsystemDisabled, reason)
+		close(uplinksDone)
+	} else {
+		uplinkMon = uplink.New(uplink.Options{
+			Preferred: uplinks,
+			Interval:  time.Duration(failoverConf.IntervalMS) * time.Millisecond,
+			OnSwitch: func(sw uplink.Switch) {
+				fields := map[string]string{"from": sw.From, "to": sw.To, "reason": sw.Reason}
+				if sw.From == "" {
+					state.RecordEvent(core.EventOrchestration, "uplink active: "+sw.To, fields)
+				} else {
+					state.RecordEvent(core.EventWarning, fmt.Sprintf("uplink failover: %s -> %s (%s)", sw.From, orNone(sw.To), sw.Reason), fields)
+				}
+				setUplinkSubsystem(state, uplinkMon.Status())
+			},
+			Logger: logging.Component(logger, logging.ComponentOrchestrator),
+		})
+		setUplinkSubsystem(state, uplinkMon.Check())
+		uplinkCtx, cancel := context.WithCancel(context.Background())
+		stopUplinks = cancel
+		go func() {
+			defer close(uplinksDone)
+			uplinkMon.Run(uplinkCtx)
+		}()
+	}
+
+	// Route repair: while active, re-apply planned routes that VPN
+	// software or a DHCP renewal removed or replaced.
+	var (
+		registry   = metrics.NewRegistry()
+		repairer   *routerepair.Repairer
+		stopRepair = func() {}
+		repairDone = make(chan struct{})
+		repairConf config.RouteRepair
+	)
+	if cfg.RouteRepair != nil {
+		repairConf = *cfg.RouteRepair
+	}
+	if _, err := netinfo.LookupRoute(netip.IPv4Unspecified()); errors.Is(err, netinfo.ErrUnsupported) || repairConf.Disabled {
+		reason := "disabled in config"
+		if err != nil && !repairConf.Disabled {
+			reason = err.Error()
+		}
+		state.SetSubsystem("route_repair", core.SubsystemDisabled, reason)
+		close(repairDone)
+	} else {
+		repairer = routerepair.New(routerepair.Options{
+			Active: func() bool {
+				s := state.GetSnapshot().AgentState
+				return s == core.StateActive || s == core.StateDegraded
+			},
+			Interval: time.Duration(repairConf.IntervalMS) * time.Millisecond,
+			Apply:    applyRoute,
+			OnRepair: func(rep routerepair.Repair) {
+				registry.ObserveRouteRepair(rep.Err == nil)
+				fields := map[string]string{
+					"kind":   "route_repair",
+					"dst":    rep.Route.Dst.String(),
+					"reason": string(rep.Reason),
+					"route":  string(rep.Route.Reason),
+				}
+				if rep.Err != nil {
+					fields["error"] = rep.Err.Error()
+					state.RecordEvent(core.EventWarning, fmt.Sprintf("route repair failed: %s (%s): %v", rep.Route.Dst, rep.Reason, rep.Err), fields)
+					return
+				}
+				state.RecordEvent(core.EventOrchestration, fmt.Sprintf("route repaired: %s (%s)", rep.Route.Dst, rep.Reason), fields)
+			},
+			Logger: logging.Component(logger, logging.ComponentOrchestrator),
+		})
+		state.SetSubsystem("route_repair", core.SubsystemOK, "")
+		repairCtx, cancel := context.WithCancel(context.Background())
+		stopRepair = cancel
+		go func() {
+			defer close(repairDone)
+			repairer.Run(repairCtx)
+		}()
+	}
+
+	// Proxy re-pinning: while active, follow a proxy hostname to new
+	// addresses and move its host routes along.
+	var (
+		pinner  *proxypin.Pinner
+		stopPin = func() {}
+		pinDone = make(chan struct{})
+		pinConf config.ProxyPin
+	)
+	if cfg.ProxyPin != nil {
+		pinConf = *cfg.ProxyPin
+	}
+	if repairer == nil || pinConf.Disabled {
+		reason := "disabled in config"
+		if repairer == nil && !pinConf.Disabled {
+			reason = "route changes not supported on this platform"
+		}
+		state.SetSubsystem("proxy_pin", core.SubsystemDisabled, reason)
+		close(pinDone)
+	} else {
+		pinner = proxypin.New(proxypin.Options{
+			Active: func() bool {
+				s := state.GetSnapshot().AgentState
+				return s == core.StateActive || s == core.StateDegraded
+			},
+			Interval: time.Duration(pinConf.IntervalMS) * time.Millisecond,
+			Apply:    applyRoute,
+			Delete:   deleteRoute,
+			OnChange: func(ch proxypin.Change) {
+				if len(ch.Routes) > 0 {
+					routes := state.GetSnapshot().Routes
+					routes.ProxyAddrs = nil
+					for _, rt := range ch.Routes {
+						routes.ProxyAddrs = append(routes.ProxyAddrs, rt.Dst.Addr().String())
+					}
+					state.UpdateRoutes(routes)
+					repairer.ReplaceRoutes(routeplan.ReasonProxy, ch.Routes)
+				}
+				fields := map[string]string{
+					"kind":     "proxy_repin",
+					"host":     ch.Host,
+					"old":      joinAddrs(ch.Old),
+					"new":      joinAddrs(ch.New),
+					"verified": strconv.FormatBool(ch.Verified),
+				}
+				msg := fmt.Sprintf("proxy %s moved: %s -> %s", ch.Host, joinAddrs(ch.Old), joinAddrs(ch.New))
+				switch {
+				case ch.Err != nil:
+					fields["error"] = ch.Err.Error()
+					state.RecordEvent(core.EventWarning, fmt.Sprintf("%s; re-pin failed: %v", msg, ch.Err), fields)
+				case ch.VerifyErr != nil:
+					fields["verify_error"] = ch.VerifyErr.Error()
+					state.RecordEvent(core.EventWarning, fmt.Sprintf("%s; verify failed: %v", msg, ch.VerifyErr), fields)
+				default:
+					state.RecordEvent(core.EventOrchestration, msg, fields)
+				}
+			},
+			Logger: logging.Component(logger, logging.ComponentOrchestrator),
+		})
+		state.SetSubsystem("proxy_pin", core.SubsystemOK, "")
+		pinCtx, cancel := context.WithCancel(context.Background())
+		stopPin = cancel
+		go func() {
+			defer close(pinDone)
+			pinner.Run(pinCtx)
+		}()
+	}
+
+	// Captive portal detection: check the uplink directly, outside the
+	// tunnel, and hold back auto-selected starts while a portal intercepts.
+	var (
+		detector    *captive.Detector
+		stopCaptive = func() {}
+		captiveDone = make(chan struct{})
+		captiveConf config.Captive
+	)
+	if cfg.Captive != nil {
+		captiveConf = *cfg.Captive
+	}
+	if captiveConf.Disabled {
+		state.SetSubsystem("captive", core.SubsystemDisabled, "disabled in config")
+		close(captiveDone)
+	} else {
+		detector = captive.New(captive.Options{
+			Interface: func() string {
+				if uplinkMon == nil {
+					return ""
+				}
+				return uplinkMon.Status().Active
+			},
+			Interval: time.Duration(captiveConf.IntervalMS) * time.Millisecond,
+			OnChange: func(prev, next captive.Status) {
+				fields := map[string]string{
+					"kind":      "captive_portal",
+					"old":       prev.State,
+					"new":       next.State,
+					"interface": next.Interface,
+				}
+				switch {
+				case next.State == captive.StatePortal:
+					fields["portal_url"] = next.PortalURL
+					state.RecordEvent(core.EventWarning, fmt.Sprintf("captive portal detected: %s", next.PortalURL), fields)
+				case prev.State == captive.StatePortal:
+					state.RecordEvent(core.EventOrchestration, fmt.Sprintf("captive portal cleared (%s)", next.State), fields)
+				}
+			},
+			Logger: logging.Component(logger, logging.ComponentOrchestrator),
+		})
+		state.SetSubsystem("captive", core.SubsystemOK, "")
+		captiveCtx, cancel := context.WithCancel(context.Background())
+		stopCaptive = cancel
+		go func() {
+			defer close(captiveDone)
+			detector.Check(captiveCtx)
+			detector.Run(captiveCtx)
+		}()
+	}
+
+	// Host firewall: rule sets installed by leak protection, torn down
+	// after every stop and at exit.
+	var fw *firewall.Firewall
+	fwBackend, fwErr := firewall.OSBackend()
+	if helper != nil {
+		fwBackend, fwErr = helper.FirewallBackend()
+	}
+	switch {
+	case cfg.Firewall != nil && cfg.Firewall.Disabled:
+		state.SetSubsystem("firewall", core.SubsystemDisabled, "disabled in config")
+	case fwErr != nil:
+		state.SetSubsystem("firewall", core.SubsystemDisabled, fwErr.Error())
+	default:
+		fw = firewall.New(fwBackend, logging.Component(logger, logging.ComponentOrchestrator))
+		// Sets left by an unclean exit keep filtering otherwise.
+		removed, err := fw.Teardown()
+		switch {
+		case err != nil:
+			logger.Warn("remove leftover firewall rules failed", "err", err)
+			state.RecordEvent(core.EventWarning, "remove leftover firewall rules failed: "+err.Error(), map[string]string{"kind": "firewall"})
+			state.SetSubsystem("firewall", core.SubsystemDegraded, fw.Backend()+"; leftover rules: "+err.Error())
+		case len(removed) > 0:
+			logger.Warn("removed firewall rules left by a previous run", "sets", removed)
+			state.RecordEvent(core.EventWarning, "removed firewall rules after unclean exit: "+strings.Join(removed, ", "), map[string]string{"kind": "firewall"})
+			fallthrough
+		default:
+			state.SetSubsystem("firewall", core.SubsystemOK, fw.Backend())
+		}
+	}
+
+	// IPv6 leak monitor: while an IPv4-only tunnel is up, report IPv6
+	// egress outside it and, with the kill switch, block IPv6 through the
+	// firewall, or with blackhole routes without one.
+	var (
+		v6Mon    *ipv6leak.Monitor
+		stopV6   = func() {}
+		v6Done   = make(chan struct{})
+		v6Config config.IPv6Leak
+	)
+	if cfg.IPv6Leak != nil {
+		v6Config = *cfg.IPv6Leak
+	}
+	if v6Config.KillSwitch {
+		// Routes left by an unclean exit block IPv6 for good otherwise.
+		if err := unblockIPv6(); err == nil {
+			logger.Warn("removed ipv6 kill switch routes left by a previous run")
+			state.RecordEvent(core.EventWarning, "removed ipv6 kill switch routes after unclean exit", map[string]string{"kind": "ipv6_leak"})
+		}
+	}
+	if v6Config.Disabled {
+		state.SetSubsystem("ipv6_leak", core.SubsystemDisabled, "disabled in config")
+		close(v6Done)
+	} else {
+		v6Block, v6Unblock := blockIPv6, unblockIPv6
+		if fw != nil {
+			v6Block = func() error {
+				return fw.Install(ipv6leak.FirewallSet, ipv6leak.FirewallRules(state.GetSnapshot().TUN.Name))
+			}
+			v6Unblock = func() error { return fw.Remove(ipv6leak.FirewallSet) }
+		}
+		v6Mon = ipv6leak.New(ipv6leak.Options{
+			Tunnel: func() (string, bool, bool) {
+				snap := state.GetSnapshot()
+				active := snap.AgentState == core.StateActive || snap.AgentState == core.StateDegraded
+				return snap.TUN.Name, active, snap.TUN.LocalIP6 != ""
+			},
+			KillSwitch: v6Config.KillSwitch,
+			Interval:   time.Duration(v6Config.IntervalMS) * time.Millisecond,
+			Block:      v6Block,
+			Unblock:    v6Unblock,
+			OnChange: func(prev, next ipv6leak.Status) {
+				fields := map[string]string{"kind": "ipv6_leak", "old": prev.State, "new": next.State}
+				var egress []string
+				for _, e := range next.Egress {
+					egress = append(egress, e.String())
+				}
+				if len(egress) > 0 {
+					fields["egress"] = strings.Join(egress, ", ")
+				}
+				if next.BlockErr != "" {
+					fields["error"] = next.BlockErr
+				}
+				switch next.State {
+				case ipv6leak.StateLeak:
+					msg := "ipv6 leaks outside the tunnel via " + fields["egress"]
+					if next.BlockErr != "" {
+						msg += "; kill switch failed: " + next.BlockErr
+					}
+					state.RecordEvent(core.EventWarning, msg, fields)
+					state.SetSubsystem("ipv6_leak", core.SubsystemDegraded, "leak via "+fields["egress"])
+				case ipv6leak.StateBlocked:
+					state.RecordEvent(core.EventWarning, "ipv6 blocked by kill switch: egress outside the tunnel via "+fields["egress"], fields)
+					state.SetSubsystem("ipv6_leak", core.SubsystemOK, "ipv6 blocked until stop")
+				default:
+					if prev.State == ipv6leak.StateLeak || prev.State == ipv6leak.StateBlocked {
+						state.RecordEvent(core.EventOrchestration, fmt.Sprintf("ipv6 leak %s -> %s", prev.State, next.State), fields)
+					}
+					state.SetSubsystem("ipv6_leak", core.SubsystemOK, v6Detail(v6Config.KillSwitch))
+				}
+			},
+			Logger: logging.Component(logger, logging.ComponentOrchestrator),
+		})
+		state.SetSubsystem("ipv6_leak", core.SubsystemOK, v6Detail(v6Config.KillSwitch))
+		v6Ctx, cancel := context.WithCancel(context.Background())
+		stopV6 = cancel
+		go func() {
+			defer close(v6Done)
+			v6Mon.Run(v6Ctx)
+		}()
+	}
+
+	// Egress lookups: the public address seen through the tunnel and
+	// directly over the uplink, for GET /v1/egress.
+	var egressConf config.Egress
+	if cfg.Egress != nil {
+		egressConf = *cfg.Egress
+	}
+	var resolver *egress.Resolver
+	if egressConf.Disabled {
+		state.SetSubsystem("egress", core.SubsystemDisabled, "disabled in config")
+	} else {
+		resolver, err = egress.New(egress.Options{
+			Provider: egressConf.Provider,
+			TTL:      time.Duration(egressConf.TTLMS) * time.Millisecond,
+			Interface: func() string {
+				if uplinkMon == nil {
+					return ""
+				}
+				return uplinkMon.Status().Active
+			},
+			Logger: logging.Component(logger, logging.ComponentOrchestrator),
+		})
+		if err != nil {
+			logger.Error("invalid config", "err", err)
+			os.Exit(2)
+		}
+		state.SetSubsystem("egress", core.SubsystemOK, "provider "+resolver.Provider())
+	}
+
+	// POST /v1/service/install writes a launch daemon that reruns this
+	// executable with these arguments.
+	launchJob := launchd.Job{Label: launchd.DefaultLabel, Args: os.Args[1:]}
+	if exe, err := os.Executable(); err == nil {
+		launchJob.Program = exe
+	}
+	underLaunchd := launchd.Under(launchJob.Label)
+	if underLaunchd {
+		logger.Info("running under launchd", "label", launchJob.Label)
+	}
+
+	// POST /v1/upgrade starts the same executable and arguments with the
+	// listeners. launchd stops a job's other processes when its main one
+	// exits, so a launch daemon is reloaded instead.
+	var upgrade func(context.Context, string, []net.Listener) (int, error)
+	var upgradedTo atomic.Int64
+	if handover.Supported() && !underLaunchd && launchJob.Program != "" {
+		upgrade = func(ctx context.Context, sha string, lns []net.Listener) (int, error) {
+			// Storage passes to the new agent: this one saves state and
+			// stops writing it and the event journal, unless it fails.
+			persistMu.Lock()
+			stopPersist()
+			persistMu.Unlock()
+			state.Events().SetSink(nil, nil)
+			pid, err := handover.Offer(ctx, handover.Options{
+				Binary:    launchJob.Program,
+				Args:      os.Args[1:],
+				SHA256:    sha,
+				Listeners: lns,
+			})
+			if err != nil {
+				if journal != nil {
+					state.Events().SetSink(journal, func(err error) {
+						logger.Error("event journal write failed", "err", err)
+					})
+				}
+				startPersist()
+				return 0, err
+			}
+			upgradedTo.Store(int64(pid))
+			return pid, nil
+		}
+	}
+
+	// POST /v1/shutdown hands its reason to the signal wait below.
+	apiExit := make(chan string, 1)
 	srv := api.NewServer(state, api.ServerOptions{
-		Addr:              *addr,
-		ReadTimeout:       5 * time.Second,
-		ReadHeaderTimeout: 2 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       60 * time.Second,
-		ShutdownTimeout:   time.Duration(*shutdownSecs) * time.Second,
-		Logger:            logger,
+		Addr:               *addr,
+		Listeners:          listeners,
+		Activated:          activated,
+		ReadTimeout:        5 * time.Second,
+		ReadHeaderTimeout:  2 * time.Second,
+		WriteTimeout:       10 * time.Second,
+		IdleTimeout:        60 * time.Second,
+		ShutdownTimeout:    time.Duration(*shutdownSecs) * time.Second,
+		Logger:             logger,
+		Metrics:            registry,
+		DisplayLocation:    displayLoc,
+		PreviousShutdown:   previous,
+		Recovery:           recoverer,
+		LAN:                discovery.Options{Gateway: recovery.OSSystem().DefaultGateway},
+		HealthProbes:       healthProbes,
+		Rules:              ruleEngine,
+		Stream:             streamHub,
+		Tokens:             tokenStore,
+		Profiles:           profileStore,
+		Location:           locWatcher,
+		ProfileSelect:      selectConf.Mode,
+		DNS:                dnsForwarder,
+		OutboundInterfaces: uplinks,
+		Uplinks:            uplinkMon,
+		RouteRepair:        repairer,
+		ProxyPin:           pinner,
+		Captive:            detector,
+		Egress:             resolver,
+		LeakObserver:       leakObserver,
+		IPv6Leak:           v6Mon,
+		Firewall:           fw,
+		RateLimits:         limits,
+		Policy:             probePolicy,
+		Secrets:            secrets.OSStore(),
+		AllowedOrigins:     origins,
+		Tun2Socks:          t2sEngine,
+		Embedded:           t2sEmbedded,
+		WireGuard:          t2sWireGuard,
+		Tun2SocksLogs:      tun2socksLogs,
+		Diagnostics:        api.DiagnosticsOptions{ConfigPath: *configPath, LogFiles: cfg.DiagnosticsLogs},
+		EnablePprof:        *enablePprof,
+		Service:            launchJob,
+		Upgrade:            upgrade,
+		RequestShutdown: func(reason string) {
+			select {
+			case apiExit <- reason:
+			default:
+			}
+		},
 	})
 
 	// Start API
-	srv.Start()
+	if err := srv.Start(); err != nil {
+		logger.Error("api start failed", "err", err)
+		_ = inherited.Fail(err)
+		os.Exit(1)
+	}
+	if err := inherited.Ready(); err != nil {
+		logger.Warn("upgrade handover ready failed", "err", err)
+	}
+
+	// Under systemd (Type=notify), report readiness now that the API
+	// serves, and keep the watchdog fed until exit.
+	if _, err := sdnotify.Notify(sdnotify.Ready + "\n" + sdnotify.Status("serving; agent "+string(state.GetSnapshot().AgentState))); err != nil {
+		logger.Warn("systemd notify failed", "err", err)
+	}
+	stopWatchdog := func() {}
+	if interval := sdnotify.WatchdogInterval(); interval > 0 {
+		wdCtx, cancel := context.WithCancel(context.Background())
+		stopWatchdog = cancel
+		go func() {
+			t := time.NewTicker(interval)
+			defer t.Stop()
+			for {
+				select {
+				case <-wdCtx.Done():
+					return
+				case <-t.C:
+					if _, err := sdnotify.Notify(sdnotify.Watchdog); err != nil {
+						logger.Warn("systemd watchdog notify failed", "err", err)
+					}
+				}
+			}
+		}()
+	}
+	// As a Windows service, a stop request from the service control
+	// manager ends the wait below like a signal.
+	service, err := winsvc.Start(winsvc.Name, func(reason string) {
+		select {
+		case apiExit <- reason:
+		default:
+		}
+	})
+	if err != nil {
+		logger.Warn("windows service start failed", "err", err)
+	}
 
 	// Handle shutdown signals
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
-	sig := <-signals
-	logger.Printf("agent: received signal %v, shutting down", sig)
+	var reason string
+	select {
+	case sig := <-signals:
+		reason = "signal: " + sig.String()
+		logger.Info("received signal, shutting down", "signal", sig.String())
+	case reason = <-apiExit:
+		logger.Info("shutdown requested via api", "reason", reason)
+	}
+	began := time.Now()
+	last := state.GetSnapshot()
+	flows := len(state.Connections().Connections)
+	// After an upgrade the new agent is the service; systemd follows it
+	// rather than stopping the unit.
+	notice := sdnotify.Stopping + "\n" + sdnotify.Status("shutting down: "+reason)
+	if pid := upgradedTo.Load(); pid != 0 {
+		notice = sdnotify.MainPID(int(pid)) + "\n" + sdnotify.Status("handed over to pid "+strconv.FormatInt(pid, 10))
+	}
+	if _, err := sdnotify.Notify(notice); err != nil {
+		logger.Warn("systemd notify failed", "err", err)
+	}
+	service.Stopping()
 
 	ctx := context.Background()
-	if err := srv.Stop(ctx); err != nil {
-		logger.Printf("agent: graceful shutdown error: %v", err)
+	stopErr := srv.Stop(ctx)
+	if stopErr != nil {
+		logger.Error("graceful shutdown failed", "err", stopErr)
+	}
+
+	t2sCtx, cancelT2S := context.WithTimeout(ctx, 5*time.Second)
+	t2sErr := t2sEngine.Stop(t2sCtx)
+	if t2sEmbedded != nil {
+		t2sErr = errors.Join(t2sErr, t2sEmbedded.Stop(t2sCtx))
+	}
+	t2sErr = errors.Join(t2sErr, t2sWireGuard.Stop(t2sCtx))
+	cancelT2S()
+
+	var dnsErr error
+	if dnsForwarder != nil {
+		dnsErr = dnsForwarder.Stop() // restores the system resolvers
+	}
+	// With the engines stopped, traffic no longer needs the tunnel's route;
+	// after an upgrade the routes are the new agent's.
+	var routeErr error
+	if upgradedTo.Load() == 0 {
+		routeErr = recoverer.RestoreDefaultRoute(last)
+	}
+	if routeErr != nil {
+		logger.Error("restore default route failed", "err", routeErr)
+	}
+
+	report := api.NewShutdownReport(reason, began, last, srv.DrainStats(), flows, routeErr, []error{stopErr, t2sErr, dnsErr})
+	logger.Info("shutdown report", "route_restore", report.RouteRestore, "active_flows", report.ActiveFlows,
+		"open_conns", report.Drain.OpenConns, "in_flight", report.Drain.InFlight, "timed_out", report.Drain.TimedOut)
+	// The agent that took over writes the next report.
+	if upgradedTo.Load() == 0 {
+		if err := api.SaveShutdownReport(store, report); err != nil {
+			logger.Error("write shutdown report failed", "err", err)
+		}
+	}
+	// Flush exporters, then the final state write after teardown.
+	stopUplinks()
+	<-uplinksDone
+	stopRepair()
+	<-repairDone
+	stopPin()
+	<-pinDone
+	stopCaptive()
+	<-captiveDone
+	stopV6()
+	<-v6Done
+	if v6Mon != nil {
+		if err := v6Mon.Release(); err != nil {
+			logger.Error("lift ipv6 kill switch failed", "err", err)
+		}
+	}
+	// The firewall tables are shared with the agent that took over.
+	if fw != nil && upgradedTo.Load() == 0 {
+		if _, err := fw.Teardown(); err != nil {
+			logger.Error("firewall teardown failed", "err", err)
+		}
+	}
+	stopLoc()
+	<-locDone
+	stopRules()
+	<-rulesDone
+	stopExport()
+	<-exportDone
+	persistMu.Lock()
+	stopPersist()
+	persistMu.Unlock()
+	state.Events().SetSink(nil, nil) // flush the journal before closing storage
+	if err := store.Close(); err != nil {
+		logger.Error("close storage failed", "err", err)
+	}
+	stopWatchdog()
+	logger.Info("stopped")
+	if err := service.Exit(); err != nil {
+		logger.Error("windows service dispatcher failed", "err", err)
+	}
+}
+
+// joinAddrs renders addresses for event text and data.
+func joinAddrs(addrs []netip.Addr) string {
+	s := make([]string, len(addrs))
+	for i, a := range addrs {
+		s[i] = a.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// locationFields describes a network location in event data.
+func locationFields(loc netloc.Location) map[string]string {
+	fields := map[string]string{
+		"ssid":           loc.SSID,
+		"gateway_mac":    loc.GatewayMAC,
+		"search_domains": strings.Join(loc.SearchDomains, ","),
+	}
+	if loc.Gateway.IsValid() {
+		fields["gateway"] = loc.Gateway.String()
+	}
+	return fields
+}
+
+// dnsSnapshot maps forwarder status into core state.
+func dnsSnapshot(st dnsproxy.Status) core.DNSSnapshot {
+	return core.DNSSnapshot{
+		Listen:            st.Listen,
+		Upstream:          st.Upstream,
+		Resolvers:         st.Resolvers,
+		OriginalResolvers: st.OriginalResolvers,
+		Rewritten:         st.Rewritten,
+	}
+}
+
+// setUplinkSubsystem reports the active uplink and its usable standbys.
+func setUplinkSubsystem(state *core.State, st uplink.Status) {
+	var standby []string
+	for _, l := range st.Links {
+		if l.Usable && l.Interface != st.Active {
+			standby = append(standby, l.Interface)
+		}
+	}
+	switch {
+	case st.Active == "":
+		state.SetSubsystem("uplink", core.SubsystemDegraded, "no usable uplink")
+	case len(standby) == 0:
+		state.SetSubsystem("uplink", core.SubsystemOK, "active "+st.Active+"; no standby")
+	default:
+		state.SetSubsystem("uplink", core.SubsystemOK, "active "+st.Active+"; standby "+strings.Join(standby, ", "))
+	}
+}
+
+// v6Detail is the ipv6_leak subsystem detail outside a leak.
+func v6Detail(killSwitch bool) string {
+	if killSwitch {
+		return "kill switch on"
+	}
+	return "kill switch off"
+}
+
+// orNone renders an empty interface name in events.
+func orNone(name string) string {
+	if name == "" {
+		return "none"
+	}
+	return name
+}
+
+// rateLimits applies config overrides to api.DefaultRateLimits.
+func rateLimits(in map[string]config.RateLimit) (map[string]api.RateLimit, error) {
+	out := api.DefaultRateLimits()
+	for name, c := range in {
+		l, ok := out["/"+name]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("rate_limits: unknown endpoint %q (want probe, start, or stop)", name)
+		case c.PerSec < 0 || c.Burst < 0:
+			return nil, fmt.Errorf("rate_limits.%s: per_sec and burst must not be negative", name)
+		case c.Disabled:
+			l.Rate = 0
+		default:
+			if c.PerSec > 0 {
+				l.Rate = c.PerSec
+			}
+			if c.Burst > 0 {
+				l.Burst = c.Burst
+			}
+		}
+		out["/"+name] = l
+	}
+	return out, nil
+}
+
+// configTokens converts api_tokens entries; a missing scope means read.
+func configTokens(in []config.APIToken) ([]tokens.Token, error) {
+	out := make([]tokens.Token, 0, len(in))
+	for _, t := range in {
+		scope := api.Scope(t.Scope)
+		switch scope {
+		case "":
+			scope = api.ScopeReadOnly
+		case api.ScopeAdmin, api.ScopeOperate, api.ScopeReadOnly:
+		default:
+			return nil, fmt.Errorf("token %q: scope must be admin, operate, or read", t.Name)
+		}
+		out = append(out, tokens.Token{Name: t.Name, Scope: string(scope), Digest: t.SHA256})
+	}
+	return out, nil
+}
+
+// listenerTLS returns the key pair for -listen-tls: the named files, or a
+// self-signed pair in dataDir, generated for addr's host and loopback on
+// first run.
+func listenerTLS(certFile, keyFile, dataDir, addr string, logger *slog.Logger) (string, string, error) {
+	switch {
+	case certFile != "" && keyFile != "":
+		return certFile, keyFile, nil
+	case certFile != "" || keyFile != "":
+		return "", "", errors.New("-tls-cert and -tls-key must be set together")
+	}
+	certFile, keyFile = filepath.Join(dataDir, "api-cert.pem"), filepath.Join(dataDir, "api-key.pem")
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(addr); err == nil && !slices.Contains(hosts, host) {
+		hosts = append(hosts, host)
+	}
+	created, err := api.EnsureSelfSigned(certFile, keyFile, hosts)
+	if err != nil {
+		return "", "", err
+	}
+	if created {
+		logger.Info("generated self-signed api certificate", "cert", certFile, "hosts", strings.Join(hosts, ","))
+	}
+	return certFile, keyFile, nil
+}
+
+// dnsUpstreams parses the forwarder's upstream list; "upstream" is the
+// one-entry shorthand for "upstreams".
+func dnsUpstreams(c *config.DNS) ([]dnsproxy.Upstream, error) {
+	list := c.Upstreams
+	switch {
+	case c.Upstream != "" && len(list) > 0:
+		return nil, errors.New("dns: set upstream or upstreams, not both")
+	case c.Upstream != "":
+		list = []string{c.Upstream}
 	}
-	logger.Printf("agent: stopped")
+	return dnsproxy.ParseUpstreams(list)
 }
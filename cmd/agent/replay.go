@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/api"
+	"github.com/sanverite/simple-packet-logger/internal/config"
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/persist"
+)
+
+// replayReport is the -json output of agent replay. Every section goes
+// through the API mappers, so it reads like the live endpoints did.
+type replayReport struct {
+	Journal      string                    `json:"journal"`
+	SkippedLines int                       `json:"skipped_lines"` // malformed lines and flow records
+	Gaps         []string                  `json:"gaps"`          // missing event ID ranges
+	Events       api.EventsHistoryResponse `json:"events"`
+	Timeline     api.TimelineResponse      `json:"timeline"`
+	Status       api.StatusResponse        `json:"status"` // reconstructed after the last replayed event
+}
+
+// stringList collects a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+// runReplay implements "agent replay": it rebuilds what an agent went
+// through from its event journal (and optional state snapshots) without
+// touching the host, and returns the exit status.
+func runReplay(args []string, in io.Reader, out, errOut io.Writer) int {
+	fs := flag.NewFlagSet("agent replay", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	var states stringList
+	fs.Var(&states, "state", "persisted state record (state.json) to seed from; repeatable")
+	configPath := fs.String("config", "", "config file whose health hysteresis to replay with (default: built-in thresholds)")
+	until := fs.Uint64("until", 0, "stop after this event ID (0: replay everything)")
+	step := fs.Bool("step", false, "pause after each event; Enter continues, q quits")
+	asJSON := fs.Bool("json", false, "print the reconstructed events, timeline, and status as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(errOut, "usage: agent replay [flags] <journal.jsonl>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || (*step && *asJSON) {
+		fs.Usage()
+		return 2
+	}
+
+	events, skipped, err := loadJournal(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(errOut, "agent replay: %v\n", err)
+		return 1
+	}
+	var snaps []persist.Record
+	for _, p := range states {
+		rec, err := loadStateRecord(p)
+		if err != nil {
+			fmt.Fprintf(errOut, "agent replay: %v\n", err)
+			return 1
+		}
+		snaps = append(snaps, rec)
+	}
+	slices.SortStableFunc(snaps, func(a, b persist.Record) int { return a.SavedAt.Compare(b.SavedAt) })
+	var hyst core.Hysteresis
+	if *configPath != "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			fmt.Fprintf(errOut, "agent replay: %v\n", err)
+			return 2
+		}
+		if h := cfg.Health; h != nil {
+			hyst = core.Hysteresis{
+				FailThreshold:    h.FailThreshold,
+				RecoverThreshold: h.RecoverThreshold,
+				MinHold:          time.Duration(h.MinHoldMS) * time.Millisecond,
+			}
+		}
+	}
+	if *until > 0 {
+		i := 0
+		for i < len(events) && events[i].ID <= *until {
+			i++
+		}
+		events = events[:i]
+	}
+	if len(events) == 0 {
+		fmt.Fprintln(errOut, "agent replay: no events to replay")
+		return 1
+	}
+
+	r := newReplayer(hyst, snaps)
+	// Generated timestamps come from the journal, so output is reproducible.
+	api.TimeNow = func() time.Time { return r.now }
+
+	var stepIn *bufio.Reader
+	if *step {
+		stepIn = bufio.NewReader(in)
+	}
+	if !*asJSON {
+		fmt.Fprintf(out, "replaying %d events (#%d-#%d) from %s, %s to %s; %d state snapshots\n",
+			len(events), events[0].ID, events[len(events)-1].ID, fs.Arg(0),
+			events[0].At.UTC().Format(time.RFC3339), events[len(events)-1].At.UTC().Format(time.RFC3339), len(snaps))
+		if skipped > 0 {
+			fmt.Fprintf(out, "skipped %d malformed or non-event lines\n", skipped)
+		}
+	}
+	applied := 0
+	for i, e := range events {
+		for _, rec := range r.seedUntil(e.At) {
+			if !*asJSON {
+				fmt.Fprintf(out, "-- state snapshot saved %s: %s\n", rec.SavedAt.UTC().Format(time.RFC3339), summarizeStatus(r.status()))
+			}
+		}
+		if i > 0 && e.ID != events[i-1].ID+1 && !*asJSON {
+			fmt.Fprintf(out, "-- gap: events #%d-#%d missing\n", events[i-1].ID+1, e.ID-1)
+		}
+		r.apply(e)
+		applied++
+		if *asJSON {
+			continue
+		}
+		st := r.status()
+		fmt.Fprintf(out, "#%-5d %s  %-13s %s  [state=%s health=%s]\n",
+			e.ID, e.At.UTC().Format(time.RFC3339), e.Type, e.Message, st.State, st.Health.Status)
+		if stepIn != nil && i < len(events)-1 {
+			line, err := stepIn.ReadString('\n')
+			if strings.TrimSpace(line) == "q" || errors.Is(err, io.EOF) {
+				break
+			}
+		}
+	}
+	complete := applied == len(events)
+	events = events[:applied]
+	// A snapshot saved after the last event (state.json normally is) is the
+	// final state; skip it when replay stopped early.
+	if complete && *until == 0 {
+		for _, rec := range r.seedUntil(time.Unix(1<<62, 0)) {
+			if !*asJSON {
+				fmt.Fprintf(out, "-- state snapshot saved %s: %s\n", rec.SavedAt.UTC().Format(time.RFC3339), summarizeStatus(r.status()))
+			}
+		}
+	}
+
+	rep := replayReport{
+		Journal:      fs.Arg(0),
+		SkippedLines: skipped,
+		Gaps:         journalGaps(events),
+		Events:       api.FromEvents(events, false, events[len(events)-1].ID),
+		Timeline:     api.FromTimeline(events[0].At, r.timeline()),
+		Status:       r.status(),
+	}
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			fmt.Fprintf(errOut, "agent replay: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintln(out, "TIMELINE")
+	for _, t := range rep.Timeline.Entries {
+		fmt.Fprintf(out, "  %s  %-15s %s\n", t.At, t.Kind, t.Summary)
+	}
+	fmt.Fprintf(out, "FINAL STATUS\n  %s\n", summarizeStatus(rep.Status))
+	for _, w := range rep.Status.Warnings {
+		fmt.Fprintf(out, "  warning: %s\n", w)
+	}
+	return 0
+}
+
+// replayer rebuilds agent state from journal events. Probe results are fed
+// to a core.State at their original times, so damped health and its
+// timeline entries come from the same hysteresis code as the live agent.
+// Agent state is taken from the journal as recorded; warnings are folded
+// into that state too, so repeats count as they did live.
+type replayer struct {
+	probes *core.State
+	snaps  []persist.Record // pending, oldest first
+
+	base      core.Snapshot // latest state snapshot, or the zero state
+	agent     core.AgentState
+	stateLine []core.TimelineEntry
+	now       time.Time
+}
+
+func newReplayer(h core.Hysteresis, snaps []persist.Record) *replayer {
+	probes := core.NewState()
+	probes.SetHysteresis(h)
+	return &replayer{
+		probes: probes,
+		snaps:  snaps,
+		base:   core.Snapshot{AgentState: core.StateInactive},
+		agent:  core.StateInactive,
+	}
+}
+
+// seedUntil loads the state snapshots saved at or before t and returns them.
+func (r *replayer) seedUntil(t time.Time) []persist.Record {
+	var used []persist.Record
+	for len(r.snaps) > 0 && !r.snaps[0].SavedAt.After(t) {
+		rec := r.snaps[0]
+		r.snaps = r.snaps[1:]
+		r.base = rec.Snapshot()
+		r.agent = r.base.AgentState
+		// Health streaks are not persisted; the snapshot's status restarts them.
+		r.probes.Restore(r.base)
+		if rec.SavedAt.After(r.now) {
+			r.now = rec.SavedAt
+		}
+		used = append(used, rec)
+	}
+	return used
+}
+
+// apply folds one journal event into the reconstructed state.
+func (r *replayer) apply(e core.Event) {
+	r.now = e.At
+	switch e.Type {
+	case core.EventStateChange:
+		to := core.AgentState(e.Data["to"])
+		if to == "" {
+			return
+		}
+		r.stateLine = append(r.stateLine, core.TimelineEntry{
+			At: e.At, Kind: core.TimelineStateChange, Summary: e.Message, From: e.Data["from"], To: string(to),
+		})
+		r.agent = to
+	case core.EventProbeResult:
+		p := core.ProbeSummary{
+			Reachable:   e.Data["reachable"] == "true",
+			SocksOK:     e.Data["socks_ok"] == "true",
+			ConnectOK:   e.Data["connect_ok"] == "true",
+			UDPOK:       e.Data["udp_ok"] == "true",
+			LastChecked: e.At,
+		}
+		if w, ok := strings.CutPrefix(e.Message, "probe failed: "); ok {
+			p.Warnings = []core.Warning{{Severity: core.SeverityWarning, Message: w, Time: e.At}}
+		}
+		r.probes.UpdateProbe(p)
+	case core.EventWarning:
+		r.probes.AppendWarning(core.Warning{
+			Code: e.Data["code"], Severity: e.Severity(), Source: e.Data["source"], Message: e.Message, Time: e.At,
+		})
+	}
+}
+
+// status maps the reconstructed state through the /v1/status mapper.
+func (r *replayer) status() api.StatusResponse {
+	snap := r.base
+	probed := r.probes.GetSnapshot()
+	snap.AgentState = r.agent
+	snap.Warnings = probed.Warnings
+	snap.LastProbe = probed.LastProbe
+	snap.Health = probed.Health
+	snap.Subsystems = nil
+	return api.FromCoreSnapshot(snap)
+}
+
+// timeline merges state changes with the health transitions core derived.
+func (r *replayer) timeline() []core.TimelineEntry {
+	out := append(slices.Clone(r.stateLine), r.probes.Timeline(time.Time{})...)
+	slices.SortStableFunc(out, func(a, b core.TimelineEntry) int { return a.At.Compare(b.At) })
+	return out
+}
+
+// summarizeStatus renders the fields that matter when reading a replay.
+func summarizeStatus(s api.StatusResponse) string {
+	parts := []string{"state=" + s.State, "health=" + s.Health.Status}
+	if s.TUN.Name != "" {
+		parts = append(parts, "tun="+s.TUN.Name)
+	}
+	if s.Routes.DefaultVia != "" {
+		parts = append(parts, "default_via="+s.Routes.DefaultVia)
+	}
+	if s.Tun2Socks.PID != 0 {
+		parts = append(parts, "tun2socks_pid="+strconv.Itoa(s.Tun2Socks.PID))
+	}
+	if n := len(s.Warnings); n > 0 {
+		parts = append(parts, "warnings="+strconv.Itoa(n))
+	}
+	return strings.Join(parts, " ")
+}
+
+// journalLine accepts both the persisted journal (events.jsonl in the data
+// directory) and the jsonl exporter's output, which adds "kind".
+type journalLine struct {
+	Kind string `json:"kind"`
+	persist.EventRecord
+}
+
+// loadJournal reads events ordered by ID. Malformed lines (e.g. a torn
+// final write), flow records, and duplicate IDs are skipped and counted.
+func loadJournal(path string) ([]core.Event, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var events []core.Event
+	seen := map[uint64]bool{}
+	skipped := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var jl journalLine
+		if err := json.Unmarshal([]byte(line), &jl); err != nil || jl.ID == 0 || seen[jl.ID] ||
+			(jl.Kind != "" && jl.Kind != "event") {
+			skipped++
+			continue
+		}
+		seen[jl.ID] = true
+		events = append(events, core.Event{ID: jl.ID, At: jl.At, Type: core.EventType(jl.Type), Message: jl.Message, Data: jl.Data})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, 0, fmt.Errorf("read %s: %w", path, err)
+	}
+	slices.SortFunc(events, func(a, b core.Event) int { return cmp.Compare(a.ID, b.ID) })
+	return events, skipped, nil
+}
+
+// loadStateRecord reads a persisted state record.
+func loadStateRecord(path string) (persist.Record, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return persist.Record{}, err
+	}
+	var rec persist.Record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return persist.Record{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if rec.Version == 0 {
+		return persist.Record{}, fmt.Errorf("%s: not a state record", path)
+	}
+	return rec, nil
+}
+
+// journalGaps lists missing ID ranges between the first and last event.
+func journalGaps(events []core.Event) []string {
+	gaps := []string{}
+	for i := 1; i < len(events); i++ {
+		if prev, id := events[i-1].ID, events[i].ID; id != prev+1 {
+			gaps = append(gaps, fmt.Sprintf("%d-%d", prev+1, id-1))
+		}
+	}
+	return gaps
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sanverite/simple-packet-logger/internal/secrets"
+)
+
+// runSecret stores and deletes named SOCKS credentials in the platform
+// secret store, for use as auth_ref in probe and start requests. The
+// password is read from the first line of stdin so it never appears in
+// argv or shell history.
+func runSecret(args []string, store secrets.Store, in io.Reader, out, errOut io.Writer) int {
+	usage := func() {
+		fmt.Fprintln(errOut, "usage: agent secret set [-user name] <name> < password")
+		fmt.Fprintln(errOut, "       agent secret delete <name>")
+		fmt.Fprintln(errOut, "       agent secret show <name>")
+	}
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	fs := flag.NewFlagSet("agent secret "+args[0], flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.Usage = usage
+	user := fs.String("user", "", "SOCKS5 username (set only)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 || (*user != "" && args[0] != "set") {
+		usage()
+		return 2
+	}
+	name := fs.Arg(0)
+	if err := secrets.ValidName(name); err != nil {
+		fmt.Fprintf(errOut, "agent secret: %v\n", err)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "set":
+		var pass string
+		pass, err = bufio.NewReader(in).ReadString('\n')
+		if err == io.EOF {
+			err = nil
+		}
+		pass = strings.TrimRight(pass, "\r\n")
+		if err == nil && pass == "" {
+			err = errors.New("no password on stdin")
+		}
+		if err == nil {
+			err = store.Set(name, secrets.Credential{Username: *user, Password: pass})
+		}
+		if err == nil {
+			fmt.Fprintf(out, "stored %s in %s; use \"auth_ref\": %q\n", name, secrets.Method(), name)
+		}
+	case "delete":
+		if err = store.Delete(name); err == nil {
+			fmt.Fprintf(out, "deleted %s\n", name)
+		}
+	case "show":
+		var c secrets.Credential
+		if c, err = store.Get(name); err == nil {
+			fmt.Fprintf(out, "name: %s\nusername: %s\npassword: (%d characters)\n", name, orNone(c.Username), len(c.Password))
+		}
+	default:
+		usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "agent secret %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,43 @@
+// Command scenario runs scripted lifecycle scenarios against a simulated
+// agent and checks the resulting states and events, so lifecycle logic can
+// be regression-tested without a TUN device, root, or a real proxy.
+//
+// Usage:
+//
+//   scenario [-v] <file.yaml|dir>...
+//
+// Each scenario gets a fresh core.State driven by the simulate backend,
+// which plays the orchestrator and tun2socks supervisor: start brings up
+// the TUN, routes, and tun2socks after a probe; faults are injected by
+// dropping the proxy or killing tun2socks; the supervisor degrades and
+// recovers the agent on probes, through the same health hysteresis as the
+// agent. Probe times come from a simulated clock.
+//
+// Scenario files use a YAML subset (block maps and lists, scalars, flow
+// lists of scalars, comments):
+//
+//   name: tun2socks crash and recovery
+//   probe_interval_ms: 5000        # simulated time between probes
+//   health:                        # as in the agent config file
+//     fail_threshold: 2
+//   steps:
+//     - do: start                  # socks: host:port (default 127.0.0.1:1080)
+//       expect:
+//         state: active
+//         events: [agent starting -> active]
+//     - do: kill_tun2socks
+//       expect:
+//         state: degraded
+//         tun2socks: stopped
+//         warnings: [tun2socks exited]
+//
+// Actions: start, stop, kill_tun2socks, restart_tun2socks, drop_proxy,
+// restore_proxy, probe (count: N), and wait (ms: N). Expectations: state,
+// health, tun2socks (running|stopped), routes (installed|restored),
+// warnings (substrings; [] for none), events (message substrings recorded
+// by the step, in order), and error (the step must fail with this
+// substring). Every step runs even after a failure.
+//
+// Exit status is 0 when every scenario passes, 1 when any fails, and 2 on
+// usage or script errors. Example scripts live in scenarios/.
+package main
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	fs := flag.NewFlagSet("scenario", flag.ContinueOnError)
+	verbose := fs.Bool("v", false, "print every step and the events it recorded")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: scenario [-v] <file.yaml|dir>...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	files, err := expand(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "scenario: %v\n", err)
+		os.Exit(2)
+	}
+	scenarios := make([]Scenario, 0, len(files))
+	for _, f := range files {
+		sc, err := load(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "scenario: %v\n", err)
+			os.Exit(2)
+		}
+		scenarios = append(scenarios, sc)
+	}
+
+	failed := 0
+	for _, sc := range scenarios {
+		if *verbose {
+			fmt.Printf("RUN  %s\n", sc.Name)
+		}
+		res := run(sc, *verbose, os.Stdout)
+		if len(res.Failures) == 0 {
+			fmt.Printf("PASS %s (%d steps)\n", res.Name, res.Steps)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s\n", res.Name)
+		for _, f := range res.Failures {
+			fmt.Printf("     %s\n", f)
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", len(scenarios)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// expand replaces directories with the .yaml and .yml files they contain.
+func expand(args []string) ([]string, error) {
+	var out []string
+	for _, a := range args {
+		fi, err := os.Stat(a)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			out = append(out, a)
+			continue
+		}
+		for _, pat := range []string{"*.yaml", "*.yml"} {
+			m, err := filepath.Glob(filepath.Join(a, pat))
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, m...)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no scenario files in %v", args)
+	}
+	return out, nil
+}
+
+// load reads and validates one scenario file.
+func load(path string) (Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Scenario{}, err
+	}
+	var sc Scenario
+	if err := decodeYAML(b, &sc); err != nil {
+		return Scenario{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := sc.validate(); err != nil {
+		return Scenario{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
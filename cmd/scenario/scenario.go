@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/config"
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// defaultProbeInterval is the simulated time between probes.
+const defaultProbeInterval = 5 * time.Second
+
+// Step actions.
+const (
+	actStart            = "start"
+	actStop             = "stop"
+	actKillTun2Socks    = "kill_tun2socks"
+	actRestartTun2Socks = "restart_tun2socks"
+	actDropProxy        = "drop_proxy"
+	actRestoreProxy     = "restore_proxy"
+	actProbe            = "probe"
+	actWait             = "wait"
+)
+
+// Scenario is one scripted run against a fresh simulated agent.
+type Scenario struct {
+	Name            string         `json:"name"`
+	Description     string         `json:"description,omitempty"`
+	ProbeIntervalMS int64          `json:"probe_interval_ms,omitempty"` // default 5000
+	Health          *config.Health `json:"health,omitempty"`            // hysteresis, as in the config file
+	Steps           []Step         `json:"steps"`
+}
+
+// Step is one action and what must hold after it.
+type Step struct {
+	Do     string `json:"do"`
+	SOCKS  string `json:"socks,omitempty"` // start; default 127.0.0.1:1080
+	Count  int    `json:"count,omitempty"` // probe; default 1
+	MS     int64  `json:"ms,omitempty"`    // wait
+	Expect Expect `json:"expect"`
+}
+
+// Expect lists assertions; empty fields are not checked.
+type Expect struct {
+	State     string    `json:"state,omitempty"`     // agent state
+	Health    string    `json:"health,omitempty"`    // damped proxy health
+	Tun2Socks string    `json:"tun2socks,omitempty"` // "running" or "stopped"
+	Routes    string    `json:"routes,omitempty"`    // "installed" or "restored"
+	Warnings  *[]string `json:"warnings,omitempty"`  // substrings that must all be present; [] means none
+	Events    []string  `json:"events,omitempty"`    // substrings of messages recorded by the step, in order
+	Error     string    `json:"error,omitempty"`     // the step must fail with this substring
+}
+
+// validate checks the script before anything runs.
+func (sc *Scenario) validate() error {
+	if sc.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(sc.Steps) == 0 {
+		return fmt.Errorf("steps are required")
+	}
+	if sc.ProbeIntervalMS < 0 {
+		return fmt.Errorf("probe_interval_ms must not be negative")
+	}
+	for i, st := range sc.Steps {
+		switch st.Do {
+		case actStart, actStop, actKillTun2Socks, actRestartTun2Socks, actDropProxy, actRestoreProxy:
+		case actProbe:
+			if st.Count < 0 {
+				return fmt.Errorf("step %d: count must not be negative", i+1)
+			}
+		case actWait:
+			if st.MS <= 0 {
+				return fmt.Errorf("step %d: wait needs ms > 0", i+1)
+			}
+		default:
+			return fmt.Errorf("step %d: unknown action %q", i+1, st.Do)
+		}
+		e := st.Expect
+		if e.Tun2Socks != "" && e.Tun2Socks != "running" && e.Tun2Socks != "stopped" {
+			return fmt.Errorf("step %d: expect.tun2socks must be running or stopped", i+1)
+		}
+		if e.Routes != "" && e.Routes != "installed" && e.Routes != "restored" {
+			return fmt.Errorf("step %d: expect.routes must be installed or restored", i+1)
+		}
+	}
+	return nil
+}
+
+// Result is the outcome of one scenario.
+type Result struct {
+	Name     string
+	Steps    int      // steps run
+	Failures []string // one per failed step
+}
+
+// run executes sc against a fresh state. With verbose, every step and the
+// events it recorded are written to out. A failed step does not stop the
+// run, so one script reports every broken expectation.
+func run(sc Scenario, verbose bool, out io.Writer) Result {
+	state := core.NewState()
+	if h := sc.Health; h != nil {
+		state.SetHysteresis(core.Hysteresis{
+			FailThreshold:    h.FailThreshold,
+			RecoverThreshold: h.RecoverThreshold,
+			MinHold:          time.Duration(h.MinHoldMS) * time.Millisecond,
+		})
+	}
+	interval := defaultProbeInterval
+	if sc.ProbeIntervalMS > 0 {
+		interval = time.Duration(sc.ProbeIntervalMS) * time.Millisecond
+	}
+	sm := newSim(state, interval)
+
+	res := Result{Name: sc.Name}
+	var cursor uint64
+	for i, st := range sc.Steps {
+		err := apply(sm, st)
+		events, _, last := state.Events().After(cursor, 0)
+		cursor = last
+		res.Steps++
+
+		problems := check(state.GetSnapshot(), events, st.Expect, err)
+		if verbose {
+			mark := "ok"
+			if len(problems) > 0 {
+				mark = "FAIL"
+			}
+			fmt.Fprintf(out, "  %-4s %2d %s\n", mark, i+1, st.Do)
+			for _, e := range events {
+				fmt.Fprintf(out, "           %-13s %s\n", e.Type, e.Message)
+			}
+		}
+		if len(problems) > 0 {
+			res.Failures = append(res.Failures, fmt.Sprintf("step %d (%s): %s", i+1, st.Do, strings.Join(problems, "; ")))
+		}
+	}
+	return res
+}
+
+func apply(sm *sim, st Step) error {
+	switch st.Do {
+	case actStart:
+		return sm.start(st.SOCKS)
+	case actStop:
+		return sm.stop()
+	case actKillTun2Socks:
+		return sm.killTun2Socks()
+	case actRestartTun2Socks:
+		return sm.restartTun2Socks()
+	case actDropProxy:
+		sm.proxyUp = false
+	case actRestoreProxy:
+		sm.proxyUp = true
+	case actProbe:
+		sm.probe(max(st.Count, 1))
+	case actWait:
+		sm.wait(time.Duration(st.MS) * time.Millisecond)
+	}
+	return nil
+}
+
+// check compares the state after a step with its expectations.
+func check(snap core.Snapshot, events []core.Event, e Expect, err error) []string {
+	var problems []string
+	switch {
+	case e.Error == "" && err != nil:
+		problems = append(problems, "unexpected error: "+err.Error())
+	case e.Error != "" && err == nil:
+		problems = append(problems, fmt.Sprintf("error = nil, want %q", e.Error))
+	case e.Error != "" && !strings.Contains(err.Error(), e.Error):
+		problems = append(problems, fmt.Sprintf("error = %q, want %q", err, e.Error))
+	}
+	if e.State != "" && string(snap.AgentState) != e.State {
+		problems = append(problems, fmt.Sprintf("state = %s, want %s", snap.AgentState, e.State))
+	}
+	if e.Health != "" && string(snap.Health.Status) != e.Health {
+		problems = append(problems, fmt.Sprintf("health = %s, want %s", snap.Health.Status, e.Health))
+	}
+	if e.Tun2Socks != "" {
+		got := "stopped"
+		if snap.Tun2Socks.PID != 0 {
+			got = "running"
+		}
+		if got != e.Tun2Socks {
+			problems = append(problems, fmt.Sprintf("tun2socks = %s, want %s", got, e.Tun2Socks))
+		}
+	}
+	if e.Routes != "" {
+		got := "restored"
+		if snap.Routes.DefaultVia != "" {
+			got = "installed"
+		}
+		if got != e.Routes {
+			problems = append(problems, fmt.Sprintf("routes = %s, want %s", got, e.Routes))
+		}
+	}
+	if e.Warnings != nil {
+		want := *e.Warnings
+		msgs := core.WarningMessages(snap.Warnings)
+		if len(want) == 0 && len(msgs) > 0 {
+			problems = append(problems, fmt.Sprintf("warnings = %q, want none", msgs))
+		}
+		for _, w := range want {
+			if !slices.ContainsFunc(msgs, func(s string) bool { return strings.Contains(s, w) }) {
+				problems = append(problems, fmt.Sprintf("no warning containing %q", w))
+			}
+		}
+	}
+	// Expected events must appear in order; others may be interleaved.
+	next := 0
+	for _, ev := range events {
+		if next < len(e.Events) && strings.Contains(ev.Message, e.Events[next]) {
+			next++
+		}
+	}
+	if next < len(e.Events) {
+		problems = append(problems, fmt.Sprintf("no event containing %q in order", e.Events[next]))
+	}
+	return problems
+}
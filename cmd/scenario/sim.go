@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// Simulated host values reported in state.
+const (
+	simTUN       = "utun9"
+	simLocalIP   = "198.18.0.1"
+	simPeerIP    = "198.18.0.2"
+	simGateway   = "192.168.1.1"
+	simMTU       = 1500
+	simFirstPID  = 4242
+	defaultSOCKS = "127.0.0.1:1080"
+)
+
+// sim is the simulate backend: it plays the orchestrator and tun2socks
+// supervisor against core.State without touching the host. Faults are
+// injected by flipping the proxy and tun2socks, and the supervisor reacts
+// on the next probe the way the agent would. Time is simulated so health
+// hysteresis can be exercised without waiting.
+type sim struct {
+	state    *core.State
+	clock    time.Time
+	interval time.Duration // advanced before each probe
+
+	socks   string
+	proxyUp bool
+	pid     int // tun2socks; 0 when not running
+	nextPID int
+}
+
+func newSim(state *core.State, interval time.Duration) *sim {
+	return &sim{
+		state:    state,
+		clock:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		interval: interval,
+		socks:    defaultSOCKS,
+		proxyUp:  true,
+		nextPID:  simFirstPID,
+	}
+}
+
+var (
+	errNotRunning = errors.New("tun2socks is not running")
+	errRunning    = errors.New("tun2socks is already running")
+)
+
+// start brings the tunnel up: probe, TUN, routes, tun2socks. An unreachable
+// proxy fails the start into StateError, as the agent would.
+func (s *sim) start(socks string) error {
+	if socks != "" {
+		if _, _, err := net.SplitHostPort(socks); err != nil {
+			return fmt.Errorf("socks: %w", err)
+		}
+		s.socks = socks
+	}
+	if err := s.state.SetAgentState(core.StateStarting); err != nil {
+		return err
+	}
+	s.probeOnce()
+	if !s.proxyUp {
+		s.state.AppendWarning(s.warning("proxy_unreachable", "proxy unreachable: "+s.socks))
+		return s.state.SetAgentState(core.StateError)
+	}
+	s.state.UpdateTUN(core.TUNSnapshot{Name: simTUN, Up: true, MTU: simMTU, LocalIP: simLocalIP, PeerIP: simPeerIP})
+	s.state.RecordEvent(core.EventOrchestration, "tun up", map[string]string{"name": simTUN})
+	host, _, _ := net.SplitHostPort(s.socks)
+	s.state.UpdateRoutes(core.RouteSnapshot{
+		DefaultVia:      simPeerIP,
+		BypassHosts:     []string{host},
+		ProxyHostRoute:  true,
+		OriginalGateway: simGateway,
+	})
+	s.state.RecordEvent(core.EventOrchestration, "routes installed", nil)
+	s.launch("tun2socks started")
+	return s.state.SetAgentState(core.StateActive)
+}
+
+// stop tears down and restores routes. From StateError only cleanup runs.
+func (s *sim) stop() error {
+	if s.state.GetSnapshot().AgentState != core.StateError {
+		if err := s.state.SetAgentState(core.StateStopping); err != nil {
+			return err
+		}
+	}
+	s.pid = 0
+	s.state.UpdateTun2Socks(core.Tun2SocksSnapshot{})
+	s.state.UpdateRoutes(core.RouteSnapshot{})
+	s.state.UpdateTUN(core.TUNSnapshot{})
+	s.state.RecordEvent(core.EventOrchestration, "routes restored", map[string]string{"gateway": simGateway})
+	s.state.ClearWarnings()
+	return s.state.SetAgentState(core.StateInactive)
+}
+
+// killTun2Socks simulates tun2socks exiting unexpectedly.
+func (s *sim) killTun2Socks() error {
+	if s.pid == 0 {
+		return errNotRunning
+	}
+	pid := s.pid
+	s.pid = 0
+	s.state.UpdateTun2Socks(core.Tun2SocksSnapshot{})
+	s.state.AppendWarning(s.warning("tun2socks_exited", "tun2socks exited unexpectedly (pid "+strconv.Itoa(pid)+")"))
+	s.reconcile()
+	return nil
+}
+
+// restartTun2Socks is the supervisor relaunching tun2socks.
+func (s *sim) restartTun2Socks() error {
+	if s.pid != 0 {
+		return errRunning
+	}
+	switch s.state.GetSnapshot().AgentState {
+	case core.StateActive, core.StateDegraded:
+	default:
+		return errors.New("no tunnel to restart tun2socks for")
+	}
+	s.launch("tun2socks restarted")
+	s.reconcile()
+	return nil
+}
+
+// probe runs n probes, one interval apart, reconciling after each.
+func (s *sim) probe(n int) {
+	for range n {
+		s.clock = s.clock.Add(s.interval)
+		s.probeOnce()
+		s.reconcile()
+	}
+}
+
+// warning returns an orchestrator warning stamped with the simulated clock.
+func (s *sim) warning(code, msg string) core.Warning {
+	return core.Warning{Code: code, Severity: core.SeverityWarning, Source: "orchestrator", Message: msg, Time: s.clock}
+}
+
+// wait advances the simulated clock.
+func (s *sim) wait(d time.Duration) { s.clock = s.clock.Add(d) }
+
+func (s *sim) probeOnce() {
+	p := core.ProbeSummary{LastChecked: s.clock}
+	if s.proxyUp {
+		p.Reachable, p.SocksOK, p.ConnectOK = true, true, true
+		p.Latencies = map[string]time.Duration{"tcp_connect": 3 * time.Millisecond, "socks_handshake": 2 * time.Millisecond, "connect": 12 * time.Millisecond}
+	} else {
+		p.Warnings = []core.Warning{{Code: "tcp_connect_failed", Severity: core.SeverityWarning, Source: "probe", Message: "dial tcp " + s.socks + ": connect: connection refused", Time: s.clock}}
+	}
+	s.state.UpdateProbe(p)
+}
+
+func (s *sim) launch(msg string) {
+	s.pid = s.nextPID
+	s.nextPID++
+	s.state.UpdateTun2Socks(core.Tun2SocksSnapshot{PID: s.pid, TCPOk: true})
+	s.state.RecordEvent(core.EventOrchestration, msg, map[string]string{"pid": strconv.Itoa(s.pid)})
+}
+
+// reconcile is the supervisor: a running tunnel is degraded while tun2socks
+// is down or damped health is degraded, and active again once both recover.
+func (s *sim) reconcile() {
+	snap := s.state.GetSnapshot()
+	healthy := s.pid != 0 && snap.Health.Status != core.HealthDegraded
+	switch {
+	case snap.AgentState == core.StateActive && !healthy:
+		_ = s.state.SetAgentState(core.StateDegraded)
+	case snap.AgentState == core.StateDegraded && healthy:
+		s.state.ClearWarnings()
+		_ = s.state.SetAgentState(core.StateActive)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The module has no YAML dependency, so scenarios use a block-style subset:
+// nested maps ("key: value", "key:" + indented block), lists ("- item",
+// "- key: value" starting a map), scalars (plain, 'single', or "double"
+// quoted), "[a, b]" flow lists of scalars, and "#" comments. Anchors, flow
+// maps, multi-line strings, and tabs are rejected.
+
+// yamlLine is one significant line: indentation and text without comments.
+type yamlLine struct {
+	num    int // 1-based, for errors
+	indent int
+	text   string
+}
+
+// decodeYAML parses src and decodes it into v with encoding/json rules,
+// rejecting unknown fields.
+func decodeYAML(src []byte, v any) error {
+	lines, err := yamlLines(string(src))
+	if err != nil {
+		return err
+	}
+	var doc any
+	if len(lines) > 0 {
+		p := &yamlParser{lines: lines}
+		doc, err = p.block(lines[0].indent)
+		if err != nil {
+			return err
+		}
+		if p.i < len(p.lines) {
+			return fmt.Errorf("line %d: unexpected indentation", p.lines[p.i].num)
+		}
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func yamlLines(src string) ([]yamlLine, error) {
+	var out []yamlLine
+	for n, raw := range strings.Split(src, "\n") {
+		raw = strings.TrimRight(raw, " \r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n+1)
+		}
+		if n == 0 && text == "---" {
+			continue
+		}
+		text = strings.TrimRight(stripComment(text), " ")
+		if text == "" {
+			continue
+		}
+		out = append(out, yamlLine{num: n + 1, indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text})
+	}
+	return out, nil
+}
+
+// stripComment drops a "#" comment that starts the line or follows a space,
+// outside quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+// block parses the map or list starting at the current line.
+func (p *yamlParser) block(indent int) (any, error) {
+	if isListItem(p.lines[p.i].text) {
+		return p.list(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) list(indent int) ([]any, error) {
+	out := []any{}
+	for p.i < len(p.lines) {
+		l := p.lines[p.i]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent || !isListItem(l.text) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		rest := strings.TrimLeft(l.text[1:], " ")
+		switch {
+		case rest == "":
+			p.i++
+			v, err := p.nested(indent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		case isMapEntry(rest):
+			// "- key: value" opens a map aligned with key.
+			p.lines[p.i] = yamlLine{num: l.num, indent: l.indent + len(l.text) - len(rest), text: rest}
+			v, err := p.mapping(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		default:
+			v, err := scalar(rest, l.num)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+			p.i++
+		}
+	}
+	return out, nil
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	out := map[string]any{}
+	for p.i < len(p.lines) {
+		l := p.lines[p.i]
+		if l.indent < indent || (l.indent == indent && isListItem(l.text)) {
+			break
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.num)
+		}
+		key, val, ok := splitEntry(l.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", l.num)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.num, key)
+		}
+		p.i++
+		if val != "" {
+			v, err := scalar(val, l.num)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+			continue
+		}
+		// A list may sit at the key's own indentation.
+		if p.i < len(p.lines) && p.lines[p.i].indent == indent && isListItem(p.lines[p.i].text) {
+			v, err := p.list(indent)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+			continue
+		}
+		v, err := p.nested(indent)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = v
+	}
+	return out, nil
+}
+
+// nested parses the block indented deeper than parent, or nil if none.
+func (p *yamlParser) nested(parent int) (any, error) {
+	if p.i >= len(p.lines) || p.lines[p.i].indent <= parent {
+		return nil, nil
+	}
+	return p.block(p.lines[p.i].indent)
+}
+
+func isListItem(s string) bool { return s == "-" || strings.HasPrefix(s, "- ") }
+
+func isMapEntry(s string) bool {
+	if s == "" || s[0] == '"' || s[0] == '\'' || s[0] == '[' {
+		return false
+	}
+	_, _, ok := splitEntry(s)
+	return ok
+}
+
+// splitEntry splits "key: value" or "key:".
+func splitEntry(s string) (key, val string, ok bool) {
+	if k, ok := strings.CutSuffix(s, ":"); ok && !strings.Contains(k, ": ") {
+		return strings.TrimSpace(k), "", k != ""
+	}
+	k, v, ok := strings.Cut(s, ": ")
+	if !ok || strings.TrimSpace(k) == "" {
+		return "", "", false
+	}
+	return strings.TrimSpace(k), strings.TrimSpace(v), true
+}
+
+// scalar converts a plain or quoted scalar: null, booleans, and numbers are
+// typed; everything else is a string.
+func scalar(s string, num int) (any, error) {
+	switch {
+	case s[0] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad quoted string %s", num, s)
+		}
+		return v, nil
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("line %d: bad quoted string %s", num, s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '[':
+		if s[len(s)-1] != ']' {
+			return nil, fmt.Errorf("line %d: unterminated flow list", num)
+		}
+		out := []any{}
+		if inner := strings.TrimSpace(s[1 : len(s)-1]); inner != "" {
+			for _, item := range strings.Split(inner, ",") {
+				item = strings.TrimSpace(item)
+				if item == "" {
+					return nil, fmt.Errorf("line %d: empty flow list item", num)
+				}
+				v, err := scalar(item, num)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	case s[0] == '{' || s[0] == '&' || s[0] == '*' || s[0] == '|' || s[0] == '>':
+		return nil, fmt.Errorf("line %d: unsupported YAML syntax %q", num, s)
+	}
+	switch s {
+	case "null", "~":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return s, nil
+}
@@ -0,0 +1,36 @@
+// Command spctl is a command-line client for the agent's control-plane API.
+//
+// Usage:
+//
+//   spctl [global flags] <command> [command flags] [args]
+//
+// Global flags:
+//   -addr      agent API address (default from config file, else 127.0.0.1:8787)
+//   -token     API bearer token (default from config file)
+//   -config    path to the shared JSON config file
+//   -json      print raw JSON responses instead of tables
+//   -timeout   per-call timeout (default 30s)
+//   -ca-file   PEM certificate to trust for an https:// agent (default: the
+//              agent's certificate when the config file sets listen_tls)
+//
+// Commands:
+//   status                        show daemon state, TUN, routes, tun2socks, last probe
+//   probe [flags] <host:port>     run a probe, SOCKS5 by default (-type, -wg-config, -proxy-type, -chain, -target, -targets, -udp, -user, -pass, -auth-ref, -timeout-ms, -trace, -tls, -sni, -alpn, -url, -expect-status, -expect-body)
+//   start -socks <host:port> ...  start orchestration (-profile, -wg-config, -proxy-type, -chain, -mtu, -target, -udp, -bypass, -include, -exclude, -via, -engine, -auth-ref, -dry-run, -async)
+//   stop [-force] [-async]        stop orchestration and restore routes
+//   pause [-async]                route around the tunnel, keeping it up
+//   resume [-async]               route through the paused tunnel again
+//   op <operation-id>             show the step-by-step progress of an operation
+//   shutdown [-reason text]       ask the agent to exit cleanly (admin scope; token or unix socket)
+//   upgrade [-sha256 hex]         restart the installed agent executable without closing the API (admin scope; token or unix socket)
+//   service [status]              show the agent's launchd daemon (macOS)
+//   service install [-- args]     write and load the launchd plist; args replace the agent's own (admin scope; token or unix socket)
+//   service uninstall             unload the launchd daemon and remove its plist (admin scope; token or unix socket)
+//   events [-follow] [-after ID]  print the agent event log; -follow keeps watching
+//   diagnostics [-o file]         save a support bundle (-agent-path, -events, -probes; admin scope)
+//   connections                   list the flows the engine is relaying now
+//
+// Exit status is 0 on success, 1 on API or transport errors, and 2 on usage
+// errors. The address and token are read from the same config file as the
+// agent (see internal/config), so a single file configures both.
+package main
@@ -0,0 +1,726 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/api"
+	"github.com/sanverite/simple-packet-logger/internal/client"
+	"github.com/sanverite/simple-packet-logger/internal/config"
+	"github.com/sanverite/simple-packet-logger/internal/probe"
+	"github.com/sanverite/simple-packet-logger/internal/wireguard"
+)
+
+// errUsage marks command-line misuse (exit status 2).
+var errUsage = errors.New("usage error")
+
+// cli carries global options shared by every subcommand.
+type cli struct {
+	client  *client.Client
+	json    bool
+	timeout time.Duration
+	out     io.Writer
+}
+
+func main() {
+	global := flag.NewFlagSet("spctl", flag.ContinueOnError)
+	var (
+		addr       = global.String("addr", "", "agent API address (host:port or URL)")
+		token      = global.String("token", "", "API bearer token")
+		configPath = global.String("config", config.DefaultPath(), "path to JSON config file")
+		asJSON     = global.Bool("json", false, "print raw JSON instead of tables")
+		timeout    = global.Duration("timeout", client.DefaultTimeout, "per-call timeout")
+		caFile     = global.String("ca-file", "", "PEM certificate to trust for an https agent (e.g. its self-signed api-cert.pem)")
+	)
+	global.Usage = func() {
+		fmt.Fprintln(global.Output(), "usage: spctl [global flags] <status|probe|start|stop|pause|resume|op|shutdown|upgrade|service|events|diagnostics|connections> [flags] [args]")
+		global.PrintDefaults()
+	}
+	if err := global.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "spctl: %v\n", err)
+		os.Exit(2)
+	}
+	if *addr == "" {
+		*addr = cfg.Listen
+		if *addr == "" {
+			*addr = api.DefaultAddress
+		}
+		if cfg.ListenTLS {
+			*addr = "https://" + *addr
+			if *caFile == "" {
+				*caFile = agentCert(cfg)
+			}
+		}
+	}
+	if *token == "" {
+		*token = cfg.Token
+	}
+
+	hc := &http.Client{Timeout: *timeout}
+	if *caFile != "" {
+		if hc.Transport, err = trustTransport(*caFile); err != nil {
+			fmt.Fprintf(os.Stderr, "spctl: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	cl := client.New(*addr, *token, hc)
+	cl.SetUserAgent("spctl")
+	c := &cli{
+		client:  cl,
+		json:    *asJSON,
+		timeout: *timeout,
+		out:     os.Stdout,
+	}
+
+	args := global.Args()
+	if len(args) == 0 {
+		global.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var cmdErr error
+	switch args[0] {
+	case "status":
+		cmdErr = c.status(ctx, args[1:])
+	case "probe":
+		cmdErr = c.probe(ctx, args[1:])
+	case "start":
+		cmdErr = c.start(ctx, args[1:])
+	case "stop":
+		cmdErr = c.stop(ctx, args[1:])
+	case "pause", "resume":
+		cmdErr = c.pause(ctx, args[0], args[1:])
+	case "op":
+		cmdErr = c.op(ctx, args[1:])
+	case "shutdown":
+		cmdErr = c.shutdown(ctx, args[1:])
+	case "upgrade":
+		cmdErr = c.upgrade(ctx, args[1:])
+	case "service":
+		cmdErr = c.service(ctx, args[1:])
+	case "events":
+		cmdErr = c.events(ctx, args[1:])
+	case "diagnostics":
+		cmdErr = c.diagnostics(ctx, args[1:])
+	case "connections":
+		cmdErr = c.connections(ctx, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "spctl: unknown command %q\n", args[0])
+		global.Usage()
+		os.Exit(2)
+	}
+
+	switch {
+	case cmdErr == nil:
+	case errors.Is(cmdErr, errUsage), errors.Is(cmdErr, flag.ErrHelp):
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "spctl: %v\n", cmdErr)
+		os.Exit(1)
+	}
+}
+
+func (c *cli) status(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	resp, err := c.client.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	printStatus(c.out, resp)
+	return nil
+}
+
+func (c *cli) probe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
+	var (
+		target    = fs.String("target", "", "CONNECT target host:port (default example.com:80)")
+		udp       = fs.Bool("udp", false, "also test UDP ASSOCIATE")
+		user      = fs.String("user", "", "SOCKS5 username")
+		pass      = fs.String("pass", "", "SOCKS5 password")
+		authRef   = fs.String("auth-ref", "", "name of SOCKS5 credentials stored on the agent (see agent secret)")
+		timeoutMS = fs.Int("timeout-ms", 0, "probe timeout in milliseconds (0 = server default)")
+		typ       = fs.String("type", "", "probe type (default socks5; see GET /v1/probe/types)")
+		wgConf    = fs.String("wg-config", "", "wg-quick config file: handshake with its peer (type wireguard)")
+		ptype     = fs.String("proxy-type", "", "proxy type of <host:port>: socks5 (default) or http")
+		chain     = fs.String("chain", "", "comma-separated proxies reached through <host:port>, e.g. socks5://u:p@h:1080,http://h:3128")
+		trace     = fs.Bool("trace", false, "print the bytes exchanged with the proxy (socks5)")
+		targets   = fs.String("targets", "", "comma-separated host:port destinations to also CONNECT to, each over its own handshake")
+		tlsCheck  = fs.Bool("tls", false, "also handshake TLS with the target through the proxy")
+		sni       = fs.String("sni", "", "TLS server name to send and verify (implies -tls; default the target host)")
+		alpn      = fs.String("alpn", "", "comma-separated ALPN protocols to offer, e.g. h2,http/1.1 (implies -tls)")
+		canary    = fs.String("url", "", "canary URL to GET through the proxy; its host:port is the CONNECT target")
+		wantCode  = fs.Int("expect-status", 0, "status the -url response must have (default any 2xx)")
+		wantBody  = fs.String("expect-body", "", "text the -url response body must contain")
+	)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 && (*wgConf == "" || fs.NArg() != 0) {
+		fmt.Fprintln(os.Stderr, "usage: spctl probe [flags] <host:port> | -wg-config <file>")
+		return errUsage
+	}
+	req := api.ProbeRequest{
+		SocksServer:   fs.Arg(0),
+		TimeoutMS:     *timeoutMS,
+		ConnectTarget: *target,
+		UDPTest:       *udp,
+		Type:          *typ,
+		AuthRef:       *authRef,
+		ProxyType:     *ptype,
+		Trace:         *trace,
+	}
+	req.ConnectTargets = probe.ParseTargets(*targets)
+	if *tlsCheck || *sni != "" || *alpn != "" {
+		req.TLS = &api.ProbeTLSRequest{ServerName: *sni}
+		if *alpn != "" {
+			req.TLS.ALPN = strings.Split(*alpn, ",")
+		}
+	}
+	if *canary != "" {
+		req.HTTP = &api.ProbeHTTPRequest{URL: *canary, ExpectStatus: *wantCode, ExpectBody: *wantBody}
+	}
+	hops, err := parseChain(*chain)
+	if err != nil {
+		return err
+	}
+	req.Chain = hops
+	if *wgConf != "" {
+		wg, err := readWireGuard(*wgConf)
+		if err != nil {
+			return err
+		}
+		req.Type = probe.NameWireGuard
+		req.Options = map[string]string{"private_key": wg.PrivateKey, "public_key": wg.PublicKey}
+		if wg.PresharedKey != "" {
+			req.Options["preshared_key"] = wg.PresharedKey
+		}
+		if fs.NArg() == 0 {
+			req.SocksServer = wg.Endpoint
+		}
+	}
+	if req.Type != "" && req.Type != "socks5" {
+		req.SocksServer, req.Target = "", req.SocksServer
+	}
+	if *user != "" || *pass != "" {
+		req.Auth = &api.ProbeAuth{Username: *user, Password: *pass}
+	}
+	resp, err := c.client.Probe(ctx, req)
+	var apiErr *client.Error
+	if errors.As(err, &apiErr) && apiErr.Details["trace"] != "" && !c.json {
+		fmt.Fprint(c.out, apiErr.Details["trace"])
+	}
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	if req.Type == probe.NameWireGuard {
+		printHandshake(c.out, resp)
+		return nil
+	}
+	printProbe(c.out, resp)
+	return nil
+}
+
+func (c *cli) start(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("start", flag.ContinueOnError)
+	var (
+		socks  = fs.String("socks", "", "upstream SOCKS5 proxy host:port (required without -profile or -wg-config)")
+		ptype  = fs.String("proxy-type", "", "upstream proxy type: socks5 (default) or http (HTTP CONNECT, TCP only)")
+		chain  = fs.String("chain", "", "comma-separated proxies reached through -socks, e.g. socks5://u:p@h:1080 (needs -engine embedded)")
+		wgConf = fs.String("wg-config", "", "wg-quick config file of a WireGuard upstream, instead of -socks")
+		prof   = fs.String("profile", "", "stored profile supplying the flags left unset (see /v1/profiles)")
+		mtu    = fs.Int("mtu", 0, "TUN MTU (0 = default)")
+		tunNet = fs.String("tun-addr", "", "TUN IPv4 address and subnet, e.g. 10.77.0.1/30 (default 10.255.0.1/30)")
+		peer   = fs.String("tun-peer", "", "TUN peer address in the -tun-addr subnet (default: the next address)")
+		target = fs.String("target", "", "CONNECT target for verification")
+		udp    = fs.Bool("udp", false, "enable UDP relay")
+		bypass = fs.String("bypass", "", "comma-separated hosts to route outside the TUN")
+		incl   = fs.String("include", "", "comma-separated CIDRs to tunnel exclusively (split tunnel)")
+		excl   = fs.String("exclude", "", "comma-separated CIDRs to keep outside the TUN")
+		via    = fs.String("via", "", "comma-separated outbound interfaces in fallback order (e.g. en7,en0)")
+		engine = fs.String("engine", "", "tun2socks engine: empty for the configured one, or embedded")
+		dryRun = fs.Bool("dry-run", false, "report the plan without making changes")
+		async  = fs.Bool("async", false, "return the operation ID instead of waiting")
+		user   = fs.String("user", "", "SOCKS5 username")
+		pass   = fs.String("pass", "", "SOCKS5 password")
+		ref    = fs.String("auth-ref", "", "name of SOCKS5 credentials stored on the agent (see agent secret)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *socks == "" && *prof == "" && *wgConf == "" {
+		fmt.Fprintln(os.Stderr, "usage: spctl start -socks <host:port> | -profile <name> | -wg-config <file> [flags]")
+		return errUsage
+	}
+	req := api.StartRequest{
+		Profile:       *prof,
+		SocksServer:   *socks,
+		ProxyType:     *ptype,
+		MTU:           *mtu,
+		ConnectTarget: *target,
+		UDP:           *udp,
+		BypassHosts:   splitList(*bypass),
+		DryRun:        *dryRun,
+		AuthRef:       *ref,
+		IncludeCIDRs:  splitList(*incl),
+		ExcludeCIDRs:  splitList(*excl),
+		Engine:        *engine,
+
+		OutboundInterfaces: splitList(*via),
+		TUNAddress:         *tunNet,
+		TUNPeer:            *peer,
+	}
+	hops, err := parseChain(*chain)
+	if err != nil {
+		return err
+	}
+	req.Chain = hops
+	if *user != "" || *pass != "" {
+		req.Auth = &api.ProbeAuth{Username: *user, Password: *pass}
+	}
+	if *wgConf != "" {
+		wg, err := readWireGuard(*wgConf)
+		if err != nil {
+			return err
+		}
+		req.WireGuard = wg
+	}
+	if *async {
+		return c.accepted(c.client.StartAsync(ctx, req))
+	}
+	resp, err := c.client.Start(ctx, req)
+	if err != nil {
+		// A failed step still reports how far the start got.
+		if len(resp.Steps) > 0 {
+			c.printSteps(resp, resp.Steps)
+		}
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	printStart(c.out, resp)
+	return nil
+}
+
+func (c *cli) stop(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stop", flag.ContinueOnError)
+	force := fs.Bool("force", false, "skip graceful tun2socks shutdown")
+	async := fs.Bool("async", false, "return the operation ID instead of waiting")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *async {
+		return c.accepted(c.client.StopAsync(ctx, api.StopRequest{Force: *force}))
+	}
+	resp, err := c.client.Stop(ctx, api.StopRequest{Force: *force})
+	if err != nil {
+		if len(resp.Steps) > 0 {
+			c.printSteps(resp, resp.Steps)
+		}
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	printStop(c.out, resp)
+	return nil
+}
+
+// pause runs the pause or resume command (cmd).
+func (c *cli) pause(ctx context.Context, cmd string, args []string) error {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	async := fs.Bool("async", false, "return the operation ID instead of waiting")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	call, callAsync := c.client.Pause, c.client.PauseAsync
+	if cmd == "resume" {
+		call, callAsync = c.client.Resume, c.client.ResumeAsync
+	}
+	if *async {
+		return c.accepted(callAsync(ctx))
+	}
+	resp, err := call(ctx)
+	if err != nil {
+		if len(resp.Steps) > 0 {
+			c.printSteps(resp, resp.Steps)
+		}
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	printPause(c.out, resp)
+	return nil
+}
+
+// printSteps shows the steps of a failed operation: the whole response
+// with -json, otherwise the step table.
+func (c *cli) printSteps(resp any, steps []api.OperationStepView) {
+	if c.json {
+		_ = c.printJSON(resp)
+		return
+	}
+	printOpSteps(c.out, steps)
+}
+
+// accepted prints the result of an async operation.
+func (c *cli) accepted(resp api.OperationAccepted, err error) error {
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	fmt.Fprintf(c.out, "%s accepted: %s (spctl op %s)\n", resp.Kind, resp.OperationID, resp.OperationID)
+	return nil
+}
+
+// op shows the step-by-step progress of a start or stop.
+func (c *cli) op(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("op", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: spctl op <operation-id>")
+		return errUsage
+	}
+	resp, err := c.client.Operation(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	printOperation(c.out, resp)
+	return nil
+}
+
+// shutdown asks the agent to exit cleanly.
+func (c *cli) shutdown(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("shutdown", flag.ContinueOnError)
+	reason := fs.String("reason", "", "reason recorded in the shutdown report")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	resp, err := c.client.Shutdown(ctx, api.ShutdownRequest{Reason: *reason})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	fmt.Fprintf(c.out, "shutdown accepted (%s)\n", resp.Reason)
+	return nil
+}
+
+// upgrade has the agent restart its executable without closing the API.
+func (c *cli) upgrade(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+	digest := fs.String("sha256", "", "hex digest the new executable must have")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	resp, err := c.client.Upgrade(ctx, api.UpgradeRequest{SHA256: *digest})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	fmt.Fprintf(c.out, "upgrade accepted; new agent pid %d\n", resp.PID)
+	return nil
+}
+
+// service shows, installs, or uninstalls the agent's launchd daemon.
+// Arguments after "install" replace those the agent was started with.
+func (c *cli) service(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("service", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	var (
+		resp api.ServiceResponse
+		err  error
+	)
+	switch sub := fs.Arg(0); {
+	case sub == "" || sub == "status" && fs.NArg() == 1:
+		resp, err = c.client.Service(ctx)
+	case sub == "install":
+		var req api.ServiceInstallRequest
+		rest := fs.Args()[1:]
+		if len(rest) > 0 && rest[0] == "--" {
+			rest = rest[1:]
+		}
+		if len(rest) > 0 {
+			req.Args = rest
+		}
+		resp, err = c.client.ServiceInstall(ctx, req)
+	case sub == "uninstall" && fs.NArg() == 1:
+		resp, err = c.client.ServiceUninstall(ctx)
+	default:
+		fmt.Fprintln(os.Stderr, "usage: spctl service [status | install [-- agent args] | uninstall]")
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(resp)
+	}
+	printService(c.out, resp)
+	return nil
+}
+
+// diagnostics saves a support bundle from POST /v1/diagnostics to -o, or
+// has the agent write it to -agent-path on its own host.
+func (c *cli) diagnostics(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("diagnostics", flag.ContinueOnError)
+	var (
+		outFile   = fs.String("o", "", "file to save the bundle to (default spl-diagnostics-<time>.tar.gz)")
+		agentPath = fs.String("agent-path", "", "absolute path the agent writes the bundle to instead")
+		events    = fs.Int("events", 0, "most recent events to include (default 500)")
+		probes    = fs.Int("probes", 0, "most recent probe results to include (default 20)")
+	)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	req := api.DiagnosticsRequest{Path: *agentPath, Events: *events, Probes: *probes}
+	if *agentPath != "" {
+		resp, err := c.client.DiagnosticsToPath(ctx, req)
+		if err != nil {
+			return err
+		}
+		if c.json {
+			return c.printJSON(resp)
+		}
+		fmt.Fprintf(c.out, "agent wrote %s (%d bytes, %d files, %d skipped)\n", resp.Path, resp.Bytes, len(resp.Files), len(resp.Skipped))
+		for _, sk := range resp.Skipped {
+			fmt.Fprintf(c.out, "  skipped %s: %s\n", sk.Name, sk.Reason)
+		}
+		return nil
+	}
+	b, err := c.client.Diagnostics(ctx, req)
+	if err != nil {
+		return err
+	}
+	name := *outFile
+	if name == "" {
+		name = "spl-diagnostics-" + time.Now().UTC().Format("20060102T150405Z") + ".tar.gz"
+	}
+	if err := os.WriteFile(name, b, 0o600); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "saved %s (%d bytes)\n", name, len(b))
+	return nil
+}
+
+// events prints event log entries from /v1/events/history. With -follow it
+// keeps polling with after_id until interrupted.
+func (c *cli) events(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("events", flag.ContinueOnError)
+	var (
+		follow   = fs.Bool("follow", false, "keep watching for new events")
+		after    = fs.Uint64("after", 0, "only show events with ID greater than this")
+		interval = fs.Duration("interval", time.Second, "poll interval with -follow")
+	)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	afterID := *after
+	for {
+		page, err := c.client.EventsHistory(ctx, afterID, 0)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if page.Truncated {
+			fmt.Fprintf(os.Stderr, "spctl: events after id %d were evicted; output has a gap\n", afterID)
+		}
+		for _, ev := range page.Events {
+			if err := c.printEvent(ev); err != nil {
+				return err
+			}
+			afterID = ev.ID
+		}
+		if page.HasMore {
+			continue
+		}
+		if !*follow {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// connections prints the flows the engine is relaying, fetching every
+// page of /v1/connections.
+func (c *cli) connections(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("connections", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	var all api.ConnectionsResponse
+	var afterID uint64
+	for {
+		page, err := c.client.Connections(ctx, afterID, 0)
+		if err != nil {
+			return err
+		}
+		all.Connections = append(all.Connections, page.Connections...)
+		all.Total, all.UpdatedAt, all.GeneratedAt = page.Total, page.UpdatedAt, page.GeneratedAt
+		if !page.HasMore {
+			break
+		}
+		afterID = page.NextAfterID
+	}
+	if all.Connections == nil {
+		all.Connections = []api.ConnectionView{}
+	}
+	if c.json {
+		return c.printJSON(all)
+	}
+	printConnections(c.out, all)
+	return nil
+}
+
+func (c *cli) printEvent(ev api.EventView) error {
+	if c.json {
+		return json.NewEncoder(c.out).Encode(ev)
+	}
+	printEvent(c.out, ev)
+	return nil
+}
+
+func (c *cli) printJSON(v any) error {
+	enc := json.NewEncoder(c.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// splitList parses a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// parseChain reads -chain: proxy URLs ("socks5://", "http://", or a bare
+// host:port for SOCKS5) with optional user:pass.
+func parseChain(s string) ([]api.ProxyHop, error) {
+	var out []api.ProxyHop
+	for _, part := range splitList(s) {
+		if !strings.Contains(part, "://") {
+			part = probe.TypeSOCKS5 + "://" + part
+		}
+		u, err := url.Parse(part)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("-chain: hop %d: want [socks5://|http://][user:pass@]host:port", len(out)+1)
+		}
+		hop := api.ProxyHop{Server: u.Host, ProxyType: u.Scheme}
+		if u.User != nil {
+			pw, _ := u.User.Password()
+			hop.Auth = &api.ProbeAuth{Username: u.User.Username(), Password: pw}
+		}
+		out = append(out, hop)
+	}
+	return out, nil
+}
+
+// agentCert returns the certificate the agent serves with listen_tls: the
+// configured one, or the self-signed pair it generates in its data dir.
+func agentCert(cfg config.Config) string {
+	if cfg.TLSCertFile != "" {
+		return cfg.TLSCertFile
+	}
+	if cfg.DataDir != "" {
+		return filepath.Join(cfg.DataDir, "api-cert.pem")
+	}
+	return config.DefaultDataPath("api-cert.pem")
+}
+
+// trustTransport returns a transport that trusts the certificates in
+// caFile in addition to the system roots.
+func trustTransport(caFile string) (*http.Transport, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("ca file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ca file %s: no PEM certificates", caFile)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return t, nil
+}
+
+// readWireGuard reads a wg-quick config file as a start request upstream.
+func readWireGuard(path string) (*api.WireGuardUpstream, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := wireguard.ParseQuick(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	wg := &api.WireGuardUpstream{
+		Endpoint:     c.Peer.Endpoint,
+		PublicKey:    c.Peer.PublicKey.String(),
+		PrivateKey:   c.PrivateKey.String(),
+		Address:      c.Address.String(),
+		KeepaliveSec: int(c.Peer.Keepalive / time.Second),
+	}
+	if !c.Peer.PresharedKey.IsZero() {
+		wg.PresharedKey = c.Peer.PresharedKey.String()
+	}
+	return wg, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/api"
+)
+
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
+
+func printStatus(w io.Writer, s api.StatusResponse) {
+	tw := newTable(w)
+	fmt.Fprintf(tw, "STATE\t%s\n", s.State)
+	fmt.Fprintf(tw, "STARTED\t%s\n", orDash(s.StartedAt))
+	fmt.Fprintf(tw, "UPTIME\t%ds\n", s.UptimeSec)
+	fmt.Fprintf(tw, "TUN\t%s up=%t mtu=%d %s -> %s\n", orDash(s.TUN.Name), s.TUN.Up, s.TUN.MTU, orDash(s.TUN.LocalIP), orDash(s.TUN.PeerIP))
+	if s.TUN.LocalIP6 != "" {
+		fmt.Fprintf(tw, "TUN IPV6\t%s\n", s.TUN.LocalIP6)
+	}
+	fmt.Fprintf(tw, "DEFAULT VIA\t%s (original %s)\n", orDash(s.Routes.DefaultVia), orDash(s.Routes.OriginalGateway))
+	if s.Routes.OriginalGateway6 != "" {
+		fmt.Fprintf(tw, "ORIGINAL GW6\t%s\n", s.Routes.OriginalGateway6)
+	}
+	fmt.Fprintf(tw, "LAN CIDRS\t%s\n", joinOrDash(s.Routes.LanCIDRs))
+	fmt.Fprintf(tw, "BYPASS\t%s\n", joinOrDash(s.Routes.BypassHosts))
+	if len(s.Routes.IncludeCIDRs) > 0 || len(s.Routes.ExcludeCIDRs) > 0 {
+		fmt.Fprintf(tw, "SPLIT\tinclude=%s exclude=%s\n", joinOrDash(s.Routes.IncludeCIDRs), joinOrDash(s.Routes.ExcludeCIDRs))
+	}
+	udp := strconv.FormatBool(s.Tun2Socks.UDPOk)
+	if s.Tun2Socks.UDPUnsupported {
+		udp = "unsupported"
+	}
+	fmt.Fprintf(tw, "TUN2SOCKS\tpid=%d uptime=%ds tcp=%t udp=%s\n", s.Tun2Socks.PID, s.Tun2Socks.UptimeSec, s.Tun2Socks.TCPOk, udp)
+	if t := s.Tun2Socks.Traffic; t != nil {
+		fmt.Fprintf(tw, "TRAFFIC\tdown %s/s (%s) up %s/s (%s)\n", api.HumanBytes(t.RateInBps), api.HumanBytes(int64(t.BytesIn)),
+			api.HumanBytes(t.RateOutBps), api.HumanBytes(int64(t.BytesOut)))
+	}
+	fmt.Fprintf(tw, "LAST PROBE\t%s\n", orDash(s.LastProbe.LastChecked))
+	fmt.Fprintf(tw, "HEALTH\t%s since %s\n", s.Health.Status, orDash(s.Health.Since))
+	if s.Health.Score != nil {
+		fmt.Fprintf(tw, "SCORE\t%d/100\n", *s.Health.Score)
+	}
+	tw.Flush()
+	printWarnings(w, s.Warnings)
+}
+
+func printProbe(w io.Writer, p api.ProbeView) {
+	tw := newTable(w)
+	fmt.Fprintln(tw, "CHECK\tOK\tLATENCY\tP50\tP90\tP99")
+	fmt.Fprintf(tw, "tcp_connect\t%t\t%s\t%s\n", p.Reachable, latency(p, "tcp_connect"), percentiles(p, "tcp_connect"))
+	fmt.Fprintf(tw, "socks_handshake\t%t\t%s\t%s\n", p.SocksOK, latency(p, "socks_handshake"), percentiles(p, "socks_handshake"))
+	fmt.Fprintf(tw, "connect\t%t\t%s\t%s\n", p.ConnectOK, latency(p, "connect"), percentiles(p, "connect"))
+	if _, ok := p.LatenciesMs["udp_associate"]; ok {
+		fmt.Fprintf(tw, "udp_associate\t%t\t%s\t%s\n", p.UDPOK, latency(p, "udp_associate"), percentiles(p, "udp_associate"))
+	}
+	tw.Flush()
+	if len(p.Hops) > 0 {
+		tw = newTable(w)
+		fmt.Fprintln(tw, "HOP\tPROXY\tTYPE\tOK\tLATENCY\tERROR")
+		for i, h := range p.Hops {
+			lat := (time.Duration(h.LatencyUs) * time.Microsecond).String()
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%t\t%s\t%s\n", i+1, h.Server, h.ProxyType, h.OK, lat, orDash(h.Error))
+		}
+		tw.Flush()
+	}
+	if len(p.Targets) > 0 {
+		tw = newTable(w)
+		fmt.Fprintln(tw, "TARGET\tOK\tLATENCY\tERROR")
+		names := make([]string, 0, len(p.Targets))
+		for t := range p.Targets {
+			names = append(names, t)
+		}
+		sort.Strings(names)
+		for _, t := range names {
+			v := p.Targets[t]
+			lat := (time.Duration(v.LatencyUs) * time.Microsecond).String()
+			fmt.Fprintf(tw, "%s\t%t\t%s\t%s\n", t, v.OK, lat, orDash(v.Error))
+		}
+		tw.Flush()
+	}
+	fmt.Fprintf(w, "auth=%s ipv6=%t udp=%t\n", orDash(p.Features.Auth), p.Features.IPv6, p.Features.UDP)
+	if t := p.TLS; t != nil {
+		printProbeTLS(w, *t)
+	}
+	if h := p.HTTP; h != nil {
+		fmt.Fprintf(w, "http GET %s: status=%d bytes=%d ok=%t %s\n", h.URL, h.Status, h.BodyBytes, h.OK, h.Error)
+	}
+	if len(p.Trace) > 0 {
+		tw = newTable(w)
+		fmt.Fprintln(tw, "OFFSET\tHOP\tDIR\tLEN\tBYTES")
+		for _, e := range p.Trace {
+			off := (time.Duration(e.OffsetUs) * time.Microsecond).String()
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", off, orDash(hopLabel(e.Hop)), e.Dir, e.Len, e.Hex)
+		}
+		tw.Flush()
+	}
+	printWarnings(w, p.Warnings)
+}
+
+// printProbeTLS prints a probe's TLS check.
+func printProbeTLS(w io.Writer, t api.ProbeTLSView) {
+	tw := newTable(w)
+	if !t.OK {
+		fmt.Fprintf(tw, "TLS\tfailed: %s\n", t.Error)
+		tw.Flush()
+		return
+	}
+	fmt.Fprintf(tw, "TLS\t%s %s alpn=%s\n", t.Version, t.CipherSuite, orDash(t.ALPN))
+	cert := "valid"
+	if !t.Verified {
+		cert = "INVALID: " + t.VerifyError
+	}
+	fmt.Fprintf(tw, "CERT\t%s for %s\n", cert, t.ServerName)
+	fmt.Fprintf(tw, "ISSUER\t%s\n", orDash(t.Issuer))
+	if t.ExpiresInDays != nil {
+		fmt.Fprintf(tw, "EXPIRES\t%s (%d days)\n", t.NotAfter, *t.ExpiresInDays)
+	}
+	tw.Flush()
+}
+
+// hopLabel numbers a trace entry's hop; "" for a single proxy.
+func hopLabel(hop int) string {
+	if hop == 0 {
+		return ""
+	}
+	return strconv.Itoa(hop)
+}
+
+// printHandshake prints a wireguard probe, which has one step.
+func printHandshake(w io.Writer, p api.ProbeView) {
+	tw := newTable(w)
+	fmt.Fprintln(tw, "CHECK\tOK\tLATENCY")
+	fmt.Fprintf(tw, "wg_handshake\t%t\t%s\n", p.ConnectOK, latency(p, "wg_handshake"))
+	tw.Flush()
+	printWarnings(w, p.Warnings)
+}
+
+func printStart(w io.Writer, s api.StartResponse) {
+	tw := newTable(w)
+	fmt.Fprintf(tw, "STATE\t%s\n", s.State)
+	fmt.Fprintf(tw, "TUN\t%s mtu=%d\n", orDash(s.TUN.Name), s.TUN.MTU)
+	fmt.Fprintf(tw, "DEFAULT VIA\t%s\n", orDash(s.Routes.DefaultVia))
+	fmt.Fprintf(tw, "TUN2SOCKS\tpid=%d\n", s.Tun2Socks.PID)
+	tw.Flush()
+	printOpSteps(w, s.Steps)
+	printWarnings(w, s.Warnings)
+}
+
+func printStop(w io.Writer, s api.StopResponse) {
+	fmt.Fprintf(w, "state: %s\n", s.State)
+	printOpSteps(w, s.Steps)
+	printWarnings(w, s.Warnings)
+}
+
+func printPause(w io.Writer, s api.PauseResponse) {
+	fmt.Fprintf(w, "state: %s\n", s.State)
+	printOpSteps(w, s.Steps)
+	printWarnings(w, s.Warnings)
+}
+
+func printOperation(w io.Writer, op api.OperationView) {
+	fmt.Fprintf(w, "%s %s: %s (%dms)\n", op.Kind, op.ID, op.Status, op.ElapsedMS)
+	printOpSteps(w, op.Steps)
+	if op.Error != "" {
+		fmt.Fprintf(w, "error: %s\n", op.Error)
+	}
+}
+
+func printOpSteps(w io.Writer, steps []api.OperationStepView) {
+	if len(steps) == 0 {
+		return
+	}
+	tw := newTable(w)
+	fmt.Fprintln(tw, "STEP\tSTATUS\tDURATION\tERROR")
+	for _, st := range steps {
+		fmt.Fprintf(tw, "%s\t%s\t%dms\t%s\n", st.Name, st.Status, st.DurationMS, orDash(st.Error))
+	}
+	tw.Flush()
+}
+
+func printService(w io.Writer, s api.ServiceResponse) {
+	state := "not installed"
+	switch {
+	case s.Loaded && s.PID != 0:
+		state = fmt.Sprintf("%s (pid %d)", orDash(s.State), s.PID)
+	case s.Loaded:
+		state = orDash(s.State)
+	case s.Installed:
+		state = "installed, not loaded"
+	}
+	tw := newTable(w)
+	fmt.Fprintf(tw, "LABEL\t%s\n", s.Label)
+	fmt.Fprintf(tw, "PLIST\t%s\n", s.PlistPath)
+	fmt.Fprintf(tw, "STATE\t%s\n", state)
+	fmt.Fprintf(tw, "UNDER LAUNCHD\t%t\n", s.UnderLaunchd)
+	fmt.Fprintf(tw, "COMMAND\t%s\n", strings.Join(append([]string{s.Program}, s.Args...), " "))
+	tw.Flush()
+	printWarnings(w, s.Warnings)
+}
+
+func printWarnings(w io.Writer, warns []string) {
+	if len(warns) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "WARNINGS")
+	for _, msg := range warns {
+		fmt.Fprintf(w, "  - %s\n", msg)
+	}
+}
+
+func printEvent(w io.Writer, ev api.EventView) {
+	fmt.Fprintf(w, "%6d  %s  %-13s  %s", ev.ID, ev.At, ev.Type, ev.Message)
+	if len(ev.Data) > 0 && ev.Type != "state_change" {
+		keys := make([]string, 0, len(ev.Data))
+		for k := range ev.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, " %s=%s", k, ev.Data[k])
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+func printConnections(w io.Writer, r api.ConnectionsResponse) {
+	if len(r.Connections) == 0 {
+		fmt.Fprintln(w, "no connections")
+		return
+	}
+	tw := newTable(w)
+	fmt.Fprintln(tw, "ID\tPROTO\tSRC\tDST\tSTATE\tUP\tDOWN\tDURATION")
+	for _, c := range r.Connections {
+		dur := (time.Duration(c.DurationMs) * time.Millisecond).Truncate(time.Second)
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.ID, c.Proto, c.Src, c.Dst, c.State,
+			api.HumanBytes(int64(c.BytesUp)), api.HumanBytes(int64(c.BytesDown)), dur)
+	}
+	tw.Flush()
+}
+
+// latency renders one step's latency, in µs precision when the agent
+// reports latencies_us (older agents only send whole ms).
+func latency(p api.ProbeView, key string) string {
+	if v, ok := p.LatenciesUs[key]; ok {
+		return (time.Duration(v) * time.Microsecond).String()
+	}
+	v, ok := p.LatenciesMs[key]
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%dms", v)
+}
+
+// percentiles renders a step's rolling p50, p90, and p99 as three table
+// cells, dashes before any successful probe.
+func percentiles(p api.ProbeView, key string) string {
+	v, ok := p.LatencyPercentiles[key]
+	if !ok {
+		return "-\t-\t-"
+	}
+	us := func(n int64) string { return (time.Duration(n) * time.Microsecond).String() }
+	return us(v.P50Us) + "\t" + us(v.P90Us) + "\t" + us(v.P99Us)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func joinOrDash(xs []string) string {
+	if len(xs) == 0 {
+		return "-"
+	}
+	return strings.Join(xs, ", ")
+}
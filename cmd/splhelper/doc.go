@@ -0,0 +1,21 @@
+// Command splhelper is the agent's privileged helper: it runs as root and
+// performs TUN, route, and firewall changes for an unprivileged agent
+// over a local socket (see package privhelper).
+//
+// Usage:
+//
+//	splhelper -allow-user spl [-socket /run/simple-packet-logger-helper/helper.sock] [-tun-prefix spl]
+//
+// Flags:
+//
+//	-allow-user   user the agent runs as; only it and root may connect
+//	-socket       unix socket path (default /run/simple-packet-logger-helper/helper.sock)
+//	-tun-prefix   prefix every TUN name must start with (default spl)
+//	-log-level    debug, info, warn, or error (default info)
+//	-log-format   text or json (default text)
+//
+// The helper holds no state beyond the firewall sets it installed; the
+// agent removes those (through the helper) after every stop, at exit, and
+// at startup. Install and check it with "agent helper install" and
+// "agent helper verify"; packaging/systemd has a unit.
+package main
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/sanverite/simple-packet-logger/internal/firewall"
+	"github.com/sanverite/simple-packet-logger/internal/logging"
+	"github.com/sanverite/simple-packet-logger/internal/privhelper"
+)
+
+func main() {
+	socket := flag.String("socket", privhelper.DefaultSocket, "unix socket path")
+	allowUser := flag.String("allow-user", "", "user the agent runs as")
+	tunPrefix := flag.String("tun-prefix", "spl", "prefix of TUN names the helper manages")
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn, error")
+	logFormat := flag.String("log-format", "text", "log format: text or json")
+	flag.Parse()
+
+	logger, err := logging.New(logging.Options{Level: *logLevel, Format: *logFormat})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "splhelper: %v\n", err)
+		os.Exit(2)
+	}
+	if *allowUser == "" {
+		fmt.Fprintln(os.Stderr, "splhelper: -allow-user is required")
+		os.Exit(2)
+	}
+	u, err := user.Lookup(*allowUser)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "splhelper: %v\n", err)
+		os.Exit(2)
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "splhelper: uid %q: %v\n", u.Uid, err)
+		os.Exit(2)
+	}
+	if os.Geteuid() != 0 {
+		fmt.Fprintln(os.Stderr, "splhelper: must run as root")
+		os.Exit(1)
+	}
+
+	// The digest the agent pins; hello reports it.
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	var digest string
+	if err == nil {
+		digest, err = privhelper.Digest(exe)
+	}
+	if err != nil {
+		logger.Error("digest own executable failed", "err", err)
+		os.Exit(1)
+	}
+
+	opts := privhelper.ServerOptions{AllowUID: uid, TUNPrefix: *tunPrefix, Digest: digest, Logger: logger}
+	if b, err := firewall.OSBackend(); err == nil {
+		opts.Firewall = firewall.New(b, logger)
+		opts.FirewallBackend = b
+	} else if !errors.Is(err, firewall.ErrUnsupported) {
+		logger.Warn("firewall backend unavailable", "err", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*socket), 0o755); err != nil {
+		logger.Error("create socket directory failed", "err", err)
+		os.Exit(1)
+	}
+	ln, err := privhelper.Listen(*socket, uid)
+	if err != nil {
+		logger.Error("listen failed", "socket", *socket, "err", err)
+		os.Exit(1)
+	}
+	logger.Info("helper serving", "socket", *socket, "allow_user", *allowUser, "uid", uid, "sha256", digest)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logger.Info("received signal, stopping", "signal", sig.String())
+		ln.Close()
+	}()
+	if err := privhelper.NewServer(opts).Serve(ln); err != nil {
+		logger.Error("serve failed", "err", err)
+		os.Exit(1)
+	}
+	os.Remove(*socket)
+	logger.Info("stopped")
+}
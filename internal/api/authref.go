@@ -0,0 +1,39 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/sanverite/simple-packet-logger/internal/secrets"
+)
+
+// resolveAuth returns a request's SOCKS credentials: auth as sent, or the
+// stored credential ref names, so callers never need to send a password.
+// On failure it also returns the status to answer with.
+func (s *Server) resolveAuth(r *http.Request, auth *ProbeAuth, ref string) (*ProbeAuth, int, error) {
+	if ref == "" {
+		return auth, http.StatusOK, nil
+	}
+	if auth != nil {
+		return nil, http.StatusBadRequest, errors.New("auth and auth_ref are mutually exclusive")
+	}
+	if s.opts.Secrets == nil {
+		return nil, http.StatusServiceUnavailable, errors.New("credential store not configured")
+	}
+	if err := secrets.ValidName(ref); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("auth_ref: %w", err)
+	}
+	c, err := s.opts.Secrets.Get(ref)
+	switch {
+	case errors.Is(err, secrets.ErrNotFound):
+		return nil, http.StatusBadRequest, fmt.Errorf("auth_ref: no stored credential named %q", ref)
+	case errors.Is(err, secrets.ErrUnsupported):
+		return nil, http.StatusNotImplemented, err
+	case err != nil:
+		s.logger.ErrorContext(r.Context(), "credential lookup failed", "auth_ref", ref, "err", err)
+		return nil, http.StatusInternalServerError, fmt.Errorf("auth_ref %q: credential store failed", ref)
+	}
+	s.logger.DebugContext(r.Context(), "credential resolved", "auth_ref", ref, "client", clientID(r.Context()))
+	return &ProbeAuth{Username: c.Username, Password: c.Password}, http.StatusOK, nil
+}
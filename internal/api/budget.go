@@ -0,0 +1,229 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/handover"
+	"github.com/sanverite/simple-packet-logger/internal/metrics"
+)
+
+// Budget bounds one endpoint. MaxTime is enforced through the request
+// context: a handler still running at the deadline is abandoned and the
+// client gets 503 at once, so a hung handler cannot block a GUI waiting on
+// the response. MaxBody caps the request body (handlers see a read error,
+// usually answered with 400) and MaxResponse the response body (500
+// instead of a truncated document). Zero fields are unlimited; a budget
+// with neither MaxTime nor MaxResponse serves the handler unbuffered,
+// which streaming endpoints such as /v1/ws need.
+type Budget struct {
+	MaxTime     time.Duration
+	MaxBody     int64
+	MaxResponse int64
+}
+
+// Default endpoint budgets. Slow endpoints (probes, plans, sweeps) get the
+// health sweep limit plus budgetSlack instead of budgetFastTime, so a
+// sweep using its whole budget can still answer.
+const (
+	budgetFastTime    = 2 * time.Second
+	budgetMaxBody     = 1 << 20 // 1 MiB
+	budgetMaxResponse = 8 << 20 // 8 MiB
+	budgetSlack       = 500 * time.Millisecond
+)
+
+// fastBudget is for handlers that only read state.
+func (s *Server) fastBudget() Budget {
+	return Budget{MaxTime: budgetFastTime, MaxBody: budgetMaxBody, MaxResponse: budgetMaxResponse}
+}
+
+// slowBudget is for handlers that touch the network or the host.
+func (s *Server) slowBudget() Budget {
+	return Budget{MaxTime: s.healthBudgetLimit() + budgetSlack, MaxBody: budgetMaxBody, MaxResponse: budgetMaxResponse}
+}
+
+// upgradeBudget gives POST /v1/upgrade the time the new agent has to
+// start serving.
+func (s *Server) upgradeBudget() Budget {
+	return Budget{MaxTime: handover.DefaultTimeout + budgetSlack, MaxBody: budgetMaxBody, MaxResponse: budgetMaxResponse}
+}
+
+// streamBudget is for hijacked connections: only the body is bounded.
+func (s *Server) streamBudget() Budget {
+	return Budget{MaxBody: budgetMaxBody}
+}
+
+// handle registers h at /v1+path under budget b and the path's rate
+// limit, if any. Rate limiting runs first so rejected requests cost nothing.
+func (s *Server) handle(path string, b Budget, h http.HandlerFunc) {
+	route := "/" + APIVersion + path
+	s.budgets[route] = b
+	next := withBudget(route, b, h, s.logger, s.metrics)
+	if l, ok := s.opts.RateLimits[path]; ok && l.Rate > 0 {
+		next = withRateLimit(route, l, next, s.logger)
+	}
+	s.mux.Handle(route, next)
+}
+
+// withBudget enforces b on next. Violations are logged and counted per
+// route in the metrics registry.
+func withBudget(route string, b Budget, next http.Handler, logger *slog.Logger, reg *metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var bodyHit atomic.Bool
+		if b.MaxBody > 0 && r.Body != nil {
+			r.Body = &budgetBody{ReadCloser: http.MaxBytesReader(w, r.Body, b.MaxBody), hit: &bodyHit}
+		}
+		defer func() {
+			if bodyHit.Load() {
+				violation(r, route, metrics.BudgetBody, "request body exceeds "+strconv.FormatInt(b.MaxBody, 10)+" bytes", logger, reg)
+			}
+		}()
+		if b.MaxTime <= 0 && b.MaxResponse <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		if b.MaxTime > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, b.MaxTime)
+			defer cancel()
+		}
+		bw := &budgetWriter{header: w.Header().Clone(), limit: b.MaxResponse}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+				close(done)
+			}()
+			next.ServeHTTP(bw, r.WithContext(ctx))
+		}()
+
+		select {
+		case <-done:
+			select {
+			case p := <-panicked:
+				panic(p) // let net/http log it and drop the connection
+			default:
+			}
+			bw.mu.Lock()
+			defer bw.mu.Unlock()
+			if bw.overflow {
+				msg := "response exceeds " + strconv.FormatInt(b.MaxResponse, 10) + " bytes"
+				violation(r, route, metrics.BudgetResponse, msg, logger, reg)
+				writeJSON(w, http.StatusInternalServerError, APIError{
+					Error:     msg,
+					Code:      CodeBudgetExceeded,
+					Details:   map[string]string{"budget": string(metrics.BudgetResponse)},
+					Timestamp: TimeNow().UTC().Format(time.RFC3339),
+				})
+				return
+			}
+			for k, v := range bw.header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(bw.status())
+			_, _ = w.Write(bw.buf.Bytes())
+		case <-ctx.Done():
+			bw.mu.Lock()
+			defer bw.mu.Unlock()
+			bw.abandoned = true
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				msg := fmt.Sprintf("handler exceeded its %s time budget", b.MaxTime)
+				violation(r, route, metrics.BudgetTime, msg, logger, reg)
+				writeJSON(w, http.StatusServiceUnavailable, APIError{
+					Error:     msg,
+					Code:      CodeBudgetExceeded,
+					Details:   map[string]string{"budget": string(metrics.BudgetTime)},
+					Timestamp: TimeNow().UTC().Format(time.RFC3339),
+				})
+			}
+		}
+	})
+}
+
+// violation logs and counts one budget violation.
+func violation(r *http.Request, route string, kind metrics.BudgetKind, msg string, logger *slog.Logger, reg *metrics.Registry) {
+	reg.ObserveBudgetViolation(route, kind)
+	logger.LogAttrs(r.Context(), slog.LevelWarn, "endpoint budget exceeded",
+		slog.String("route", route),
+		slog.String("kind", string(kind)),
+		slog.String("detail", msg),
+		slog.String("remote_addr", r.RemoteAddr),
+	)
+}
+
+// budgetBody records whether the MaxBytesReader limit was hit.
+type budgetBody struct {
+	io.ReadCloser
+	hit *atomic.Bool
+}
+
+func (b *budgetBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.hit.Store(true)
+	}
+	return n, err
+}
+
+// budgetWriter buffers a response so it can be replaced by an error when
+// the handler overruns its time or size budget. Writes after the handler
+// was abandoned are discarded.
+type budgetWriter struct {
+	mu        sync.Mutex
+	header    http.Header
+	code      int
+	buf       bytes.Buffer
+	limit     int64
+	overflow  bool
+	abandoned bool
+}
+
+func (b *budgetWriter) Header() http.Header { return b.header }
+
+func (b *budgetWriter) WriteHeader(code int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.code == 0 {
+		b.code = code
+	}
+}
+
+func (b *budgetWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.abandoned {
+		return 0, http.ErrHandlerTimeout
+	}
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	if b.limit > 0 && int64(b.buf.Len()+len(p)) > b.limit {
+		b.overflow = true
+		return 0, errResponseBudget
+	}
+	return b.buf.Write(p)
+}
+
+func (b *budgetWriter) status() int {
+	if b.code == 0 {
+		return http.StatusOK
+	}
+	return b.code
+}
+
+var errResponseBudget = errors.New("response exceeds endpoint budget")
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/captive"
+)
+
+// captiveMaxAge is how old a captive portal check may be before a start
+// checks again.
+const captiveMaxAge = 30 * time.Second
+
+// handleCaptive reports whether a captive portal intercepts the uplink.
+// Method: GET
+// Query: refresh=true checks now instead of reporting the latest check
+// Response (200): CaptiveResponse JSON
+// Errors:
+//   - 503 when captive portal detection is not configured
+func (s *Server) handleCaptive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if s.opts.Captive == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "captive portal detection not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	st := s.opts.Captive.Status()
+	if refresh, err := strconv.ParseBool(r.URL.Query().Get("refresh")); err == nil && refresh {
+		st = s.opts.Captive.Check(r.Context())
+	}
+	writeJSON(w, http.StatusOK, FromCaptive(st))
+}
+
+// captivePortal reports whether a captive portal intercepts the uplink,
+// checking again when the latest check is older than captiveMaxAge.
+func (s *Server) captivePortal(ctx context.Context) (captive.Status, bool) {
+	if s.opts.Captive == nil {
+		return captive.Status{}, false
+	}
+	st := s.opts.Captive.Status()
+	if TimeNow().Sub(st.Checked) > captiveMaxAge {
+		st = s.opts.Captive.Check(ctx)
+	}
+	return st, st.State == captive.StatePortal
+}
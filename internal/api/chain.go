@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/sanverite/simple-packet-logger/internal/probe"
+	"github.com/sanverite/simple-packet-logger/internal/tun2socks"
+)
+
+// validProxyType checks a proxy_type field; empty means SOCKS5.
+func validProxyType(field, t string) error {
+	switch t {
+	case "", probe.TypeSOCKS5, probe.TypeHTTP:
+		return nil
+	}
+	return fmt.Errorf(`%s must be "socks5" or "http"`, field)
+}
+
+// resolveChain validates a chain and returns it with every auth_ref
+// replaced by the stored credentials. Non-admin callers must be allowed
+// each hop as a server. On failure it also returns the status to answer
+// with.
+func (s *Server) resolveChain(r *http.Request, chain []ProxyHop) ([]ProxyHop, int, error) {
+	if len(chain) > probe.MaxChainHops {
+		return nil, http.StatusBadRequest, fmt.Errorf("chain: at most %d hops", probe.MaxChainHops)
+	}
+	out := make([]ProxyHop, 0, len(chain))
+	for i, h := range chain {
+		field := "chain[" + strconv.Itoa(i) + "]"
+		if _, _, err := net.SplitHostPort(h.Server); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("%s.server: %w", field, err)
+		}
+		if err := validProxyType(field+".proxy_type", h.ProxyType); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		if err := s.checkPolicy(r, h.Server, ""); err != nil {
+			return nil, http.StatusForbidden, fmt.Errorf("%s: %w", field, err)
+		}
+		auth, code, err := s.resolveAuth(r, h.Auth, h.AuthRef)
+		if err != nil {
+			return nil, code, fmt.Errorf("%s: %w", field, err)
+		}
+		h.Auth, h.AuthRef = auth, ""
+		out = append(out, h)
+	}
+	return out, http.StatusOK, nil
+}
+
+// chainCode is the APIError code for a resolveChain failure; empty uses
+// the status default.
+func chainCode(status int) string {
+	if status == http.StatusForbidden {
+		return CodePolicyDenied
+	}
+	return ""
+}
+
+// probeHops converts a resolved chain for the socks5 probe.
+func probeHops(chain []ProxyHop) []probe.Hop {
+	out := make([]probe.Hop, 0, len(chain))
+	for _, h := range chain {
+		hop := probe.Hop{Server: h.Server, Type: h.ProxyType}
+		if h.Auth != nil {
+			hop.Auth = &probe.Auth{Username: h.Auth.Username, Password: h.Auth.Password}
+		}
+		out = append(out, hop)
+	}
+	return out
+}
+
+// engineHops converts a resolved chain for tun2socks.Config.
+func engineHops(chain []ProxyHop) []tun2socks.Hop {
+	out := make([]tun2socks.Hop, 0, len(chain))
+	for _, h := range chain {
+		hop := tun2socks.Hop{Proxy: h.Server, ProxyType: h.ProxyType}
+		if h.Auth != nil {
+			hop.Username, hop.Password = h.Auth.Username, h.Auth.Password
+		}
+		out = append(out, hop)
+	}
+	return out
+}
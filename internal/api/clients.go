@@ -0,0 +1,235 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Client registry bounds. Entries idle longer than clientRetention (with no
+// open stream) are dropped; past maxClients the least recently seen go first.
+const (
+	maxClients      = 256
+	clientRetention = 24 * time.Hour
+	clientActiveFor = time.Minute
+)
+
+// Subscription types recorded for streaming clients.
+const (
+	SubscriptionStatus = "status" // GET /v1/ws
+	SubscriptionEvents = "events" // GET /v1/events/stream, /v1/ws?stream=events
+)
+
+// clientIDKey carries the registry ID of the calling client in the request
+// context, so streaming handlers can attach subscriptions to it.
+type clientIDKey struct{}
+
+// clientEntry is one client as identified by listener, credential, user
+// agent, and remote host.
+type clientEntry struct {
+	ID          string
+	UserAgent   string
+	Identity    string
+	Listener    string
+	Scope       Scope
+	Remote      string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Requests    uint64
+	Mutations   uint64 // non-GET/HEAD requests
+	LastRequest string // "METHOD /path"
+	subs        map[string]int
+}
+
+// clientRegistry tracks API clients seen by the listeners.
+type clientRegistry struct {
+	mu      sync.Mutex
+	clients map[string]*clientEntry
+}
+
+// tokenID is a short, stable fingerprint of a bearer token, safe to display.
+func tokenID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:4])
+}
+
+// touch records an authorized request with its credential identity and
+// effective scope, and returns the client's ID.
+func (c *clientRegistry) touch(r *http.Request, lc ListenerConfig, identity string, scope Scope) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host // ephemeral ports would split one client into many
+	}
+	if remote == "" || remote == "@" {
+		remote = "local"
+	}
+	ua := r.UserAgent()
+	sum := sha256.Sum256([]byte(lc.String() + "\x00" + identity + "\x00" + ua + "\x00" + remote))
+	id := hex.EncodeToString(sum[:6])
+	now := TimeNow()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clients == nil {
+		c.clients = map[string]*clientEntry{}
+	}
+	e, ok := c.clients[id]
+	if !ok {
+		c.pruneLocked(now)
+		e = &clientEntry{
+			ID:        id,
+			UserAgent: ua,
+			Identity:  identity,
+			Listener:  lc.String(),
+			Scope:     scope,
+			Remote:    remote,
+			FirstSeen: now,
+			subs:      map[string]int{},
+		}
+		c.clients[id] = e
+	}
+	e.LastSeen = now
+	e.Requests++
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		e.Mutations++
+	}
+	e.LastRequest = r.Method + " " + r.URL.Path
+	return id
+}
+
+// pruneLocked drops expired entries and, at capacity, the least recently
+// seen idle ones. Callers hold c.mu.
+func (c *clientRegistry) pruneLocked(now time.Time) {
+	var idle []*clientEntry
+	for id, e := range c.clients {
+		if len(e.subs) > 0 {
+			continue
+		}
+		if now.Sub(e.LastSeen) > clientRetention {
+			delete(c.clients, id)
+			continue
+		}
+		idle = append(idle, e)
+	}
+	if len(c.clients) < maxClients {
+		return
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].LastSeen.Before(idle[j].LastSeen) })
+	for _, e := range idle {
+		if len(c.clients) < maxClients {
+			break
+		}
+		delete(c.clients, e.ID)
+	}
+}
+
+// subscribe marks an open stream of type typ on client id and returns a
+// function that releases it.
+func (c *clientRegistry) subscribe(id, typ string) (release func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.clients[id]
+	if !ok {
+		return func() {}
+	}
+	e.subs[typ]++
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if e.subs[typ]--; e.subs[typ] <= 0 {
+			delete(e.subs, typ)
+		}
+		e.LastSeen = TimeNow()
+	}
+}
+
+// list returns copies of every entry with its sorted subscription types,
+// most recently seen first.
+func (c *clientRegistry) list() ([]clientEntry, [][]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]clientEntry, 0, len(c.clients))
+	for _, e := range c.clients {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].LastSeen.Equal(entries[j].LastSeen) {
+			return entries[i].LastSeen.After(entries[j].LastSeen)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	subs := make([][]string, len(entries))
+	for i, e := range entries {
+		subs[i] = make([]string, 0, len(e.subs))
+		for typ := range e.subs {
+			subs[i] = append(subs[i], typ)
+		}
+		sort.Strings(subs[i])
+	}
+	return entries, subs
+}
+
+// clientID returns the registry ID stored by the listener policy.
+func clientID(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey{}).(string)
+	return id
+}
+
+// handleClients lists API clients seen recently.
+// Method: GET
+// Query: tz (optional display timezone)
+// Response (200): ClientsResponse JSON, most recently seen first
+// Errors:
+//   - 400 for an invalid tz
+func (s *Server) handleClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	loc, err := s.displayLocation(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	resp := fromClients(s.clients.list())
+	localizeClients(&resp, loc)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// fromClients maps registry entries; subs[i] belongs to entries[i].
+func fromClients(entries []clientEntry, subs [][]string) ClientsResponse {
+	now := TimeNow()
+	out := ClientsResponse{
+		Clients:     make([]ClientView, 0, len(entries)),
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+	}
+	for i, e := range entries {
+		out.Clients = append(out.Clients, ClientView{
+			ID:            e.ID,
+			UserAgent:     e.UserAgent,
+			Identity:      e.Identity,
+			Listener:      e.Listener,
+			Scope:         string(e.Scope),
+			Remote:        e.Remote,
+			Active:        len(subs[i]) > 0 || now.Sub(e.LastSeen) < clientActiveFor,
+			Subscriptions: subs[i],
+			Requests:      e.Requests,
+			Mutations:     e.Mutations,
+			LastRequest:   e.LastRequest,
+			FirstSeen:     e.FirstSeen.UTC().Format(time.RFC3339),
+			LastSeen:      e.LastSeen.UTC().Format(time.RFC3339),
+		})
+	}
+	return out
+}
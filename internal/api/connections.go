@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Page size bounds for GET /v1/connections.
+const (
+	defaultConnectionsLimit = 100
+	maxConnectionsLimit     = 1000
+)
+
+// handleConnections lists the flows the tun2socks engine is relaying, as
+// of its last report.
+// Method: GET
+// Query: after_id (default 0 = from the oldest), limit (1-1000, default 100)
+// Response (200): ConnectionsResponse JSON, connections in ascending ID order
+// Errors:
+//   - 400 for invalid after_id or limit
+func (s *Server) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	q := r.URL.Query()
+	var afterID uint64
+	if v := q.Get("after_id"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "after_id must be a non-negative integer",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		afterID = n
+	}
+	limit := defaultConnectionsLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxConnectionsLimit {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "limit must be an integer between 1 and 1000",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		limit = n
+	}
+
+	writeJSON(w, http.StatusOK, FromConnections(s.state.Connections(), afterID, limit, TimeNow()))
+}
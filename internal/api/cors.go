@@ -0,0 +1,111 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS headers answered to allowed origins.
+const (
+	corsAllowMethods  = "GET, HEAD, POST, PUT, DELETE, OPTIONS"
+	corsAllowHeaders  = "Authorization, Content-Type, If-None-Match, " + RequestIDHeader
+	corsExposeHeaders = RequestIDHeader + ", " + OperationHeader + ", ETag, Retry-After"
+	corsMaxAge        = 10 * time.Minute
+)
+
+// ParseOrigin normalizes a configured CORS origin to "scheme://host[:port]"
+// with a lowercase scheme and host. Paths, queries, and wildcards are
+// rejected: each allowed origin is named exactly.
+func ParseOrigin(s string) (string, error) {
+	u, err := url.Parse(strings.TrimSuffix(s, "/"))
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("origin %q: %w", s, err)
+	case u.Scheme != "http" && u.Scheme != "https":
+		return "", fmt.Errorf("origin %q: scheme must be http or https", s)
+	case u.Host == "" || strings.Contains(u.Host, "*"):
+		return "", fmt.Errorf("origin %q: want scheme://host[:port]", s)
+	case u.User != nil || u.Path != "" || u.RawQuery != "" || u.Fragment != "":
+		return "", fmt.Errorf("origin %q: must not have a path, query, or credentials", s)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// originSet returns the normalized origins of list; invalid entries, which
+// the agent rejects at startup, are skipped.
+func originSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, o := range list {
+		if n, err := ParseOrigin(o); err == nil {
+			set[n] = true
+		}
+	}
+	return set
+}
+
+// withCORS lets browser pages served from allowed origins call the API.
+// Preflight requests from an allowed origin are answered here, before the
+// listener policy, since browsers send them without credentials; other
+// requests from an allowed origin get CORS headers and continue.
+//
+// Any other Origin is a page the operator did not allow. Its preflights
+// and every request other than GET and HEAD are refused with 403: a
+// browser sends "simple" cross-site POSTs without asking first, and
+// withholding the response would not undo what the handler did. Its reads
+// continue without CORS headers, so the browser withholds the response.
+func withCORS(next http.Handler, allowed map[string]bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !allowed[strings.ToLower(origin)] {
+			if preflight || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+				writeJSON(w, http.StatusForbidden, APIError{
+					Error:     "origin not allowed: " + origin,
+					Timestamp: TimeNow().UTC().Format(time.RFC3339),
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		h.Set("Access-Control-Allow-Origin", origin)
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(corsMaxAge.Seconds())))
+			h.Del("Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// refuseForeignOrigin writes 403 and returns true when r carries an Origin
+// outside AllowedOrigins. Streams call it themselves: CORS does not govern
+// WebSocket, and a page on any site could otherwise read live status,
+// events, and flows from a listener that needs no token.
+func (s *Server) refuseForeignOrigin(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || s.origins[strings.ToLower(origin)] {
+		return false
+	}
+	writeJSON(w, http.StatusForbidden, APIError{
+		Error:     "origin not allowed: " + origin,
+		Timestamp: TimeNow().UTC().Format(time.RFC3339),
+	})
+	return true
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/diag"
+)
+
+// Bounds for GET /v1/debug/contention. The window stays well below the
+// default WriteTimeout (10s) so the response is not cut off.
+const (
+	defaultContentionWindow = 2 * time.Second
+	maxContentionSeconds    = 5
+	defaultContentionTop    = 10
+	maxContentionTop        = 50
+)
+
+// handleContention samples mutex and block contention for a short window.
+// Admin scope is required for the same reason as handleRuntime: the stacks
+// reveal what the agent is doing.
+// Method: GET
+// Query: seconds (1-5, default 2), top (1-50, default 10)
+// Response (200): ContentionResponse JSON, sites sorted by delay descending
+// Errors:
+//   - 400 for an invalid seconds or top value
+//   - 403 without admin scope
+//   - 409 while another sample is running
+func (s *Server) handleContention(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if requestScope(r.Context()) != ScopeAdmin {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     "contention profiles require admin scope",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	q := r.URL.Query()
+	window := defaultContentionWindow
+	if v := q.Get("seconds"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxContentionSeconds {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "seconds must be an integer between 1 and 5",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		window = time.Duration(n) * time.Second
+	}
+	top := defaultContentionTop
+	if v := q.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxContentionTop {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "top must be an integer between 1 and 50",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		top = n
+	}
+
+	c, err := diag.SampleContention(r.Context(), window, top)
+	if errors.Is(err, diag.ErrBusy) {
+		writeJSON(w, http.StatusConflict, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, FromContention(c))
+}
+
+// handleRuntime reports goroutine, heap, and GC statistics for diagnosing
+// memory growth. Admin scope is required because the numbers reveal the
+// agent's activity even when no capture data does.
+// Method: GET
+// Response (200): RuntimeResponse JSON
+// Errors:
+//   - 403 without admin scope
+func (s *Server) handleRuntime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if requestScope(r.Context()) != ScopeAdmin {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     "runtime statistics require admin scope",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, FromRuntime(diag.ReadRuntime()))
+}
+
+// registerPprof serves net/http/pprof at /debug/pprof/ (outside /v1, where
+// go tool pprof expects it) for admin callers. The profile and trace
+// handlers stream for "seconds", which must stay below WriteTimeout.
+func (s *Server) registerPprof() {
+	admin := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if requestScope(r.Context()) != ScopeAdmin {
+				writeJSON(w, http.StatusForbidden, APIError{
+					Error:     "pprof requires admin scope",
+					Timestamp: TimeNow().UTC().Format(time.RFC3339),
+				})
+				return
+			}
+			h(w, r)
+		}
+	}
+	s.mux.HandleFunc("/debug/pprof/", admin(pprof.Index))
+	s.mux.HandleFunc("/debug/pprof/cmdline", admin(pprof.Cmdline))
+	s.mux.HandleFunc("/debug/pprof/profile", admin(pprof.Profile))
+	s.mux.HandleFunc("/debug/pprof/symbol", admin(pprof.Symbol))
+	s.mux.HandleFunc("/debug/pprof/trace", admin(pprof.Trace))
+}
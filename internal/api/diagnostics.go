@@ -0,0 +1,243 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/bundle"
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/diag"
+)
+
+// Bounds for POST /v1/diagnostics.
+const (
+	defaultDiagnosticsEvents = 500
+	defaultDiagnosticsProbes = 20
+	maxDiagnosticsProbes     = 200
+	diagnosticsLogTail       = 1 << 20 // bytes kept from the end of each log file
+)
+
+// DiagnosticsOptions configures the files POST /v1/diagnostics collects
+// beyond the agent's own state.
+type DiagnosticsOptions struct {
+	// ConfigPath is the agent's config file, included with secrets masked.
+	// Empty leaves it out.
+	ConfigPath string
+	// LogFiles are log files (e.g. tun2socks output) whose tails are
+	// included under logs/. Missing files are listed as skipped.
+	LogFiles []string
+}
+
+// handleDiagnostics collects a support bundle (see package bundle): status,
+// recent events and probe results, the timeline, runtime statistics,
+// interfaces, host route and address dumps, log tails, and the redacted
+// config. Admin scope is required: the bundle describes the host, and
+// "path" writes a file as the agent's user.
+// Method: POST
+// Request: optional DiagnosticsRequest JSON
+// Response (200): the tar.gz archive, or DiagnosticsResponse JSON when
+// "path" is set
+// Errors:
+//   - 400 for invalid JSON, counts, or a relative path
+//   - 403 without admin scope
+//   - 409 when path exists or another bundle is being collected
+//   - 500 when the bundle cannot be written
+func (s *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if requestScope(r.Context()) != ScopeAdmin {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     "diagnostics require admin scope",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	var req DiagnosticsRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if req.Events == 0 {
+		req.Events = defaultDiagnosticsEvents
+	}
+	if req.Probes == 0 {
+		req.Probes = defaultDiagnosticsProbes
+	}
+	var msg string
+	switch {
+	case req.Events < 0 || req.Events > core.DefaultEventCapacity:
+		msg = "events must be between 1 and " + strconv.Itoa(core.DefaultEventCapacity)
+	case req.Probes < 0 || req.Probes > maxDiagnosticsProbes:
+		msg = "probes must be between 1 and " + strconv.Itoa(maxDiagnosticsProbes)
+	case req.Path != "" && !filepath.IsAbs(req.Path):
+		msg = "path must be absolute"
+	}
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     msg,
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if !s.diagMu.TryLock() {
+		writeJSON(w, http.StatusConflict, APIError{
+			Error:     "a diagnostics bundle is already being collected",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	defer s.diagMu.Unlock()
+
+	// Create the file first so an existing path fails before any work.
+	var f *os.File
+	if req.Path != "" {
+		var err error
+		f, err = os.OpenFile(req.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, fs.ErrExist) {
+				status = http.StatusConflict
+			}
+			writeJSON(w, status, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+	}
+
+	now := TimeNow()
+	var buf bytes.Buffer
+	bw := bundle.NewWriter(&buf, now)
+	err := s.collectDiagnostics(r, bw, req)
+	if err == nil {
+		err = bw.Close()
+	}
+	if err == nil && f != nil {
+		_, err = f.Write(buf.Bytes())
+		err = errors.Join(err, f.Close())
+	}
+	if err != nil {
+		if f != nil {
+			f.Close()
+			os.Remove(req.Path)
+		}
+		writeJSON(w, http.StatusInternalServerError, APIError{
+			Error:     "write diagnostics bundle: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	s.logger.InfoContext(r.Context(), "diagnostics bundle collected", "path", req.Path, "bytes", buf.Len(), "skipped", len(bw.Skipped()))
+
+	if f != nil {
+		writeJSON(w, http.StatusOK, FromBundle(req.Path, buf.Len(), bw.Files(), bw.Skipped()))
+		return
+	}
+	name := "spl-diagnostics-" + now.UTC().Format("20060102T150405Z") + ".tar.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// collectDiagnostics adds every part of the bundle. Parts that cannot be
+// read are skipped; only archive write errors are returned.
+func (s *Server) collectDiagnostics(r *http.Request, bw *bundle.Writer, req DiagnosticsRequest) error {
+	snap := s.state.GetSnapshot()
+	events, _, _ := s.state.Events().After(0, 0)
+	var probes []core.Event
+	for i := len(events) - 1; i >= 0 && len(probes) < req.Probes; i-- {
+		if events[i].Type == core.EventProbeResult {
+			probes = append(probes, events[i])
+		}
+	}
+	for i, j := 0, len(probes)-1; i < j; i, j = i+1, j-1 {
+		probes[i], probes[j] = probes[j], probes[i]
+	}
+	if n := len(events); n > req.Events {
+		events = events[n-req.Events:]
+	}
+	var lastID uint64
+	if n := len(events); n > 0 {
+		lastID = events[n-1].ID
+	}
+
+	parts := []struct {
+		name string
+		v    any
+	}{
+		{"status.json", FromCoreSnapshot(snap)},
+		{"events.json", FromEvents(events, false, lastID)},
+		{"probes.json", FromEvents(probes, false, lastID)},
+		{"timeline.json", FromTimeline(time.Time{}, s.state.Timeline(time.Time{}))},
+		{"runtime.json", FromRuntime(diag.ReadRuntime())},
+	}
+	for _, p := range parts {
+		if err := bw.AddJSON(p.name, p.v); err != nil {
+			return err
+		}
+	}
+
+	if ifaces, err := s.interfaces(); err != nil {
+		bw.Skip("interfaces.json", err)
+	} else if err := bw.AddJSON("interfaces.json", ifaces); err != nil {
+		return err
+	}
+	if err := bundle.Host(r.Context(), bw); err != nil {
+		return err
+	}
+
+	if path := s.opts.Diagnostics.ConfigPath; path != "" {
+		b, err := os.ReadFile(path)
+		if err == nil {
+			b, err = bundle.RedactJSON(b)
+		}
+		if err != nil {
+			bw.Skip("config.json", err)
+		} else if err := bw.Add("config.json", append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	if buf := s.opts.Tun2SocksLogs; buf != nil {
+		var b strings.Builder
+		for _, l := range buf.Tail(0) {
+			b.WriteString(l.At.UTC().Format(time.RFC3339Nano) + " " + l.Stream + " " + l.Text + "\n")
+		}
+		if err := bw.Add("logs/tun2socks-output.log", []byte(b.String())); err != nil {
+			return err
+		}
+	}
+	for _, path := range s.opts.Diagnostics.LogFiles {
+		name := "logs/" + filepath.Base(path)
+		b, err := bundle.Tail(path, diagnosticsLogTail)
+		if err != nil {
+			bw.Skip(name, err)
+			continue
+		}
+		if err := bw.Add(name, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// handleDNSUpstreams reports the DNS forwarder's upstreams in fallback
+// order with per-upstream health.
+// Method: GET
+// Response (200): DNSUpstreamsResponse JSON
+// Errors:
+//   - 503 when the DNS forwarder is not configured
+func (s *Server) handleDNSUpstreams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	f := s.opts.DNS
+	if f == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "dns forwarder not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, FromDNSUpstreams(f.Status(), f.Upstreams()))
+}
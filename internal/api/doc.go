@@ -14,17 +14,74 @@
 // Server
 //
 // NewServer wires handlers onto a ServeMux and configures timeouts. Start()
-// runs ListenAndServe() in a goroutine; Stop() performs graceful shutdown.
-// Middleware sets JSON content type and logs method/path/duration.
+// binds every configured listener (tcp, unix, optionally TLS) and serves each
+// in a goroutine; Stop() shuts them all down gracefully. Each listener applies
+// its own scope (admin, operate, or read-only) and optional bearer token;
+// operate callers are further held to the probe policy (ServerOptions.Policy).
+// Middleware assigns each request an X-Request-ID (honoring a valid incoming
+// one) carried in its context, sets JSON content type, emits one structured
+// slog record per request (method, path, remote addr, status, bytes,
+// duration) under the "api" component, and records per-route counters in a
+// metrics.Registry. Handlers log with the *Context slog methods and record
+// events via recordEvent so both carry the request ID. With
+// ServerOptions.AllowedOrigins set, CORS headers and preflights for those
+// origins are handled ahead of the listener's auth (cors.go). Writes from
+// other origins, writes without a JSON content type, and foreign Host
+// headers on loopback listeners are refused there too (forgery.go).
+//
+// Stable Output
+//
+// Mappers never emit null collections, and encoding/json sorts map keys, so
+// identical state serializes identically. See docs/api.md for list orders.
 //
 // Error Model
 //
-// APIError uses a string message and a timestamp in RFC3339. Handlers validate
-// methods and respond with 405 where appropriate.
+// APIError uses a string message, a stable code (errcodes.go), optional
+// details, the request ID, and a timestamp in RFC3339. writeJSON fills the code from the
+// status when a handler sets none. Handlers validate methods and respond
+// with 405 where appropriate.
 //
 // Current Endpoints
 //
 // - GET /v1/healthz: basic liveness/readiness
-// - GET /v1/status: maps core.Snapshot into stable JSON (see docs/api.md)
+// - GET /v1/status: maps core.Snapshot into stable JSON (see docs/api.md); honors
+//   If-None-Match against its revision ETag, and since_rev for diffs
+// - GET /v1/metrics: per-route request counters from the metrics registry
+// - GET /v1/ws: WebSocket stream of StatusResponse snapshots (or of
+//   StreamMessage records with stream=events)
+// - GET /v1/events/stream: Server-Sent Events of filtered events and flows
+// - /v1/profiles: named upstream profiles that /v1/start requests can name
+// - GET /v1/probe/active, DELETE /v1/probe/active: probes and health
+//   sweeps in flight, and canceling them
+// - GET /v1/debug/runtime: goroutine, heap, and GC statistics (admin scope);
+//   with EnablePprof, net/http/pprof is also served at /debug/pprof/
+// - POST /v1/diagnostics: support bundle (see package bundle), admin scope
+// - GET /v1/tun2socks/logs: captured tun2socks output (see package tun2socks)
+// - GET /v1/connections: flows the engine is relaying, paged by after_id
+// - POST /v1/pause, /v1/resume: route around a running tunnel and back,
+//   through the same operation guard as start and stop
+// - POST /v1/warnings/clear: drop state warnings once dealt with
+// - GET /v1/network/captive: captive portal detection on the uplink (see
+//   package captive); a detected portal holds back auto-selected starts
+// - GET /v1/egress: public address through the tunnel and directly, to
+//   confirm traffic exits via the proxy (see package egress)
+// - POST /v1/leaktest/dns: tagged lookups through the system resolver,
+//   checked against what the DNS forwarder relayed (see package leaktest)
+// - GET /v1/firewall: rule sets installed into the host firewall (see
+//   package firewall), torn down after every stop
+// - GET /v1/version: build version and which platform features (TUN
+//   device, route changes, firewall, service manager, ...) this host has
+// - GET /v1/service, POST /v1/service/install, POST /v1/service/uninstall:
+//   the agent's launchd daemon on macOS (see package launchd); install and
+//   uninstall require admin scope
+// - POST /v1/upgrade: restart the agent's executable with the listeners'
+//   sockets and exit once it serves them (see package handover); admin
+//   scope
+// - GET /v1/interfaces: host network interfaces with addresses and default
+//   routes (see netinfo.Interfaces)
+// - GET /v1/routes: recorded routes verified against the host routing table
+//   (see package routecheck)
+// - GET /v1/openapi.json: OpenAPI 3.0 document (schemas reflected from types.go;
+//   operations listed in apiOperations, which must track registered routes)
 package api
 
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/egress"
+)
+
+// handleEgress reports the public address traffic exits from, through the
+// tunnel and directly over the uplink.
+// Method: GET
+// Query: refresh=true looks up again instead of reusing cached answers
+// Response (200): EgressResponse JSON; a failed lookup sets its error
+// Errors:
+//   - 503 when egress lookups are not configured
+func (s *Server) handleEgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if s.opts.Egress == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "egress lookups not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	refresh, err := strconv.ParseBool(r.URL.Query().Get("refresh"))
+	refresh = err == nil && refresh
+
+	// The tunnel path only differs from the direct one while the TUN
+	// holds the default route.
+	cur := s.state.GetSnapshot().AgentState
+	active := cur == core.StateActive || cur == core.StateDegraded
+
+	var (
+		wg                   sync.WaitGroup
+		tunnel, direct       egress.Identity
+		tunnelHit, directHit bool
+	)
+	if active {
+		wg.Go(func() { tunnel, tunnelHit = s.opts.Egress.Lookup(r.Context(), egress.PathTunnel, refresh) })
+	}
+	wg.Go(func() { direct, directHit = s.opts.Egress.Lookup(r.Context(), egress.PathDirect, refresh) })
+	wg.Wait()
+
+	resp := EgressResponse{
+		Provider:     s.opts.Egress.Provider(),
+		TunnelActive: active,
+		Direct:       FromEgressIdentity(direct, directHit),
+		Warnings:     []string{},
+		GeneratedAt:  TimeNow().UTC().Format(time.RFC3339),
+	}
+	if active {
+		v := FromEgressIdentity(tunnel, tunnelHit)
+		resp.Tunnel = &v
+		if tunnel.Error == "" && direct.Error == "" {
+			via := tunnel.IP != direct.IP
+			resp.ViaProxy = &via
+			if !via {
+				resp.Warnings = append(resp.Warnings, "tunnel and direct lookups exit from "+direct.IP+"; traffic may be bypassing the proxy")
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
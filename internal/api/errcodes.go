@@ -0,0 +1,77 @@
+package api
+
+import "net/http"
+
+// Error codes carried in APIError.Code. Codes are stable; clients branch on
+// them rather than on the message text, which may change. Handlers that
+// set no code get the default for the response status (see codeForStatus).
+const (
+	CodeInvalidInput     = "ERR_INVALID_INPUT"          // 400: malformed JSON or a field failed validation
+	CodeUnauthorized     = "ERR_UNAUTHORIZED"           // 401: missing or invalid bearer token
+	CodeForbidden        = "ERR_FORBIDDEN"              // 403: the credential's scope does not allow the request
+	CodePolicyDenied     = "ERR_POLICY_DENIED"          // 403: server or target outside the probe policy
+	CodeNotFound         = "ERR_NOT_FOUND"              // 404
+	CodeMethodNotAllowed = "ERR_METHOD_NOT_ALLOWED"     // 405
+	CodeConflict         = "ERR_CONFLICT"               // 409: the resource is busy or already exists
+	CodeOperationBusy    = "ERR_OPERATION_IN_PROGRESS"  // 409: another operation is running
+	CodeStateTransition  = "ERR_STATE_TRANSITION"       // 409: not allowed from the current agent state
+	CodeGone             = "ERR_GONE"                   // 410
+	CodeUnsupportedMedia = "ERR_UNSUPPORTED_MEDIA_TYPE" // 415: a mutating request without Content-Type: application/json
+	CodeUpgradeRequired  = "ERR_UPGRADE_REQUIRED"       // 426
+	CodeRateLimited      = "ERR_RATE_LIMITED"           // 429; details.retry_after_sec
+	CodeInternal         = "ERR_INTERNAL"               // 500
+	CodeNotImplemented   = "ERR_NOT_IMPLEMENTED"        // 501
+	CodeUpstream         = "ERR_UPSTREAM"               // 502
+	CodeUnavailable      = "ERR_UNAVAILABLE"            // 503: subsystem not configured or at capacity
+	CodeBudgetExceeded   = "ERR_BUDGET_EXCEEDED"        // endpoint time or response budget; details.budget
+
+	CodeProbeConnect  = "ERR_PROBE_CONNECT"  // 502: the probed server was unreachable
+	CodeProbeFailed   = "ERR_PROBE_FAILED"   // 502: reachable, but a later probe stage failed
+	CodeProbeCanceled = "ERR_PROBE_CANCELED" // 409: canceled with DELETE /v1/probe/active
+	CodeCaptivePortal = "ERR_CAPTIVE_PORTAL" // 409: auto-selected start held back by a captive portal
+
+	// Start and stop step failures, set in StartResponse and StopResponse.
+	CodeTUNCreate      = "ERR_TUN_CREATE"
+	CodeRouteApply     = "ERR_ROUTE_APPLY"
+	CodeTun2SocksStart = "ERR_TUN2SOCKS_START"
+	CodeVerify         = "ERR_VERIFY"
+	CodeTun2SocksStop  = "ERR_TUN2SOCKS_STOP"
+	CodeRouteRestore   = "ERR_ROUTE_RESTORE"
+	CodeTUNRemove      = "ERR_TUN_REMOVE"
+)
+
+// codeForStatus is the code of an APIError written without one.
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidInput
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	case http.StatusUpgradeRequired:
+		return CodeUpgradeRequired
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusNotImplemented:
+		return CodeNotImplemented
+	case http.StatusBadGateway:
+		return CodeUpstream
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 400 && status < 500 {
+		return CodeInvalidInput
+	}
+	return CodeInternal
+}
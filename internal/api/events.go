@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Page size bounds for GET /v1/events/history.
+const (
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+// handleEventsHistory returns events after a given ID so reconnecting clients
+// can catch up.
+// Method: GET
+// Query: after_id (default 0 = from the oldest retained), limit (1-1000, default 100)
+// Response (200): EventsHistoryResponse JSON, events in ascending ID order
+// Errors:
+//   - 400 for invalid after_id or limit
+func (s *Server) handleEventsHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	q := r.URL.Query()
+	var afterID uint64
+	if v := q.Get("after_id"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "after_id must be a non-negative integer",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		afterID = n
+	}
+	limit := defaultEventsLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxEventsLimit {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "limit must be an integer between 1 and 1000",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		limit = n
+	}
+
+	events, truncated, lastID := s.state.Events().After(afterID, limit)
+	writeJSON(w, http.StatusOK, FromEvents(events, truncated, lastID))
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// handleFirewall lists the rule sets the agent installed into the host
+// firewall, with each rule in the backend's native form.
+// Method: GET
+// Response (200): FirewallResponse JSON; sets is empty when nothing is
+// installed
+// Errors:
+//   - 503 when no firewall backend is available or it is disabled
+func (s *Server) handleFirewall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if s.opts.Firewall == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "firewall not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, FromFirewall(s.opts.Firewall))
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"mime"
+	"net"
+	"net/http"
+	"time"
+)
+
+// withForgeryGuard refuses requests a web page could forge against a
+// local listener, ahead of its auth:
+//
+//   - On a loopback TCP listener, a Host that does not name a loopback
+//     address is refused with 403. A page whose name was rebound to
+//     127.0.0.1 (DNS rebinding) sends its own name there.
+//   - POST, PUT, and PATCH must carry Content-Type: application/json, or
+//     get 415. Browsers send that type cross-site only after a preflight,
+//     which withCORS answers for allowed origins alone.
+func withForgeryGuard(next http.Handler, lc ListenerConfig) http.Handler {
+	checkHost := false
+	if lc.Network == NetworkTCP {
+		if host, _, err := net.SplitHostPort(lc.Addr); err == nil {
+			checkHost = isLoopbackHost(host)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if checkHost && !isLoopbackHost(requestHost(r)) {
+			writeJSON(w, http.StatusForbidden, APIError{
+				Error:     "host not allowed: " + r.Host,
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+				writeJSON(w, http.StatusUnsupportedMediaType, APIError{
+					Error:     "Content-Type must be application/json",
+					Timestamp: TimeNow().UTC().Format(time.RFC3339),
+				})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestHost is the host of r's Host header, without its port.
+func requestHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.Host); err == nil {
+		return host
+	}
+	return r.Host
+}
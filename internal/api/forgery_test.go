@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+func TestForgedRequestsRefused(t *testing.T) {
+	lc := ListenerConfig{Network: NetworkTCP, Addr: "127.0.0.1:8787", Scope: ScopeAdmin}
+	tests := []struct {
+		name    string
+		origins []string
+		method  string
+		path    string
+		host    string
+		header  map[string]string
+		want    int
+	}{
+		{"foreign origin post", nil, http.MethodPost, "/v1/stop", "", map[string]string{"Origin": "https://evil.example", "Content-Type": "application/json"}, http.StatusForbidden},
+		{"foreign origin with allowed list", []string{"http://localhost:5173"}, http.MethodPost, "/v1/stop", "", map[string]string{"Origin": "https://evil.example", "Content-Type": "application/json"}, http.StatusForbidden},
+		{"null origin post", nil, http.MethodPost, "/v1/stop", "", map[string]string{"Origin": "null", "Content-Type": "application/json"}, http.StatusForbidden},
+		{"text/plain post", nil, http.MethodPost, "/v1/stop", "", map[string]string{"Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+		{"form post", nil, http.MethodPost, "/v1/stop", "", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, http.StatusUnsupportedMediaType},
+		{"post without type", nil, http.MethodPost, "/v1/stop", "", nil, http.StatusUnsupportedMediaType},
+		{"rebound host", nil, http.MethodGet, "/v1/status", "evil.example:8787", nil, http.StatusForbidden},
+		{"foreign origin read", nil, http.MethodGet, "/v1/healthz", "", map[string]string{"Origin": "https://evil.example"}, http.StatusOK},
+		{"localhost host", nil, http.MethodGet, "/v1/healthz", "localhost:8787", nil, http.StatusOK},
+		{"allowed origin post", []string{"http://localhost:5173"}, http.MethodPost, "/v1/healthcheck/full", "", map[string]string{"Origin": "http://localhost:5173", "Content-Type": "application/json; charset=utf-8"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(core.NewState(), ServerOptions{AllowedOrigins: tt.origins})
+			h := s.newHTTPServer(lc).Handler
+
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			r.Host = "127.0.0.1:8787"
+			if tt.host != "" {
+				r.Host = tt.host
+			}
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestShutdownRequiresProvenCaller(t *testing.T) {
+	tests := []struct {
+		name  string
+		lc    ListenerConfig
+		token string
+		want  int
+	}{
+		{"tokenless tcp", ListenerConfig{Network: NetworkTCP, Addr: "127.0.0.1:8787", Scope: ScopeAdmin}, "", http.StatusForbidden},
+		{"tcp with token", ListenerConfig{Network: NetworkTCP, Addr: "127.0.0.1:8787", Scope: ScopeAdmin, Token: "s3cret-token"}, "s3cret-token", http.StatusAccepted},
+		{"unix socket", ListenerConfig{Network: NetworkUnix, Addr: "/run/spl/api.sock", Scope: ScopeAdmin}, "", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reason string
+			s := NewServer(core.NewState(), ServerOptions{RequestShutdown: func(r string) { reason = r }})
+			h := s.newHTTPServer(tt.lc).Handler
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/shutdown", strings.NewReader(`{"reason":"test"}`))
+			r.Host = "127.0.0.1:8787"
+			r.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if accepted := reason != ""; accepted != (tt.want == http.StatusAccepted) {
+				t.Errorf("shutdown requested = %v", accepted)
+			}
+		})
+	}
+}
+
+func TestStreamsRefuseForeignOrigin(t *testing.T) {
+	s := NewServer(core.NewState(), ServerOptions{AllowedOrigins: []string{"http://localhost:5173"}})
+	for _, path := range []string{"/v1/ws", "/v1/events/stream"} {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Origin", "https://evil.example")
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Sec-WebSocket-Version", "13")
+		r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		r = r.WithContext(context.WithValue(r.Context(), scopeKey{}, ScopeAdmin))
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, r)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403: %s", path, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/health"
+	"github.com/sanverite/simple-packet-logger/internal/ipv6leak"
+	"github.com/sanverite/simple-packet-logger/internal/logging"
+	"github.com/sanverite/simple-packet-logger/internal/probe"
+)
+
+// HealthProbe is one configured probe run by the full health sweep.
+type HealthProbe struct {
+	Name    string // label in the report; defaults to Type
+	Type    string // registered probe name (see probe.Registered)
+	Target  string
+	Timeout time.Duration // 0 = probe default
+	Options map[string]string
+}
+
+// maxHealthBudget bounds a sweep when the server has no write timeout.
+const maxHealthBudget = time.Minute
+
+// healthBudgetLimit keeps the sweep one second inside WriteTimeout so the
+// report is never cut off mid-response.
+func (s *Server) healthBudgetLimit() time.Duration {
+	if wt := s.opts.WriteTimeout; wt > 0 {
+		return max(wt-time.Second, time.Second)
+	}
+	return maxHealthBudget
+}
+
+// healthChecks assembles the sweep: configured probes first, then the
+// data-plane, DNS leak, route drift, and IPv6 leak checks.
+func (s *Server) healthChecks() []health.Check {
+	logger := logging.Component(s.opts.Logger, logging.ComponentProbe)
+	checks := make([]health.Check, 0, len(s.opts.HealthProbes)+4)
+	for _, hp := range s.opts.HealthProbes {
+		name := hp.Name
+		if name == "" {
+			name = hp.Type
+		}
+		p, ok := probe.Lookup(hp.Type)
+		if !ok {
+			checks = append(checks, health.Check{Name: name, Kind: health.KindProbe, Run: unknownProbe(hp.Type)})
+			continue
+		}
+		checks = append(checks, health.ProbeCheck(name, p, probe.Params{
+			Target:  hp.Target,
+			Timeout: hp.Timeout,
+			Options: hp.Options,
+			Logger:  logger,
+		}))
+	}
+	return append(checks,
+		health.DataPlane(s.state, s.opts.InterfaceExists),
+		health.DNSLeak(s.state, s.opts.Nameservers),
+		health.RouteDrift(s.state, s.opts.DefaultRoute),
+		health.IPv6Leak(s.state, scanIPv6Leaks, s.ipv6Blocked),
+	)
+}
+
+// scanIPv6Leaks renders ipv6leak.Scan for the health check.
+func scanIPv6Leaks(tun string) ([]string, error) {
+	egress, err := ipv6leak.Scan(tun)
+	out := make([]string, 0, len(egress))
+	for _, e := range egress {
+		out = append(out, e.String())
+	}
+	return out, err
+}
+
+// ipv6Blocked reports whether the kill switch holds IPv6 blocked.
+func (s *Server) ipv6Blocked() bool {
+	return s.opts.IPv6Leak != nil && s.opts.IPv6Leak.Status().State == ipv6leak.StateBlocked
+}
+
+func unknownProbe(typ string) func(ctx context.Context) (health.Status, string) {
+	return func(context.Context) (health.Status, string) {
+		return health.StatusFail, "unknown probe type: " + typ
+	}
+}
+
+// handleHealthcheckFull runs every configured probe, the data-plane check,
+// the DNS leak test, the route drift check, and the IPv6 leak check
+// sequentially within a budget.
+// Method: POST
+// Request: optional HealthcheckRequest JSON (empty body uses the default budget)
+// Query: tz (optional display timezone)
+// Response (200): HealthReportResponse JSON; status is the worst check result
+// Errors:
+//   - 400 for invalid JSON or a budget_ms outside 1..(write timeout - 1s)
+//   - 409 while another sweep is running, or when canceled with
+//     DELETE /v1/probe/active
+func (s *Server) handleHealthcheckFull(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	loc, err := s.displayLocation(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req HealthcheckRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	limit := s.healthBudgetLimit()
+	budget := min(health.DefaultBudget, limit)
+	if req.BudgetMs != 0 {
+		budget = time.Duration(req.BudgetMs) * time.Millisecond
+		if req.BudgetMs < 0 || budget > limit {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "budget_ms must be between 1 and " + strconv.FormatInt(limit.Milliseconds(), 10),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+	}
+
+	if !s.sweepMu.TryLock() {
+		writeJSON(w, http.StatusConflict, APIError{
+			Error:     "a health sweep is already running",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	defer s.sweepMu.Unlock()
+
+	ctx, done := s.trackProbe(w, r, probeKindHealthcheck, "", "")
+	rep := health.Sweep(ctx, s.healthChecks(), health.Options{Budget: budget})
+	canceled := errors.Is(context.Cause(ctx), errProbeCanceled)
+	done()
+	if canceled {
+		writeProbeCanceled(w, "health sweep")
+		return
+	}
+	s.logger.InfoContext(r.Context(), "health sweep finished", "status", rep.Status,
+		"checks", len(rep.Results), "elapsed", rep.Elapsed, "budget_exceeded", rep.BudgetExceeded)
+
+	resp := FromHealthReport(rep)
+	localizeHealthReport(&resp, loc)
+	writeJSON(w, http.StatusOK, resp)
+}
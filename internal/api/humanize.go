@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// wantHumanize reports whether the request asked for human-readable companion
+// fields via ?humanize=true. Unparseable values are treated as false.
+func wantHumanize(r *http.Request) bool {
+	v, err := strconv.ParseBool(r.URL.Query().Get("humanize"))
+	return err == nil && v
+}
+
+// HumanDuration renders d compactly with at most two units, e.g. "45s",
+// "3h12m", "2d4h". Sub-second durations render as "0s".
+func HumanDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	secs := int64(d / time.Second)
+	days := secs / 86400
+	hours := (secs % 86400) / 3600
+	mins := (secs % 3600) / 60
+	rem := secs % 60
+
+	switch {
+	case days > 0:
+		if hours > 0 {
+			return fmt.Sprintf("%dd%dh", days, hours)
+		}
+		return fmt.Sprintf("%dd", days)
+	case hours > 0:
+		if mins > 0 {
+			return fmt.Sprintf("%dh%dm", hours, mins)
+		}
+		return fmt.Sprintf("%dh", hours)
+	case mins > 0:
+		if rem > 0 {
+			return fmt.Sprintf("%dm%ds", mins, rem)
+		}
+		return fmt.Sprintf("%dm", mins)
+	default:
+		return fmt.Sprintf("%ds", rem)
+	}
+}
+
+// HumanBytes renders n using IEC units with one decimal, e.g. "512 B",
+// "1.2 GiB".
+func HumanBytes(n int64) string {
+	const unit = 1024
+	if n < unit && n > -unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for abs := n / unit; abs >= unit || abs <= -unit; abs /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// humanizeStatus fills the *_human companion fields on a StatusResponse.
+func humanizeStatus(resp *StatusResponse) {
+	resp.UptimeHuman = HumanDuration(time.Duration(resp.UptimeSec) * time.Second)
+	resp.Tun2Socks.UptimeHuman = HumanDuration(time.Duration(resp.Tun2Socks.UptimeSec) * time.Second)
+	if t := resp.Tun2Socks.Traffic; t != nil {
+		t.RateInHuman = HumanBytes(t.RateInBps) + "/s"
+		t.RateOutHuman = HumanBytes(t.RateOutBps) + "/s"
+	}
+}
+
+// humanizeMetrics fills the *_human companion fields on a MetricsResponse.
+func humanizeMetrics(resp *MetricsResponse) {
+	for k, rv := range resp.Routes {
+		rv.BytesHuman = HumanBytes(rv.Bytes)
+		resp.Routes[k] = rv
+	}
+}
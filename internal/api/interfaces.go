@@ -0,0 +1,54 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// handleInterfaces lists the host's network interfaces with their
+// addresses and default routes, for interface pickers.
+// Method: GET
+// Response (200): InterfacesResponse JSON
+// Errors:
+//   - 500 when interfaces cannot be listed
+func (s *Server) handleInterfaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	resp, err := s.interfaces()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIError{
+			Error:     "list interfaces: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// interfaces reads the interface inventory. Default routes are best
+// effort: a failure to list them is reported in RoutesError.
+func (s *Server) interfaces() (InterfacesResponse, error) {
+	ifaces, err := s.opts.Interfaces()
+	if err != nil {
+		return InterfacesResponse{}, err
+	}
+	routes4, err4 := s.opts.DefaultRoutes()
+	routes6, err6 := s.opts.DefaultRoutes6()
+	resp := FromInterfaces(ifaces, routes4, routes6)
+	// No default route is not an error here; the interfaces just have none.
+	switch {
+	case err4 != nil && !errors.Is(err4, netinfo.ErrNoDefaultRoute):
+		resp.RoutesError = err4.Error()
+	case err6 != nil && !errors.Is(err6, netinfo.ErrNoDefaultRoute):
+		resp.RoutesError = err6.Error()
+	}
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/health"
+	"github.com/sanverite/simple-packet-logger/internal/leaktest"
+)
+
+// handleLeakTestDNS looks up uniquely tagged names through the system
+// resolver and reports whether any reached a resolver outside the tunnel.
+// Method: POST
+// Request: optional LeakTestRequest JSON (empty body uses the defaults)
+// Response (200): LeakTestResponse JSON with the verdict and evidence
+// Errors:
+//   - 400 for invalid JSON, an invalid domain, or queries outside 1..10
+func (s *Server) handleLeakTestDNS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req LeakTestRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	opts := leaktest.Options{
+		Domain:   req.Domain,
+		Queries:  req.Queries,
+		Resolver: s.opts.LeakResolver,
+		Observer: s.opts.LeakObserver,
+	}
+	if !req.DisableWhoAmI {
+		opts.WhoAmI = leaktest.DefaultWhoAmI
+	}
+	var fwd LeakForwarderView
+	if f := s.opts.DNS; f != nil {
+		st := f.Status()
+		fwd = LeakForwarderView{Configured: true, Running: st.Running, Rewritten: st.Rewritten, Listen: st.Listen}
+		opts.Forwarding = st.Running && st.Rewritten
+	}
+	res, err := leaktest.Run(r.Context(), opts)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// Without an observing forwarder, fall back to where the system
+	// resolvers are routed.
+	status, detail := health.DNSLeak(s.state, s.opts.Nameservers).Run(r.Context())
+	if res.Verdict == leaktest.VerdictInconclusive && status == health.StatusFail {
+		res.Verdict, res.Reason = leaktest.VerdictLeak, detail
+	}
+	s.logger.InfoContext(r.Context(), "dns leak test", "verdict", res.Verdict, "reason", res.Reason)
+	writeJSON(w, http.StatusOK, FromLeakTest(res, fwd, LeakRoutingView{Status: string(status), Detail: detail}))
+}
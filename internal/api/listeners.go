@@ -0,0 +1,380 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/tokens"
+)
+
+// Scope limits what a listener's clients may do.
+type Scope string
+
+const (
+	// ScopeAdmin permits every endpoint (default).
+	ScopeAdmin Scope = "admin"
+	// ScopeOperate permits GET/HEAD plus probe (and canceling probes),
+	// start, stop, pause, and resume, within the server's probe policy.
+	ScopeOperate Scope = "operate"
+	// ScopeReadOnly permits only GET/HEAD requests (status, metrics, streams).
+	ScopeReadOnly Scope = "read"
+)
+
+// operateRoutes are the mutating routes ScopeOperate may call.
+var operateRoutes = map[string]bool{
+	"/" + APIVersion + "/probe":        true,
+	"/" + APIVersion + "/probe/active": true,
+	"/" + APIVersion + "/leaktest/dns": true,
+	"/" + APIVersion + "/start":        true,
+	"/" + APIVersion + "/stop":         true,
+	"/" + APIVersion + "/pause":        true,
+	"/" + APIVersion + "/resume":       true,
+}
+
+// narrower returns the more restrictive of s and o.
+func (s Scope) narrower(o Scope) Scope {
+	rank := map[Scope]int{ScopeReadOnly: 0, ScopeOperate: 1, ScopeAdmin: 2}
+	if rank[o] < rank[s] {
+		return o
+	}
+	return s
+}
+
+// Listener networks.
+const (
+	NetworkTCP  = "tcp"
+	NetworkUnix = "unix"
+)
+
+// ListenerConfig describes one address the API serves on. Each listener has
+// its own permission scope and optional bearer token, so a local GUI on a
+// unix socket and a remote admin on a LAN address can coexist safely.
+type ListenerConfig struct {
+	// Network is "tcp" (default) or "unix".
+	Network string
+	// Addr is "host:port" for tcp or a filesystem path for unix.
+	Addr string
+	// Scope restricts permitted methods. Empty means ScopeAdmin.
+	Scope Scope
+	// Token, when non-empty, is required as "Authorization: Bearer <token>".
+	Token string
+	// TLSCertFile and TLSKeyFile enable HTTPS on tcp listeners.
+	TLSCertFile string
+	TLSKeyFile  string
+	// SocketMode sets unix socket permissions (default 0600).
+	SocketMode os.FileMode
+	// Optional listeners that fail to bind are reported as failed
+	// subsystems in /v1/status instead of aborting Start.
+	Optional bool
+}
+
+// String renders the listener as "network://addr" for logs.
+func (lc ListenerConfig) String() string {
+	return lc.Network + "://" + lc.Addr
+}
+
+// normalize fills defaults and validates the listener. Non-loopback TCP
+// listeners must use TLS and a token because they expose the control plane
+// beyond this host.
+func (lc ListenerConfig) normalize() (ListenerConfig, error) {
+	if lc.Network == "" {
+		lc.Network = NetworkTCP
+	}
+	if lc.Scope == "" {
+		lc.Scope = ScopeAdmin
+	}
+	switch lc.Scope {
+	case ScopeAdmin, ScopeOperate, ScopeReadOnly:
+	default:
+		return lc, fmt.Errorf("listener %s: unknown scope %q", lc, lc.Scope)
+	}
+	if lc.Addr == "" {
+		return lc, fmt.Errorf("listener %s: empty address", lc.Network)
+	}
+	if (lc.TLSCertFile == "") != (lc.TLSKeyFile == "") {
+		return lc, fmt.Errorf("listener %s: TLS requires both cert and key files", lc)
+	}
+
+	switch lc.Network {
+	case NetworkUnix:
+		if lc.TLSCertFile != "" {
+			return lc, fmt.Errorf("listener %s: TLS is not supported on unix sockets", lc)
+		}
+		if lc.SocketMode == 0 {
+			lc.SocketMode = 0o600
+		}
+	case NetworkTCP:
+		host, _, err := net.SplitHostPort(lc.Addr)
+		if err != nil {
+			return lc, fmt.Errorf("listener %s: %w", lc, err)
+		}
+		if !isLoopbackHost(host) && (lc.TLSCertFile == "" || lc.Token == "") {
+			return lc, fmt.Errorf("listener %s: non-loopback addresses require TLS and a token", lc)
+		}
+	default:
+		return lc, fmt.Errorf("listener %s: unknown network %q", lc, lc.Network)
+	}
+	return lc, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// boundListener pairs a listener's config with its server and socket.
+type boundListener struct {
+	cfg       ListenerConfig
+	ln        net.Listener
+	raw       net.Listener // the socket under ln, handed over by POST /v1/upgrade
+	http      *http.Server
+	activated bool // raw came from ServerOptions.Activated
+}
+
+// bind opens the socket for lc, or serves pre, a socket bound by the
+// service manager or a previous agent, when it is non-nil. It returns
+// the listener to serve and the socket under it, which differ for TLS.
+// Stale unix socket files are removed first. TLS listeners load their key
+// pair here, so bad files fail Start rather than the serve goroutine. pre
+// is closed on error.
+func bind(lc ListenerConfig, pre net.Listener) (ln, raw net.Listener, err error) {
+	defer func() {
+		if err != nil && pre != nil {
+			pre.Close()
+		}
+	}()
+	if lc.Network == NetworkUnix {
+		if pre != nil {
+			return pre, pre, checkSocketMode(lc)
+		}
+		if fi, err := os.Lstat(lc.Addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(lc.Addr)
+		}
+		ln, err := net.Listen(NetworkUnix, lc.Addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := os.Chmod(lc.Addr, lc.SocketMode); err != nil {
+			ln.Close()
+			return nil, nil, err
+		}
+		return ln, ln, nil
+	}
+	var cert tls.Certificate
+	if lc.TLSCertFile != "" {
+		if cert, err = tls.LoadX509KeyPair(lc.TLSCertFile, lc.TLSKeyFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	raw = pre
+	if raw == nil {
+		if raw, err = net.Listen(NetworkTCP, lc.Addr); err != nil {
+			return nil, nil, err
+		}
+	}
+	if lc.TLSCertFile == "" {
+		return raw, raw, nil
+	}
+	// Plain HTTP sent to this listener is answered by net/http with
+	// "400 Client sent an HTTP request to an HTTPS server" and closed.
+	return tls.NewListener(raw, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}), raw, nil
+}
+
+// checkSocketMode refuses an activated unix socket that grants more than
+// lc.SocketMode, tightening it when this process owns the file. systemd
+// creates sockets 0666 unless the unit sets SocketMode=.
+func checkSocketMode(lc ListenerConfig) error {
+	fi, err := os.Stat(lc.Addr)
+	if err != nil {
+		return err
+	}
+	if fi.Mode().Perm()&^lc.SocketMode.Perm() == 0 {
+		return nil
+	}
+	if err := os.Chmod(lc.Addr, lc.SocketMode); err != nil {
+		return fmt.Errorf("activated socket mode %v is wider than %v (set SocketMode= in the .socket unit): %w", fi.Mode().Perm(), lc.SocketMode.Perm(), err)
+	}
+	return nil
+}
+
+// takeActivated removes and returns the activated socket bound to lc's
+// address, or nil when there is none. A tcp address is resolved, so
+// "localhost:8787" matches a socket on 127.0.0.1:8787.
+func takeActivated(lns *[]net.Listener, lc ListenerConfig) net.Listener {
+	for i, ln := range *lns {
+		if matchesAddr(ln.Addr(), lc) {
+			*lns = append((*lns)[:i], (*lns)[i+1:]...)
+			return ln
+		}
+	}
+	return nil
+}
+
+func matchesAddr(addr net.Addr, lc ListenerConfig) bool {
+	switch a := addr.(type) {
+	case *net.UnixAddr:
+		return lc.Network == NetworkUnix && filepath.Clean(a.Name) == filepath.Clean(lc.Addr)
+	case *net.TCPAddr:
+		if lc.Network != NetworkTCP {
+			return false
+		}
+		want, err := net.ResolveTCPAddr(NetworkTCP, lc.Addr)
+		if err != nil || want.Port != a.Port {
+			return false
+		}
+		return want.IP.Equal(a.IP) || (want.IP == nil || want.IP.IsUnspecified()) && a.IP.IsUnspecified()
+	}
+	return false
+}
+
+// newHTTPServer builds the per-listener http.Server with shared timeouts.
+func (s *Server) newHTTPServer(lc ListenerConfig) *http.Server {
+	handler := withListenerPolicy(s.mux, lc, &s.clients, s.opts.Tokens)
+	handler = withCORS(withForgeryGuard(handler, lc), s.origins)
+	handler = withBasicMiddleware(handler, s.logger.With("listener", lc.String()), s.metrics)
+	return &http.Server{
+		Handler:           handler,
+		ReadTimeout:       s.opts.ReadTimeout,
+		ReadHeaderTimeout: s.opts.ReadHeaderTimeout,
+		WriteTimeout:      s.opts.WriteTimeout,
+		IdleTimeout:       s.opts.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(s.logger.Handler(), slog.LevelError),
+		BaseContext: func(l net.Listener) context.Context {
+			return context.Background()
+		},
+		ConnState: func(_ net.Conn, cs http.ConnState) {
+			switch cs {
+			case http.StateNew:
+				s.metrics.ConnOpened()
+			case http.StateClosed, http.StateHijacked:
+				s.metrics.ConnClosed()
+			}
+		},
+	}
+}
+
+// withListenerPolicy enforces a listener's token and scope before routing,
+// then records the caller in the client registry.
+//
+// Listeners with a static token also accept configured and unexpired minted
+// tokens from store (nil disables them). Once the config file defines
+// tokens, listeners without a static token require one of store's. A store
+// token can narrow the listener's scope but never widen it.
+func withListenerPolicy(next http.Handler, lc ListenerConfig, clients *clientRegistry, store *tokens.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope, identity := lc.Scope, "unauthenticated"
+		if lc.Token != "" || (store != nil && store.HasConfigured()) {
+			if lc.Token != "" && validBearer(r, lc.Token) {
+				identity = tokenID(lc.Token)
+			} else if t, ok := mintedBearer(r, store); ok {
+				identity = "token/" + t.Name
+				scope = scope.narrower(Scope(t.Scope))
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="agent"`)
+				writeJSON(w, http.StatusUnauthorized, APIError{
+					Error:     "missing or invalid bearer token",
+					Timestamp: TimeNow().UTC().Format(time.RFC3339),
+				})
+				return
+			}
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			var denied string
+			switch {
+			case scope == ScopeReadOnly:
+				denied = "credential is read-only"
+			case scope == ScopeOperate && !operateRoutes[r.URL.Path]:
+				denied = "credential may only probe, start, and stop"
+			}
+			if denied != "" {
+				writeJSON(w, http.StatusForbidden, APIError{
+					Error:     denied,
+					Timestamp: TimeNow().UTC().Format(time.RFC3339),
+				})
+				return
+			}
+		}
+		id := clients.touch(r, lc, identity, scope)
+		ctx := context.WithValue(r.Context(), clientIDKey{}, id)
+		ctx = context.WithValue(ctx, scopeKey{}, scope)
+		ctx = context.WithValue(ctx, provenKey{}, lc.Network == NetworkUnix || identity != "unauthenticated")
+		routed := r.WithContext(ctx)
+		next.ServeHTTP(w, routed)
+		// ServeMux sets the pattern on the copy; the metrics middleware
+		// reads it from the original.
+		r.Pattern = routed.Pattern
+	})
+}
+
+// scopeKey carries the effective scope of the request in its context.
+type scopeKey struct{}
+
+// requestScope returns the effective scope set by the listener policy.
+func requestScope(ctx context.Context) Scope {
+	s, _ := ctx.Value(scopeKey{}).(Scope)
+	return s
+}
+
+// provenKey marks requests whose caller proved more than reaching the port.
+type provenKey struct{}
+
+// requireProven writes 403 and returns false unless the request came over a
+// unix socket, whose file mode limits who may connect, or presented a
+// bearer token. A web page can do neither, so endpoints that end or
+// replace the agent require it even where a tokenless listener grants
+// admin scope. action names the endpoint in the error.
+func requireProven(w http.ResponseWriter, r *http.Request, action string) bool {
+	if proven, _ := r.Context().Value(provenKey{}).(bool); proven {
+		return true
+	}
+	writeJSON(w, http.StatusForbidden, APIError{
+		Error:     action + " requires a bearer token or the unix socket listener",
+		Timestamp: TimeNow().UTC().Format(time.RFC3339),
+	})
+	return false
+}
+
+// mintedBearer authenticates the request's bearer token against store.
+func mintedBearer(r *http.Request, store *tokens.Store) (tokens.Token, bool) {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || store == nil {
+		return tokens.Token{}, false
+	}
+	return store.Authenticate(got)
+}
+
+// validBearer compares the request's bearer token in constant time.
+func validBearer(r *http.Request, want string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// closeAll releases sockets bound so far; used when a later bind fails.
+func closeAll(bls []*boundListener) {
+	for _, bl := range bls {
+		_ = bl.ln.Close()
+	}
+}
+
+// errNoListeners is returned by Start when no listener is configured.
+var errNoListeners = errors.New("api: no listeners configured")
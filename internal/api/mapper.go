@@ -1,9 +1,39 @@
 package api
 
 import (
+	"math"
+	"net"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/sanverite/simple-packet-logger/internal/bundle"
+	"github.com/sanverite/simple-packet-logger/internal/bypass"
+	"github.com/sanverite/simple-packet-logger/internal/captive"
 	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/diag"
+	"github.com/sanverite/simple-packet-logger/internal/dnsproxy"
+	"github.com/sanverite/simple-packet-logger/internal/egress"
+	"github.com/sanverite/simple-packet-logger/internal/export"
+	"github.com/sanverite/simple-packet-logger/internal/firewall"
+	"github.com/sanverite/simple-packet-logger/internal/health"
+	"github.com/sanverite/simple-packet-logger/internal/launchd"
+	"github.com/sanverite/simple-packet-logger/internal/leaktest"
+	"github.com/sanverite/simple-packet-logger/internal/metrics"
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+	"github.com/sanverite/simple-packet-logger/internal/netloc"
+	"github.com/sanverite/simple-packet-logger/internal/probe"
+	"github.com/sanverite/simple-packet-logger/internal/profiles"
+	"github.com/sanverite/simple-packet-logger/internal/recovery"
+	"github.com/sanverite/simple-packet-logger/internal/routecheck"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+	"github.com/sanverite/simple-packet-logger/internal/rules"
+	"github.com/sanverite/simple-packet-logger/internal/tokens"
+	"github.com/sanverite/simple-packet-logger/internal/tun2socks"
+	"github.com/sanverite/simple-packet-logger/internal/uplink"
 )
 
 // FromCoreSnapshot converts core.Snapshot to the public StatusResponse.
@@ -22,50 +52,111 @@ func FromCoreSnapshot(s core.Snapshot) StatusResponse {
 	}
 
 	// Defensive copies of slices/maps are already present in core.Snapshot,
-	// but we still treat them immutably on the API side.
+	// but we still treat them immutably on the API side. Empty collections
+	// are normalized to []/{} so the JSON shape does not depend on history.
 	return StatusResponse{
-		State:     string(s.AgentState),
-		StartedAt: started,
-		UptimeSec: uptime,
-		Warnings:  append([]string(nil), s.Warnings...),
+		Rev:        s.Rev,
+		State:      string(s.AgentState),
+		StartedAt:  started,
+		UptimeSec:  uptime,
+		Warnings:   core.WarningMessages(s.Warnings),
+		WarningsV2: fromWarnings(s.Warnings),
 		TUN: TUNView{
-			Name:    s.TUN.Name,
-			Up:      s.TUN.Up,
-			MTU:     s.TUN.MTU,
-			LocalIP: s.TUN.LocalIP,
-			PeerIP:  s.TUN.PeerIP,
+			Name:     s.TUN.Name,
+			Up:       s.TUN.Up,
+			MTU:      s.TUN.MTU,
+			LocalIP:  s.TUN.LocalIP,
+			PeerIP:   s.TUN.PeerIP,
+			LocalIP6: s.TUN.LocalIP6,
 		},
 		Routes: RoutesView{
-			DefaultVia:      s.Routes.DefaultVia,
-			LanCIDRs:        append([]string(nil), s.Routes.LanCIDRs...),
-			BypassHosts:     append([]string(nil), s.Routes.BypassHosts...),
-			ProxyHostRoute:  s.Routes.ProxyHostRoute,
-			OriginalGateway: s.Routes.OriginalGateway,
+			DefaultVia:       s.Routes.DefaultVia,
+			LanCIDRs:         cloneStrings(s.Routes.LanCIDRs),
+			IncludeCIDRs:     cloneStrings(s.Routes.IncludeCIDRs),
+			ExcludeCIDRs:     cloneStrings(s.Routes.ExcludeCIDRs),
+			BypassHosts:      cloneStrings(s.Routes.BypassHosts),
+			ProxyHostRoute:   s.Routes.ProxyHostRoute,
+			ProxyAddrs:       cloneStrings(s.Routes.ProxyAddrs),
+			OriginalGateway:  s.Routes.OriginalGateway,
+			OriginalGateway6: s.Routes.OriginalGateway6,
 		},
 		Tun2Socks: Tun2SocksView{
-			PID:       s.Tun2Socks.PID,
-			UptimeSec: s.Tun2Socks.UptimeSec,
-			TCPOk:     s.Tun2Socks.TCPOk,
-			UDPOk:     s.Tun2Socks.UDPOk,
+			PID:            s.Tun2Socks.PID,
+			UptimeSec:      s.Tun2Socks.UptimeSec,
+			TCPOk:          s.Tun2Socks.TCPOk,
+			UDPOk:          s.Tun2Socks.UDPOk,
+			UDPUnsupported: s.Tun2Socks.UDPUnsupported,
+			LastErrors:     cloneStrings(s.Tun2Socks.LastErrors),
+			Traffic:        fromTraffic(s.Tun2Socks.Traffic),
+		},
+		DNS: DNSView{
+			Listen:            s.DNS.Listen,
+			Upstream:          s.DNS.Upstream,
+			Resolvers:         cloneStrings(s.DNS.Resolvers),
+			OriginalResolvers: cloneStrings(s.DNS.OriginalResolvers),
+			Rewritten:         s.DNS.Rewritten,
 		},
 		LastProbe: ProbeView{
 			Reachable:   s.LastProbe.Reachable,
 			SocksOK:     s.LastProbe.SocksOK,
 			ConnectOK:   s.LastProbe.ConnectOK,
 			UDPOK:       s.LastProbe.UDPOK,
-			LatenciesMs: cloneLatencies(s.LastProbe.LatenciesMs),
+			LatenciesMs: latencyMap(s.LastProbe.Latencies, time.Millisecond),
+			LatenciesUs: latencyMap(s.LastProbe.Latencies, time.Microsecond),
 			Features: ProxyFeatures{
-				Auth: s.LastProbe.Features.Auth,
-				IPv6: s.LastProbe.Features.IPv6,
-				UDP:  s.LastProbe.Features.UDP,
+				Auth:    s.LastProbe.Features.Auth,
+				IPv6:    s.LastProbe.Features.IPv6,
+				UDP:     s.LastProbe.Features.UDP,
+				PathMTU: s.LastProbe.Features.PathMTU,
+				Family:  s.LastProbe.Features.Family,
 			},
 			LastChecked: lastChecked,
-			Warnings:    append([]string(nil), s.LastProbe.Warnings...),
+			Warnings:    core.WarningMessages(s.LastProbe.Warnings),
+			WarningsV2:  fromWarnings(s.LastProbe.Warnings),
+			Hops:        fromProbeHops(s.LastProbe.Hops),
+
+			LatencyPercentiles: fromPercentiles(s.LastProbe.Percentiles),
+			Targets:            fromProbeTargets(s.LastProbe.Targets),
+			TLS:                fromProbeTLS(s.LastProbe.TLS),
+			HTTP:               fromProbeHTTP(s.LastProbe.HTTP),
 		},
+		Health:      fromHealth(s.Health),
+		Subsystems:  fromSubsystems(s.Subsystems),
 		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
 	}
 }
 
+// fromHealth maps the damped proxy health.
+func fromHealth(h core.HealthSnapshot) HealthView {
+	v := HealthView{
+		Status:               string(h.Status),
+		ConsecutiveFailures:  h.ConsecutiveFailures,
+		ConsecutiveSuccesses: h.ConsecutiveSuccesses,
+		Pending:              h.Pending,
+	}
+	if v.Status == "" {
+		v.Status = string(core.HealthUnknown)
+	}
+	if !h.Since.IsZero() {
+		v.Since = h.Since.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+// fromSubsystems maps subsystem health (never nil).
+func fromSubsystems(in []core.Subsystem) []SubsystemView {
+	out := make([]SubsystemView, 0, len(in))
+	for _, sub := range in {
+		out = append(out, SubsystemView{
+			Name:   sub.Name,
+			Status: string(sub.Status),
+			Detail: sub.Detail,
+			Since:  sub.Since.UTC().Format(time.RFC3339),
+		})
+	}
+	return out
+}
+
 // FromProbeSummary converts core.ProbeSummary to the public ProbeView.
 // Keeps slice/map fields immutable by cloning.
 func FromProbeSummary(p core.ProbeSummary) ProbeView {
@@ -78,24 +169,1022 @@ func FromProbeSummary(p core.ProbeSummary) ProbeView {
 		SocksOK:     p.SocksOK,
 		ConnectOK:   p.ConnectOK,
 		UDPOK:       p.UDPOK,
-		LatenciesMs: cloneLatencies(p.LatenciesMs),
+		LatenciesMs: latencyMap(p.Latencies, time.Millisecond),
+		LatenciesUs: latencyMap(p.Latencies, time.Microsecond),
 		Features: ProxyFeatures{
-			Auth: p.Features.Auth,
-			IPv6: p.Features.IPv6,
-			UDP:  p.Features.UDP,
+			Auth:    p.Features.Auth,
+			IPv6:    p.Features.IPv6,
+			UDP:     p.Features.UDP,
+			PathMTU: p.Features.PathMTU,
+			Family:  p.Features.Family,
 		},
 		LastChecked: lastChecked,
-		Warnings:    append([]string(nil), p.Warnings...),
+		Warnings:    core.WarningMessages(p.Warnings),
+		WarningsV2:  fromWarnings(p.Warnings),
+		Hops:        fromProbeHops(p.Hops),
+
+		LatencyPercentiles: fromPercentiles(p.Percentiles),
+		Trace:              fromProbeTrace(p.Trace),
+		Targets:            fromProbeTargets(p.Targets),
+		TLS:                fromProbeTLS(p.TLS),
+		HTTP:               fromProbeHTTP(p.HTTP),
+	}
+}
+
+// fromProbeHTTP maps a probe's HTTP check; nil stays nil.
+func fromProbeHTTP(in *core.ProbeHTTP) *ProbeHTTPView {
+	if in == nil {
+		return nil
+	}
+	return &ProbeHTTPView{
+		URL:         in.URL,
+		OK:          in.OK,
+		Status:      in.Status,
+		BodyBytes:   in.BodyBytes,
+		BodyMatched: in.BodyMatched,
+		Error:       in.Error,
+	}
+}
+
+// fromProbeTLS maps a probe's TLS check; nil stays nil.
+func fromProbeTLS(in *core.ProbeTLS) *ProbeTLSView {
+	if in == nil {
+		return nil
+	}
+	v := &ProbeTLSView{
+		ServerName:  in.ServerName,
+		OK:          in.OK,
+		Error:       in.Error,
+		Version:     in.Version,
+		CipherSuite: in.CipherSuite,
+		ALPN:        in.ALPN,
+		Verified:    in.Verified,
+		VerifyError: in.VerifyError,
+		Subject:     in.Subject,
+		Issuer:      in.Issuer,
+		ChainLen:    in.ChainLen,
+	}
+	if !in.NotAfter.IsZero() {
+		v.NotAfter = in.NotAfter.UTC().Format(time.RFC3339)
+		days := int(math.Floor(in.NotAfter.Sub(TimeNow()).Hours() / 24))
+		v.ExpiresInDays = &days
+	}
+	return v
+}
+
+// fromProbeTargets maps the targets of a multi-target probe by target; nil
+// stays nil.
+func fromProbeTargets(in []core.ProbeTarget) map[string]ProbeTargetView {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]ProbeTargetView, len(in))
+	for _, t := range in {
+		out[t.Target] = ProbeTargetView{
+			OK:        t.OK,
+			LatencyMs: int64(t.Latency / time.Millisecond),
+			LatencyUs: int64(t.Latency / time.Microsecond),
+			Error:     t.Error,
+		}
+	}
+	return out
+}
+
+// fromProbeTrace maps a probe trace; nil stays nil.
+func fromProbeTrace(in []core.ProbeTraceEntry) []ProbeTraceView {
+	if in == nil {
+		return nil
+	}
+	out := make([]ProbeTraceView, 0, len(in))
+	for _, e := range in {
+		out = append(out, ProbeTraceView{
+			Hop:      e.Hop,
+			Dir:      e.Dir,
+			OffsetUs: e.Offset.Microseconds(),
+			Len:      e.Len,
+			Hex:      e.Hex,
+			Text:     e.Text,
+		})
+	}
+	return out
+}
+
+// fromPercentiles maps latency percentiles; nil stays nil.
+func fromPercentiles(in map[string]core.LatencyPercentiles) map[string]LatencyPercentilesView {
+	if in == nil {
+		return nil
+	}
+	out := make(map[string]LatencyPercentilesView, len(in))
+	for step, p := range in {
+		out[step] = LatencyPercentilesView{
+			P50Us:   p.P50.Microseconds(),
+			P90Us:   p.P90.Microseconds(),
+			P99Us:   p.P99.Microseconds(),
+			Samples: p.Samples,
+		}
+	}
+	return out
+}
+
+// fromProbeHops maps chain hops; nil stays nil so single-proxy probes omit
+// the field.
+func fromProbeHops(in []core.ProbeHop) []ProbeHopView {
+	if in == nil {
+		return nil
+	}
+	out := make([]ProbeHopView, 0, len(in))
+	for _, h := range in {
+		out = append(out, ProbeHopView{
+			Server:    h.Server,
+			ProxyType: h.Type,
+			OK:        h.OK,
+			LatencyMs: int64(h.Latency / time.Millisecond),
+			LatencyUs: int64(h.Latency / time.Microsecond),
+			Error:     h.Error,
+		})
+	}
+	return out
+}
+
+// FromMetricsSnapshot converts metrics.Snapshot to the public MetricsResponse,
+// attaching each route's endpoint budget from budgets (may be nil).
+func FromMetricsSnapshot(m metrics.Snapshot, budgets map[string]Budget) MetricsResponse {
+	routes := make(map[string]RouteView, len(m.Routes))
+	for route, rs := range m.Routes {
+		byStatus := make(map[string]int64, len(rs.ByStatus))
+		for code, n := range rs.ByStatus {
+			byStatus[strconv.Itoa(code)] = n
+		}
+		var avg int64
+		if rs.Count > 0 {
+			avg = rs.Duration.Milliseconds() / rs.Count
+		}
+		violations := make(map[string]int64, len(rs.Budget))
+		for kind, n := range rs.Budget {
+			violations[string(kind)] = n
+		}
+		v := RouteView{
+			Count:            rs.Count,
+			Bytes:            rs.Bytes,
+			AvgDurationMs:    avg,
+			ByStatus:         byStatus,
+			LastStatus:       rs.LastStatus,
+			BudgetViolations: violations,
+		}
+		if b, ok := budgets[route]; ok {
+			v.Budget = &BudgetView{MaxTimeMs: b.MaxTime.Milliseconds(), MaxBodyBytes: b.MaxBody, MaxResponseBytes: b.MaxResponse}
+		}
+		routes[route] = v
+	}
+	return MetricsResponse{
+		StartedAt: m.StartedAt.UTC().Format(time.RFC3339),
+		InFlight:  m.InFlight,
+		OpenConns: m.OpenConns,
+		Routes:    routes,
+
+		RouteRepairs:        m.RouteRepairs,
+		RouteRepairFailures: m.RouteRepairFailures,
+		GeneratedAt:         TimeNow().UTC().Format(time.RFC3339),
+	}
+}
+
+// FromRecoveryReport converts recovery.Report to the public RecoveryResponse.
+// Orphans keep the scan order (process, interface, route).
+func FromRecoveryReport(r recovery.Report) RecoveryResponse {
+	orphans := make([]OrphanView, 0, len(r.Orphans))
+	for _, o := range r.Orphans {
+		orphans = append(orphans, fromOrphan(o))
+	}
+	return RecoveryResponse{
+		CheckedAt: r.CheckedAt.UTC().Format(time.RFC3339),
+		Orphans:   orphans,
+		Warnings:  cloneStrings(r.Warnings),
+	}
+}
+
+// FromCleanupResult converts recovery.CleanupResult to the public response.
+func FromCleanupResult(c recovery.CleanupResult, state core.AgentState) RecoveryCleanupResponse {
+	actions := make([]RecoveryActionView, 0, len(c.Actions))
+	for _, a := range c.Actions {
+		v := RecoveryActionView{Orphan: fromOrphan(a.Orphan), OK: a.Err == nil}
+		if a.Err != nil {
+			v.Error = a.Err.Error()
+		}
+		actions = append(actions, v)
+	}
+	return RecoveryCleanupResponse{
+		Actions:   actions,
+		Remaining: FromRecoveryReport(c.Remaining),
+		Reset:     c.Reset,
+		State:     string(state),
+	}
+}
+
+func fromOrphan(o recovery.Orphan) OrphanView {
+	return OrphanView{
+		Kind:     string(o.Kind),
+		Resource: o.Resource,
+		Detail:   o.Detail,
+		Action:   o.Action,
+	}
+}
+
+// FromTimeline converts core timeline entries to the public TimelineResponse.
+func FromTimeline(since time.Time, entries []core.TimelineEntry) TimelineResponse {
+	out := make([]TimelineEntry, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, TimelineEntry{
+			At:      e.At.UTC().Format(time.RFC3339),
+			Kind:    string(e.Kind),
+			Summary: e.Summary,
+			From:    e.From,
+			To:      e.To,
+		})
+	}
+	return TimelineResponse{
+		Since:       since.UTC().Format(time.RFC3339),
+		Entries:     out,
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+}
+
+// FromEvent maps one event log entry; Data is copied.
+func FromEvent(e core.Event) EventView {
+	data := make(map[string]string, len(e.Data))
+	for k, v := range e.Data {
+		data[k] = v
+	}
+	return EventView{
+		ID:       e.ID,
+		At:       e.At.UTC().Format(time.RFC3339),
+		Type:     string(e.Type),
+		Severity: e.Severity().String(),
+		Message:  e.Message,
+		Data:     data,
+	}
+}
+
+// FromEvents converts core events to the public EventsHistoryResponse.
+func FromEvents(events []core.Event, truncated bool, lastID uint64) EventsHistoryResponse {
+	out := make([]EventView, 0, len(events))
+	for _, e := range events {
+		out = append(out, FromEvent(e))
+	}
+	hasMore := len(events) > 0 && events[len(events)-1].ID < lastID
+	return EventsHistoryResponse{
+		Events:    out,
+		LastID:    lastID,
+		HasMore:   hasMore,
+		Truncated: truncated,
 	}
 }
 
-func cloneLatencies(in map[string]int64) map[string]int64 {
-	if len(in) == 0 {
+// fromTraffic maps TUN counters; nil while they are not measured.
+func fromTraffic(t core.TrafficSnapshot) *TrafficView {
+	if t.SampledAt.IsZero() {
 		return nil
 	}
+	return &TrafficView{
+		BytesIn:    t.BytesIn,
+		BytesOut:   t.BytesOut,
+		PacketsIn:  t.PacketsIn,
+		PacketsOut: t.PacketsOut,
+		RateInBps:  int64(math.Round(t.RateIn)),
+		RateOutBps: int64(math.Round(t.RateOut)),
+	}
+}
+
+// FromConnections maps the page of c after afterID, up to limit
+// connections; durations run to now.
+func FromConnections(c core.ConnectionsSnapshot, afterID uint64, limit int, now time.Time) ConnectionsResponse {
+	start := slices.IndexFunc(c.Connections, func(conn core.Connection) bool { return conn.ID > afterID })
+	if start < 0 {
+		start = len(c.Connections)
+	}
+	page := c.Connections[start:]
+	hasMore := len(page) > limit
+	if hasMore {
+		page = page[:limit]
+	}
+	out := make([]ConnectionView, 0, len(page))
+	for _, conn := range page {
+		out = append(out, ConnectionView{
+			ID:         conn.ID,
+			Engine:     conn.Engine,
+			Proto:      conn.Proto,
+			Src:        conn.Src.String(),
+			Dst:        conn.Dst.String(),
+			State:      conn.State,
+			BytesUp:    conn.BytesUp,
+			BytesDown:  conn.BytesDown,
+			StartedAt:  conn.StartedAt.UTC().Format(time.RFC3339),
+			DurationMs: max(now.Sub(conn.StartedAt), 0).Milliseconds(),
+		})
+	}
+	resp := ConnectionsResponse{
+		Connections: out,
+		Total:       len(c.Connections),
+		HasMore:     hasMore,
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+	}
+	if hasMore {
+		resp.NextAfterID = page[len(page)-1].ID
+	}
+	if !c.UpdatedAt.IsZero() {
+		resp.UpdatedAt = c.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// latencyMap converts step durations to whole units (truncating), e.g.
+// time.Millisecond for latencies_ms. It never returns nil so that JSON
+// output is always an object ({}), never null; encoding/json emits map keys
+// in sorted order, which keeps the serialized form stable across calls.
+func latencyMap(in map[string]time.Duration, unit time.Duration) map[string]int64 {
 	out := make(map[string]int64, len(in))
 	for k, v := range in {
-		out[k] = v
+		out[k] = int64(v / unit)
 	}
 	return out
 }
+
+// cloneStrings copies a string slice, preserving order. It never returns nil
+// so that JSON output is always an array ([]), never null.
+func cloneStrings(in []string) []string {
+	out := make([]string, len(in))
+	copy(out, in)
+	return out
+}
+
+// fromWarnings maps warnings; empty maps to [].
+func fromWarnings(ws []core.Warning) []WarningView {
+	out := make([]WarningView, len(ws))
+	for i, w := range ws {
+		out[i] = WarningView{Code: w.Code, Severity: w.Severity.String(), Source: w.Source, Message: w.Message, Count: w.Count}
+		if !w.Time.IsZero() {
+			out[i].Time = w.Time.UTC().Format(time.RFC3339)
+		}
+		if !w.First.IsZero() {
+			out[i].FirstTime = w.First.UTC().Format(time.RFC3339)
+		}
+	}
+	return out
+}
+
+// FromProbes maps registered probes to the /v1/probe/types response.
+func FromProbes(probes []probe.Probe) ProbeTypesResponse {
+	out := ProbeTypesResponse{Types: make([]ProbeTypeView, 0, len(probes))}
+	for _, p := range probes {
+		v := ProbeTypeView{Name: p.Name()}
+		if d, ok := p.(probe.Describer); ok {
+			v.Description = d.Description()
+		}
+		out.Types = append(out.Types, v)
+	}
+	return out
+}
+
+// FromBypassEntries maps normalized bypass entries (never nil).
+func FromBypassEntries(entries []bypass.Entry) []BypassHostView {
+	out := make([]BypassHostView, 0, len(entries))
+	for _, e := range entries {
+		v := BypassHostView{Input: e.Input, Kind: string(e.Kind), Prefixes: make([]string, 0, len(e.Prefixes))}
+		for _, p := range e.Prefixes {
+			v.Prefixes = append(v.Prefixes, p.String())
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// FromContention maps a contention sample (never nil slices).
+func FromContention(c diag.Contention) ContentionResponse {
+	sites := func(in []diag.Site) []ContentionSite {
+		out := make([]ContentionSite, 0, len(in))
+		for _, s := range in {
+			out = append(out, ContentionSite{
+				Count:   s.Count,
+				DelayMs: float64(s.Delay.Microseconds()) / 1000,
+				Stack:   cloneStrings(s.Stack),
+			})
+		}
+		return out
+	}
+	return ContentionResponse{
+		WindowMs:    c.Window.Milliseconds(),
+		Mutex:       sites(c.Mutex),
+		Block:       sites(c.Block),
+		Goroutines:  c.Goroutines,
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+}
+
+// FromBuildInfo maps the build's version and VCS stamp with the platform
+// features; info is nil when the binary carries none.
+func FromBuildInfo(info *debug.BuildInfo, features []FeatureView) VersionResponse {
+	resp := VersionResponse{
+		Version:     "unknown",
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		Features:    append([]FeatureView{}, features...),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	if info == nil {
+		return resp
+	}
+	resp.Version = info.Main.Version
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			resp.Revision = s.Value
+		case "vcs.time":
+			resp.BuildTime = s.Value
+		case "vcs.modified":
+			resp.Modified = s.Value == "true"
+		}
+	}
+	return resp
+}
+
+// FromServiceStatus maps a launch daemon's status with the job the agent
+// would install.
+func FromServiceStatus(st launchd.Status, job launchd.Job, warnings []string) ServiceResponse {
+	return ServiceResponse{
+		Manager:      "launchd",
+		Label:        st.Label,
+		PlistPath:    st.PlistPath,
+		Installed:    st.Installed,
+		Loaded:       st.Loaded,
+		State:        st.State,
+		PID:          st.PID,
+		UnderLaunchd: launchd.Under(st.Label),
+		Program:      job.Program,
+		Args:         append([]string{}, job.Args...),
+		Warnings:     warnings,
+		GeneratedAt:  TimeNow().UTC().Format(time.RFC3339),
+	}
+}
+
+// FromRuntime maps runtime statistics.
+func FromRuntime(rt diag.Runtime) RuntimeResponse {
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	resp := RuntimeResponse{
+		GoVersion:  rt.GoVersion,
+		GOMAXPROCS: rt.GOMAXPROCS,
+		NumCPU:     rt.NumCPU,
+		Goroutines: rt.Goroutines,
+		CgoCalls:   rt.CgoCalls,
+		UptimeSec:  int64(rt.Uptime.Seconds()),
+		Heap: RuntimeHeapView{
+			Alloc:      rt.HeapAlloc,
+			Inuse:      rt.HeapInuse,
+			Idle:       rt.HeapIdle,
+			Released:   rt.HeapReleased,
+			Objects:    rt.HeapObjects,
+			HeapSys:    rt.HeapSys,
+			StackInuse: rt.StackInuse,
+			Sys:        rt.Sys,
+			TotalAlloc: rt.TotalAlloc,
+			Mallocs:    rt.Mallocs,
+			Frees:      rt.Frees,
+		},
+		GC: RuntimeGCView{
+			NumGC:        rt.NumGC,
+			NumForcedGC:  rt.NumForcedGC,
+			NextGC:       rt.NextGC,
+			PauseTotalMs: ms(rt.PauseTotal),
+			LastPauseMs:  ms(rt.LastPause),
+			CPUFraction:  rt.GCCPUFraction,
+		},
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	if !rt.LastGC.IsZero() {
+		resp.GC.LastGC = rt.LastGC.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// FromTun2SocksLogs maps captured tun2socks output (never a nil slice).
+func FromTun2SocksLogs(lines []tun2socks.Line, capacity int, total, dropped uint64) Tun2SocksLogsResponse {
+	out := make([]Tun2SocksLogLine, 0, len(lines))
+	for _, l := range lines {
+		out = append(out, Tun2SocksLogLine{
+			At:     l.At.UTC().Format(time.RFC3339Nano),
+			Stream: l.Stream,
+			Text:   l.Text,
+			Error:  l.Error,
+		})
+	}
+	return Tun2SocksLogsResponse{
+		Lines:       out,
+		Capacity:    capacity,
+		Total:       total,
+		Dropped:     dropped,
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+}
+
+// FromBundle maps a diagnostics bundle written to path (never nil slices).
+func FromBundle(path string, size int, files []bundle.File, skipped []bundle.Skipped) DiagnosticsResponse {
+	resp := DiagnosticsResponse{
+		Path:        path,
+		Bytes:       size,
+		Files:       make([]DiagnosticsFileView, 0, len(files)),
+		Skipped:     make([]DiagnosticsSkipView, 0, len(skipped)),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, f := range files {
+		resp.Files = append(resp.Files, DiagnosticsFileView{Name: f.Name, Bytes: f.Bytes})
+	}
+	for _, sk := range skipped {
+		resp.Skipped = append(resp.Skipped, DiagnosticsSkipView{Name: sk.Name, Reason: sk.Reason})
+	}
+	return resp
+}
+
+// FromInterfaces maps interfaces, attaching the default routes of each;
+// routes4 and routes6 are preferred first.
+func FromInterfaces(ifaces []netinfo.Interface, routes4, routes6 []netinfo.Route) InterfacesResponse {
+	resp := InterfacesResponse{
+		Interfaces:  make([]InterfaceView, 0, len(ifaces)),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, ifc := range ifaces {
+		v := InterfaceView{
+			Name:         ifc.Name,
+			Index:        ifc.Index,
+			MTU:          ifc.MTU,
+			HardwareAddr: ifc.HardwareAddr,
+			Flags:        []string{},
+			Up:           ifc.Flags&net.FlagUp != 0,
+			Loopback:     ifc.Flags&net.FlagLoopback != 0,
+			PointToPoint: ifc.Flags&net.FlagPointToPoint != 0,
+			Prefixes:     make([]string, 0, len(ifc.Prefixes)),
+		}
+		if ifc.Flags != 0 {
+			v.Flags = strings.Split(ifc.Flags.String(), "|")
+		}
+		for _, p := range ifc.Prefixes {
+			v.Prefixes = append(v.Prefixes, p.String())
+		}
+		for _, fam := range []struct {
+			name   string
+			routes []netinfo.Route
+		}{{"ipv4", routes4}, {"ipv6", routes6}} {
+			for i, rt := range fam.routes {
+				if rt.Interface != ifc.Name {
+					continue
+				}
+				rv := InterfaceRouteView{Family: fam.name, Metric: rt.Metric, Preferred: i == 0}
+				if rt.Gateway.IsValid() {
+					rv.Gateway = rt.Gateway.String()
+				}
+				v.DefaultRoutes = append(v.DefaultRoutes, rv)
+			}
+		}
+		resp.Interfaces = append(resp.Interfaces, v)
+	}
+	return resp
+}
+
+// FromPlan maps a route plan.
+func FromPlan(p routeplan.Plan) *PlanView {
+	routes := func(in []routeplan.Route) []PlanRouteView {
+		out := make([]PlanRouteView, 0, len(in))
+		for _, r := range in {
+			v := PlanRouteView{Dst: r.Dst.String(), Dev: r.Dev, Reason: string(r.Reason)}
+			if r.Via.IsValid() {
+				v.Via = r.Via.String()
+			}
+			out = append(out, v)
+		}
+		return out
+	}
+	tun := TUNView{Name: p.TUN.Name, MTU: p.TUN.MTU, LocalIP: p.TUN.Local4.String(), PeerIP: p.TUN.Peer4.String(), Subnet: p.TUN.Subnet4.String()}
+	if p.TUN.Local6.IsValid() {
+		tun.LocalIP6 = p.TUN.Local6.String()
+	}
+	uplink := PlanUplinkView{Interface: p.Uplink4.Interface}
+	if p.Uplink4.Addr.IsValid() {
+		uplink.Gateway = p.Uplink4.Addr.String()
+	}
+	if p.Uplink6.Addr.IsValid() {
+		uplink.Gateway6 = p.Uplink6.Addr.String()
+	}
+	return &PlanView{TUN: tun, IPv6: p.IPv6, Split: p.Split, Uplink: uplink, Routes: routes(p.Routes), Restore: routes(p.Restore)}
+}
+
+// fromMTUChoice maps a TUN MTU choice.
+func fromMTUChoice(c routeplan.MTUChoice) MTUView {
+	return MTUView{MTU: c.MTU, Source: c.Source, Reason: c.Reason}
+}
+
+// FromRouteChecks maps verified route entries (never nil).
+func FromRouteChecks(entries []routecheck.Entry) []RouteCheckView {
+	out := make([]RouteCheckView, 0, len(entries))
+	for _, e := range entries {
+		v := RouteCheckView{
+			Kind:   string(e.Kind),
+			Target: e.Target,
+			Host:   e.Host,
+			Expect: e.Expect,
+			Status: string(e.Status),
+			Detail: e.Detail,
+			Dev:    e.Route.Interface,
+		}
+		if e.Route.Prefix.IsValid() {
+			v.Matched = e.Route.Prefix.String()
+		}
+		if e.Route.Gateway.IsValid() {
+			v.Via = e.Route.Gateway.String()
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// FromHealthReport maps a health sweep report.
+func FromHealthReport(rep health.Report) HealthReportResponse {
+	checks := make([]HealthCheckView, 0, len(rep.Results))
+	for _, r := range rep.Results {
+		checks = append(checks, HealthCheckView{
+			Name:       r.Name,
+			Kind:       r.Kind,
+			Status:     string(r.Status),
+			Detail:     r.Detail,
+			DurationMs: r.Duration.Milliseconds(),
+		})
+	}
+	return HealthReportResponse{
+		Status:         string(rep.Status),
+		BudgetMs:       rep.Budget.Milliseconds(),
+		ElapsedMs:      rep.Elapsed.Milliseconds(),
+		BudgetExceeded: rep.BudgetExceeded,
+		Checks:         checks,
+		StartedAt:      rep.StartedAt.UTC().Format(time.RFC3339),
+		GeneratedAt:    TimeNow().UTC().Format(time.RFC3339),
+	}
+}
+
+// FromRules maps the rule engine's patterns, app rules, and routes.
+func FromRules(patterns []rules.Pattern, apps []rules.AppRule, routes []rules.Route, stats []rules.RuleStats, profile, attribution string) RulesResponse {
+	resp := RulesResponse{
+		Rules:          make([]string, 0, len(patterns)),
+		Routes:         make([]RuleRouteView, 0, len(routes)),
+		Apps:           make([]AppRuleView, 0, len(apps)),
+		Profile:        profile,
+		Stats:          make([]RuleStatsView, 0, len(stats)),
+		AppAttribution: attribution,
+		GeneratedAt:    TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, st := range stats {
+		v := RuleStatsView{
+			Rule:    st.Rule,
+			Kind:    st.Kind,
+			Verdict: string(st.Verdict),
+			Flows:   st.Flows,
+			Packets: st.Packets,
+			Bytes:   st.Bytes,
+		}
+		if !st.LastMatch.IsZero() {
+			v.LastMatch = st.LastMatch.UTC().Format(time.RFC3339)
+		}
+		resp.Stats = append(resp.Stats, v)
+	}
+	for _, a := range apps {
+		resp.Apps = append(resp.Apps, AppRuleView{Path: a.Path, User: a.User})
+	}
+	for _, p := range patterns {
+		resp.Rules = append(resp.Rules, string(p))
+	}
+	for _, r := range routes {
+		resp.Routes = append(resp.Routes, RuleRouteView{
+			Addr:      r.Addr.String(),
+			Domain:    r.Domain,
+			Rule:      string(r.Pattern),
+			ExpiresAt: r.ExpiresAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return resp
+}
+
+// FromToken maps minted token metadata.
+func FromToken(t tokens.Token) TokenView {
+	if t.Configured {
+		return TokenView{Name: t.Name, Scope: t.Scope, Identity: "token/" + t.Name, Source: TokenSourceConfig}
+	}
+	return TokenView{
+		Name:      t.Name,
+		Scope:     t.Scope,
+		Identity:  "token/" + t.Name,
+		Source:    TokenSourceMinted,
+		CreatedAt: t.CreatedAt.UTC().Format(time.RFC3339),
+		ExpiresAt: t.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// FromTokens maps the token list.
+func FromTokens(list []tokens.Token) TokensResponse {
+	resp := TokensResponse{
+		Tokens:      make([]TokenView, 0, len(list)),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, t := range list {
+		resp.Tokens = append(resp.Tokens, FromToken(t))
+	}
+	return resp
+}
+
+// FromProfile maps a stored profile.
+func FromProfile(p profiles.Profile) ProfileView {
+	return ProfileView{
+		Name:         p.Name,
+		SocksServer:  p.SocksServer,
+		AuthRef:      p.AuthRef,
+		MTU:          p.MTU,
+		BypassHosts:  append([]string{}, p.BypassHosts...),
+		DNSUpstreams: append([]string{}, p.DNSUpstreams...),
+		DisableDNS:   p.DisableDNS,
+		Match:        fromProfileMatch(p.Match),
+		UpdatedAt:    p.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func fromProfileMatch(m *profiles.Match) *ProfileMatchView {
+	if m == nil {
+		return nil
+	}
+	return &ProfileMatchView{SSIDs: m.SSIDs, GatewayMACs: m.GatewayMACs, SearchDomains: m.SearchDomains}
+}
+
+// FromNetworkLocation maps the location watcher status.
+func FromNetworkLocation(st netloc.Status) NetworkLocationView {
+	loc := st.Location
+	v := NetworkLocationView{
+		SSID:          loc.SSID,
+		GatewayMAC:    loc.GatewayMAC,
+		SearchDomains: append([]string{}, loc.SearchDomains...),
+		Summary:       loc.String(),
+		CheckedAt:     st.Checked.UTC().Format(time.RFC3339),
+		ChangedAt:     st.Changed.UTC().Format(time.RFC3339),
+		Error:         st.Err,
+	}
+	if loc.Gateway.IsValid() {
+		v.Gateway = loc.Gateway.String()
+	}
+	return v
+}
+
+// FromProfiles maps the profile list.
+func FromProfiles(list []profiles.Profile) ProfilesResponse {
+	resp := ProfilesResponse{
+		Profiles:    make([]ProfileView, 0, len(list)),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, p := range list {
+		resp.Profiles = append(resp.Profiles, FromProfile(p))
+	}
+	return resp
+}
+
+// FromCaptive maps the captive portal detector status.
+func FromCaptive(st captive.Status) CaptiveResponse {
+	resp := CaptiveResponse{
+		State:       st.State,
+		PortalURL:   st.PortalURL,
+		Interface:   st.Interface,
+		Results:     make([]CaptiveResultView, 0, len(st.Results)),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, r := range st.Results {
+		resp.Results = append(resp.Results, CaptiveResultView{
+			URL:       r.URL,
+			OK:        r.OK,
+			Status:    r.Status,
+			Location:  r.Location,
+			Error:     r.Error,
+			LatencyMS: r.Latency.Milliseconds(),
+		})
+	}
+	if !st.Checked.IsZero() {
+		resp.CheckedAt = st.Checked.UTC().Format(time.RFC3339)
+	}
+	if !st.Since.IsZero() {
+		resp.Since = st.Since.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// FromEgressIdentity maps one egress lookup; cached marks a reused answer.
+func FromEgressIdentity(id egress.Identity, cached bool) EgressIdentityView {
+	return EgressIdentityView{
+		IP:        id.IP,
+		Country:   id.Country,
+		Region:    id.Region,
+		City:      id.City,
+		ASN:       id.ASN,
+		Org:       id.Org,
+		Interface: id.Interface,
+		Error:     id.Error,
+		LatencyMS: id.Latency.Milliseconds(),
+		Cached:    cached,
+		CheckedAt: id.Checked.UTC().Format(time.RFC3339),
+	}
+}
+
+// FromFirewall maps a firewall's installed sets and latest teardown.
+func FromFirewall(fw *firewall.Firewall) FirewallResponse {
+	resp := FirewallResponse{
+		Backend:     fw.Backend(),
+		Sets:        []FirewallSetView{},
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, set := range fw.Status() {
+		v := FirewallSetView{
+			Name:        set.Name,
+			Rules:       make([]FirewallRuleView, 0, len(set.Rules)),
+			InstalledAt: set.Installed.UTC().Format(time.RFC3339),
+		}
+		for i, r := range set.Rules {
+			rv := FirewallRuleView{Action: r.Action, Family: r.Family, Out: r.Out, Comment: r.Comment, Native: set.Native[i]}
+			if r.Dst.IsValid() {
+				rv.Dst = r.Dst.Masked().String()
+			}
+			v.Rules = append(v.Rules, rv)
+		}
+		resp.Sets = append(resp.Sets, v)
+	}
+	if t := fw.LastTeardown(); !t.At.IsZero() {
+		resp.LastTeardown = &FirewallTeardown{
+			At:      t.At.UTC().Format(time.RFC3339),
+			Removed: append([]string{}, t.Removed...),
+			Error:   t.Err,
+		}
+	}
+	return resp
+}
+
+// FromLeakTest maps a DNS leak test with the forwarder and routing found.
+func FromLeakTest(res leaktest.Result, fwd LeakForwarderView, routing LeakRoutingView) LeakTestResponse {
+	resp := LeakTestResponse{
+		Verdict:     res.Verdict,
+		Reason:      res.Reason,
+		Forwarder:   fwd,
+		Routing:     routing,
+		Queries:     make([]LeakQueryView, 0, len(res.Queries)),
+		Resolvers:   append([]string{}, res.Resolvers...),
+		WhoAmIError: res.WhoAmIErr,
+		ElapsedMS:   res.Elapsed.Milliseconds(),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, q := range res.Queries {
+		resp.Queries = append(resp.Queries, LeakQueryView{
+			Name:      q.Name,
+			Answered:  q.Answered,
+			Observed:  q.Observed,
+			Addrs:     q.Addrs,
+			Error:     q.Error,
+			LatencyMS: q.Latency.Milliseconds(),
+		})
+	}
+	return resp
+}
+
+// FromUplinks maps the uplink monitor status.
+func FromUplinks(st uplink.Status) UplinksResponse {
+	resp := UplinksResponse{
+		Active:      st.Active,
+		Links:       make([]UplinkView, 0, len(st.Links)),
+		Switches:    st.Switches,
+		Error:       st.Err,
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, l := range st.Links {
+		v := UplinkView{Interface: l.Interface, Metric: l.Metric, Usable: l.Usable, Active: l.Interface == st.Active, Reason: l.Reason}
+		if l.Gateway.IsValid() {
+			v.Gateway = l.Gateway.String()
+		}
+		resp.Links = append(resp.Links, v)
+	}
+	if !st.Checked.IsZero() {
+		resp.CheckedAt = st.Checked.UTC().Format(time.RFC3339)
+	}
+	if st.Switches > 0 {
+		resp.LastSwitch = &SwitchView{
+			From:   st.LastSwitch.From,
+			To:     st.LastSwitch.To,
+			Reason: st.LastSwitch.Reason,
+			At:     st.LastSwitch.At.UTC().Format(time.RFC3339),
+		}
+	}
+	return resp
+}
+
+// FromDNSUpstreams maps the forwarder status and upstream health.
+func FromDNSUpstreams(st dnsproxy.Status, ups []dnsproxy.UpstreamStatus) DNSUpstreamsResponse {
+	stamp := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	resp := DNSUpstreamsResponse{
+		Running:     st.Running,
+		Queries:     st.Queries,
+		Failures:    st.Failures,
+		Upstreams:   make([]DNSUpstreamView, 0, len(ups)),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, u := range ups {
+		resp.Upstreams = append(resp.Upstreams, DNSUpstreamView{
+			Upstream:            u.Upstream,
+			Scheme:              u.Scheme,
+			Pinned:              u.Pinned,
+			Healthy:             u.Healthy,
+			Attempts:            u.Attempts,
+			Failures:            u.Failures,
+			ConsecutiveFailures: u.ConsecutiveFailures,
+			LatencyMS:           math.Round(u.LatencyMS*10) / 10,
+			LastError:           u.LastError,
+			LastErrorAt:         stamp(u.LastErrorAt),
+			LastSuccessAt:       stamp(u.LastSuccessAt),
+			CooldownUntil:       stamp(u.CooldownUntil),
+		})
+	}
+	return resp
+}
+
+// FromOperation maps a tracked operation to its view.
+func FromOperation(op core.Operation) OperationView {
+	end := op.FinishedAt
+	v := OperationView{
+		ID:        op.ID,
+		Kind:      op.Kind,
+		Client:    op.Client,
+		Async:     op.Async,
+		Status:    string(op.Status),
+		StartedAt: op.StartedAt.UTC().Format(time.RFC3339),
+		Steps:     make([]OperationStepView, 0, len(op.Steps)),
+		Error:     op.Error,
+		State:     string(op.State),
+	}
+	if end.IsZero() {
+		end = TimeNow()
+	} else {
+		v.FinishedAt = end.UTC().Format(time.RFC3339)
+	}
+	v.ElapsedMS = end.Sub(op.StartedAt).Milliseconds()
+	for _, st := range op.Steps {
+		sv := OperationStepView{Name: st.Name, Status: string(st.Status), Error: st.Error}
+		if !st.FinishedAt.IsZero() {
+			sv.DurationMS = st.FinishedAt.Sub(st.StartedAt).Milliseconds()
+		}
+		v.Steps = append(v.Steps, sv)
+	}
+	return v
+}
+
+// FromStateMachine maps core's transition table with the current state.
+func FromStateMachine(current core.AgentState) StateMachineResponse {
+	resp := StateMachineResponse{
+		States:      make([]string, 0, len(core.AgentStates)),
+		Initial:     string(core.StateInactive),
+		Current:     string(current),
+		Transitions: []StateTransitionView{},
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, st := range core.AgentStates {
+		resp.States = append(resp.States, string(st))
+	}
+	for _, t := range core.Transitions() {
+		resp.Transitions = append(resp.Transitions, StateTransitionView{From: string(t.From), To: string(t.To)})
+	}
+	return resp
+}
+
+// FromRecord maps a streamed record with the client's drop count.
+func FromRecord(rec export.Record, dropped uint64) StreamMessage {
+	msg := StreamMessage{Kind: string(rec.Kind), Dropped: dropped}
+	switch {
+	case rec.Event != nil:
+		ev := FromEvent(*rec.Event)
+		msg.Event = &ev
+	case rec.Flow != nil:
+		f := rec.Flow
+		msg.Flow = &FlowView{
+			Proto:    f.Proto,
+			Src:      f.Src.String(),
+			Dst:      f.Dst.String(),
+			Packets:  f.Packets,
+			Bytes:    f.Bytes,
+			TCPFlags: f.TCPFlags,
+			Start:    f.Start.UTC().Format(time.RFC3339),
+			End:      f.End.UTC().Format(time.RFC3339),
+			Verdict:  f.Verdict,
+			Rule:     f.Rule,
+			Profile:  f.Profile,
+		}
+	}
+	return msg
+}
@@ -0,0 +1,380 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPI 3.0 document for the control plane, served at GET /v1/openapi.json.
+//
+// Schemas are derived by reflection from the public JSON types in types.go,
+// so field additions there show up automatically. Operations are listed in
+// apiOperations; every route registered in NewServer must have an entry
+// (openapi_test.go checks).
+
+// apiOperation describes one method+path for the OpenAPI document.
+type apiOperation struct {
+	Method   string
+	Path     string // without the /v1 prefix; "{name}" segments are path parameters
+	Summary  string
+	Params   []apiParam // path parameters, in order
+	Query    []apiParam
+	Request  any   // zero value of the request body type, or nil
+	Response any   // zero value of the 2xx body type, or nil
+	Status   int   // success status (default 200)
+	Errors   []int // documented error statuses (APIError body)
+}
+
+// apiParam is a query parameter.
+type apiParam struct {
+	Name        string
+	Type        string // OpenAPI primitive: string, integer, boolean
+	Description string
+}
+
+var (
+	paramHumanize = apiParam{Name: "humanize", Type: "boolean", Description: "Add human-readable *_human companion fields."}
+	paramTZ       = apiParam{Name: "tz", Type: "string", Description: "IANA timezone for *_local companion timestamps."}
+
+	// streamParams filter event streams (see parseStreamFilter).
+	streamParams = []apiParam{
+		{Name: "kinds", Type: "string", Description: "Comma-separated: events, flows (default events)."},
+		{Name: "types", Type: "string", Description: "Comma-separated event types (default all)."},
+		{Name: "min_severity", Type: "string", Description: "info (default), warning, or error."},
+		{Name: "flow_proto", Type: "string", Description: "tcp, udp, or an IP protocol number."},
+		{Name: "flow_cidr", Type: "string", Description: "Source or destination inside this CIDR or IP."},
+		{Name: "flow_port", Type: "integer", Description: "Source or destination port."},
+		{Name: "flow_verdict", Type: "string", Description: "tunnel, direct, or block."},
+		{Name: "flow_rule", Type: "string", Description: "Deciding rule, as in GET /v1/rules stats."},
+		{Name: "buffer", Type: "integer", Description: "Per-client buffer in records (16-4096, default 256); the oldest are dropped when full."},
+	}
+)
+
+// apiOperations enumerates every documented endpoint.
+var apiOperations = []apiOperation{
+	{Method: http.MethodGet, Path: "/healthz", Summary: "Liveness/readiness check.",
+		Response: map[string]string{}, Errors: []int{405}},
+	{Method: http.MethodGet, Path: "/status", Summary: "Snapshot of daemon state; sends an ETag and answers 304 to a matching If-None-Match.",
+		Query: []apiParam{
+			{Name: "since_rev", Type: "integer", Description: "Return the merge patch of /status/diff instead of the full snapshot."},
+			paramHumanize, paramTZ,
+		},
+		Response: StatusResponse{}, Errors: []int{400, 405, 410}},
+	{Method: http.MethodGet, Path: "/status/diff", Summary: "JSON Merge Patch of status changes since a revision.",
+		Query: []apiParam{
+			{Name: "since_rev", Type: "integer", Description: "Revision from a previous status response (required)."},
+			paramHumanize, paramTZ,
+		},
+		Response: map[string]any{}, Errors: []int{400, 405, 410}},
+	{Method: http.MethodGet, Path: "/metrics", Summary: "Per-route request counters.",
+		Query: []apiParam{paramHumanize, paramTZ}, Response: MetricsResponse{}, Errors: []int{400, 405}},
+	{Method: http.MethodGet, Path: "/ws", Summary: "WebSocket stream of StatusResponse frames, or StreamMessage frames with stream=events.",
+		Query: append([]apiParam{
+			{Name: "interval_ms", Type: "integer", Description: "Push cadence in milliseconds (>= 250)."},
+			paramHumanize, paramTZ,
+			{Name: "stream", Type: "string", Description: "status (default) or events; events takes the filters of /events/stream."},
+		}, streamParams...),
+		Status: http.StatusSwitchingProtocols, Errors: []int{400, 405, 426, 503}},
+	{Method: http.MethodPost, Path: "/probe", Summary: "Run a bounded probe (SOCKS5 by default).",
+		Query: []apiParam{paramTZ}, Request: ProbeRequest{}, Response: ProbeView{}, Errors: []int{400, 403, 405, 429, 500, 501, 502, 503}},
+	{Method: http.MethodGet, Path: "/probe/types", Summary: "Registered probe types.",
+		Response: ProbeTypesResponse{}, Errors: []int{405}},
+	{Method: http.MethodGet, Path: "/probe/active", Summary: "Probes and health sweeps in flight.",
+		Response: ActiveProbesResponse{}, Errors: []int{405}},
+	{Method: http.MethodDelete, Path: "/probe/active", Summary: "Cancel in-flight probes; ?id= cancels one.",
+		Query:    []apiParam{{Name: "id", Type: "string", Description: "Probe to cancel (X-Probe-ID); empty cancels all."}},
+		Response: ProbeCancelResponse{}, Errors: []int{403, 404, 405}},
+	{Method: http.MethodPost, Path: "/start", Summary: "Start routing traffic via TUN + tun2socks.",
+		Request: StartRequest{}, Response: StartResponse{}, Errors: []int{400, 403, 405, 409, 429, 500, 501, 503}},
+	{Method: http.MethodPost, Path: "/stop", Summary: "Tear down orchestration and restore routes.",
+		Request: StopRequest{}, Response: StopResponse{}, Errors: []int{400, 405, 409, 429, 500, 501}},
+	{Method: http.MethodPost, Path: "/pause", Summary: "Route around the tunnel, keeping the TUN and tun2socks up.",
+		Request: PauseRequest{}, Response: PauseResponse{}, Errors: []int{400, 405, 409, 429, 500, 501}},
+	{Method: http.MethodPost, Path: "/resume", Summary: "Route through the paused tunnel again.",
+		Request: PauseRequest{}, Response: PauseResponse{}, Errors: []int{400, 405, 409, 429, 500, 501}},
+	{Method: http.MethodPost, Path: "/warnings/clear", Summary: "Clear state warnings, optionally only some codes, a source, or severities.",
+		Request: WarningsClearRequest{}, Response: WarningsClearResponse{}, Errors: []int{400, 405}},
+	{Method: http.MethodGet, Path: "/routes", Summary: "Recorded routes verified against the host routing table.",
+		Response: RoutesResponse{}, Errors: []int{405}},
+	{Method: http.MethodGet, Path: "/interfaces", Summary: "Host network interfaces with flags, MTU, addresses, and default routes.",
+		Response: InterfacesResponse{}, Errors: []int{405, 500}},
+	{Method: http.MethodGet, Path: "/operations", Summary: "Recent operations (start, stop, pause, resume) with step progress, newest first.",
+		Response: OperationsResponse{}, Errors: []int{405}},
+	{Method: http.MethodGet, Path: "/operations/{id}", Summary: "Step-by-step progress and result of one operation.",
+		Params:   []apiParam{{Name: "id", Type: "string", Description: "Operation ID from X-Operation-ID or an async 202."}},
+		Response: OperationView{}, Errors: []int{404, 405}},
+	{Method: http.MethodPost, Path: "/shutdown", Summary: "Ask the agent to exit cleanly (admin scope).",
+		Request: ShutdownRequest{}, Response: ShutdownResponse{}, Status: http.StatusAccepted, Errors: []int{400, 403, 405, 409, 503}},
+	{Method: http.MethodPost, Path: "/upgrade", Summary: "Hand the listeners to the agent executable started anew and exit (admin scope).",
+		Request: UpgradeRequest{}, Response: UpgradeResponse{}, Status: http.StatusAccepted, Errors: []int{400, 403, 405, 409, 500, 503}},
+	{Method: http.MethodGet, Path: "/shutdown-report", Summary: "Report written when the agent last exited.",
+		Response: ShutdownReport{}, Errors: []int{404, 405}},
+	{Method: http.MethodGet, Path: "/timeline", Summary: "Significant health events over the last N hours.",
+		Query: []apiParam{
+			{Name: "hours", Type: "integer", Description: "Window size in hours (1-168, default 24)."},
+			paramTZ,
+		},
+		Response: TimelineResponse{}, Errors: []int{400, 405}},
+	{Method: http.MethodGet, Path: "/events/history", Summary: "Event log entries after a given ID.",
+		Query: []apiParam{
+			{Name: "after_id", Type: "integer", Description: "Return events with ID greater than this (default 0)."},
+			{Name: "limit", Type: "integer", Description: "Page size (1-1000, default 100)."},
+		},
+		Response: EventsHistoryResponse{}, Errors: []int{400, 405}},
+	{Method: http.MethodGet, Path: "/events/stream", Summary: "Server-Sent Events stream of events and flow records (StreamMessage data), filtered per client.",
+		Query: streamParams, Response: StreamMessage{}, Errors: []int{400, 405, 503}},
+	{Method: http.MethodGet, Path: "/recovery", Summary: "List artifacts orphaned by a previous run.",
+		Response: RecoveryResponse{}, Errors: []int{405, 503}},
+	{Method: http.MethodPost, Path: "/recovery/cleanup", Summary: "Clean up orphaned artifacts.",
+		Response: RecoveryCleanupResponse{}, Errors: []int{405, 503}},
+	{Method: http.MethodGet, Path: "/debug/contention", Summary: "Sample mutex and block contention (admin scope).",
+		Query: []apiParam{
+			{Name: "seconds", Type: "integer", Description: "Sample window in seconds (1-5, default 2)."},
+			{Name: "top", Type: "integer", Description: "Sites returned per profile (1-50, default 10)."},
+		},
+		Response: ContentionResponse{}, Errors: []int{400, 403, 405, 409}},
+	{Method: http.MethodGet, Path: "/tun2socks/logs", Summary: "Captured tun2socks stdout and stderr, oldest line first.",
+		Query: []apiParam{
+			{Name: "tail", Type: "integer", Description: "Lines returned (1 to the buffer capacity, default 200)."},
+			{Name: "errors", Type: "boolean", Description: "Only lines classified as errors."},
+		},
+		Response: Tun2SocksLogsResponse{}, Errors: []int{400, 405, 503}},
+	{Method: http.MethodGet, Path: "/connections", Summary: "Flows the tun2socks engine is relaying, as of its last report.",
+		Query: []apiParam{
+			{Name: "after_id", Type: "integer", Description: "Return connections with ID greater than this (default 0)."},
+			{Name: "limit", Type: "integer", Description: "Page size (1-1000, default 100)."},
+		},
+		Response: ConnectionsResponse{}, Errors: []int{400, 405}},
+	{Method: http.MethodPost, Path: "/diagnostics", Summary: "Collect a support bundle (tar.gz, or written to path; admin scope).",
+		Request: DiagnosticsRequest{}, Response: DiagnosticsResponse{}, Errors: []int{400, 403, 405, 409, 500}},
+	{Method: http.MethodGet, Path: "/debug/runtime", Summary: "Goroutine, heap, and GC statistics (admin scope).",
+		Response: RuntimeResponse{}, Errors: []int{403, 405}},
+	{Method: http.MethodPost, Path: "/healthcheck/full", Summary: "Run every configured probe and host check within a budget.",
+		Query: []apiParam{paramTZ}, Request: HealthcheckRequest{}, Response: HealthReportResponse{}, Errors: []int{400, 405, 409}},
+	{Method: http.MethodGet, Path: "/rules", Summary: "Domain split-tunnel rules and the host routes learned from DNS.",
+		Response: RulesResponse{}, Errors: []int{405, 503}},
+	{Method: http.MethodPut, Path: "/rules", Summary: "Replace the domain split-tunnel rules.",
+		Request: RulesRequest{}, Response: RulesResponse{}, Errors: []int{400, 405, 500, 503}},
+	{Method: http.MethodGet, Path: "/clients", Summary: "API clients seen recently (user agent, identity, subscriptions).",
+		Query: []apiParam{paramTZ}, Response: ClientsResponse{}, Errors: []int{400, 405}},
+	{Method: http.MethodGet, Path: "/tokens", Summary: "Minted API tokens (metadata only).",
+		Response: TokensResponse{}, Errors: []int{403, 405, 503}},
+	{Method: http.MethodPost, Path: "/tokens", Summary: "Mint a named API token; the secret is returned once.",
+		Request: TokenRequest{}, Response: MintTokenResponse{}, Status: http.StatusCreated, Errors: []int{400, 403, 405, 409, 500, 503}},
+	{Method: http.MethodDelete, Path: "/tokens", Summary: "Revoke a minted API token.",
+		Query:    []apiParam{{Name: "name", Type: "string", Description: "Token to revoke (required)."}},
+		Response: TokenView{}, Errors: []int{403, 404, 405, 500, 503}},
+	{Method: http.MethodGet, Path: "/profiles", Summary: "Named upstream profiles for /v1/start; ?name= returns one ProfileView.",
+		Query:    []apiParam{{Name: "name", Type: "string", Description: "Return only this profile."}},
+		Response: ProfilesResponse{}, Errors: []int{404, 405, 503}},
+	{Method: http.MethodPost, Path: "/profiles", Summary: "Create a profile.",
+		Request: ProfileRequest{}, Response: ProfileView{}, Status: http.StatusCreated, Errors: []int{400, 403, 405, 409, 500, 503}},
+	{Method: http.MethodPut, Path: "/profiles", Summary: "Create or replace a profile (201 when new).",
+		Request: ProfileRequest{}, Response: ProfileView{}, Errors: []int{400, 403, 405, 500, 503}},
+	{Method: http.MethodDelete, Path: "/profiles", Summary: "Delete a profile.",
+		Query:    []apiParam{{Name: "name", Type: "string", Description: "Profile to delete (required)."}},
+		Response: ProfileView{}, Errors: []int{403, 404, 405, 500, 503}},
+	{Method: http.MethodGet, Path: "/uplinks", Summary: "Active uplink for the upstream connection and its failover standbys.",
+		Response: UplinksResponse{}, Errors: []int{405, 503}},
+	{Method: http.MethodGet, Path: "/network/captive", Summary: "Whether a captive portal intercepts the uplink, from direct checks outside the tunnel.",
+		Query:    []apiParam{{Name: "refresh", Type: "boolean", Description: "Check now instead of reporting the latest check."}},
+		Response: CaptiveResponse{}, Errors: []int{405, 503}},
+	{Method: http.MethodGet, Path: "/egress", Summary: "Public address traffic exits from, through the tunnel and directly.",
+		Query:    []apiParam{{Name: "refresh", Type: "boolean", Description: "Look up again instead of reusing answers younger than the TTL."}},
+		Response: EgressResponse{}, Errors: []int{405, 503}},
+	{Method: http.MethodPost, Path: "/leaktest/dns", Summary: "Look up tagged names through the system resolver and report whether DNS escapes the tunnel.",
+		Request: LeakTestRequest{}, Response: LeakTestResponse{}, Errors: []int{400, 403, 405}},
+	{Method: http.MethodGet, Path: "/firewall", Summary: "Rule sets the agent installed into the host firewall, in native form.",
+		Response: FirewallResponse{}, Errors: []int{405, 503}},
+	{Method: http.MethodGet, Path: "/version", Summary: "Build version and the platform features available on this host.",
+		Response: VersionResponse{}, Errors: []int{405}},
+	{Method: http.MethodGet, Path: "/service", Summary: "The agent's launchd daemon: installed, loaded, and whether this agent runs under it.",
+		Response: ServiceResponse{}, Errors: []int{405, 500, 503}},
+	{Method: http.MethodPost, Path: "/service/install", Summary: "Write the launchd plist for this agent and load it (admin scope).",
+		Request: ServiceInstallRequest{}, Response: ServiceResponse{}, Errors: []int{400, 403, 405, 500, 503}},
+	{Method: http.MethodPost, Path: "/service/uninstall", Summary: "Unload the launchd daemon and remove its plist (admin scope).",
+		Response: ServiceResponse{}, Errors: []int{403, 405, 500, 503}},
+	{Method: http.MethodGet, Path: "/dns/upstreams", Summary: "DNS forwarder upstreams in fallback order with health stats.",
+		Response: DNSUpstreamsResponse{}, Errors: []int{405, 503}},
+	{Method: http.MethodGet, Path: "/statemachine", Summary: "Lifecycle states, allowed transitions, and the current state.",
+		Query:    []apiParam{{Name: "format", Type: "string", Description: "json (default) or dot for a Graphviz digraph."}},
+		Response: StateMachineResponse{}, Errors: []int{400, 405}},
+	{Method: http.MethodGet, Path: "/openapi.json", Summary: "This OpenAPI document.",
+		Response: map[string]any{}, Errors: []int{405}},
+}
+
+// handleOpenAPI serves the generated OpenAPI document.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.openapi)
+}
+
+// BuildOpenAPI assembles the OpenAPI 3.0 document as a JSON-ready map.
+func BuildOpenAPI() map[string]any {
+	sb := &schemaBuilder{schemas: map[string]any{}}
+	errRef := sb.ref(reflect.TypeOf(APIError{}))
+
+	paths := map[string]any{}
+	for _, op := range apiOperations {
+		path := "/" + APIVersion + op.Path
+		item, _ := paths[path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[path] = item
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		okResp := map[string]any{"description": http.StatusText(status)}
+		if op.Response != nil {
+			okResp["content"] = jsonContent(sb.ref(reflect.TypeOf(op.Response)))
+		}
+		responses := map[string]any{strconv.Itoa(status): okResp}
+		for _, code := range op.Errors {
+			responses[strconv.Itoa(code)] = map[string]any{
+				"description": http.StatusText(code),
+				"content":     jsonContent(errRef),
+			}
+		}
+
+		o := map[string]any{
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"responses":   responses,
+		}
+		if len(op.Params)+len(op.Query) > 0 {
+			params := make([]any, 0, len(op.Params)+len(op.Query))
+			for _, p := range op.Params {
+				params = append(params, map[string]any{
+					"name":        p.Name,
+					"in":          "path",
+					"required":    true,
+					"description": p.Description,
+					"schema":      map[string]any{"type": p.Type},
+				})
+			}
+			for _, p := range op.Query {
+				params = append(params, map[string]any{
+					"name":        p.Name,
+					"in":          "query",
+					"required":    false,
+					"description": p.Description,
+					"schema":      map[string]any{"type": p.Type},
+				})
+			}
+			o["parameters"] = params
+		}
+		if op.Request != nil {
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent(sb.ref(reflect.TypeOf(op.Request))),
+			}
+		}
+		item[strings.ToLower(op.Method)] = o
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "simple-packet-logger agent API",
+			"version": APIVersion,
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": sb.schemas},
+	}
+}
+
+func jsonContent(schema any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// operationID derives a stable identifier such as "getStatus" or "postProbe".
+func operationID(op apiOperation) string {
+	name := strings.NewReplacer("/", " ", ".", " ", "-", " ", "_", " ", "{", " ", "}", " ").Replace(op.Path)
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, w := range strings.Fields(name) {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
+
+// schemaBuilder converts Go types into OpenAPI schemas, registering named
+// structs under components/schemas and returning $ref objects for them.
+type schemaBuilder struct {
+	schemas map[string]any
+}
+
+func (b *schemaBuilder) ref(t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		name := t.Name()
+		if _, ok := b.schemas[name]; !ok {
+			b.schemas[name] = nil // reserve to break cycles
+			b.schemas[name] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.ref(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.ref(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = b.ref(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+	obj := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
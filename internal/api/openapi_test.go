@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// TestOpenAPICoversRoutes checks that apiOperations and the routes
+// NewServer registers name the same paths.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	s := NewServer(core.NewState(), ServerOptions{})
+
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		documented["/"+APIVersion+op.Path] = true
+	}
+	for route := range s.budgets {
+		if !documented[route] {
+			t.Errorf("route %s has no apiOperations entry", route)
+		}
+	}
+	for path := range documented {
+		if _, ok := s.budgets[path]; !ok {
+			t.Errorf("apiOperations documents %s, which NewServer does not register", path)
+		}
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func TestOpenAPIOperations(t *testing.T) {
+	seen := map[string]bool{}
+	for _, op := range apiOperations {
+		key := op.Method + " " + op.Path
+		if seen[key] {
+			t.Errorf("%s: listed twice", key)
+		}
+		seen[key] = true
+		if op.Summary == "" {
+			t.Errorf("%s: empty summary", key)
+		}
+
+		var names []string
+		for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+			names = append(names, m[1])
+		}
+		if len(names) != len(op.Params) {
+			t.Errorf("%s: %d path segments, %d params", key, len(names), len(op.Params))
+			continue
+		}
+		for i, p := range op.Params {
+			if p.Name != names[i] {
+				t.Errorf("%s: param %d is %q, path names %q", key, i, p.Name, names[i])
+			}
+		}
+	}
+}
+
+// TestOpenAPIRefs checks that the built document is valid JSON, that
+// operation IDs are unique, and that every $ref names a schema in
+// components.
+func TestOpenAPIRefs(t *testing.T) {
+	b, err := json.Marshal(BuildOpenAPI())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var doc struct {
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	ids := map[string]string{}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			id, _ := op["operationId"].(string)
+			if id == "" {
+				t.Errorf("%s %s: no operationId", method, path)
+			} else if prev, dup := ids[id]; dup {
+				t.Errorf("%s %s: operationId %q also used by %s", method, path, id, prev)
+			}
+			ids[id] = method + " " + path
+		}
+	}
+
+	var refs int
+	var walk func(where string, v any)
+	walk = func(where string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				if k != "$ref" {
+					walk(where+"/"+k, child)
+					continue
+				}
+				refs++
+				ref, _ := child.(string)
+				name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+				if !ok {
+					t.Errorf("%s: $ref %q outside components/schemas", where, ref)
+				} else if _, ok := doc.Components.Schemas[name]; !ok {
+					t.Errorf("%s: $ref %q names no schema", where, ref)
+				}
+			}
+		case []any:
+			for _, child := range v {
+				walk(where, child)
+			}
+		}
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatal(err)
+	}
+	walk("", raw)
+	if refs == 0 {
+		t.Error("document has no $refs")
+	}
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/logging"
+)
+
+// Orchestration kinds held by the single-flight guard. An upgrade holds
+// it without steps and is not recorded in core.State.Operations.
+const (
+	opStart   = "start"
+	opStop    = "stop"
+	opPause   = "pause"
+	opResume  = "resume"
+	opUpgrade = "upgrade"
+)
+
+// OperationHeader carries the operation ID on operation responses, so a
+// caller can match it against the ID in a later 409.
+const OperationHeader = "X-Operation-ID"
+
+// errNotImplemented is returned by orchestration steps that have not landed.
+var errNotImplemented = errors.New("not implemented yet")
+
+// stepCodes maps each orchestration step to the error code of its failure.
+var stepCodes = map[string]string{
+	core.StepTUNCreated:       CodeTUNCreate,
+	core.StepRoutesApplied:    CodeRouteApply,
+	core.StepTun2SocksStarted: CodeTun2SocksStart,
+	core.StepVerified:         CodeVerify,
+	core.StepTun2SocksStopped: CodeTun2SocksStop,
+	core.StepRoutesRestored:   CodeRouteRestore,
+	core.StepTUNRemoved:       CodeTUNRemove,
+	core.StepRoutesReverted:   CodeRouteRestore,
+	core.StepRoutesReapplied:  CodeRouteApply,
+}
+
+// stepError is the failure of one orchestration step.
+type stepError struct {
+	Kind string
+	Step string
+	Err  error
+}
+
+func (e *stepError) Error() string { return e.Kind + ": " + e.Step + ": " + e.Err.Error() }
+func (e *stepError) Unwrap() error { return e.Err }
+
+// operation is a start, stop, pause, or resume holding the orchestration
+// guard. Its progress is tracked in core.State.Operations under the same
+// ID.
+type operation struct {
+	ID        string
+	Kind      string
+	Client    string
+	RequestID string
+	StartedAt time.Time
+}
+
+// beginOperation takes the orchestration guard for kind. If another
+// operation holds it, that operation is returned instead and nothing is
+// taken. Operations change routes and the TUN, so overlapping ones are
+// rejected rather than queued: the caller decides whether to retry.
+func (s *Server) beginOperation(r *http.Request, kind string, async bool) (*operation, *operation) {
+	s.opMu.Lock()
+	defer s.opMu.Unlock()
+	if s.op != nil {
+		cur := *s.op
+		return nil, &cur
+	}
+	s.op = &operation{
+		ID:        newOperationID(),
+		Kind:      kind,
+		Client:    clientID(r.Context()),
+		RequestID: logging.RequestID(r.Context()),
+		StartedAt: TimeNow(),
+	}
+	steps := core.StartSteps
+	switch kind {
+	case opStop:
+		steps = core.StopSteps
+	case opPause:
+		steps = core.PauseSteps
+	case opResume:
+		steps = core.ResumeSteps
+	}
+	s.state.Operations().Begin(s.op.ID, kind, s.op.Client, async, steps, s.op.StartedAt)
+	s.logger.DebugContext(r.Context(), "operation started", "id", s.op.ID, "kind", kind, "client", s.op.Client, "async", async)
+	return s.op, nil
+}
+
+// runOperation runs op's steps in order, recording progress, and stops at
+// the first failure. A stop tears down the firewall rule sets afterwards,
+// whatever the outcome.
+func (s *Server) runOperation(ctx context.Context, op *operation) error {
+	if op.Kind == opStop {
+		// Whatever the steps did, no firewall rule outlives a stop.
+		defer s.teardownFirewall()
+	}
+	ops := s.state.Operations()
+	rec, _ := ops.Get(op.ID)
+	for _, st := range rec.Steps {
+		ops.StepStarted(op.ID, st.Name, TimeNow())
+		err := s.runStep(ctx, st.Name)
+		ops.StepFinished(op.ID, st.Name, TimeNow(), err)
+		if err != nil {
+			return &stepError{Kind: op.Kind, Step: st.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// runStep performs one orchestration step; step names are unique across
+// operation kinds.
+func (s *Server) runStep(ctx context.Context, step string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// orchestration todo; tun_created and tun_removed go through package
+	// tundev (Linux; the engine opens utun on macOS) or the privileged
+	// helper (package privhelper) when configured, routes_applied and
+	// routes_reapplied hand the plan to opts.RouteRepair and the proxy
+	// endpoint to opts.ProxyPin, the teardown steps clear both
+	return errNotImplemented
+}
+
+// teardownFirewall removes every firewall rule set the agent installed,
+// recording a warning event when one stays.
+func (s *Server) teardownFirewall() {
+	if s.opts.Firewall == nil {
+		return
+	}
+	removed, err := s.opts.Firewall.Teardown()
+	if err != nil {
+		s.state.RecordEvent(core.EventWarning, "firewall teardown after stop failed: "+err.Error(), map[string]string{"kind": "firewall", "removed": strings.Join(removed, ",")})
+		return
+	}
+	if len(removed) > 0 {
+		s.logger.Info("firewall rules torn down after stop", "sets", removed)
+	}
+}
+
+// endOperation records op's result and releases the guard.
+func (s *Server) endOperation(op *operation, err error) {
+	s.state.Operations().Finish(op.ID, TimeNow(), s.state.GetSnapshot().AgentState, err)
+	s.opMu.Lock()
+	defer s.opMu.Unlock()
+	if s.op == op {
+		s.op = nil
+		s.logger.Debug("operation finished", "id", op.ID, "request_id", op.RequestID, "kind", op.Kind, "elapsed", time.Since(op.StartedAt), "err", err)
+	}
+}
+
+// runAsync answers 202 and runs op in the background. The operation is
+// canceled when the server stops rather than when the request ends.
+func (s *Server) runAsync(w http.ResponseWriter, op *operation) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer cancel()
+		go func() {
+			select {
+			case <-s.shutdown:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		s.endOperation(op, s.runOperation(ctx, op))
+	}()
+	writeJSON(w, http.StatusAccepted, OperationAccepted{
+		OperationID: op.ID,
+		Kind:        op.Kind,
+		StatusURL:   "/" + APIVersion + "/operations/" + op.ID,
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	})
+}
+
+// operationResult maps a synchronous operation error to an HTTP status, an
+// error code, and the response's error text.
+func operationResult(err error) (int, string, string) {
+	var se *stepError
+	switch {
+	case err == nil:
+		return http.StatusOK, "", ""
+	case errors.Is(err, errNotImplemented):
+		return http.StatusNotImplemented, CodeNotImplemented, err.Error()
+	case errors.Is(err, core.ErrInvalidTransition):
+		return http.StatusConflict, CodeStateTransition, err.Error()
+	case errors.As(err, &se) && stepCodes[se.Step] != "":
+		return http.StatusInternalServerError, stepCodes[se.Step], err.Error()
+	}
+	return http.StatusInternalServerError, CodeInternal, err.Error()
+}
+
+// operationSteps returns op's steps as recorded, in execution order.
+func (s *Server) operationSteps(op *operation) []OperationStepView {
+	rec, _ := s.state.Operations().Get(op.ID)
+	return FromOperation(rec).Steps
+}
+
+// writeOperationConflict answers 409 naming the operation in progress.
+func (s *Server) writeOperationConflict(w http.ResponseWriter, cur *operation) {
+	w.Header().Set(OperationHeader, cur.ID)
+	rec, _ := s.state.Operations().Get(cur.ID)
+	writeJSON(w, http.StatusConflict, OperationConflict{
+		Error:     "a " + cur.Kind + " operation is already in progress (" + cur.ID + ")",
+		Code:      CodeOperationBusy,
+		Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		Operation: FromOperation(rec),
+	})
+}
+
+// newOperationID returns a random "op_" ID.
+func newOperationID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return "op_" + hex.EncodeToString(b[:])
+}
+
+// handleOperation reports one start, stop, pause, or resume by ID.
+// Method: GET
+// Response (200): OperationView JSON
+// Errors:
+//   - 404 for unknown or expired IDs
+func (s *Server) handleOperation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	id := r.PathValue("id")
+	rec, ok := s.state.Operations().Get(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, APIError{
+			Error:     "operation not found: " + id,
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, FromOperation(rec))
+}
+
+// handleOperations lists recent starts and stops, newest first.
+// Method: GET
+// Response (200): OperationsResponse JSON
+func (s *Server) handleOperations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	recs := s.state.Operations().List()
+	resp := OperationsResponse{
+		Operations:  make([]OperationView, 0, len(recs)),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	for _, rec := range recs {
+		resp.Operations = append(resp.Operations, FromOperation(rec))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
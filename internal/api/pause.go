@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// handlePause moves routes back to the original gateway while the TUN and
+// tun2socks keep running, so traffic bypasses the tunnel until a resume.
+// Method: POST
+// Request: PauseRequest JSON (optional)
+// Response (200): PauseResponse JSON
+// Errors:
+//   - 409 unless the agent is active or degraded (ERR_STATE_TRANSITION),
+//     or while another operation is in progress (OperationConflict)
+//   - 501 until orchestration lands
+//   - 500 and 501 carry a PauseResponse with error set and the steps so far
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.pauseOrResume(w, r, opPause)
+}
+
+// handleResume reapplies the routes a pause reverted.
+// Method: POST
+// Request: PauseRequest JSON (optional)
+// Response (200): PauseResponse JSON
+// Errors:
+//   - 409 unless the agent is paused (ERR_STATE_TRANSITION), or while
+//     another operation is in progress (OperationConflict)
+//   - 501 until orchestration lands
+//   - 500 and 501 carry a PauseResponse with error set and the steps so far
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.pauseOrResume(w, r, opResume)
+}
+
+// pauseOrResume runs a pause or resume operation for kind.
+func (s *Server) pauseOrResume(w http.ResponseWriter, r *http.Request, kind string) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req PauseRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// Pausing needs a tunnel to bypass and resuming a paused one; checked
+	// here so a wrong call fails before taking the orchestration guard.
+	cur := s.state.GetSnapshot().AgentState
+	ok := cur == core.StateActive || cur == core.StateDegraded
+	if kind == opResume {
+		ok = cur == core.StatePaused
+	}
+	if !ok {
+		writeJSON(w, http.StatusConflict, APIError{
+			Error:     "cannot " + kind + " while the agent is " + string(cur),
+			Code:      CodeStateTransition,
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	op, busy := s.beginOperation(r, kind, req.Async)
+	if busy != nil {
+		s.writeOperationConflict(w, busy)
+		return
+	}
+	w.Header().Set(OperationHeader, op.ID)
+	if req.Async {
+		s.runAsync(w, op)
+		return
+	}
+	err := s.runOperation(r.Context(), op)
+	s.endOperation(op, err)
+	code, errCode, errText := operationResult(err)
+	status := FromCoreSnapshot(s.state.GetSnapshot())
+	writeJSON(w, code, PauseResponse{
+		OperationID: op.ID,
+		State:       status.State,
+		Warnings:    status.Warnings,
+		Steps:       s.operationSteps(op),
+		Error:       errText,
+		Code:        errCode,
+		GeneratedAt: status.GeneratedAt,
+	})
+}
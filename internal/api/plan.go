@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/sanverite/simple-packet-logger/internal/bypass"
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/discovery"
+	"github.com/sanverite/simple-packet-logger/internal/dnsproxy"
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+	"github.com/sanverite/simple-packet-logger/internal/tun2socks"
+)
+
+// splitCIDRs holds the validated split tunnel lists of a start request.
+type splitCIDRs struct {
+	include []netip.Prefix
+	exclude []netip.Prefix
+}
+
+// prefixStrings renders prefixes for views (never nil).
+func prefixStrings(in []netip.Prefix) []string {
+	out := make([]string, 0, len(in))
+	for _, p := range in {
+		out = append(out, p.String())
+	}
+	return out
+}
+
+// checkTUNSubnet refuses a TUN subnet that overlaps a live network: a
+// detected LAN or an address on any interface other than the agent's own
+// TUN. An unreadable interface list is returned as a warning.
+func (s *Server) checkTUNSubnet(subnet netip.Prefix, lan []discovery.LANNet) (warning string, err error) {
+	var live []routeplan.LANPrefix
+	for _, n := range lan {
+		if n.Interface != s.opts.TUNName {
+			live = append(live, routeplan.LANPrefix{Prefix: n.Prefix, Interface: n.Interface})
+		}
+	}
+	ifaces, ierr := s.opts.Interfaces()
+	if ierr != nil {
+		warning = "tun subnet check: interfaces: " + ierr.Error()
+	}
+	for _, ifc := range ifaces {
+		if ifc.Name == s.opts.TUNName {
+			continue
+		}
+		for _, p := range ifc.Prefixes {
+			live = append(live, routeplan.LANPrefix{Prefix: p, Interface: ifc.Name})
+		}
+	}
+	return warning, routeplan.CheckTUNSubnet(subnet, live)
+}
+
+// planRoutes gathers the environment for req and builds a route plan.
+// uplinks is the outbound interface preference (empty: system default).
+// Problems that prevent planning are returned as warnings with a nil plan.
+func (s *Server) planRoutes(ctx context.Context, req StartRequest, snap core.Snapshot,
+	entries []bypass.Entry, lan []discovery.LANNet, split splitCIDRs, uplinks []string) (*PlanView, []string) {
+	var warnings []string
+
+	in := routeplan.Input{
+		TUNName:   s.opts.TUNName,
+		MTU:       req.MTU,
+		Bypass:    bypass.Prefixes(entries),
+		IPv6:      req.IPv6,
+		ProxyIPv6: snap.LastProbe.Features.IPv6,
+		Include:   split.include,
+		Exclude:   split.exclude,
+	}
+	for _, n := range lan {
+		in.LAN = append(in.LAN, routeplan.LANPrefix{Prefix: n.Prefix, Interface: n.Interface})
+	}
+	if req.TUNAddress != "" {
+		if t, err := routeplan.ParseTUNAddr(req.TUNAddress, req.TUNPeer); err == nil {
+			in.TUN4 = t
+		}
+	}
+
+	// A WireGuard upstream's endpoint is pinned like a proxy, and the TUN
+	// takes the address the peer assigned.
+	upstream := req.SocksServer
+	if req.WireGuard != nil {
+		upstream = req.WireGuard.Endpoint
+		if wg, err := req.WireGuard.config(); err == nil {
+			in.Local4 = wg.Address
+		}
+		if in.IPv6 {
+			in.IPv6 = false
+			warnings = append(warnings, "ipv6 is not routed through wireguard upstreams")
+		}
+	}
+	host, _, err := net.SplitHostPort(upstream)
+	if err != nil {
+		return nil, append(warnings, "plan: upstream: "+err.Error())
+	}
+	if a, err := netip.ParseAddr(host); err == nil {
+		in.Proxy = []netip.Addr{a.WithZone("")}
+	} else if addrs, err := s.opts.Resolver.LookupNetIP(ctx, "ip", host); err == nil {
+		in.Proxy = addrs
+	} else {
+		warnings = append(warnings, "plan: resolve proxy host: "+err.Error())
+	}
+
+	gw4, err := s.opts.DefaultRoute()
+	if err != nil {
+		return nil, append(warnings, "plan: ipv4 default route: "+err.Error())
+	}
+	in.Gateway4 = routeplan.Gateway{Addr: gw4.Gateway, Interface: gw4.Interface}
+	if req.IPv6 {
+		gw6, err := s.opts.DefaultRoute6()
+		switch {
+		case errors.Is(err, netinfo.ErrNoDefaultRoute):
+		case err != nil:
+			warnings = append(warnings, "plan: ipv6 default route: "+err.Error())
+		default:
+			in.Gateway6 = routeplan.Gateway{Addr: gw6.Gateway, Interface: gw6.Interface}
+		}
+	}
+
+	preferred := false
+	if len(uplinks) > 0 {
+		var w []string
+		in.Uplink4, in.Uplink6, preferred, w = s.chooseUplinks(uplinks, in.Gateway4, req.IPv6)
+		warnings = append(warnings, w...)
+	}
+
+	plan, err := routeplan.Build(in)
+	if err != nil {
+		return nil, append(warnings, "plan: "+err.Error())
+	}
+	view := FromPlan(plan)
+	view.Uplink.Preferred = preferred
+	if f := s.opts.DNS; f != nil && !req.DisableDNS {
+		view.DNS = &PlanDNSView{
+			Listen:           f.ListenAddr(plan.TUN.Local4).String(),
+			Upstream:         f.Status().Upstream,
+			RewriteResolvers: f.RewritesResolvers(),
+		}
+		if ups, err := dnsproxy.ParseUpstreams(req.DNSUpstreams); err == nil && len(req.DNSUpstreams) > 0 {
+			view.DNS.Upstream = ups[0].String()
+		}
+	}
+	if e, _ := s.startEngine(req); e != nil {
+		view.Tun2Socks = &PlanTun2SocksView{Engine: e.Name()}
+		if c, ok := e.(tun2socks.Commander); ok {
+			// The proxy host route already pins the uplink, so the
+			// engine is not bound to an interface.
+			cfg := tun2socks.Config{
+				Device:    plan.TUN.Name,
+				MTU:       plan.TUN.MTU,
+				Local:     plan.TUN.Local4,
+				Peer:      plan.TUN.Peer4,
+				Proxy:     req.SocksServer,
+				ProxyType: req.ProxyType,
+				UDP:       req.UDP,
+				Chain:     engineHops(req.Chain),
+			}
+			if req.Auth != nil {
+				cfg.Username, cfg.Password = req.Auth.Username, req.Auth.Password
+			}
+			cmd, err := c.Command(cfg)
+			if err != nil {
+				warnings = append(warnings, "plan: "+err.Error())
+			}
+			view.Tun2Socks.Command = cmd
+		}
+	}
+	return view, append(warnings, plan.Warnings...)
+}
+
+// chooseMTU picks the TUN MTU for req (see routeplan.ChooseMTU). The path
+// MTU comes from the last probe; the interface is the first of uplinks
+// with a default route, else the system default's.
+func (s *Server) chooseMTU(req StartRequest, uplinks []string) routeplan.MTUChoice {
+	in := routeplan.MTUInput{
+		Requested: req.MTU,
+		PathMTU:   s.state.GetSnapshot().LastProbe.Features.PathMTU,
+		Overhead:  routeplan.OverheadSOCKS,
+	}
+	if req.WireGuard != nil {
+		// The probe measured the SOCKS path, not the WireGuard peer.
+		in.PathMTU, in.Overhead = 0, routeplan.OverheadWireGuard
+	}
+	if rt, err := s.opts.DefaultRoute(); err == nil {
+		in.Interface = rt.Interface
+	}
+	if len(uplinks) > 0 {
+		if routes, err := s.opts.DefaultRoutes(); err == nil {
+			if g, _, ok := routeplan.ChooseUplink(uplinks, gateways(routes)); ok {
+				in.Interface = g.Interface
+			}
+		}
+	}
+	if ifaces, err := s.opts.Interfaces(); err == nil && in.Interface != "" {
+		for _, ifc := range ifaces {
+			if ifc.Name == in.Interface {
+				in.IfaceMTU = ifc.MTU
+			}
+		}
+	}
+	return routeplan.ChooseMTU(in)
+}
+
+// chooseUplinks picks the IPv4 (and, with ipv6, IPv6) egress for pinned
+// routes from the preference list. Falling back past the first choice, or
+// to the system default gw4, is reported as a warning. The IPv6 uplink
+// follows the chosen interface when it has an IPv6 default route.
+func (s *Server) chooseUplinks(prefs []string, gw4 routeplan.Gateway, ipv6 bool) (up4, up6 routeplan.Gateway, preferred bool, warnings []string) {
+	routes, err := s.opts.DefaultRoutes()
+	if err != nil {
+		return up4, up6, false, []string{"plan: outbound interfaces: " + err.Error()}
+	}
+	up4, idx, ok := routeplan.ChooseUplink(prefs, gateways(routes))
+	switch {
+	case !ok:
+		return routeplan.Gateway{}, up6, false, []string{fmt.Sprintf(
+			"plan: no outbound interface in %v has a default route; using system default via %s",
+			prefs, gw4.Interface)}
+	case idx > 0:
+		warnings = append(warnings, fmt.Sprintf(
+			"plan: outbound interface %s has no default route; using %s", prefs[0], up4.Interface))
+	}
+	if ipv6 {
+		routes6, err := s.opts.DefaultRoutes6()
+		if err != nil && !errors.Is(err, netinfo.ErrNoDefaultRoute) {
+			warnings = append(warnings, "plan: outbound interfaces: ipv6: "+err.Error())
+		}
+		up6, _, _ = routeplan.ChooseUplink([]string{up4.Interface}, gateways(routes6))
+	}
+	return up4, up6, true, warnings
+}
+
+// gateways converts discovered default routes for uplink selection.
+func gateways(routes []netinfo.Route) []routeplan.Gateway {
+	out := make([]routeplan.Gateway, 0, len(routes))
+	for _, r := range routes {
+		out = append(out, routeplan.Gateway{Addr: r.Gateway, Interface: r.Interface})
+	}
+	return out
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+)
+
+// checkPolicy applies the probe policy to a caller without admin scope.
+// Empty addresses are skipped: they fall back to the agent's own defaults.
+func (s *Server) checkPolicy(r *http.Request, socks, target string) error {
+	if s.opts.Policy == nil || requestScope(r.Context()) == ScopeAdmin {
+		return nil
+	}
+	var err error
+	if socks != "" {
+		err = s.opts.Policy.AllowSOCKS(socks)
+	}
+	if err == nil && target != "" {
+		err = s.opts.Policy.AllowTarget(target)
+	}
+	if err != nil {
+		s.logger.WarnContext(r.Context(), "policy denied request", "client", clientID(r.Context()), "route", r.URL.Path, "err", err)
+	}
+	return err
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/logging"
+	"github.com/sanverite/simple-packet-logger/internal/probe"
+)
+
+// handleProbeTypes lists the probes accepted by POST /v1/probe "type".
+// Method: GET
+// Response (200): ProbeTypesResponse JSON, sorted by name
+func (s *Server) handleProbeTypes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, FromProbes(probe.Registered()))
+}
+
+// errProbeCanceled is the cancel cause of probes stopped with
+// DELETE /v1/probe/active.
+var errProbeCanceled = errors.New("probe canceled")
+
+// Kinds of in-flight probes.
+const (
+	probeKindProbe       = "probe"
+	probeKindHealthcheck = "healthcheck"
+)
+
+// ProbeHeader carries the ID of a probe or health sweep in its response,
+// for DELETE /v1/probe/active?id=.
+const ProbeHeader = "X-Probe-ID"
+
+// activeProbe is one probe or health sweep in flight.
+type activeProbe struct {
+	ID        string
+	Kind      string
+	Type      string
+	Target    string
+	Client    string
+	RequestID string
+	StartedAt time.Time
+	Canceled  bool
+
+	cancel context.CancelCauseFunc
+}
+
+// activeProbes tracks the probes and sweeps in flight.
+type activeProbes struct {
+	mu     sync.Mutex
+	probes map[string]*activeProbe
+}
+
+// trackProbe registers a probe or sweep for r, sets its ID header on w, and
+// returns the context to run it with and a func to call when it is done.
+func (s *Server) trackProbe(w http.ResponseWriter, r *http.Request, kind, typ, target string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(r.Context())
+	p := &activeProbe{
+		ID:        newProbeID(),
+		Kind:      kind,
+		Type:      typ,
+		Target:    target,
+		Client:    clientID(r.Context()),
+		RequestID: logging.RequestID(r.Context()),
+		StartedAt: TimeNow(),
+		cancel:    cancel,
+	}
+	s.probes.mu.Lock()
+	if s.probes.probes == nil {
+		s.probes.probes = make(map[string]*activeProbe)
+	}
+	s.probes.probes[p.ID] = p
+	s.probes.mu.Unlock()
+	w.Header().Set(ProbeHeader, p.ID)
+	return ctx, func() {
+		s.probes.mu.Lock()
+		delete(s.probes.probes, p.ID)
+		s.probes.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// activeProbeViews lists the probes in flight, oldest first.
+func (s *Server) activeProbeViews() []ActiveProbeView {
+	s.probes.mu.Lock()
+	defer s.probes.mu.Unlock()
+	ps := make([]*activeProbe, 0, len(s.probes.probes))
+	for _, p := range s.probes.probes {
+		ps = append(ps, p)
+	}
+	slices.SortFunc(ps, func(a, b *activeProbe) int {
+		return cmp.Or(a.StartedAt.Compare(b.StartedAt), cmp.Compare(a.ID, b.ID))
+	})
+	out := make([]ActiveProbeView, 0, len(ps))
+	for _, p := range ps {
+		out = append(out, fromActiveProbe(p))
+	}
+	return out
+}
+
+// cancelProbes cancels the probe with id, or every probe when id is empty,
+// and returns the ones canceled.
+func (s *Server) cancelProbes(id string) []ActiveProbeView {
+	s.probes.mu.Lock()
+	defer s.probes.mu.Unlock()
+	out := []ActiveProbeView{}
+	for _, p := range s.probes.probes {
+		if id != "" && p.ID != id {
+			continue
+		}
+		p.Canceled = true
+		p.cancel(errProbeCanceled)
+		out = append(out, fromActiveProbe(p))
+	}
+	return out
+}
+
+// fromActiveProbe maps an in-flight probe.
+func fromActiveProbe(p *activeProbe) ActiveProbeView {
+	return ActiveProbeView{
+		ID:        p.ID,
+		Kind:      p.Kind,
+		Type:      p.Type,
+		Target:    p.Target,
+		Client:    p.Client,
+		RequestID: p.RequestID,
+		StartedAt: p.StartedAt.UTC().Format(time.RFC3339Nano),
+		ElapsedMs: TimeNow().Sub(p.StartedAt).Milliseconds(),
+		Canceled:  p.Canceled,
+	}
+}
+
+// newProbeID returns a random "probe_" ID.
+func newProbeID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return "probe_" + hex.EncodeToString(b[:])
+}
+
+// handleProbeActive lists or cancels the probes and health sweeps in
+// flight.
+// Method: GET, DELETE
+// Query: id (DELETE only; optional, default every probe)
+// Response (200): ActiveProbesResponse JSON (GET), ProbeCancelResponse
+// JSON (DELETE)
+// Errors:
+//   - 404 when id names no probe in flight
+func (s *Server) handleProbeActive(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		writeJSON(w, http.StatusOK, ActiveProbesResponse{
+			Probes:      s.activeProbeViews(),
+			GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+		})
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		canceled := s.cancelProbes(id)
+		if id != "" && len(canceled) == 0 {
+			writeJSON(w, http.StatusNotFound, APIError{
+				Error:     "no probe in flight with id " + id,
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		for _, p := range canceled {
+			s.logger.InfoContext(r.Context(), "probe canceled", "id", p.ID, "kind", p.Kind, "target", p.Target, "client", p.Client)
+		}
+		writeJSON(w, http.StatusOK, ProbeCancelResponse{
+			Canceled:    canceled,
+			GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+		})
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// writeProbeCanceled answers 409 for a probe or sweep canceled with
+// DELETE /v1/probe/active.
+func writeProbeCanceled(w http.ResponseWriter, kind string) {
+	writeJSON(w, http.StatusConflict, APIError{
+		Error:     kind + " canceled",
+		Code:      CodeProbeCanceled,
+		Details:   map[string]string{"probe_id": w.Header().Get(ProbeHeader)},
+		Timestamp: TimeNow().UTC().Format(time.RFC3339),
+	})
+}
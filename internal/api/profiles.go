@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/profiles"
+)
+
+// handleProfiles lists, creates, replaces, and deletes named upstream
+// profiles for /v1/start. Changes need admin scope, like other mutating
+// routes outside the operate set.
+// Method: GET, POST, PUT, DELETE
+// Query (GET): name (optional, one profile); (DELETE): name (required)
+// Request (POST, PUT): ProfileRequest JSON
+// Response: 200 ProfilesResponse or ProfileView (GET), 201 ProfileView
+// (POST, PUT of a new name), 200 ProfileView (PUT replacing, DELETE)
+// Errors:
+//   - 400 for invalid JSON or fields (see profiles.Profile.Validate), or too
+//     many profiles
+//   - 404 when GET or DELETE names an unknown profile
+//   - 409 when POST names an existing profile
+//   - 500 when the profile list cannot be persisted
+//   - 503 when profiles are not configured
+func (s *Server) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete:
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	store := s.opts.Profiles
+	if store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "profiles not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if name == "" {
+			resp := FromProfiles(store.List())
+			resp.SelectMode = profiles.SelectOff
+			if s.opts.Location != nil {
+				st := s.opts.Location.Status()
+				loc := FromNetworkLocation(st)
+				resp.SelectMode, resp.Location = s.opts.ProfileSelect, &loc
+				if p, why, ok := store.Select(st.Location); ok {
+					resp.Selected, resp.SelectedBy = p.Name, why
+				}
+			}
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+		p, ok := store.Get(name)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, APIError{
+				Error:     "profile not found: " + name,
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		writeJSON(w, http.StatusOK, FromProfile(p))
+	case http.MethodPost, http.MethodPut:
+		s.saveProfile(w, r, store)
+	case http.MethodDelete:
+		p, err := store.Delete(name)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, profiles.ErrNotFound) {
+				code = http.StatusNotFound
+			}
+			writeJSON(w, code, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		s.recordEvent(r.Context(), core.EventOrchestration, "profile deleted", map[string]string{
+			"name": p.Name,
+		})
+		writeJSON(w, http.StatusOK, FromProfile(p))
+	}
+}
+
+// saveProfile creates (POST) or creates-or-replaces (PUT) a profile.
+func (s *Server) saveProfile(w http.ResponseWriter, r *http.Request, store *profiles.Store) {
+	var req ProfileRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	in := profiles.Profile{
+		Name:         req.Name,
+		SocksServer:  req.SocksServer,
+		AuthRef:      req.AuthRef,
+		MTU:          req.MTU,
+		BypassHosts:  req.BypassHosts,
+		DNSUpstreams: req.DNSUpstreams,
+		DisableDNS:   req.DisableDNS,
+	}
+	if m := req.Match; m != nil {
+		in.Match = &profiles.Match{SSIDs: m.SSIDs, GatewayMACs: m.GatewayMACs, SearchDomains: m.SearchDomains}
+	}
+	if err := in.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	var (
+		p       profiles.Profile
+		created = true
+		err     error
+	)
+	if r.Method == http.MethodPost {
+		p, err = store.Create(in)
+	} else {
+		p, created, err = store.Put(in)
+	}
+	if err != nil {
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, profiles.ErrExists):
+			code = http.StatusConflict
+		case errors.Is(err, profiles.ErrFull):
+			code = http.StatusBadRequest
+		}
+		writeJSON(w, code, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	s.recordEvent(r.Context(), core.EventOrchestration, "profile saved", map[string]string{
+		"name":         p.Name,
+		"socks_server": p.SocksServer,
+		"created":      strconv.FormatBool(created),
+	})
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, FromProfile(p))
+}
+
+// autoProfile returns the profile selected for the current network when
+// the selection mode applies it, and why it was selected.
+func (s *Server) autoProfile() (string, string, bool) {
+	if s.opts.Location == nil || s.opts.Profiles == nil || s.opts.ProfileSelect != profiles.SelectApply {
+		return "", "", false
+	}
+	p, why, ok := s.opts.Profiles.Select(s.opts.Location.Status().Location)
+	if !ok {
+		return "", "", false
+	}
+	return p.Name, strings.Join(why, ", "), true
+}
+
+// applyProfile fills the fields a start request leaves empty from the
+// profile it names. Credentials come from the profile only when the
+// request sends neither auth nor auth_ref, and its DNS settings only when
+// the request sets neither dns_upstreams nor disable_dns.
+func (s *Server) applyProfile(req *StartRequest) (int, error) {
+	if req.Profile == "" {
+		return http.StatusOK, nil
+	}
+	if s.opts.Profiles == nil {
+		return http.StatusServiceUnavailable, errors.New("profiles not configured")
+	}
+	p, ok := s.opts.Profiles.Get(req.Profile)
+	if !ok {
+		return http.StatusBadRequest, errors.New("profile not found: " + req.Profile)
+	}
+	if req.SocksServer == "" {
+		req.SocksServer = p.SocksServer
+	}
+	if req.Auth == nil && req.AuthRef == "" {
+		req.AuthRef = p.AuthRef
+	}
+	if req.MTU == 0 {
+		req.MTU = p.MTU
+	}
+	if len(req.BypassHosts) == 0 {
+		req.BypassHosts = p.BypassHosts
+	}
+	if len(req.DNSUpstreams) == 0 && !req.DisableDNS {
+		req.DNSUpstreams, req.DisableDNS = p.DNSUpstreams, p.DisableDNS
+	}
+	return http.StatusOK, nil
+}
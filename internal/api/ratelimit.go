@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket for one endpoint: Burst requests at once,
+// refilled at Rate per second. Rate <= 0 disables limiting.
+type RateLimit struct {
+	Rate  float64
+	Burst int // default 1
+}
+
+// DefaultRateLimits bounds the endpoints that probe the network or change
+// the host, keyed by path under /v1. The limits are per endpoint, not per
+// client, so a UI polling POST /v1/probe cannot stack concurrent SOCKS
+// probes no matter how it connects.
+func DefaultRateLimits() map[string]RateLimit {
+	return map[string]RateLimit{
+		"/probe":  {Rate: 1, Burst: 5},
+		"/start":  {Rate: 0.2, Burst: 2},
+		"/stop":   {Rate: 0.2, Burst: 2},
+		"/pause":  {Rate: 0.5, Burst: 3},
+		"/resume": {Rate: 0.5, Burst: 3},
+	}
+}
+
+// tokenBucket is a mutex-guarded token bucket.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(l RateLimit) *tokenBucket {
+	burst := float64(max(l.Burst, 1))
+	return &tokenBucket{rate: l.Rate, burst: burst, tokens: burst}
+}
+
+// take consumes a token, or reports how long until one is available.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// withRateLimit rejects requests over l with 429 and Retry-After. Only
+// mutating methods consume tokens, so method errors and reads stay cheap.
+func withRateLimit(route string, l RateLimit, next http.Handler, logger *slog.Logger) http.Handler {
+	bucket := newTokenBucket(l)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		ok, wait := bucket.take(time.Now())
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		secs := int(math.Ceil(wait.Seconds()))
+		logger.DebugContext(r.Context(), "rate limited", "route", route, "retry_after_sec", secs, "remote_addr", r.RemoteAddr)
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+		writeJSON(w, http.StatusTooManyRequests, APIError{
+			Error:     fmt.Sprintf("rate limit exceeded for %s; retry in %ds", route, secs),
+			Code:      CodeRateLimited,
+			Details:   map[string]string{"retry_after_sec": strconv.Itoa(secs)},
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// handleRecovery lists artifacts orphaned by a previous run.
+// Method: GET
+// Response (200): RecoveryResponse JSON
+// Errors:
+//   - 503 when recovery is not configured
+func (s *Server) handleRecovery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if s.opts.Recovery == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "recovery not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, FromRecoveryReport(s.opts.Recovery.Scan(r.Context())))
+}
+
+// handleRecoveryCleanup removes orphaned artifacts and resets state when
+// nothing remains.
+// Method: POST (empty body)
+// Response (200): RecoveryCleanupResponse JSON; inspect actions[].ok.
+// Errors:
+//   - 503 when recovery is not configured
+func (s *Server) handleRecoveryCleanup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if s.opts.Recovery == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "recovery not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	res := s.opts.Recovery.Cleanup(r.Context())
+	writeJSON(w, http.StatusOK, FromCleanupResult(res, s.state.GetSnapshot().AgentState))
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/storage"
+)
+
+// Route restoration outcomes recorded in ShutdownReport.RouteRestore.
+const (
+	RouteRestoreNotNeeded = "not_needed"
+	RouteRestoreOK        = "ok"
+	RouteRestoreFailed    = "failed"
+)
+
+// NewShutdownReport assembles a report from the status captured before
+// teardown and the server's drain stats. began is when shutdown started;
+// flows is the number of connections open before the engines stopped, and
+// routeErr the outcome of restoring the default route (see
+// recovery.Recoverer.RestoreDefaultRoute), which is also listed in Errors.
+func NewShutdownReport(reason string, began time.Time, last core.Snapshot, drain DrainStats, flows int, routeErr error, errs []error) ShutdownReport {
+	finished := TimeNow()
+	restore := RouteRestoreNotNeeded
+	switch {
+	case routeErr != nil:
+		restore = RouteRestoreFailed
+		errs = append(errs, routeErr)
+	case last.Routes.OriginalGateway != "":
+		restore = RouteRestoreOK
+	}
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	return ShutdownReport{
+		Reason:     reason,
+		StartedAt:  began.UTC().Format(time.RFC3339),
+		FinishedAt: finished.UTC().Format(time.RFC3339),
+		DurationMs: finished.Sub(began).Milliseconds(),
+		Drain: DrainView{
+			OpenConns:  drain.OpenConns,
+			InFlight:   drain.InFlight,
+			Drained:    drain.Drained,
+			Aborted:    drain.Aborted,
+			WSClients:  drain.WSClients,
+			WSClosed:   drain.WSClosed,
+			WSAborted:  drain.WSAborted,
+			Remaining:  drain.Remaining,
+			TimedOut:   drain.TimedOut,
+			DurationMs: drain.Duration.Milliseconds(),
+		},
+		ActiveFlows:  flows,
+		RouteRestore: restore,
+		Errors:       msgs,
+		LastStatus:   FromCoreSnapshot(last),
+	}
+}
+
+// ShutdownReportKey is the storage key holding the last shutdown report.
+const ShutdownReportKey = "last-shutdown"
+
+// SaveShutdownReport stores rep as indented JSON under ShutdownReportKey.
+func SaveShutdownReport(kv storage.KV, rep ShutdownReport) error {
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	return kv.Put(ShutdownReportKey, append(b, '\n'))
+}
+
+// LoadShutdownReport reads a report stored by SaveShutdownReport.
+// A missing report returns (nil, nil).
+func LoadShutdownReport(kv storage.KV) (*ShutdownReport, error) {
+	b, ok, err := kv.Get(ShutdownReportKey)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var rep ShutdownReport
+	if err := json.Unmarshal(b, &rep); err != nil {
+		return nil, err
+	}
+	return &rep, nil
+}
+
+// handleShutdownReport returns the report from the previous run.
+// Method: GET
+// Errors: 404 when no previous report was found at startup.
+func (s *Server) handleShutdownReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if s.opts.PreviousShutdown == nil {
+		writeJSON(w, http.StatusNotFound, APIError{
+			Error:     "no previous shutdown report",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.PreviousShutdown)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/logging"
+)
+
+// RequestIDHeader carries the request ID on every response. A client may
+// send its own (e.g. a UI action ID) to have the agent use it.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds client-supplied request IDs.
+const maxRequestIDLen = 128
+
+// withRequestID assigns each request an ID, honoring a valid incoming
+// X-Request-ID, echoes it in the response header, and stores it in the
+// request context for logs, errors, and events.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts 1-128 characters from [A-Za-z0-9._:-], so IDs
+// are safe to log and to echo in a header.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range []byte(id) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '_', c == ':', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random "req_" ID.
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return "req_" + hex.EncodeToString(b[:])
+}
+
+// recordEvent appends an event caused by the request in ctx, tagged with
+// its request ID under data key "request_id".
+func (s *Server) recordEvent(ctx context.Context, typ core.EventType, msg string, data map[string]string) {
+	if id := logging.RequestID(ctx); id != "" {
+		if data == nil {
+			data = map[string]string{}
+		}
+		data["request_id"] = id
+	}
+	s.state.RecordEvent(typ, msg, data)
+}
+
+// requestData is the event data naming the request in ctx, or nil.
+func requestData(ctx context.Context) map[string]string {
+	if id := logging.RequestID(ctx); id != "" {
+		return map[string]string{"request_id": id}
+	}
+	return nil
+}
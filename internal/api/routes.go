@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/routecheck"
+)
+
+// handleRoutes reports the recorded routes and verifies them against the
+// host's routing table, flagging drift per entry (see package routecheck).
+// Method: GET
+// Response (200): RoutesResponse JSON; verified is false where the
+// platform cannot look routes up
+func (s *Server) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	snap := s.state.GetSnapshot()
+	tun := snap.TUN.Name
+	if tun == "" {
+		tun = s.opts.TUNName
+	}
+	entries, err := routecheck.Check(r.Context(), routecheck.Input{
+		State:          snap.AgentState,
+		Routes:         snap.Routes,
+		TUN:            tun,
+		IPv6:           snap.TUN.LocalIP6 != "",
+		Lookup:         s.opts.LookupRoute,
+		DefaultRoutes:  s.opts.DefaultRoutes,
+		DefaultRoutes6: s.opts.DefaultRoutes6,
+		Resolver:       s.opts.Resolver,
+	})
+	resp := RoutesResponse{
+		State:     string(snap.AgentState),
+		Planned:   FromCoreSnapshot(snap).Routes,
+		Verified:  err == nil,
+		Drift:     routecheck.Drift(entries),
+		Entries:   FromRouteChecks(entries),
+		CheckedAt: TimeNow().UTC().Format(time.RFC3339),
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
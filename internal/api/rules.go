@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/procowner"
+	"github.com/sanverite/simple-packet-logger/internal/rules"
+)
+
+// handleRules reads or replaces the split-tunnel rules: domain patterns and
+// best-effort app bypass rules.
+// Method: GET, PUT
+// Request (PUT): RulesRequest JSON; the lists replace all rules
+// Response (200): RulesResponse JSON with the rules, learned routes, and
+// per-rule flow counters
+// Errors:
+//   - 400 for invalid JSON, an invalid or duplicate pattern or app rule,
+//     or too many rules
+//   - 500 when the rules cannot be persisted
+//   - 503 when the rules engine is not configured
+func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	eng := s.opts.Rules
+	if eng == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "rules engine not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req RulesRequest
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "invalid JSON: " + err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		patterns, err := rules.ParsePatterns(req.Rules)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		apps := make([]rules.AppRule, 0, len(req.Apps))
+		for _, a := range req.Apps {
+			apps = append(apps, rules.AppRule{Path: a.Path, User: a.User})
+		}
+		if apps, err = rules.ParseApps(apps); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		if err := eng.SetRules(patterns, apps); err != nil {
+			writeJSON(w, http.StatusInternalServerError, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		s.recordEvent(r.Context(), core.EventOrchestration, "split-tunnel rules replaced", map[string]string{
+			"count": strconv.Itoa(len(patterns)),
+			"apps":  strconv.Itoa(len(apps)),
+		})
+	}
+	writeJSON(w, http.StatusOK, FromRules(eng.Rules(), eng.Apps(), eng.Routes(), eng.Stats(), eng.Profile(), procowner.Method()))
+}
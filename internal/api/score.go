@@ -0,0 +1,47 @@
+package api
+
+import (
+	"math"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/tun2socks"
+)
+
+// healthScore scores snap with the engines' last unexpected exit and the
+// DNS forwarder's counters.
+func (s *Server) healthScore(snap core.Snapshot) core.HealthScore {
+	in := core.ScoreInputs{Now: TimeNow()}
+	for _, e := range []tun2socks.Engine{s.opts.Tun2Socks, s.opts.Embedded, s.opts.WireGuard} {
+		if e == nil {
+			continue
+		}
+		if st := e.Stats(); st.LastExit.After(in.LastRestart) {
+			in.LastRestart = st.LastExit
+		}
+	}
+	if f := s.opts.DNS; f != nil {
+		st := f.Status()
+		in.DNSQueries, in.DNSFailures = st.Queries, st.Failures
+	}
+	return core.ScoreHealth(snap, in, s.state.ScoreWeights())
+}
+
+// fromHealthScore maps a health score; nil when it is unknown.
+func fromHealthScore(h core.HealthScore) *int {
+	if !h.Known {
+		return nil
+	}
+	return &h.Score
+}
+
+// fromScoreParts maps the parts of a health score, rounded to 0.001.
+func fromScoreParts(h core.HealthScore) map[string]float64 {
+	if !h.Known {
+		return nil
+	}
+	out := make(map[string]float64, len(h.Parts))
+	for k, v := range h.Parts {
+		out[k] = math.Round(v*1000) / 1000
+	}
+	return out
+}
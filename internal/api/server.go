@@ -1,16 +1,51 @@
 package api
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/sanverite/simple-packet-logger/internal/bypass"
+	"github.com/sanverite/simple-packet-logger/internal/captive"
 	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/discovery"
+	"github.com/sanverite/simple-packet-logger/internal/dnsproxy"
+	"github.com/sanverite/simple-packet-logger/internal/egress"
+	"github.com/sanverite/simple-packet-logger/internal/firewall"
+	"github.com/sanverite/simple-packet-logger/internal/health"
+	"github.com/sanverite/simple-packet-logger/internal/ipv6leak"
+	"github.com/sanverite/simple-packet-logger/internal/launchd"
+	"github.com/sanverite/simple-packet-logger/internal/leaktest"
+	"github.com/sanverite/simple-packet-logger/internal/logging"
+	"github.com/sanverite/simple-packet-logger/internal/metrics"
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+	"github.com/sanverite/simple-packet-logger/internal/netloc"
+	"github.com/sanverite/simple-packet-logger/internal/policy"
 	"github.com/sanverite/simple-packet-logger/internal/probe"
+	"github.com/sanverite/simple-packet-logger/internal/profiles"
+	"github.com/sanverite/simple-packet-logger/internal/proxypin"
+	"github.com/sanverite/simple-packet-logger/internal/recovery"
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+	"github.com/sanverite/simple-packet-logger/internal/routerepair"
+	"github.com/sanverite/simple-packet-logger/internal/rules"
+	"github.com/sanverite/simple-packet-logger/internal/secrets"
+	"github.com/sanverite/simple-packet-logger/internal/stream"
+	"github.com/sanverite/simple-packet-logger/internal/tokens"
+	"github.com/sanverite/simple-packet-logger/internal/tun2socks"
+	"github.com/sanverite/simple-packet-logger/internal/uplink"
 )
 
 // Constants for route prefixing. Versioning is explicit to allow non-breaking additions.
@@ -22,21 +57,253 @@ const (
 // ServerOptions configures the HTTP server.
 // Timeouts are conservative defaults suitable for a local control-plane server.
 type ServerOptions struct {
-	Addr              string
+	// Addr is a single loopback TCP address with admin scope. It is used only
+	// when Listeners is empty.
+	Addr string
+	// TLSCertFile and TLSKeyFile serve Addr over HTTPS (see EnsureSelfSigned
+	// for a generated pair). Like Addr, they are used only when Listeners is
+	// empty.
+	TLSCertFile string
+	TLSKeyFile  string
+	// Listeners configures every address to serve on, each with its own
+	// scope, token, and optional TLS. See ListenerConfig.
+	Listeners []ListenerConfig
+
 	ReadTimeout       time.Duration
 	ReadHeaderTimeout time.Duration
 	WriteTimeout      time.Duration
 	IdleTimeout       time.Duration
 	ShutdownTimeout   time.Duration
-	Logger            *log.Logger
+	// Logger is the root logger; the server derives an "api" component logger
+	// from it. Nil falls back to slog.Default().
+	Logger *slog.Logger
+	// Metrics receives per-route request counters. Nil allocates a private registry.
+	Metrics *metrics.Registry
+
+	// WSInterval is the default push cadence for /v1/ws (default 1s).
+	WSInterval time.Duration
+	// WSMaxClients caps concurrent /v1/ws connections (default 8).
+	WSMaxClients int
+	// WSWriteTimeout bounds each frame write to a WebSocket client (default 5s).
+	WSWriteTimeout time.Duration
+
+	// DisplayLocation, when set, adds *_local timestamp companions to every
+	// response that supports them. Requests may override it with ?tz=.
+	DisplayLocation *time.Location
+
+	// PreviousShutdown is the report left by the last run, served at
+	// GET /v1/shutdown-report. Nil means none was found.
+	PreviousShutdown *ShutdownReport
+
+	// Recovery backs /v1/recovery. Nil makes those endpoints return 503.
+	Recovery *recovery.Recoverer
+
+	// Resolver resolves hostname bypass hosts in /v1/start (default
+	// net.DefaultResolver).
+	Resolver bypass.Resolver
+
+	// LAN configures LAN auto-detection for /v1/start (zero value: system
+	// interfaces, no gateway lookup).
+	LAN discovery.Options
+
+	// TUNName names the TUN device in route plans (default "spl0"; macOS
+	// assigns utunN at creation).
+	TUNName string
+
+	// DefaultRoute and DefaultRoute6 report the host's original default
+	// routes for planning (default netinfo.GetDefaultRoute/GetDefaultRoute6).
+	DefaultRoute  func() (netinfo.Route, error)
+	DefaultRoute6 func() (netinfo.Route, error)
+
+	// DefaultRoutes and DefaultRoutes6 list every default route, preferred
+	// first, for outbound interface selection (default
+	// netinfo.GetDefaultRoutes/GetDefaultRoutes6).
+	DefaultRoutes  func() ([]netinfo.Route, error)
+	DefaultRoutes6 func() ([]netinfo.Route, error)
+
+	// Interfaces lists the host's network interfaces for /v1/interfaces and
+	// diagnostics bundles (default netinfo.Interfaces).
+	Interfaces func() ([]netinfo.Interface, error)
+
+	// RouteRepair, when set, keeps the applied route plan in place while
+	// active: the start orchestration hands it the plan and teardown
+	// clears it (see package routerepair).
+	RouteRepair *routerepair.Repairer
+
+	// ProxyPin, when set, follows a proxy given by hostname while active:
+	// the start orchestration hands it the endpoint, its pinned addresses,
+	// and a verifying probe, and teardown clears it (see package proxypin).
+	ProxyPin *proxypin.Pinner
+
+	// Captive, when set, reports captive portals on the uplink at
+	// /v1/network/captive, and starts that would use an automatically
+	// selected profile are refused while one is detected (see package
+	// captive).
+	Captive *captive.Detector
+
+	// Egress, when set, looks up the public address at /v1/egress (see
+	// package egress).
+	Egress *egress.Resolver
+
+	// LeakObserver, when set, sees the queries the DNS forwarder relays,
+	// so POST /v1/leaktest/dns can tell tunneled lookups from escaped
+	// ones; LeakResolver looks the test names up (default the system
+	// resolver). See package leaktest.
+	LeakObserver *leaktest.Observer
+	LeakResolver *net.Resolver
+
+	// IPv6Leak, when set, is the monitor of IPv6 egress outside an
+	// IPv4-only tunnel; the health sweep reports a leak it blocks as a
+	// warning (see package ipv6leak).
+	IPv6Leak *ipv6leak.Monitor
+
+	// Firewall, when set, holds the rule sets the agent installed into
+	// the host firewall; they are listed at /v1/firewall and torn down
+	// after every stop operation (see package firewall).
+	Firewall *firewall.Firewall
+
+	// LookupRoute reports the route the host uses for a destination, to
+	// verify recorded routes in /v1/routes (default netinfo.LookupRoute).
+	LookupRoute func(netip.Addr) (netinfo.Route, error)
+
+	// OutboundInterfaces is the fallback order of physical interfaces for
+	// the proxy connection and pinned routes when a start request names
+	// none (e.g. ["en7", "en0"]). Empty keeps the system default route.
+	OutboundInterfaces []string
+
+	// HealthProbes are the configured probes run by POST /v1/healthcheck/full.
+	HealthProbes []HealthProbe
+	// Nameservers and InterfaceExists inspect the host for the health sweep
+	// (default health.SystemNameservers/SystemInterfaceExists).
+	Nameservers     func() ([]netip.Addr, error)
+	InterfaceExists func(name string) (bool, error)
+
+	// Rules backs /v1/rules (domain split tunneling). Nil makes it return 503.
+	Rules *rules.Engine
+
+	// Tokens backs /v1/tokens and lets token-protected listeners accept
+	// minted tokens. Nil makes /v1/tokens return 503.
+	Tokens *tokens.Store
+
+	// DNS is the local DNS forwarder started with the tunnel. Nil disables
+	// it; dry-run plans report it when set.
+	DNS *dnsproxy.Forwarder
+
+	// Uplinks tracks the active uplink and its standbys. When set, start
+	// plans without outbound_interfaces prefer the active link; nil makes
+	// /v1/uplinks return 503.
+	Uplinks *uplink.Monitor
+
+	// Stream fans events and flow records out to /v1/events/stream and
+	// /v1/ws?stream=events. Nil makes those return 503.
+	Stream *stream.Hub
+
+	// RateLimits maps paths under /v1 (e.g. "/probe") to token buckets.
+	// Nil uses DefaultRateLimits; a zero Rate disables a route's limit.
+	RateLimits map[string]RateLimit
+
+	// Policy restricts the SOCKS servers and connect targets that callers
+	// without admin scope may probe or start against. Nil allows any.
+	Policy *policy.Policy
+
+	// Profiles backs /v1/profiles and "profile" in start requests. Nil
+	// makes both return 503.
+	Profiles *profiles.Store
+	// Location watches the attached network for profile selection, and
+	// ProfileSelect is the selection mode (profiles.SelectSuggest or
+	// SelectApply). Nil Location turns selection off.
+	Location      *netloc.Watcher
+	ProfileSelect string
+
+	// Secrets resolves auth_ref in probe and start requests. Nil makes
+	// requests naming one fail with 503.
+	Secrets secrets.Store
+
+	// AllowedOrigins lists the browser origins ("http://localhost:5173")
+	// allowed to call the API cross-origin, e.g. a local web dashboard.
+	// Other origins get no CORS headers, and their writes are refused.
+	AllowedOrigins []string
+
+	// Tun2Socks is the engine orchestration runs (see package tun2socks);
+	// dry-run plans show its command line. Nil leaves it out of plans.
+	Tun2Socks tun2socks.Engine
+
+	// Embedded is the in-process engine start requests select with
+	// engine "embedded". Nil, as in builds without it, makes such
+	// requests fail with 400.
+	Embedded tun2socks.Engine
+
+	// WireGuard runs start requests with a WireGuard upstream. Nil makes
+	// them fail with 400.
+	WireGuard tun2socks.Engine
+
+	// Tun2SocksLogs holds the tun2socks process's captured output for
+	// /v1/tun2socks/logs and diagnostics bundles. Nil makes the endpoint
+	// return 503.
+	Tun2SocksLogs *tun2socks.LogBuffer
+
+	// Diagnostics configures the files POST /v1/diagnostics collects.
+	Diagnostics DiagnosticsOptions
+
+	// Activated are sockets bound by the service manager (see package
+	// sdactivate) or handed over by the agent this one replaces (see
+	// package handover). Start serves each in place of the listener with
+	// the same address, keeping that listener's scope, token, and TLS, and
+	// closes those that match none.
+	Activated []net.Listener
+
+	// Upgrade starts the agent's executable again with the listeners'
+	// sockets for POST /v1/upgrade, checking its SHA-256 when one is
+	// given, and returns the new pid once it serves; the caller then asks
+	// for shutdown. It must leave the listeners usable when it fails. Nil
+	// makes the endpoint return 503.
+	Upgrade func(ctx context.Context, sha256 string, listeners []net.Listener) (int, error)
+
+	// Service is the launch daemon /v1/service installs: its label and the
+	// agent's executable and arguments. An empty Label makes the endpoints
+	// return 503; off macOS they always do.
+	Service launchd.Job
+
+	// EnablePprof serves net/http/pprof at /debug/pprof/ to admin callers.
+	// Off by default: profiles expose code paths and briefly slow the agent.
+	EnablePprof bool
+
+	// RequestShutdown is called by POST /v1/shutdown to ask the process to
+	// exit; it must not block. Nil makes the endpoint return 503.
+	RequestShutdown func(reason string)
 }
 
 // Server hosts the HTTP API for the daemon.
 type Server struct {
-	http   *http.Server
-	state  *core.State
-	logger *log.Logger
-	opts   ServerOptions
+	mux     *http.ServeMux
+	state   *core.State
+	logger  *slog.Logger
+	metrics *metrics.Registry
+	opts    ServerOptions
+
+	listeners []*boundListener // populated by Start
+
+	drainMu sync.Mutex
+	drain   DrainStats // recorded by Stop
+
+	statusRevs statusHistory  // snapshots served, for /v1/status/diff
+	clients    clientRegistry // callers seen by the listeners, for /v1/clients
+
+	sweepMu sync.Mutex // held while a full health sweep runs
+	diagMu  sync.Mutex // held while a diagnostics bundle is collected
+
+	probes activeProbes // probes and sweeps in flight, for /v1/probe/active
+
+	opMu sync.Mutex
+	op   *operation // operation in progress; see beginOperation
+
+	budgets  map[string]Budget // per route; see handle
+	openapi  map[string]any    // generated once; see openapi.go
+	origins  map[string]bool   // normalized AllowedOrigins; see withCORS
+	wsSlots  chan struct{}     // semaphore bounding concurrent WebSocket clients
+	shutdown chan struct{}     // closed by Stop to end hijacked streams
+	exiting  atomic.Bool       // set once POST /v1/shutdown is accepted, or while POST /v1/upgrade hands over
+	wsClosed atomic.Int64      // streams that sent a close frame on shutdown
 }
 
 // NewServer constructs a new API server bound to the provided State.
@@ -48,6 +315,15 @@ func NewServer(state *core.State, opts ServerOptions) *Server {
 	if opts.Addr == "" {
 		opts.Addr = DefaultAddress
 	}
+	if len(opts.Listeners) == 0 {
+		opts.Listeners = []ListenerConfig{{
+			Network:     NetworkTCP,
+			Addr:        opts.Addr,
+			Scope:       ScopeAdmin,
+			TLSCertFile: opts.TLSCertFile,
+			TLSKeyFile:  opts.TLSKeyFile,
+		}}
+	}
 	if opts.ReadTimeout == 0 {
 		opts.ReadTimeout = 5 * time.Second
 	}
@@ -64,58 +340,267 @@ func NewServer(state *core.State, opts ServerOptions) *Server {
 		opts.ShutdownTimeout = 5 * time.Second
 	}
 	if opts.Logger == nil {
-		opts.Logger = log.Default()
+		opts.Logger = slog.Default()
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = metrics.NewRegistry()
+	}
+	if opts.WSInterval == 0 {
+		opts.WSInterval = time.Second
+	}
+	if opts.WSMaxClients == 0 {
+		opts.WSMaxClients = 8
+	}
+	if opts.WSWriteTimeout == 0 {
+		opts.WSWriteTimeout = 5 * time.Second
+	}
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
 	}
+	if opts.TUNName == "" {
+		opts.TUNName = "spl0"
+	}
+	if opts.DefaultRoute == nil {
+		opts.DefaultRoute = netinfo.GetDefaultRoute
+	}
+	if opts.DefaultRoute6 == nil {
+		opts.DefaultRoute6 = netinfo.GetDefaultRoute6
+	}
+	if opts.DefaultRoutes == nil {
+		opts.DefaultRoutes = netinfo.GetDefaultRoutes
+	}
+	if opts.DefaultRoutes6 == nil {
+		opts.DefaultRoutes6 = netinfo.GetDefaultRoutes6
+	}
+	if opts.Interfaces == nil {
+		opts.Interfaces = netinfo.Interfaces
+	}
+	if opts.LookupRoute == nil {
+		opts.LookupRoute = netinfo.LookupRoute
+	}
+	if opts.Nameservers == nil {
+		opts.Nameservers = health.SystemNameservers
+	}
+	if opts.InterfaceExists == nil {
+		opts.InterfaceExists = health.SystemInterfaceExists
+	}
+	if opts.RateLimits == nil {
+		opts.RateLimits = DefaultRateLimits()
+	}
+	logger := logging.Component(opts.Logger, logging.ComponentAPI)
 
 	mux := http.NewServeMux()
 	s := &Server{
-		state:  state,
-		logger: opts.Logger,
-		opts:   opts,
-		http: &http.Server{
-			Addr:              opts.Addr,
-			Handler:           withBasicMiddleware(mux, opts.Logger),
-			ReadTimeout:       opts.ReadTimeout,
-			ReadHeaderTimeout: opts.ReadHeaderTimeout,
-			WriteTimeout:      opts.WriteTimeout,
-			IdleTimeout:       opts.IdleTimeout,
-			ErrorLog:          opts.Logger,
-			BaseContext: func(l net.Listener) context.Context {
-				return context.Background()
-			},
-		},
-	}
-
-	// Routes
-	mux.HandleFunc("/"+APIVersion+"/healthz", s.handleHealthz)
-	mux.HandleFunc("/"+APIVersion+"/status", s.handleStatus)
-	mux.HandleFunc("/"+APIVersion+"/probe", s.handleProbe)
-	mux.HandleFunc("/"+APIVersion+"/start", s.handleStart)
-	mux.HandleFunc("/"+APIVersion+"/stop", s.handleStop)
+		mux:      mux,
+		state:    state,
+		logger:   logger,
+		metrics:  opts.Metrics,
+		opts:     opts,
+		budgets:  make(map[string]Budget),
+		openapi:  BuildOpenAPI(),
+		origins:  originSet(opts.AllowedOrigins),
+		wsSlots:  make(chan struct{}, opts.WSMaxClients),
+		shutdown: make(chan struct{}),
+	}
+
+	// Routes, each with its endpoint budget (see budget.go)
+	s.handle("/healthz", s.fastBudget(), s.handleHealthz)
+	s.handle("/status", s.fastBudget(), s.handleStatus)
+	s.handle("/status/diff", s.fastBudget(), s.handleStatusDiff)
+	s.handle("/probe", s.slowBudget(), s.handleProbe)
+	s.handle("/probe/types", s.fastBudget(), s.handleProbeTypes)
+	s.handle("/probe/active", s.fastBudget(), s.handleProbeActive)
+	s.handle("/start", s.slowBudget(), s.handleStart)
+	s.handle("/stop", s.slowBudget(), s.handleStop)
+	s.handle("/pause", s.slowBudget(), s.handlePause)
+	s.handle("/resume", s.slowBudget(), s.handleResume)
+	s.handle("/warnings/clear", s.fastBudget(), s.handleWarningsClear)
+	s.handle("/routes", s.slowBudget(), s.handleRoutes)
+	s.handle("/interfaces", s.fastBudget(), s.handleInterfaces)
+	s.handle("/operations", s.fastBudget(), s.handleOperations)
+	s.handle("/operations/{id}", s.fastBudget(), s.handleOperation)
+	s.handle("/metrics", s.fastBudget(), s.handleMetrics)
+	s.handle("/ws", s.streamBudget(), s.handleWS)
+	s.handle("/openapi.json", s.fastBudget(), s.handleOpenAPI)
+	s.handle("/shutdown", s.fastBudget(), s.handleShutdown)
+	s.handle("/shutdown-report", s.fastBudget(), s.handleShutdownReport)
+	s.handle("/timeline", s.fastBudget(), s.handleTimeline)
+	s.handle("/events/history", s.fastBudget(), s.handleEventsHistory)
+	s.handle("/events/stream", s.streamBudget(), s.handleEventsStream)
+	s.handle("/recovery", s.slowBudget(), s.handleRecovery)
+	s.handle("/recovery/cleanup", s.slowBudget(), s.handleRecoveryCleanup)
+	s.handle("/debug/contention", s.slowBudget(), s.handleContention)
+	s.handle("/debug/runtime", s.fastBudget(), s.handleRuntime)
+	s.handle("/diagnostics", s.slowBudget(), s.handleDiagnostics)
+	s.handle("/tun2socks/logs", s.fastBudget(), s.handleTun2SocksLogs)
+	s.handle("/connections", s.fastBudget(), s.handleConnections)
+	s.handle("/healthcheck/full", s.slowBudget(), s.handleHealthcheckFull)
+	s.handle("/rules", s.fastBudget(), s.handleRules)
+	s.handle("/clients", s.fastBudget(), s.handleClients)
+	s.handle("/tokens", s.fastBudget(), s.handleTokens)
+	s.handle("/profiles", s.fastBudget(), s.handleProfiles)
+	s.handle("/uplinks", s.fastBudget(), s.handleUplinks)
+	s.handle("/network/captive", s.slowBudget(), s.handleCaptive)
+	s.handle("/egress", s.slowBudget(), s.handleEgress)
+	s.handle("/leaktest/dns", s.slowBudget(), s.handleLeakTestDNS)
+	s.handle("/firewall", s.fastBudget(), s.handleFirewall)
+	s.handle("/version", s.fastBudget(), s.handleVersion)
+	s.handle("/service", s.slowBudget(), s.handleService)
+	s.handle("/service/install", s.slowBudget(), s.handleServiceInstall)
+	s.handle("/service/uninstall", s.slowBudget(), s.handleServiceUninstall)
+	s.handle("/upgrade", s.upgradeBudget(), s.handleUpgrade)
+	s.handle("/dns/upstreams", s.fastBudget(), s.handleDNSUpstreams)
+	s.handle("/statemachine", s.fastBudget(), s.handleStateMachine)
+	if opts.EnablePprof {
+		s.registerPprof()
+	}
 
 	return s
 }
 
-// Start begins serving HTTP in a background goroutine.
-// It returns immediately; use Stop for graceful shutdown.
-func (s *Server) Start() {
-	go func() {
-		s.logger.Printf("api: listening on %s\n", s.http.Addr)
-		if err := s.http.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
-			s.logger.Printf("api: ListenAndServe error: %v", err)
+// Start binds every configured listener and serves each in a background
+// goroutine. Binding is synchronous so configuration and address errors are
+// returned to the caller; if a required listener fails, none are left open.
+// Optional listeners that fail are recorded as failed subsystems and
+// skipped. Use Stop for graceful shutdown.
+func (s *Server) Start() error {
+	if len(s.opts.Listeners) == 0 {
+		return errNoListeners
+	}
+	activated := slices.Clone(s.opts.Activated)
+	defer func() {
+		for _, ln := range activated {
+			s.logger.Warn("activated socket matches no listener; closing it", "addr", ln.Addr().String())
+			ln.Close()
 		}
 	}()
+	var bound []*boundListener
+	for _, raw := range s.opts.Listeners {
+		lc, err := raw.normalize()
+		if err == nil {
+			pre := takeActivated(&activated, lc)
+			var ln, raw net.Listener
+			if ln, raw, err = bind(lc, pre); err == nil {
+				bound = append(bound, &boundListener{cfg: lc, ln: ln, raw: raw, http: s.newHTTPServer(lc), activated: pre != nil})
+				detail := ""
+				if pre != nil {
+					detail = "inherited socket"
+				}
+				s.state.SetSubsystem("listener "+lc.String(), core.SubsystemOK, detail)
+				continue
+			}
+			err = fmt.Errorf("listen %s: %w", lc, err)
+		}
+		if !raw.Optional {
+			closeAll(bound)
+			return err
+		}
+		s.logger.Warn("optional listener failed; continuing without it", "listener", lc.String(), "err", err)
+		s.state.SetSubsystem("listener "+lc.String(), core.SubsystemFailed, err.Error())
+	}
+	if len(bound) == 0 {
+		return errNoListeners
+	}
+	s.listeners = bound
+
+	for _, bl := range bound {
+		go func(bl *boundListener) {
+			s.logger.Info("listening", "listener", bl.cfg.String(), "scope", bl.cfg.Scope, "inherited", bl.activated,
+				"tls", bl.cfg.TLSCertFile != "", "auth", bl.cfg.Token != "" || (s.opts.Tokens != nil && s.opts.Tokens.HasConfigured()))
+			if err := bl.http.Serve(bl.ln); !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Error("serve failed", "listener", bl.cfg.String(), "err", err)
+			}
+		}(bl)
+	}
+	return nil
 }
 
-// Stop gracefully shuts down the server, waiting up to ShutdownTimeout.
+// Stop gracefully shuts down every listener, waiting up to ShutdownTimeout.
+// Hijacked WebSocket streams are not tracked by http.Server, so they are
+// signalled separately, sent a close frame, and awaited first. What was
+// drained is recorded and available afterwards via DrainStats.
 func (s *Server) Stop(ctx context.Context) error {
+	began := time.Now()
+	drain := DrainStats{
+		OpenConns: s.metrics.OpenConns(),
+		InFlight:  s.metrics.InFlight(),
+		WSClients: len(s.wsSlots),
+	}
+	// Streams are in flight too, but are reported separately.
+	requests := max(drain.InFlight-int64(drain.WSClients), 0)
+
+	select {
+	case <-s.shutdown:
+	default:
+		close(s.shutdown)
+	}
 	timeout := s.opts.ShutdownTimeout
 	if timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, timeout)
 		defer cancel()
 	}
-	return s.http.Shutdown(ctx)
+	// A stream releases its slot once its connection is closed.
+	for len(s.wsSlots) > 0 && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	drain.WSAborted = len(s.wsSlots)
+	drain.WSClosed = int(s.wsClosed.Load())
+
+	var errs []error
+	for _, bl := range s.listeners {
+		if err := bl.http.Shutdown(ctx); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				drain.TimedOut = true
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", bl.cfg, err))
+		}
+	}
+	drain.Remaining = s.metrics.OpenConns()
+	if drain.TimedOut {
+		drain.Aborted = min(max(s.metrics.InFlight()-int64(len(s.wsSlots)), 0), requests)
+		// Force-close stragglers so the process can exit.
+		for _, bl := range s.listeners {
+			_ = bl.http.Close()
+		}
+	}
+	drain.Drained = requests - drain.Aborted
+	drain.Duration = time.Since(began)
+
+	s.drainMu.Lock()
+	s.drain = drain
+	s.drainMu.Unlock()
+	s.logger.Info("drained", "open_conns", drain.OpenConns, "in_flight", drain.InFlight,
+		"drained", drain.Drained, "aborted", drain.Aborted,
+		"ws_clients", drain.WSClients, "ws_closed", drain.WSClosed, "ws_aborted", drain.WSAborted,
+		"remaining", drain.Remaining, "timed_out", drain.TimedOut,
+		"duration_ms", drain.Duration.Milliseconds())
+	return errors.Join(errs...)
+}
+
+// DrainStats summarizes what Stop had to drain.
+type DrainStats struct {
+	OpenConns int64         // Client connections open when Stop began
+	InFlight  int64         // Requests inside handlers when Stop began, streams included
+	Drained   int64         // Of those (streams excluded), requests that completed
+	Aborted   int64         // Requests still running when the timeout force-closed them
+	WSClients int           // WebSocket streams open when Stop began
+	WSClosed  int           // Streams that sent a close frame (1001) and finished
+	WSAborted int           // Streams still open when the timeout elapsed
+	Remaining int64         // Connections still open when shutdown returned
+	TimedOut  bool          // ShutdownTimeout elapsed before draining completed
+	Duration  time.Duration // Wall time spent in Stop
+}
+
+// DrainStats returns the stats recorded by the most recent Stop, or the zero
+// value if Stop has not run.
+func (s *Server) DrainStats() DrainStats {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+	return s.drain
 }
 
 // handleHealthz is a simple readiness/liveness endpoint.
@@ -134,6 +619,13 @@ func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleStatus returns the current daemon snapshot.
+// Method: GET
+// Query: since_rev (optional; answers as GET /v1/status/diff), humanize, tz
+// Response (200): StatusResponse JSON with a weak ETag of the revision and
+// display options; 304 without a body when If-None-Match lists it
+// Errors:
+//   - 400 for an invalid tz (or since_rev, as for /v1/status/diff)
+//   - 410 when since_rev is too old to diff
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeJSON(w, http.StatusMethodNotAllowed, APIError{
@@ -142,17 +634,72 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	loc, err := s.displayLocation(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if r.URL.Query().Has("since_rev") {
+		s.writeStatusDiff(w, r, loc)
+		return
+	}
 	snap := s.state.GetSnapshot()
-	resp := FromCoreSnapshot(snap)
+	humanize := wantHumanize(r)
+	tag := statusETag(snap, s.healthScore(snap), humanize, loc)
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatch(r.Header.Get("If-None-Match"), tag) {
+		s.statusRevs.remember(snap)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.renderStatus(snap, humanize, loc))
+}
+
+// handleMetrics returns per-route request counters.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	loc, err := s.displayLocation(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	resp := FromMetricsSnapshot(s.metrics.Snapshot(), s.budgets)
+	resp.ProbeLatency = fromPercentiles(s.state.LatencyPercentiles())
+	resp.HealthScore = fromHealthScore(s.healthScore(s.state.GetSnapshot()))
+	if wantHumanize(r) {
+		humanizeMetrics(&resp)
+	}
+	localizeMetrics(&resp, loc)
 	writeJSON(w, http.StatusOK, resp)
 }
 
-// handleProbe runs a bounded SOCKS5 probe and returns a ProbeView.
+// handleProbe runs a bounded probe (SOCKS5 unless "type" names another
+// registered probe) and returns a ProbeView.
 // Method: POST
 // Request: ProbeRequest JSON
 // Response (200): ProbeView JSON (same shape as "last_probe" in /v1/status)
 // Errors:
-//   - 400 for invalid inputs (malformed host:port, negative timeout)
+//   - 400 for invalid inputs (malformed host:port, negative timeout, unknown type,
+//     unknown auth_ref, a malformed chain hop)
+//   - 403 when a non-admin caller names a server, chain hop, or target outside
+//     the policy
+//   - 500, 501, or 503 when auth_ref cannot be looked up (store failure,
+//     unsupported platform, no store)
+//   - 409 when canceled with DELETE /v1/probe/active (X-Probe-ID names
+//     it); last_probe is left alone
 //   - 502 for probe failures (TCP connect/handshake/CONNECT/UDP errors), state still updates
 func (s *Server) handleProbe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -163,6 +710,15 @@ func (s *Server) handleProbe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	loc, err := s.displayLocation(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
 	// Strict JSON decode with unkown-field rejection.
 	var req ProbeRequest
 	dec := json.NewDecoder(r.Body)
@@ -174,10 +730,133 @@ func (s *Server) handleProbe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	typ := req.Type
+	if typ == "" {
+		typ = probe.NameSOCKS5
+	}
+	p, ok := probe.Lookup(typ)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "unknown probe type: " + typ,
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// Request -> probe.Params mapping. The typed SOCKS fields take precedence
+	// over the generic options bag.
+	params := probe.Params{
+		Target:  req.Target,
+		Timeout: time.Duration(req.TimeoutMS) * time.Millisecond,
+		Options: make(map[string]string, len(req.Options)+4),
+		Logger:  logging.Component(s.opts.Logger, logging.ComponentProbe),
+	}
+	for k, v := range req.Options {
+		params.Options[k] = v
+	}
+	if typ == probe.NameSOCKS5 {
+		auth, code, err := s.resolveAuth(r, req.Auth, req.AuthRef)
+		if err != nil {
+			writeJSON(w, code, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		req.Auth = auth
+		if req.SocksServer != "" {
+			params.Target = req.SocksServer
+		}
+		if req.Auth != nil && (req.Auth.Username != "" || req.Auth.Password != "") {
+			params.Options["username"] = req.Auth.Username
+			params.Options["password"] = req.Auth.Password
+		}
+		if req.ConnectTarget != "" {
+			params.Options["connect_target"] = req.ConnectTarget
+		}
+		if req.UDPTest {
+			params.Options["udp_test"] = "true"
+		}
+		if err := validProxyType("proxy_type", req.ProxyType); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		if req.ProxyType != "" {
+			params.Options["proxy_type"] = req.ProxyType
+		}
+		chain, code, err := s.resolveChain(r, req.Chain)
+		if err != nil {
+			writeJSON(w, code, APIError{
+				Error:     err.Error(),
+				Code:      chainCode(code),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		if len(chain) > 0 {
+			params.Options["chain"] = probe.FormatChain(probeHops(chain))
+		}
+		if req.Trace {
+			params.Options["trace"] = "true"
+		}
+		if len(req.ConnectTargets) > probe.MaxConnectTargets {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     fmt.Sprintf("connect_targets: at most %d targets", probe.MaxConnectTargets),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		if len(req.ConnectTargets) > 0 {
+			params.Options["connect_targets"] = probe.FormatTargets(req.ConnectTargets)
+		}
+		if req.TLS != nil {
+			params.Options["tls"] = "true"
+			params.Options["tls_server_name"] = req.TLS.ServerName
+			params.Options["tls_alpn"] = strings.Join(req.TLS.ALPN, ",")
+		}
+		if req.HTTP != nil {
+			// The canary's endpoint is the connect target, so the policy
+			// below covers it.
+			target, err := probe.HTTPTarget(req.HTTP.URL)
+			if err == nil && params.Options["connect_target"] != "" && params.Options["connect_target"] != target {
+				err = fmt.Errorf("connect_target %s differs from http.url's %s", params.Options["connect_target"], target)
+			}
+			if err == nil && req.HTTP.ExpectStatus != 0 && (req.HTTP.ExpectStatus < 100 || req.HTTP.ExpectStatus > 599) {
+				err = fmt.Errorf("http.expect_status %d out of range", req.HTTP.ExpectStatus)
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, APIError{
+					Error:     err.Error(),
+					Timestamp: TimeNow().UTC().Format(time.RFC3339),
+				})
+				return
+			}
+			params.Options["connect_target"] = target
+			params.Options["http_url"] = req.HTTP.URL
+			if req.HTTP.ExpectStatus != 0 {
+				params.Options["http_expect_status"] = strconv.Itoa(req.HTTP.ExpectStatus)
+			}
+			params.Options["http_expect_body"] = req.HTTP.ExpectBody
+		}
+	} else if req.Trace || len(req.ConnectTargets) > 0 || req.TLS != nil || req.HTTP != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "trace, connect_targets, tls, and http are only supported by the socks5 probe",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
 	// Basic input validation (deeper checks happen inside the probe package).
-	if req.SocksServer == "" {
+	if params.Target == "" {
+		msg := "target is required"
+		if typ == probe.NameSOCKS5 {
+			msg = "socks_server is required"
+		}
 		writeJSON(w, http.StatusBadRequest, APIError{
-			Error:     "socks_server is required",
+			Error:     msg,
 			Timestamp: TimeNow().UTC().Format(time.RFC3339),
 		})
 		return
@@ -190,32 +869,81 @@ func (s *Server) handleProbe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Request -> probe.Config mapping with sensible defaults.
-	var auth *probe.Auth
-	if req.Auth != nil && (req.Auth.Username != "" || req.Auth.Password != "") {
-		auth = &probe.Auth{
-			Username: req.Auth.Username,
-			Password: req.Auth.Password,
+	// Non-admin callers stay within the probe policy. Other probe types dial
+	// their target directly, so it is checked as a connect target.
+	var perr error
+	if typ == probe.NameSOCKS5 {
+		perr = s.checkPolicy(r, params.Target, params.Options["connect_target"])
+		for _, t := range probe.ParseTargets(params.Options["connect_targets"]) {
+			if perr == nil {
+				perr = s.checkPolicy(r, "", t)
+			}
 		}
+	} else {
+		perr = s.checkPolicy(r, "", params.Target)
 	}
-	cfg := probe.Config{
-		Server:        req.SocksServer,
-		Timeout:       time.Duration(req.TimeoutMS) * time.Millisecond,
-		Auth:          auth,
-		ConnectTarget: req.ConnectTarget,
-		UDPTest:       req.UDPTest,
+	if perr != nil {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     perr.Error(),
+			Code:      CodePolicyDenied,
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
 	}
 
-	// Run the probe using the request context; probe also enforces its own deadline.
-	summary, err := probe.ProbeSOCKS(r.Context(), cfg)
+	// Run the probe using the request context; probes also enforce their own deadline.
+	// Credentials never leave this handler: the summary is served as is.
+	// DELETE /v1/probe/active cancels it; the result is then discarded.
+	ctx, done := s.trackProbe(w, r, probeKindProbe, typ, params.Target)
+	summary, err := probe.Run(ctx, p, params)
+	canceled := errors.Is(context.Cause(ctx), errProbeCanceled)
+	done()
+	if canceled {
+		s.recordEvent(r.Context(), core.EventProbeResult, "probe "+typ+" canceled", map[string]string{
+			"type":     typ,
+			"target":   params.Target,
+			"probe_id": w.Header().Get(ProbeHeader),
+			"canceled": "true",
+		})
+		writeProbeCanceled(w, "probe")
+		return
+	}
 
-	// Persist the result regardless of success.
-	s.state.UpdateProbe(summary)
+	// Persist the result regardless of success. Only the SOCKS probe describes
+	// the upstream proxy, so other probes are recorded as events instead of
+	// replacing last_probe.
+	if typ == probe.NameSOCKS5 {
+		trace := summary.Trace
+		summary = s.state.UpdateProbeWith(summary, requestData(r.Context()))
+		summary.Trace = trace
+	} else {
+		msg := "probe " + typ + " ok"
+		if err != nil {
+			msg = "probe " + typ + " failed: " + err.Error()
+		}
+		s.recordEvent(r.Context(), core.EventProbeResult, msg, map[string]string{
+			"type":       typ,
+			"target":     params.Target,
+			"reachable":  strconv.FormatBool(summary.Reachable),
+			"connect_ok": strconv.FormatBool(summary.ConnectOK),
+		})
+	}
 
 	if err != nil {
 		// Return a stable error; details available via /v1/status last_probe.warnings.
+		code := CodeProbeFailed
+		if !summary.Reachable {
+			code = CodeProbeConnect
+		}
+		details := map[string]string{"type": typ, "target": params.Target}
+		if len(summary.Trace) > 0 {
+			// Where the exchange stopped is the point of a trace.
+			details["trace"] = probe.FormatTrace(summary.Trace)
+		}
 		writeJSON(w, http.StatusBadGateway, APIError{
 			Error:     "probe failed: " + err.Error(),
+			Code:      code,
+			Details:   details,
 			Timestamp: TimeNow().UTC().Format(time.RFC3339),
 		})
 		return
@@ -223,13 +951,26 @@ func (s *Server) handleProbe(w http.ResponseWriter, r *http.Request) {
 
 	// Success: return the probe payload.
 	resp := FromProbeSummary(summary)
+	localizeProbe(&resp, loc)
 	writeJSON(w, http.StatusOK, resp)
 }
 
 // handleStart begins orchestration to route traffic via TUN + tun2socks.
 // Method: POST
 // Request: StartRequest JSON
-// Response (200): StartResponse JSON
+// Response (200): StartResponse JSON, including normalized bypass_hosts and
+// the detected LAN networks in routes.lan_cidrs
+// Errors:
+//   - 400 for invalid inputs, unresolvable or overlapping bypass hosts, an
+//     unknown auth_ref, profile, or engine, or both socks_server and
+//     wireguard
+//   - 403 when a non-admin caller names a server or target outside the policy
+//   - 409 when tun_address's subnet overlaps a live network
+//   - 409 behind a captive portal when the profile was selected
+//     automatically (CodeCaptivePortal)
+//   - 409 while another operation is in progress (OperationConflict)
+//   - 501 until orchestration lands (dry_run already validates and answers 200)
+//   - 500 and 501 carry a StartResponse with error set and the steps so far
 func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeJSON(w, http.StatusMethodNotAllowed, APIError{
@@ -250,14 +991,113 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A named profile fills what the request leaves out. Without a profile
+	// or server, the one selected for the current network applies.
+	var startWarnings []string
+	var autoSelected bool
+	if req.Profile == "" && req.SocksServer == "" && req.WireGuard == nil {
+		if name, why, ok := s.autoProfile(); ok {
+			req.Profile, autoSelected = name, true
+			s.logger.InfoContext(r.Context(), "start uses selected profile", "profile", name, "matched", why)
+			startWarnings = append(startWarnings, "using profile "+name+" selected by "+why)
+		}
+	}
+	// Behind a captive portal the upstream is unreachable until someone
+	// signs in; only an explicit start goes ahead anyway.
+	if st, ok := s.captivePortal(r.Context()); ok {
+		if autoSelected {
+			writeJSON(w, http.StatusConflict, APIError{
+				Error:     "captive portal detected: sign in at " + st.PortalURL + ", or name a profile or server to start anyway",
+				Code:      CodeCaptivePortal,
+				Details:   map[string]string{"portal_url": st.PortalURL, "profile": req.Profile},
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		startWarnings = append(startWarnings, "captive portal detected ("+st.PortalURL+"); the upstream may be unreachable until it is cleared")
+	}
+	if code, err := s.applyProfile(&req); err != nil {
+		writeJSON(w, code, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
 	// Basic validation; depper checks will live in orchestrator.
-	if req.SocksServer == "" {
+	upstream := req.SocksServer
+	if wg := req.WireGuard; wg != nil {
+		var err error
+		switch {
+		case req.SocksServer != "":
+			err = errors.New("socks_server and wireguard are mutually exclusive")
+		case req.Auth != nil || req.AuthRef != "":
+			err = errors.New("auth and auth_ref apply to socks_server only")
+		case req.ProxyType != "" || len(req.Chain) > 0:
+			err = errors.New("proxy_type and chain apply to socks_server only")
+		default:
+			_, err = wg.config()
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		upstream = wg.Endpoint
+	} else if req.SocksServer == "" {
 		writeJSON(w, http.StatusBadRequest, APIError{
-			Error:     "socks_server is required",
+			Error:     "socks_server or wireguard is required",
 			Timestamp: TimeNow().UTC().Format(time.RFC3339),
 		})
 		return
 	}
+	if err := validProxyType("proxy_type", req.ProxyType); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	switch {
+	case !req.UDP:
+	case req.ProxyType == tun2socks.ProxyHTTP:
+		startWarnings = append(startWarnings, "udp is not carried by http proxies; status reports it unsupported")
+	case len(req.Chain) > 0:
+		startWarnings = append(startWarnings, "udp is not carried through proxy chains; status reports it unsupported")
+	}
+
+	if err := s.checkPolicy(r, upstream, req.ConnectTarget); err != nil {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     err.Error(),
+			Code:      CodePolicyDenied,
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// Stored credentials are looked up now so a bad auth_ref fails before
+	// anything changes; orchestration only sees req.Auth.
+	auth, code, err := s.resolveAuth(r, req.Auth, req.AuthRef)
+	if err != nil {
+		writeJSON(w, code, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	req.Auth = auth
+	chain, code, err := s.resolveChain(r, req.Chain)
+	if err != nil {
+		writeJSON(w, code, APIError{
+			Error:     err.Error(),
+			Code:      chainCode(code),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	req.Chain = chain
 
 	// Conservative MTU bounds (typical ethernet MTU to jumbo); 0 means "use default".
 	if req.MTU < 0 || (req.MTU > 0 && (req.MTU < 576 || req.MTU > 9000)) {
@@ -268,10 +1108,183 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// orchestration todo
-	writeJSON(w, http.StatusNotImplemented, APIError{
-		Error:     "start not implemented yet",
-		Timestamp: TimeNow().UTC().Format(time.RFC3339),
+	// Custom TUN addressing; the subnet is checked against live networks
+	// once LAN detection has run.
+	var tunAddr routeplan.TUNAddr
+	if req.TUNAddress != "" || req.TUNPeer != "" {
+		switch {
+		case req.TUNAddress == "":
+			err = errors.New("tun_peer requires tun_address")
+		case req.WireGuard != nil:
+			err = errors.New("tun_address does not apply to wireguard upstreams; the peer assigns the address")
+		default:
+			tunAddr, err = routeplan.ParseTUNAddr(req.TUNAddress, req.TUNPeer)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+	}
+
+	if _, err := s.startEngine(req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// DNS upstreams for this start replace the configured ones.
+	if req.DisableDNS && len(req.DNSUpstreams) > 0 {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "dns_upstreams and disable_dns are mutually exclusive",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if len(req.DNSUpstreams) > 0 {
+		if _, err := dnsproxy.ParseUpstreams(req.DNSUpstreams); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "dns_upstreams: " + err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+	}
+
+	// Resolve and normalize bypass hosts; duplicates and overlaps are rejected.
+	bypassEntries, err := bypass.Normalize(r.Context(), s.opts.Resolver, req.BypassHosts)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// Split tunnel destinations; duplicates and /0 are rejected.
+	var split splitCIDRs
+	split.include, err = routeplan.ParseCIDRs("include_cidrs", req.IncludeCIDRs)
+	if err == nil {
+		split.exclude, err = routeplan.ParseCIDRs("exclude_cidrs", req.ExcludeCIDRs)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// Outbound interface preference; the active uplink and its standbys, or
+	// else the configured order, apply when the request names none.
+	uplinks, err := routeplan.ParseUplinks("outbound_interfaces", req.OutboundInterfaces)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if len(uplinks) == 0 && s.opts.Uplinks != nil {
+		uplinks = s.opts.Uplinks.Order()
+	}
+	if len(uplinks) == 0 {
+		uplinks = s.opts.OutboundInterfaces
+	}
+
+	// A zero MTU is tuned to the path; the plan and orchestration see
+	// the chosen value.
+	mtu := s.chooseMTU(req, uplinks)
+	req.MTU = mtu.MTU
+
+	// LAN networks stay reachable outside the TUN unless the caller opts out.
+	// Detection problems degrade to warnings; the plan proceeds without them.
+	var (
+		lanWarnings []string
+		lanNets     []discovery.LANNet
+	)
+	if !req.DisableLANDetect {
+		lanNets, err = discovery.DetectLAN(s.opts.LAN)
+		if err != nil {
+			lanWarnings = append(lanWarnings, "lan detection: "+err.Error())
+		}
+	}
+	lanCIDRs := discovery.Strings(lanNets)
+	if tunAddr.Local.IsValid() {
+		warning, err := s.checkTUNSubnet(tunAddr.Subnet(), lanNets)
+		if err != nil {
+			writeJSON(w, http.StatusConflict, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		if warning != "" {
+			lanWarnings = append(lanWarnings, warning)
+		}
+	}
+
+	// A dry run reports the validated plan against the current state.
+	if req.DryRun {
+		snap := s.state.GetSnapshot()
+		plan, planWarnings := s.planRoutes(r.Context(), req, snap, bypassEntries, lanNets, split, uplinks)
+		status := FromCoreSnapshot(snap)
+		status.Routes.LanCIDRs = lanCIDRs
+		status.Routes.IncludeCIDRs = prefixStrings(split.include)
+		status.Routes.ExcludeCIDRs = prefixStrings(split.exclude)
+		status.Warnings = append(append(append(status.Warnings, startWarnings...), lanWarnings...), planWarnings...)
+		writeJSON(w, http.StatusOK, StartResponse{
+			State:       status.State,
+			Warnings:    status.Warnings,
+			TUN:         status.TUN,
+			Routes:      status.Routes,
+			Tun2Socks:   status.Tun2Socks,
+			BypassHosts: FromBypassEntries(bypassEntries),
+			MTU:         fromMTUChoice(mtu),
+			Plan:        plan,
+			GeneratedAt: status.GeneratedAt,
+		})
+		return
+	}
+
+	// One operation at a time; see beginOperation.
+	op, cur := s.beginOperation(r, opStart, req.Async)
+	if cur != nil {
+		s.writeOperationConflict(w, cur)
+		return
+	}
+	w.Header().Set(OperationHeader, op.ID)
+	s.recordEvent(r.Context(), core.EventOrchestration, fmt.Sprintf("tun mtu %d: %s", mtu.MTU, mtu.Reason), map[string]string{
+		"kind":         "mtu",
+		"mtu":          strconv.Itoa(mtu.MTU),
+		"source":       mtu.Source,
+		"operation_id": op.ID,
+	})
+	if req.Async {
+		s.runAsync(w, op)
+		return
+	}
+	err = s.runOperation(r.Context(), op)
+	s.endOperation(op, err)
+	code, errCode, errText := operationResult(err)
+	status := FromCoreSnapshot(s.state.GetSnapshot())
+	writeJSON(w, code, StartResponse{
+		OperationID: op.ID,
+		State:       status.State,
+		Warnings:    append(status.Warnings, startWarnings...),
+		TUN:         status.TUN,
+		Routes:      status.Routes,
+		Tun2Socks:   status.Tun2Socks,
+		BypassHosts: FromBypassEntries(bypassEntries),
+		MTU:         fromMTUChoice(mtu),
+		Steps:       s.operationSteps(op),
+		Error:       errText,
+		Code:        errCode,
+		GeneratedAt: status.GeneratedAt,
 	})
 }
 
@@ -279,6 +1292,10 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 // Method: POST
 // Request: StopRequest JSON
 // Response (200): StopResponse JSON
+// Errors:
+//   - 409 while another operation is in progress (OperationConflict)
+//   - 501 until orchestration lands
+//   - 500 and 501 carry a StopResponse with error set and the steps so far
 func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeJSON(w, http.StatusMethodNotAllowed, APIError{
@@ -299,26 +1316,131 @@ func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//
-	writeJSON(w, http.StatusNotImplemented, APIError{
-		Error:     "stop not implemented yet",
-		Timestamp: TimeNow().UTC().Format(time.RFC3339),
+	op, cur := s.beginOperation(r, opStop, req.Async)
+	if cur != nil {
+		s.writeOperationConflict(w, cur)
+		return
+	}
+	w.Header().Set(OperationHeader, op.ID)
+	if req.Async {
+		s.runAsync(w, op)
+		return
+	}
+	err := s.runOperation(r.Context(), op)
+	s.endOperation(op, err)
+	code, errCode, errText := operationResult(err)
+	status := FromCoreSnapshot(s.state.GetSnapshot())
+	writeJSON(w, code, StopResponse{
+		OperationID: op.ID,
+		State:       status.State,
+		Warnings:    status.Warnings,
+		Steps:       s.operationSteps(op),
+		Error:       errText,
+		Code:        errCode,
+		GeneratedAt: status.GeneratedAt,
 	})
 }
 
-// Basic middleware: sets JSON content type and very lightweight logging.
-// No CORS or auth because this is a local control-plane service.
-func withBasicMiddleware(next http.Handler, logger *log.Logger) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// Basic middleware: assigns the request ID, sets JSON content type, logs one
+// structured record per request, and feeds per-route counters into the
+// metrics registry.
+func withBasicMiddleware(next http.Handler, logger *slog.Logger, reg *metrics.Registry) http.Handler {
+	return withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := TimeNow()
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		next.ServeHTTP(w, r)
+		rec := &responseRecorder{ResponseWriter: w}
+		reg.RequestStarted()
+		defer reg.RequestFinished()
+		next.ServeHTTP(rec, r)
 		dur := time.Since(start)
-		logger.Printf("%s %s %dms UA=%q", r.Method, r.URL.Path, dur.Milliseconds(), r.UserAgent())
-	})
+		// ServeMux records the matched pattern on the request it routed.
+		reg.ObserveRequest(r.Pattern, rec.Status(), rec.Bytes(), dur)
+		logger.LogAttrs(r.Context(), levelForStatus(rec.Status()), "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.Int("status", rec.Status()),
+			slog.Int64("bytes", rec.Bytes()),
+			slog.Int64("duration_ms", dur.Milliseconds()),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	}))
+}
+
+// responseRecorder captures the status code and body size written by a handler.
+// Handlers that never call WriteHeader implicitly respond 200.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Hijack takes over the connection (used by /v1/ws) and records the
+// request as 101 Switching Protocols for logs and metrics.
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Status returns the recorded status code, defaulting to 200.
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Bytes returns the number of body bytes written.
+func (r *responseRecorder) Bytes() int64 {
+	return r.bytes
+}
+
+// levelForStatus maps response classes to log levels so that server errors
+// stand out without raising the global level.
+func levelForStatus(code int) slog.Level {
+	switch {
+	case code >= 500:
+		return slog.LevelError
+	case code >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
+	if e, ok := v.(APIError); ok {
+		if e.Code == "" {
+			e.Code = codeForStatus(status)
+		}
+		e.RequestID = w.Header().Get(RequestIDHeader)
+		e.Error, e.Details = redact.String(e.Error), redact.Map(e.Details)
+		v = e
+	}
 	w.WriteHeader(status)
 	enc := json.NewEncoder(w)
 	enc.SetEscapeHTML(true)
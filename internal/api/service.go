@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/launchd"
+)
+
+// uninstallDelay is how long an agent uninstalling its own launch daemon
+// waits before booting it out, so the response reaches the client first.
+const uninstallDelay = 500 * time.Millisecond
+
+// handleService reports the agent's launch daemon: whether its plist is
+// installed, whether launchd has it loaded and running, and whether this
+// agent was started by it.
+// Method: GET
+// Response (200): ServiceResponse JSON
+// Errors:
+//   - 405 for other methods
+//   - 500 when launchctl fails
+//   - 503 off macOS or when the process did not configure the service
+func (s *Server) handleService(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	st, ok := s.queryService(w)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, FromServiceStatus(st, s.opts.Service, nil))
+}
+
+// handleServiceInstall writes the launch daemon's plist, running this
+// executable with this process's arguments or those in the body, and
+// loads it. A job that is already loaded keeps running with its old
+// arguments until it is reloaded; the response warns.
+// Method: POST
+// Request: ServiceInstallRequest (optional body)
+// Response (200): ServiceResponse JSON
+// Errors:
+//   - 400 invalid body
+//   - 403 without admin scope, or from a tokenless TCP listener (see
+//     requireProven)
+//   - 405 for other methods
+//   - 500 when the plist cannot be written or launchctl fails
+//   - 503 off macOS or when the process did not configure the service
+func (s *Server) handleServiceInstall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if requestScope(r.Context()) != ScopeAdmin {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     "service install requires admin scope",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if !requireProven(w, r, "service install") {
+		return
+	}
+	var req ServiceInstallRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	st, ok := s.queryService(w)
+	if !ok {
+		return
+	}
+
+	job := s.opts.Service
+	if req.Args != nil {
+		job.Args = req.Args
+	}
+	path, err := launchd.WritePlist(job)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIError{
+			Error:     "write plist: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	var warnings []string
+	if st.Loaded {
+		warnings = append(warnings, "job already loaded; the new plist applies after POST /v1/service/uninstall and install, or a reboot")
+	} else if err := launchd.Load(path); err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	s.logger.InfoContext(r.Context(), "launch daemon installed", "label", job.Label, "plist", path, "client", clientID(r.Context()))
+	s.recordEvent(r.Context(), core.EventOrchestration, "launch daemon installed", map[string]string{"label": job.Label, "plist": path})
+
+	st, err = launchd.Query(job.Label)
+	if err != nil {
+		warnings = append(warnings, "query: "+err.Error())
+	}
+	writeJSON(w, http.StatusOK, FromServiceStatus(st, job, warnings))
+}
+
+// handleServiceUninstall unloads the launch daemon and removes its plist.
+// When this agent runs under the job, unloading stops it: the response is
+// sent first and the agent exits shortly after.
+// Method: POST
+// Response (200): ServiceResponse JSON
+// Errors:
+//   - 403 without admin scope, or from a tokenless TCP listener (see
+//     requireProven)
+//   - 405 for other methods
+//   - 500 when the plist cannot be removed or launchctl fails
+//   - 503 off macOS or when the process did not configure the service
+func (s *Server) handleServiceUninstall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if requestScope(r.Context()) != ScopeAdmin {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     "service uninstall requires admin scope",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if !requireProven(w, r, "service uninstall") {
+		return
+	}
+	st, ok := s.queryService(w)
+	if !ok {
+		return
+	}
+	label := st.Label
+	if err := launchd.RemovePlist(label); err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIError{
+			Error:     "remove plist: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	st.Installed = false
+	s.logger.InfoContext(r.Context(), "launch daemon uninstalled", "label", label, "client", clientID(r.Context()))
+	s.recordEvent(r.Context(), core.EventOrchestration, "launch daemon uninstalled", map[string]string{"label": label})
+
+	var warnings []string
+	switch {
+	case !st.Loaded:
+	case launchd.Under(label):
+		// Booting out our own job sends us SIGTERM; answer before that.
+		warnings = append(warnings, "agent runs under this job and will exit")
+		go func() {
+			time.Sleep(uninstallDelay)
+			if err := launchd.Unload(label); err != nil {
+				s.logger.Error("launch daemon unload failed", "label", label, "error", err)
+			}
+		}()
+	default:
+		if err := launchd.Unload(label); err != nil {
+			writeJSON(w, http.StatusInternalServerError, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		st.Loaded, st.State, st.PID = false, "", 0
+	}
+	writeJSON(w, http.StatusOK, FromServiceStatus(st, s.opts.Service, warnings))
+}
+
+// queryService returns the configured job's status, or writes the error
+// response and returns false.
+func (s *Server) queryService(w http.ResponseWriter) (launchd.Status, bool) {
+	if s.opts.Service.Label == "" {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "service management not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return launchd.Status{}, false
+	}
+	st, err := launchd.Query(s.opts.Service.Label)
+	switch {
+	case errors.Is(err, launchd.ErrUnsupported):
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return launchd.Status{}, false
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return launchd.Status{}, false
+	}
+	return st, true
+}
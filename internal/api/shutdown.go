@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxShutdownReason bounds the reason recorded in the shutdown report.
+const maxShutdownReason = 200
+
+// handleShutdown asks the agent to exit cleanly. The response is sent
+// before teardown begins; the outcome is in GET /v1/shutdown-report on the
+// next run.
+// Method: POST
+// Request: ShutdownRequest (optional body)
+// Response: 202 ShutdownResponse
+// Errors: 400 invalid body, 403 without admin scope or from a tokenless
+// TCP listener (see requireProven), 409 when shutdown is already underway,
+// 503 when the process did not enable it.
+func (s *Server) handleShutdown(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if requestScope(r.Context()) != ScopeAdmin {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     "shutdown requires admin scope",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if !requireProven(w, r, "shutdown") {
+		return
+	}
+	if s.opts.RequestShutdown == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "shutdown via API not enabled",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req ShutdownRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if len(req.Reason) > maxShutdownReason {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "reason too long",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	reason := "api"
+	if req.Reason != "" {
+		reason += ": " + req.Reason
+	}
+
+	if !s.exiting.CompareAndSwap(false, true) {
+		writeJSON(w, http.StatusConflict, APIError{
+			Error:     "shutdown already in progress",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	s.logger.InfoContext(r.Context(), "shutdown requested", "reason", reason, "client", clientID(r.Context()), "remote_addr", r.RemoteAddr)
+	s.opts.RequestShutdown(reason)
+	writeJSON(w, http.StatusAccepted, ShutdownResponse{
+		Accepted:    true,
+		Reason:      reason,
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	})
+}
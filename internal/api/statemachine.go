@@ -0,0 +1,66 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// handleStateMachine reports the lifecycle state machine, generated from
+// the table SetAgentState enforces, so clients need not hardcode it.
+// Method: GET
+// Query: format=json (default) or format=dot for a Graphviz digraph
+// Response (200): StateMachineResponse JSON, or text/vnd.graphviz
+// Errors:
+//   - 400 for an unknown format
+func (s *Server) handleStateMachine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	resp := FromStateMachine(s.state.GetSnapshot().AgentState)
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, resp)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(stateMachineDOT(resp)))
+	default:
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "format must be json or dot",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// stateMachineDOT renders sm as a Graphviz digraph. The initial state is
+// drawn with a double circle and the current state is filled.
+func stateMachineDOT(sm StateMachineResponse) string {
+	var b strings.Builder
+	b.WriteString("digraph agent {\n\trankdir=LR;\n\tnode [shape=circle];\n")
+	for _, st := range sm.States {
+		var attrs []string
+		if st == sm.Initial {
+			attrs = append(attrs, "shape=doublecircle")
+		}
+		if st == sm.Current {
+			attrs = append(attrs, "style=filled")
+		}
+		fmt.Fprintf(&b, "\t%s", strconv.Quote(st))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	for _, t := range sm.Transitions {
+		fmt.Fprintf(&b, "\t%s -> %s;\n", strconv.Quote(t.From), strconv.Quote(t.To))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
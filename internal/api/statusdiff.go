@@ -0,0 +1,252 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// statusHistoryLen is how many served revisions /v1/status/diff can diff
+// against. Older revisions answer 410 and the client refetches /v1/status.
+const statusHistoryLen = 64
+
+// statusHistory remembers recently served snapshots by revision.
+type statusHistory struct {
+	mu    sync.Mutex
+	snaps []core.Snapshot // oldest first, at most statusHistoryLen
+}
+
+// remember records snap unless its revision is already present.
+func (h *statusHistory) remember(snap core.Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if n := len(h.snaps); n > 0 && h.snaps[n-1].Rev >= snap.Rev {
+		return // revisions only grow; an equal or older one is already known
+	}
+	if len(h.snaps) == statusHistoryLen {
+		copy(h.snaps, h.snaps[1:])
+		h.snaps = h.snaps[:statusHistoryLen-1]
+	}
+	h.snaps = append(h.snaps, snap)
+}
+
+// lookup returns the snapshot served at rev.
+func (h *statusHistory) lookup(rev uint64) (core.Snapshot, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range h.snaps {
+		if s.Rev == rev {
+			return s, true
+		}
+	}
+	return core.Snapshot{}, false
+}
+
+// renderStatus maps snap with the request's display options and remembers
+// it so later diffs can use its revision as a base.
+func (s *Server) renderStatus(snap core.Snapshot, humanize bool, loc *time.Location) StatusResponse {
+	s.statusRevs.remember(snap)
+	resp := FromCoreSnapshot(snap)
+	score := s.healthScore(snap)
+	resp.Health.Score, resp.Health.ScoreParts = fromHealthScore(score), fromScoreParts(score)
+	if humanize {
+		humanizeStatus(&resp)
+	}
+	localizeStatus(&resp, loc)
+	return resp
+}
+
+// statusETag is the entity tag of snap rendered with the given display
+// options: its revision plus the traffic counters and health score, which
+// move without one. It is weak because uptime and generated_at change
+// with the clock; a match means nothing but those fields changed.
+func statusETag(snap core.Snapshot, score core.HealthScore, humanize bool, loc *time.Location) string {
+	tag := strconv.FormatUint(snap.Rev, 10)
+	if t := fromTraffic(snap.Tun2Socks.Traffic); t != nil {
+		tag += fmt.Sprintf("-t%x.%x.%x.%x", t.BytesIn, t.BytesOut, t.RateInBps, t.RateOutBps)
+	}
+	if score.Known {
+		tag += "-s" + strconv.Itoa(score.Score)
+		parts := fromScoreParts(score)
+		for _, k := range slices.Sorted(maps.Keys(parts)) {
+			tag += "." + k[:1] + strconv.FormatFloat(parts[k], 'g', -1, 64)
+		}
+	}
+	if humanize {
+		tag += "-h"
+	}
+	if loc != nil {
+		tag += "-" + strings.ReplaceAll(loc.String(), `"`, "")
+	}
+	return `W/"` + tag + `"`
+}
+
+// etagMatch reports whether an If-None-Match header lists tag, comparing
+// weakly (RFC 9110 13.1.2).
+func etagMatch(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// statusVolatile lists fields that change with the clock rather than the
+// revision, dot-separated below the top level; a diff always carries their
+// current values.
+var statusVolatile = []string{"uptime_sec", "uptime_human", "generated_at", "generated_at_local", "tun2socks.traffic", "health.score", "health.score_parts"}
+
+// handleStatusDiff returns a JSON Merge Patch (RFC 7386) that turns the
+// status served at since_rev into the current one.
+// Method: GET
+// Query: since_rev (required), humanize, tz (must match the base request)
+// Response (200): merge patch object; "rev" is present when the revision
+// changed, and uptime/generated_at fields are always present
+// Errors:
+//   - 400 for a missing, malformed, or future since_rev
+//   - 410 when since_rev is too old to diff; fetch GET /v1/status instead
+func (s *Server) handleStatusDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	loc, err := s.displayLocation(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	s.writeStatusDiff(w, r, loc)
+}
+
+// writeStatusDiff answers a diff request for GET /v1/status/diff and for
+// GET /v1/status?since_rev=N.
+func (s *Server) writeStatusDiff(w http.ResponseWriter, r *http.Request, loc *time.Location) {
+	since, err := strconv.ParseUint(r.URL.Query().Get("since_rev"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "since_rev must be a revision number from a previous status response",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	snap := s.state.GetSnapshot()
+	if since > snap.Rev {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "since_rev is newer than the current revision " + strconv.FormatUint(snap.Rev, 10),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	base, ok := s.statusRevs.lookup(since)
+	if !ok {
+		writeJSON(w, http.StatusGone, APIError{
+			Error:     "revision " + strconv.FormatUint(since, 10) + " is no longer available; fetch /v1/status",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	humanize := wantHumanize(r)
+	oldDoc, err := toJSONObject(s.renderStatus(base, humanize, loc))
+	if err == nil {
+		var newDoc map[string]any
+		newDoc, err = toJSONObject(s.renderStatus(snap, humanize, loc))
+		if err == nil {
+			patch := mergePatch(oldDoc, newDoc)
+			for _, k := range statusVolatile {
+				setVolatile(patch, newDoc, strings.Split(k, "."))
+			}
+			writeJSON(w, http.StatusOK, patch)
+			return
+		}
+	}
+	writeJSON(w, http.StatusInternalServerError, APIError{
+		Error:     "render status: " + err.Error(),
+		Timestamp: TimeNow().UTC().Format(time.RFC3339),
+	})
+}
+
+// setVolatile copies the value at path in newDoc into patch, creating
+// the enclosing objects; absent values are left out.
+func setVolatile(patch, newDoc map[string]any, path []string) {
+	v, ok := newDoc[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		patch[path[0]] = v
+		return
+	}
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return
+	}
+	sub, ok := patch[path[0]].(map[string]any)
+	if !ok {
+		sub = map[string]any{}
+	}
+	setVolatile(sub, obj, path[1:])
+	if len(sub) > 0 {
+		patch[path[0]] = sub
+	}
+}
+
+// toJSONObject round-trips v through encoding/json into a generic object.
+func toJSONObject(v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
+
+// mergePatch computes an RFC 7386 patch from oldDoc to newDoc: changed
+// values are set, removed keys become null, nested objects recurse, and
+// arrays are replaced whole.
+func mergePatch(oldDoc, newDoc map[string]any) map[string]any {
+	patch := map[string]any{}
+	for k, nv := range newDoc {
+		ov, ok := oldDoc[k]
+		if !ok {
+			patch[k] = nv
+			continue
+		}
+		om, oIsObj := ov.(map[string]any)
+		nm, nIsObj := nv.(map[string]any)
+		if oIsObj && nIsObj {
+			if sub := mergePatch(om, nm); len(sub) > 0 {
+				patch[k] = sub
+			}
+			continue
+		}
+		if !reflect.DeepEqual(ov, nv) {
+			patch[k] = nv
+		}
+	}
+	for k := range oldDoc {
+		if _, ok := newDoc[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	return patch
+}
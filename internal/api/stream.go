@@ -0,0 +1,239 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/stream"
+)
+
+// sseHeartbeat is the idle interval after which the event stream sends a
+// comment line, so proxies and clients can tell a quiet stream from a dead one.
+const sseHeartbeat = 15 * time.Second
+
+// streamEventTypes are the event types a filter may name.
+var streamEventTypes = []core.EventType{core.EventStateChange, core.EventProbeResult, core.EventOrchestration, core.EventWarning}
+
+// parseStreamFilter reads the subscription filter and buffer size shared by
+// GET /v1/events/stream and /v1/ws?stream=events.
+func parseStreamFilter(q url.Values) (stream.Filter, int, error) {
+	f := stream.Filter{Events: true}
+	if v := q.Get("kinds"); v != "" {
+		f.Events = false
+		for _, k := range strings.Split(v, ",") {
+			switch strings.TrimSpace(k) {
+			case "events":
+				f.Events = true
+			case "flows":
+				f.Flows = true
+			default:
+				return f, 0, errors.New("kinds must list events and/or flows")
+			}
+		}
+	}
+	if v := q.Get("types"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			typ := core.EventType(strings.TrimSpace(t))
+			if !slices.Contains(streamEventTypes, typ) {
+				return f, 0, fmt.Errorf("unknown event type %q", typ)
+			}
+			f.Types = append(f.Types, typ)
+		}
+	}
+	if v := q.Get("min_severity"); v != "" {
+		sev, err := core.ParseSeverity(v)
+		if err != nil {
+			return f, 0, errors.New("min_severity must be info, warning, or error")
+		}
+		f.MinSeverity = sev
+	}
+	switch v := q.Get("flow_proto"); v {
+	case "":
+	case "tcp":
+		f.Flow.Proto = 6
+	case "udp":
+		f.Flow.Proto = 17
+	default:
+		n, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || n == 0 {
+			return f, 0, errors.New("flow_proto must be tcp, udp, or a protocol number")
+		}
+		f.Flow.Proto = uint8(n)
+	}
+	if v := q.Get("flow_cidr"); v != "" {
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			a, aerr := netip.ParseAddr(v)
+			if aerr != nil {
+				return f, 0, errors.New("flow_cidr must be a CIDR or an IP address")
+			}
+			p = netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen())
+		}
+		f.Flow.Prefix = p.Masked()
+	}
+	if v := q.Get("flow_port"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 16)
+		if err != nil || n == 0 {
+			return f, 0, errors.New("flow_port must be between 1 and 65535")
+		}
+		f.Flow.Port = uint16(n)
+	}
+	switch v := q.Get("flow_verdict"); v {
+	case "", "tunnel", "direct", "block":
+		f.Flow.Verdict = v
+	default:
+		return f, 0, errors.New("flow_verdict must be tunnel, direct, or block")
+	}
+	f.Flow.Rule = q.Get("flow_rule")
+	buffer := stream.DefaultBuffer
+	if v := q.Get("buffer"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < stream.MinBuffer || n > stream.MaxBuffer {
+			return f, 0, fmt.Errorf("buffer must be an integer between %d and %d", stream.MinBuffer, stream.MaxBuffer)
+		}
+		buffer = n
+	}
+	return f, buffer, nil
+}
+
+// handleEventsStream streams events and flow records as Server-Sent Events.
+// Each record is one SSE message with event "event" or "flow" and a
+// StreamMessage as data; events also carry their ID.
+// Method: GET
+// Query: kinds, types, min_severity, flow_proto, flow_cidr, flow_port,
+// flow_verdict, flow_rule, buffer (see docs/api.md)
+// Response (200): text/event-stream
+// Errors:
+//   - 400 for an invalid filter
+//   - 403 when Origin is set and not in AllowedOrigins
+//   - 503 when streaming is not configured or WSMaxClients streams are open
+func (s *Server) handleEventsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if s.refuseForeignOrigin(w, r) {
+		return
+	}
+	if s.opts.Stream == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "event streaming not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	filter, buffer, err := parseStreamFilter(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	// Streams share the WebSocket client limit and shutdown drain.
+	select {
+	case s.wsSlots <- struct{}{}:
+		defer func() { <-s.wsSlots }()
+	default:
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "too many streaming clients",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The server's write timeout would cut the stream; each write gets its own.
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(b []byte) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(s.opts.WSWriteTimeout))
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	if err := write([]byte(": stream open\n\n")); err != nil {
+		return
+	}
+
+	ctx := r.Context()
+	sub := s.opts.Stream.Subscribe(ctx, filter, buffer)
+	defer s.clients.subscribe(clientID(ctx), SubscriptionEvents)()
+	s.logger.InfoContext(r.Context(), "event stream client connected", "remote_addr", r.RemoteAddr, "buffer", buffer)
+	defer s.logger.InfoContext(r.Context(), "event stream client disconnected", "remote_addr", r.RemoteAddr, "dropped", sub.Dropped())
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-sub.Ready():
+			recs, dropped := sub.Drain()
+			var b []byte
+			for _, rec := range recs {
+				data, err := json.Marshal(FromRecord(rec, dropped))
+				if err != nil {
+					continue
+				}
+				if rec.Event != nil {
+					b = fmt.Appendf(b, "id: %d\n", rec.Event.ID)
+				}
+				b = fmt.Appendf(b, "event: %s\ndata: %s\n\n", rec.Kind, data)
+			}
+			if err := write(b); err != nil {
+				s.logger.DebugContext(r.Context(), "event stream write failed", "remote_addr", r.RemoteAddr, "err", err)
+				return
+			}
+			heartbeat.Reset(sseHeartbeat)
+		case <-heartbeat.C:
+			if err := write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-s.shutdown:
+			return
+		}
+	}
+}
+
+// streamEvents pushes subscription records to a WebSocket client, one
+// StreamMessage per text frame, until the client leaves or the server stops.
+// It returns false when the server is shutting down.
+func (s *Server) streamEvents(ws *wsConn, sub *stream.Subscription, readerDone <-chan struct{}) bool {
+	for {
+		select {
+		case <-sub.Ready():
+			recs, dropped := sub.Drain()
+			for _, rec := range recs {
+				b, err := json.Marshal(FromRecord(rec, dropped))
+				if err != nil {
+					continue
+				}
+				if err := ws.writeFrame(wsOpText, b); err != nil {
+					return true
+				}
+			}
+		case <-readerDone:
+			return true
+		case <-s.shutdown:
+			return false
+		}
+	}
+}
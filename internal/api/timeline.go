@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Timeline window bounds for GET /v1/timeline.
+const (
+	defaultTimelineHours = 24
+	maxTimelineHours     = 7 * 24
+)
+
+// handleTimeline returns significant health events over the last N hours.
+// Method: GET
+// Query: hours (1-168, default 24), tz (adds at_local)
+// Response (200): TimelineResponse JSON, entries oldest first
+// Errors:
+//   - 400 for an invalid hours or tz value
+func (s *Server) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	hours := defaultTimelineHours
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTimelineHours {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "hours must be an integer between 1 and 168",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		hours = n
+	}
+	loc, err := s.displayLocation(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	since := TimeNow().Add(-time.Duration(hours) * time.Hour)
+	resp := FromTimeline(since, s.state.Timeline(since))
+	for i := range resp.Entries {
+		resp.Entries[i].AtLocal = localTime(resp.Entries[i].At, loc)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
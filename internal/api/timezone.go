@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// displayLocation resolves the timezone used for *_local companion fields.
+// A ?tz= query parameter (IANA name such as "Europe/Berlin", or "Local")
+// overrides ServerOptions.DisplayLocation. Returns nil when neither is set,
+// meaning only UTC fields are rendered.
+func (s *Server) displayLocation(r *http.Request) (*time.Location, error) {
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tz %q", tz)
+		}
+		return loc, nil
+	}
+	return s.opts.DisplayLocation, nil
+}
+
+// localTime re-renders an RFC3339 UTC timestamp in loc. Empty or unparseable
+// input yields "" so the companion field is omitted.
+func localTime(utc string, loc *time.Location) string {
+	if utc == "" || loc == nil {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339, utc)
+	if err != nil {
+		return ""
+	}
+	return t.In(loc).Format(time.RFC3339)
+}
+
+// localizeStatus fills the *_local companion fields on a StatusResponse.
+func localizeStatus(resp *StatusResponse, loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	resp.TZ = loc.String()
+	resp.StartedAtLocal = localTime(resp.StartedAt, loc)
+	resp.GeneratedAtLocal = localTime(resp.GeneratedAt, loc)
+	localizeProbe(&resp.LastProbe, loc)
+	resp.Health.SinceLocal = localTime(resp.Health.Since, loc)
+	for i := range resp.Subsystems {
+		resp.Subsystems[i].SinceLocal = localTime(resp.Subsystems[i].Since, loc)
+	}
+}
+
+// localizeProbe fills the *_local companion fields on a ProbeView.
+func localizeProbe(p *ProbeView, loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	p.LastCheckedLocal = localTime(p.LastChecked, loc)
+}
+
+// localizeMetrics fills the *_local companion fields on a MetricsResponse.
+func localizeMetrics(resp *MetricsResponse, loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	resp.TZ = loc.String()
+	resp.StartedAtLocal = localTime(resp.StartedAt, loc)
+	resp.GeneratedAtLocal = localTime(resp.GeneratedAt, loc)
+}
+
+// localizeHealthReport fills the *_local companion fields on a HealthReportResponse.
+func localizeHealthReport(resp *HealthReportResponse, loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	resp.TZ = loc.String()
+	resp.StartedAtLocal = localTime(resp.StartedAt, loc)
+	resp.GeneratedAtLocal = localTime(resp.GeneratedAt, loc)
+}
+
+// localizeClients fills the *_local companion fields on a ClientsResponse.
+func localizeClients(resp *ClientsResponse, loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	resp.TZ = loc.String()
+	resp.GeneratedAtLocal = localTime(resp.GeneratedAt, loc)
+	for i := range resp.Clients {
+		resp.Clients[i].FirstSeenLocal = localTime(resp.Clients[i].FirstSeen, loc)
+		resp.Clients[i].LastSeenLocal = localTime(resp.Clients[i].LastSeen, loc)
+	}
+}
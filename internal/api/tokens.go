@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/tokens"
+)
+
+// handleTokens lists, mints, and revokes named API tokens. Every method
+// requires admin scope.
+// Method: GET, POST, DELETE
+// Query (DELETE): name (required)
+// Request (POST): TokenRequest JSON
+// Response: 200 TokensResponse (GET), 201 MintTokenResponse (POST),
+// 200 TokenView of the revoked token (DELETE)
+// Errors:
+//   - 400 for invalid JSON, name, scope, or ttl, or too many tokens
+//   - 403 when the caller's scope is not admin
+//   - 404 when DELETE names an unknown token
+//   - 409 when POST names an existing token, or DELETE a config token
+//   - 500 when the token list cannot be persisted
+//   - 503 when token management is not configured
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if requestScope(r.Context()) != ScopeAdmin {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     "token management requires admin scope",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	store := s.opts.Tokens
+	if store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "token management not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, FromTokens(store.List()))
+	case http.MethodPost:
+		s.mintToken(w, r, store)
+	case http.MethodDelete:
+		t, err := store.Revoke(r.URL.Query().Get("name"))
+		if err != nil {
+			code := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, tokens.ErrNotFound):
+				code = http.StatusNotFound
+			case errors.Is(err, tokens.ErrConfigured):
+				code = http.StatusConflict
+			}
+			writeJSON(w, code, APIError{
+				Error:     err.Error(),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		s.recordEvent(r.Context(), core.EventOrchestration, "api token revoked", map[string]string{
+			"name": t.Name,
+		})
+		writeJSON(w, http.StatusOK, FromToken(t))
+	}
+}
+
+func (s *Server) mintToken(w http.ResponseWriter, r *http.Request, store *tokens.Store) {
+	var req TokenRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	scope := Scope(req.Scope)
+	if scope == "" {
+		scope = ScopeReadOnly
+	}
+	ttl := tokens.DefaultTTL
+	if req.TTLSec != 0 {
+		ttl = time.Duration(req.TTLSec) * time.Second
+	}
+	var verr error
+	switch {
+	case scope != ScopeAdmin && scope != ScopeOperate && scope != ScopeReadOnly:
+		verr = errors.New(`scope must be "admin", "operate", or "read"`)
+	case req.TTLSec < 0 || req.TTLSec > int64(tokens.MaxTTL/time.Second):
+		verr = errors.New("ttl_sec must be between 1 and " + strconv.FormatInt(int64(tokens.MaxTTL/time.Second), 10))
+	default:
+		verr = tokens.ValidName(req.Name)
+	}
+	if verr != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     verr.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	secret, t, err := store.Mint(req.Name, string(scope), ttl)
+	if err != nil {
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, tokens.ErrExists):
+			code = http.StatusConflict
+		case errors.Is(err, tokens.ErrFull):
+			code = http.StatusBadRequest
+		}
+		writeJSON(w, code, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	s.recordEvent(r.Context(), core.EventOrchestration, "api token minted", map[string]string{
+		"name":       t.Name,
+		"scope":      t.Scope,
+		"expires_at": t.ExpiresAt.Format(time.RFC3339),
+	})
+	writeJSON(w, http.StatusCreated, MintTokenResponse{Token: FromToken(t), Secret: secret})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/tun2socks"
+)
+
+// defaultTun2SocksTail is how many lines GET /v1/tun2socks/logs returns
+// without "tail".
+const defaultTun2SocksTail = 200
+
+// handleTun2SocksLogs returns the captured output of the tun2socks process.
+// Method: GET
+// Query: tail (lines, 1 to the buffer capacity, default 200), errors
+// (true returns error lines only)
+// Response (200): Tun2SocksLogsResponse JSON, oldest line first
+// Errors:
+//   - 400 for an invalid tail
+//   - 503 when output capture is not configured
+func (s *Server) handleTun2SocksLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	buf := s.opts.Tun2SocksLogs
+	if buf == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "tun2socks output capture not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	capacity, total, dropped := buf.Stats()
+	q := r.URL.Query()
+	tail := min(defaultTun2SocksTail, capacity)
+	if v := q.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > capacity {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "tail must be an integer between 1 and " + strconv.Itoa(capacity),
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		tail = n
+	}
+	lines := buf.Tail(0)
+	if q.Get("errors") == "true" {
+		kept := lines[:0]
+		for _, l := range lines {
+			if l.Error {
+				kept = append(kept, l)
+			}
+		}
+		lines = kept
+	}
+	if len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+	writeJSON(w, http.StatusOK, FromTun2SocksLogs(lines, capacity, total, dropped))
+}
+
+// startEngine returns the engine for a start request: a WireGuard
+// upstream selects ServerOptions.WireGuard; otherwise an empty engine or
+// the configured engine's name selects ServerOptions.Tun2Socks, and
+// "embedded" the in-process engine.
+func (s *Server) startEngine(req StartRequest) (tun2socks.Engine, error) {
+	name := req.Engine
+	if req.WireGuard != nil {
+		switch {
+		case name != "" && name != tun2socks.EngineWireGuard:
+			return nil, fmt.Errorf("engine %q: a wireguard upstream runs on the %s engine", name, tun2socks.EngineWireGuard)
+		case s.opts.WireGuard == nil:
+			return nil, errors.New("engine: wireguard engine not available in this agent")
+		}
+		return s.opts.WireGuard, nil
+	}
+	switch {
+	case name == tun2socks.EngineWireGuard:
+		return nil, errors.New("engine \"wireguard\" needs a wireguard upstream instead of socks_server")
+	case name == "":
+		return s.opts.Tun2Socks, nil
+	case name == tun2socks.EngineEmbedded:
+		if s.opts.Embedded == nil {
+			return nil, errors.New("engine: embedded engine not available in this agent (build with -tags netstack on linux)")
+		}
+		return s.opts.Embedded, nil
+	case s.opts.Tun2Socks != nil && name == s.opts.Tun2Socks.Name():
+		return s.opts.Tun2Socks, nil
+	}
+	want := []string{tun2socks.EngineEmbedded}
+	if s.opts.Tun2Socks != nil {
+		want = append([]string{s.opts.Tun2Socks.Name()}, want...)
+	}
+	return nil, fmt.Errorf("engine %q: want empty or one of %v", name, want)
+}
@@ -8,41 +8,131 @@ import "time"
 
 // StatusResponse is the top-level payload for GET /v1/status.
 type StatusResponse struct {
-	State       string        `json:"state"`
-	StartedAt   string        `json:"started_at"`
-	UptimeSec   int64         `json:"uptime_sec"`
-	Warnings    []string      `json:"warnings"`
-	TUN         TUNView       `json:"tun"`
-	Routes      RoutesView    `json:"routes"`
-	Tun2Socks   Tun2SocksView `json:"tun2socks"`
-	LastProbe   ProbeView     `json:"last_probe"`
-	GeneratedAt string        `json:"generated_at"`
+	Rev              uint64          `json:"rev"` // state revision; see GET /v1/status/diff
+	State            string          `json:"state"`
+	StartedAt        string          `json:"started_at"`
+	StartedAtLocal   string          `json:"started_at_local,omitempty"` // set with ?tz= or a default display tz
+	UptimeSec        int64           `json:"uptime_sec"`
+	UptimeHuman      string          `json:"uptime_human,omitempty"` // set with ?humanize=true
+	Warnings         []string        `json:"warnings"`               // messages of WarningsV2, for older clients
+	WarningsV2       []WarningView   `json:"warnings_v2"`            // structured; same order as warnings
+	TUN              TUNView         `json:"tun"`
+	Routes           RoutesView      `json:"routes"`
+	Tun2Socks        Tun2SocksView   `json:"tun2socks"`
+	DNS              DNSView         `json:"dns"`
+	LastProbe        ProbeView       `json:"last_probe"`
+	Health           HealthView      `json:"health"`
+	Subsystems       []SubsystemView `json:"subsystems"` // sorted by name
+	GeneratedAt      string          `json:"generated_at"`
+	GeneratedAtLocal string          `json:"generated_at_local,omitempty"`
+	TZ               string          `json:"tz,omitempty"` // IANA name used for *_local fields
+}
+
+// HealthView is the damped proxy health: unknown, ok, or degraded. It
+// changes only after consecutive probe failures or successes (see
+// docs/api.md), so a single lost probe does not flip it. Pending is true
+// while the latest probe disagrees with Status.
+type HealthView struct {
+	Status               string `json:"status"`
+	Since                string `json:"since,omitempty"`
+	SinceLocal           string `json:"since_local,omitempty"`
+	ConsecutiveFailures  int    `json:"consecutive_failures"`
+	ConsecutiveSuccesses int    `json:"consecutive_successes"`
+	Pending              bool   `json:"pending"`
+
+	// Score is 0-100 (100 is best) from the parts in ScoreParts, each 0-1;
+	// absent while no part has data. See core.ScoreHealth.
+	Score      *int               `json:"score,omitempty"`
+	ScoreParts map[string]float64 `json:"score_parts,omitempty"`
+}
+
+// SubsystemView reports one optional subsystem. Status is ok, degraded
+// (running on a fallback), failed, or disabled.
+type SubsystemView struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail"`
+	Since      string `json:"since"`
+	SinceLocal string `json:"since_local,omitempty"`
 }
 
 // TUNView describes the current view of the TUN interface.
 type TUNView struct {
-	Name    string `json:"name"`
-	Up      bool   `json:"up"`
-	MTU     int    `json:"mtu"`
-	LocalIP string `json:"local_ip"`
-	PeerIP  string `json:"peer_ip"`
+	Name     string `json:"name"`
+	Up       bool   `json:"up"`
+	MTU      int    `json:"mtu"`
+	LocalIP  string `json:"local_ip"`
+	PeerIP   string `json:"peer_ip"`
+	LocalIP6 string `json:"local_ip6"`
+	Subnet   string `json:"subnet,omitempty"` // IPv4 TUN subnet; set in plans
 }
 
 // RoutesView summarizes the routing decisions.
 type RoutesView struct {
-	DefaultVia      string   `json:"default_via"`
-	LanCIDRs        []string `json:"lan_cidrs"`
-	BypassHosts     []string `json:"bypass_hosts"`
-	ProxyHostRoute  bool     `json:"proxy_host_route"`
-	OriginalGateway string   `json:"original_gateway"`
+	DefaultVia       string   `json:"default_via"`
+	LanCIDRs         []string `json:"lan_cidrs"`
+	IncludeCIDRs     []string `json:"include_cidrs"` // split tunnel; empty means full tunnel
+	ExcludeCIDRs     []string `json:"exclude_cidrs"`
+	BypassHosts      []string `json:"bypass_hosts"`
+	ProxyHostRoute   bool     `json:"proxy_host_route"`
+	ProxyAddrs       []string `json:"proxy_addrs,omitempty"` // addresses the proxy host routes pin
+	OriginalGateway  string   `json:"original_gateway"`
+	OriginalGateway6 string   `json:"original_gateway6"`
 }
 
 // Tun2SocksView summarizes the supervised tun2socks process.
 type Tun2SocksView struct {
-	PID       int   `json:"pid"`
-	UptimeSec int64 `json:"uptime_sec"`
-	TCPOk     bool  `json:"tcp_ok"`
-	UDPOk     bool  `json:"udp_ok"`
+	PID         int    `json:"pid"`
+	UptimeSec   int64  `json:"uptime_sec"`
+	UptimeHuman string `json:"uptime_human,omitempty"` // set with ?humanize=true
+	TCPOk       bool   `json:"tcp_ok"`
+	UDPOk       bool   `json:"udp_ok"`
+	// UDPUnsupported is set when UDP was requested but the upstream
+	// cannot carry it (proxy_type "http"); udp_ok is then false.
+	UDPUnsupported bool `json:"udp_unsupported,omitempty"`
+	// LastErrors is the process's recent error output while a health
+	// check fails; see GET /v1/tun2socks/logs for the full output.
+	LastErrors []string `json:"last_errors,omitempty"`
+	// Traffic is omitted while the TUN device is not measured.
+	Traffic *TrafficView `json:"traffic,omitempty"`
+}
+
+// TrafficView counts the TUN device's traffic since it was created. Out
+// is upload (host into the tunnel), in is download; rates are bytes per
+// second over the last few seconds.
+type TrafficView struct {
+	BytesIn      uint64 `json:"bytes_in"`
+	BytesOut     uint64 `json:"bytes_out"`
+	PacketsIn    uint64 `json:"packets_in"`
+	PacketsOut   uint64 `json:"packets_out"`
+	RateInBps    int64  `json:"rate_in_bps"`
+	RateOutBps   int64  `json:"rate_out_bps"`
+	RateInHuman  string `json:"rate_in_human,omitempty"` // set with ?humanize=true
+	RateOutHuman string `json:"rate_out_human,omitempty"`
+}
+
+// DNSView reports the local DNS forwarder and the system resolvers.
+// Listen is empty while the forwarder is not running; OriginalResolvers is
+// empty unless the agent rewrote the system resolvers.
+type DNSView struct {
+	Listen            string   `json:"listen"`
+	Upstream          string   `json:"upstream"`
+	Resolvers         []string `json:"resolvers"`
+	OriginalResolvers []string `json:"original_resolvers"`
+	Rewritten         bool     `json:"rewritten"`
+}
+
+// WarningView is a non-fatal anomaly in the state or a probe result.
+type WarningView struct {
+	Code     string `json:"code,omitempty"` // stable cause, e.g. tcp_connect_failed; absent when unclassified
+	Severity string `json:"severity"`       // info, warning, error
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+	Time     string `json:"time,omitempty"` // RFC 3339, latest occurrence
+	// Count and FirstTime are set on state warnings: how often the
+	// warning was raised and when it first was.
+	Count     int    `json:"count,omitempty"`
+	FirstTime string `json:"first_time,omitempty"`
 }
 
 // ProbeView summarizes the last proxy probe.
@@ -51,23 +141,121 @@ type ProbeView struct {
 	SocksOK     bool             `json:"socks_ok"`
 	ConnectOK   bool             `json:"connect_ok"`
 	UDPOK       bool             `json:"udp_ok"`
-	LatenciesMs map[string]int64 `json:"latencies_ms"`
+	LatenciesMs map[string]int64 `json:"latencies_ms"` // truncated to whole ms
+	LatenciesUs map[string]int64 `json:"latencies_us"` // same steps in µs, for sub-ms local handshakes
 	Features    ProxyFeatures    `json:"features"`
 	LastChecked string           `json:"last_checked"`
-	Warnings    []string         `json:"warnings"`
+	Warnings    []string         `json:"warnings"`    // messages of WarningsV2, for older clients
+	WarningsV2  []WarningView    `json:"warnings_v2"` // structured; same order as warnings
+	// Hops reports each proxy of a chain or HTTP proxy probe, entry
+	// first; absent for a single SOCKS5 proxy.
+	Hops []ProbeHopView `json:"hops,omitempty"`
+	// LatencyPercentiles smooths latencies per step over the last 100
+	// successful SOCKS5 probes; absent before the first.
+	LatencyPercentiles map[string]LatencyPercentilesView `json:"latency_percentiles,omitempty"`
+	// Trace is the bytes exchanged with the proxy; only in the response of
+	// a probe run with "trace": true.
+	Trace []ProbeTraceView `json:"trace,omitempty"`
+	// Targets reports each connect target of a multi-target probe by
+	// "host:port", connect_target included; absent for a single target.
+	Targets map[string]ProbeTargetView `json:"targets,omitempty"`
+	// TLS is the TLS handshake with connect_target through the proxy;
+	// absent unless the probe asked for one.
+	TLS *ProbeTLSView `json:"tls,omitempty"`
+	// HTTP is the GET of a canary URL through the proxy; absent unless the
+	// probe asked for one.
+	HTTP *ProbeHTTPView `json:"http,omitempty"`
+
+	LastCheckedLocal string `json:"last_checked_local,omitempty"` // set with ?tz= or a default display tz
+}
+
+// ProbeHTTPView is the HTTP check of a probe.
+type ProbeHTTPView struct {
+	URL         string `json:"url"`
+	OK          bool   `json:"ok"`               // status and body as expected
+	Status      int    `json:"status,omitempty"` // absent when no response was read
+	BodyBytes   int    `json:"body_bytes"`
+	BodyMatched *bool  `json:"body_matched,omitempty"` // absent without expect_body
+	Error       string `json:"error,omitempty"`
+}
+
+// ProbeTLSView is the TLS check of a probe. ok with verified false means
+// TLS passes through the proxy but the certificate does not check out for
+// server_name, as behind an intercepting middlebox.
+type ProbeTLSView struct {
+	ServerName    string `json:"server_name"`
+	OK            bool   `json:"ok"` // handshake completed
+	Error         string `json:"error,omitempty"`
+	Version       string `json:"version,omitempty"` // e.g. "TLS 1.3"
+	CipherSuite   string `json:"cipher_suite,omitempty"`
+	ALPN          string `json:"alpn,omitempty"`
+	Verified      bool   `json:"verified"` // chain valid for server_name against system roots
+	VerifyError   string `json:"verify_error,omitempty"`
+	Subject       string `json:"subject,omitempty"`
+	Issuer        string `json:"issuer,omitempty"`
+	NotAfter      string `json:"not_after,omitempty"`       // RFC3339
+	ExpiresInDays *int   `json:"expires_in_days,omitempty"` // negative once expired
+	ChainLen      int    `json:"chain_len,omitempty"`
+}
+
+// ProbeTargetView is one connect target of a multi-target probe.
+type ProbeTargetView struct {
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"` // CONNECT step
+	LatencyUs int64  `json:"latency_us"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ProbeTraceView is one message of a probe trace: consecutive bytes sent
+// to ("send") or received from ("recv") the proxy. Passwords and Basic
+// credentials show as "**" in hex and '*' in text.
+type ProbeTraceView struct {
+	Hop      int    `json:"hop,omitempty"` // proxy of a chain, from 1
+	Dir      string `json:"dir"`
+	OffsetUs int64  `json:"offset_us"` // since the TCP connect completed
+	Len      int    `json:"len"`
+	Hex      string `json:"hex"`  // e.g. "05 01 00"
+	Text     string `json:"text"` // printable ASCII, other bytes as '.'
+}
+
+// LatencyPercentilesView is one probe step's latency distribution.
+type LatencyPercentilesView struct {
+	P50Us   int64 `json:"p50_us"`
+	P90Us   int64 `json:"p90_us"`
+	P99Us   int64 `json:"p99_us"`
+	Samples int   `json:"samples"`
+}
+
+// ProbeHopView is one proxy of a probed chain. A hop is ok once it
+// accepted the handshake and the CONNECT to the next hop (the last: to
+// connect_target); hops after a failure are not reached.
+type ProbeHopView struct {
+	Server    string `json:"server"`
+	ProxyType string `json:"proxy_type"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"` // handshake plus CONNECT
+	LatencyUs int64  `json:"latency_us"`
+	Error     string `json:"error,omitempty"`
 }
 
 // ProxyFeatures reports discovered capabilities.
 type ProxyFeatures struct {
-	Auth string `json:"auth"` // "none" or "userpass"
-	IPv6 bool   `json:"ipv6"`
-	UDP  bool   `json:"udp"`
+	Auth    string `json:"auth"` // "none" or "userpass"
+	IPv6    bool   `json:"ipv6"`
+	UDP     bool   `json:"udp"`
+	PathMTU int    `json:"path_mtu,omitempty"` // toward the proxy; absent when unknown
+	Family  string `json:"family,omitempty"`   // "ipv4" or "ipv6": how the proxy was reached
 }
 
-// APIError is a standard error payload.
+// APIError is a standard error payload. Code is one of the Code constants
+// and is filled from the status when a handler leaves it empty; Details
+// carries code-specific context such as the failed step.
 type APIError struct {
-	Error     string `json:"error"`
-	Timestamp string `json:"timestamp"` // RFC3339
+	Error     string            `json:"error"`
+	Code      string            `json:"code"`
+	Details   map[string]string `json:"details,omitempty"`
+	RequestID string            `json:"request_id,omitempty"` // as in the X-Request-ID header
+	Timestamp string            `json:"timestamp"`            // RFC3339
 }
 
 // TimeNow abstracts time for tests; overridden in tests.
@@ -79,15 +267,101 @@ var TimeNow = func() time.Time { return time.Now() }
 // SocksServer is the upstream SOCKS5 proxy endpoint ("host:port").
 // TimeoutMS bounds the entire probe (0 = server default).
 // Auth holds optional credentials for proxies that require user/pass.
+// AuthRef names credentials in the agent's secret store instead of Auth.
 // ConnectTarget is the target used for the CONNECT test ("host:port").
 // Empty uses a sensible default.
 // UDPTest requests a minimal UDP ASSOCIATE exchange.
+// Type selects a registered probe (default "socks5"; see GET /v1/probe/types).
+// Target and Options address non-SOCKS probes; for socks5, Target is an
+// alternative to SocksServer and Options merge under the typed fields.
 type ProbeRequest struct {
-	SocksServer   string     `json:"socks_server"`
-	TimeoutMS     int        `json:"timeout_ms"`
-	Auth          *ProbeAuth `json:"auth,omitempty"`
-	ConnectTarget string     `json:"connect_target"`
-	UDPTest       bool       `json:"udp_test"`
+	SocksServer   string            `json:"socks_server"`
+	TimeoutMS     int               `json:"timeout_ms"`
+	Auth          *ProbeAuth        `json:"auth,omitempty"`
+	AuthRef       string            `json:"auth_ref,omitempty"`
+	ConnectTarget string            `json:"connect_target"`
+	UDPTest       bool              `json:"udp_test"`
+	Type          string            `json:"type,omitempty"`
+	Target        string            `json:"target,omitempty"`
+	Options       map[string]string `json:"options,omitempty"`
+
+	// ProxyType and Chain are as in StartRequest; the probe checks every
+	// hop and reports each in ProbeView.Hops.
+	ProxyType string     `json:"proxy_type,omitempty"`
+	Chain     []ProxyHop `json:"chain,omitempty"`
+
+	// Trace returns the bytes exchanged with the proxy in ProbeView.Trace
+	// (socks5 only).
+	Trace bool `json:"trace,omitempty"`
+	// ConnectTargets lists more destinations to CONNECT to through the
+	// proxy, each over its own handshake; results are in ProbeView.Targets.
+	ConnectTargets []string `json:"connect_targets,omitempty"`
+	// TLS asks for a TLS handshake with connect_target through the proxy
+	// after CONNECT; the result is in ProbeView.TLS.
+	TLS *ProbeTLSRequest `json:"tls,omitempty"`
+	// HTTP asks for a GET of a canary URL through the proxy after CONNECT;
+	// the result is in ProbeView.HTTP.
+	HTTP *ProbeHTTPRequest `json:"http,omitempty"`
+}
+
+// ProbeHTTPRequest configures the HTTP check of a probe. The URL's host
+// and port become connect_target.
+type ProbeHTTPRequest struct {
+	URL          string `json:"url"`                     // http:// or https://
+	ExpectStatus int    `json:"expect_status,omitempty"` // default: any 2xx
+	ExpectBody   string `json:"expect_body,omitempty"`   // substring of the first 64 KiB
+}
+
+// ProbeTLSRequest configures the TLS check of a probe.
+type ProbeTLSRequest struct {
+	ServerName string   `json:"server_name,omitempty"` // SNI and name to verify; default the target host
+	ALPN       []string `json:"alpn,omitempty"`        // e.g. ["h2", "http/1.1"]
+}
+
+// ProxyHop is a proxy of a chain, reached through the previous one.
+type ProxyHop struct {
+	Server    string     `json:"server"`               // "host:port"
+	ProxyType string     `json:"proxy_type,omitempty"` // "socks5" (default) or "http"
+	Auth      *ProbeAuth `json:"auth,omitempty"`
+	AuthRef   string     `json:"auth_ref,omitempty"`
+}
+
+// ProbeTypesResponse is returned by GET /v1/probe/types.
+type ProbeTypesResponse struct {
+	Types []ProbeTypeView `json:"types"` // sorted by name
+}
+
+// ProbeTypeView describes one registered probe.
+type ProbeTypeView struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ActiveProbesResponse is returned by GET /v1/probe/active: the probes and
+// health sweeps in flight, oldest first.
+type ActiveProbesResponse struct {
+	Probes      []ActiveProbeView `json:"probes"`
+	GeneratedAt string            `json:"generated_at"`
+}
+
+// ProbeCancelResponse is returned by DELETE /v1/probe/active.
+type ProbeCancelResponse struct {
+	Canceled    []ActiveProbeView `json:"canceled"`
+	GeneratedAt string            `json:"generated_at"`
+}
+
+// ActiveProbeView is one probe or health sweep in flight. Canceled is set
+// once it was canceled and is winding down.
+type ActiveProbeView struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`           // "probe" or "healthcheck"
+	Type      string `json:"type,omitempty"` // probe type; absent for sweeps
+	Target    string `json:"target,omitempty"`
+	Client    string `json:"client"`
+	RequestID string `json:"request_id,omitempty"`
+	StartedAt string `json:"started_at"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+	Canceled  bool   `json:"canceled,omitempty"`
 }
 
 // ProbeAuth captures optional SOCKS5 username/password credentials.
@@ -100,40 +374,1107 @@ type ProbeAuth struct {
 //
 // SocksServer is the upstream SOCKS5 proxy endpoint ("host:port")
 // Auth holds optional credentials for proxies that require user/pass.
-// MTU to set for the TUN interface. If 0, default will be user (e.g., 1500)
+// AuthRef names credentials in the agent's secret store instead of Auth.
+// MTU to set for the TUN interface. If 0, it is tuned to the path to the
+// proxy (see routeplan.ChooseMTU); StartResponse.MTU says how.
 // ConnectTarget used for initial end-to-end verification via CONNECT ("host:port")
 // Empty uses a sensible default.
 // BypassHosts will be routed outside the TUN (e.g., proxy host, LAN router).
 // DryRun performs discovery/probes and reports the plan without making changes.
+// DisableLANDetect skips LAN auto-detection; only BypassHosts are bypassed.
+// IPv6 requests dual-stack routing (used only if the last probe reported
+// IPv6 support through the proxy).
+// Profile names a stored profile (see /v1/profiles) that fills the fields
+// the request leaves empty.
+// DNSUpstreams replace the DNS forwarder's configured upstreams for this
+// start; DisableDNS leaves the forwarder off.
 type StartRequest struct {
+	Profile string `json:"profile,omitempty"`
+
 	SocksServer   string     `json:"socks_server"`
 	Auth          *ProbeAuth `json:"auth,omitempty"`
+	AuthRef       string     `json:"auth_ref,omitempty"`
 	MTU           int        `json:"mtu,omitempty"`
 	ConnectTarget string     `json:"connect_target"`
 	UDP           bool       `json:"udp"`
 	BypassHosts   []string   `json:"bypass_hosts"`
 	DryRun        bool       `json:"dry_run"`
+
+	// ProxyType is "socks5" (default) or "http" for an HTTP CONNECT
+	// proxy at SocksServer. HTTP carries TCP only; UDP is reported
+	// unsupported in status.
+	ProxyType string `json:"proxy_type,omitempty"`
+
+	// Chain lists proxies reached through SocksServer, in order;
+	// destinations are connected through the last. Only the embedded
+	// engine chains, and UDP is reported unsupported.
+	Chain []ProxyHop `json:"chain,omitempty"`
+
+	// IncludeCIDRs, when set, tunnels only these destinations (split
+	// tunnel); ExcludeCIDRs keeps destinations outside the TUN.
+	IncludeCIDRs []string `json:"include_cidrs,omitempty"`
+	ExcludeCIDRs []string `json:"exclude_cidrs,omitempty"`
+
+	// OutboundInterfaces pins the proxy connection and bypass routes to
+	// the first listed interface that has a default route (fallback order,
+	// e.g. ["en7", "en0"]). Empty uses the agent's configured order.
+	OutboundInterfaces []string `json:"outbound_interfaces,omitempty"`
+
+	DisableLANDetect bool `json:"disable_lan_detect,omitempty"`
+	IPv6             bool `json:"ipv6,omitempty"`
+
+	// TUNAddress replaces the default TUN addressing (10.255.0.1/30) with
+	// an IPv4 address and subnet, e.g. "10.77.0.1/30"; TUNPeer is the
+	// point-to-point peer in that subnet (empty: the next host address).
+	// A subnet overlapping a live network refuses the start.
+	TUNAddress string `json:"tun_address,omitempty"`
+	TUNPeer    string `json:"tun_peer,omitempty"`
+
+	DNSUpstreams []string `json:"dns_upstreams,omitempty"`
+	DisableDNS   bool     `json:"disable_dns,omitempty"`
+
+	// Engine selects the tun2socks engine: empty uses the configured
+	// one, "embedded" the in-process stack (no external binary).
+	Engine string `json:"engine,omitempty"`
+
+	// WireGuard, instead of SocksServer, routes the TUN through a
+	// userspace WireGuard tunnel (engine "wireguard").
+	WireGuard *WireGuardUpstream `json:"wireguard,omitempty"`
+
+	// Async answers 202 with an operation ID instead of waiting; progress
+	// is at GET /v1/operations/{id}.
+	Async bool `json:"async,omitempty"`
+}
+
+// WireGuardUpstream is a WireGuard peer used as the upstream. Keys are
+// base64 as printed by wg(8); Address is this host's tunnel address from
+// the peer's operator. KeepaliveSec holds NAT mappings open and keeps
+// health current while idle.
+type WireGuardUpstream struct {
+	Endpoint     string `json:"endpoint"`   // peer "host:port"
+	PublicKey    string `json:"public_key"` // the peer's
+	PrivateKey   string `json:"private_key"`
+	PresharedKey string `json:"preshared_key,omitempty"`
+	Address      string `json:"address"` // e.g. "10.8.0.2" or "10.8.0.2/32"
+	KeepaliveSec int    `json:"keepalive_sec,omitempty"`
 }
 
 // StartResponse summarizes the orchestration result and current state snapshot.
+// BypassHosts is the validated, normalized form of StartRequest.BypassHosts.
+// Steps lists the orchestration steps in order; when one fails the response
+// is sent with the error status, Error set, and the steps reached so far.
 type StartResponse struct {
-	State       string        `json:"state"`
-	Warnings    []string      `json:"warnings"`
-	TUN         TUNView       `json:"tun"`
-	Routes      RoutesView    `json:"routes"`
-	Tun2Socks   Tun2SocksView `json:"tun2socks"`
-	GeneratedAt string        `json:"generated_at"`
+	OperationID string              `json:"operation_id,omitempty"` // absent for dry runs
+	State       string              `json:"state"`
+	Warnings    []string            `json:"warnings"`
+	TUN         TUNView             `json:"tun"`
+	Routes      RoutesView          `json:"routes"`
+	Tun2Socks   Tun2SocksView       `json:"tun2socks"`
+	BypassHosts []BypassHostView    `json:"bypass_hosts"`
+	MTU         MTUView             `json:"mtu"` // TUN MTU and why it was chosen
+	Plan        *PlanView           `json:"plan,omitempty"`
+	Steps       []OperationStepView `json:"steps,omitempty"` // absent for dry runs
+	Error       string              `json:"error,omitempty"` // set when a step failed
+	Code        string              `json:"code,omitempty"`  // error code when a step failed, e.g. ERR_ROUTE_APPLY
+	GeneratedAt string              `json:"generated_at"`
+}
+
+// MTUView is the TUN MTU a start uses and why.
+type MTUView struct {
+	MTU    int    `json:"mtu"`
+	Source string `json:"source"` // request, path, interface, or default
+	Reason string `json:"reason"`
+}
+
+// PlanView is the route plan computed for a start request (see package
+// routeplan). Routes are applied in order; Restore is applied on stop.
+type PlanView struct {
+	TUN     TUNView         `json:"tun"`
+	IPv6    bool            `json:"ipv6"`
+	Split   bool            `json:"split"` // only include_cidrs use the TUN
+	Routes  []PlanRouteView `json:"routes"`
+	Restore []PlanRouteView `json:"restore"`
+	Uplink  PlanUplinkView  `json:"uplink"`
+	DNS     *PlanDNSView    `json:"dns,omitempty"` // set when the DNS forwarder is configured
+	// Tun2Socks is the engine that will bridge the TUN to the proxy; set
+	// when one is configured.
+	Tun2Socks *PlanTun2SocksView `json:"tun2socks,omitempty"`
+}
+
+// PlanTun2SocksView is the planned tun2socks engine and, for external
+// binaries, its command line with credentials masked.
+type PlanTun2SocksView struct {
+	Engine  string   `json:"engine"`
+	Command []string `json:"command,omitempty"`
+}
+
+// PlanUplinkView is the egress used by pinned routes (proxy, bypass,
+// exclude). Preferred is false when no outbound_interfaces entry had a
+// default route and the system default was kept.
+type PlanUplinkView struct {
+	Interface string `json:"interface"`
+	Gateway   string `json:"gateway"`
+	Gateway6  string `json:"gateway6,omitempty"`
+	Preferred bool   `json:"preferred"`
+}
+
+// PlanDNSView is the planned DNS forwarder: where it listens and whether
+// the system resolvers will be pointed at it.
+type PlanDNSView struct {
+	Listen           string `json:"listen"`
+	Upstream         string `json:"upstream"`
+	RewriteResolvers bool   `json:"rewrite_resolvers"`
+}
+
+// PlanRouteView is one planned route. Via is empty for on-link routes.
+type PlanRouteView struct {
+	Dst    string `json:"dst"`
+	Via    string `json:"via"`
+	Dev    string `json:"dev"`
+	Reason string `json:"reason"` // default, proxy, bypass, lan
+}
+
+// BypassHostView is one normalized bypass entry.
+// Kind is "ip", "cidr", or "hostname"; Prefixes are masked CIDRs
+// (host routes use /32 or /128), resolved once for hostnames.
+type BypassHostView struct {
+	Input    string   `json:"input"`
+	Kind     string   `json:"kind"`
+	Prefixes []string `json:"prefixes"`
 }
 
 // StopRequest tears down orchestration and restores original routes.
 type StopRequest struct {
 	// Force skips graceful shutdown of tun2socks and proceeds with teardown.
 	Force bool `json:"force"`
+	// Async answers 202 with an operation ID instead of waiting.
+	Async bool `json:"async,omitempty"`
 }
 
-// StopResponse provides a summary after teardown.
+// StopResponse provides a summary after teardown. Steps and Error behave
+// as in StartResponse.
 type StopResponse struct {
-	State       string   `json:"state"`
+	OperationID string              `json:"operation_id"`
+	State       string              `json:"state"`
+	Warnings    []string            `json:"warnings"`
+	Steps       []OperationStepView `json:"steps"`
+	Error       string              `json:"error,omitempty"` // set when a step failed
+	Code        string              `json:"code,omitempty"`  // error code when a step failed
+	GeneratedAt string              `json:"generated_at"`
+}
+
+// PauseRequest is the optional payload for POST /v1/pause and /v1/resume.
+type PauseRequest struct {
+	// Async answers 202 with an operation ID instead of waiting.
+	Async bool `json:"async,omitempty"`
+}
+
+// PauseResponse summarizes a pause or resume. Steps and Error behave as in
+// StartResponse.
+type PauseResponse struct {
+	OperationID string              `json:"operation_id"`
+	State       string              `json:"state"`
+	Warnings    []string            `json:"warnings"`
+	Steps       []OperationStepView `json:"steps"`
+	Error       string              `json:"error,omitempty"` // set when a step failed
+	Code        string              `json:"code,omitempty"`  // error code when a step failed
+	GeneratedAt string              `json:"generated_at"`
+}
+
+// WarningsClearRequest is the optional payload for POST /v1/warnings/clear.
+// Empty fields match every warning, so an empty body clears them all.
+type WarningsClearRequest struct {
+	Codes       []string `json:"codes,omitempty"`        // clear only these codes
+	Source      string   `json:"source,omitempty"`       // clear only warnings from this source
+	MaxSeverity string   `json:"max_severity,omitempty"` // clear only up to this severity: info, warning, or error
+}
+
+// WarningsClearResponse reports a POST /v1/warnings/clear.
+type WarningsClearResponse struct {
+	Cleared     int           `json:"cleared"`
+	Warnings    []string      `json:"warnings"`    // what remains, as in /v1/status
+	WarningsV2  []WarningView `json:"warnings_v2"` // what remains, structured
+	GeneratedAt string        `json:"generated_at"`
+}
+
+// RoutesResponse is the body of GET /v1/routes: the recorded routes and how
+// the host's routing table matches them.
+type RoutesResponse struct {
+	State    string           `json:"state"`
+	Planned  RoutesView       `json:"planned"`
+	Verified bool             `json:"verified"`        // false when the platform cannot look routes up
+	Error    string           `json:"error,omitempty"` // why verification did not run
+	Drift    bool             `json:"drift"`           // some entry is not ok
+	Entries  []RouteCheckView `json:"entries"`
+	// CheckedAt is when the routing table was read.
+	CheckedAt string `json:"checked_at"`
+}
+
+// RouteCheckView is one verified route expectation.
+type RouteCheckView struct {
+	Kind    string `json:"kind"`           // default, default6, proxy, bypass, lan, include, exclude
+	Target  string `json:"target"`         // prefix checked
+	Host    string `json:"host,omitempty"` // bypass hostname the target was resolved from
+	Expect  string `json:"expect"`         // tun or outside
+	Status  string `json:"status"`         // ok, missing, or conflict
+	Detail  string `json:"detail"`
+	Matched string `json:"matched,omitempty"` // prefix of the route the host uses
+	Via     string `json:"via,omitempty"`     // its gateway
+	Dev     string `json:"dev,omitempty"`     // its interface
+}
+
+// ShutdownRequest is the optional payload for POST /v1/shutdown.
+type ShutdownRequest struct {
+	Reason string `json:"reason,omitempty"` // recorded in the shutdown report
+}
+
+// ShutdownResponse acknowledges POST /v1/shutdown; teardown starts after
+// it is sent.
+type ShutdownResponse struct {
+	Accepted    bool   `json:"accepted"`
+	Reason      string `json:"reason"` // as it will appear in the shutdown report
+	GeneratedAt string `json:"generated_at"`
+}
+
+// UpgradeRequest is the optional body of POST /v1/upgrade.
+type UpgradeRequest struct {
+	SHA256 string `json:"sha256,omitempty"` // hex digest the new executable must have
+}
+
+// UpgradeResponse acknowledges POST /v1/upgrade: the new agent serves the
+// listeners and this one is shutting down.
+type UpgradeResponse struct {
+	Accepted    bool   `json:"accepted"`
+	PID         int    `json:"pid"` // the new agent
+	GeneratedAt string `json:"generated_at"`
+}
+
+// OperationView is a start, stop, pause, or resume with per-step progress,
+// from
+// GET /v1/operations/{id}.
+type OperationView struct {
+	ID         string              `json:"id"`
+	Kind       string              `json:"kind"`   // start, stop, pause, or resume
+	Client     string              `json:"client"` // as in GET /v1/clients
+	Async      bool                `json:"async"`
+	Status     string              `json:"status"` // running, succeeded, failed
+	StartedAt  string              `json:"started_at"`
+	FinishedAt string              `json:"finished_at,omitempty"`
+	ElapsedMS  int64               `json:"elapsed_ms"` // so far, or in total once finished
+	Steps      []OperationStepView `json:"steps"`
+	Error      string              `json:"error,omitempty"`
+	State      string              `json:"state,omitempty"` // agent state when it finished
+}
+
+// OperationStepView is one orchestration step, in execution order.
+type OperationStepView struct {
+	Name       string `json:"name"`   // e.g. tun_created, routes_applied, tun2socks_started, verified
+	Status     string `json:"status"` // pending, running, succeeded, failed, skipped
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OperationAccepted is the 202 body of an async POST /v1/start, /v1/stop,
+// /v1/pause, or /v1/resume.
+type OperationAccepted struct {
+	OperationID string `json:"operation_id"`
+	Kind        string `json:"kind"`
+	StatusURL   string `json:"status_url"` // GET for progress
+	GeneratedAt string `json:"generated_at"`
+}
+
+// OperationsResponse is the payload for GET /v1/operations.
+type OperationsResponse struct {
+	Operations  []OperationView `json:"operations"` // newest first
+	GeneratedAt string          `json:"generated_at"`
+}
+
+// OperationConflict is the 409 body of POST /v1/start, /v1/stop,
+// /v1/pause, and /v1/resume while another operation is in progress.
+type OperationConflict struct {
+	Error     string        `json:"error"`
+	Code      string        `json:"code"`      // ERR_OPERATION_IN_PROGRESS
+	Timestamp string        `json:"timestamp"` // RFC3339
+	Operation OperationView `json:"operation"`
+}
+
+// MetricsResponse is the payload for GET /v1/metrics.
+type MetricsResponse struct {
+	StartedAt        string               `json:"started_at"`
+	StartedAtLocal   string               `json:"started_at_local,omitempty"`
+	InFlight         int64                `json:"in_flight"`
+	OpenConns        int64                `json:"open_conns"`
+	Routes           map[string]RouteView `json:"routes"`
+	GeneratedAt      string               `json:"generated_at"`
+	GeneratedAtLocal string               `json:"generated_at_local,omitempty"`
+	TZ               string               `json:"tz,omitempty"`
+
+	// ProbeLatency is last_probe.latency_percentiles from /v1/status.
+	ProbeLatency map[string]LatencyPercentilesView `json:"probe_latency,omitempty"`
+	// HealthScore is health.score from /v1/status.
+	HealthScore *int `json:"health_score,omitempty"`
+
+	// RouteRepairs and RouteRepairFailures count drifted routes re-applied
+	// while active, and the attempts that failed (see package routerepair).
+	RouteRepairs        int64 `json:"route_repairs"`
+	RouteRepairFailures int64 `json:"route_repair_failures"`
+}
+
+// RouteView reports request counters for a single route.
+// ByStatus is keyed by the decimal HTTP status code.
+type RouteView struct {
+	Count         int64            `json:"count"`
+	Bytes         int64            `json:"bytes"`
+	BytesHuman    string           `json:"bytes_human,omitempty"` // set with ?humanize=true
+	AvgDurationMs int64            `json:"avg_duration_ms"`
+	ByStatus      map[string]int64 `json:"by_status"`
+	LastStatus    int              `json:"last_status"`
+
+	// Budget is the route's endpoint budget; BudgetViolations counts
+	// requests that exceeded it, keyed by time, body, or response.
+	Budget           *BudgetView      `json:"budget,omitempty"`
+	BudgetViolations map[string]int64 `json:"budget_violations"`
+}
+
+// BudgetView is an endpoint budget; 0 means unlimited.
+type BudgetView struct {
+	MaxTimeMs        int64 `json:"max_time_ms"`
+	MaxBodyBytes     int64 `json:"max_body_bytes"`
+	MaxResponseBytes int64 `json:"max_response_bytes"`
+}
+
+// ShutdownReport records what the agent drained and restored when it last
+// exited. It is written to disk during shutdown and served by
+// GET /v1/shutdown-report on the next run.
+type ShutdownReport struct {
+	Reason       string         `json:"reason"` // e.g. "signal: interrupt"
+	StartedAt    string         `json:"started_at"`
+	FinishedAt   string         `json:"finished_at"`
+	DurationMs   int64          `json:"duration_ms"`
+	Drain        DrainView      `json:"drain"`
+	ActiveFlows  int            `json:"active_flows"`  // connections open when teardown began
+	RouteRestore string         `json:"route_restore"` // "not_needed", "ok", or "failed"
+	Errors       []string       `json:"errors"`
+	LastStatus   StatusResponse `json:"last_status"` // status captured before teardown
+}
+
+// DrainView reports API connection draining during shutdown.
+type DrainView struct {
+	OpenConns  int64 `json:"open_conns"`
+	InFlight   int64 `json:"in_flight"`
+	Drained    int64 `json:"drained"` // in-flight requests that completed
+	Aborted    int64 `json:"aborted"` // in-flight requests cut off by the timeout
+	WSClients  int   `json:"ws_clients"`
+	WSClosed   int   `json:"ws_closed"`  // streams closed with a close frame (1001)
+	WSAborted  int   `json:"ws_aborted"` // streams still open at the timeout
+	Remaining  int64 `json:"remaining"`
+	TimedOut   bool  `json:"timed_out"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// RecoveryResponse is the payload for GET /v1/recovery.
+type RecoveryResponse struct {
+	CheckedAt string       `json:"checked_at"`
+	Orphans   []OrphanView `json:"orphans"`
+	Warnings  []string     `json:"warnings"`
+}
+
+// OrphanView describes one artifact left by a previous run.
+type OrphanView struct {
+	Kind     string `json:"kind"` // "tun2socks_process", "tun_interface", "default_route"
+	Resource string `json:"resource"`
+	Detail   string `json:"detail"`
+	Action   string `json:"action"`
+}
+
+// RecoveryCleanupResponse is the payload for POST /v1/recovery/cleanup.
+type RecoveryCleanupResponse struct {
+	Actions   []RecoveryActionView `json:"actions"`
+	Remaining RecoveryResponse     `json:"remaining"`
+	Reset     bool                 `json:"reset"` // agent state was reset to inactive
+	State     string               `json:"state"`
+}
+
+// RecoveryActionView reports the outcome of cleaning one orphan.
+type RecoveryActionView struct {
+	Orphan OrphanView `json:"orphan"`
+	OK     bool       `json:"ok"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// TimelineResponse is the payload for GET /v1/timeline.
+type TimelineResponse struct {
+	Since       string          `json:"since"`
+	Entries     []TimelineEntry `json:"entries"` // oldest first
+	GeneratedAt string          `json:"generated_at"`
+}
+
+// TimelineEntry is one significant health event.
+type TimelineEntry struct {
+	At      string `json:"at"`
+	AtLocal string `json:"at_local,omitempty"` // set with ?tz= or a default display tz
+	Kind    string `json:"kind"`               // state_change, probe_failed, probe_recovered, probe_regression, failover, network_change
+	Summary string `json:"summary"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+// EventsHistoryResponse is the payload for GET /v1/events/history.
+type EventsHistoryResponse struct {
+	Events    []EventView `json:"events"`    // ascending ID order
+	LastID    uint64      `json:"last_id"`   // newest ID in the log; resume with after_id=last_id
+	HasMore   bool        `json:"has_more"`  // more events exist past this page
+	Truncated bool        `json:"truncated"` // events after after_id were evicted (gap)
+}
+
+// EventView is one event log entry.
+type EventView struct {
+	ID       uint64            `json:"id"`
+	At       string            `json:"at"`
+	Type     string            `json:"type"`     // state_change, probe_result, orchestration, warning
+	Severity string            `json:"severity"` // info, warning, error
+	Message  string            `json:"message"`
+	Data     map[string]string `json:"data"`
+}
+
+// ContentionResponse is returned by GET /v1/debug/contention.
+type ContentionResponse struct {
+	WindowMs    int64            `json:"window_ms"`
+	Mutex       []ContentionSite `json:"mutex"` // lock hold-over delay at Unlock sites
+	Block       []ContentionSite `json:"block"` // time goroutines spent blocked (locks, channels, select)
+	Goroutines  int              `json:"goroutines"`
+	GeneratedAt string           `json:"generated_at"`
+}
+
+// ContentionSite is one contended call path within the window.
+type ContentionSite struct {
+	Count   int64    `json:"count"`
+	DelayMs float64  `json:"delay_ms"`
+	Stack   []string `json:"stack"` // innermost first, runtime/sync frames trimmed
+}
+
+// RuntimeResponse is returned by GET /v1/debug/runtime.
+type RuntimeResponse struct {
+	GoVersion   string          `json:"go_version"`
+	GOMAXPROCS  int             `json:"gomaxprocs"`
+	NumCPU      int             `json:"num_cpu"`
+	Goroutines  int             `json:"goroutines"`
+	CgoCalls    int64           `json:"cgo_calls"`
+	UptimeSec   int64           `json:"uptime_sec"` // process uptime
+	Heap        RuntimeHeapView `json:"heap"`
+	GC          RuntimeGCView   `json:"gc"`
+	GeneratedAt string          `json:"generated_at"`
+}
+
+// RuntimeHeapView holds memory statistics, in bytes unless noted.
+type RuntimeHeapView struct {
+	Alloc      uint64 `json:"alloc"`       // live and not yet swept heap objects
+	Inuse      uint64 `json:"inuse"`       // heap spans with at least one object
+	Idle       uint64 `json:"idle"`        // heap spans with no objects
+	Released   uint64 `json:"released"`    // idle memory returned to the OS
+	Objects    uint64 `json:"objects"`     // count of allocated heap objects
+	HeapSys    uint64 `json:"heap_sys"`    // obtained from the OS for the heap
+	StackInuse uint64 `json:"stack_inuse"` // goroutine stacks
+	Sys        uint64 `json:"sys"`         // total obtained from the OS
+	TotalAlloc uint64 `json:"total_alloc"` // cumulative
+	Mallocs    uint64 `json:"mallocs"`     // cumulative count
+	Frees      uint64 `json:"frees"`       // cumulative count
+}
+
+// RuntimeGCView holds garbage collector statistics.
+type RuntimeGCView struct {
+	NumGC        uint32  `json:"num_gc"`
+	NumForcedGC  uint32  `json:"num_forced_gc"`
+	NextGC       uint64  `json:"next_gc"`           // heap target of the next cycle, bytes
+	LastGC       string  `json:"last_gc,omitempty"` // RFC 3339; absent before the first cycle
+	PauseTotalMs float64 `json:"pause_total_ms"`
+	LastPauseMs  float64 `json:"last_pause_ms"`
+	CPUFraction  float64 `json:"cpu_fraction"` // share of CPU used by the GC since start
+}
+
+// Tun2SocksLogsResponse is returned by GET /v1/tun2socks/logs.
+type Tun2SocksLogsResponse struct {
+	Lines       []Tun2SocksLogLine `json:"lines"`    // oldest first
+	Capacity    int                `json:"capacity"` // lines the agent keeps
+	Total       uint64             `json:"total"`    // lines captured since the agent started
+	Dropped     uint64             `json:"dropped"`  // lines evicted to make room
+	GeneratedAt string             `json:"generated_at"`
+}
+
+// Tun2SocksLogLine is one captured output line.
+type Tun2SocksLogLine struct {
+	At     string `json:"at"`
+	Stream string `json:"stream"` // stdout or stderr
+	Text   string `json:"text"`
+	Error  bool   `json:"error,omitempty"`
+}
+
+// ConnectionsResponse is returned by GET /v1/connections.
+type ConnectionsResponse struct {
+	Connections []ConnectionView `json:"connections"` // ascending ID order
+	Total       int              `json:"total"`       // live connections, all pages
+	HasMore     bool             `json:"has_more"`    // more connections past this page
+	NextAfterID uint64           `json:"next_after_id,omitempty"`
+	UpdatedAt   string           `json:"updated_at,omitempty"` // engine's last report; empty before the first
+	GeneratedAt string           `json:"generated_at"`
+}
+
+// ConnectionView is one flow relayed by the engine. Up is from the host
+// to the destination.
+type ConnectionView struct {
+	ID         uint64 `json:"id"`
+	Engine     string `json:"engine"`
+	Proto      string `json:"proto"` // tcp or udp
+	Src        string `json:"src"`   // host side, "ip:port"
+	Dst        string `json:"dst"`
+	State      string `json:"state"` // connecting, established, or closing
+	BytesUp    uint64 `json:"bytes_up"`
+	BytesDown  uint64 `json:"bytes_down"`
+	StartedAt  string `json:"started_at"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// DiagnosticsRequest is the optional body of POST /v1/diagnostics.
+type DiagnosticsRequest struct {
+	Path   string `json:"path,omitempty"`   // absolute file to write on the agent host; empty streams the archive
+	Events int    `json:"events,omitempty"` // most recent events included; 0 = 500
+	Probes int    `json:"probes,omitempty"` // most recent probe results included; 0 = 20
+}
+
+// DiagnosticsResponse is returned by POST /v1/diagnostics when "path" is set.
+type DiagnosticsResponse struct {
+	Path        string                `json:"path"`
+	Bytes       int                   `json:"bytes"`
+	Files       []DiagnosticsFileView `json:"files"`
+	Skipped     []DiagnosticsSkipView `json:"skipped"` // parts that could not be collected
+	GeneratedAt string                `json:"generated_at"`
+}
+
+// DiagnosticsFileView is one file in a diagnostics bundle.
+type DiagnosticsFileView struct {
+	Name  string `json:"name"`
+	Bytes int    `json:"bytes"`
+}
+
+// DiagnosticsSkipView is one part left out of a diagnostics bundle.
+type DiagnosticsSkipView struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// InterfacesResponse is the body of GET /v1/interfaces and the
+// interfaces.json of a diagnostics bundle.
+type InterfacesResponse struct {
+	Interfaces  []InterfaceView `json:"interfaces"`             // system order
+	RoutesError string          `json:"routes_error,omitempty"` // why default routes are missing
+	GeneratedAt string          `json:"generated_at"`
+}
+
+// InterfaceView is a host network interface.
+type InterfaceView struct {
+	Name         string   `json:"name"`
+	Index        int      `json:"index"`
+	MTU          int      `json:"mtu"`
+	HardwareAddr string   `json:"hardware_addr,omitempty"`
+	Flags        []string `json:"flags"` // e.g. up, broadcast, multicast, running
+	Up           bool     `json:"up"`
+	Loopback     bool     `json:"loopback"`
+	PointToPoint bool     `json:"point_to_point"`
+	Prefixes     []string `json:"prefixes"` // addresses with prefix length
+	// DefaultRoutes are the default routes leaving through the interface.
+	DefaultRoutes []InterfaceRouteView `json:"default_routes,omitempty"`
+}
+
+// InterfaceRouteView is a default route of an interface.
+type InterfaceRouteView struct {
+	Family    string `json:"family"`            // ipv4 or ipv6
+	Gateway   string `json:"gateway,omitempty"` // empty for interface-only routes
+	Metric    int    `json:"metric"`
+	Preferred bool   `json:"preferred"` // the family's default the host uses
+}
+
+// HealthcheckRequest is the optional body of POST /v1/healthcheck/full.
+type HealthcheckRequest struct {
+	BudgetMs int `json:"budget_ms,omitempty"` // whole-sweep bound; 0 = default
+}
+
+// HealthReportResponse is returned by POST /v1/healthcheck/full.
+type HealthReportResponse struct {
+	Status           string            `json:"status"` // worst check result: pass, warn, or fail
+	BudgetMs         int64             `json:"budget_ms"`
+	ElapsedMs        int64             `json:"elapsed_ms"`
+	BudgetExceeded   bool              `json:"budget_exceeded"`
+	Checks           []HealthCheckView `json:"checks"` // in execution order
+	StartedAt        string            `json:"started_at"`
+	StartedAtLocal   string            `json:"started_at_local,omitempty"`
+	GeneratedAt      string            `json:"generated_at"`
+	GeneratedAtLocal string            `json:"generated_at_local,omitempty"`
+	TZ               string            `json:"tz,omitempty"`
+}
+
+// HealthCheckView is one step of a health sweep.
+type HealthCheckView struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`   // probe, data_plane, dns_leak, route_drift
+	Status     string `json:"status"` // pass, warn, fail, skip
+	Detail     string `json:"detail"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// RulesRequest is the body of PUT /v1/rules. It replaces every rule.
+type RulesRequest struct {
+	Rules []string      `json:"rules"`          // domain patterns: "host.example.com" or "*.example.com"
+	Apps  []AppRuleView `json:"apps,omitempty"` // processes to exempt from the tunnel
+}
+
+// RulesResponse is returned by GET and PUT /v1/rules.
+type RulesResponse struct {
+	Rules  []string        `json:"rules"`  // canonical patterns, in order
+	Routes []RuleRouteView `json:"routes"` // host routes learned from DNS, sorted by address
+	Apps   []AppRuleView   `json:"apps"`   // app bypass rules, in order
+	// Profile labels the verdicts in flow records; Stats counts the flows
+	// each rule decided, including rules that never matched.
+	Profile string          `json:"profile"`
+	Stats   []RuleStatsView `json:"stats"`
+	// AppAttribution names how sockets are attributed to processes
+	// ("procfs", "lsof", or "unsupported"). App rules are best effort.
+	AppAttribution string `json:"app_attribution"`
+	GeneratedAt    string `json:"generated_at"`
+}
+
+// AppRuleView exempts a local process from the tunnel. Exactly one of Path
+// (absolute executable path) or User (login name or numeric UID) is set.
+type AppRuleView struct {
+	Path string `json:"path,omitempty"`
+	User string `json:"user,omitempty"`
+}
+
+// RuleStatsView counts the flows one rule decided.
+type RuleStatsView struct {
+	Rule      string `json:"rule"`
+	Kind      string `json:"kind"`    // domain, app, default, or other
+	Verdict   string `json:"verdict"` // tunnel, direct, or block
+	Flows     uint64 `json:"flows"`
+	Packets   uint64 `json:"packets"`
+	Bytes     uint64 `json:"bytes"`
+	LastMatch string `json:"last_match,omitempty"`
+}
+
+// RuleRouteView is one host route installed because a DNS answer matched
+// a rule.
+type RuleRouteView struct {
+	Addr      string `json:"addr"`
+	Domain    string `json:"domain"`
+	Rule      string `json:"rule"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// TokenRequest is the body of POST /v1/tokens.
+type TokenRequest struct {
+	Name   string `json:"name"`              // 1-64 of [A-Za-z0-9._-], unique
+	Scope  string `json:"scope,omitempty"`   // "admin", "operate", or "read" (default)
+	TTLSec int64  `json:"ttl_sec,omitempty"` // default 30 days, max 365 days
+}
+
+// Token sources in TokenView.
+const (
+	TokenSourceMinted = "minted" // POST /v1/tokens
+	TokenSourceConfig = "config" // api_tokens in the config file
+)
+
+// TokenView is a token's metadata. Secrets are never listed.
+type TokenView struct {
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`
+	Identity  string `json:"identity"` // as shown in /v1/clients
+	Source    string `json:"source"`
+	CreatedAt string `json:"created_at,omitempty"` // empty for config tokens
+	ExpiresAt string `json:"expires_at,omitempty"` // empty for config tokens, which do not expire
+}
+
+// MintTokenResponse is returned by POST /v1/tokens. Secret is shown only
+// here; store it, since the agent keeps just a digest.
+type MintTokenResponse struct {
+	Token  TokenView `json:"token"`
+	Secret string    `json:"secret"`
+}
+
+// TokensResponse is returned by GET /v1/tokens.
+type TokensResponse struct {
+	Tokens      []TokenView `json:"tokens"` // sorted by name
+	GeneratedAt string      `json:"generated_at"`
+}
+
+// ProfileRequest is the body of POST and PUT /v1/profiles. Name is 1-64
+// of [A-Za-z0-9._-]; SocksServer is required; other fields are optional
+// and follow StartRequest.
+type ProfileRequest struct {
+	Name         string   `json:"name"`
+	SocksServer  string   `json:"socks_server"`
+	AuthRef      string   `json:"auth_ref,omitempty"`
+	MTU          int      `json:"mtu,omitempty"`
+	BypassHosts  []string `json:"bypass_hosts,omitempty"`
+	DNSUpstreams []string `json:"dns_upstreams,omitempty"`
+	DisableDNS   bool     `json:"disable_dns,omitempty"`
+
+	// Match lists the networks the profile is for; see GET /v1/profiles.
+	Match *ProfileMatchView `json:"match,omitempty"`
+}
+
+// ProfileMatchView lists the networks a profile is selected on. Every
+// non-empty list must contain the current value.
+type ProfileMatchView struct {
+	SSIDs         []string `json:"ssids,omitempty"`
+	GatewayMACs   []string `json:"gateway_macs,omitempty"`
+	SearchDomains []string `json:"search_domains,omitempty"`
+}
+
+// ProfileView is a stored profile.
+type ProfileView struct {
+	Name         string            `json:"name"`
+	SocksServer  string            `json:"socks_server"`
+	AuthRef      string            `json:"auth_ref,omitempty"`
+	MTU          int               `json:"mtu,omitempty"`
+	BypassHosts  []string          `json:"bypass_hosts"`
+	DNSUpstreams []string          `json:"dns_upstreams"`
+	DisableDNS   bool              `json:"disable_dns"`
+	Match        *ProfileMatchView `json:"match,omitempty"`
+	UpdatedAt    string            `json:"updated_at"`
+}
+
+// ProfilesResponse is returned by GET /v1/profiles. Selected is the
+// profile matching Location, if any; SelectMode says whether starts use it
+// ("apply") or it is only reported ("suggest"). Location is absent when
+// the network is not watched.
+type ProfilesResponse struct {
+	Profiles    []ProfileView        `json:"profiles"` // sorted by name
+	SelectMode  string               `json:"select_mode"`
+	Location    *NetworkLocationView `json:"location,omitempty"`
+	Selected    string               `json:"selected,omitempty"`
+	SelectedBy  []string             `json:"selected_by,omitempty"` // the rules that matched
+	GeneratedAt string               `json:"generated_at"`
+}
+
+// NetworkLocationView is the network the host is attached to. Empty
+// fields are unknown.
+type NetworkLocationView struct {
+	SSID          string   `json:"ssid"`
+	Gateway       string   `json:"gateway"`
+	GatewayMAC    string   `json:"gateway_mac"`
+	SearchDomains []string `json:"search_domains"`
+	Summary       string   `json:"summary"`
+	CheckedAt     string   `json:"checked_at"`
+	ChangedAt     string   `json:"changed_at"` // when this location was first seen
+	Error         string   `json:"error,omitempty"`
+}
+
+// ClientsResponse is returned by GET /v1/clients.
+type ClientsResponse struct {
+	Clients          []ClientView `json:"clients"` // most recently seen first
+	GeneratedAt      string       `json:"generated_at"`
+	GeneratedAtLocal string       `json:"generated_at_local,omitempty"`
+	TZ               string       `json:"tz,omitempty"`
+}
+
+// ClientView is one API client, keyed by listener, credential, user agent,
+// and remote host. Identity is a token fingerprint ("token:1a2b3c4d") or
+// "unauthenticated" on listeners without a token.
+type ClientView struct {
+	ID             string   `json:"id"`
+	UserAgent      string   `json:"user_agent"`
+	Identity       string   `json:"identity"`
+	Listener       string   `json:"listener"`
+	Scope          string   `json:"scope"`
+	Remote         string   `json:"remote"`
+	Active         bool     `json:"active"`        // open stream or a request in the last minute
+	Subscriptions  []string `json:"subscriptions"` // open stream types: "status" or "events"
+	Requests       uint64   `json:"requests"`
+	Mutations      uint64   `json:"mutations"` // non-GET requests: the client controls the agent
+	LastRequest    string   `json:"last_request"`
+	FirstSeen      string   `json:"first_seen"`
+	FirstSeenLocal string   `json:"first_seen_local,omitempty"`
+	LastSeen       string   `json:"last_seen"`
+	LastSeenLocal  string   `json:"last_seen_local,omitempty"`
+}
+
+// UplinksResponse is returned by GET /v1/uplinks: the active uplink for
+// the upstream connection and the standbys in preference order.
+type UplinksResponse struct {
+	Active      string       `json:"active"` // empty when no link is usable
+	Links       []UplinkView `json:"links"`
+	Switches    int          `json:"switches"` // active link changes since boot
+	LastSwitch  *SwitchView  `json:"last_switch,omitempty"`
+	Error       string       `json:"error,omitempty"` // last route listing failure
+	CheckedAt   string       `json:"checked_at,omitempty"`
+	GeneratedAt string       `json:"generated_at"`
+}
+
+// UplinkView is one candidate uplink. Reason explains an unusable link.
+type UplinkView struct {
+	Interface string `json:"interface"`
+	Gateway   string `json:"gateway,omitempty"`
+	Metric    int    `json:"metric"`
+	Usable    bool   `json:"usable"`
+	Active    bool   `json:"active"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// CaptiveResponse is returned by GET /v1/network/captive: whether a
+// captive portal intercepts the uplink, from the latest direct check.
+type CaptiveResponse struct {
+	State       string              `json:"state"`                // unknown, clear, portal, or offline
+	PortalURL   string              `json:"portal_url,omitempty"` // login page, or the intercepted check URL
+	Interface   string              `json:"interface,omitempty"`  // uplink the checks were bound to
+	Results     []CaptiveResultView `json:"results"`
+	CheckedAt   string              `json:"checked_at,omitempty"`
+	Since       string              `json:"since,omitempty"` // when state last changed
+	GeneratedAt string              `json:"generated_at"`
+}
+
+// CaptiveResultView is one check URL's answer. Status is 0 when no
+// response was read.
+type CaptiveResultView struct {
+	URL       string `json:"url"`
+	OK        bool   `json:"ok"`
+	Status    int    `json:"status"`
+	Location  string `json:"location,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// EgressResponse is returned by GET /v1/egress: the public address
+// traffic exits from, through the tunnel and directly over the uplink.
+type EgressResponse struct {
+	Provider     string              `json:"provider"`
+	TunnelActive bool                `json:"tunnel_active"`
+	Tunnel       *EgressIdentityView `json:"tunnel,omitempty"` // only while the tunnel is active
+	Direct       EgressIdentityView  `json:"direct"`
+	// ViaProxy is true when the tunnel exits from another address than
+	// the uplink; absent unless both lookups succeeded.
+	ViaProxy    *bool    `json:"via_proxy,omitempty"`
 	Warnings    []string `json:"warnings"`
 	GeneratedAt string   `json:"generated_at"`
 }
+
+// EgressIdentityView is the address one path exits from. Geo and ASN
+// fields are present when the provider reports them.
+type EgressIdentityView struct {
+	IP        string `json:"ip,omitempty"`
+	Country   string `json:"country,omitempty"`
+	Region    string `json:"region,omitempty"`
+	City      string `json:"city,omitempty"`
+	ASN       string `json:"asn,omitempty"`
+	Org       string `json:"org,omitempty"`
+	Interface string `json:"interface,omitempty"` // uplink a direct lookup was bound to
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	Cached    bool   `json:"cached"` // reused from an earlier lookup within the TTL
+	CheckedAt string `json:"checked_at"`
+}
+
+// FirewallResponse is returned by GET /v1/firewall: the rule sets the
+// agent installed into the host firewall.
+type FirewallResponse struct {
+	Backend      string            `json:"backend"` // nftables or pf
+	Sets         []FirewallSetView `json:"sets"`
+	LastTeardown *FirewallTeardown `json:"last_teardown,omitempty"`
+	GeneratedAt  string            `json:"generated_at"`
+}
+
+// FirewallSetView is one installed rule set.
+type FirewallSetView struct {
+	Name        string             `json:"name"` // feature owning the set, e.g. ipv6_leak
+	Rules       []FirewallRuleView `json:"rules"`
+	InstalledAt string             `json:"installed_at"`
+}
+
+// FirewallRuleView is one rule, first match wins. Empty matches are
+// omitted and match anything.
+type FirewallRuleView struct {
+	Action  string `json:"action"`           // accept or drop
+	Family  string `json:"family,omitempty"` // ipv4 or ipv6
+	Out     string `json:"out,omitempty"`    // outgoing interface
+	Dst     string `json:"dst,omitempty"`    // destination prefix
+	Comment string `json:"comment,omitempty"`
+	Native  string `json:"native"` // the rule as loaded into the backend
+}
+
+// FirewallTeardown is the result of the latest removal of every set, run
+// after each stop and at exit.
+type FirewallTeardown struct {
+	At      string   `json:"at"`
+	Removed []string `json:"removed"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// VersionResponse is returned by GET /v1/version: the agent's build and
+// which platform features this build on this host provides.
+type VersionResponse struct {
+	Version     string        `json:"version"`              // module version; a pseudo-version for a checkout build
+	Revision    string        `json:"revision,omitempty"`   // VCS commit
+	BuildTime   string        `json:"build_time,omitempty"` // commit time
+	Modified    bool          `json:"modified,omitempty"`   // built from a dirty tree
+	GoVersion   string        `json:"go_version"`
+	OS          string        `json:"os"`
+	Arch        string        `json:"arch"`
+	Features    []FeatureView `json:"features"`
+	GeneratedAt string        `json:"generated_at"`
+}
+
+// FeatureView is one platform feature.
+type FeatureView struct {
+	Name      string `json:"name"` // e.g. tun_device, route_changes, firewall
+	Available bool   `json:"available"`
+	Method    string `json:"method,omitempty"` // mechanism, e.g. wintun, rtnetlink, nftables
+	Detail    string `json:"detail,omitempty"` // why it is unavailable
+}
+
+// ServiceResponse is returned by GET /v1/service and the install and
+// uninstall endpoints: the agent's launch daemon as launchd sees it.
+type ServiceResponse struct {
+	Manager      string   `json:"manager"` // launchd
+	Label        string   `json:"label"`
+	PlistPath    string   `json:"plist_path"`
+	Installed    bool     `json:"installed"`       // the plist exists
+	Loaded       bool     `json:"loaded"`          // launchd knows the job
+	State        string   `json:"state,omitempty"` // e.g. running, not running
+	PID          int      `json:"pid,omitempty"`
+	UnderLaunchd bool     `json:"under_launchd"` // this agent was started by the job
+	Program      string   `json:"program"`       // executable the plist runs
+	Args         []string `json:"args"`
+	Warnings     []string `json:"warnings,omitempty"`
+	GeneratedAt  string   `json:"generated_at"`
+}
+
+// ServiceInstallRequest is the optional body of POST /v1/service/install.
+type ServiceInstallRequest struct {
+	Args []string `json:"args,omitempty"` // agent arguments; default those of this process
+}
+
+// LeakTestRequest is the optional body of POST /v1/leaktest/dns.
+type LeakTestRequest struct {
+	Domain        string `json:"domain,omitempty"`         // zone for the tagged names; default example.com
+	Queries       int    `json:"queries,omitempty"`        // tagged names to look up, 1..10; default 3
+	DisableWhoAmI bool   `json:"disable_whoami,omitempty"` // skip the TXT lookup naming the resolver
+}
+
+// LeakTestResponse is returned by POST /v1/leaktest/dns.
+type LeakTestResponse struct {
+	Verdict   string            `json:"verdict"` // no_leak, leak, or inconclusive
+	Reason    string            `json:"reason"`
+	Forwarder LeakForwarderView `json:"forwarder"`
+	Routing   LeakRoutingView   `json:"routing"` // where the system resolvers are routed
+	Queries   []LeakQueryView   `json:"queries"`
+	// Resolvers are the addresses the TXT lookup of o-o.myaddr.l.google.com
+	// named: the resolver that reached the internet.
+	Resolvers   []string `json:"resolvers"`
+	WhoAmIError string   `json:"whoami_error,omitempty"`
+	ElapsedMS   int64    `json:"elapsed_ms"`
+	GeneratedAt string   `json:"generated_at"`
+}
+
+// LeakForwarderView is the DNS forwarder as the leak test found it. The
+// test observes queries only while it runs with the resolvers rewritten.
+type LeakForwarderView struct {
+	Configured bool   `json:"configured"`
+	Running    bool   `json:"running"`
+	Rewritten  bool   `json:"rewritten"`
+	Listen     string `json:"listen,omitempty"`
+}
+
+// LeakRoutingView is the dns_leak health check: whether the system
+// resolvers are routed through the tunnel.
+type LeakRoutingView struct {
+	Status string `json:"status"` // pass, warn, fail, or skip
+	Detail string `json:"detail"`
+}
+
+// LeakQueryView is one tagged lookup. Observed means the tunnel's DNS
+// forwarder relayed it; answered but not observed is a leak.
+type LeakQueryView struct {
+	Name      string   `json:"name"`
+	Answered  bool     `json:"answered"`
+	Observed  bool     `json:"observed"`
+	Addrs     []string `json:"addrs,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	LatencyMS int64    `json:"latency_ms"`
+}
+
+// SwitchView is a change of active uplink.
+type SwitchView struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+	At     string `json:"at"`
+}
+
+// DNSUpstreamsResponse is returned by GET /v1/dns/upstreams: the DNS
+// forwarder's upstreams in fallback order with their health.
+type DNSUpstreamsResponse struct {
+	Running     bool              `json:"running"`
+	Queries     uint64            `json:"queries"`
+	Failures    uint64            `json:"failures"` // queries answered with SERVFAIL
+	Upstreams   []DNSUpstreamView `json:"upstreams"`
+	GeneratedAt string            `json:"generated_at"`
+}
+
+// DNSUpstreamView is one upstream. Attempts include fallbacks from an
+// earlier upstream; Healthy is false during the cooldown after repeated
+// failures, when the upstream is tried last.
+type DNSUpstreamView struct {
+	Upstream            string  `json:"upstream"`
+	Scheme              string  `json:"scheme"` // tcp, tls, or https
+	Pinned              bool    `json:"pinned"`
+	Healthy             bool    `json:"healthy"`
+	Attempts            uint64  `json:"attempts"`
+	Failures            uint64  `json:"failures"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	LatencyMS           float64 `json:"latency_ms"` // moving average of successful exchanges
+	LastError           string  `json:"last_error,omitempty"`
+	LastErrorAt         string  `json:"last_error_at,omitempty"`
+	LastSuccessAt       string  `json:"last_success_at,omitempty"`
+	CooldownUntil       string  `json:"cooldown_until,omitempty"`
+}
+
+// StateMachineResponse is returned by GET /v1/statemachine: the agent
+// lifecycle states and the transitions SetAgentState allows between them.
+type StateMachineResponse struct {
+	States      []string              `json:"states"` // lifecycle order
+	Initial     string                `json:"initial"`
+	Current     string                `json:"current"`
+	Transitions []StateTransitionView `json:"transitions"` // grouped by from, in states order
+	GeneratedAt string                `json:"generated_at"`
+}
+
+// StateTransitionView is one allowed transition.
+type StateTransitionView struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// StreamMessage is one record on GET /v1/events/stream or
+// /v1/ws?stream=events. Exactly one of Event and Flow is set. Dropped is
+// the number of records this client has lost so far because it read too
+// slowly; a change means it should resynchronize (e.g. from
+// /v1/events/history).
+type StreamMessage struct {
+	Kind    string     `json:"kind"` // event or flow
+	Event   *EventView `json:"event,omitempty"`
+	Flow    *FlowView  `json:"flow,omitempty"`
+	Dropped uint64     `json:"dropped"`
+}
+
+// FlowView is one flow record, as exported to sinks.
+type FlowView struct {
+	Proto    uint8  `json:"proto"` // IP protocol number (6 TCP, 17 UDP)
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+	Packets  uint64 `json:"packets"`
+	Bytes    uint64 `json:"bytes"`
+	TCPFlags uint8  `json:"tcp_flags,omitempty"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Verdict  string `json:"verdict,omitempty"` // tunnel, direct, or block
+	Rule     string `json:"rule,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+}
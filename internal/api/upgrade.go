@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/handover"
+	"github.com/sanverite/simple-packet-logger/internal/logging"
+)
+
+// handleUpgrade replaces the agent with the executable now at its path
+// without closing the API: the new process is started with the
+// listeners' sockets and, once it serves them, this one shuts down,
+// draining its open requests. Connections arriving meanwhile are served
+// by either. Only an inactive agent upgrades; the tunnel is not handed
+// over.
+// Method: POST
+// Request: UpgradeRequest (optional body)
+// Response: 202 UpgradeResponse
+// Errors: 400 invalid body or digest mismatch, 403 without admin scope or
+// from a tokenless TCP listener (see requireProven), 409 while a tunnel is up, an operation runs, or shutdown is underway,
+// 500 when the new agent fails to start (this one keeps serving), 503
+// when the process did not enable it.
+func (s *Server) handleUpgrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if requestScope(r.Context()) != ScopeAdmin {
+		writeJSON(w, http.StatusForbidden, APIError{
+			Error:     "upgrade requires admin scope",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if !requireProven(w, r, "upgrade") {
+		return
+	}
+	if s.opts.Upgrade == nil || s.opts.RequestShutdown == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "upgrade via API not enabled",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req UpgradeRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if req.SHA256 != "" && !isHexDigest(req.SHA256) {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "sha256 must be 64 hex digits",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// The upgrade holds the orchestration guard, so no start can slip in
+	// while it hands over, nor after: this agent is on its way out.
+	up := &operation{
+		ID:        newOperationID(),
+		Kind:      opUpgrade,
+		Client:    clientID(r.Context()),
+		RequestID: logging.RequestID(r.Context()),
+		StartedAt: TimeNow(),
+	}
+	s.opMu.Lock()
+	cur := s.op
+	if cur == nil {
+		s.op = up
+	}
+	s.opMu.Unlock()
+	if cur != nil {
+		s.writeOperationConflict(w, cur)
+		return
+	}
+	release := func() {
+		s.opMu.Lock()
+		if s.op == up {
+			s.op = nil
+		}
+		s.opMu.Unlock()
+	}
+	// Only the API listeners are handed over. Adopting the TUN and the
+	// engine needs the start steps that have not landed (errNotImplemented),
+	// so there is nothing of the tunnel to pass yet.
+	if st := s.state.GetSnapshot().AgentState; st != core.StateInactive {
+		release()
+		writeJSON(w, http.StatusConflict, APIError{
+			Error:     fmt.Sprintf("agent is %s; stop the tunnel before upgrading", st),
+			Code:      CodeStateTransition,
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if !s.exiting.CompareAndSwap(false, true) {
+		release()
+		writeJSON(w, http.StatusConflict, APIError{
+			Error:     "shutdown or upgrade already in progress",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	lns := make([]net.Listener, len(s.listeners))
+	for i, bl := range s.listeners {
+		lns[i] = bl.raw
+	}
+	pid, err := s.opts.Upgrade(r.Context(), req.SHA256, lns)
+	if err != nil {
+		s.exiting.Store(false)
+		release()
+		s.logger.ErrorContext(r.Context(), "upgrade failed; still serving", "err", err)
+		s.recordEvent(r.Context(), core.EventWarning, "upgrade failed: "+err.Error(), map[string]string{"kind": "upgrade"})
+		code := http.StatusInternalServerError
+		if errors.Is(err, handover.ErrDigestMismatch) {
+			code = http.StatusBadRequest
+		}
+		writeJSON(w, code, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	reason := "upgrade: handed over to pid " + strconv.Itoa(pid)
+	s.logger.InfoContext(r.Context(), "upgrade handed over", "pid", pid, "client", clientID(r.Context()), "remote_addr", r.RemoteAddr)
+	s.recordEvent(r.Context(), core.EventOrchestration, reason, map[string]string{"kind": "upgrade", "pid": strconv.Itoa(pid)})
+	s.opts.RequestShutdown(reason)
+	writeJSON(w, http.StatusAccepted, UpgradeResponse{
+		Accepted:    true,
+		PID:         pid,
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	})
+}
+
+// isHexDigest reports whether s is a hex SHA-256 digest.
+func isHexDigest(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// handleUplinks reports the uplinks tracked for failover.
+// Method: GET
+// Response (200): UplinksResponse JSON
+// Errors:
+//   - 503 when the uplink monitor is not configured
+func (s *Server) handleUplinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if s.opts.Uplinks == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "uplink monitor not configured",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, FromUplinks(s.opts.Uplinks.Status()))
+}
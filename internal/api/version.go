@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/procowner"
+	"github.com/sanverite/simple-packet-logger/internal/routerepair"
+	"github.com/sanverite/simple-packet-logger/internal/secrets"
+	"github.com/sanverite/simple-packet-logger/internal/tun2socks"
+	"github.com/sanverite/simple-packet-logger/internal/tundev"
+)
+
+// handleVersion reports the agent's build and the platform features it
+// provides here, so clients can tell what to offer before calling them.
+// Method: GET
+// Response (200): VersionResponse JSON
+// Errors:
+//   - 405 for other methods
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	info, _ := debug.ReadBuildInfo()
+	writeJSON(w, http.StatusOK, FromBuildInfo(info, s.features()))
+}
+
+// features lists the platform features: those a package implements per
+// platform report its Method, and optional subsystems whether the agent
+// runs them.
+func (s *Server) features() []FeatureView {
+	fs := []FeatureView{
+		methodFeature("tun_device", tundev.Method()),
+		methodFeature("route_changes", routerepair.Method()),
+		methodFeature("process_attribution", procowner.Method()),
+		methodFeature("secrets", secrets.Method()),
+	}
+
+	fw := FeatureView{Name: "firewall", Detail: "not configured"}
+	if s.opts.Firewall != nil {
+		fw = FeatureView{Name: "firewall", Available: true, Method: s.opts.Firewall.Backend()}
+	}
+	fs = append(fs, fw)
+
+	svc := FeatureView{Name: "service_manager", Detail: "not supported on this platform"}
+	switch runtime.GOOS {
+	case "linux":
+		svc = FeatureView{Name: "service_manager", Available: true, Method: "systemd"}
+	case "darwin":
+		svc = FeatureView{Name: "service_manager", Available: true, Method: "launchd"}
+	case "windows":
+		svc = FeatureView{Name: "service_manager", Available: true, Method: "windows_service"}
+	}
+	fs = append(fs, svc)
+
+	eng := FeatureView{Name: "embedded_engine", Detail: tun2socks.ErrEmbeddedUnavailable.Error()}
+	if tun2socks.EmbeddedAvailable() {
+		eng = FeatureView{Name: "embedded_engine", Available: true, Method: "netstack"}
+	}
+	fs = append(fs, eng)
+
+	fs = append(fs,
+		optionalFeature("dns_forwarder", s.opts.DNS != nil),
+		optionalFeature("ipv6_kill_switch", s.opts.IPv6Leak != nil),
+	)
+	return fs
+}
+
+// methodFeature reports a feature by its package's Method.
+func methodFeature(name, method string) FeatureView {
+	if method == "unsupported" {
+		return FeatureView{Name: name, Detail: "not supported on this platform"}
+	}
+	return FeatureView{Name: name, Available: true, Method: method}
+}
+
+// optionalFeature reports a subsystem the agent runs unless disabled.
+func optionalFeature(name string, running bool) FeatureView {
+	if !running {
+		return FeatureView{Name: name, Detail: "not configured"}
+	}
+	return FeatureView{Name: name, Available: true}
+}
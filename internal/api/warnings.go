@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// handleWarningsClear removes state warnings once they have been dealt with.
+// Method: POST
+// Request: WarningsClearRequest JSON (optional; empty clears all)
+// Response (200): WarningsClearResponse JSON
+// Errors:
+//   - 400 on invalid JSON or an unknown severity
+func (s *Server) handleWarningsClear(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	var req WarningsClearRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "invalid JSON: " + err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	maxSev := core.SeverityError
+	if req.MaxSeverity != "" {
+		sev, err := core.ParseSeverity(req.MaxSeverity)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "max_severity must be info, warning, or error",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		maxSev = sev
+	}
+
+	cleared := s.state.RemoveWarnings(func(w core.Warning) bool {
+		return (len(req.Codes) == 0 || slices.Contains(req.Codes, w.Code)) &&
+			(req.Source == "" || w.Source == req.Source) &&
+			w.Severity <= maxSev
+	})
+	if cleared > 0 {
+		s.logger.InfoContext(r.Context(), "warnings cleared", "cleared", cleared)
+	}
+	warns := s.state.GetSnapshot().Warnings
+	writeJSON(w, http.StatusOK, WarningsClearResponse{
+		Cleared:     cleared,
+		Warnings:    core.WarningMessages(warns),
+		WarningsV2:  fromWarnings(warns),
+		GeneratedAt: TimeNow().UTC().Format(time.RFC3339),
+	})
+}
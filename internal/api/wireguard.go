@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/wireguard"
+)
+
+// config converts u to a validated tunnel configuration. Address may be
+// written as a prefix ("10.8.0.2/32"), as in wg-quick files; only IPv4
+// addresses are routed.
+func (u *WireGuardUpstream) config() (wireguard.Config, error) {
+	var c wireguard.Config
+	var err error
+	if u.PrivateKey == "" {
+		return c, errors.New("wireguard.private_key is required")
+	}
+	if c.PrivateKey, err = wireguard.ParseKey(u.PrivateKey); err != nil {
+		return c, fmt.Errorf("wireguard.private_key: %w", err)
+	}
+	if c.Peer.PublicKey, err = wireguard.ParseKey(u.PublicKey); err != nil {
+		return c, fmt.Errorf("wireguard.public_key: %w", err)
+	}
+	if u.PresharedKey != "" {
+		if c.Peer.PresharedKey, err = wireguard.ParseKey(u.PresharedKey); err != nil {
+			return c, fmt.Errorf("wireguard.preshared_key: %w", err)
+		}
+	}
+	if p, err := netip.ParsePrefix(u.Address); err == nil {
+		c.Address = p.Addr()
+	} else if c.Address, err = netip.ParseAddr(u.Address); err != nil {
+		return c, fmt.Errorf("wireguard.address %q: want an IPv4 address", u.Address)
+	}
+	if !c.Address.Is4() {
+		return c, fmt.Errorf("wireguard.address %q: want an IPv4 address", u.Address)
+	}
+	c.Peer.Endpoint = u.Endpoint
+	c.Peer.Keepalive = time.Duration(u.KeepaliveSec) * time.Second
+	return c, c.Validate()
+}
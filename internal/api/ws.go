@@ -0,0 +1,373 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/stream"
+)
+
+// Minimal server-side WebSocket (RFC 6455) support for GET /v1/ws.
+// Only what the status stream needs is implemented: the opening handshake,
+// unfragmented text frames from the server, and control frames (ping, pong,
+// close) from the client. Client data frames are read and discarded.
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+
+	// wsMaxClientFrame bounds payloads accepted from clients; the stream is
+	// server-push only, so anything larger is a protocol abuse.
+	wsMaxClientFrame = 4096
+
+	// wsMinInterval is the fastest push cadence a client may request.
+	wsMinInterval = 250 * time.Millisecond
+
+	// wsCloseGrace is how long a server-initiated close waits for the
+	// client's close frame before dropping the connection.
+	wsCloseGrace = time.Second
+)
+
+// handleWS upgrades to WebSocket and pushes StatusResponse snapshots, or
+// with stream=events, StreamMessage records as they happen.
+// Method: GET
+// Query: interval_ms (push cadence, >= 250; default ServerOptions.WSInterval),
+// humanize and tz (same as /v1/status); stream=events takes the filters of
+// GET /v1/events/stream instead.
+// Errors:
+//   - 400 when the request is not a valid WebSocket upgrade or filter
+//   - 403 when Origin is set and not in AllowedOrigins
+//   - 426 for unsupported Sec-WebSocket-Version
+//   - 503 when WSMaxClients connections are already open, or for
+//     stream=events when streaming is not configured
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{
+			Error:     "method not allowed",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if s.refuseForeignOrigin(w, r) {
+		return
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "websocket upgrade required",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeJSON(w, http.StatusUpgradeRequired, APIError{
+			Error:     "unsupported websocket version",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     "missing Sec-WebSocket-Key",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	interval := s.opts.WSInterval
+	if v := r.URL.Query().Get("interval_ms"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || time.Duration(ms)*time.Millisecond < wsMinInterval {
+			writeJSON(w, http.StatusBadRequest, APIError{
+				Error:     "interval_ms must be an integer >= 250",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		interval = time.Duration(ms) * time.Millisecond
+	}
+	humanize := wantHumanize(r)
+	loc, err := s.displayLocation(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+	var (
+		events bool
+		filter stream.Filter
+		buffer int
+	)
+	switch r.URL.Query().Get("stream") {
+	case "", SubscriptionStatus:
+	case SubscriptionEvents:
+		if s.opts.Stream == nil {
+			writeJSON(w, http.StatusServiceUnavailable, APIError{
+				Error:     "event streaming not configured",
+				Timestamp: TimeNow().UTC().Format(time.RFC3339),
+			})
+			return
+		}
+		events = true
+		filter, buffer, err = parseStreamFilter(r.URL.Query())
+	default:
+		err = errors.New("stream must be status or events")
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIError{
+			Error:     err.Error(),
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// Enforce the client limit before hijacking so the rejection is plain HTTP.
+	select {
+	case s.wsSlots <- struct{}{}:
+		defer func() { <-s.wsSlots }()
+	default:
+		writeJSON(w, http.StatusServiceUnavailable, APIError{
+			Error:     "too many websocket clients",
+			Timestamp: TimeNow().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), "websocket hijack failed", "err", err)
+		return
+	}
+	defer conn.Close()
+	// Clear server read/write timeouts inherited from http.Server; the stream
+	// manages its own per-write deadlines.
+	_ = conn.SetDeadline(time.Time{})
+
+	ws := &wsConn{conn: conn, br: brw.Reader, writeTimeout: s.opts.WSWriteTimeout}
+	if err := ws.handshake(key); err != nil {
+		s.logger.WarnContext(r.Context(), "websocket handshake failed", "remote_addr", r.RemoteAddr, "err", err)
+		return
+	}
+	// The reader goroutine answers pings and detects client close/disconnect.
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		ws.readLoop()
+	}()
+	closeForShutdown := func() {
+		if ws.writeClose(1001, "server shutting down") == nil {
+			s.wsClosed.Add(1)
+			// Give the client a moment to echo the close frame.
+			select {
+			case <-readerDone:
+			case <-time.After(wsCloseGrace):
+			}
+		}
+	}
+
+	if events {
+		// The hijacked connection outlives r.Context(); the subscription
+		// ends with this handler instead.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sub := s.opts.Stream.Subscribe(ctx, filter, buffer)
+		s.logger.InfoContext(r.Context(), "websocket client connected", "remote_addr", r.RemoteAddr, "stream", SubscriptionEvents, "buffer", buffer)
+		defer func() {
+			s.logger.InfoContext(r.Context(), "websocket client disconnected", "remote_addr", r.RemoteAddr, "dropped", sub.Dropped())
+		}()
+		defer s.clients.subscribe(clientID(r.Context()), SubscriptionEvents)()
+		if !s.streamEvents(ws, sub, readerDone) {
+			closeForShutdown()
+		}
+		return
+	}
+
+	s.logger.InfoContext(r.Context(), "websocket client connected", "remote_addr", r.RemoteAddr, "interval_ms", interval.Milliseconds())
+	defer s.logger.InfoContext(r.Context(), "websocket client disconnected", "remote_addr", r.RemoteAddr)
+	defer s.clients.subscribe(clientID(r.Context()), SubscriptionStatus)()
+
+	push := func() error {
+		b, err := json.Marshal(s.renderStatus(s.state.GetSnapshot(), humanize, loc))
+		if err != nil {
+			return err
+		}
+		return ws.writeFrame(wsOpText, b)
+	}
+
+	if err := push(); err != nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := push(); err != nil {
+				s.logger.DebugContext(r.Context(), "websocket write failed", "remote_addr", r.RemoteAddr, "err", err)
+				return
+			}
+		case <-readerDone:
+			return
+		case <-s.shutdown:
+			closeForShutdown()
+			return
+		}
+	}
+}
+
+// wsConn is a hijacked connection speaking the server side of RFC 6455.
+type wsConn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	writeTimeout time.Duration
+
+	wmu       sync.Mutex  // serializes frame writes from the push loop and reader
+	closeSent atomic.Bool // the server started the closing handshake
+}
+
+func (c *wsConn) handshake(key string) error {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	_, err := io.WriteString(c.conn, resp)
+	return err
+}
+
+// writeFrame sends a single unmasked, unfragmented frame.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	hdr := make([]byte, 0, 10)
+	hdr = append(hdr, 0x80|op) // FIN + opcode
+	switch n := len(payload); {
+	case n <= 125:
+		hdr = append(hdr, byte(n))
+	case n <= 0xFFFF:
+		hdr = append(hdr, 126)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr = append(hdr, 127)
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	if _, err := c.conn.Write(hdr); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// writeClose sends a close frame with the given status code and reason.
+func (c *wsConn) writeClose(code uint16, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	payload = append(payload, reason...)
+	c.closeSent.Store(true)
+	return c.writeFrame(wsOpClose, payload)
+}
+
+// readLoop consumes client frames until close or error. Pings are answered
+// with pongs; close frames are echoed per RFC 6455 §5.5.1 unless the server
+// sent one first, in which case the client's frame completes the handshake.
+func (c *wsConn) readLoop() {
+	for {
+		op, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return
+			}
+		case wsOpClose:
+			if !c.closeSent.Load() {
+				_ = c.writeFrame(wsOpClose, payload)
+			}
+			return
+		}
+	}
+}
+
+// readFrame reads one masked client frame and returns its opcode and
+// unmasked payload.
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return 0, nil, err
+	}
+	op := h[0] & 0x0F
+	if h[1]&0x80 == 0 {
+		return 0, nil, errors.New("client frame not masked")
+	}
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxClientFrame {
+		return 0, nil, errors.New("client frame too large")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
+
+// headerHasToken reports whether a comma-separated header contains token
+// (case-insensitive), e.g. "Connection: keep-alive, Upgrade".
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
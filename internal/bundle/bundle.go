@@ -0,0 +1,173 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// ManifestName is the file Close appends to every bundle.
+const ManifestName = "manifest.json"
+
+// File is one file written to a bundle.
+type File struct {
+	Name  string `json:"name"`
+	Bytes int    `json:"bytes"`
+}
+
+// Skipped is a part of the bundle that could not be collected.
+type Skipped struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Manifest describes a bundle; it is written last as ManifestName.
+type Manifest struct {
+	GeneratedAt string    `json:"generated_at"`
+	OS          string    `json:"os"`
+	Arch        string    `json:"arch"`
+	GoVersion   string    `json:"go_version"`
+	Files       []File    `json:"files"`
+	Skipped     []Skipped `json:"skipped"`
+}
+
+// Writer writes a bundle to an underlying io.Writer. It is not safe for
+// concurrent use.
+type Writer struct {
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	now     time.Time
+	files   []File
+	skipped []Skipped
+}
+
+// NewWriter starts a bundle on w; every entry is stamped with now.
+func NewWriter(w io.Writer, now time.Time) *Writer {
+	gz := gzip.NewWriter(w)
+	return &Writer{gz: gz, tw: tar.NewWriter(gz), now: now, files: []File{}, skipped: []Skipped{}}
+}
+
+// Add writes data as name (a slash-separated relative path).
+func (w *Writer) Add(name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: w.now,
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := w.tw.Write(data); err != nil {
+		return err
+	}
+	w.files = append(w.files, File{Name: name, Bytes: len(data)})
+	return nil
+}
+
+// AddJSON writes v as indented JSON.
+func (w *Writer) AddJSON(name string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		w.Skip(name, err)
+		return nil
+	}
+	return w.Add(name, append(b, '\n'))
+}
+
+// Skip records that name could not be collected. The reason is redacted.
+func (w *Writer) Skip(name string, err error) {
+	w.skipped = append(w.skipped, Skipped{Name: name, Reason: redact.String(err.Error())})
+}
+
+// Files returns the files written so far, in order.
+func (w *Writer) Files() []File { return append([]File(nil), w.files...) }
+
+// Skipped returns the parts skipped so far, in order.
+func (w *Writer) Skipped() []Skipped { return append([]Skipped(nil), w.skipped...) }
+
+// Close writes the manifest and flushes the archive. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	m := Manifest{
+		GeneratedAt: w.now.UTC().Format(time.RFC3339),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		GoVersion:   runtime.Version(),
+		Files:       w.Files(),
+		Skipped:     w.Skipped(),
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return errors.Join(w.Add(ManifestName, append(b, '\n')), w.tw.Close(), w.gz.Close())
+}
+
+// RedactJSON returns doc re-encoded with sensitive values masked.
+func RedactJSON(doc []byte) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactValue(v), "", "  ")
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if s, ok := e.(string); ok && s != "" && redact.SensitiveKey(k) {
+				v[k] = redact.Mask
+			} else {
+				v[k] = redactValue(e)
+			}
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = redactValue(e)
+		}
+	case string:
+		return redact.String(v)
+	}
+	return v
+}
+
+// Tail returns up to the last limit bytes of the file at path, starting at
+// a line boundary, with each line redacted.
+func Tail(path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	off := max(fi.Size()-limit, 0)
+	b, err := io.ReadAll(io.NewSectionReader(f, off, fi.Size()-off))
+	if err != nil {
+		return nil, err
+	}
+	if off > 0 {
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			b = b[i+1:]
+		}
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	var out bytes.Buffer
+	for _, l := range lines {
+		out.WriteString(redact.String(l))
+	}
+	return out.Bytes(), nil
+}
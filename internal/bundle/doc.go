@@ -0,0 +1,29 @@
+// Package bundle writes diagnostics bundles: gzip-compressed tar archives
+// that collect what a bug report needs in one file.
+//
+// # Writer
+//
+// A Writer adds named files to the archive and remembers each one, along
+// with the parts it could not collect (Skip). Close appends manifest.json
+// listing both, plus the platform and Go version, so a reader can tell a
+// missing file from one that was never attempted. Failures to collect one
+// part never abort the bundle.
+//
+// # Host Dumps
+//
+// Host runs read-only commands and adds their output under host/:
+//
+//   - linux:  `ip route show table all` (IPv4 and IPv6), `ip rule show`,
+//     and `ip addr show`.
+//   - darwin: `netstat -rn`, `ifconfig -a`, and `scutil --dns`.
+//   - others: nothing.
+//
+// Interfaces as the agent sees them are added separately by the caller.
+//
+// # Redaction
+//
+// RedactJSON masks the values of sensitive keys (redact.SensitiveKey) and
+// credentials inside string values of a JSON document, such as the agent's
+// config file. Tail reads at most the end of a log file and redacts each
+// line. Callers must pass other text through package redact themselves.
+package bundle
@@ -0,0 +1,46 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// hostCommandTimeout bounds each host command.
+const hostCommandTimeout = 3 * time.Second
+
+// hostCommand is one read-only command whose output becomes host/<Name>.
+type hostCommand struct {
+	Name string
+	Args []string
+}
+
+// Host runs the platform's host commands and adds their output, or a Skip
+// for each command that fails or is not installed.
+func Host(ctx context.Context, w *Writer) error {
+	for _, c := range hostCommands {
+		name := "host/" + c.Name
+		out, err := runHost(ctx, c.Args)
+		if err != nil {
+			w.Skip(name, err)
+			continue
+		}
+		if err := w.Add(name, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runHost(ctx context.Context, args []string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, hostCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return nil, errors.New(strings.Join(args, " ") + ": " + strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return out, err
+}
@@ -0,0 +1,9 @@
+//go:build darwin
+
+package bundle
+
+var hostCommands = []hostCommand{
+	{Name: "routes.txt", Args: []string{"netstat", "-rn"}},
+	{Name: "ifconfig.txt", Args: []string{"ifconfig", "-a"}},
+	{Name: "dns.txt", Args: []string{"scutil", "--dns"}},
+}
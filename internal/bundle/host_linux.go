@@ -0,0 +1,10 @@
+//go:build linux
+
+package bundle
+
+var hostCommands = []hostCommand{
+	{Name: "routes.txt", Args: []string{"ip", "route", "show", "table", "all"}},
+	{Name: "routes6.txt", Args: []string{"ip", "-6", "route", "show", "table", "all"}},
+	{Name: "rules.txt", Args: []string{"ip", "rule", "show"}},
+	{Name: "addrs.txt", Args: []string{"ip", "addr", "show"}},
+}
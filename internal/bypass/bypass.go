@@ -0,0 +1,172 @@
+package bypass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Kind classifies an input entry.
+type Kind string
+
+const (
+	KindIP       Kind = "ip"
+	KindCIDR     Kind = "cidr"
+	KindHostname Kind = "hostname"
+)
+
+// DefaultResolveTimeout bounds resolution of all hostnames in one call.
+const DefaultResolveTimeout = 3 * time.Second
+
+// MaxEntries caps the list size to keep validation and routing bounded.
+const MaxEntries = 256
+
+// Resolver looks up host addresses; *net.Resolver satisfies it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Entry is one normalized input.
+type Entry struct {
+	Input    string         // trimmed input as given
+	Kind     Kind           // ip, cidr, or hostname
+	Prefixes []netip.Prefix // masked prefixes to route outside the TUN
+}
+
+// Normalize validates entries and returns them normalized, in input order.
+// Hostnames are resolved with r within DefaultResolveTimeout.
+func Normalize(ctx context.Context, r Resolver, entries []string) ([]Entry, error) {
+	if len(entries) > MaxEntries {
+		return nil, fmt.Errorf("too many bypass hosts (%d > %d)", len(entries), MaxEntries)
+	}
+	ctx, cancel := context.WithTimeout(ctx, DefaultResolveTimeout)
+	defer cancel()
+
+	out := make([]Entry, 0, len(entries))
+	for _, raw := range entries {
+		e, err := parse(ctx, r, raw)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	if err := checkOverlaps(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func parse(ctx context.Context, r Resolver, raw string) (Entry, error) {
+	in := strings.TrimSpace(raw)
+	e := Entry{Input: in}
+	if in == "" {
+		return e, errors.New("empty bypass host")
+	}
+	if strings.Contains(in, "/") {
+		p, err := netip.ParsePrefix(in)
+		if err != nil {
+			return e, fmt.Errorf("invalid CIDR %q: %w", in, err)
+		}
+		if p.Addr().Zone() != "" {
+			return e, fmt.Errorf("invalid CIDR %q: zones are not allowed", in)
+		}
+		e.Kind = KindCIDR
+		e.Prefixes = []netip.Prefix{unmapPrefix(p).Masked()}
+		return e, nil
+	}
+	if a, err := netip.ParseAddr(in); err == nil {
+		if a.Zone() != "" {
+			return e, fmt.Errorf("invalid IP %q: zones are not allowed", in)
+		}
+		e.Kind = KindIP
+		e.Prefixes = []netip.Prefix{hostPrefix(a)}
+		return e, nil
+	}
+	if !validHostname(in) {
+		return e, fmt.Errorf("invalid bypass host %q: not an IP, CIDR, or hostname", in)
+	}
+	e.Kind = KindHostname
+	addrs, err := r.LookupNetIP(ctx, "ip", in)
+	if err != nil {
+		return e, fmt.Errorf("resolve %q: %w", in, err)
+	}
+	if len(addrs) == 0 {
+		return e, fmt.Errorf("resolve %q: no addresses", in)
+	}
+	seen := map[netip.Prefix]bool{}
+	for _, a := range addrs {
+		p := hostPrefix(a.WithZone(""))
+		if !seen[p] {
+			seen[p] = true
+			e.Prefixes = append(e.Prefixes, p)
+		}
+	}
+	return e, nil
+}
+
+// checkOverlaps rejects identical or nested prefixes across entries.
+func checkOverlaps(entries []Entry) error {
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			for _, p := range entries[i].Prefixes {
+				for _, q := range entries[j].Prefixes {
+					switch {
+					case p == q:
+						return fmt.Errorf("duplicate bypass host: %q and %q both cover %s", entries[i].Input, entries[j].Input, p)
+					case p.Overlaps(q):
+						return fmt.Errorf("overlapping bypass hosts: %q (%s) and %q (%s)", entries[i].Input, p, entries[j].Input, q)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Prefixes flattens entries into their prefixes, in order.
+func Prefixes(entries []Entry) []netip.Prefix {
+	var out []netip.Prefix
+	for _, e := range entries {
+		out = append(out, e.Prefixes...)
+	}
+	return out
+}
+
+func hostPrefix(a netip.Addr) netip.Prefix {
+	a = a.Unmap()
+	return netip.PrefixFrom(a, a.BitLen())
+}
+
+func unmapPrefix(p netip.Prefix) netip.Prefix {
+	a := p.Addr()
+	if !a.Is4In6() {
+		return p
+	}
+	bits := p.Bits() - 96
+	if bits < 0 {
+		bits = 0
+	}
+	return netip.PrefixFrom(a.Unmap(), bits)
+}
+
+// validHostname applies RFC 1123 label rules; a trailing dot is allowed.
+func validHostname(h string) bool {
+	h = strings.TrimSuffix(h, ".")
+	if h == "" || len(h) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(h, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
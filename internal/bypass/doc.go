@@ -0,0 +1,23 @@
+// Package bypass validates and normalizes the hosts a caller wants routed
+// outside the TUN device.
+//
+// # Input Forms
+//
+// Each entry may be an IP address ("192.0.2.10", "2001:db8::1"), a CIDR
+// ("10.0.0.0/8"), or a hostname ("proxy.example.com"). Hostnames are
+// resolved once, at validation time, to every A/AAAA address; routes are
+// pinned to those addresses, not re-resolved later.
+//
+// # Normalization
+//
+// Addresses become host prefixes (/32 or /128), CIDRs are masked to their
+// network address, IPv4-mapped IPv6 addresses are unmapped, and zones are
+// rejected. The result preserves input order.
+//
+// # Rejections
+//
+// Normalize rejects malformed entries, unresolvable hostnames, and any two
+// entries whose prefixes are identical (duplicates) or overlap (one contains
+// the other). Overlap is an error rather than being merged silently because
+// it usually means the caller's list is wrong.
+package bypass
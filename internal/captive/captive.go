@@ -0,0 +1,235 @@
+package captive
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// DefaultInterval is the pause between checks.
+const DefaultInterval = 60 * time.Second
+
+// fetchTimeout bounds one check URL.
+const fetchTimeout = 5 * time.Second
+
+// maxBody bounds the body read from a check URL.
+const maxBody = 16 << 10
+
+// States of a check.
+const (
+	StateUnknown = "unknown" // not checked yet
+	StateClear   = "clear"   // a check URL answered as expected
+	StatePortal  = "portal"  // check URLs were answered by something else
+	StateOffline = "offline" // no check URL could be reached
+)
+
+// Endpoint is a well-known connectivity check URL and the answer it gives
+// on an open network.
+type Endpoint struct {
+	URL    string
+	Status int    // expected status code
+	Body   string // expected body substring; "" checks the status only
+}
+
+// DefaultEndpoints are the operating systems' own portal checks.
+var DefaultEndpoints = []Endpoint{
+	{URL: "http://connectivitycheck.gstatic.com/generate_204", Status: http.StatusNoContent},
+	{URL: "http://captive.apple.com/hotspot-detect.html", Status: http.StatusOK, Body: "Success"},
+	{URL: "http://www.msftconnecttest.com/connecttest.txt", Status: http.StatusOK, Body: "Microsoft Connect Test"},
+}
+
+// Options configures a Detector.
+type Options struct {
+	// Endpoints are the check URLs (default DefaultEndpoints).
+	Endpoints []Endpoint
+	// Interface, when set, names the uplink to bind the checks to, so they
+	// leave the host outside the tunnel; "" uses the routing table.
+	Interface func() string
+	// Interval is the pause between checks in Run (default
+	// DefaultInterval).
+	Interval time.Duration
+	// OnChange, when set, is called when the state changes.
+	OnChange func(prev, next Status)
+	// Logger receives detector records. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Result is one check URL's answer.
+type Result struct {
+	URL      string
+	OK       bool   // answered as on an open network
+	Status   int    // 0 when no response was read
+	Location string // redirect target, typically the portal's login page
+	Error    string
+	Latency  time.Duration
+}
+
+// Status is the detector's state.
+type Status struct {
+	State     string
+	PortalURL string // login page when known (redirect target), else the intercepted URL
+	Interface string // uplink the checks were bound to; "" for the routing table
+	Results   []Result
+	Checked   time.Time
+	Since     time.Time // when State last changed
+}
+
+// Detector checks for captive portals.
+type Detector struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New constructs a detector; call Check or Run.
+func New(opts Options) *Detector {
+	if len(opts.Endpoints) == 0 {
+		opts.Endpoints = DefaultEndpoints
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Detector{opts: opts, logger: logger, status: Status{State: StateUnknown}}
+}
+
+// Status returns the result of the latest check.
+func (d *Detector) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	st := d.status
+	st.Results = append([]Result(nil), st.Results...)
+	return st
+}
+
+// Check fetches every check URL in parallel and returns the new state. One
+// expected answer means the network is open; otherwise any answer at all
+// means something intercepted the requests.
+func (d *Detector) Check(ctx context.Context) Status {
+	var iface string
+	if d.opts.Interface != nil {
+		iface = d.opts.Interface()
+	}
+	client := newClient(iface)
+	defer client.CloseIdleConnections()
+
+	results := make([]Result, len(d.opts.Endpoints))
+	var wg sync.WaitGroup
+	for i, ep := range d.opts.Endpoints {
+		wg.Go(func() { results[i] = fetch(ctx, client, ep) })
+	}
+	wg.Wait()
+
+	next := Status{State: StateOffline, Interface: iface, Results: results, Checked: time.Now()}
+	var location, intercepted string
+	for _, r := range results {
+		if r.OK {
+			next.State = StateClear
+			break
+		}
+		if r.Status != 0 {
+			next.State = StatePortal
+			location = cmp.Or(location, r.Location)
+			intercepted = cmp.Or(intercepted, r.URL)
+		}
+	}
+	if next.State == StatePortal {
+		next.PortalURL = cmp.Or(location, intercepted)
+	}
+
+	d.mu.Lock()
+	prev := d.status
+	next.Since = prev.Since
+	if next.State != prev.State {
+		next.Since = next.Checked
+	}
+	d.status = next
+	d.mu.Unlock()
+
+	if next.State != prev.State {
+		d.logger.Info("captive portal state changed", "from", prev.State, "to", next.State, "portal", next.PortalURL, "interface", iface)
+		if d.opts.OnChange != nil {
+			d.opts.OnChange(prev, next)
+		}
+	}
+	return next
+}
+
+// Run checks every interval until ctx is done.
+func (d *Detector) Run(ctx context.Context) {
+	t := time.NewTicker(d.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			d.Check(ctx)
+		}
+	}
+}
+
+// fetch requests ep once.
+func fetch(ctx context.Context, client *http.Client, ep Endpoint) Result {
+	r := Result{URL: ep.URL}
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ep.URL, nil)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	req.Header.Set("Cache-Control", "no-cache")
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		r.Latency = time.Since(start)
+		r.Error = err.Error()
+		return r
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	r.Latency = time.Since(start)
+	r.Status = resp.StatusCode
+	r.Location = resp.Header.Get("Location")
+	switch {
+	case err != nil && !errors.Is(err, io.ErrUnexpectedEOF):
+		r.Error = "read body: " + err.Error()
+	case resp.StatusCode != ep.Status:
+		r.Error = fmt.Sprintf("status %d, want %d", resp.StatusCode, ep.Status)
+	case ep.Body != "" && !strings.Contains(string(body), ep.Body):
+		r.Error = fmt.Sprintf("body does not contain %q", ep.Body)
+	default:
+		r.OK = true
+	}
+	return r
+}
+
+// newClient returns a client that goes direct (no proxy from the
+// environment), never follows redirects (a portal's redirect is the
+// signal), and binds to iface when set.
+func newClient(iface string) *http.Client {
+	dialer := netinfo.BoundDialer(iface, fetchTimeout)
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:             nil,
+			DialContext:       dialer.DialContext,
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
@@ -0,0 +1,28 @@
+// Package captive detects captive portals (hotel, airport, and café
+// Wi-Fi login pages) on the host's uplink, so the agent does not start a
+// tunnel that cannot connect until someone signs in.
+//
+// # Checks
+//
+// A check fetches the operating systems' well-known connectivity URLs
+// (DefaultEndpoints: Google's generate_204, Apple's hotspot-detect, and
+// Microsoft's connecttest) in parallel, directly: no proxy from the
+// environment, no redirects followed, and, when Options.Interface names
+// the uplink, bound to it (see netinfo.BoundDialer) so the requests leave
+// outside the tunnel while it is up. Name
+// resolution still uses the system resolver.
+//
+// # States
+//
+//   - clear: at least one URL answered exactly as on an open network.
+//   - portal: none did, but something answered, typically a redirect to
+//     a login page; PortalURL is the redirect target when there is one,
+//     else the first intercepted URL.
+//   - offline: no URL could be reached at all.
+//   - unknown: not checked yet.
+//
+// A Detector checks every interval (default 60s) and reports state
+// changes to Options.OnChange. The agent records them as events, serves
+// the status at GET /v1/network/captive, and holds back starts that would
+// pick their profile automatically while a portal is detected.
+package captive
@@ -0,0 +1,399 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/api"
+)
+
+// DefaultTimeout bounds a single API call when no http.Client is supplied.
+const DefaultTimeout = 30 * time.Second
+
+// DefaultUserAgent identifies the client in GET /v1/clients unless
+// SetUserAgent overrides it.
+const DefaultUserAgent = "spl-client"
+
+// Error is returned for non-2xx API responses.
+type Error struct {
+	Status  int               // HTTP status code
+	Message string            // APIError.Error, or the raw body if it was not JSON
+	Code    string            // APIError.Code (e.g. api.CodeRateLimited); empty if not JSON
+	Details map[string]string // APIError.Details
+	Body    []byte            // raw response body, e.g. a failed start's partial StartResponse
+	// RequestID is the X-Request-ID the agent answered with, for matching
+	// the failure against agent logs and events.
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api error %d: %s", e.Status, e.Message)
+}
+
+// Client talks to one agent.
+type Client struct {
+	base  string
+	token string
+	ua    string
+	http  *http.Client
+}
+
+// New constructs a client for addr: "host:port", a full http(s):// URL, or
+// "unix:///path/to.sock" for a unix socket listener. token, when non-empty,
+// is sent as a bearer token. A nil hc uses a client with DefaultTimeout.
+func New(addr, token string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = &http.Client{Timeout: DefaultTimeout}
+	}
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		// Route every request over the socket; the URL host is a placeholder.
+		unixClient := *hc
+		unixClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		return &Client{base: "http://unix", token: token, ua: DefaultUserAgent, http: &unixClient}
+	}
+	base := strings.TrimRight(addr, "/")
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	return &Client{base: base, token: token, ua: DefaultUserAgent, http: hc}
+}
+
+// SetUserAgent sets the User-Agent sent with every request, so the agent
+// can tell applications apart.
+func (c *Client) SetUserAgent(ua string) {
+	c.ua = ua
+}
+
+// Healthz calls GET /v1/healthz.
+func (c *Client) Healthz(ctx context.Context) (map[string]string, error) {
+	var out map[string]string
+	err := c.do(ctx, http.MethodGet, "/healthz", nil, &out)
+	return out, err
+}
+
+// Status calls GET /v1/status.
+func (c *Client) Status(ctx context.Context) (api.StatusResponse, error) {
+	var out api.StatusResponse
+	err := c.do(ctx, http.MethodGet, "/status", nil, &out)
+	return out, err
+}
+
+// Probe calls POST /v1/probe.
+func (c *Client) Probe(ctx context.Context, req api.ProbeRequest) (api.ProbeView, error) {
+	var out api.ProbeView
+	err := c.do(ctx, http.MethodPost, "/probe", req, &out)
+	return out, err
+}
+
+// Start calls POST /v1/start. When a step fails, the partial response
+// (steps reached so far) is returned along with the error.
+func (c *Client) Start(ctx context.Context, req api.StartRequest) (api.StartResponse, error) {
+	var out api.StartResponse
+	err := c.do(ctx, http.MethodPost, "/start", req, &out)
+	partial(err, &out)
+	return out, err
+}
+
+// Stop calls POST /v1/stop; partial results are returned as with Start.
+func (c *Client) Stop(ctx context.Context, req api.StopRequest) (api.StopResponse, error) {
+	var out api.StopResponse
+	err := c.do(ctx, http.MethodPost, "/stop", req, &out)
+	partial(err, &out)
+	return out, err
+}
+
+// partial decodes the body of an API error into out, leaving out untouched
+// if the body is not a response of that shape.
+func partial(err error, out any) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) && len(apiErr.Body) > 0 {
+		_ = json.Unmarshal(apiErr.Body, out)
+	}
+}
+
+// StartAsync calls POST /v1/start in async mode; poll Operation with the
+// returned ID for progress. req.Async is set for the caller.
+func (c *Client) StartAsync(ctx context.Context, req api.StartRequest) (api.OperationAccepted, error) {
+	req.Async = true
+	var out api.OperationAccepted
+	err := c.do(ctx, http.MethodPost, "/start", req, &out)
+	return out, err
+}
+
+// StopAsync calls POST /v1/stop in async mode.
+func (c *Client) StopAsync(ctx context.Context, req api.StopRequest) (api.OperationAccepted, error) {
+	req.Async = true
+	var out api.OperationAccepted
+	err := c.do(ctx, http.MethodPost, "/stop", req, &out)
+	return out, err
+}
+
+// Pause calls POST /v1/pause; partial results are returned as with Start.
+func (c *Client) Pause(ctx context.Context) (api.PauseResponse, error) {
+	var out api.PauseResponse
+	err := c.do(ctx, http.MethodPost, "/pause", api.PauseRequest{}, &out)
+	partial(err, &out)
+	return out, err
+}
+
+// PauseAsync calls POST /v1/pause in async mode.
+func (c *Client) PauseAsync(ctx context.Context) (api.OperationAccepted, error) {
+	var out api.OperationAccepted
+	err := c.do(ctx, http.MethodPost, "/pause", api.PauseRequest{Async: true}, &out)
+	return out, err
+}
+
+// Resume calls POST /v1/resume; partial results are returned as with Start.
+func (c *Client) Resume(ctx context.Context) (api.PauseResponse, error) {
+	var out api.PauseResponse
+	err := c.do(ctx, http.MethodPost, "/resume", api.PauseRequest{}, &out)
+	partial(err, &out)
+	return out, err
+}
+
+// ResumeAsync calls POST /v1/resume in async mode.
+func (c *Client) ResumeAsync(ctx context.Context) (api.OperationAccepted, error) {
+	var out api.OperationAccepted
+	err := c.do(ctx, http.MethodPost, "/resume", api.PauseRequest{Async: true}, &out)
+	return out, err
+}
+
+// Operation calls GET /v1/operations/{id}.
+func (c *Client) Operation(ctx context.Context, id string) (api.OperationView, error) {
+	var out api.OperationView
+	err := c.do(ctx, http.MethodGet, "/operations/"+url.PathEscape(id), nil, &out)
+	return out, err
+}
+
+// Shutdown calls POST /v1/shutdown. The agent exits after answering.
+func (c *Client) Shutdown(ctx context.Context, req api.ShutdownRequest) (api.ShutdownResponse, error) {
+	var out api.ShutdownResponse
+	err := c.do(ctx, http.MethodPost, "/shutdown", req, &out)
+	return out, err
+}
+
+// Upgrade calls POST /v1/upgrade. The agent answering exits after the
+// new one serves.
+func (c *Client) Upgrade(ctx context.Context, req api.UpgradeRequest) (api.UpgradeResponse, error) {
+	var out api.UpgradeResponse
+	err := c.do(ctx, http.MethodPost, "/upgrade", req, &out)
+	return out, err
+}
+
+// ClearWarnings calls POST /v1/warnings/clear; a zero req clears every
+// warning.
+func (c *Client) ClearWarnings(ctx context.Context, req api.WarningsClearRequest) (api.WarningsClearResponse, error) {
+	var out api.WarningsClearResponse
+	err := c.do(ctx, http.MethodPost, "/warnings/clear", req, &out)
+	return out, err
+}
+
+// ActiveProbes calls GET /v1/probe/active.
+func (c *Client) ActiveProbes(ctx context.Context) (api.ActiveProbesResponse, error) {
+	var out api.ActiveProbesResponse
+	err := c.do(ctx, http.MethodGet, "/probe/active", nil, &out)
+	return out, err
+}
+
+// CancelProbes calls DELETE /v1/probe/active; an empty id cancels every
+// probe in flight.
+func (c *Client) CancelProbes(ctx context.Context, id string) (api.ProbeCancelResponse, error) {
+	path := "/probe/active"
+	if id != "" {
+		path += "?" + url.Values{"id": {id}}.Encode()
+	}
+	var out api.ProbeCancelResponse
+	err := c.do(ctx, http.MethodDelete, path, nil, &out)
+	return out, err
+}
+
+// Captive calls GET /v1/network/captive; refresh checks now.
+func (c *Client) Captive(ctx context.Context, refresh bool) (api.CaptiveResponse, error) {
+	path := "/network/captive"
+	if refresh {
+		path += "?refresh=true"
+	}
+	var out api.CaptiveResponse
+	err := c.do(ctx, http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+// Egress calls GET /v1/egress; refresh skips cached lookups.
+func (c *Client) Egress(ctx context.Context, refresh bool) (api.EgressResponse, error) {
+	path := "/egress"
+	if refresh {
+		path += "?refresh=true"
+	}
+	var out api.EgressResponse
+	err := c.do(ctx, http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+// LeakTestDNS calls POST /v1/leaktest/dns.
+func (c *Client) LeakTestDNS(ctx context.Context, req api.LeakTestRequest) (api.LeakTestResponse, error) {
+	var out api.LeakTestResponse
+	err := c.do(ctx, http.MethodPost, "/leaktest/dns", req, &out)
+	return out, err
+}
+
+// Firewall calls GET /v1/firewall.
+func (c *Client) Firewall(ctx context.Context) (api.FirewallResponse, error) {
+	var out api.FirewallResponse
+	err := c.do(ctx, http.MethodGet, "/firewall", nil, &out)
+	return out, err
+}
+
+// Version calls GET /v1/version.
+func (c *Client) Version(ctx context.Context) (api.VersionResponse, error) {
+	var out api.VersionResponse
+	err := c.do(ctx, http.MethodGet, "/version", nil, &out)
+	return out, err
+}
+
+// Service calls GET /v1/service.
+func (c *Client) Service(ctx context.Context) (api.ServiceResponse, error) {
+	var out api.ServiceResponse
+	err := c.do(ctx, http.MethodGet, "/service", nil, &out)
+	return out, err
+}
+
+// ServiceInstall calls POST /v1/service/install; a zero req keeps the
+// agent's own arguments.
+func (c *Client) ServiceInstall(ctx context.Context, req api.ServiceInstallRequest) (api.ServiceResponse, error) {
+	var out api.ServiceResponse
+	err := c.do(ctx, http.MethodPost, "/service/install", req, &out)
+	return out, err
+}
+
+// ServiceUninstall calls POST /v1/service/uninstall.
+func (c *Client) ServiceUninstall(ctx context.Context) (api.ServiceResponse, error) {
+	var out api.ServiceResponse
+	err := c.do(ctx, http.MethodPost, "/service/uninstall", nil, &out)
+	return out, err
+}
+
+// Interfaces calls GET /v1/interfaces.
+func (c *Client) Interfaces(ctx context.Context) (api.InterfacesResponse, error) {
+	var out api.InterfacesResponse
+	err := c.do(ctx, http.MethodGet, "/interfaces", nil, &out)
+	return out, err
+}
+
+// Routes calls GET /v1/routes.
+func (c *Client) Routes(ctx context.Context) (api.RoutesResponse, error) {
+	var out api.RoutesResponse
+	err := c.do(ctx, http.MethodGet, "/routes", nil, &out)
+	return out, err
+}
+
+// EventsHistory calls GET /v1/events/history.
+func (c *Client) EventsHistory(ctx context.Context, afterID uint64, limit int) (api.EventsHistoryResponse, error) {
+	q := url.Values{}
+	q.Set("after_id", strconv.FormatUint(afterID, 10))
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out api.EventsHistoryResponse
+	err := c.do(ctx, http.MethodGet, "/events/history?"+q.Encode(), nil, &out)
+	return out, err
+}
+
+// Connections calls GET /v1/connections for the page after afterID; limit
+// 0 uses the server default.
+func (c *Client) Connections(ctx context.Context, afterID uint64, limit int) (api.ConnectionsResponse, error) {
+	q := url.Values{}
+	q.Set("after_id", strconv.FormatUint(afterID, 10))
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var out api.ConnectionsResponse
+	err := c.do(ctx, http.MethodGet, "/connections?"+q.Encode(), nil, &out)
+	return out, err
+}
+
+// Diagnostics calls POST /v1/diagnostics without a path and returns the
+// tar.gz bundle.
+func (c *Client) Diagnostics(ctx context.Context, req api.DiagnosticsRequest) ([]byte, error) {
+	req.Path = ""
+	var out []byte
+	err := c.do(ctx, http.MethodPost, "/diagnostics", req, &out)
+	return out, err
+}
+
+// DiagnosticsToPath calls POST /v1/diagnostics with req.Path, so the agent
+// writes the bundle on its own host.
+func (c *Client) DiagnosticsToPath(ctx context.Context, req api.DiagnosticsRequest) (api.DiagnosticsResponse, error) {
+	var out api.DiagnosticsResponse
+	err := c.do(ctx, http.MethodPost, "/diagnostics", req, &out)
+	return out, err
+}
+
+// do performs a JSON request against /v1+path and decodes a 2xx body into
+// out; a *[]byte out receives the raw body instead.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+"/"+api.APIVersion+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil || method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch {
+		// The agent refuses writes without it, even bodiless ones.
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.ua)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr api.APIError
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Error != "" {
+			return &Error{Status: resp.StatusCode, Message: apiErr.Error, Code: apiErr.Code, Details: apiErr.Details, Body: raw, RequestID: resp.Header.Get("X-Request-ID")}
+		}
+		return &Error{Status: resp.StatusCode, Message: strings.TrimSpace(string(raw)), Body: raw, RequestID: resp.Header.Get("X-Request-ID")}
+	}
+	if out == nil {
+		return nil
+	}
+	if b, ok := out.(*[]byte); ok {
+		*b = raw
+		return nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
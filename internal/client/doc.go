@@ -0,0 +1,15 @@
+// Package client is a thin Go client for the agent's HTTP control-plane API.
+//
+// # Overview
+//
+// Client wraps net/http with the /v1 prefix, JSON encoding, bearer-token
+// headers, and APIError decoding. Request and response bodies reuse the
+// public types from the api package so the client cannot drift from the
+// server's wire contract.
+//
+// # Error Model
+//
+// Non-2xx responses are returned as *Error, carrying the HTTP status and the
+// decoded APIError message, code, and details; branch on Code rather than
+// Message. Transport failures are returned unwrapped from net/http.
+package client
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// AppName is the directory name used under the user config dir.
+const AppName = "simple-packet-logger"
+
+// Config mirrors the JSON config file. Zero values mean "not set"; callers
+// apply their own defaults.
+type Config struct {
+	// Listen is the agent API address ("host:port").
+	Listen string `json:"listen,omitempty"`
+	// ListenTLS serves Listen over HTTPS with TLSCertFile and TLSKeyFile,
+	// or with a self-signed pair generated in DataDir when both are empty.
+	ListenTLS   bool   `json:"listen_tls,omitempty"`
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+	// Token is the API bearer token presented by clients.
+	Token string `json:"token,omitempty"`
+	// APITokens are bearer tokens the agent accepts, each with a scope.
+	// Defining any makes every listener require a token.
+	APITokens []APIToken `json:"api_tokens,omitempty"`
+	// LogLevel is one of debug, info, warn, error.
+	LogLevel string `json:"log_level,omitempty"`
+	// LogFormat is text or json.
+	LogFormat string `json:"log_format,omitempty"`
+	// DisplayTZ is an IANA timezone for *_local timestamp companions.
+	DisplayTZ string `json:"display_tz,omitempty"`
+	// ShutdownSecs bounds graceful shutdown.
+	ShutdownSecs int `json:"shutdown_secs,omitempty"`
+	// Storage selects the persistence backend: file, sqlite, or memory.
+	Storage string `json:"storage,omitempty"`
+	// DataDir holds persisted state, events, and reports.
+	DataDir string `json:"data_dir,omitempty"`
+	// Exports lists event/flow export sinks started by the agent.
+	Exports []Export `json:"exports,omitempty"`
+	// Probes are run by the full health sweep (POST /v1/healthcheck/full).
+	Probes []Probe `json:"probes,omitempty"`
+	// Listeners, when present, replaces Listen with several API listeners.
+	Listeners []Listener `json:"listeners,omitempty"`
+	// DNS, when present, enables the local DNS forwarder (see package dnsproxy).
+	DNS *DNS `json:"dns,omitempty"`
+	// OutboundInterfaces is the fallback order of physical interfaces used
+	// by the proxy connection and bypass routes (e.g. ["en7", "en0"]).
+	OutboundInterfaces []string `json:"outbound_interfaces,omitempty"`
+	// Health damps proxy health transitions shown in status.
+	Health *Health `json:"health,omitempty"`
+	// Failover tunes the uplink monitor (see package uplink).
+	Failover *Failover `json:"failover,omitempty"`
+	// RouteRepair tunes the route repairer (see package routerepair).
+	RouteRepair *RouteRepair `json:"route_repair,omitempty"`
+	// ProxyPin tunes proxy hostname re-resolution (see package proxypin).
+	ProxyPin *ProxyPin `json:"proxy_pin,omitempty"`
+	// Captive tunes captive portal detection (see package captive).
+	Captive *Captive `json:"captive,omitempty"`
+	// Egress tunes the public address lookup of GET /v1/egress (see
+	// package egress).
+	Egress *Egress `json:"egress,omitempty"`
+	// IPv6Leak tunes the IPv6 leak monitor and its kill switch (see
+	// package ipv6leak).
+	IPv6Leak *IPv6Leak `json:"ipv6_leak,omitempty"`
+	// Firewall tunes the host firewall integration (see package
+	// firewall).
+	Firewall *Firewall `json:"firewall,omitempty"`
+	// Helper sends privileged operations to a root helper instead of
+	// performing them in the agent (see package privhelper).
+	Helper *Helper `json:"helper,omitempty"`
+	// ProfileSelect tunes profile selection by network location (see
+	// package profiles).
+	ProfileSelect *ProfileSelect `json:"profile_select,omitempty"`
+	// RateLimits overrides the token buckets of rate-limited endpoints,
+	// keyed by endpoint: probe, start, or stop.
+	RateLimits map[string]RateLimit `json:"rate_limits,omitempty"`
+	// PolicyFile names a probe policy (see package policy) limiting the
+	// SOCKS servers and connect targets of callers without admin scope.
+	PolicyFile string `json:"policy_file,omitempty"`
+	// AllowedOrigins lists browser origins (e.g. "http://localhost:5173")
+	// allowed to call the API cross-origin. Empty disables CORS.
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	// Tun2Socks selects the tun2socks engine (see package tun2socks).
+	Tun2Socks *Tun2Socks `json:"tun2socks,omitempty"`
+	// DiagnosticsLogs lists log files (e.g. tun2socks output) whose tails
+	// POST /v1/diagnostics includes in the bundle.
+	DiagnosticsLogs []string `json:"diagnostics_logs,omitempty"`
+	// Pprof serves net/http/pprof at /debug/pprof/ to admin callers.
+	Pprof bool `json:"pprof,omitempty"`
+	// Hooks run executables on lifecycle transitions (see package hooks).
+	Hooks []Hook `json:"hooks,omitempty"`
+	// Webhooks POST events to HTTP endpoints (see package webhooks).
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+}
+
+// APIToken is a bearer token accepted by the agent. Only the digest of the
+// secret is kept in the file ("agent gen-token" prints both).
+type APIToken struct {
+	Name   string `json:"name"`
+	Scope  string `json:"scope,omitempty"` // "admin", "operate", or "read" (default)
+	SHA256 string `json:"sha256"`          // hex SHA-256 of the secret
+}
+
+// RateLimit overrides one endpoint's token bucket; zero fields keep the
+// built-in value.
+type RateLimit struct {
+	PerSec   float64 `json:"per_sec,omitempty"`  // refill rate
+	Burst    int     `json:"burst,omitempty"`    // requests allowed at once
+	Disabled bool    `json:"disabled,omitempty"` // no limit for this endpoint
+}
+
+// Health configures hysteresis for proxy health transitions, probe
+// regression warnings, and how many status warnings are kept; zero fields
+// use the defaults (3 failures, 2 successes, 30s hold; a 2x slowdown of at
+// least 5ms over 5 probes; 100 warnings, kept until cleared).
+type Health struct {
+	FailThreshold    int `json:"fail_threshold,omitempty"`    // consecutive failed probes to degrade
+	RecoverThreshold int `json:"recover_threshold,omitempty"` // consecutive successful probes to recover
+	MinHoldMS        int `json:"min_hold_ms,omitempty"`       // minimum time between transitions; -1 disables
+
+	RegressionFactor        float64 `json:"regression_factor,omitempty"`          // slowdown vs the median that warns; -1 disables
+	RegressionMinSamples    int     `json:"regression_min_samples,omitempty"`     // successful probes before latency is compared
+	RegressionMinIncreaseMS int     `json:"regression_min_increase_ms,omitempty"` // smallest slowdown that warns; -1 disables
+
+	ScoreWeights *ScoreWeights `json:"score_weights,omitempty"`
+
+	MaxWarnings  int `json:"max_warnings,omitempty"`   // distinct warnings kept in status
+	WarningTTLMS int `json:"warning_ttl_ms,omitempty"` // drop a warning not raised again for this long
+}
+
+// ScoreWeights weighs the parts of the health score; only ratios matter.
+// Zero fields use the defaults (50, 20, 15, 15); -1 leaves a part out.
+type ScoreWeights struct {
+	Reachability float64 `json:"reachability,omitempty"`
+	Latency      float64 `json:"latency,omitempty"`
+	Restarts     float64 `json:"restarts,omitempty"`
+	DNS          float64 `json:"dns,omitempty"`
+}
+
+// Tun2Socks selects the external tun2socks implementation.
+type Tun2Socks struct {
+	Engine    string   `json:"engine,omitempty"`     // xjasonlyu (default) or badvpn
+	Binary    string   `json:"binary,omitempty"`     // executable; default per engine, looked up in PATH
+	ExtraArgs []string `json:"extra_args,omitempty"` // appended to the engine's arguments
+}
+
+// Failover configures active/standby uplink failover.
+type Failover struct {
+	IntervalMS int  `json:"interval_ms,omitempty"` // pause between link checks; default 2000
+	Disabled   bool `json:"disabled,omitempty"`    // do not watch uplinks
+}
+
+// RouteRepair configures route drift repair while active.
+type RouteRepair struct {
+	IntervalMS int  `json:"interval_ms,omitempty"` // pause between route checks; default 10000
+	Disabled   bool `json:"disabled,omitempty"`    // leave drifted routes alone
+}
+
+// ProxyPin configures re-resolution of a proxy hostname while active.
+type ProxyPin struct {
+	IntervalMS int  `json:"interval_ms,omitempty"` // pause between lookups; default 60000
+	Disabled   bool `json:"disabled,omitempty"`    // keep the addresses resolved at start
+}
+
+// Captive configures captive portal detection on the uplink.
+type Captive struct {
+	IntervalMS int  `json:"interval_ms,omitempty"` // pause between checks; default 60000
+	Disabled   bool `json:"disabled,omitempty"`    // never check; starts are not held back
+}
+
+// Egress configures the egress identity lookup.
+type Egress struct {
+	Provider string `json:"provider,omitempty"` // lookup URL; default https://api.ipify.org?format=json
+	TTLMS    int    `json:"ttl_ms,omitempty"`   // reuse a lookup this long; default 60000
+	Disabled bool   `json:"disabled,omitempty"` // never contact a provider
+}
+
+// IPv6Leak configures the monitor of IPv6 egress outside an IPv4-only
+// tunnel.
+type IPv6Leak struct {
+	KillSwitch bool `json:"kill_switch,omitempty"` // block IPv6 on a leak until stop
+	IntervalMS int  `json:"interval_ms,omitempty"` // pause between checks; default 5000
+	Disabled   bool `json:"disabled,omitempty"`    // never check
+}
+
+// Firewall configures the host firewall integration.
+type Firewall struct {
+	Disabled bool `json:"disabled,omitempty"` // never install rules; the kill switch uses routes
+}
+
+// Helper configures the privileged helper.
+type Helper struct {
+	Socket string `json:"socket,omitempty"` // default privhelper.DefaultSocket
+	Path   string `json:"path"`             // installed helper executable, checked at startup
+	SHA256 string `json:"sha256"`           // pinned digest from "agent helper install"
+}
+
+// ProfileSelect configures the network location watcher.
+type ProfileSelect struct {
+	Mode       string `json:"mode,omitempty"`        // "off", "suggest" (default), or "apply"
+	IntervalMS int    `json:"interval_ms,omitempty"` // pause between location checks; default 10000
+}
+
+// DNS configures the local DNS forwarder started with the tunnel.
+type DNS struct {
+	Upstream      string   `json:"upstream,omitempty"`       // tcp://, tls://, or https:// URL; default tcp://1.1.1.1:53
+	Upstreams     []string `json:"upstreams,omitempty"`      // fallback order; replaces upstream
+	Port          int      `json:"port,omitempty"`           // listening port on the TUN address; default 53
+	TimeoutMS     int      `json:"timeout_ms,omitempty"`     // per query; default 5000
+	KeepResolvers bool     `json:"keep_resolvers,omitempty"` // do not point the system resolvers at the forwarder
+}
+
+// Listener mirrors api.ListenerConfig in the config file.
+type Listener struct {
+	Network     string `json:"network,omitempty"` // "tcp" (default) or "unix"
+	Addr        string `json:"addr"`
+	Scope       string `json:"scope,omitempty"` // "admin" (default), "operate", or "read"
+	Token       string `json:"token,omitempty"`
+	TLSCertFile string `json:"tls_cert_file,omitempty"`
+	TLSKeyFile  string `json:"tls_key_file,omitempty"`
+	SocketMode  uint32 `json:"socket_mode,omitempty"` // e.g. 432 (0660); default 0600
+	Optional    bool   `json:"optional,omitempty"`    // bind failure degrades instead of exiting
+}
+
+// Probe configures one health-sweep probe (see package probe).
+type Probe struct {
+	Name      string            `json:"name,omitempty"` // label in reports; defaults to Type
+	Type      string            `json:"type"`           // socks5, tcp, or a registered plugin
+	Target    string            `json:"target"`         // "host:port"
+	TimeoutMS int               `json:"timeout_ms,omitempty"`
+	Options   map[string]string `json:"options,omitempty"`
+}
+
+// Export configures one export sink (see package export).
+type Export struct {
+	Name    string            `json:"name,omitempty"` // label for logs; defaults to Type
+	Type    string            `json:"type"`           // jsonl, syslog, netflow, or a registered plugin
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// Hook runs an executable when the agent enters a state (see package
+// hooks).
+type Hook struct {
+	Name      string   `json:"name,omitempty"` // label in events; defaults to the command's base name
+	On        string   `json:"on"`             // agent state, e.g. active, degraded, inactive
+	Command   string   `json:"command"`        // executable path
+	Args      []string `json:"args,omitempty"`
+	TimeoutMS int      `json:"timeout_ms,omitempty"` // default 10000
+}
+
+// Webhook POSTs matching events to a URL (see package webhooks).
+type Webhook struct {
+	Name        string   `json:"name,omitempty"` // label in events; defaults to the URL's host
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret,omitempty"`       // HMAC-SHA256 key for X-SPL-Signature
+	Types       []string `json:"types,omitempty"`        // event types; all when empty
+	MinSeverity string   `json:"min_severity,omitempty"` // info (default), warning, or error
+	TimeoutMS   int      `json:"timeout_ms,omitempty"`   // per attempt; default 10000
+	MaxRetries  int      `json:"max_retries,omitempty"`  // default 3; -1 disables retries
+}
+
+// DefaultPath returns the per-user config file location, or "" if the
+// user config directory cannot be determined.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, AppName, "config.json")
+}
+
+// DefaultDataPath returns name inside the per-user application directory
+// (the directory holding the default config file), or "" if unknown.
+func DefaultDataPath(name string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, AppName, name)
+}
+
+// Load reads and strictly decodes the config at path. A missing file yields
+// the zero Config and no error; an empty path does the same.
+func Load(path string) (Config, error) {
+	var cfg Config
+	if path == "" {
+		return cfg, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("read config: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,36 @@
+// Package config loads the shared on-disk configuration file.
+//
+// # Overview
+//
+// Both the agent and the spctl client read the same JSON file so that the
+// API address (and, once enabled, the access token) is configured in one
+// place. Command-line flags always take precedence over file values.
+//
+// # Location
+//
+// DefaultPath resolves to <UserConfigDir>/simple-packet-logger/config.json
+// (e.g., ~/Library/Application Support/... on macOS, ~/.config/... on Linux).
+// A missing file is not an error; Load returns the zero Config.
+//
+// # Format
+//
+//	{
+//	  "listen": "127.0.0.1:8787",
+//	  "token": "",
+//	  "log_level": "info",
+//	  "log_format": "text",
+//	  "display_tz": "",
+//	  "shutdown_secs": 5,
+//	  "listeners": [
+//	    {"network": "tcp", "addr": "127.0.0.1:8787"},
+//	    {"network": "unix", "addr": "/tmp/spl.sock", "scope": "read"},
+//	    {"addr": "192.168.1.10:8788", "token": "...",
+//	     "tls_cert_file": "cert.pem", "tls_key_file": "key.pem"}
+//	  ]
+//	}
+//
+// When "listeners" is present it replaces "listen" on the agent; clients
+// still use "listen" (or -addr) to pick the address they connect to.
+//
+// Unknown fields are rejected so typos surface at startup.
+package config
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// Connection states.
+const (
+	ConnConnecting  = "connecting"  // waiting for the proxy to accept the CONNECT or ASSOCIATE
+	ConnEstablished = "established" // relaying in both directions
+	ConnClosing     = "closing"     // one direction has ended
+)
+
+// Connection is one flow an engine is relaying. Up is from the host to
+// the destination, down the reverse; byte counts are payload only.
+type Connection struct {
+	ID        uint64 // unique per agent run, increasing
+	Engine    string // engine relaying it
+	Proto     string // "tcp" or "udp"
+	Src       netip.AddrPort
+	Dst       netip.AddrPort
+	State     string // ConnConnecting, ConnEstablished, or ConnClosing
+	BytesUp   uint64
+	BytesDown uint64
+	StartedAt time.Time
+}
+
+// ConnectionsSnapshot is the set of live connections at UpdatedAt,
+// ordered by ID.
+type ConnectionsSnapshot struct {
+	UpdatedAt   time.Time // zero before the first report
+	Connections []Connection
+}
+
+// UpdateConnections replaces the live connections. Reports arrive every
+// second or so while traffic flows, so they are not a mutation: they do
+// not bump Rev or signal Changed, and are not persisted.
+func (s *State) UpdateConnections(c ConnectionsSnapshot) {
+	conns := slices.Clone(c.Connections)
+	slices.SortFunc(conns, func(a, b Connection) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connections = ConnectionsSnapshot{UpdatedAt: c.UpdatedAt, Connections: conns}
+}
+
+// Connections returns a copy of the last reported connections.
+func (s *State) Connections() ConnectionsSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c := s.connections
+	c.Connections = slices.Clone(c.Connections)
+	return c
+}
@@ -18,14 +18,16 @@
 // AgentState reflects the coarse lifecycle:
 //   inactive -> starting | active
 //   starting -> active | error | inactive
-//   active   -> degraded | stopping | error
-//   degraded -> active | stopping | error
+//   active   -> degraded | paused | stopping | error
+//   degraded -> active | paused | stopping | error
+//   paused   -> active | stopping | error
 //   stopping -> inactive | error
 //   error    -> inactive | starting
 //
-// SetAgentState enforces these transitions. On the first transition to Active,
-// startedAt is set. Transition to Inactive clears startedAt. Uptime derives
-// from startedAt.
+// SetAgentState enforces these transitions, which come from one table that
+// AgentStates and Transitions() also expose for rendering the lifecycle.
+// On the first transition to Active, startedAt is set. Transition to
+// Inactive clears startedAt. Uptime derives from startedAt.
 //
 // Snapshots
 //
@@ -34,7 +36,37 @@
 // - Tun2SocksSnapshot: PID, uptime sec, TCP/UDP health
 // - ProbeSummary: SOCKS reachability and capabilities, with timings
 //
+// UpdateProbe compares each probe with the latency history of earlier ones
+// and with the capabilities they found (Regression); a slowdown past the
+// configured factor, or UDP or IPv6 that stopped working, becomes a probe
+// warning, a warning event, and a probe_regression timeline entry.
+// ScoreHealth condenses a snapshot, tun2socks exits, and DNS failures into
+// one 0-100 number with configurable weights (ScoreWeights).
+//
 // Update methods replace the entire snapshot atomically to avoid partial-state
 // ambiguity. The API layer consumes snapshot copies to serve JSON.
+//
+// Change Notification & Restore
+//
+// Every mutation signals Changed() (coalescing, single consumer), which the
+// persist package uses to save state. Subsystems that react to specific
+// transitions use Subscribe(ctx, types...) instead: each subscriber gets its
+// own buffered channel with drop-oldest overflow and a Dropped counter, so
+// slow consumers never block mutations. Restore() replaces all state from a
+// persisted snapshot, bypassing transition checks; use it only at startup.
+//
+// Operations
+//
+// Operations() keeps the last DefaultOperationCapacity starts and stops with
+// per-step progress (StartSteps, StopSteps) and their results, so callers of
+// the async API can poll one by ID. Operations are not part of the snapshot
+// and are not persisted.
+//
+// Connections
+//
+// UpdateConnections() holds the flows the tun2socks engine last reported
+// (ConnectionsSnapshot: 5-tuple, state, bytes each way, start time).
+// Reports replace the set every second or so; like operations they are
+// outside the snapshot, and they neither bump Rev nor signal Changed.
 package core
 
@@ -0,0 +1,268 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// EventType classifies entries in the event log.
+type EventType string
+
+const (
+	EventStateChange   EventType = "state_change"  // AgentState transition
+	EventProbeResult   EventType = "probe_result"  // every probe stored via UpdateProbe
+	EventOrchestration EventType = "orchestration" // orchestration step progress
+	EventWarning       EventType = "warning"       // AppendWarning
+)
+
+// Severity ranks events for filtering; higher is more severe.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+var severityNames = [...]string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return "unknown"
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity parses "info", "warning" (or "warn"), or "error".
+func ParseSeverity(name string) (Severity, error) {
+	switch name {
+	case "info":
+		return SeverityInfo, nil
+	case "warning", "warn":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	}
+	return 0, errors.New("severity must be info, warning, or error")
+}
+
+// Severity derives e's severity from its type and data: warnings (unless
+// their data names another severity), failed probes, and transitions to
+// degraded are warnings; transitions to error are errors; everything else
+// is info.
+func (e Event) Severity() Severity {
+	switch e.Type {
+	case EventWarning:
+		if sev, err := ParseSeverity(e.Data["severity"]); err == nil {
+			return sev
+		}
+		return SeverityWarning
+	case EventStateChange:
+		switch AgentState(e.Data["to"]) {
+		case StateError:
+			return SeverityError
+		case StateDegraded:
+			return SeverityWarning
+		}
+	case EventProbeResult:
+		if e.Data["reachable"] == "false" || e.Data["connect_ok"] == "false" {
+			return SeverityWarning
+		}
+	}
+	return SeverityInfo
+}
+
+// DefaultEventCapacity bounds the in-memory event log.
+const DefaultEventCapacity = 4096
+
+// Event is one append-only log entry. IDs increase monotonically across the
+// lifetime of the log (including entries reloaded from a sink), so clients
+// can resume with "after_id".
+type Event struct {
+	ID      uint64
+	At      time.Time
+	Type    EventType
+	Message string
+	Data    map[string]string // Small structured attributes (e.g., from/to)
+}
+
+// EventSink receives every appended event, e.g. to persist it. Append is
+// called in ID order from a single writer goroutine, never while the log's
+// lock is held, so implementations may block on disk or their own locks.
+// They must not call SetSink, which waits for the writer to drain.
+type EventSink interface {
+	Append(Event) error
+}
+
+// sinkQueue bounds the events waiting for the sink. When it is full, new
+// events stay in memory but skip the sink, and onErr reports the loss.
+const sinkQueue = 1024
+
+// sinkWriter feeds one sink from a queue. Append sends on queue while
+// holding EventLog.mu; SetSink closes queue only after swapping the writer
+// out under that lock, so no send can race with the close.
+type sinkWriter struct {
+	sink    EventSink
+	onErr   func(error)
+	queue   chan Event
+	dropped atomic.Uint64
+	done    chan struct{}
+}
+
+func newSinkWriter(sink EventSink, onErr func(error)) *sinkWriter {
+	w := &sinkWriter{sink: sink, onErr: onErr, queue: make(chan Event, sinkQueue), done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *sinkWriter) run() {
+	defer close(w.done)
+	for e := range w.queue {
+		if err := w.sink.Append(e); err != nil {
+			w.report(err)
+		}
+		w.reportDropped()
+	}
+	w.reportDropped()
+}
+
+func (w *sinkWriter) reportDropped() {
+	if n := w.dropped.Swap(0); n > 0 {
+		w.report(fmt.Errorf("event sink fell behind; %d events not written", n))
+	}
+}
+
+func (w *sinkWriter) report(err error) {
+	if w.onErr != nil {
+		w.onErr(err)
+	}
+}
+
+// EventLog is a bounded, append-only, concurrency-safe event list.
+type EventLog struct {
+	mu     sync.RWMutex
+	cap    int
+	nextID uint64
+	events []Event
+	sink   *sinkWriter              // nil: no sink; see SetSink
+	subs   map[*subscriber]struct{} // see Subscribe
+}
+
+// NewEventLog constructs an empty log holding up to capacity events
+// (DefaultEventCapacity if <= 0).
+func NewEventLog(capacity int) *EventLog {
+	if capacity <= 0 {
+		capacity = DefaultEventCapacity
+	}
+	return &EventLog{cap: capacity, nextID: 1}
+}
+
+// SetSink installs a sink for new events, replacing any previous one, and
+// returns once every event queued for the previous sink has been written.
+// SetSink(nil, nil) therefore flushes and detaches the sink. onErr, if
+// non-nil, is called from the writer goroutine when the sink fails or falls
+// behind; the event is still kept in memory.
+func (l *EventLog) SetSink(sink EventSink, onErr func(error)) {
+	var w *sinkWriter
+	if sink != nil {
+		w = newSinkWriter(sink, onErr)
+	}
+	l.mu.Lock()
+	old := l.sink
+	l.sink = w
+	l.mu.Unlock()
+	if old != nil {
+		close(old.queue)
+		<-old.done
+	}
+}
+
+// Seed loads previously persisted events (oldest first) and continues IDs
+// after the highest one. Call before any Append.
+func (l *EventLog) Seed(events []Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(events) > l.cap {
+		events = events[len(events)-l.cap:]
+	}
+	l.events = append(l.events[:0], events...)
+	for _, e := range events {
+		if e.ID >= l.nextID {
+			l.nextID = e.ID + 1
+		}
+	}
+}
+
+// Append records an event stamped now and returns it with its assigned ID.
+func (l *EventLog) Append(typ EventType, msg string, data map[string]string) Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Events reach storage, exporters, and the API; strip credentials once here.
+	e := Event{ID: l.nextID, At: time.Now(), Type: typ, Message: redact.String(msg), Data: redact.Map(data)}
+	l.nextID++
+	if len(l.events) >= l.cap {
+		n := copy(l.events, l.events[len(l.events)-l.cap+1:])
+		l.events = l.events[:n]
+	}
+	l.events = append(l.events, e)
+	if l.sink != nil {
+		select {
+		case l.sink.queue <- e:
+		default:
+			l.sink.dropped.Add(1)
+		}
+	}
+	l.publishLocked(e)
+	return e
+}
+
+// After returns up to limit events with ID > afterID, oldest first.
+// truncated is true when events after afterID were already evicted, i.e.
+// the caller has a gap. lastID is the newest ID in the log (0 if empty).
+func (l *EventLog) After(afterID uint64, limit int) (events []Event, truncated bool, lastID uint64) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if len(l.events) == 0 {
+		return nil, false, 0
+	}
+	lastID = l.events[len(l.events)-1].ID
+	oldest := l.events[0].ID
+	truncated = afterID+1 < oldest && afterID < lastID
+
+	// IDs are dense within the retained window, so index arithmetic works.
+	start := 0
+	if afterID >= oldest {
+		start = int(afterID - oldest + 1)
+	}
+	if start >= len(l.events) {
+		return nil, truncated, lastID
+	}
+	end := len(l.events)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	out := make([]Event, end-start)
+	for i, e := range l.events[start:end] {
+		e.Data = cloneData(e.Data)
+		out[i] = e
+	}
+	return out, truncated, lastID
+}
+
+func cloneData(in map[string]string) map[string]string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
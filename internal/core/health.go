@@ -0,0 +1,105 @@
+package core
+
+import "time"
+
+// HealthStatus is the damped proxy health derived from probe results. It is
+// what a status icon should show: single lost probes do not change it.
+type HealthStatus string
+
+const (
+	HealthUnknown  HealthStatus = "unknown"  // no probe yet
+	HealthOK       HealthStatus = "ok"       // CONNECT through the proxy works
+	HealthDegraded HealthStatus = "degraded" // consecutive probes failed
+)
+
+// Hysteresis defaults.
+const (
+	DefaultFailThreshold    = 3
+	DefaultRecoverThreshold = 2
+	DefaultMinHold          = 30 * time.Second
+)
+
+// Hysteresis damps health transitions. Leaving unknown is immediate; after
+// that, FailThreshold consecutive failed probes degrade health and
+// RecoverThreshold consecutive successes restore it, and no transition
+// happens within MinHold of the previous one. A transition held back by
+// MinHold happens on the next probe after the hold, if the streak lasts.
+type Hysteresis struct {
+	FailThreshold    int
+	RecoverThreshold int
+	MinHold          time.Duration
+}
+
+// withDefaults fills zero fields. A negative MinHold means no hold.
+func (h Hysteresis) withDefaults() Hysteresis {
+	if h.FailThreshold <= 0 {
+		h.FailThreshold = DefaultFailThreshold
+	}
+	if h.RecoverThreshold <= 0 {
+		h.RecoverThreshold = DefaultRecoverThreshold
+	}
+	if h.MinHold == 0 {
+		h.MinHold = DefaultMinHold
+	}
+	if h.MinHold < 0 {
+		h.MinHold = 0
+	}
+	return h
+}
+
+// HealthSnapshot is the damped health and the streak behind it. Pending is
+// true while the latest probe disagrees with Status.
+type HealthSnapshot struct {
+	Status               HealthStatus
+	Since                time.Time // last transition
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	Pending              bool
+}
+
+// SetHysteresis replaces the health damping parameters; zero fields use the
+// defaults. The current streak is kept.
+func (s *State) SetHysteresis(h Hysteresis) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hysteresis = h.withDefaults()
+}
+
+// Hysteresis returns the health damping parameters in effect.
+func (s *State) Hysteresis() Hysteresis {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hysteresis
+}
+
+// applyHealthLocked folds one probe result into the damped health and
+// reports whether the status changed. Callers hold s.mu.
+func (s *State) applyHealthLocked(ok bool, at time.Time) bool {
+	h := &s.health
+	if ok {
+		h.ConsecutiveSuccesses++
+		h.ConsecutiveFailures = 0
+	} else {
+		h.ConsecutiveFailures++
+		h.ConsecutiveSuccesses = 0
+	}
+	next := h.Status
+	switch {
+	case h.Status == HealthUnknown || h.Status == "":
+		next = HealthDegraded
+		if ok {
+			next = HealthOK
+		}
+	case h.Status == HealthOK && h.ConsecutiveFailures >= s.hysteresis.FailThreshold:
+		next = HealthDegraded
+	case h.Status == HealthDegraded && h.ConsecutiveSuccesses >= s.hysteresis.RecoverThreshold:
+		next = HealthOK
+	}
+	held := h.Status != HealthUnknown && h.Status != "" && at.Sub(h.Since) < s.hysteresis.MinHold
+	changed := next != h.Status && !held
+	if changed {
+		h.Status, h.Since = next, at
+	}
+	h.Pending = (h.Status == HealthOK) != ok
+	return changed
+}
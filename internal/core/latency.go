@@ -0,0 +1,68 @@
+package core
+
+import (
+	"slices"
+	"time"
+)
+
+// LatencyHistory is how many successful probes the latency percentiles
+// cover.
+const LatencyHistory = 100
+
+// LatencyPercentiles summarizes one probe step's latency over the last
+// Samples successful probes (nearest-rank percentiles).
+type LatencyPercentiles struct {
+	P50, P90, P99 time.Duration
+	Samples       int
+}
+
+// latencyHistory keeps the latest LatencyHistory latencies of each probe
+// step, oldest first. The zero value is empty.
+type latencyHistory map[string][]time.Duration
+
+// add records one probe's step latencies.
+func (h *latencyHistory) add(lat map[string]time.Duration) {
+	if *h == nil {
+		*h = make(latencyHistory)
+	}
+	for step, d := range lat {
+		s := append((*h)[step], d)
+		if len(s) > LatencyHistory {
+			s = s[len(s)-LatencyHistory:]
+		}
+		(*h)[step] = s
+	}
+}
+
+// percentiles returns the percentiles of every recorded step; nil when
+// nothing was recorded.
+func (h latencyHistory) percentiles() map[string]LatencyPercentiles {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(map[string]LatencyPercentiles, len(h))
+	for step, s := range h {
+		sorted := slices.Sorted(slices.Values(s))
+		out[step] = LatencyPercentiles{
+			P50:     rank(sorted, 50),
+			P90:     rank(sorted, 90),
+			P99:     rank(sorted, 99),
+			Samples: len(sorted),
+		}
+	}
+	return out
+}
+
+// LatencyPercentiles returns the probe latency percentiles per step, as
+// in the last probe summary.
+func (s *State) LatencyPercentiles() map[string]LatencyPercentiles {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latencies.percentiles()
+}
+
+// rank is the nearest-rank pth percentile of sorted, which is not empty.
+func rank(sorted []time.Duration, p int) time.Duration {
+	i := (p*len(sorted)+99)/100 - 1
+	return sorted[max(i, 0)]
+}
@@ -0,0 +1,202 @@
+package core
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// OperationStatus is the lifecycle of an operation or one of its steps.
+type OperationStatus string
+
+const (
+	OpPending   OperationStatus = "pending"   // step not reached yet
+	OpRunning   OperationStatus = "running"   // in progress
+	OpSucceeded OperationStatus = "succeeded" // finished without error
+	OpFailed    OperationStatus = "failed"    // finished with an error
+	OpSkipped   OperationStatus = "skipped"   // step not run because an earlier one failed
+)
+
+// Orchestration steps, in the order start, stop, pause, and resume run
+// them.
+const (
+	StepTUNCreated       = "tun_created"
+	StepRoutesApplied    = "routes_applied"
+	StepTun2SocksStarted = "tun2socks_started"
+	StepVerified         = "verified"
+
+	StepTun2SocksStopped = "tun2socks_stopped"
+	StepRoutesRestored   = "routes_restored"
+	StepTUNRemoved       = "tun_removed"
+
+	StepRoutesReverted  = "routes_reverted"
+	StepRoutesReapplied = "routes_reapplied"
+)
+
+// StartSteps, StopSteps, PauseSteps, and ResumeSteps list the steps of each
+// operation kind.
+var (
+	StartSteps  = []string{StepTUNCreated, StepRoutesApplied, StepTun2SocksStarted, StepVerified}
+	StopSteps   = []string{StepTun2SocksStopped, StepRoutesRestored, StepTUNRemoved}
+	PauseSteps  = []string{StepRoutesReverted}
+	ResumeSteps = []string{StepRoutesReapplied}
+)
+
+// DefaultOperationCapacity bounds the operation history.
+const DefaultOperationCapacity = 32
+
+// Operation is a start, stop, pause, or resume with per-step progress.
+type Operation struct {
+	ID         string
+	Kind       string // "start", "stop", "pause", or "resume"
+	Client     string // API client that requested it
+	Async      bool
+	Status     OperationStatus
+	StartedAt  time.Time
+	FinishedAt time.Time // zero while running
+	Steps      []OperationStep
+	Error      string
+	State      AgentState // agent state when it finished
+}
+
+// OperationStep is one step of an Operation.
+type OperationStep struct {
+	Name       string
+	Status     OperationStatus
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Error      string
+}
+
+// Operations is a bounded, concurrency-safe history of operations. The
+// oldest finished operations are dropped first; running ones are kept.
+type Operations struct {
+	mu  sync.RWMutex
+	cap int
+	ops []*Operation // oldest first
+}
+
+// NewOperations constructs an empty history holding up to capacity
+// operations (DefaultOperationCapacity if <= 0).
+func NewOperations(capacity int) *Operations {
+	if capacity <= 0 {
+		capacity = DefaultOperationCapacity
+	}
+	return &Operations{cap: capacity}
+}
+
+// Begin records a running operation with the given steps, all pending.
+func (o *Operations) Begin(id, kind, client string, async bool, steps []string, now time.Time) {
+	op := &Operation{
+		ID:        id,
+		Kind:      kind,
+		Client:    client,
+		Async:     async,
+		Status:    OpRunning,
+		StartedAt: now,
+		Steps:     make([]OperationStep, len(steps)),
+	}
+	for i, name := range steps {
+		op.Steps[i] = OperationStep{Name: name, Status: OpPending}
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ops = append(o.ops, op)
+	for len(o.ops) > o.cap {
+		i := slices.IndexFunc(o.ops, func(op *Operation) bool { return op.Status != OpRunning })
+		if i < 0 {
+			break
+		}
+		o.ops = slices.Delete(o.ops, i, i+1)
+	}
+}
+
+// StepStarted marks step of operation id running.
+func (o *Operations) StepStarted(id, step string, now time.Time) {
+	o.update(id, func(op *Operation) {
+		if s := op.step(step); s != nil {
+			s.Status, s.StartedAt = OpRunning, now
+		}
+	})
+}
+
+// StepFinished marks step of operation id succeeded, or failed with err.
+func (o *Operations) StepFinished(id, step string, now time.Time, err error) {
+	o.update(id, func(op *Operation) {
+		s := op.step(step)
+		if s == nil {
+			return
+		}
+		s.Status, s.FinishedAt = OpSucceeded, now
+		if err != nil {
+			s.Status, s.Error = OpFailed, redact.String(err.Error())
+		}
+	})
+}
+
+// Finish records the result of operation id. Steps still pending are marked
+// skipped.
+func (o *Operations) Finish(id string, now time.Time, state AgentState, err error) {
+	o.update(id, func(op *Operation) {
+		op.Status, op.FinishedAt, op.State = OpSucceeded, now, state
+		if err != nil {
+			op.Status, op.Error = OpFailed, redact.String(err.Error())
+		}
+		for i := range op.Steps {
+			if op.Steps[i].Status == OpPending {
+				op.Steps[i].Status = OpSkipped
+			}
+		}
+	})
+}
+
+// Get returns a copy of operation id.
+func (o *Operations) Get(id string) (Operation, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for _, op := range o.ops {
+		if op.ID == id {
+			return op.clone(), true
+		}
+	}
+	return Operation{}, false
+}
+
+// List returns copies of every operation, newest first.
+func (o *Operations) List() []Operation {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	out := make([]Operation, 0, len(o.ops))
+	for i := len(o.ops) - 1; i >= 0; i-- {
+		out = append(out, o.ops[i].clone())
+	}
+	return out
+}
+
+func (o *Operations) update(id string, fn func(*Operation)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, op := range o.ops {
+		if op.ID == id {
+			fn(op)
+			return
+		}
+	}
+}
+
+func (op *Operation) step(name string) *OperationStep {
+	for i := range op.Steps {
+		if op.Steps[i].Name == name {
+			return &op.Steps[i]
+		}
+	}
+	return nil
+}
+
+func (op *Operation) clone() Operation {
+	c := *op
+	c.Steps = slices.Clone(op.Steps)
+	return c
+}
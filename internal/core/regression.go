@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Regression defaults.
+const (
+	DefaultRegressionFactor      = 2.0
+	DefaultRegressionMinSamples  = 5
+	DefaultRegressionMinIncrease = 5 * time.Millisecond
+)
+
+// Regression configures how a probe is compared with its baseline. A step
+// regresses when its latency exceeds Factor times the median of its last
+// LatencyHistory successful probes, and by at least MinIncrease (so
+// sub-millisecond jitter on a local proxy is not news); the baseline needs
+// MinSamples probes. A capability regresses when UDP ASSOCIATE or an IPv6
+// CONNECT fails where the last probe that tried it succeeded.
+type Regression struct {
+	Factor      float64 // negative disables regression checks
+	MinSamples  int
+	MinIncrease time.Duration
+}
+
+// withDefaults fills zero fields.
+func (r Regression) withDefaults() Regression {
+	if r.Factor == 0 {
+		r.Factor = DefaultRegressionFactor
+	}
+	if r.MinSamples <= 0 {
+		r.MinSamples = DefaultRegressionMinSamples
+	}
+	if r.MinIncrease == 0 {
+		r.MinIncrease = DefaultRegressionMinIncrease
+	}
+	return r
+}
+
+// SetRegression replaces the regression parameters; zero fields use the
+// defaults.
+func (s *State) SetRegression(r Regression) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.regression = r.withDefaults()
+}
+
+// Regression returns the regression parameters in effect.
+func (s *State) Regression() Regression {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.regression
+}
+
+// regressionState remembers what the next probe is compared with beyond
+// the latency history: which steps are already reported as regressed and
+// which capabilities last worked.
+type regressionState struct {
+	slow      map[string]bool // step -> reported, until it recovers
+	udp, ipv6 bool            // last probe that tried it succeeded
+}
+
+// regressionsLocked compares p with the baseline before p joins it and
+// returns one warning per new regression. A regression is reported once,
+// then again only after the step or capability recovered. Callers hold
+// s.mu.
+func (s *State) regressionsLocked(p ProbeSummary) []Warning {
+	cfg := s.regression
+	if cfg.Factor < 0 {
+		return nil
+	}
+	rs := &s.regressed
+	var out []Warning
+	if p.ConnectOK {
+		steps := make([]string, 0, len(p.Latencies))
+		for step := range p.Latencies {
+			steps = append(steps, step)
+		}
+		sort.Strings(steps)
+		for _, step := range steps {
+			d := p.Latencies[step]
+			hist := s.latencies[step]
+			if len(hist) < cfg.MinSamples {
+				continue
+			}
+			median := rank(slices.Sorted(slices.Values(hist)), 50)
+			slow := float64(d) > cfg.Factor*float64(median) && d-median >= cfg.MinIncrease
+			switch {
+			case slow && !rs.slow[step]:
+				if rs.slow == nil {
+					rs.slow = make(map[string]bool)
+				}
+				rs.slow[step] = true
+				out = append(out, NewWarning("probe", "probe_regression", fmt.Sprintf("probe regression: %s took %s, %.1fx the median %s of the last %d successful probes",
+					step, d.Round(time.Microsecond), float64(d)/float64(max(median, 1)), median.Round(time.Microsecond), len(hist))))
+			case !slow && rs.slow[step]:
+				delete(rs.slow, step)
+			}
+		}
+	}
+	if _, tried := p.Latencies["udp_associate"]; tried && p.ConnectOK {
+		if rs.udp && !p.UDPOK {
+			out = append(out, NewWarning("probe", "probe_regression", "probe regression: UDP ASSOCIATE no longer works through the proxy"))
+		}
+		rs.udp = p.UDPOK
+	}
+	if ipv6Target(p.Target) && p.SocksOK {
+		if rs.ipv6 && !p.ConnectOK {
+			out = append(out, NewWarning("probe", "probe_regression", "probe regression: IPv6 CONNECT to "+p.Target+" no longer works through the proxy"))
+		}
+		rs.ipv6 = p.ConnectOK
+	}
+	return out
+}
+
+// ipv6Target reports whether target ("host:port") names an IPv6 literal.
+func ipv6Target(target string) bool {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return false
+	}
+	a, err := netip.ParseAddr(host)
+	return err == nil && a.Is6() && !a.Is4In6()
+}
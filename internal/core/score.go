@@ -0,0 +1,160 @@
+package core
+
+import (
+	"math"
+	"time"
+)
+
+// Health score parts, the keys of HealthScore.Parts.
+const (
+	ScoreReachability = "reachability"
+	ScoreLatency      = "latency"
+	ScoreRestarts     = "restarts"
+	ScoreDNS          = "dns"
+)
+
+// Score defaults. CONNECT-path latency (the p50s of tcp_connect,
+// socks_handshake, and connect added up) scores full marks up to
+// ScoreLatencyGood and nothing from ScoreLatencyBad; an unexpected
+// tun2socks exit costs the restarts part in full and is forgiven linearly
+// over ScoreRestartWindow.
+const (
+	DefaultScoreWeightReachability = 50
+	DefaultScoreWeightLatency      = 20
+	DefaultScoreWeightRestarts     = 15
+	DefaultScoreWeightDNS          = 15
+
+	ScoreLatencyGood   = 100 * time.Millisecond
+	ScoreLatencyBad    = time.Second
+	ScoreRestartWindow = 10 * time.Minute
+)
+
+// ScoreWeights sets how much each part counts toward the health score.
+// Only their ratios matter. Zero fields use the defaults (50, 20, 15, 15);
+// a negative weight leaves the part out.
+type ScoreWeights struct {
+	Reachability float64
+	Latency      float64
+	Restarts     float64
+	DNS          float64
+}
+
+// withDefaults fills zero fields.
+func (w ScoreWeights) withDefaults() ScoreWeights {
+	if w.Reachability == 0 {
+		w.Reachability = DefaultScoreWeightReachability
+	}
+	if w.Latency == 0 {
+		w.Latency = DefaultScoreWeightLatency
+	}
+	if w.Restarts == 0 {
+		w.Restarts = DefaultScoreWeightRestarts
+	}
+	if w.DNS == 0 {
+		w.DNS = DefaultScoreWeightDNS
+	}
+	return w
+}
+
+// SetScoreWeights replaces the health score weights; zero fields use the
+// defaults.
+func (s *State) SetScoreWeights(w ScoreWeights) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scoreWeights = w.withDefaults()
+}
+
+// ScoreWeights returns the health score weights in effect.
+func (s *State) ScoreWeights() ScoreWeights {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scoreWeights
+}
+
+// ScoreInputs is what the health score needs beyond the snapshot.
+type ScoreInputs struct {
+	LastRestart time.Time // last unexpected tun2socks exit; zero if none
+	DNSQueries  uint64    // answered by the DNS forwarder; 0 leaves DNS out
+	DNSFailures uint64
+	Now         time.Time
+}
+
+// HealthScore condenses proxy health into one number for a status icon.
+// Parts holds each part that had data, from 0 (bad) to 1 (good); Score is
+// their weighted mean scaled to 0-100. Known is false when no part had
+// data, e.g. before the first probe with no tunnel up.
+type HealthScore struct {
+	Score int
+	Known bool
+	Parts map[string]float64
+}
+
+// ScoreHealth computes the health score of snap with weights w:
+//
+//   - reachability: 1 when the last probe's CONNECT worked, 0.5 when only
+//     the SOCKS handshake did, 0.25 when only TCP did, else 0
+//   - latency: from the rolling p50s of the CONNECT path (see
+//     ScoreLatencyGood)
+//   - restarts: tun2socks exits while a tunnel runs (see
+//     ScoreRestartWindow)
+//   - dns: the DNS forwarder's success rate
+func ScoreHealth(snap Snapshot, in ScoreInputs, w ScoreWeights) HealthScore {
+	w = w.withDefaults()
+	parts := make(map[string]float64, 4)
+	p := snap.LastProbe
+	if !p.LastChecked.IsZero() {
+		switch {
+		case p.ConnectOK:
+			parts[ScoreReachability] = 1
+		case p.SocksOK:
+			parts[ScoreReachability] = 0.5
+		case p.Reachable:
+			parts[ScoreReachability] = 0.25
+		default:
+			parts[ScoreReachability] = 0
+		}
+	}
+	if len(p.Percentiles) > 0 {
+		var total time.Duration
+		for _, step := range []string{"tcp_connect", "socks_handshake", "connect"} {
+			total += p.Percentiles[step].P50
+		}
+		parts[ScoreLatency] = 1 - clamp01(float64(total-ScoreLatencyGood)/float64(ScoreLatencyBad-ScoreLatencyGood))
+	}
+	running := snap.AgentState == StateActive || snap.AgentState == StateDegraded || snap.AgentState == StatePaused
+	since := in.Now.Sub(in.LastRestart)
+	switch {
+	case !in.LastRestart.IsZero() && since < ScoreRestartWindow:
+		parts[ScoreRestarts] = clamp01(float64(since) / float64(ScoreRestartWindow))
+	case running:
+		parts[ScoreRestarts] = 1
+	}
+	if in.DNSQueries > 0 {
+		parts[ScoreDNS] = 1 - clamp01(float64(in.DNSFailures)/float64(in.DNSQueries))
+	}
+
+	weights := map[string]float64{
+		ScoreReachability: w.Reachability,
+		ScoreLatency:      w.Latency,
+		ScoreRestarts:     w.Restarts,
+		ScoreDNS:          w.DNS,
+	}
+	var sum, total float64
+	for name, v := range parts {
+		if weights[name] < 0 {
+			delete(parts, name)
+			continue
+		}
+		sum += weights[name] * v
+		total += weights[name]
+	}
+	if total == 0 {
+		return HealthScore{Parts: parts}
+	}
+	return HealthScore{Score: int(math.Round(100 * sum / total)), Known: true, Parts: parts}
+}
+
+// clamp01 limits v to [0, 1].
+func clamp01(v float64) float64 {
+	return min(max(v, 0), 1)
+}
@@ -2,8 +2,14 @@ package core
 
 import (
 	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/redact"
 )
 
 // AgentState represents the lifecycle state of the daemon.
@@ -12,8 +18,9 @@ import (
 //
 // inactive -> starting | active
 // starting -> active | error | inactive
-// active   -> degraded | stopping | error
-// degraded -> active | stopping | error
+// active   -> degraded | paused | stopping | error
+// degraded -> active | paused | stopping | error
+// paused   -> active | stopping | error
 // stopping -> inactive | error
 // error    -> inactive | starting
 //
@@ -25,6 +32,9 @@ const (
 	StateStarting AgentState = "starting"
 	StateActive   AgentState = "active"
 	StateDegraded AgentState = "degraded"
+	// StatePaused: routes are back on the original gateway while the TUN
+	// and tun2socks stay up, so resuming only reapplies routes.
+	StatePaused   AgentState = "paused"
 	StateStopping AgentState = "stopping"
 	StateError    AgentState = "error"
 )
@@ -39,39 +49,139 @@ type ProxyFeatures struct {
 	IPv6 bool
 	// UDP: true if proxy supports UDP ASSOCIATE
 	UDP bool
+	// PathMTU: path MTU toward the proxy as the kernel knew it after
+	// connecting; 0 when unknown (only Linux reports it).
+	PathMTU int
+	// Family: address family the proxy was reached over, "ipv4" or
+	// "ipv6"; a hostname with both is dialed in parallel (RFC 8305).
+	Family string
 }
 
 // ProbeSummary is a condensed view of the last SOCKS proxy probe.
 // Times and latencies are captured as observed, without smoothing.
 type ProbeSummary struct {
-	Reachable   bool             // TCP reachability to proxy endpoint
-	SocksOK     bool             // Successful SOCKS5 greeting/handshake
-	ConnectOK   bool             // Successful CONNECT to a known egress target
-	UDPOK       bool             // Successful UDP ASSOCIATE probe
-	LatenciesMs map[string]int64 // e.g., "tcp_connect", "socks_handshake", "connect"
-	Features    ProxyFeatures    // Discovered capabilities
-	LastChecked time.Time        // Wall clock time of probe
-	Warnings    []string         // Non-fatal anomalies observed during probe
+	Reachable   bool                     // TCP reachability to proxy endpoint
+	SocksOK     bool                     // Successful SOCKS5 greeting/handshake
+	ConnectOK   bool                     // Successful CONNECT to a known egress target
+	UDPOK       bool                     // Successful UDP ASSOCIATE probe
+	Latencies   map[string]time.Duration // Per step, e.g., "tcp_connect", "socks_handshake", "connect"
+	Features    ProxyFeatures            // Discovered capabilities
+	LastChecked time.Time                // Wall clock time of probe
+	Warnings    []Warning                // Non-fatal anomalies observed during probe
+	Hops        []ProbeHop               // Per proxy of a chain, entry first; nil for a single proxy
+	// Percentiles smooths Latencies per step over recent successful
+	// probes; State fills it, so callers leave it nil.
+	Percentiles map[string]LatencyPercentiles
+	Target      string // connect target ("host:port"), for regression checks
+
+	// Trace is the bytes exchanged with the proxy, when the probe was asked
+	// for it; State never stores it.
+	Trace []ProbeTraceEntry
+	// Targets reports each connect target of a multi-target probe, the
+	// probe's own first; nil for a single target.
+	Targets []ProbeTarget
+	// TLS is the handshake with the connect target through the proxy;
+	// nil unless the probe was asked for one.
+	TLS *ProbeTLS
+	// HTTP is the GET of a canary URL through the proxy; nil unless the
+	// probe was asked for one.
+	HTTP *ProbeHTTP
+}
+
+// ProbeHTTP is an HTTP GET of a canary URL through the proxy.
+type ProbeHTTP struct {
+	URL         string
+	OK          bool   // status and body as expected
+	Status      int    // 0 when no response was read
+	BodyBytes   int    // read, at most 64 KiB
+	BodyMatched *bool  // expected substring found; nil when none was expected
+	Error       string // why the check failed
+}
+
+// ProbeTLS is a TLS handshake with the connect target through the proxy.
+// OK with Verified false means TLS passes but the certificate does not
+// check out for ServerName, e.g. behind an intercepting middlebox.
+type ProbeTLS struct {
+	ServerName  string // name checked; also the SNI unless an IP
+	OK          bool   // handshake completed
+	Error       string // why the handshake failed
+	Version     string // e.g. "TLS 1.3"
+	CipherSuite string
+	ALPN        string    // negotiated protocol; "" when none
+	Verified    bool      // chain valid for ServerName against system roots
+	VerifyError string    // why it is not
+	Subject     string    // leaf certificate
+	Issuer      string    // leaf certificate
+	NotAfter    time.Time // leaf expiry
+	ChainLen    int       // certificates the server sent
+}
+
+// ProbeTarget is one connect target as a multi-target probe saw it.
+type ProbeTarget struct {
+	Target  string        // "host:port"
+	OK      bool          // CONNECT through the proxy succeeded
+	Latency time.Duration // of the CONNECT step
+	Error   string        // why it failed; empty when OK
+}
+
+// ProbeTraceEntry is one message of a probe trace: consecutive bytes sent
+// to or received from the proxy.
+type ProbeTraceEntry struct {
+	Hop    int           // proxy of a chain, from 1; 0 for a single proxy
+	Dir    string        // "send" or "recv"
+	Offset time.Duration // since the TCP connect completed
+	Len    int           // bytes
+	Hex    string        // space-separated hex pairs; credentials as "**"
+	Text   string        // printable ASCII, other bytes as '.'
+}
+
+// ProbeHop is one proxy of a chain as the probe saw it. A hop is OK once
+// it accepted the handshake and the CONNECT to the next hop (or, for the
+// last, to the connect target); hops after a failure are not reached.
+type ProbeHop struct {
+	Server  string        // "host:port"
+	Type    string        // "socks5" or "http"
+	OK      bool          // handshake and CONNECT through this hop succeeded
+	Latency time.Duration // handshake plus CONNECT through this hop
+	Error   string        // why the hop failed; empty when OK or not reached
 }
 
 // TUNSnapshot describes the TUN interface state at a point in time.
 type TUNSnapshot struct {
-	Name    string // OS interface name (e.g., "utun7" on macOS)
-	Up      bool   // Administrative up flag
-	MTU     int    // MTU currently set
-	LocalIP string // Local (interface) IP assigned to TUN
-	PeerIP  string // Peer IP (if point-to-point)
+	Name     string // OS interface name (e.g., "utun7" on macOS)
+	Up       bool   // Administrative up flag
+	MTU      int    // MTU currently set
+	LocalIP  string // Local (interface) IP assigned to TUN
+	PeerIP   string // Peer IP (if point-to-point)
+	LocalIP6 string // IPv6 prefix assigned to TUN ("" when IPv6 is not routed)
 }
 
 // RouteSnapshot summarizes routing decisions captured by the daemon.
-// LanCIDRs and BypassHosts are additive lists used to steer routing.
-// OriginalGateway is the pre-modification default gateway (used for restore).
+// LanCIDRs and BypassHosts are additive lists used to steer routing;
+// IncludeCIDRs and ExcludeCIDRs describe split tunneling.
+// OriginalGateway is the pre-modification default gateway (used for restore);
+// OriginalGateway6 is its IPv6 counterpart, set only when IPv6 is routed.
 type RouteSnapshot struct {
-	DefaultVia      string   // Current default route gateway (post-swap)
-	LanCIDRs        []string // Detected local/LAN networks to bypass
-	BypassHosts     []string // Hosts to bypass (e.g., proxy endpoint, router)
-	ProxyHostRoute  bool     // whether proxy endpoint has a pinned host route
-	OriginalGateway string   // Default gateway observed before swapping
+	DefaultVia       string   // Current default route gateway (post-swap)
+	LanCIDRs         []string // Detected local/LAN networks to bypass
+	IncludeCIDRs     []string // Split tunnel: only these destinations use the TUN (empty: all)
+	ExcludeCIDRs     []string // Split tunnel: destinations kept outside the TUN
+	BypassHosts      []string // Hosts to bypass (e.g., proxy endpoint, router)
+	ProxyHostRoute   bool     // whether proxy endpoint has a pinned host route
+	ProxyAddrs       []string // resolved proxy endpoint addresses the host routes pin
+	OriginalGateway  string   // Default gateway observed before swapping
+	OriginalGateway6 string   // IPv6 default gateway observed before swapping
+}
+
+// DNSSnapshot describes the local DNS forwarder and the system resolvers.
+// OriginalResolvers are the resolvers before the agent pointed the system
+// at the forwarder (used for restore); empty when nothing was rewritten.
+type DNSSnapshot struct {
+	Listen            string   // forwarder address ("198.18.0.1:53"); "" when not running
+	Upstream          string   // e.g. "tls://1.1.1.1:853"
+	Resolvers         []string // system resolvers as configured now
+	OriginalResolvers []string // system resolvers before the rewrite
+	Rewritten         bool     // Resolvers point at the forwarder
 }
 
 // Tun2SocksSnapshot summarizes the supervized tun2socks process.
@@ -80,80 +190,184 @@ type Tun2SocksSnapshot struct {
 	UptimeSec int64 // Monotonic-ish uptime of the process
 	TCPOk     bool  // Health check for TCP path
 	UDPOk     bool  // Health check for UDP path
+	// UDPUnsupported means UDP was asked for but the upstream cannot
+	// carry it (an HTTP CONNECT proxy); UDPOk is then false.
+	UDPUnsupported bool
+	// LastErrors holds the process's most recent error output, oldest
+	// first, while a health check fails; empty otherwise.
+	LastErrors []string
+	// Traffic counts what crossed the TUN device; see UpdateTraffic.
+	Traffic TrafficSnapshot
+}
+
+// TrafficSnapshot counts the TUN device's traffic since it was created,
+// from the host's point of view: Out went into the tunnel (upload), In
+// came back from it. Rates are bytes per second over a short sliding
+// window.
+type TrafficSnapshot struct {
+	BytesIn, BytesOut     uint64
+	PacketsIn, PacketsOut uint64
+	RateIn, RateOut       float64
+	SampledAt             time.Time // zero when not measured
 }
 
 // Snapshot is a threadsafe read model returned to the API layer.
 // All nested slices/maps are returned as defensive copies, so callers
 // may safely retain value without additional locking.
 type Snapshot struct {
+	Rev        uint64 // revision; see State.Rev
 	AgentState AgentState
 	StartedAt  time.Time
-	Warnings   []string
+	Warnings   []Warning
 	TUN        TUNSnapshot
 	Routes     RouteSnapshot
 	Tun2Socks  Tun2SocksSnapshot
+	DNS        DNSSnapshot
 	LastProbe  ProbeSummary
+	Health     HealthSnapshot // damped proxy health (see Hysteresis)
+	Subsystems []Subsystem    // optional subsystems, sorted by name
 }
 
 // State holds mutable daemon state with synchronization.
 // Use the provided methods to mutate; callers should never take the lock directly.
 type State struct {
-	mu        sync.RWMutex
-	agent     AgentState
-	startedAt time.Time
-	warnings  []string
-	tun       TUNSnapshot
-	routes    RouteSnapshot
-	tun2socks Tun2SocksSnapshot
-	lastProbe ProbeSummary
+	mu            sync.RWMutex
+	agent         AgentState
+	startedAt     time.Time
+	warnings      []Warning
+	warningLimits WarningLimits
+	tun           TUNSnapshot
+	routes        RouteSnapshot
+	tun2socks     Tun2SocksSnapshot
+	dns           DNSSnapshot
+	lastProbe     ProbeSummary
+	latencies     latencyHistory // of successful probes, for Percentiles
+	regression    Regression
+	regressed     regressionState
+	health        HealthSnapshot
+	hysteresis    Hysteresis
+	scoreWeights  ScoreWeights
+	logger        *slog.Logger
+	changed       chan struct{} // coalescing change signal; see Changed
+	rev           uint64        // bumped by markChanged
+	timeline      []TimelineEntry
+	events        *EventLog
+	operations    *Operations
+	// connections is reported by engines; see UpdateConnections.
+	connections ConnectionsSnapshot
+
+	subsystems map[string]Subsystem // see SetSubsystem
 }
 
 // NewState constructs a default-inactive state.
 func NewState() *State {
 	return &State{
-		agent:    StateInactive,
-		warnings: nil,
+		agent:         StateInactive,
+		warnings:      nil,
+		health:        HealthSnapshot{Status: HealthUnknown},
+		hysteresis:    Hysteresis{}.withDefaults(),
+		regression:    Regression{}.withDefaults(),
+		scoreWeights:  ScoreWeights{}.withDefaults(),
+		warningLimits: WarningLimits{Max: DefaultMaxWarnings},
+		logger:        slog.New(slog.DiscardHandler),
+		changed:       make(chan struct{}, 1),
+		rev:           uint64(time.Now().UnixMilli()),
+		events:        NewEventLog(DefaultEventCapacity),
+		operations:    NewOperations(DefaultOperationCapacity),
 	}
 }
 
+// Events returns the daemon's append-only event log. State records state
+// transitions, probe results, and warnings; orchestration code appends its
+// own steps via RecordEvent.
+func (s *State) Events() *EventLog {
+	return s.events
+}
+
+// Operations returns the start/stop operation history.
+func (s *State) Operations() *Operations {
+	return s.operations
+}
+
+// RecordEvent appends an event to the log. It does not mutate the snapshot.
+func (s *State) RecordEvent(typ EventType, msg string, data map[string]string) Event {
+	return s.events.Append(typ, msg, data)
+}
+
+// Changed returns a channel that receives a value after any mutation.
+// Signals coalesce: several mutations between reads yield one receive, so a
+// consumer should re-read GetSnapshot on each signal. Intended for a single
+// consumer (e.g., the persistence writer).
+func (s *State) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// Rev returns the current revision. It increases by one on every mutation
+// and is seeded from the construction time in milliseconds, so revisions do
+// not repeat across restarts (and stay below 2^53 for JSON clients).
+func (s *State) Rev() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rev
+}
+
+// markChanged bumps the revision and signals Changed without blocking.
+// Callers must hold s.mu.
+func (s *State) markChanged() {
+	s.rev++
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// SetLogger installs the logger used to record lifecycle transitions and
+// warnings. Callers typically pass a "core" component logger. Nil disables
+// logging.
+func (s *State) SetLogger(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(slog.DiscardHandler)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = l
+}
+
 // GetSnapshot returns a deep copy safe for concurrent reads.
 func (s *State) GetSnapshot() Snapshot {
+	s.expireWarnings()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	// Defensive copies for slices/maps
-	warnings := append([]string(nil), s.warnings...)
+	warnings := slices.Clone(s.warnings)
 	lanCIDRs := append([]string(nil), s.routes.LanCIDRs...)
 	bypass := append([]string(nil), s.routes.BypassHosts...)
-	latencies := make(map[string]int64, len(s.lastProbe.LatenciesMs))
-	for k, v := range s.lastProbe.LatenciesMs {
-		latencies[k] = v
-	}
-	probeWarnings := append([]string(nil), s.lastProbe.Warnings...)
+	include := append([]string(nil), s.routes.IncludeCIDRs...)
+	exclude := append([]string(nil), s.routes.ExcludeCIDRs...)
 
 	return Snapshot{
+		Rev:        s.rev,
 		AgentState: s.agent,
 		StartedAt:  s.startedAt,
 		Warnings:   warnings,
 		TUN:        s.tun,
 		Routes: RouteSnapshot{
-			DefaultVia:      s.routes.DefaultVia,
-			LanCIDRs:        lanCIDRs,
-			BypassHosts:     bypass,
-			ProxyHostRoute:  s.routes.ProxyHostRoute,
-			OriginalGateway: s.routes.OriginalGateway,
-		},
-		Tun2Socks: s.tun2socks,
-		LastProbe: ProbeSummary{
-			Reachable:   s.lastProbe.Reachable,
-			SocksOK:     s.lastProbe.SocksOK,
-			ConnectOK:   s.lastProbe.ConnectOK,
-			UDPOK:       s.lastProbe.UDPOK,
-			LatenciesMs: latencies,
-			Features:    s.lastProbe.Features,
-			LastChecked: s.lastProbe.LastChecked,
-			Warnings:    probeWarnings,
+			DefaultVia:       s.routes.DefaultVia,
+			LanCIDRs:         lanCIDRs,
+			IncludeCIDRs:     include,
+			ExcludeCIDRs:     exclude,
+			BypassHosts:      bypass,
+			ProxyHostRoute:   s.routes.ProxyHostRoute,
+			ProxyAddrs:       slices.Clone(s.routes.ProxyAddrs),
+			OriginalGateway:  s.routes.OriginalGateway,
+			OriginalGateway6: s.routes.OriginalGateway6,
 		},
+		Tun2Socks:  copyTun2Socks(s.tun2socks),
+		DNS:        copyDNS(s.dns),
+		LastProbe:  copyProbe(s.lastProbe),
+		Health:     s.health,
+		Subsystems: s.subsystemsLocked(),
 	}
 }
 
@@ -176,23 +390,33 @@ func (s *State) SetStartedAt(t time.Time) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.startedAt = t
+	s.markChanged()
 }
 
-// AppendWarning adds a non-fatal warning to the state.
-func (s *State) AppendWarning(msg string) {
-	if msg == "" {
+// AppendWarning adds a non-fatal warning to the state and records it as a
+// warning event. The message is redacted; a zero Time is set to now. A
+// warning with the source and code of one already kept (the message, when
+// it has no code) counts as another occurrence of it instead of a new
+// entry; see WarningLimits for how many are kept and for how long.
+func (s *State) AppendWarning(w Warning) {
+	if w.Message == "" {
 		return
 	}
+	w.Message = redact.String(w.Message)
+	if w.Time.IsZero() {
+		w.Time = time.Now()
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.warnings = append(s.warnings, msg)
+	w = s.addWarningLocked(w)
+	s.logger.Warn("warning recorded", "msg", w.Message, "code", w.Code, "source", w.Source, "count", w.Count)
+	s.events.Append(EventWarning, w.Message, w.eventData())
+	s.markChanged()
 }
 
 // ClearWarnings removes all accumulated warnings.
 func (s *State) ClearWarnings() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.warnings = nil
+	s.RemoveWarnings(nil)
 }
 
 // UpdateTUN replaces the current TUN snapshot with the provided value.
@@ -200,6 +424,7 @@ func (s *State) UpdateTUN(t TUNSnapshot) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.tun = t
+	s.markChanged()
 }
 
 // UpdateRoutes replaces the current routing snapshot with the provided value.
@@ -208,37 +433,157 @@ func (s *State) UpdateRoutes(r RouteSnapshot) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.routes = r
+	s.markChanged()
+}
+
+// UpdateDNS replaces the DNS forwarder snapshot.
+func (s *State) UpdateDNS(d DNSSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dns = copyDNS(d)
+	s.markChanged()
+}
+
+func copyDNS(d DNSSnapshot) DNSSnapshot {
+	d.Resolvers = append([]string(nil), d.Resolvers...)
+	d.OriginalResolvers = append([]string(nil), d.OriginalResolvers...)
+	return d
+}
+
+// copyTun2Socks returns t with its slice copied.
+func copyTun2Socks(t Tun2SocksSnapshot) Tun2SocksSnapshot {
+	t.LastErrors = append([]string(nil), t.LastErrors...)
+	return t
+}
+
+// copyProbe returns p with its maps and slices copied.
+func copyProbe(p ProbeSummary) ProbeSummary {
+	p.Latencies = maps.Clone(p.Latencies)
+	p.Warnings = slices.Clone(p.Warnings)
+	p.Hops = slices.Clone(p.Hops)
+	p.Targets = slices.Clone(p.Targets)
+	if p.TLS != nil {
+		t := *p.TLS
+		p.TLS = &t
+	}
+	if p.HTTP != nil {
+		h := *p.HTTP
+		p.HTTP = &h
+	}
+	p.Percentiles = maps.Clone(p.Percentiles)
+	return p
 }
 
 // UpdateTun2Socks replaces the current tun2socks process snapshot.
+// LastErrors is copied and redacted. Traffic is kept: it belongs to
+// UpdateTraffic.
 func (s *State) UpdateTun2Socks(p Tun2SocksSnapshot) {
+	p.LastErrors = redact.Strings(p.LastErrors)
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	p.Traffic = s.tun2socks.Traffic
 	s.tun2socks = p
+	s.markChanged()
+}
+
+// UpdateTraffic replaces the tun2socks traffic counters. Like uptime they
+// move with the clock, so they do not bump Rev or signal Changed.
+func (s *State) UpdateTraffic(t TrafficSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tun2socks.Traffic = t
 }
 
 // UpdateProbe replaces the last probe summary with a new value.
 // Slices/maps are copied defensively.
 func (s *State) UpdateProbe(p ProbeSummary) {
+	s.UpdateProbeWith(p, nil)
+}
+
+// UpdateProbeWith is UpdateProbe with extra data for the probe_result
+// event, e.g. the API request ID that ran the probe. It returns the
+// summary as stored: redacted, with Percentiles, and with a warning for
+// each regression against the baseline (see Regression), which is also
+// recorded as a warning event and a timeline entry.
+func (s *State) UpdateProbeWith(p ProbeSummary, data map[string]string) ProbeSummary {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	lat := make(map[string]int64, len(p.LatenciesMs))
-	for k, v := range p.LatenciesMs {
-		lat[k] = v
+	lat := maps.Clone(p.Latencies)
+	warns := redactWarnings(p.Warnings)
+	hops := slices.Clone(p.Hops)
+	for i := range hops {
+		hops[i].Error = redact.String(hops[i].Error)
+	}
+	targets := slices.Clone(p.Targets)
+	for i := range targets {
+		targets[i].Error = redact.String(targets[i].Error)
+	}
+	var tlsResult *ProbeTLS
+	if p.TLS != nil {
+		t := *p.TLS
+		t.Error = redact.String(t.Error)
+		tlsResult = &t
+	}
+	var httpResult *ProbeHTTP
+	if p.HTTP != nil {
+		h := *p.HTTP
+		h.URL, h.Error = redact.String(h.URL), redact.String(h.Error)
+		httpResult = &h
 	}
-	warns := append([]string(nil), p.Warnings...)
 
-	s.lastProbe = ProbeSummary{
+	next := ProbeSummary{
 		Reachable:   p.Reachable,
 		SocksOK:     p.SocksOK,
 		ConnectOK:   p.ConnectOK,
 		UDPOK:       p.UDPOK,
-		LatenciesMs: lat,
+		Latencies:   lat,
 		Features:    p.Features,
 		LastChecked: p.LastChecked,
 		Warnings:    warns,
+		Hops:        hops,
+		Target:      p.Target,
+		Targets:     targets,
+		TLS:         tlsResult,
+		HTTP:        httpResult,
+	}
+	regressions := s.regressionsLocked(next)
+	next.Warnings = append(next.Warnings, regressions...)
+	if next.ConnectOK {
+		s.latencies.add(lat)
+	}
+	next.Percentiles = s.latencies.percentiles()
+	at := next.LastChecked
+	if at.IsZero() {
+		at = time.Now()
+	}
+	from := s.health.Status
+	if s.applyHealthLocked(next.ConnectOK, at) {
+		if e, ok := healthChange(from, s.health, next, at); ok {
+			s.recordTimelineLocked(e)
+		}
+		s.logger.Info("proxy health changed", "from", from, "to", s.health.Status)
 	}
+	s.lastProbe = next
+	evData := map[string]string{
+		"reachable":  strconv.FormatBool(next.Reachable),
+		"socks_ok":   strconv.FormatBool(next.SocksOK),
+		"connect_ok": strconv.FormatBool(next.ConnectOK),
+		"udp_ok":     strconv.FormatBool(next.UDPOK),
+	}
+	for k, v := range data {
+		evData[k] = v
+	}
+	s.events.Append(EventProbeResult, probeResultMessage(next), evData)
+	for _, w := range regressions {
+		s.logger.Warn("probe regression", "msg", w.Message)
+		data := w.eventData()
+		data["kind"] = string(TimelineProbeRegression)
+		s.events.Append(EventWarning, w.Message, data)
+		s.recordTimelineLocked(TimelineEntry{At: at, Kind: TimelineProbeRegression, Summary: w.Message})
+	}
+	s.markChanged()
+	return copyProbe(next)
 }
 
 // ErrInvalidTransition is returned when SetAgentState receives an illegal transition.
@@ -260,6 +605,7 @@ func (s *State) SetAgentState(next AgentState) error {
 	}
 
 	if !allowedTransition(cur, next) {
+		s.logger.Warn("rejected state transition", "from", cur, "to", next)
 		return ErrInvalidTransition
 	}
 
@@ -277,26 +623,70 @@ func (s *State) SetAgentState(next AgentState) error {
 	}
 
 	s.agent = next
+	s.logger.Info("state transition", "from", cur, "to", next)
+	s.recordTimelineLocked(TimelineEntry{
+		At:      time.Now(),
+		Kind:    TimelineStateChange,
+		Summary: "agent " + string(cur) + " -> " + string(next),
+		From:    string(cur),
+		To:      string(next),
+	})
+	s.events.Append(EventStateChange, "agent "+string(cur)+" -> "+string(next), map[string]string{
+		"from": string(cur),
+		"to":   string(next),
+	})
+	s.markChanged()
 	return nil
 }
 
-func allowedTransition(cur, next AgentState) bool {
-	switch cur {
-	case StateInactive:
-		return next == StateStarting || next == StateActive
-	case StateStarting:
-		return next == StateActive || next == StateError || next == StateInactive
-	case StateActive:
-		return next == StateDegraded || next == StateStopping || next == StateError
-	case StateDegraded:
-		return next == StateActive || next == StateStopping || next == StateError
-	case StateStopping:
-		return next == StateInactive || next == StateError
-	case StateError:
-		return next == StateInactive || next == StateStarting
-	default:
-		return false
+// probeResultMessage summarizes a probe for the event log.
+func probeResultMessage(p ProbeSummary) string {
+	if p.ConnectOK {
+		return "probe ok"
+	}
+	if last := LastWarning(p.Warnings); last != "" {
+		return "probe failed: " + last
+	}
+	return "probe failed"
+}
+
+// AgentStates lists every AgentState in lifecycle order; StateInactive is
+// the initial state.
+var AgentStates = []AgentState{StateInactive, StateStarting, StateActive, StateDegraded, StatePaused, StateStopping, StateError}
+
+// transitions is the allowed-transition table enforced by SetAgentState and
+// reported by Transitions.
+var transitions = map[AgentState][]AgentState{
+	StateInactive: {StateStarting, StateActive},
+	StateStarting: {StateActive, StateError, StateInactive},
+	StateActive:   {StateDegraded, StatePaused, StateStopping, StateError},
+	StateDegraded: {StateActive, StatePaused, StateStopping, StateError},
+	StatePaused:   {StateActive, StateStopping, StateError},
+	StateStopping: {StateInactive, StateError},
+	StateError:    {StateInactive, StateStarting},
+}
+
+// Transition is one allowed edge of the state machine.
+type Transition struct {
+	From AgentState
+	To   AgentState
+}
+
+// Transitions returns every allowed transition, grouped by From in
+// AgentStates order, so callers can render the lifecycle without
+// duplicating the table.
+func Transitions() []Transition {
+	var out []Transition
+	for _, from := range AgentStates {
+		for _, to := range transitions[from] {
+			out = append(out, Transition{From: from, To: to})
+		}
 	}
+	return out
+}
+
+func allowedTransition(cur, next AgentState) bool {
+	return slices.Contains(transitions[cur], next)
 }
 
 // Reset clears all mutable state back to a fresh NewState, retaining only
@@ -318,5 +708,57 @@ func (s *State) Reset(clearLifecycle bool) {
 	s.tun = TUNSnapshot{}
 	s.routes = RouteSnapshot{}
 	s.tun2socks = Tun2SocksSnapshot{}
+	s.dns = DNSSnapshot{}
 	s.lastProbe = ProbeSummary{}
+	s.latencies = nil
+	s.regressed = regressionState{}
+	s.health = HealthSnapshot{Status: HealthUnknown}
+	s.markChanged()
+}
+
+// Restore replaces all mutable state with snap, bypassing transition checks.
+// It is intended for loading persisted state at startup, before any other
+// mutation. Slices/maps are copied defensively.
+func (s *State) Restore(snap Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.agent = snap.AgentState
+	if s.agent == "" {
+		s.agent = StateInactive
+	}
+	s.startedAt = snap.StartedAt
+	s.warnings = slices.Clone(snap.Warnings)
+	for i, w := range s.warnings {
+		// Warnings saved before occurrences were counted.
+		s.warnings[i].Count = max(w.Count, 1)
+		if w.First.IsZero() {
+			s.warnings[i].First = w.Time
+		}
+	}
+	s.trimWarningsLocked(time.Now())
+	s.tun = snap.TUN
+	s.routes = RouteSnapshot{
+		DefaultVia:       snap.Routes.DefaultVia,
+		LanCIDRs:         append([]string(nil), snap.Routes.LanCIDRs...),
+		IncludeCIDRs:     append([]string(nil), snap.Routes.IncludeCIDRs...),
+		ExcludeCIDRs:     append([]string(nil), snap.Routes.ExcludeCIDRs...),
+		BypassHosts:      append([]string(nil), snap.Routes.BypassHosts...),
+		ProxyHostRoute:   snap.Routes.ProxyHostRoute,
+		ProxyAddrs:       slices.Clone(snap.Routes.ProxyAddrs),
+		OriginalGateway:  snap.Routes.OriginalGateway,
+		OriginalGateway6: snap.Routes.OriginalGateway6,
+	}
+	s.tun2socks = copyTun2Socks(snap.Tun2Socks)
+	s.dns = copyDNS(snap.DNS)
+	s.lastProbe = snap.LastProbe
+	s.lastProbe.Latencies = maps.Clone(snap.LastProbe.Latencies)
+	s.lastProbe.Warnings = slices.Clone(snap.LastProbe.Warnings)
+	s.lastProbe.Percentiles = maps.Clone(snap.LastProbe.Percentiles)
+	s.health = HealthSnapshot{Status: snap.Health.Status, Since: snap.Health.Since}
+	if s.health.Status == "" {
+		s.health.Status = HealthUnknown
+	}
+	s.logger.Info("state restored", "agent_state", s.agent)
+	s.markChanged()
 }
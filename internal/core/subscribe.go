@@ -0,0 +1,83 @@
+package core
+
+import "context"
+
+// DefaultSubscriberBuffer is the per-subscriber channel capacity.
+const DefaultSubscriberBuffer = 64
+
+// StateEvent is delivered to subscribers for every event appended to the
+// log. Dropped counts events this subscriber has lost so far because its
+// buffer was full (drop-oldest); a change since the previous receive means
+// the subscriber should resynchronize from GetSnapshot.
+type StateEvent struct {
+	Event
+	Dropped uint64
+}
+
+type subscriber struct {
+	ch      chan StateEvent
+	types   map[EventType]bool // nil: all types
+	dropped uint64
+}
+
+// Subscribe returns a channel receiving every subsequent event whose type is
+// in types (all types when none are given). The channel is buffered; when a
+// subscriber falls behind, the oldest undelivered event is discarded so
+// publishers never block. The channel is closed after ctx is done.
+func (s *State) Subscribe(ctx context.Context, types ...EventType) <-chan StateEvent {
+	return s.events.Subscribe(ctx, types...)
+}
+
+// Subscribe is the EventLog counterpart of State.Subscribe.
+func (l *EventLog) Subscribe(ctx context.Context, types ...EventType) <-chan StateEvent {
+	sub := &subscriber{ch: make(chan StateEvent, DefaultSubscriberBuffer)}
+	if len(types) > 0 {
+		sub.types = make(map[EventType]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	l.mu.Lock()
+	if l.subs == nil {
+		l.subs = make(map[*subscriber]struct{})
+	}
+	l.subs[sub] = struct{}{}
+	l.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.subs, sub)
+		// Publishing happens under l.mu, so no send can race with close.
+		close(sub.ch)
+	}()
+	return sub.ch
+}
+
+// publishLocked fans e out to matching subscribers, each with its own copy
+// of Data. Callers must hold l.mu.
+func (l *EventLog) publishLocked(e Event) {
+	for sub := range l.subs {
+		if sub.types != nil && !sub.types[e.Type] {
+			continue
+		}
+		pub := e
+		pub.Data = cloneData(e.Data)
+		for {
+			select {
+			case sub.ch <- StateEvent{Event: pub, Dropped: sub.dropped}:
+			default:
+				// Full: discard the oldest buffered event and retry.
+				select {
+				case <-sub.ch:
+					sub.dropped++
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
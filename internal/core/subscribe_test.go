@@ -0,0 +1,25 @@
+package core
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSubscribersGetOwnData(t *testing.T) {
+	l := NewEventLog(8)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a, b := l.Subscribe(ctx), l.Subscribe(ctx)
+
+	l.Append(EventOrchestration, "step", map[string]string{"step": "tun"})
+	ea, eb := <-a, <-b
+	ea.Data["step"] = "changed"
+
+	if eb.Data["step"] != "tun" {
+		t.Errorf("second subscriber sees data[step] = %q, want tun", eb.Data["step"])
+	}
+	events, _, _ := l.After(0, 0)
+	if events[0].Data["step"] != "tun" {
+		t.Errorf("log holds data[step] = %q, want tun", events[0].Data["step"])
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// SubsystemStatus is the health of an optional agent subsystem.
+type SubsystemStatus string
+
+const (
+	SubsystemOK       SubsystemStatus = "ok"
+	SubsystemDegraded SubsystemStatus = "degraded" // running with a fallback
+	SubsystemFailed   SubsystemStatus = "failed"   // not running
+	SubsystemDisabled SubsystemStatus = "disabled" // turned off by configuration or platform
+)
+
+// Subsystem describes one optional subsystem (storage, a listener, an
+// export sink, ...). The agent boots even when some fail, and reports them
+// here instead of exiting.
+type Subsystem struct {
+	Name   string
+	Status SubsystemStatus
+	Detail string    // error or fallback description; empty when ok
+	Since  time.Time // when Status last changed
+}
+
+// SetSubsystem records the status of a named subsystem. A change into
+// degraded or failed is logged and recorded as a warning event; repeating
+// the same status only updates Detail.
+func (s *State) SetSubsystem(name string, status SubsystemStatus, detail string) {
+	detail = redact.String(detail)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subsystems == nil {
+		s.subsystems = make(map[string]Subsystem)
+	}
+	prev, ok := s.subsystems[name]
+	if ok && prev.Status == status && prev.Detail == detail {
+		return
+	}
+	sub := Subsystem{Name: name, Status: status, Detail: detail, Since: prev.Since}
+	if !ok || prev.Status != status {
+		sub.Since = time.Now()
+		if status == SubsystemDegraded || status == SubsystemFailed {
+			s.logger.Warn("subsystem unhealthy", "subsystem", name, "status", status, "detail", detail)
+			s.events.Append(EventWarning, "subsystem "+name+" "+string(status)+": "+detail, map[string]string{
+				"subsystem": name,
+				"status":    string(status),
+			})
+		}
+	}
+	s.subsystems[name] = sub
+	s.markChanged()
+}
+
+// subsystemsLocked returns subsystems sorted by name. Callers hold s.mu.
+func (s *State) subsystemsLocked() []Subsystem {
+	out := make([]Subsystem, 0, len(s.subsystems))
+	for _, sub := range s.subsystems {
+		out = append(out, sub)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimelineKind classifies a significant health event.
+type TimelineKind string
+
+const (
+	TimelineStateChange    TimelineKind = "state_change"    // AgentState transition
+	TimelineProbeFailed    TimelineKind = "probe_failed"    // proxy health became degraded (see Hysteresis)
+	TimelineProbeRecovered TimelineKind = "probe_recovered" // proxy health recovered
+	TimelineFailover       TimelineKind = "failover"        // upstream/uplink switched
+	TimelineNetworkChange  TimelineKind = "network_change"  // gateway/interface/location changed
+	// TimelineProbeRegression: a probe was slower than its baseline or
+	// lost a capability (see Regression).
+	TimelineProbeRegression TimelineKind = "probe_regression"
+)
+
+// maxTimelineEntries bounds memory for the timeline ring; at typical event
+// rates this covers well over a day.
+const maxTimelineEntries = 1024
+
+// TimelineEntry is one significant health event. Entries are compact by
+// design: the timeline records transitions, not every observation.
+type TimelineEntry struct {
+	At      time.Time
+	Kind    TimelineKind
+	Summary string // Human-readable one-liner
+	From    string // Previous value (state name, "ok"/"failed"), if applicable
+	To      string // New value, if applicable
+}
+
+// RecordTimeline appends an entry stamped with the current time. Core records
+// state changes and probe failures/recoveries itself; other subsystems use
+// this for failovers and network changes.
+func (s *State) RecordTimeline(kind TimelineKind, summary, from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordTimelineLocked(TimelineEntry{At: time.Now(), Kind: kind, Summary: summary, From: from, To: to})
+}
+
+// Timeline returns entries at or after since, oldest first.
+func (s *State) Timeline(since time.Time) []TimelineEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// Entries are appended in time order; find the first one in range.
+	i := 0
+	for i < len(s.timeline) && s.timeline[i].At.Before(since) {
+		i++
+	}
+	return append([]TimelineEntry(nil), s.timeline[i:]...)
+}
+
+// recordTimelineLocked appends e, evicting the oldest entries past the cap.
+// Callers must hold s.mu.
+func (s *State) recordTimelineLocked(e TimelineEntry) {
+	if len(s.timeline) >= maxTimelineEntries {
+		n := copy(s.timeline, s.timeline[len(s.timeline)-maxTimelineEntries+1:])
+		s.timeline = s.timeline[:n]
+	}
+	s.timeline = append(s.timeline, e)
+}
+
+// healthChange returns the timeline entry for a damped health transition
+// from prev to h caused by probe p, or false if none is recorded. Leaving
+// unknown is recorded only when the first probe fails.
+func healthChange(prev HealthStatus, h HealthSnapshot, p ProbeSummary, at time.Time) (TimelineEntry, bool) {
+	switch {
+	case h.Status == HealthDegraded:
+		from := "unknown"
+		if prev == HealthOK {
+			from = "ok"
+		}
+		summary := "proxy probe failed"
+		if h.ConsecutiveFailures > 1 {
+			summary = fmt.Sprintf("proxy probe failed %d times in a row", h.ConsecutiveFailures)
+		}
+		if last := LastWarning(p.Warnings); last != "" {
+			summary += ": " + last
+		}
+		return TimelineEntry{At: at, Kind: TimelineProbeFailed, Summary: summary, From: from, To: "failed"}, true
+	case h.Status == HealthOK && prev == HealthDegraded:
+		return TimelineEntry{At: at, Kind: TimelineProbeRecovered, Summary: "proxy probe recovered", From: "failed", To: "ok"}, true
+	default:
+		return TimelineEntry{}, false
+	}
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// DefaultMaxWarnings bounds the warnings kept in the state.
+const DefaultMaxWarnings = 100
+
+// Warning is a non-fatal anomaly kept in the state or a probe result.
+type Warning struct {
+	Code     string // stable cause, e.g. "tcp_connect_failed"; empty when unclassified
+	Severity Severity
+	Source   string // what raised it, e.g. "probe", "dns", "recovery"
+	Message  string
+	Time     time.Time // latest occurrence
+	// Count and First are kept by the state: how often the warning was
+	// raised and when it first was. Zero in probe results.
+	Count int
+	First time.Time
+}
+
+// sameWarning reports whether a and b are occurrences of one warning: the
+// same source and code, or the same message when unclassified.
+func sameWarning(a, b Warning) bool {
+	if a.Source != b.Source || a.Code != b.Code {
+		return false
+	}
+	return a.Code != "" || a.Message == b.Message
+}
+
+// WarningLimits bounds the warnings kept in the state. A repeated warning
+// (see AppendWarning) counts once.
+type WarningLimits struct {
+	Max int           // most kept; the least recently raised go first. 0 uses DefaultMaxWarnings
+	TTL time.Duration // drop a warning not raised again for this long; 0 keeps it
+}
+
+// SetWarningLimits replaces the warning limits and applies them.
+func (s *State) SetWarningLimits(l WarningLimits) {
+	if l.Max <= 0 {
+		l.Max = DefaultMaxWarnings
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warningLimits = l
+	if s.trimWarningsLocked(time.Now()) {
+		s.markChanged()
+	}
+}
+
+// WarningLimits returns the warning limits in effect.
+func (s *State) WarningLimits() WarningLimits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.warningLimits
+}
+
+// addWarningLocked appends w or, when it repeats a kept warning, folds it
+// into that one: the count grows, and the message, time, and severity
+// follow the latest occurrence (the severity never drops). It returns the
+// stored warning. Callers hold s.mu.
+func (s *State) addWarningLocked(w Warning) Warning {
+	w.Count, w.First = 1, w.Time
+	if i := slices.IndexFunc(s.warnings, func(k Warning) bool { return sameWarning(k, w) }); i >= 0 {
+		prev := s.warnings[i]
+		w.Count, w.First = prev.Count+1, prev.First
+		w.Severity = max(w.Severity, prev.Severity)
+		s.warnings[i] = w
+	} else {
+		s.warnings = append(s.warnings, w)
+	}
+	s.trimWarningsLocked(w.Time)
+	return w
+}
+
+// trimWarningsLocked drops expired warnings, then the least recently
+// raised ones beyond the limit, and reports whether any went. Callers hold
+// s.mu.
+func (s *State) trimWarningsLocked(now time.Time) bool {
+	n := len(s.warnings)
+	if ttl := s.warningLimits.TTL; ttl > 0 {
+		s.warnings = slices.DeleteFunc(s.warnings, func(w Warning) bool { return now.Sub(w.Time) > ttl })
+	}
+	for len(s.warnings) > s.warningLimits.Max {
+		oldest := 0
+		for i, w := range s.warnings {
+			if w.Time.Before(s.warnings[oldest].Time) {
+				oldest = i
+			}
+		}
+		s.warnings = slices.Delete(s.warnings, oldest, oldest+1)
+	}
+	return len(s.warnings) != n
+}
+
+// expireWarnings drops warnings past the TTL, so readers never see them.
+func (s *State) expireWarnings() {
+	now := time.Now()
+	s.mu.RLock()
+	ttl := s.warningLimits.TTL
+	stale := ttl > 0 && slices.ContainsFunc(s.warnings, func(w Warning) bool { return now.Sub(w.Time) > ttl })
+	s.mu.RUnlock()
+	if !stale {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.trimWarningsLocked(now) {
+		s.markChanged()
+	}
+}
+
+// NewWarning returns a warning-severity Warning stamped with the current
+// time.
+func NewWarning(source, code, msg string) Warning {
+	return Warning{Code: code, Severity: SeverityWarning, Source: source, Message: msg, Time: time.Now()}
+}
+
+// WarningMessages returns the messages of ws, the flat form older clients
+// read.
+func WarningMessages(ws []Warning) []string {
+	out := make([]string, len(ws))
+	for i, w := range ws {
+		out[i] = w.Message
+	}
+	return out
+}
+
+// LastWarning returns the message of the last warning in ws, or "".
+func LastWarning(ws []Warning) string {
+	if len(ws) == 0 {
+		return ""
+	}
+	return ws[len(ws)-1].Message
+}
+
+// redactWarnings returns a copy of ws with redacted messages.
+func redactWarnings(ws []Warning) []Warning {
+	out := slices.Clone(ws)
+	for i := range out {
+		out[i].Message = redact.String(out[i].Message)
+	}
+	return out
+}
+
+// eventData is the data of the warning event recording w.
+func (w Warning) eventData() map[string]string {
+	data := map[string]string{"severity": w.Severity.String()}
+	if w.Count > 1 {
+		data["count"] = strconv.Itoa(w.Count)
+	}
+	if w.Code != "" {
+		data["code"] = w.Code
+	}
+	if w.Source != "" {
+		data["source"] = w.Source
+	}
+	return data
+}
+
+// RemoveWarnings drops the warnings match accepts, or all of them when
+// match is nil, and returns how many it dropped.
+func (s *State) RemoveWarnings(match func(Warning) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.warnings)
+	if match == nil {
+		s.warnings = nil
+	} else {
+		s.warnings = slices.DeleteFunc(s.warnings, match)
+	}
+	if removed := n - len(s.warnings); removed > 0 {
+		s.markChanged()
+		return removed
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package diag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBusy is returned when another contention sample is in progress.
+var ErrBusy = errors.New("a contention sample is already running")
+
+// maxStackDepth bounds symbolized stacks.
+const maxStackDepth = 8
+
+// Site is one contended call path within the sample window.
+type Site struct {
+	Count int64         // contention events (mutex) or blocking events (block)
+	Delay time.Duration // total time waited
+	Stack []string      // innermost first
+}
+
+// Contention is the result of one sample.
+type Contention struct {
+	Window     time.Duration
+	Mutex      []Site // sorted by Delay, descending
+	Block      []Site // sorted by Delay, descending
+	Goroutines int    // at the end of the window
+}
+
+var sampling sync.Mutex
+
+// SampleContention profiles mutex and block contention for window and
+// returns up to top sites of each kind. It returns early with what was
+// collected if ctx ends first.
+func SampleContention(ctx context.Context, window time.Duration, top int) (Contention, error) {
+	if !sampling.TryLock() {
+		return Contention{}, ErrBusy
+	}
+	defer sampling.Unlock()
+
+	prevMutex := runtime.SetMutexProfileFraction(1)
+	runtime.SetBlockProfileRate(1)
+	defer func() {
+		runtime.SetMutexProfileFraction(prevMutex)
+		// The block profile rate cannot be read back; the agent never
+		// enables it elsewhere, so restore the default (off).
+		runtime.SetBlockProfileRate(0)
+	}()
+
+	mu0, bl0 := mutexRecords(), blockRecords()
+	start := time.Now()
+	t := time.NewTimer(window)
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		t.Stop()
+	}
+	out := Contention{
+		Window:     time.Since(start),
+		Mutex:      diff(mu0, mutexRecords(), top),
+		Block:      diff(bl0, blockRecords(), top),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	return out, nil
+}
+
+func mutexRecords() []runtime.BlockProfileRecord {
+	return readProfile(runtime.MutexProfile)
+}
+
+func blockRecords() []runtime.BlockProfileRecord {
+	return readProfile(runtime.BlockProfile)
+}
+
+// readProfile grows the buffer until the runtime's record set fits.
+func readProfile(read func([]runtime.BlockProfileRecord) (int, bool)) []runtime.BlockProfileRecord {
+	n, _ := read(nil)
+	for {
+		recs := make([]runtime.BlockProfileRecord, n+16)
+		m, ok := read(recs)
+		if ok {
+			return recs[:m]
+		}
+		n = m
+	}
+}
+
+type stackKey [32]uintptr
+
+func keyOf(r *runtime.BlockProfileRecord) stackKey {
+	var k stackKey
+	copy(k[:], r.Stack())
+	return k
+}
+
+// diff subtracts before from after per stack and symbolizes the top sites.
+func diff(before, after []runtime.BlockProfileRecord, top int) []Site {
+	base := make(map[stackKey]runtime.BlockProfileRecord, len(before))
+	for i := range before {
+		base[keyOf(&before[i])] = before[i]
+	}
+	type delta struct {
+		count  int64
+		cycles int64
+		stack  []uintptr
+	}
+	var ds []delta
+	for i := range after {
+		r := &after[i]
+		b := base[keyOf(r)]
+		d := delta{count: r.Count - b.Count, cycles: r.Cycles - b.Cycles, stack: r.Stack()}
+		if d.count > 0 && d.cycles > 0 {
+			ds = append(ds, d)
+		}
+	}
+	slices.SortFunc(ds, func(a, b delta) int { return cmp64(b.cycles, a.cycles) })
+	if top > 0 && len(ds) > top {
+		ds = ds[:top]
+	}
+	perNs := cyclesPerNanosecond()
+	out := make([]Site, 0, len(ds))
+	for _, d := range ds {
+		out = append(out, Site{
+			Count: d.count,
+			Delay: time.Duration(float64(d.cycles) / perNs),
+			Stack: symbolize(d.stack),
+		})
+	}
+	return out
+}
+
+func cmp64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// symbolize renders pcs innermost first, skipping sync and runtime frames.
+func symbolize(pcs []uintptr) []string {
+	frames := runtime.CallersFrames(pcs)
+	var out []string
+	for {
+		f, more := frames.Next()
+		if f.Function != "" && !strings.HasPrefix(f.Function, "runtime.") && !strings.HasPrefix(f.Function, "sync.") {
+			out = append(out, fmt.Sprintf("%s %s:%d", f.Function, filepath.Base(f.File), f.Line))
+			if len(out) == maxStackDepth {
+				break
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return out
+}
+
+var (
+	cpnsOnce sync.Once
+	cpns     float64
+)
+
+// cyclesPerNanosecond returns the rate of the runtime's cputicks clock, in
+// which profile Cycles are measured. The runtime exposes it only through the
+// legacy text profile header ("cycles/second=N").
+func cyclesPerNanosecond() float64 {
+	cpnsOnce.Do(func() {
+		cpns = 1
+		var buf bytes.Buffer
+		if err := pprof.Lookup("mutex").WriteTo(&buf, 1); err != nil {
+			return
+		}
+		for _, line := range strings.Split(buf.String(), "\n") {
+			if v, ok := strings.CutPrefix(line, "cycles/second="); ok {
+				if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && n > 0 {
+					cpns = n / 1e9
+				}
+				return
+			}
+		}
+	})
+	return cpns
+}
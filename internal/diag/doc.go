@@ -0,0 +1,24 @@
+// Package diag collects runtime self-diagnostics for the agent.
+//
+// # Contention Sampling
+//
+// SampleContention turns on the runtime mutex and block profilers for a short
+// window, diffs the cumulative profile records taken before and after, and
+// returns the stacks with the most accumulated delay. Profiling is switched
+// back off afterwards, so normal operation pays nothing.
+//
+// Only one sample runs at a time (ErrBusy otherwise): the profiler rates are
+// process-global, and overlapping windows would distort each other.
+//
+// Stacks are symbolized to "package.Function file:line" strings, innermost
+// frame first, with sync and runtime frames trimmed so the first entry is the
+// contended call site (e.g., a core.(*State) method).
+//
+// # Runtime Statistics
+//
+// ReadRuntime snapshots goroutine counts, heap statistics, and GC metrics
+// from runtime.ReadMemStats. Comparing snapshots taken minutes apart shows
+// whether memory growth during a long capture is live heap (HeapAlloc and
+// HeapObjects keep rising across GCs), goroutine leaks, or memory the
+// runtime has not yet returned to the OS (HeapIdle minus HeapReleased).
+package diag
@@ -0,0 +1,78 @@
+package diag
+
+import (
+	"runtime"
+	"time"
+)
+
+// processStart approximates the process start for Runtime.Uptime.
+var processStart = time.Now()
+
+// Runtime is a snapshot of Go runtime statistics.
+type Runtime struct {
+	GoVersion  string
+	GOMAXPROCS int
+	NumCPU     int
+	Goroutines int
+	CgoCalls   int64
+	Uptime     time.Duration
+
+	// Heap, in bytes unless noted.
+	HeapAlloc    uint64 // live and not yet swept objects
+	HeapInuse    uint64 // spans with at least one object
+	HeapIdle     uint64 // spans with no objects
+	HeapReleased uint64 // idle memory returned to the OS
+	HeapObjects  uint64 // count
+	HeapSys      uint64 // obtained from the OS for the heap
+	StackInuse   uint64
+	Sys          uint64 // total obtained from the OS
+	TotalAlloc   uint64 // cumulative bytes allocated
+	Mallocs      uint64 // cumulative count
+	Frees        uint64 // cumulative count
+
+	// Garbage collector.
+	NumGC         uint32
+	NumForcedGC   uint32
+	NextGC        uint64 // heap size target of the next cycle
+	LastGC        time.Time
+	PauseTotal    time.Duration
+	LastPause     time.Duration
+	GCCPUFraction float64 // share of CPU time used by the GC since start
+}
+
+// ReadRuntime returns current runtime statistics. It stops the world
+// briefly (runtime.ReadMemStats), so callers should not poll it in a
+// tight loop.
+func ReadRuntime() Runtime {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	rt := Runtime{
+		GoVersion:     runtime.Version(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		Goroutines:    runtime.NumGoroutine(),
+		CgoCalls:      runtime.NumCgoCall(),
+		Uptime:        time.Since(processStart),
+		HeapAlloc:     m.HeapAlloc,
+		HeapInuse:     m.HeapInuse,
+		HeapIdle:      m.HeapIdle,
+		HeapReleased:  m.HeapReleased,
+		HeapObjects:   m.HeapObjects,
+		HeapSys:       m.HeapSys,
+		StackInuse:    m.StackInuse,
+		Sys:           m.Sys,
+		TotalAlloc:    m.TotalAlloc,
+		Mallocs:       m.Mallocs,
+		Frees:         m.Frees,
+		NumGC:         m.NumGC,
+		NumForcedGC:   m.NumForcedGC,
+		NextGC:        m.NextGC,
+		PauseTotal:    time.Duration(m.PauseTotalNs),
+		GCCPUFraction: m.GCCPUFraction,
+	}
+	if m.NumGC > 0 {
+		rt.LastGC = time.Unix(0, int64(m.LastGC))
+		rt.LastPause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	}
+	return rt
+}
@@ -0,0 +1,24 @@
+// Package discovery inspects the host network to derive routing inputs.
+//
+// # LAN Detection
+//
+// DetectLAN enumerates local interfaces and returns the networks that must
+// stay reachable outside the TUN device:
+//
+//   - RFC 1918 networks (10/8, 172.16/12, 192.168/16) actually configured on
+//     an interface, masked to the interface prefix (e.g., 192.168.1.0/24,
+//     not the whole 192.168/16).
+//   - Link-local ranges (169.254.0.0/16, fe80::/10) when an interface uses
+//     them.
+//   - The subnet containing the current default gateway, even if public, so
+//     the gateway itself stays reachable.
+//
+// Down, loopback, and point-to-point interfaces (TUN devices, VPNs) are
+// ignored, as are names listed in Options.SkipInterfaces. Results are
+// deduplicated and sorted for stable output.
+//
+// # Testability
+//
+// Options.Interfaces and Options.Gateway replace the OS lookups, so callers
+// can supply fixed inputs; the defaults use package net and no gateway.
+package discovery
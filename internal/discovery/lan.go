@@ -0,0 +1,155 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// Reason explains why a network was selected.
+type Reason string
+
+const (
+	ReasonRFC1918       Reason = "rfc1918"
+	ReasonLinkLocal     Reason = "link_local"
+	ReasonGatewaySubnet Reason = "gateway_subnet"
+)
+
+// Interface is the subset of interface data DetectLAN needs.
+type Interface struct {
+	Name         string
+	Up           bool
+	Loopback     bool
+	PointToPoint bool
+	Prefixes     []netip.Prefix // configured addresses with their prefix length
+}
+
+// LANNet is one detected network.
+type LANNet struct {
+	Prefix    netip.Prefix // masked network
+	Interface string       // interface the network was found on ("" for link-local ranges)
+	Reason    Reason
+}
+
+// Options configures DetectLAN.
+type Options struct {
+	// Interfaces lists host interfaces (default: SystemInterfaces).
+	Interfaces func() ([]Interface, error)
+	// Gateway returns the IPv4 default gateway or "" (default: none).
+	Gateway func() (string, error)
+	// SkipInterfaces are ignored by name (e.g., the agent's own TUN).
+	SkipInterfaces []string
+}
+
+var (
+	rfc1918 = []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+		netip.MustParsePrefix("192.168.0.0/16"),
+	}
+	linkLocal4 = netip.MustParsePrefix("169.254.0.0/16")
+	linkLocal6 = netip.MustParsePrefix("fe80::/10")
+)
+
+// DetectLAN returns the LAN networks present on the host, sorted by
+// address. A gateway lookup failure is returned alongside the networks found
+// without it, so callers can degrade to a warning.
+func DetectLAN(opts Options) ([]LANNet, error) {
+	list := opts.Interfaces
+	if list == nil {
+		list = SystemInterfaces
+	}
+	ifaces, err := list()
+	if err != nil {
+		return nil, fmt.Errorf("list interfaces: %w", err)
+	}
+
+	var gw netip.Addr
+	var gwErr error
+	if opts.Gateway != nil {
+		s, err := opts.Gateway()
+		switch {
+		case err != nil:
+			gwErr = fmt.Errorf("default gateway: %w", err)
+		case s != "":
+			if gw, err = netip.ParseAddr(s); err != nil {
+				gwErr = fmt.Errorf("default gateway %q: %w", s, err)
+			}
+		}
+	}
+
+	seen := map[netip.Prefix]bool{}
+	var out []LANNet
+	add := func(p netip.Prefix, iface string, why Reason) {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, LANNet{Prefix: p, Interface: iface, Reason: why})
+		}
+	}
+	for _, ifc := range ifaces {
+		if !ifc.Up || ifc.Loopback || ifc.PointToPoint || slices.Contains(opts.SkipInterfaces, ifc.Name) {
+			continue
+		}
+		for _, p := range ifc.Prefixes {
+			a := p.Addr().Unmap()
+			network := netip.PrefixFrom(a, p.Bits()).Masked()
+			switch {
+			case gw.IsValid() && network.Contains(gw):
+				add(network, ifc.Name, ReasonGatewaySubnet)
+			case a.Is4() && linkLocal4.Contains(a):
+				add(linkLocal4, "", ReasonLinkLocal)
+			case a.Is6() && linkLocal6.Contains(a):
+				add(linkLocal6, "", ReasonLinkLocal)
+			case isRFC1918(a):
+				add(network, ifc.Name, ReasonRFC1918)
+			}
+		}
+	}
+	slices.SortFunc(out, func(x, y LANNet) int {
+		if c := x.Prefix.Addr().Compare(y.Prefix.Addr()); c != 0 {
+			return c
+		}
+		return x.Prefix.Bits() - y.Prefix.Bits()
+	})
+	return out, gwErr
+}
+
+func isRFC1918(a netip.Addr) bool {
+	for _, p := range rfc1918 {
+		if p.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// SystemInterfaces reads interfaces and addresses via netinfo.Interfaces.
+func SystemInterfaces() ([]Interface, error) {
+	ifaces, err := netinfo.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Interface, 0, len(ifaces))
+	for _, ifc := range ifaces {
+		out = append(out, Interface{
+			Name:         ifc.Name,
+			Up:           ifc.Flags&net.FlagUp != 0,
+			Loopback:     ifc.Flags&net.FlagLoopback != 0,
+			PointToPoint: ifc.Flags&net.FlagPointToPoint != 0,
+			Prefixes:     ifc.Prefixes,
+		})
+	}
+	return out, nil
+}
+
+// Strings formats nets as CIDR strings, preserving order.
+func Strings(nets []LANNet) []string {
+	out := make([]string, 0, len(nets))
+	for _, n := range nets {
+		out = append(out, n.Prefix.String())
+	}
+	return out
+}
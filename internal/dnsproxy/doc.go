@@ -0,0 +1,44 @@
+// Package dnsproxy implements a local DNS forwarder that keeps queries
+// inside the tunnel.
+//
+// # Forwarding
+//
+// A Forwarder listens on UDP and TCP port 53 of the TUN address and relays
+// every query to an Upstream over a stream through the SOCKS proxy:
+//
+//   - "tcp://1.1.1.1:53": plain DNS over TCP (RFC 7766)
+//   - "tls://1.1.1.1:853?sni=cloudflare-dns.com": DNS over TLS (RFC 7858)
+//   - "https://dns.google/dns-query": DNS over HTTPS (RFC 8484, POST)
+//
+// Upstream hostnames are sent to the proxy unresolved, so no lookup leaves
+// the host in the clear. tls and https upstreams take "sni=" to override
+// the server name and repeated "pin=" SHA-256 SPKI digests (base64); with
+// pins, the verified chain must contain a pinned key.
+// Options.Observe sees every upstream answer, which lets the rules engine
+// learn split-tunnel routes without sniffing the TUN.
+//
+// # Fallback
+//
+// Options.Upstreams is an ordered list. A query that fails on one upstream
+// is retried on the next; after three consecutive failures an upstream is
+// tried last for 30 seconds, and a success ends the cooldown early.
+// Queries that fail everywhere are answered with SERVFAIL. Upstreams
+// reports attempts, failures, a latency moving average, and the last
+// error for each upstream.
+//
+// # System Resolvers
+//
+// Start points the system resolvers at the forwarder and Stop restores
+// them, through the platform's Resolvers:
+//
+//   - Linux renames /etc/resolv.conf to /etc/resolv.conf.spl-backup
+//     (preserving a systemd-resolved symlink) and writes a file with the
+//     forwarder as the only nameserver, keeping search and options lines.
+//   - macOS saves each network service's DNS servers (networksetup) to a
+//     JSON file and sets the forwarder on every enabled service.
+//   - Other platforms return ErrUnsupported; the forwarder still runs.
+//
+// The saved configuration lives on disk until restored, so a crash leaves
+// enough behind for the next boot: Restore is idempotent and reports
+// whether there was anything to restore.
+package dnsproxy
@@ -0,0 +1,375 @@
+package dnsproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults and limits.
+const (
+	DefaultPort    = 53
+	DefaultTimeout = 5 * time.Second
+	maxInflight    = 128              // concurrent upstream queries
+	tcpIdle        = 10 * time.Second // idle TCP client connections are closed
+	headerLen      = 12
+)
+
+// ErrRunning is returned by Start when the forwarder is already running.
+var ErrRunning = errors.New("dns forwarder already running")
+
+// Options configures a Forwarder.
+type Options struct {
+	// Upstreams are tried in order for every query: a failed exchange
+	// falls back to the next one, and an upstream failing several queries
+	// in a row is tried last until its cooldown ends. Empty uses
+	// DefaultUpstream.
+	Upstreams []Upstream
+	// Port is the listening port on the TUN address (default 53).
+	Port int
+	// Timeout bounds one upstream exchange (default 5s); a query that falls
+	// back spends up to Timeout on each upstream.
+	Timeout time.Duration
+	// Resolvers rewrites the system resolvers on Start and restores them on
+	// Stop. Nil leaves them untouched.
+	Resolvers Resolvers
+	// Observe, when set, receives every upstream answer.
+	Observe func(msg []byte)
+	// OnChange, when set, is called with the new status after Start and Stop.
+	OnChange func(Status)
+	// Logger receives forwarder records. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Status describes the forwarder and the resolver rewrite.
+type Status struct {
+	Running           bool
+	Listen            string   // "198.18.0.1:53" while running
+	Upstream          string   // first upstream a query tries now
+	Resolvers         []string // system resolvers as last read or set
+	OriginalResolvers []string // system resolvers before the rewrite
+	Rewritten         bool     // Resolvers point at the forwarder
+	Queries           uint64
+	Failures          uint64
+}
+
+// Forwarder is a local DNS listener relaying queries to an Upstream.
+type Forwarder struct {
+	opts   Options
+	logger *slog.Logger
+
+	queries  atomic.Uint64
+	failures atomic.Uint64
+
+	mu       sync.Mutex
+	running  bool
+	listen   netip.AddrPort
+	udp      *net.UDPConn
+	tcp      net.Listener
+	ups      []*upstream
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	original []netip.Addr
+	current  []netip.Addr
+	rewrote  bool
+}
+
+// New constructs a stopped forwarder.
+func New(opts Options) *Forwarder {
+	if opts.Port == 0 {
+		opts.Port = DefaultPort
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	if len(opts.Upstreams) == 0 {
+		u, _ := ParseUpstream(DefaultUpstream)
+		opts.Upstreams = []Upstream{u}
+	}
+	f := &Forwarder{opts: opts, logger: logger}
+	for _, u := range opts.Upstreams {
+		f.ups = append(f.ups, &upstream{u: u})
+	}
+	if opts.Resolvers != nil {
+		f.current, _ = opts.Resolvers.Current()
+	}
+	return f
+}
+
+// Start listens on addr (normally the TUN address), relays queries with
+// dial, and points the system resolvers at the forwarder. If the rewrite
+// fails for a reason other than ErrUnsupported, the listeners are closed
+// and the error returned.
+func (f *Forwarder) Start(addr netip.Addr, dial DialFunc) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.running {
+		return ErrRunning
+	}
+	listen := f.ListenAddr(addr)
+	udp, err := net.ListenUDP("udp", net.UDPAddrFromAddrPort(listen))
+	if err != nil {
+		return fmt.Errorf("dns listen udp %s: %w", listen, err)
+	}
+	tcp, err := net.Listen("tcp", listen.String())
+	if err != nil {
+		udp.Close()
+		return fmt.Errorf("dns listen tcp %s: %w", listen, err)
+	}
+
+	if r := f.opts.Resolvers; r != nil {
+		original, _ := r.Current()
+		switch err := r.Apply([]netip.Addr{addr}); {
+		case err == nil:
+			f.original, f.current, f.rewrote = original, []netip.Addr{addr}, true
+		case errors.Is(err, ErrUnsupported):
+			f.logger.Warn("system resolvers not rewritten", "err", err)
+		default:
+			udp.Close()
+			tcp.Close()
+			return fmt.Errorf("rewrite system resolvers: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f.running, f.listen, f.udp, f.tcp, f.cancel = true, listen, udp, tcp, cancel
+	for _, up := range f.ups {
+		up.ex = newExchanger(up.u, dial)
+	}
+	f.wg.Add(2)
+	go f.serveUDP(ctx, udp)
+	go f.serveTCP(ctx, tcp)
+	f.logger.Info("dns forwarder started", "listen", listen, "upstreams", len(f.ups), "upstream", f.ups[0].u.String(), "rewritten", f.rewrote)
+	f.notifyLocked()
+	return nil
+}
+
+// Stop restores the system resolvers, then closes the listeners and waits
+// for in-flight queries. It is a no-op when not running. A restore failure
+// is returned after the listeners are closed; the saved configuration stays
+// on disk for a later Restore.
+func (f *Forwarder) Stop() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.running {
+		return nil
+	}
+	var restoreErr error
+	if f.rewrote {
+		if _, err := f.opts.Resolvers.Restore(); err != nil {
+			restoreErr = fmt.Errorf("restore system resolvers: %w", err)
+		} else {
+			f.current, f.original, f.rewrote = f.original, nil, false
+		}
+	}
+	f.cancel()
+	f.udp.Close()
+	f.tcp.Close()
+	f.wg.Wait()
+	for _, up := range f.ups {
+		up.ex.close()
+	}
+	f.running, f.listen = false, netip.AddrPort{}
+	f.logger.Info("dns forwarder stopped", "queries", f.queries.Load(), "failures", f.failures.Load())
+	f.notifyLocked()
+	return restoreErr
+}
+
+// ListenAddr returns the address Start binds for a TUN address.
+func (f *Forwarder) ListenAddr(tun netip.Addr) netip.AddrPort {
+	return netip.AddrPortFrom(tun, uint16(f.opts.Port))
+}
+
+// RewritesResolvers reports whether Start points the system resolvers at
+// the forwarder.
+func (f *Forwarder) RewritesResolvers() bool { return f.opts.Resolvers != nil }
+
+// Status returns the current status.
+func (f *Forwarder) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.statusLocked()
+}
+
+func (f *Forwarder) statusLocked() Status {
+	st := Status{
+		Running:           f.running,
+		Upstream:          order(f.ups, time.Now())[0].u.String(),
+		Resolvers:         addrStrings(f.current),
+		OriginalResolvers: addrStrings(f.original),
+		Rewritten:         f.rewrote,
+		Queries:           f.queries.Load(),
+		Failures:          f.failures.Load(),
+	}
+	if f.running {
+		st.Listen = f.listen.String()
+	}
+	return st
+}
+
+// Upstreams returns the health of each upstream in configured order.
+func (f *Forwarder) Upstreams() []UpstreamStatus {
+	now := time.Now()
+	out := make([]UpstreamStatus, 0, len(f.ups))
+	for _, up := range f.ups {
+		out = append(out, up.status(now))
+	}
+	return out
+}
+
+func (f *Forwarder) notify() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notifyLocked()
+}
+
+func (f *Forwarder) notifyLocked() {
+	if f.opts.OnChange != nil {
+		f.opts.OnChange(f.statusLocked())
+	}
+}
+
+func (f *Forwarder) serveUDP(ctx context.Context, conn *net.UDPConn) {
+	defer f.wg.Done()
+	slots := make(chan struct{}, maxInflight)
+	var inflight sync.WaitGroup
+	defer inflight.Wait()
+	for {
+		buf := make([]byte, maxMessage)
+		n, from, err := conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				f.logger.Warn("dns udp read failed", "err", err)
+			}
+			return
+		}
+		if n < headerLen {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			f.failures.Add(1) // overloaded: drop, the client retries
+			continue
+		}
+		inflight.Add(1)
+		go func(q []byte) {
+			defer func() { <-slots; inflight.Done() }()
+			if resp := f.answer(ctx, q); resp != nil {
+				_, _ = conn.WriteToUDPAddrPort(resp, from)
+			}
+		}(buf[:n])
+	}
+}
+
+func (f *Forwarder) serveTCP(ctx context.Context, ln net.Listener) {
+	defer f.wg.Done()
+	var conns sync.WaitGroup
+	defer conns.Wait()
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				f.logger.Warn("dns tcp accept failed", "err", err)
+			}
+			return
+		}
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			defer c.Close()
+			stop := context.AfterFunc(ctx, func() { c.Close() })
+			defer stop()
+			for {
+				_ = c.SetReadDeadline(time.Now().Add(tcpIdle))
+				q, err := readTCPMessage(c)
+				if err != nil {
+					return
+				}
+				resp := f.answer(ctx, q)
+				if resp == nil {
+					return
+				}
+				_ = c.SetWriteDeadline(time.Now().Add(f.opts.Timeout))
+				if writeTCPMessage(c, resp) != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// answer relays q to the upstreams in fallback order, returning SERVFAIL
+// when all fail and nil when the forwarder is shutting down.
+func (f *Forwarder) answer(ctx context.Context, q []byte) []byte {
+	f.queries.Add(1)
+	for _, up := range order(f.ups, time.Now()) {
+		began := time.Now()
+		qctx, cancel := context.WithTimeout(ctx, f.opts.Timeout)
+		resp, err := up.exchange(qctx, q)
+		cancel()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if up.record(err, time.Since(began), time.Now()) {
+			// The preferred upstream changed; report it without holding
+			// up the query (Stop holds f.mu while draining queries).
+			go f.notify()
+		}
+		if err != nil {
+			f.logger.Debug("dns query failed", "upstream", up.u.String(), "err", err)
+			continue
+		}
+		if f.opts.Observe != nil {
+			f.opts.Observe(resp)
+		}
+		return resp
+	}
+	f.failures.Add(1)
+	return servfail(q)
+}
+
+// servfail builds a SERVFAIL response echoing q's ID and question.
+func servfail(q []byte) []byte {
+	end := headerLen
+	qd := binary.BigEndian.Uint16(q[4:6])
+	if qd == 1 {
+		// Queries carry uncompressed names; skip labels, then type and class.
+		off := headerLen
+		for off < len(q) && q[off] != 0 && q[off]&0xc0 == 0 {
+			off += 1 + int(q[off])
+		}
+		if off < len(q) && q[off] == 0 && off+5 <= len(q) {
+			end = off + 5
+		} else {
+			qd = 0
+		}
+	} else {
+		qd = 0
+	}
+	resp := append([]byte(nil), q[:end]...)
+	resp[2] = 0x80 | q[2]&0x79 // QR, keep opcode and RD
+	resp[3] = 0x80 | 2         // RA, RCODE=SERVFAIL
+	binary.BigEndian.PutUint16(resp[4:], qd)
+	clear(resp[6:12])
+	return resp
+}
+
+func addrStrings(in []netip.Addr) []string {
+	out := make([]string, 0, len(in))
+	for _, a := range in {
+		out = append(out, a.String())
+	}
+	return out
+}
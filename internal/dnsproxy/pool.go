@@ -0,0 +1,135 @@
+package dnsproxy
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Fallback tuning: an upstream that fails failThreshold queries in a row
+// is skipped for cooldown, unless every upstream is cooling down.
+const (
+	failThreshold = 3
+	cooldown      = 30 * time.Second
+	latencyWeight = 0.2 // EWMA weight of the newest sample
+)
+
+// UpstreamStatus is the health of one upstream. Attempts count every query
+// sent to it, including fallbacks from an earlier upstream.
+type UpstreamStatus struct {
+	Upstream            string
+	Scheme              string
+	Pinned              bool // SPKI pins are enforced
+	Healthy             bool // not cooling down
+	Attempts            uint64
+	Failures            uint64
+	ConsecutiveFailures int
+	LatencyMS           float64 // moving average of successful exchanges
+	LastError           string
+	LastErrorAt         time.Time
+	LastSuccessAt       time.Time
+	CooldownUntil       time.Time
+}
+
+// upstream pairs an Upstream with its exchanger (while running) and health.
+type upstream struct {
+	u  Upstream
+	ex exchanger
+
+	mu          sync.Mutex
+	attempts    uint64
+	failures    uint64
+	consecutive int
+	latency     float64
+	lastErr     string
+	lastErrAt   time.Time
+	lastOKAt    time.Time
+	downUntil   time.Time
+}
+
+// exchange sends q and checks that the answer matches its ID.
+func (up *upstream) exchange(ctx context.Context, q []byte) ([]byte, error) {
+	resp, err := up.ex.exchange(ctx, q)
+	if err == nil && (len(resp) < headerLen || resp[0] != q[0] || resp[1] != q[1]) {
+		err = errors.New("upstream answer does not match query")
+	}
+	return resp, err
+}
+
+// record accounts one attempt that took d and reports whether the
+// upstream entered or left its cooldown.
+func (up *upstream) record(err error, d time.Duration, now time.Time) bool {
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	up.attempts++
+	wasDown := now.Before(up.downUntil)
+	if err != nil {
+		up.failures++
+		up.consecutive++
+		up.lastErr, up.lastErrAt = err.Error(), now
+		if up.consecutive >= failThreshold {
+			up.downUntil = now.Add(cooldown)
+		}
+		return !wasDown && now.Before(up.downUntil)
+	}
+	ms := float64(d) / float64(time.Millisecond)
+	if up.latency == 0 {
+		up.latency = ms
+	} else {
+		up.latency += latencyWeight * (ms - up.latency)
+	}
+	up.consecutive, up.lastOKAt, up.downUntil = 0, now, time.Time{}
+	return wasDown
+}
+
+// until returns the end of the cooldown (zero when healthy).
+func (up *upstream) until(now time.Time) time.Time {
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	if now.Before(up.downUntil) {
+		return up.downUntil
+	}
+	return time.Time{}
+}
+
+func (up *upstream) status(now time.Time) UpstreamStatus {
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	st := UpstreamStatus{
+		Upstream:            up.u.String(),
+		Scheme:              up.u.Scheme,
+		Pinned:              len(up.u.Pins) > 0,
+		Healthy:             !now.Before(up.downUntil),
+		Attempts:            up.attempts,
+		Failures:            up.failures,
+		ConsecutiveFailures: up.consecutive,
+		LatencyMS:           up.latency,
+		LastError:           up.lastErr,
+		LastErrorAt:         up.lastErrAt,
+		LastSuccessAt:       up.lastOKAt,
+	}
+	if !st.Healthy {
+		st.CooldownUntil = up.downUntil
+	}
+	return st
+}
+
+// order returns the upstreams to try: healthy ones in configured order,
+// then those cooling down, soonest back first.
+func order(ups []*upstream, now time.Time) []*upstream {
+	out := make([]*upstream, 0, len(ups))
+	var down []*upstream
+	for _, up := range ups {
+		if up.until(now).IsZero() {
+			out = append(out, up)
+		} else {
+			down = append(down, up)
+		}
+	}
+	slices.SortStableFunc(down, func(a, b *upstream) int {
+		return a.until(now).Compare(b.until(now))
+	})
+	return append(out, down...)
+}
@@ -0,0 +1,63 @@
+package dnsproxy
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// ErrUnsupported is returned by Resolvers on platforms without an
+// implementation.
+var ErrUnsupported = errors.New("system resolver rewrite not supported on this platform")
+
+// Resolvers reads and rewrites the system DNS configuration.
+type Resolvers interface {
+	// Current returns the configured nameservers.
+	Current() ([]netip.Addr, error)
+	// Apply saves the current configuration (unless a saved copy already
+	// exists, e.g. after a crash) and sets servers as the only nameservers.
+	Apply(servers []netip.Addr) error
+	// Restore reinstates the saved configuration and reports whether there
+	// was one. It is a no-op when nothing is saved.
+	Restore() (bool, error)
+}
+
+// resolvConf is a parsed resolv.conf: nameservers plus the lines a rewrite
+// keeps (search, domain, options).
+type resolvConf struct {
+	nameservers []netip.Addr
+	keep        []string
+}
+
+func readResolvConf(path string) (resolvConf, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return resolvConf{}, err
+	}
+	defer f.Close()
+	return parseResolvConf(f)
+}
+
+func parseResolvConf(r io.Reader) (resolvConf, error) {
+	var rc resolvConf
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			// Zone suffixes ("fe80::1%en0") are accepted by netip.
+			if a, err := netip.ParseAddr(fields[1]); err == nil {
+				rc.nameservers = append(rc.nameservers, a)
+			}
+		case "search", "domain", "options":
+			rc.keep = append(rc.keep, strings.Join(fields, " "))
+		}
+	}
+	return rc, sc.Err()
+}
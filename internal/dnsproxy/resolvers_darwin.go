@@ -0,0 +1,131 @@
+//go:build darwin
+
+package dnsproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// OSResolvers returns the platform's Resolvers. On macOS it sets DNS
+// servers per network service with networksetup and saves the previous
+// settings to backupDir/dns-backup.json.
+func OSResolvers(backupDir string) Resolvers {
+	return networkSetup{backup: filepath.Join(backupDir, "dns-backup.json")}
+}
+
+type networkSetup struct {
+	backup string
+}
+
+// Current reads /etc/resolv.conf, which macOS keeps in sync with the
+// primary service's resolvers.
+func (networkSetup) Current() ([]netip.Addr, error) {
+	rc, err := readResolvConf("/etc/resolv.conf")
+	return rc.nameservers, err
+}
+
+func (n networkSetup) Apply(servers []netip.Addr) error {
+	services, err := networkServices()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(n.backup); errors.Is(err, fs.ErrNotExist) {
+		saved := make(map[string][]string, len(services))
+		for _, svc := range services {
+			if saved[svc], err = serviceDNS(svc); err != nil {
+				return err
+			}
+		}
+		b, err := json.Marshal(saved)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(n.backup, b, 0o600); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	args := make([]string, 0, len(servers))
+	for _, a := range servers {
+		args = append(args, a.String())
+	}
+	for _, svc := range services {
+		if err := setServiceDNS(svc, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n networkSetup) Restore() (bool, error) {
+	b, err := os.ReadFile(n.backup)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var saved map[string][]string
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return false, fmt.Errorf("decode %s: %w", n.backup, err)
+	}
+	var errs []error
+	for svc, servers := range saved {
+		errs = append(errs, setServiceDNS(svc, servers))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return false, err
+	}
+	return true, os.Remove(n.backup)
+}
+
+// networkServices lists enabled services; disabled ones are prefixed "*".
+func networkServices() ([]string, error) {
+	out, err := exec.Command("networksetup", "-listallnetworkservices").Output()
+	if err != nil {
+		return nil, fmt.Errorf("networksetup -listallnetworkservices: %w", err)
+	}
+	var services []string
+	for i, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if i == 0 || line == "" || strings.HasPrefix(line, "*") {
+			continue // header: "An asterisk (*) denotes..."
+		}
+		services = append(services, line)
+	}
+	return services, nil
+}
+
+// serviceDNS returns a service's manual DNS servers; empty means DHCP.
+func serviceDNS(svc string) ([]string, error) {
+	out, err := exec.Command("networksetup", "-getdnsservers", svc).Output()
+	if err != nil {
+		return nil, fmt.Errorf("networksetup -getdnsservers %q: %w", svc, err)
+	}
+	var servers []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if _, err := netip.ParseAddr(strings.TrimSpace(line)); err == nil {
+			servers = append(servers, strings.TrimSpace(line))
+		}
+	}
+	return servers, nil
+}
+
+func setServiceDNS(svc string, servers []string) error {
+	if len(servers) == 0 {
+		servers = []string{"Empty"} // back to DHCP-provided servers
+	}
+	args := append([]string{"-setdnsservers", svc}, servers...)
+	if out, err := exec.Command("networksetup", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("networksetup -setdnsservers %q: %w: %s", svc, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
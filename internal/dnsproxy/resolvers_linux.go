@@ -0,0 +1,68 @@
+//go:build linux
+
+package dnsproxy
+
+import (
+	"errors"
+	"io/fs"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// ResolvConfPath is the file rewritten by OSResolvers on Linux.
+var ResolvConfPath = "/etc/resolv.conf"
+
+// OSResolvers returns the platform's Resolvers. On Linux it rewrites
+// ResolvConfPath and keeps the original beside it; backupDir is unused.
+func OSResolvers(backupDir string) Resolvers {
+	return resolvConfFile{path: ResolvConfPath, backup: ResolvConfPath + ".spl-backup"}
+}
+
+type resolvConfFile struct {
+	path, backup string
+}
+
+func (r resolvConfFile) Current() ([]netip.Addr, error) {
+	rc, err := readResolvConf(r.path)
+	return rc.nameservers, err
+}
+
+func (r resolvConfFile) Apply(servers []netip.Addr) error {
+	if _, err := os.Lstat(r.backup); errors.Is(err, fs.ErrNotExist) {
+		// Renaming moves a symlink itself, so a managed resolv.conf
+		// (systemd-resolved, NetworkManager) comes back intact.
+		if err := os.Rename(r.path, r.backup); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	orig, err := readResolvConf(r.backup)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("# Generated by simple-packet-logger; the original is " + r.backup + "\n")
+	for _, a := range servers {
+		b.WriteString("nameserver " + a.String() + "\n")
+	}
+	for _, l := range orig.keep {
+		b.WriteString(l + "\n")
+	}
+	tmp := r.path + ".spl-tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+func (r resolvConfFile) Restore() (bool, error) {
+	if _, err := os.Lstat(r.backup); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err := os.Rename(r.backup, r.path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package dnsproxy
+
+import "net/netip"
+
+// OSResolvers returns the platform's Resolvers, which on this platform
+// cannot rewrite anything.
+func OSResolvers(backupDir string) Resolvers { return unsupportedResolvers{} }
+
+type unsupportedResolvers struct{}
+
+func (unsupportedResolvers) Current() ([]netip.Addr, error) { return nil, ErrUnsupported }
+func (unsupportedResolvers) Apply([]netip.Addr) error       { return ErrUnsupported }
+func (unsupportedResolvers) Restore() (bool, error)         { return false, nil }
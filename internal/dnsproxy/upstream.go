@@ -0,0 +1,320 @@
+package dnsproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultUpstream is used when no upstream is configured.
+const DefaultUpstream = "tcp://1.1.1.1:53"
+
+// maxMessage bounds DNS messages read from upstreams and TCP clients.
+const maxMessage = 65535
+
+// Upstream schemes.
+const (
+	SchemeTCP   = "tcp"
+	SchemeTLS   = "tls"
+	SchemeHTTPS = "https"
+)
+
+// DialFunc opens a stream to addr ("host:port"), normally through the
+// SOCKS proxy (see probe.DialSOCKS).
+type DialFunc func(ctx context.Context, addr string) (net.Conn, error)
+
+// MaxUpstreams caps the fallback list.
+const MaxUpstreams = 8
+
+// Upstream is a parsed upstream resolver.
+type Upstream struct {
+	Scheme     string   // SchemeTCP, SchemeTLS, or SchemeHTTPS
+	Addr       string   // "host:port" dialed through the tunnel
+	ServerName string   // TLS server name (tls, https)
+	URL        string   // request URL without sni/pin parameters (https)
+	Pins       []string // base64 SHA-256 digests of accepted SPKIs (tls, https)
+}
+
+// String renders the upstream in the form ParseUpstream accepts.
+func (u Upstream) String() string {
+	var base string
+	host, _, _ := net.SplitHostPort(u.Addr)
+	q := url.Values{}
+	switch u.Scheme {
+	case SchemeHTTPS:
+		base = u.URL
+		if p, err := url.Parse(u.URL); err == nil {
+			host = p.Hostname()
+		}
+	default:
+		base = u.Scheme + "://" + u.Addr
+	}
+	if u.Scheme != SchemeTCP && u.ServerName != host {
+		q.Set("sni", u.ServerName)
+	}
+	for _, pin := range u.Pins {
+		q.Add("pin", pin)
+	}
+	if len(q) == 0 {
+		return base
+	}
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + q.Encode()
+}
+
+// ParseUpstreams parses a fallback list in order; an empty list yields
+// DefaultUpstream. Duplicates are rejected.
+func ParseUpstreams(in []string) ([]Upstream, error) {
+	if len(in) == 0 {
+		in = []string{DefaultUpstream}
+	}
+	if len(in) > MaxUpstreams {
+		return nil, fmt.Errorf("too many upstreams (%d > %d)", len(in), MaxUpstreams)
+	}
+	out := make([]Upstream, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	for _, s := range in {
+		u, err := ParseUpstream(s)
+		if err != nil {
+			return nil, err
+		}
+		if seen[u.String()] {
+			return nil, fmt.Errorf("duplicate upstream %q", s)
+		}
+		seen[u.String()] = true
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// ParseUpstream parses "tcp://host[:53]", "tls://host[:853]", or
+// "https://host[:443]/path". tls and https accept "sni=name" to override
+// the TLS server name and repeated "pin=<base64 sha256>" parameters that
+// restrict the server to certificates whose SubjectPublicKeyInfo digest is
+// listed (any certificate in the verified chain may match).
+func ParseUpstream(s string) (Upstream, error) {
+	if s == "" {
+		s = DefaultUpstream
+	}
+	p, err := url.Parse(s)
+	if err != nil {
+		return Upstream{}, fmt.Errorf("invalid upstream %q: %w", s, err)
+	}
+	if p.Hostname() == "" || p.User != nil || p.Fragment != "" {
+		return Upstream{}, fmt.Errorf("invalid upstream %q: need scheme://host[:port]", s)
+	}
+	port := p.Port()
+	if port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return Upstream{}, fmt.Errorf("invalid upstream %q: bad port", s)
+		}
+	}
+	u := Upstream{Scheme: p.Scheme, ServerName: p.Hostname()}
+	withPort := func(def string) string {
+		if port == "" {
+			port = def
+		}
+		return net.JoinHostPort(p.Hostname(), port)
+	}
+	switch p.Scheme {
+	case SchemeTCP:
+		if p.Path != "" || p.RawQuery != "" {
+			return Upstream{}, fmt.Errorf("invalid upstream %q: tcp takes no path or query", s)
+		}
+		u.Addr, u.ServerName = withPort("53"), ""
+	case SchemeTLS:
+		if p.Path != "" {
+			return Upstream{}, fmt.Errorf("invalid upstream %q: tls takes no path", s)
+		}
+		q := p.Query()
+		if err := u.tlsParams(q); err != nil {
+			return Upstream{}, fmt.Errorf("invalid upstream %q: %w", s, err)
+		}
+		if len(q) > 0 {
+			return Upstream{}, fmt.Errorf("invalid upstream %q: tls takes only sni= and pin=", s)
+		}
+		u.Addr = withPort("853")
+	case SchemeHTTPS:
+		if p.Path == "" || p.Path == "/" {
+			return Upstream{}, fmt.Errorf("invalid upstream %q: https needs a path such as /dns-query", s)
+		}
+		q := p.Query()
+		if err := u.tlsParams(q); err != nil {
+			return Upstream{}, fmt.Errorf("invalid upstream %q: %w", s, err)
+		}
+		p.RawQuery = q.Encode() // remaining parameters belong to the server
+		u.Addr, u.URL = withPort("443"), p.String()
+	default:
+		return Upstream{}, fmt.Errorf("invalid upstream %q: scheme must be tcp, tls, or https", s)
+	}
+	return u, nil
+}
+
+// tlsParams consumes the sni and pin parameters from q.
+func (u *Upstream) tlsParams(q url.Values) error {
+	if q.Has("sni") {
+		if q.Get("sni") == "" || len(q["sni"]) > 1 {
+			return errors.New("sni takes one server name")
+		}
+		u.ServerName = q.Get("sni")
+	}
+	for _, raw := range q["pin"] {
+		// '+' in standard base64 arrives as a space unless percent-encoded.
+		raw = strings.ReplaceAll(raw, " ", "+")
+		var sum []byte
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if b, err := enc.DecodeString(raw); err == nil {
+				sum = b
+				break
+			}
+		}
+		if len(sum) != sha256.Size {
+			return fmt.Errorf("pin %q is not a base64 SHA-256 digest", raw)
+		}
+		if pin := base64.StdEncoding.EncodeToString(sum); !slices.Contains(u.Pins, pin) {
+			u.Pins = append(u.Pins, pin)
+		}
+	}
+	q.Del("sni")
+	q.Del("pin")
+	return nil
+}
+
+// tlsConfig verifies the server name as usual and, with pins, also
+// requires a pinned key in the verified chain.
+func (u Upstream) tlsConfig() *tls.Config {
+	cfg := &tls.Config{ServerName: u.ServerName, MinVersion: tls.VersionTLS12}
+	if len(u.Pins) == 0 {
+		return cfg
+	}
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if slices.Contains(u.Pins, base64.StdEncoding.EncodeToString(sum[:])) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("no pinned public key in the certificate chain of %s", u.ServerName)
+	}
+	return cfg
+}
+
+// exchanger sends one query to an upstream and returns the answer.
+type exchanger interface {
+	exchange(ctx context.Context, msg []byte) ([]byte, error)
+	close()
+}
+
+func newExchanger(u Upstream, dial DialFunc) exchanger {
+	if u.Scheme == SchemeHTTPS {
+		tr := &http.Transport{
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return dial(ctx, addr)
+			},
+			TLSClientConfig:     u.tlsConfig(),
+			ForceAttemptHTTP2:   true,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     30 * time.Second,
+		}
+		return &dohExchanger{url: u.URL, client: &http.Client{Transport: tr}}
+	}
+	return &streamExchanger{u: u, dial: dial}
+}
+
+// streamExchanger opens one connection per query; DNS traffic through the
+// forwarder is light and this keeps failure handling trivial.
+type streamExchanger struct {
+	u    Upstream
+	dial DialFunc
+}
+
+func (s *streamExchanger) exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	conn, err := s.dial(ctx, s.u.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", s.u.Addr, err)
+	}
+	defer conn.Close()
+	if s.u.Scheme == SchemeTLS {
+		tc := tls.Client(conn, s.u.tlsConfig())
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("tls handshake with %s: %w", s.u.Addr, err)
+		}
+		conn = tc
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	if err := writeTCPMessage(conn, msg); err != nil {
+		return nil, err
+	}
+	return readTCPMessage(conn)
+}
+
+func (s *streamExchanger) close() {}
+
+type dohExchanger struct {
+	url    string
+	client *http.Client
+}
+
+func (d *dohExchanger) exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("doh %s: status %d", d.url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxMessage))
+}
+
+func (d *dohExchanger) close() { d.client.CloseIdleConnections() }
+
+// writeTCPMessage writes msg with its two-byte length prefix.
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readTCPMessage reads one length-prefixed message.
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(l[:]))
+	if n < headerLen {
+		return nil, errors.New("short dns message")
+	}
+	msg := make([]byte, n)
+	_, err := io.ReadFull(r, msg)
+	return msg, err
+}
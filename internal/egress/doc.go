@@ -0,0 +1,25 @@
+// Package egress looks up the public address traffic leaves the host
+// from, so users can confirm it really exits through the proxy.
+//
+// # Paths
+//
+// A Resolver asks a provider over two paths: tunnel follows the routing
+// table, so it goes through the TUN while the agent holds the default
+// route; direct is bound to the uplink (see netinfo.BoundDialer), outside
+// the tunnel. With the tunnel up the two should differ; the same address
+// on both means traffic bypasses the proxy.
+//
+// # Providers
+//
+// The provider is any URL answering the caller's IP as plain text or as
+// JSON: ipify (the default, IP only), ipinfo.io, ip-api.com, and
+// ifconfig.co shapes are understood, and their country, region, city,
+// ASN, and organization are kept when present. Proxies from the
+// environment are ignored.
+//
+// # Caching
+//
+// A successful lookup is reused for the TTL (default 60s) so dashboards
+// polling GET /v1/egress do not hammer the provider; failures are never
+// cached, and a direct lookup is redone when the uplink changes.
+package egress
@@ -0,0 +1,232 @@
+package egress
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// DefaultProvider answers the caller's public IP as JSON, without geo
+// data.
+const DefaultProvider = "https://api.ipify.org?format=json"
+
+// DefaultTTL is how long a lookup is reused.
+const DefaultTTL = 60 * time.Second
+
+// lookupTimeout bounds one lookup.
+const lookupTimeout = 5 * time.Second
+
+// maxBody bounds the provider's answer.
+const maxBody = 64 << 10
+
+// Paths a lookup can take.
+const (
+	PathTunnel = "tunnel" // by the routing table: through the TUN while it holds the default route
+	PathDirect = "direct" // bound to the uplink, outside the tunnel
+)
+
+// Options configures a Resolver.
+type Options struct {
+	// Provider is the lookup URL (default DefaultProvider). It may answer
+	// the IP as plain text, or JSON in the shape of ipify, ipinfo.io,
+	// ip-api.com, or ifconfig.co.
+	Provider string
+	// TTL is how long a successful lookup is reused (default DefaultTTL).
+	TTL time.Duration
+	// Interface, when set, names the uplink the direct path binds to; ""
+	// uses the routing table.
+	Interface func() string
+	// Logger receives resolver records. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Identity is the public address a path exits from, as the provider saw
+// it. Geo and ASN fields are empty when the provider does not report them.
+type Identity struct {
+	Path      string
+	IP        string
+	Country   string
+	Region    string
+	City      string
+	ASN       string // "AS15169"
+	Org       string
+	Interface string // uplink the direct path was bound to
+	Error     string
+	Latency   time.Duration
+	Checked   time.Time
+}
+
+// Resolver looks up egress identities and caches them.
+type Resolver struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	cache map[string]Identity // by path
+}
+
+// New constructs a resolver, validating the provider URL.
+func New(opts Options) (*Resolver, error) {
+	if opts.Provider == "" {
+		opts.Provider = DefaultProvider
+	}
+	u, err := url.Parse(opts.Provider)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("egress provider %q: want an http or https URL", opts.Provider)
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultTTL
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Resolver{opts: opts, logger: logger, cache: make(map[string]Identity)}, nil
+}
+
+// Provider returns the lookup URL.
+func (r *Resolver) Provider() string { return r.opts.Provider }
+
+// Lookup returns path's identity, reusing a successful lookup younger
+// than the TTL unless refresh is set; cached reports whether it did.
+// Failures are never cached.
+func (r *Resolver) Lookup(ctx context.Context, path string, refresh bool) (id Identity, cached bool) {
+	var iface string
+	if path == PathDirect && r.opts.Interface != nil {
+		iface = r.opts.Interface()
+	}
+	r.mu.Lock()
+	prev, ok := r.cache[path]
+	r.mu.Unlock()
+	if ok && !refresh && prev.Interface == iface && time.Since(prev.Checked) < r.opts.TTL {
+		return prev, true
+	}
+
+	id = r.lookup(ctx, path, iface)
+	if id.Error != "" {
+		r.logger.Warn("egress lookup failed", "path", path, "interface", iface, "err", id.Error)
+		return id, false
+	}
+	r.mu.Lock()
+	r.cache[path] = id
+	r.mu.Unlock()
+	if ok && prev.IP != id.IP {
+		r.logger.Info("egress address changed", "path", path, "from", prev.IP, "to", id.IP)
+	}
+	return id, false
+}
+
+// lookup asks the provider once over path.
+func (r *Resolver) lookup(ctx context.Context, path, iface string) Identity {
+	id := Identity{Path: path, Interface: iface, Checked: time.Now()}
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.opts.Provider, nil)
+	if err != nil {
+		id.Error = err.Error()
+		return id
+	}
+	req.Header.Set("Accept", "application/json")
+	client := newClient(iface)
+	defer client.CloseIdleConnections()
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		id.Latency = time.Since(start)
+		id.Error = err.Error()
+		return id
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	id.Latency = time.Since(start)
+	switch {
+	case err != nil:
+		id.Error = "read answer: " + err.Error()
+	case resp.StatusCode != http.StatusOK:
+		id.Error = fmt.Sprintf("provider answered %s", resp.Status)
+	default:
+		if err := parse(body, &id); err != nil {
+			id.Error = err.Error()
+		}
+	}
+	return id
+}
+
+// newClient returns a client that ignores proxies from the environment
+// and, for the direct path, binds to iface.
+func newClient(iface string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:             nil,
+			DialContext:       netinfo.BoundDialer(iface, lookupTimeout).DialContext,
+			DisableKeepAlives: true,
+		},
+	}
+}
+
+// parse fills id from a provider answer: a bare IP, or a JSON object.
+func parse(body []byte, id *Identity) error {
+	text := strings.TrimSpace(string(body))
+	if addr, err := netip.ParseAddr(text); err == nil {
+		id.IP = addr.Unmap().String()
+		return nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(body, &m); err != nil {
+		return errors.New("provider answer is neither an IP nor a JSON object")
+	}
+	field := func(keys ...string) string {
+		for _, k := range keys {
+			switch v := m[k].(type) {
+			case string:
+				if v != "" {
+					return v
+				}
+			case float64:
+				return strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		return ""
+	}
+	addr, err := netip.ParseAddr(field("ip", "query", "ip_addr"))
+	if err != nil {
+		return errors.New("provider answer carries no IP")
+	}
+	id.IP = addr.Unmap().String()
+	id.Country = field("country_iso", "country_code", "countryCode", "country")
+	id.Region = field("region", "regionName", "region_name")
+	id.City = field("city")
+	id.Org = field("asn_org", "org", "isp")
+	id.ASN = asn(field("asn", "as"))
+	// ipinfo.io reports "AS15169 Google LLC" in org.
+	if num, org, ok := strings.Cut(id.Org, " "); ok && asn(num) != "" && strings.HasPrefix(num, "AS") {
+		id.ASN = cmp.Or(id.ASN, asn(num))
+		id.Org = org
+	}
+	return nil
+}
+
+// asn normalizes "15169", "AS15169", or "AS15169 Google LLC" to
+// "AS15169"; anything else is "".
+func asn(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), " ")
+	s = strings.TrimPrefix(strings.ToUpper(s), "AS")
+	if _, err := strconv.ParseUint(s, 10, 32); err != nil {
+		return ""
+	}
+	return "AS" + s
+}
@@ -0,0 +1,32 @@
+// Package export streams events and flow records to external sinks.
+//
+// # Sinks
+//
+// Sink is the exporter plugin interface: Write(Record), Flush, Close.
+// Sinks are constructed by name from a string option map through a registry,
+// so site-specific exporters (Kafka, S3, ...) live in their own packages and
+// call Register from init; neither core nor the agent needs to change.
+//
+// Built-in sinks:
+//
+//   - jsonl:   one JSON object per line appended to options["path"].
+//   - syslog:  RFC 5424 messages to options["addr"] over options["network"]
+//     (udp by default; tcp, unix, unixgram also work), tagged
+//     options["tag"] (default "simple-packet-logger").
+//   - netflow: NetFlow v5 datagrams to options["addr"] (UDP). Only IPv4
+//     flow records are exported; events and IPv6 flows are skipped.
+//
+// # Records
+//
+// A Record carries either an Event (from core's event log) or a Flow
+// (produced by the packet logger). Sinks ignore kinds they cannot express.
+// Flows carry the verdict (tunnel, direct, block), rule, and profile that
+// decided their path, from rules.Engine.Attribute; jsonl and syslog export
+// them, NetFlow v5 has no field for them.
+//
+// # Runner
+//
+// Run subscribes to core.State events, writes each to every sink, flushes on
+// an interval, and flushes and closes sinks when its context ends. A failing
+// sink is logged and does not block the others.
+package export
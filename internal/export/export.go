@@ -0,0 +1,164 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// Kind distinguishes record payloads.
+type Kind string
+
+const (
+	KindEvent Kind = "event"
+	KindFlow  Kind = "flow"
+)
+
+// Flow is one unidirectional traffic aggregate.
+type Flow struct {
+	Proto    uint8 // IP protocol number (6 TCP, 17 UDP)
+	Src      netip.AddrPort
+	Dst      netip.AddrPort
+	Packets  uint64
+	Bytes    uint64
+	TCPFlags uint8 // OR of flags seen
+	Start    time.Time
+	End      time.Time
+
+	// Verdict, Rule, and Profile record what decided the flow's path
+	// (rules.Decision); empty when the producer has no rules engine.
+	Verdict string // tunnel, direct, or block
+	Rule    string // domain pattern, app rule, or "default"
+	Profile string
+}
+
+// Record is one exported item; exactly one of Event or Flow is set.
+type Record struct {
+	Kind  Kind
+	Event *core.Event
+	Flow  *Flow
+}
+
+// Sink receives records. Implementations may buffer in Write; Flush must
+// push buffered records out, and Close must flush before releasing
+// resources. Sinks are used from a single goroutine.
+type Sink interface {
+	Write(Record) error
+	Flush() error
+	Close() error
+}
+
+// Factory builds a sink from string options.
+type Factory func(opts map[string]string) (Sink, error)
+
+var (
+	regMu     sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a sink type available to Open. It panics on duplicates,
+// as registration happens in init.
+func Register(name string, f Factory) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	if _, dup := factories[name]; dup {
+		panic("export: duplicate sink " + name)
+	}
+	factories[name] = f
+}
+
+// Types returns the registered sink type names, sorted.
+func Types() []string {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for n := range factories {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open constructs a sink of the named type.
+func Open(typ string, opts map[string]string) (Sink, error) {
+	regMu.RLock()
+	f, ok := factories[typ]
+	regMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown export sink %q (available: %s)", typ, strings.Join(Types(), ", "))
+	}
+	return f(opts)
+}
+
+// Named pairs a sink with a label for logging.
+type Named struct {
+	Name string
+	Sink Sink
+}
+
+// DefaultFlushInterval bounds how long records sit in sink buffers.
+const DefaultFlushInterval = 5 * time.Second
+
+// Run exports every new event in state to sinks until ctx is done, then
+// flushes and closes them. It blocks; run it in a goroutine.
+func Run(ctx context.Context, state *core.State, sinks []Named, flushEvery time.Duration, logger *slog.Logger) {
+	if flushEvery <= 0 {
+		flushEvery = DefaultFlushInterval
+	}
+	events := state.Subscribe(ctx)
+	tick := time.NewTicker(flushEvery)
+	defer tick.Stop()
+
+	var dropped uint64
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				closeAll(sinks, logger)
+				return
+			}
+			if ev.Dropped > dropped {
+				logger.Warn("export fell behind; events dropped", "dropped", ev.Dropped-dropped)
+				dropped = ev.Dropped
+			}
+			e := ev.Event
+			WriteAll(sinks, Record{Kind: KindEvent, Event: &e}, logger)
+		case <-tick.C:
+			for _, s := range sinks {
+				if err := s.Sink.Flush(); err != nil {
+					logger.Error("export flush failed", "sink", s.Name, "err", err)
+				}
+			}
+		}
+	}
+}
+
+// WriteAll writes rec to every sink, logging failures. Producers other than
+// Run (e.g., the packet logger's flow table) use it to share sinks.
+func WriteAll(sinks []Named, rec Record, logger *slog.Logger) {
+	for _, s := range sinks {
+		if err := s.Sink.Write(rec); err != nil {
+			logger.Error("export write failed", "sink", s.Name, "err", err)
+		}
+	}
+}
+
+func closeAll(sinks []Named, logger *slog.Logger) {
+	var errs []error
+	for _, s := range sinks {
+		if err := s.Sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		logger.Error("export close failed", "err", err)
+	}
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func init() {
+	Register("jsonl", func(opts map[string]string) (Sink, error) { return NewJSONL(opts["path"]) })
+}
+
+// jsonlRecord is the line format; field names follow the API's EventView.
+type jsonlRecord struct {
+	Kind    string            `json:"kind"`
+	ID      uint64            `json:"id,omitempty"`
+	At      time.Time         `json:"at"`
+	Type    string            `json:"type,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Data    map[string]string `json:"data,omitempty"`
+
+	Proto    uint8  `json:"proto,omitempty"`
+	Src      string `json:"src,omitempty"`
+	Dst      string `json:"dst,omitempty"`
+	Packets  uint64 `json:"packets,omitempty"`
+	Bytes    uint64 `json:"bytes,omitempty"`
+	TCPFlags uint8  `json:"tcp_flags,omitempty"`
+	End      string `json:"end,omitempty"`
+	Verdict  string `json:"verdict,omitempty"`
+	Rule     string `json:"rule,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+}
+
+// JSONL appends records as JSON lines to a file.
+type JSONL struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// NewJSONL opens (appending, creating with 0600) the file at path.
+func NewJSONL(path string) (*JSONL, error) {
+	if path == "" {
+		return nil, errors.New("jsonl sink requires a path option")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONL{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (j *JSONL) Write(rec Record) error {
+	var out jsonlRecord
+	switch {
+	case rec.Event != nil:
+		e := rec.Event
+		out = jsonlRecord{Kind: string(KindEvent), ID: e.ID, At: e.At.UTC(), Type: string(e.Type), Message: e.Message, Data: e.Data}
+	case rec.Flow != nil:
+		f := rec.Flow
+		out = jsonlRecord{Kind: string(KindFlow), At: f.Start.UTC(), Proto: f.Proto, Src: f.Src.String(), Dst: f.Dst.String(),
+			Packets: f.Packets, Bytes: f.Bytes, TCPFlags: f.TCPFlags, End: f.End.UTC().Format(time.RFC3339Nano),
+			Verdict: f.Verdict, Rule: f.Rule, Profile: f.Profile}
+	default:
+		return nil
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	if _, err := j.w.Write(b); err != nil {
+		return err
+	}
+	return j.w.WriteByte('\n')
+}
+
+func (j *JSONL) Flush() error { return j.w.Flush() }
+
+func (j *JSONL) Close() error {
+	return errors.Join(j.w.Flush(), j.f.Close())
+}
@@ -0,0 +1,105 @@
+package export
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("syslog", func(opts map[string]string) (Sink, error) {
+		return NewSyslog(opts["network"], opts["addr"], opts["tag"])
+	})
+}
+
+// Syslog facility local0; severities follow RFC 5424.
+const (
+	syslogFacility = 16
+	sevWarning     = 4
+	sevInfo        = 6
+)
+
+// Syslog sends each record as one RFC 5424 message. It does not buffer, so
+// Flush is a no-op.
+type Syslog struct {
+	conn     net.Conn
+	network  string
+	hostname string
+	tag      string
+}
+
+// NewSyslog dials addr over network (default "udp"). tag defaults to
+// "simple-packet-logger".
+func NewSyslog(network, addr, tag string) (*Syslog, error) {
+	if network == "" {
+		network = "udp"
+	}
+	if addr == "" {
+		return nil, fmt.Errorf("syslog sink requires an addr option")
+	}
+	if tag == "" {
+		tag = "simple-packet-logger"
+	}
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "-"
+	}
+	return &Syslog{conn: conn, network: network, hostname: host, tag: tag}, nil
+}
+
+func (s *Syslog) Write(rec Record) error {
+	var (
+		sev = sevInfo
+		at  time.Time
+		id  string
+		msg string
+	)
+	switch {
+	case rec.Event != nil:
+		e := rec.Event
+		at, id, msg = e.At, string(e.Type), e.Message
+		if e.Type == "warning" {
+			sev = sevWarning
+		}
+		if len(e.Data) > 0 {
+			keys := make([]string, 0, len(e.Data))
+			for k := range e.Data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				msg += " " + k + "=" + e.Data[k]
+			}
+		}
+	case rec.Flow != nil:
+		f := rec.Flow
+		at, id = f.End, "flow"
+		msg = fmt.Sprintf("proto=%d src=%s dst=%s packets=%d bytes=%d", f.Proto, f.Src, f.Dst, f.Packets, f.Bytes)
+		if f.Verdict != "" {
+			msg += fmt.Sprintf(" verdict=%s rule=%s profile=%s", f.Verdict, f.Rule, f.Profile)
+		}
+	default:
+		return nil
+	}
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+	line := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		syslogFacility*8+sev, at.UTC().Format(time.RFC3339Nano), s.hostname, s.tag, os.Getpid(), id,
+		strings.ReplaceAll(msg, "\n", " "))
+	if s.network == "tcp" {
+		// RFC 6587 octet counting for stream transports.
+		line = fmt.Sprintf("%d %s", len(line), line)
+	}
+	_, err := s.conn.Write([]byte(line))
+	return err
+}
+
+func (s *Syslog) Flush() error { return nil }
+
+func (s *Syslog) Close() error { return s.conn.Close() }
@@ -0,0 +1,4 @@
+package firewall
+
+// OSBackend returns pf.
+func OSBackend() (Backend, error) { return &PF{}, nil }
@@ -0,0 +1,14 @@
+package firewall
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// OSBackend returns nftables when the nft command is installed.
+func OSBackend() (Backend, error) {
+	if _, err := exec.LookPath("nft"); err != nil {
+		return nil, fmt.Errorf("%w: nft not found", ErrUnsupported)
+	}
+	return NFTables{}, nil
+}
@@ -0,0 +1,6 @@
+//go:build !linux && !darwin
+
+package firewall
+
+// OSBackend returns ErrUnsupported.
+func OSBackend() (Backend, error) { return nil, ErrUnsupported }
@@ -0,0 +1,32 @@
+// Package firewall installs the agent's packet filter rules into the host
+// firewall, for protections that routes alone cannot express, such as the
+// IPv6 kill switch.
+//
+// # Sets
+//
+// Rules come in named sets, one per feature (ipv6leak.FirewallSet for the
+// kill switch). A Rule matches outgoing packets by family, interface, and
+// destination and accepts or drops them; the first match in a set
+// decides. Install replaces a set atomically, Remove deletes it, and
+// Status lists what is installed with each rule in the backend's native
+// form, as served at GET /v1/firewall.
+//
+// # Backends
+//
+//   - linux: nftables. Each set is the table "inet spl_<set>" with one
+//     output chain, replaced in a single "nft -f -" transaction.
+//   - darwin: pf. Each set is the anchor "com.apple/250.spl.<set>",
+//     loaded with "pfctl -a ... -f -". pf is enabled by reference while a
+//     set is installed ("pfctl -E", released with "pfctl -X").
+//   - others, or linux without nft: ErrUnsupported; the kill switch falls
+//     back to blackhole routes.
+//
+// Sets never touch tables, anchors, or rules the agent did not create.
+//
+// # Teardown
+//
+// Teardown removes every set the agent installed, plus any the backend
+// still lists from an earlier run. The agent calls it after every stop
+// operation, whatever the outcome, and at exit; it also runs at startup so
+// rules left by a crash do not outlive it.
+package firewall
@@ -0,0 +1,283 @@
+package firewall
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnsupported is returned by OSBackend on platforms without a backend.
+var ErrUnsupported = errors.New("no firewall backend on this platform")
+
+// Rule actions.
+const (
+	ActionAccept = "accept"
+	ActionDrop   = "drop"
+)
+
+// Rule families.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// Rule matches outgoing packets. Empty fields match anything; rules of a
+// set are evaluated in order and the first match decides.
+type Rule struct {
+	Action  string
+	Family  string       // FamilyIPv4, FamilyIPv6, or "" for both
+	Out     string       // outgoing interface
+	Dst     netip.Prefix // destination; its family must agree with Family
+	Comment string
+}
+
+func (r Rule) validate() error {
+	if r.Action != ActionAccept && r.Action != ActionDrop {
+		return fmt.Errorf("invalid action %q", r.Action)
+	}
+	switch r.Family {
+	case "", FamilyIPv4, FamilyIPv6:
+	default:
+		return fmt.Errorf("invalid family %q", r.Family)
+	}
+	if r.Dst.IsValid() && r.Family != "" && r.Dst.Addr().Is6() != (r.Family == FamilyIPv6) {
+		return fmt.Errorf("destination %s is not %s", r.Dst, r.Family)
+	}
+	if r.Out != "" && !validWord(r.Out, 15) {
+		return fmt.Errorf("invalid interface %q", r.Out)
+	}
+	if strings.ContainsAny(r.Comment, "\"\\\n") || len(r.Comment) > 63 {
+		return fmt.Errorf("invalid comment %q", r.Comment)
+	}
+	return nil
+}
+
+// family is the rule's family, taken from Dst when Family is empty.
+func (r Rule) family() string {
+	if r.Family == "" && r.Dst.IsValid() {
+		if r.Dst.Addr().Is6() {
+			return FamilyIPv6
+		}
+		return FamilyIPv4
+	}
+	return r.Family
+}
+
+// Backend installs named rule sets into the host firewall. Every set
+// lives in its own table or anchor, so sets never touch each other or
+// rules the agent did not install.
+type Backend interface {
+	// Name is "nftables" or "pf".
+	Name() string
+	// Render returns the backend's native form of r.
+	Render(r Rule) string
+	// Apply replaces set with rules atomically: the old rules stay in
+	// place until the new ones are loaded.
+	Apply(set string, rules []Rule) error
+	// Remove deletes set; removing a missing set is not an error.
+	Remove(set string) error
+	// Sets lists the agent's sets installed now, including those left by
+	// an earlier run.
+	Sets() ([]string, error)
+}
+
+// ByName returns the backend called name, for rendering rules that
+// another process installs (see package privhelper).
+func ByName(name string) (Backend, error) {
+	switch name {
+	case "nftables":
+		return NFTables{}, nil
+	case "pf":
+		return &PF{}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown backend %q", ErrUnsupported, name)
+}
+
+// Set is an installed rule set.
+type Set struct {
+	Name      string
+	Rules     []Rule
+	Native    []string // Rules as the backend rendered them
+	Installed time.Time
+}
+
+// TeardownResult records one Teardown.
+type TeardownResult struct {
+	At      time.Time
+	Removed []string
+	Err     string
+}
+
+// Firewall tracks the sets the agent installed through one backend.
+type Firewall struct {
+	backend Backend
+	logger  *slog.Logger
+
+	mu       sync.Mutex
+	sets     map[string]Set
+	teardown TeardownResult
+}
+
+// New returns a firewall installing through b. A nil logger disables
+// logging.
+func New(b Backend, logger *slog.Logger) *Firewall {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Firewall{backend: b, logger: logger, sets: make(map[string]Set)}
+}
+
+// Backend returns the backend's name.
+func (f *Firewall) Backend() string { return f.backend.Name() }
+
+// Install replaces set with rules atomically.
+func (f *Firewall) Install(set string, rules []Rule) error {
+	if !validSet(set) {
+		return fmt.Errorf("invalid set name %q", set)
+	}
+	native := make([]string, len(rules))
+	for i, r := range rules {
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("set %s rule %d: %w", set, i, err)
+		}
+		native[i] = f.backend.Render(r)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.backend.Apply(set, rules); err != nil {
+		return fmt.Errorf("install %s: %w", set, err)
+	}
+	f.sets[set] = Set{Name: set, Rules: slices.Clone(rules), Native: native, Installed: time.Now()}
+	f.logger.Info("firewall rules installed", "backend", f.backend.Name(), "set", set, "rules", len(rules))
+	return nil
+}
+
+// Remove deletes set. Removing a set that is not installed is not an
+// error.
+func (f *Firewall) Remove(set string) error {
+	if !validSet(set) {
+		return fmt.Errorf("invalid set name %q", set)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.remove(set)
+}
+
+func (f *Firewall) remove(set string) error {
+	if err := f.backend.Remove(set); err != nil {
+		return fmt.Errorf("remove %s: %w", set, err)
+	}
+	if _, ok := f.sets[set]; ok {
+		delete(f.sets, set)
+		f.logger.Info("firewall rules removed", "backend", f.backend.Name(), "set", set)
+	}
+	return nil
+}
+
+// Teardown removes every set the agent installed, and any the backend
+// still lists from an earlier run. It returns the sets removed and the
+// first failure; the remaining sets are still attempted.
+func (f *Firewall) Teardown() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.sets))
+	for name := range f.sets {
+		names = append(names, name)
+	}
+	installed, err := f.backend.Sets()
+	for _, name := range installed {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var removed []string
+	for _, name := range names {
+		if rerr := f.remove(name); rerr != nil {
+			if err == nil {
+				err = rerr
+			}
+			continue
+		}
+		removed = append(removed, name)
+	}
+	f.teardown = TeardownResult{At: time.Now(), Removed: removed}
+	if err != nil {
+		f.teardown.Err = err.Error()
+		f.logger.Warn("firewall teardown incomplete", "backend", f.backend.Name(), "removed", removed, "err", err)
+	}
+	return removed, err
+}
+
+// LastTeardown returns the latest Teardown's result; At is zero before
+// the first.
+func (f *Firewall) LastTeardown() TeardownResult {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.teardown
+	t.Removed = slices.Clone(t.Removed)
+	return t
+}
+
+// Status returns the installed sets by name.
+func (f *Firewall) Status() []Set {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Set, 0, len(f.sets))
+	for _, s := range f.sets {
+		s.Rules = slices.Clone(s.Rules)
+		s.Native = slices.Clone(s.Native)
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Installed reports whether set is installed.
+func (f *Firewall) Installed(set string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.sets[set]
+	return ok
+}
+
+// validSet reports whether set is a valid set name: up to 32 lowercase
+// letters, digits, and '_'.
+func validSet(set string) bool {
+	return validWord(set, 32) && !strings.ContainsAny(set, "ABCDEFGHIJKLMNOPQRSTUVWXYZ-.")
+}
+
+// validWord reports whether s is a short name of letters, digits, '_',
+// '-', and '.', safe to place in a backend's rule text.
+func validWord(s string, max int) bool {
+	if s == "" || len(s) > max {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// command runs name with stdin and returns its output; a failure carries
+// stderr.
+func command(stdin, name string, args ...string) (string, string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), stderr.String(), nil
+}
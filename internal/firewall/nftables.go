@@ -0,0 +1,77 @@
+package firewall
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nftPrefix starts the name of every table the agent owns.
+const nftPrefix = "spl_"
+
+// NFTables installs each set as its own inet table with an output chain,
+// loaded through "nft -f -" so a replace is one transaction.
+type NFTables struct{}
+
+// Name implements Backend.
+func (NFTables) Name() string { return "nftables" }
+
+// Render implements Backend: `oifname "utun3" ip6 daddr fe80::/10 accept`.
+func (NFTables) Render(r Rule) string {
+	var parts []string
+	if r.Out != "" {
+		parts = append(parts, fmt.Sprintf("oifname %q", r.Out))
+	}
+	switch fam := r.family(); {
+	case r.Dst.IsValid() && fam == FamilyIPv6:
+		parts = append(parts, "ip6 daddr "+r.Dst.Masked().String())
+	case r.Dst.IsValid():
+		parts = append(parts, "ip daddr "+r.Dst.Masked().String())
+	case fam != "":
+		parts = append(parts, "meta nfproto "+fam)
+	}
+	parts = append(parts, r.Action)
+	if r.Comment != "" {
+		parts = append(parts, fmt.Sprintf("comment %q", r.Comment))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Apply implements Backend. Declaring the table first makes the delete
+// valid when it does not exist yet.
+func (n NFTables) Apply(set string, rules []Rule) error {
+	table := "inet " + nftPrefix + set
+	var b strings.Builder
+	fmt.Fprintf(&b, "table %s\ndelete table %s\ntable %s {\n", table, table, table)
+	b.WriteString("\tchain output {\n\t\ttype filter hook output priority 0; policy accept;\n")
+	for _, r := range rules {
+		b.WriteString("\t\t" + n.Render(r) + "\n")
+	}
+	b.WriteString("\t}\n}\n")
+	return nft(b.String())
+}
+
+// Remove implements Backend.
+func (NFTables) Remove(set string) error {
+	table := "inet " + nftPrefix + set
+	return nft(fmt.Sprintf("table %s\ndelete table %s\n", table, table))
+}
+
+// Sets implements Backend from "nft list tables".
+func (NFTables) Sets() ([]string, error) {
+	out, _, err := command("", "nft", "list", "tables")
+	if err != nil {
+		return nil, err
+	}
+	var sets []string
+	for line := range strings.Lines(out) {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), "table inet "+nftPrefix); ok {
+			sets = append(sets, name)
+		}
+	}
+	return sets, nil
+}
+
+func nft(script string) error {
+	_, _, err := command(script, "nft", "-f", "-")
+	return err
+}
@@ -0,0 +1,130 @@
+package firewall
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// pfParent is the anchor macOS's default ruleset evaluates every child
+// of; pfPrefix starts the name of each child the agent owns.
+const (
+	pfParent = "com.apple"
+	pfPrefix = "250.spl."
+)
+
+// PF installs each set as its own anchor under com.apple, loaded with
+// "pfctl -a <anchor> -f -", which swaps the anchor's rules atomically. pf
+// is enabled with a reference ("pfctl -E") while any set is installed, so
+// removing the last set leaves pf as enabled as it was.
+type PF struct {
+	mu    sync.Mutex
+	token string // enable reference held while any set is installed
+	sets  map[string]bool
+}
+
+// Name implements Backend.
+func (*PF) Name() string { return "pf" }
+
+// Render implements Backend: `pass out quick on utun3 inet6 to fe80::/10`.
+func (*PF) Render(r Rule) string {
+	parts := []string{"pass out quick"}
+	if r.Action == ActionDrop {
+		parts = []string{"block drop out quick"}
+	}
+	if r.Out != "" {
+		parts = append(parts, "on "+r.Out)
+	}
+	switch r.family() {
+	case FamilyIPv4:
+		parts = append(parts, "inet")
+	case FamilyIPv6:
+		parts = append(parts, "inet6")
+	}
+	if r.Dst.IsValid() {
+		parts = append(parts, "to "+r.Dst.Masked().String())
+	} else {
+		parts = append(parts, "all")
+	}
+	if r.Comment != "" {
+		parts = append(parts, fmt.Sprintf("label %q", r.Comment))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Apply implements Backend.
+func (p *PF) Apply(set string, rules []Rule) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token == "" {
+		stdout, stderr, err := command("", "pfctl", "-E")
+		if err != nil {
+			return err
+		}
+		m := pfToken.FindStringSubmatch(stdout + stderr)
+		if m == nil {
+			return fmt.Errorf("pfctl -E: no reference token in %q", strings.TrimSpace(stderr))
+		}
+		p.token = m[1]
+	}
+	var b strings.Builder
+	for _, r := range rules {
+		b.WriteString(p.Render(r) + "\n")
+	}
+	if _, _, err := command(b.String(), "pfctl", "-a", pfAnchor(set), "-f", "-"); err != nil {
+		p.release()
+		return err
+	}
+	if p.sets == nil {
+		p.sets = make(map[string]bool)
+	}
+	p.sets[set] = true
+	return nil
+}
+
+// Remove implements Backend. Flushing a missing anchor succeeds.
+func (p *PF) Remove(set string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, _, err := command("", "pfctl", "-a", pfAnchor(set), "-F", "all"); err != nil {
+		return err
+	}
+	delete(p.sets, set)
+	p.release()
+	return nil
+}
+
+// release drops the enable reference once no set is installed.
+func (p *PF) release() {
+	if p.token == "" || len(p.sets) > 0 {
+		return
+	}
+	_, _, _ = command("", "pfctl", "-X", p.token)
+	p.token = ""
+}
+
+// Sets implements Backend from the children of com.apple that have rules.
+// Flushed anchors linger in the listing while empty, so each is checked.
+func (*PF) Sets() ([]string, error) {
+	out, _, err := command("", "pfctl", "-a", pfParent, "-s", "Anchors")
+	if err != nil {
+		return nil, err
+	}
+	var sets []string
+	for line := range strings.Lines(out) {
+		name, ok := strings.CutPrefix(strings.TrimSpace(line), pfParent+"/"+pfPrefix)
+		if !ok {
+			continue
+		}
+		if rules, _, err := command("", "pfctl", "-a", pfAnchor(name), "-s", "rules"); err == nil && strings.TrimSpace(rules) != "" {
+			sets = append(sets, name)
+		}
+	}
+	return sets, nil
+}
+
+func pfAnchor(set string) string { return pfParent + "/" + pfPrefix + set }
+
+// pfToken finds the reference in "pfctl -E" output ("Token : 1234").
+var pfToken = regexp.MustCompile(`Token : (\d+)`)
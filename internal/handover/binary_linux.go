@@ -0,0 +1,35 @@
+package handover
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// openBinary opens path once and, when want is set, checks the digest of
+// what it read. The returned exe is /proc/self/fd/N for that descriptor,
+// so the process Offer starts runs exactly the bytes that were hashed even
+// if path is replaced in between. extra is the number of ExtraFiles the
+// child gets: exec.Cmd dup2s them onto 3 and up, using scratch descriptors
+// up to about twice that, so N is placed above the range it touches. The
+// caller closes f; it is nil when want is empty and exe is path.
+func openBinary(path, want string, extra int) (exe string, f *os.File, err error) {
+	if want == "" {
+		return path, nil, nil
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("handover: %w", err)
+	}
+	defer src.Close()
+	if err := checkDigest(src, path, want); err != nil {
+		return "", nil, err
+	}
+	fd, err := unix.FcntlInt(src.Fd(), unix.F_DUPFD_CLOEXEC, 2*(3+extra)+2)
+	if err != nil {
+		return "", nil, fmt.Errorf("handover: dup %s: %w", path, err)
+	}
+	return "/proc/self/fd/" + strconv.Itoa(fd), os.NewFile(uintptr(fd), path), nil
+}
@@ -0,0 +1,52 @@
+package handover
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestOpenBinaryRunsHashedBytes replaces the binary after the digest check
+// and expects the exec to run the original.
+func TestOpenBinaryRunsHashedBytes(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "agent")
+	if err := os.WriteFile(path, b, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(b)
+
+	if _, _, err := openBinary(path, hex.EncodeToString(make([]byte, sha256.Size)), 1); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("wrong digest: err = %v, want ErrDigestMismatch", err)
+	}
+
+	exe, f, err := openBinary(path, hex.EncodeToString(sum[:]), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nexit 3\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(exe, "-test.run=^$")
+	cmd.Args[0] = path
+	cmd.ExtraFiles = []*os.File{os.Stdin}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("exec %s: %v: %s", exe, err, out)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build unix && !linux
+
+package handover
+
+import (
+	"fmt"
+	"os"
+)
+
+// openBinary checks the digest of path when want is set and returns path
+// to execute. Without /proc/self/fd or fexecve the new process is started
+// by path, so the check is advisory: a writer who can replace path between
+// the check and the exec defeats it. Keep the binary's directory writable
+// only by root. extra is unused here; f is always nil.
+func openBinary(path, want string, extra int) (exe string, f *os.File, err error) {
+	if want == "" {
+		return path, nil, nil
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("handover: %w", err)
+	}
+	defer src.Close()
+	if err := checkDigest(src, path, want); err != nil {
+		return "", nil, err
+	}
+	return path, nil, nil
+}
@@ -0,0 +1,28 @@
+// Package handover passes the agent's API listeners to a new agent
+// process, so an upgraded binary takes over without refusing a
+// connection.
+//
+// Offer starts the new executable with a control socket and each
+// listener's socket as inherited descriptors (3, then 4 and up), names the
+// control socket in $SPL_HANDOVER_FD, and sends a JSON header line
+// (protocol version, its own pid, the listener addresses). The new
+// process calls Receive at startup, serves the listeners (package api
+// matches them to its configured listeners by address, as it does
+// systemd's), and answers Ready, or Fail. Until the answer both processes
+// hold the sockets, so the kernel queues connections rather than refusing
+// them; the old process then stops accepting and drains. Offer kills a
+// new process that fails, exits, or does not answer within the timeout,
+// and the old one keeps serving.
+//
+// With a digest, Offer on Linux hashes the opened executable and starts it
+// through /proc/self/fd, so the checked bytes are the ones that run. Other
+// unix platforms check the file and then execute it by path, so there the
+// digest is advisory.
+//
+// Passing descriptors rather than binding again with SO_REUSEPORT means
+// unix sockets are handed over too, and a socket the new process does
+// not take is never half served. Only listeners are passed; the TUN
+// device and engines stay with the process that set them up. Offer works
+// on unix platforms; elsewhere it returns ErrUnsupported and Receive
+// returns nil.
+package handover
@@ -0,0 +1,120 @@
+package handover
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Protocol is the handover protocol version; Receive refuses others.
+const Protocol = 1
+
+// DefaultTimeout bounds how long Offer waits for the new process to serve.
+const DefaultTimeout = 30 * time.Second
+
+// envFD names the environment variable holding the control socket's
+// descriptor in the new process.
+const envFD = "SPL_HANDOVER_FD"
+
+// Errors returned by Offer.
+var (
+	ErrUnsupported    = errors.New("handover: not supported on this platform")
+	ErrDigestMismatch = errors.New("handover: binary digest mismatch")
+)
+
+// Supported reports whether Offer works on this platform.
+func Supported() bool { return supported }
+
+// Options configures Offer.
+type Options struct {
+	Binary    string         // absolute path of the new executable
+	Args      []string       // its arguments
+	SHA256    string         // hex digest Binary must have; empty skips the check
+	Listeners []net.Listener // TCP and unix listeners to pass, in order
+	Timeout   time.Duration  // default DefaultTimeout
+}
+
+// header is the first line the old process sends on the control socket.
+// The listeners follow it as descriptors 4 and up.
+type header struct {
+	Protocol  int      `json:"protocol"`
+	PID       int      `json:"pid"`       // the old process
+	Listeners []string `json:"listeners"` // addresses, for logs
+}
+
+// reply is the new process's answer.
+type reply struct {
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+// Handover is what the new process received. A nil *Handover means the
+// process was not started by Offer; its methods then do nothing.
+type Handover struct {
+	ctrl      net.Conn
+	from      int
+	listeners []net.Listener
+}
+
+// From returns the pid of the process that handed over, or 0.
+func (h *Handover) From() int {
+	if h == nil {
+		return 0
+	}
+	return h.from
+}
+
+// Listeners returns the listeners handed over.
+func (h *Handover) Listeners() []net.Listener {
+	if h == nil {
+		return nil
+	}
+	return h.listeners
+}
+
+// Ready tells the old process that this one serves; the old one then
+// shuts down.
+func (h *Handover) Ready() error { return h.answer(reply{Ready: true}) }
+
+// Fail tells the old process that this one cannot take over, so it keeps
+// serving; this process should exit.
+func (h *Handover) Fail(err error) error { return h.answer(reply{Error: err.Error()}) }
+
+func (h *Handover) answer(r reply) error {
+	if h == nil || h.ctrl == nil {
+		return nil
+	}
+	defer func() {
+		h.ctrl.Close()
+		h.ctrl = nil
+	}()
+	return writeLine(h.ctrl, r)
+}
+
+// checkDigest compares the SHA-256 of r, the contents of path, with want.
+func checkDigest(r io.Reader, path, want string) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%w: %s has %s", ErrDigestMismatch, path, got)
+	}
+	return nil
+}
+
+// writeLine sends v as one JSON line.
+func writeLine(w io.Writer, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
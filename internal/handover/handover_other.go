@@ -0,0 +1,13 @@
+//go:build !unix
+
+package handover
+
+import "context"
+
+const supported = false
+
+// Offer returns ErrUnsupported.
+func Offer(context.Context, Options) (int, error) { return 0, ErrUnsupported }
+
+// Receive returns nil: nothing is handed over on this platform.
+func Receive() (*Handover, error) { return nil, nil }
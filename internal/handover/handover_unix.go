@@ -0,0 +1,220 @@
+//go:build unix
+
+package handover
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const supported = true
+
+// ctrlFD is the control socket's descriptor in the new process; the
+// listeners follow it.
+const ctrlFD = 3
+
+// Offer starts opts.Binary with the listeners and waits until it serves
+// them. On success it returns the new pid; the caller should then stop
+// accepting and exit without tearing down what the new process took
+// over. On failure the new process is killed and the listeners stay
+// usable here. See openBinary for how opts.SHA256 is enforced.
+func Offer(ctx context.Context, opts Options) (int, error) {
+	if !filepath.IsAbs(opts.Binary) {
+		return 0, fmt.Errorf("handover: binary %q is not absolute", opts.Binary)
+	}
+	exe, bin, err := openBinary(opts.Binary, opts.SHA256, 1+len(opts.Listeners))
+	if err != nil {
+		return 0, err
+	}
+	if bin != nil {
+		defer bin.Close()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	hdr := header{Protocol: Protocol, PID: os.Getpid()}
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, ln := range opts.Listeners {
+		fl, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return 0, fmt.Errorf("handover: listener %s cannot be passed", ln.Addr())
+		}
+		f, err := fl.File()
+		if err != nil {
+			return 0, fmt.Errorf("handover: listener %s: %w", ln.Addr(), err)
+		}
+		files = append(files, f)
+		hdr.Listeners = append(hdr.Listeners, ln.Addr().Network()+"://"+ln.Addr().String())
+	}
+	ctrl, peer, err := socketPair()
+	if err != nil {
+		return 0, err
+	}
+	defer ctrl.Close()
+
+	cmd := exec.Command(exe, opts.Args...)
+	cmd.Args[0] = opts.Binary
+	cmd.ExtraFiles = append([]*os.File{peer}, files...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = childEnv(os.Environ())
+	err = cmd.Start()
+	peer.Close()
+	if err != nil {
+		return 0, fmt.Errorf("handover: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	if err := writeLine(ctrl, hdr); err != nil {
+		return 0, abort(cmd, exited, fmt.Errorf("handover: send: %w", err))
+	}
+	answered := make(chan reply, 1)
+	go func() {
+		var r reply
+		if err := json.NewDecoder(ctrl).Decode(&r); err != nil {
+			r.Error = "no answer: " + err.Error()
+		}
+		answered <- r
+	}()
+	timer := time.NewTimer(opts.Timeout)
+	defer timer.Stop()
+	select {
+	case r := <-answered:
+		if !r.Ready {
+			return 0, abort(cmd, exited, errors.New("handover: new agent: "+r.Error))
+		}
+	case err := <-exited:
+		return 0, fmt.Errorf("handover: new agent exited: %v", err)
+	case <-timer.C:
+		return 0, abort(cmd, exited, fmt.Errorf("handover: new agent not serving after %v", opts.Timeout))
+	case <-ctx.Done():
+		return 0, abort(cmd, exited, ctx.Err())
+	}
+	// The socket file now belongs to the new process too.
+	for _, ln := range opts.Listeners {
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	return cmd.Process.Pid, nil
+}
+
+// abort kills the new process and returns err.
+func abort(cmd *exec.Cmd, exited <-chan error, err error) error {
+	_ = cmd.Process.Kill()
+	<-exited
+	return err
+}
+
+// socketPair returns a connected control socket and the end for the new
+// process, both close-on-exec until exec.Cmd passes the peer.
+func socketPair() (net.Conn, *os.File, error) {
+	syscall.ForkLock.RLock()
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err == nil {
+		unix.CloseOnExec(fds[0])
+		unix.CloseOnExec(fds[1])
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return nil, nil, fmt.Errorf("handover: socketpair: %w", err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "handover")
+	conn, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		unix.Close(fds[1])
+		return nil, nil, fmt.Errorf("handover: %w", err)
+	}
+	return conn, os.NewFile(uintptr(fds[1]), "handover-peer"), nil
+}
+
+// childEnv points the new process at its control socket. WATCHDOG_PID
+// names this process; without it systemd's watchdog applies to whichever
+// process becomes the main one.
+func childEnv(env []string) []string {
+	out := env[:0:0]
+	for _, kv := range env {
+		if strings.HasPrefix(kv, envFD+"=") || strings.HasPrefix(kv, "WATCHDOG_PID=") {
+			continue
+		}
+		out = append(out, kv)
+	}
+	return append(out, envFD+"="+strconv.Itoa(ctrlFD))
+}
+
+// Receive returns the listeners handed over by the process that started
+// this one with Offer, or nil when it was started otherwise. The caller
+// answers with Ready once it serves them, or Fail.
+func Receive() (*Handover, error) {
+	v, ok := os.LookupEnv(envFD)
+	if !ok {
+		return nil, nil
+	}
+	os.Unsetenv(envFD)
+	fd, err := strconv.Atoi(v)
+	if err != nil || fd < 0 {
+		return nil, fmt.Errorf("handover: invalid %s %q", envFD, v)
+	}
+	unix.CloseOnExec(fd)
+	f := os.NewFile(uintptr(fd), "handover")
+	ctrl, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("handover: control socket: %w", err)
+	}
+	h := &Handover{ctrl: ctrl}
+	fail := func(err error) (*Handover, error) {
+		_ = h.Fail(err)
+		for _, ln := range h.listeners {
+			ln.Close()
+		}
+		return nil, err
+	}
+
+	_ = ctrl.SetReadDeadline(time.Now().Add(10 * time.Second))
+	line, err := bufio.NewReader(ctrl).ReadBytes('\n')
+	if err != nil {
+		return fail(fmt.Errorf("handover: read header: %w", err))
+	}
+	_ = ctrl.SetReadDeadline(time.Time{})
+	var hdr header
+	if err := json.Unmarshal(line, &hdr); err != nil {
+		return fail(fmt.Errorf("handover: header: %w", err))
+	}
+	if hdr.Protocol != Protocol {
+		return fail(fmt.Errorf("handover: protocol %d, want %d", hdr.Protocol, Protocol))
+	}
+	h.from = hdr.PID
+	for i, addr := range hdr.Listeners {
+		lfd := fd + 1 + i
+		unix.CloseOnExec(lfd)
+		lf := os.NewFile(uintptr(lfd), addr)
+		ln, err := net.FileListener(lf)
+		lf.Close()
+		if err != nil {
+			return fail(fmt.Errorf("handover: listener %s: %w", addr, err))
+		}
+		h.listeners = append(h.listeners, ln)
+	}
+	return h, nil
+}
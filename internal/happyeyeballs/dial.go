@@ -0,0 +1,210 @@
+package happyeyeballs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// RFC 8305 timings.
+const (
+	ResolutionDelay = 50 * time.Millisecond
+	AttemptDelay    = 250 * time.Millisecond
+)
+
+// Address families reported by Family.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// Resolver looks up a hostname; *net.Resolver implements it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Dialer dials TCP with parallel address families. The zero value is
+// ready to use.
+type Dialer struct {
+	// Resolver resolves hostnames (default net.DefaultResolver).
+	Resolver Resolver
+	// AttemptDelay overrides the pause between attempts (default
+	// AttemptDelay).
+	AttemptDelay time.Duration
+	// Dialer makes each attempt; its Timeout and KeepAlive apply per
+	// attempt.
+	Dialer net.Dialer
+}
+
+// Dial dials address with a zero Dialer.
+func Dial(ctx context.Context, address string) (net.Conn, error) {
+	var d Dialer
+	return d.DialContext(ctx, "tcp", address)
+}
+
+// DialContext connects to address ("host:port") on network "tcp". Other
+// networks are passed to the underlying net.Dialer unchanged.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if network != "tcp" {
+		return d.Dialer.DialContext(ctx, network, address)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return d.Dialer.DialContext(ctx, network, address)
+	}
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return d.race(ctx, addrs, port)
+}
+
+// Family returns FamilyIPv4 or FamilyIPv6 for conn's remote address, or
+// "" when it is not an IP address.
+func Family(conn net.Conn) string {
+	ap, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil {
+		return ""
+	}
+	if ap.Addr().Unmap().Is4() {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// resolve looks up AAAA and A concurrently and returns the addresses in
+// attempt order.
+func (d *Dialer) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	r := d.Resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	type answer struct {
+		addrs []netip.Addr
+		err   error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	v6, v4 := make(chan answer, 1), make(chan answer, 1)
+	lookup := func(network string, out chan<- answer) {
+		addrs, err := r.LookupNetIP(ctx, network, host)
+		out <- answer{addrs, err}
+	}
+	go lookup("ip6", v6)
+	go lookup("ip4", v4)
+
+	var a6, a4 *answer
+	var wait <-chan time.Time
+	for a6 == nil || a4 == nil {
+		select {
+		case a := <-v6:
+			a6 = &a
+		case a := <-v4:
+			a4 = &a
+			if a6 == nil && a.err == nil && len(a.addrs) > 0 {
+				wait = time.After(ResolutionDelay)
+			}
+		case <-wait:
+			a6 = &answer{err: errors.New("resolution delay elapsed")}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if len(a6.addrs) == 0 && len(a4.addrs) == 0 {
+		err := a4.err
+		if err == nil {
+			err = a6.err
+		}
+		if err == nil {
+			err = fmt.Errorf("lookup %s: no addresses", host)
+		}
+		return nil, err
+	}
+	return interleave(a6.addrs, a4.addrs), nil
+}
+
+// interleave alternates families, IPv6 first.
+func interleave(v6, v4 []netip.Addr) []netip.Addr {
+	out := make([]netip.Addr, 0, len(v6)+len(v4))
+	for i := 0; i < len(v6) || i < len(v4); i++ {
+		if i < len(v6) {
+			out = append(out, v6[i])
+		}
+		if i < len(v4) {
+			out = append(out, v4[i].Unmap())
+		}
+	}
+	return out
+}
+
+// race starts one attempt per address, staggered, and returns the first
+// connection.
+func (d *Dialer) race(ctx context.Context, addrs []netip.Addr, port string) (net.Conn, error) {
+	delay := d.AttemptDelay
+	if delay <= 0 {
+		delay = AttemptDelay
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan attemptResult, len(addrs))
+	attempt := func(a netip.Addr) {
+		conn, err := d.Dialer.DialContext(ctx, "tcp", net.JoinHostPort(a.String(), port))
+		results <- attemptResult{conn, err}
+	}
+
+	next, pending := 0, 0
+	var firstErr error
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for next < len(addrs) || pending > 0 {
+		select {
+		case <-timer.C:
+			if next < len(addrs) {
+				go attempt(addrs[next])
+				next++
+				pending++
+				timer.Reset(delay)
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				cancel()
+				go drain(results, pending)
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			// A failure starts the next attempt without waiting.
+			if next < len(addrs) {
+				timer.Reset(0)
+			}
+		case <-ctx.Done():
+			go drain(results, pending)
+			return nil, ctx.Err()
+		}
+	}
+	return nil, firstErr
+}
+
+// attemptResult is the outcome of one connection attempt.
+type attemptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// drain closes the connections of the n attempts still running after the
+// race was decided.
+func drain(results <-chan attemptResult, n int) {
+	for range n {
+		if r := <-results; r.conn != nil {
+			r.conn.Close()
+		}
+	}
+}
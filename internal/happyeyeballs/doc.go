@@ -0,0 +1,22 @@
+// Package happyeyeballs dials TCP endpoints given by hostname over IPv6
+// and IPv4 in parallel, as RFC 8305 describes, so a proxy whose AAAA (or
+// A) records lead to a broken network does not stall the connection until
+// the kernel's connect timeout.
+//
+// # Algorithm
+//
+// The AAAA and A lookups run concurrently. Dialing starts as soon as the
+// AAAA answer arrives, or ResolutionDelay (50ms) after the A answer when
+// AAAA is still outstanding; a late AAAA answer is then dropped. The
+// addresses are interleaved by family, IPv6 first, and attempts start
+// AttemptDelay (250ms) apart, or at once when the previous attempt fails.
+// The first connection to succeed wins; the others are canceled and
+// closed. When every attempt fails, the error of the first is returned.
+//
+// IP literals are dialed directly, as net.Dialer would.
+//
+// # Reporting
+//
+// Family names the address family of a connection ("ipv4" or "ipv6"),
+// for probe summaries and logs.
+package happyeyeballs
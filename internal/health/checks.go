@@ -0,0 +1,271 @@
+package health
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+	"github.com/sanverite/simple-packet-logger/internal/probe"
+)
+
+// ProbeCheck runs a registered probe. Errors fail the check; probe warnings
+// downgrade it to warn. The result is not stored in core.State.
+func ProbeCheck(name string, p probe.Probe, params probe.Params) Check {
+	return Check{Name: name, Kind: KindProbe, Run: func(ctx context.Context) (Status, string) {
+		summary, err := probe.Run(ctx, p, params)
+		if err != nil {
+			return StatusFail, err.Error()
+		}
+		if len(summary.Warnings) > 0 {
+			return StatusWarn, strings.Join(core.WarningMessages(summary.Warnings), "; ")
+		}
+		return StatusPass, formatLatencies(summary.Latencies)
+	}}
+}
+
+// formatLatencies renders "step=duration" pairs (e.g. "tcp_connect=412µs")
+// in a stable order, rounded to the microsecond.
+func formatLatencies(lat map[string]time.Duration) string {
+	keys := make([]string, 0, len(lat))
+	for k := range lat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, lat[k].Round(time.Microsecond)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// running reports whether the agent is expected to have a data plane.
+func running(s core.AgentState) bool {
+	return s == core.StateActive || s == core.StateDegraded
+}
+
+// DataPlane checks the tun2socks process and TUN interface recorded in
+// state. It is skipped unless the agent is active, degraded, or paused
+// (which keeps both up).
+func DataPlane(state *core.State, ifaceExists func(name string) (bool, error)) Check {
+	return Check{Name: "data plane", Kind: KindDataPlane, Run: func(ctx context.Context) (Status, string) {
+		snap := state.GetSnapshot()
+		if !running(snap.AgentState) && snap.AgentState != core.StatePaused {
+			return StatusSkip, fmt.Sprintf("agent is %s; data plane not running", snap.AgentState)
+		}
+		if snap.Tun2Socks.PID == 0 {
+			return StatusFail, "tun2socks is not running"
+		}
+		if snap.TUN.Name != "" {
+			ok, err := ifaceExists(snap.TUN.Name)
+			switch {
+			case err != nil:
+				return StatusWarn, fmt.Sprintf("inspect interface %s: %v", snap.TUN.Name, err)
+			case !ok:
+				return StatusFail, fmt.Sprintf("TUN interface %s is missing", snap.TUN.Name)
+			}
+		}
+		if !snap.Tun2Socks.TCPOk {
+			if n := len(snap.Tun2Socks.LastErrors); n > 0 {
+				return StatusFail, "tun2socks TCP path is unhealthy; last error: " + snap.Tun2Socks.LastErrors[n-1]
+			}
+			return StatusFail, "tun2socks TCP path is unhealthy"
+		}
+		if snap.Tun2Socks.UDPUnsupported {
+			return StatusWarn, "tun2socks UDP is not carried by the http proxy upstream"
+		}
+		if !snap.Tun2Socks.UDPOk {
+			return StatusWarn, "tun2socks UDP path is unhealthy"
+		}
+		return StatusPass, fmt.Sprintf("tun2socks pid %d up %ds on %s", snap.Tun2Socks.PID, snap.Tun2Socks.UptimeSec, snap.TUN.Name)
+	}}
+}
+
+// DNSLeak fails when a system resolver is reached outside the tunnel, i.e.
+// it falls inside a bypass host, LAN, or excluded network, or outside the
+// included networks of a split tunnel. Loopback stub resolvers are
+// reported as a warning because their upstreams cannot be inspected.
+func DNSLeak(state *core.State, nameservers func() ([]netip.Addr, error)) Check {
+	return Check{Name: "dns leak", Kind: KindDNSLeak, Run: func(ctx context.Context) (Status, string) {
+		snap := state.GetSnapshot()
+		if !running(snap.AgentState) {
+			return StatusSkip, fmt.Sprintf("agent is %s; nothing is tunneled", snap.AgentState)
+		}
+		servers, err := nameservers()
+		if err != nil {
+			return StatusWarn, "read resolvers: " + err.Error()
+		}
+		if len(servers) == 0 {
+			return StatusWarn, "no system resolvers configured"
+		}
+		outside := append(parsePrefixes(snap.Routes.BypassHosts), parsePrefixes(snap.Routes.LanCIDRs)...)
+		outside = append(outside, parsePrefixes(snap.Routes.ExcludeCIDRs)...)
+		include := parsePrefixes(snap.Routes.IncludeCIDRs)
+		var leaks, stubs []string
+		for _, ns := range servers {
+			ns = ns.Unmap().WithZone("")
+			if ns.IsLoopback() {
+				stubs = append(stubs, ns.String())
+				continue
+			}
+			if p, ok := containing(outside, ns); ok {
+				leaks = append(leaks, fmt.Sprintf("%s (via %s)", ns, p))
+			} else if _, ok := containing(include, ns); len(include) > 0 && !ok {
+				leaks = append(leaks, fmt.Sprintf("%s (not in include_cidrs)", ns))
+			}
+		}
+		switch {
+		case len(leaks) > 0:
+			return StatusFail, "resolvers outside the tunnel: " + strings.Join(leaks, ", ")
+		case len(stubs) > 0:
+			return StatusWarn, "local stub resolver " + strings.Join(stubs, ", ") + "; upstreams not verified"
+		}
+		return StatusPass, fmt.Sprintf("%d resolver(s) routed through the tunnel", len(servers))
+	}}
+}
+
+// IPv6Leak fails when an IPv4-only tunnel leaves IPv6 egress outside it:
+// scan lists the uncovered routes (see package ipv6leak). A leak the kill
+// switch blocks is a warning. Skipped unless active, or when the tunnel
+// carries IPv6.
+func IPv6Leak(state *core.State, scan func(tun string) ([]string, error), blocked func() bool) Check {
+	return Check{Name: "ipv6 leak", Kind: KindIPv6Leak, Run: func(ctx context.Context) (Status, string) {
+		snap := state.GetSnapshot()
+		switch {
+		case !running(snap.AgentState):
+			return StatusSkip, fmt.Sprintf("agent is %s; nothing is tunneled", snap.AgentState)
+		case snap.TUN.LocalIP6 != "":
+			return StatusSkip, "ipv6 is routed through the tunnel"
+		}
+		leaks, err := scan(snap.TUN.Name)
+		switch {
+		case err != nil:
+			return StatusWarn, "scan ipv6 routes: " + err.Error()
+		case len(leaks) == 0:
+			return StatusPass, "no ipv6 egress outside the tunnel"
+		case blocked():
+			return StatusWarn, "ipv6 blocked by the kill switch; egress outside the tunnel: " + strings.Join(leaks, ", ")
+		}
+		return StatusFail, "ipv6 egress outside the tunnel: " + strings.Join(leaks, ", ")
+	}}
+}
+
+// containing returns the first prefix that contains a.
+func containing(prefixes []netip.Prefix, a netip.Addr) (netip.Prefix, bool) {
+	for _, p := range prefixes {
+		if p.Contains(a) {
+			return p, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// parsePrefixes accepts CIDRs and bare addresses, skipping anything else
+// (e.g., unresolved hostnames).
+func parsePrefixes(in []string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, s := range in {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			out = append(out, p.Masked())
+		} else if a, err := netip.ParseAddr(s); err == nil {
+			a = a.Unmap()
+			out = append(out, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	return out
+}
+
+// RouteDrift compares the live IPv4 default route with the agent's state:
+// while active with a full tunnel it must use the TUN interface; otherwise
+// (split tunnel or inactive) it must not, and a changed gateway since the
+// last start is reported as a warning.
+func RouteDrift(state *core.State, current func() (netinfo.Route, error)) Check {
+	return Check{Name: "route drift", Kind: KindRoute, Run: func(ctx context.Context) (Status, string) {
+		snap := state.GetSnapshot()
+		switch snap.AgentState {
+		case core.StateStarting, core.StateStopping:
+			return StatusSkip, fmt.Sprintf("agent is %s; routes are changing", snap.AgentState)
+		}
+		rt, err := current()
+		switch {
+		case errors.Is(err, netinfo.ErrUnsupported):
+			return StatusSkip, err.Error()
+		case errors.Is(err, netinfo.ErrNoDefaultRoute):
+			if running(snap.AgentState) {
+				return StatusFail, "no IPv4 default route"
+			}
+			return StatusWarn, "no IPv4 default route"
+		case err != nil:
+			return StatusWarn, "read default route: " + err.Error()
+		}
+		via := rt.Interface
+		if rt.Gateway.IsValid() {
+			via = rt.Gateway.String() + " on " + rt.Interface
+		}
+		tun := snap.TUN.Name
+		if running(snap.AgentState) && len(snap.Routes.IncludeCIDRs) == 0 {
+			if tun != "" && rt.Interface != tun {
+				return StatusFail, fmt.Sprintf("default route via %s, expected TUN %s", via, tun)
+			}
+			return StatusPass, "default route via " + via
+		}
+		if tun != "" && rt.Interface == tun {
+			return StatusFail, fmt.Sprintf("default route points at TUN %s", tun)
+		}
+		if orig := snap.Routes.OriginalGateway; orig != "" && rt.Gateway.IsValid() && orig != rt.Gateway.String() {
+			return StatusWarn, fmt.Sprintf("default gateway changed from %s to %s", orig, rt.Gateway)
+		}
+		return StatusPass, "default route via " + via
+	}}
+}
+
+// ResolvConfPath is read by SystemNameservers.
+var ResolvConfPath = "/etc/resolv.conf"
+
+// SystemNameservers returns the nameserver entries of ResolvConfPath.
+func SystemNameservers() ([]netip.Addr, error) {
+	f, err := os.Open(ResolvConfPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseResolvConf(f)
+}
+
+func parseResolvConf(r io.Reader) ([]netip.Addr, error) {
+	var out []netip.Addr
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		if a, err := netip.ParseAddr(fields[1]); err == nil {
+			out = append(out, a)
+		}
+	}
+	return out, sc.Err()
+}
+
+// SystemInterfaceExists reports whether a network interface named name exists.
+func SystemInterfaceExists(name string) (bool, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return false, err
+	}
+	for _, ifi := range ifaces {
+		if ifi.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
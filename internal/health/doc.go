@@ -0,0 +1,29 @@
+// Package health runs the on-demand full health sweep behind
+// POST /v1/healthcheck/full.
+//
+// # Sweep
+//
+// Sweep executes a list of Checks one after another under a single budget.
+// Each check gets the smaller of the remaining budget and Options.PerCheck as
+// its context deadline; once the budget is spent the remaining checks are
+// reported as skipped rather than run. Checks never run concurrently, so a
+// sweep never adds more than one probe's worth of load to the proxy.
+//
+// The report's overall Status is the worst individual result
+// (fail > warn > pass); skipped checks do not affect it.
+//
+// # Checks
+//
+//   - ProbeCheck wraps a registered probe (see package probe).
+//   - DataPlane verifies the tun2socks process and TUN interface while the
+//     agent is active.
+//   - DNSLeak flags system resolvers whose queries would leave outside the
+//     tunnel (bypass hosts or LAN networks).
+//   - RouteDrift compares the live default route with the one the agent
+//     expects for its current state.
+//   - IPv6Leak flags IPv6 default routes outside an IPv4-only tunnel.
+//
+// Host inspection is injected (interface lookup, default route, resolver
+// list, IPv6 scan) so checks stay platform-neutral; the System* helpers provide the
+// real implementations.
+package health
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// Status is the outcome of one check or of a whole sweep.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip" // not applicable, or the budget ran out
+)
+
+// Check kinds, used to group results in reports.
+const (
+	KindProbe     = "probe"
+	KindDataPlane = "data_plane"
+	KindDNSLeak   = "dns_leak"
+	KindRoute     = "route_drift"
+	KindIPv6Leak  = "ipv6_leak"
+)
+
+// Check is one step of a sweep. Run must honor ctx's deadline.
+type Check struct {
+	Name string
+	Kind string
+	Run  func(ctx context.Context) (Status, string)
+}
+
+// Result records the outcome of one Check.
+type Result struct {
+	Name     string
+	Kind     string
+	Status   Status
+	Detail   string
+	Duration time.Duration
+}
+
+// Report is the consolidated outcome of a sweep.
+type Report struct {
+	Status         Status
+	Results        []Result
+	Budget         time.Duration
+	StartedAt      time.Time
+	Elapsed        time.Duration
+	BudgetExceeded bool // at least one check was skipped for lack of time
+}
+
+// Options bounds a sweep.
+type Options struct {
+	// Budget caps the whole sweep (default DefaultBudget).
+	Budget time.Duration
+	// PerCheck caps each check (default DefaultPerCheck).
+	PerCheck time.Duration
+}
+
+// Defaults for Options.
+const (
+	DefaultBudget   = 8 * time.Second
+	DefaultPerCheck = 5 * time.Second
+)
+
+// Sweep runs checks sequentially within opts.Budget and returns a report
+// with one Result per check, in order.
+func Sweep(ctx context.Context, checks []Check, opts Options) Report {
+	if opts.Budget <= 0 {
+		opts.Budget = DefaultBudget
+	}
+	if opts.PerCheck <= 0 {
+		opts.PerCheck = DefaultPerCheck
+	}
+	start := time.Now()
+	ctx, cancel := context.WithDeadline(ctx, start.Add(opts.Budget))
+	defer cancel()
+
+	rep := Report{
+		Status:    StatusPass,
+		Results:   make([]Result, 0, len(checks)),
+		Budget:    opts.Budget,
+		StartedAt: start,
+	}
+	for _, c := range checks {
+		res := Result{Name: c.Name, Kind: c.Kind}
+		if err := ctx.Err(); err != nil {
+			res.Status = StatusSkip
+			res.Detail = "not run: " + budgetReason(err)
+			if errors.Is(err, context.DeadlineExceeded) {
+				rep.BudgetExceeded = true
+			}
+			rep.Results = append(rep.Results, res)
+			continue
+		}
+		checkCtx, checkCancel := context.WithTimeout(ctx, opts.PerCheck)
+		t0 := time.Now()
+		res.Status, res.Detail = c.Run(checkCtx)
+		res.Detail = redact.String(res.Detail)
+		res.Duration = time.Since(t0)
+		checkCancel()
+		rep.Status = worse(rep.Status, res.Status)
+		rep.Results = append(rep.Results, res)
+	}
+	rep.Elapsed = time.Since(start)
+	return rep
+}
+
+func budgetReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "sweep budget exhausted"
+	}
+	return "sweep canceled"
+}
+
+// worse returns the more severe of a and b; skip never wins.
+func worse(a, b Status) Status {
+	rank := map[Status]int{StatusPass: 0, StatusWarn: 1, StatusFail: 2}
+	ra, okA := rank[a]
+	rb, okB := rank[b]
+	switch {
+	case !okB:
+		return a
+	case !okA || rb > ra:
+		return b
+	}
+	return a
+}
@@ -0,0 +1,26 @@
+// Package hooks runs user executables on agent lifecycle transitions, for
+// notifications or firewall tweaks that should follow the tunnel.
+//
+// # Hooks
+//
+// A Hook names an executable, its arguments, the AgentState it fires on
+// (active, degraded, inactive, or any other state), and a timeout
+// (DefaultTimeout, at most MaxTimeout). Run subscribes to state_change
+// events and runs every hook whose state was just entered, one at a time
+// in transition order, so a hook never sees transitions out of order.
+//
+// # Input
+//
+// The hook reads one JSON Input on stdin: the hook name, the from and to
+// states, the event ID and time, and the status document GET /v1/status
+// would serve right after the transition. SPL_HOOK, SPL_STATE_FROM, and
+// SPL_STATE_TO are set in its environment for scripts that need nothing
+// else. The process is killed when its timeout passes.
+//
+// # Results
+//
+// Each run is recorded in the event log with kind=hook, the hook name, the
+// state, exit_code, and duration_ms: an orchestration event on success, a
+// warning event naming the error and the last line of output on failure
+// (a non-zero exit, a timeout, or a command that cannot start).
+package hooks
@@ -0,0 +1,182 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// Timeout bounds.
+const (
+	DefaultTimeout = 10 * time.Second
+	MaxTimeout     = 5 * time.Minute
+)
+
+// maxOutput bounds the output kept from one run.
+const maxOutput = 4 << 10
+
+// Hook is an executable run when the agent enters state On.
+type Hook struct {
+	Name    string // label in events and logs; defaults to the command's base name
+	On      core.AgentState
+	Command string
+	Args    []string
+	Timeout time.Duration // 0 uses DefaultTimeout
+}
+
+// Validate checks h and fills defaults.
+func (h *Hook) Validate() error {
+	if h.Name == "" {
+		h.Name = h.Command[strings.LastIndexAny(h.Command, `/\`)+1:]
+	}
+	if !slices.Contains(core.AgentStates, h.On) {
+		return fmt.Errorf("hook %q: on %q: want one of %v", h.Name, h.On, core.AgentStates)
+	}
+	if h.Command == "" {
+		return fmt.Errorf("hook %q: command is required", h.Name)
+	}
+	if h.Timeout < 0 || h.Timeout > MaxTimeout {
+		return fmt.Errorf("hook %q: timeout must be between 0 and %s", h.Name, MaxTimeout)
+	}
+	if h.Timeout == 0 {
+		h.Timeout = DefaultTimeout
+	}
+	return nil
+}
+
+// Input is the JSON document a hook reads on stdin.
+type Input struct {
+	Hook    string `json:"hook"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	EventID uint64 `json:"event_id"`
+	At      string `json:"at"` // RFC 3339
+	// Status is the agent status after the transition, as served by
+	// GET /v1/status.
+	Status any `json:"status"`
+}
+
+// Result is the outcome of one run.
+type Result struct {
+	Duration time.Duration
+	ExitCode int    // -1 when the command did not exit on its own
+	Output   string // stdout and stderr, truncated to the last 4 KiB
+	Err      error
+}
+
+// Run executes the hooks whose On matches each state transition in state
+// until ctx is done. status renders the snapshot a hook receives (e.g.
+// api.FromCoreSnapshot); hooks run one at a time in transition order. Each
+// result is recorded as an event: orchestration when the hook succeeded,
+// warning when it failed. Run blocks; run it in a goroutine.
+func Run(ctx context.Context, state *core.State, hooks []Hook, status func(core.Snapshot) any, logger *slog.Logger) {
+	var dropped uint64
+	for ev := range state.Subscribe(ctx, core.EventStateChange) {
+		if ev.Dropped > dropped {
+			logger.Warn("hooks fell behind; transitions dropped", "dropped", ev.Dropped-dropped)
+			dropped = ev.Dropped
+		}
+		to := core.AgentState(ev.Event.Data["to"])
+		for _, h := range hooks {
+			if h.On != to {
+				continue
+			}
+			in := Input{
+				Hook:    h.Name,
+				From:    ev.Event.Data["from"],
+				To:      string(to),
+				EventID: ev.Event.ID,
+				At:      ev.Event.At.UTC().Format(time.RFC3339),
+				Status:  status(state.GetSnapshot()),
+			}
+			record(state, h, Exec(ctx, h, in), logger)
+		}
+	}
+}
+
+// Exec runs h once with in on stdin. The process is killed when h.Timeout
+// passes or ctx ends.
+func Exec(ctx context.Context, h Hook, in Input) Result {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return Result{ExitCode: -1, Err: err}
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "SPL_HOOK="+h.Name, "SPL_STATE_FROM="+in.From, "SPL_STATE_TO="+in.To)
+	var out tailBuffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	// Children that inherit the pipes must not hold Wait past the kill.
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err = cmd.Run()
+	res := Result{Duration: time.Since(start), ExitCode: -1, Output: out.String()}
+	if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		res.Err = fmt.Errorf("timed out after %s", h.Timeout)
+	case err != nil:
+		res.Err = err
+	}
+	return res
+}
+
+// record logs res and appends it to the event log.
+func record(state *core.State, h Hook, res Result, logger *slog.Logger) {
+	data := map[string]string{
+		"kind":        "hook",
+		"hook":        h.Name,
+		"on":          string(h.On),
+		"exit_code":   strconv.Itoa(res.ExitCode),
+		"duration_ms": strconv.FormatInt(res.Duration.Milliseconds(), 10),
+	}
+	if res.Err == nil {
+		logger.Info("hook ran", "hook", h.Name, "on", h.On, "duration", res.Duration)
+		state.RecordEvent(core.EventOrchestration, "hook "+h.Name+" ran on "+string(h.On), data)
+		return
+	}
+	msg := "hook " + h.Name + " failed on " + string(h.On) + ": " + res.Err.Error()
+	if last := lastLine(res.Output); last != "" {
+		data["output"] = last
+		msg += ": " + last
+	}
+	logger.Warn("hook failed", "hook", h.Name, "on", h.On, "err", res.Err, "output", res.Output)
+	state.RecordEvent(core.EventWarning, msg, data)
+}
+
+// lastLine returns the last non-empty line of s.
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return strings.TrimSpace(s[strings.LastIndexByte(s, '\n')+1:])
+}
+
+// tailBuffer keeps the last maxOutput bytes written to it.
+type tailBuffer struct {
+	b []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.b = append(t.b, p...)
+	if over := len(t.b) - maxOutput; over > 0 {
+		t.b = append(t.b[:0], t.b[over:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string { return string(t.b) }
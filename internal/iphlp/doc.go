@@ -0,0 +1,15 @@
+// Package iphlp changes Windows routes, addresses, and interface MTUs
+// through the IP Helper API (iphlpapi.dll), the Windows counterpart of
+// package rtnl.
+//
+// Routes are MIB_IPFORWARD_ROW2 entries in the routing table. Windows
+// keys a route by interface, prefix, and next hop, so ReplaceRoute first
+// deletes every route for the prefix and then creates the new one,
+// matching "ip route replace". A route without an interface is placed on
+// the interface that reaches its gateway (GetBestInterfaceEx). AddAddr and
+// SetMTU configure the wintun adapter (see package tundev). Routes reads
+// the table for package netinfo.
+//
+// All changes need an elevated (administrator) process. The package
+// builds on Windows only.
+package iphlp
@@ -0,0 +1,235 @@
+//go:build windows
+
+package iphlp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Functions x/sys/windows does not wrap.
+var (
+	modiphlpapi                         = windows.NewLazySystemDLL("iphlpapi.dll")
+	procInitializeIpForwardEntry        = modiphlpapi.NewProc("InitializeIpForwardEntry")
+	procCreateIpForwardEntry2           = modiphlpapi.NewProc("CreateIpForwardEntry2")
+	procDeleteIpForwardEntry2           = modiphlpapi.NewProc("DeleteIpForwardEntry2")
+	procInitializeUnicastIpAddressEntry = modiphlpapi.NewProc("InitializeUnicastIpAddressEntry")
+	procCreateUnicastIpAddressEntry     = modiphlpapi.NewProc("CreateUnicastIpAddressEntry")
+	procSetIpInterfaceEntry             = modiphlpapi.NewProc("SetIpInterfaceEntry")
+)
+
+// Route is a route to install or delete.
+type Route struct {
+	Dst netip.Prefix
+	Via netip.Addr // invalid: on-link via Dev
+	Dev string     // "" picks the interface that reaches Via
+}
+
+// ReplaceRoute installs rt, first deleting every route for the same
+// prefix on any interface, so it replaces whatever route the prefix had.
+func ReplaceRoute(rt Route) error {
+	row, err := routeRow(rt)
+	if err != nil {
+		return err
+	}
+	if err := deleteRoutes(rt.Dst.Masked(), netip.Addr{}, 0); err != nil && !errors.Is(err, windows.ERROR_NOT_FOUND) {
+		return fmt.Errorf("replace route %s: %w", rt.Dst, err)
+	}
+	if err := call(procCreateIpForwardEntry2, uintptr(unsafe.Pointer(&row))); err != nil {
+		return fmt.Errorf("replace route %s: %w", rt.Dst, err)
+	}
+	return nil
+}
+
+// DeleteRoute removes the routes for rt's prefix, limited to its gateway
+// and interface when set. No match is ERROR_NOT_FOUND.
+func DeleteRoute(rt Route) error {
+	var idx uint32
+	if rt.Dev != "" {
+		i, err := index(rt.Dev)
+		if err != nil {
+			return err
+		}
+		idx = i
+	}
+	if err := deleteRoutes(rt.Dst.Masked(), rt.Via, idx); err != nil {
+		return fmt.Errorf("delete route %s: %w", rt.Dst, err)
+	}
+	return nil
+}
+
+// Routes returns the routing table for one family.
+func Routes(v6 bool) ([]windows.MibIpForwardRow2, error) {
+	var table *windows.MibIpForwardTable2
+	if err := windows.GetIpForwardTable2(family(v6), &table); err != nil {
+		return nil, fmt.Errorf("GetIpForwardTable2: %w", err)
+	}
+	defer windows.FreeMibTable(unsafe.Pointer(table))
+	return append([]windows.MibIpForwardRow2(nil), table.Rows()...), nil
+}
+
+// deleteRoutes deletes the routes for dst matching via and the interface
+// index idx when those are set.
+func deleteRoutes(dst netip.Prefix, via netip.Addr, idx uint32) error {
+	rows, err := Routes(dst.Addr().Is6())
+	if err != nil {
+		return err
+	}
+	found := false
+	for i := range rows {
+		r := &rows[i]
+		if Prefix(&r.DestinationPrefix) != dst ||
+			(via.IsValid() && Addr(&r.NextHop) != via) ||
+			(idx != 0 && r.InterfaceIndex != idx) {
+			continue
+		}
+		if err := call(procDeleteIpForwardEntry2, uintptr(unsafe.Pointer(r))); err != nil {
+			return err
+		}
+		found = true
+	}
+	if !found {
+		return windows.ERROR_NOT_FOUND
+	}
+	return nil
+}
+
+// routeRow fills a MIB_IPFORWARD_ROW2 for rt.
+func routeRow(rt Route) (windows.MibIpForwardRow2, error) {
+	var row windows.MibIpForwardRow2
+	if !rt.Dst.IsValid() {
+		return row, errors.New("route without destination")
+	}
+	procInitializeIpForwardEntry.Call(uintptr(unsafe.Pointer(&row)))
+	dst := rt.Dst.Masked()
+	row.DestinationPrefix.Prefix = sockaddr(dst.Addr())
+	row.DestinationPrefix.PrefixLength = uint8(dst.Bits())
+	if rt.Via.IsValid() {
+		if rt.Via.Is4() != dst.Addr().Is4() {
+			return row, fmt.Errorf("route %s via %s: address families differ", dst, rt.Via)
+		}
+		row.NextHop = sockaddr(rt.Via)
+	} else {
+		row.NextHop = sockaddr(unspecified(dst.Addr().Is6()))
+	}
+	switch {
+	case rt.Dev != "":
+		idx, err := index(rt.Dev)
+		if err != nil {
+			return row, err
+		}
+		row.InterfaceIndex = idx
+	case rt.Via.IsValid():
+		var sa windows.Sockaddr = &windows.SockaddrInet4{Addr: rt.Via.As4()}
+		if rt.Via.Is6() {
+			sa = &windows.SockaddrInet6{Addr: rt.Via.As16()}
+		}
+		if err := windows.GetBestInterfaceEx(sa, &row.InterfaceIndex); err != nil {
+			return row, fmt.Errorf("no interface reaches %s: %w", rt.Via, err)
+		}
+	default:
+		return row, fmt.Errorf("route %s: needs a gateway or an interface", dst)
+	}
+	row.Protocol = windows.MIB_IPPROTO_NETMGMT
+	return row, nil
+}
+
+// AddAddr assigns local (address and on-link prefix) to the interface
+// with luid. An address already present is left as it is.
+func AddAddr(luid uint64, local netip.Prefix) error {
+	var row windows.MibUnicastIpAddressRow
+	procInitializeUnicastIpAddressEntry.Call(uintptr(unsafe.Pointer(&row)))
+	sa := sockaddr(local.Addr())
+	row.Address = *(*windows.RawSockaddrInet6)(unsafe.Pointer(&sa))
+	row.InterfaceLuid = luid
+	row.OnLinkPrefixLength = uint8(local.Bits())
+	row.DadState = 4 // IpDadStatePreferred
+	err := call(procCreateUnicastIpAddressEntry, uintptr(unsafe.Pointer(&row)))
+	if err != nil && !errors.Is(err, windows.ERROR_OBJECT_ALREADY_EXISTS) {
+		return fmt.Errorf("add address %s: %w", local, err)
+	}
+	return nil
+}
+
+// SetMTU sets the IP MTU of the interface with luid for one family.
+func SetMTU(luid uint64, v6 bool, mtu int) error {
+	row := windows.MibIpInterfaceRow{Family: family(v6), InterfaceLuid: luid}
+	if err := windows.GetIpInterfaceEntry(&row); err != nil {
+		return fmt.Errorf("GetIpInterfaceEntry: %w", err)
+	}
+	row.NlMtu = uint32(mtu)
+	row.SitePrefixLength = 0 // must be 0 for IPv4 when setting
+	if err := call(procSetIpInterfaceEntry, uintptr(unsafe.Pointer(&row))); err != nil {
+		return fmt.Errorf("set mtu %d: %w", mtu, err)
+	}
+	return nil
+}
+
+// Prefix converts an IP_ADDRESS_PREFIX.
+func Prefix(p *windows.IpAddressPrefix) netip.Prefix {
+	return netip.PrefixFrom(Addr(&p.Prefix), int(p.PrefixLength))
+}
+
+// Addr converts a SOCKADDR_INET, dropping any zone.
+func Addr(sa *windows.RawSockaddrInet) netip.Addr {
+	switch sa.Family {
+	case windows.AF_INET:
+		return netip.AddrFrom4((*windows.RawSockaddrInet4)(unsafe.Pointer(sa)).Addr)
+	case windows.AF_INET6:
+		return netip.AddrFrom16((*windows.RawSockaddrInet6)(unsafe.Pointer(sa)).Addr)
+	}
+	return netip.Addr{}
+}
+
+func sockaddr(a netip.Addr) windows.RawSockaddrInet {
+	var sa windows.RawSockaddrInet
+	if a.Is4() {
+		sa4 := (*windows.RawSockaddrInet4)(unsafe.Pointer(&sa))
+		sa4.Family = windows.AF_INET
+		sa4.Addr = a.As4()
+	} else {
+		sa6 := (*windows.RawSockaddrInet6)(unsafe.Pointer(&sa))
+		sa6.Family = windows.AF_INET6
+		sa6.Addr = a.As16()
+	}
+	return sa
+}
+
+func unspecified(v6 bool) netip.Addr {
+	if v6 {
+		return netip.IPv6Unspecified()
+	}
+	return netip.IPv4Unspecified()
+}
+
+func family(v6 bool) uint16 {
+	if v6 {
+		return windows.AF_INET6
+	}
+	return windows.AF_INET
+}
+
+// index resolves an interface name (its alias, e.g. "Ethernet").
+func index(name string) (uint32, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(ifi.Index), nil
+}
+
+// call invokes an iphlpapi function that returns a Win32 error code.
+func call(proc *windows.LazyProc, args ...uintptr) error {
+	if err := proc.Find(); err != nil {
+		return err
+	}
+	if r, _, _ := proc.Call(args...); r != 0 {
+		return windows.Errno(r)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package ipv6leak
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// blackholes cover all of IPv6 and, being more specific than the default
+// route, win over it; on-link and link-local routes stay usable.
+var blackholes = []string{"::/1", "8000::/1"}
+
+// Block installs the blackhole routes with "route add -blackhole".
+func Block() error {
+	for _, dst := range blackholes {
+		if err := route("add", dst, "::1", "-blackhole"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unblock removes the blackhole routes, returning the first failure.
+func Unblock() error {
+	var first error
+	for _, dst := range blackholes {
+		if err := route("delete", dst); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func route(cmd, dst string, extra ...string) error {
+	args := append([]string{"-n", cmd, "-inet6", "-net", dst}, extra...)
+	if out, err := exec.Command("route", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("route %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package ipv6leak
+
+import (
+	"net/netip"
+
+	"github.com/sanverite/simple-packet-logger/internal/rtnl"
+)
+
+// blackholes cover all of IPv6 and, being more specific than ::/0, win
+// over every default route; on-link and link-local routes stay usable.
+var blackholes = []netip.Prefix{netip.MustParsePrefix("::/1"), netip.MustParsePrefix("8000::/1")}
+
+// Block installs the blackhole routes over rtnetlink, replacing any route
+// the prefixes have.
+func Block() error {
+	for _, dst := range blackholes {
+		if err := rtnl.ReplaceRoute(rtnl.Route{Dst: dst, Blackhole: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unblock removes the blackhole routes, returning the first failure.
+func Unblock() error {
+	var first error
+	for _, dst := range blackholes {
+		if err := rtnl.DeleteRoute(rtnl.Route{Dst: dst, Blackhole: true}); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
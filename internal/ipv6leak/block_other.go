@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package ipv6leak
+
+// Block returns ErrUnsupported.
+func Block() error { return ErrUnsupported }
+
+// Unblock returns ErrUnsupported.
+func Unblock() error { return ErrUnsupported }
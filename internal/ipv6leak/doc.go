@@ -0,0 +1,28 @@
+// Package ipv6leak detects IPv6 traffic escaping an IPv4-only tunnel and,
+// with the kill switch, blocks it.
+//
+// # Detection
+//
+// When the tunnel does not carry IPv6 (the proxy lacks it, or the start
+// did not ask for it), applications still reach IPv6 destinations over
+// the host's own IPv6 default route, bypassing the proxy. Scan lists every
+// IPv6 default route outside the TUN whose interface has a global unicast
+// address; unique local addresses are ignored. A Monitor runs the scan
+// every 5s while a tunnel is up and reports leak and clear transitions.
+//
+// # Kill Switch
+//
+// With Options.KillSwitch, the first leak found blocks IPv6 until the
+// tunnel stops (or starts carrying IPv6); Release lifts it when the agent
+// exits. When the host has a firewall backend (see package firewall), the
+// agent installs FirewallRules as the FirewallSet set: IPv6 through the
+// TUN, to loopback, link-local, and multicast passes, and the rest is
+// dropped. Without one, Block installs blackhole routes for ::/1 and
+// 8000::/1 instead. They are more specific than any default route, so all
+// IPv6 to the internet fails fast while on-link and link-local traffic
+// keeps working.
+//
+//   - linux:  blackhole routes over rtnetlink (see package rtnl).
+//   - darwin: "route add -inet6 ... -blackhole".
+//   - others: ErrUnsupported; leaks are still reported.
+package ipv6leak
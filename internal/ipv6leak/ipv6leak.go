@@ -0,0 +1,263 @@
+package ipv6leak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/firewall"
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// DefaultInterval is the pause between checks.
+const DefaultInterval = 5 * time.Second
+
+// ErrUnsupported is returned by Block and Unblock on platforms without a
+// kill switch.
+var ErrUnsupported = errors.New("ipv6 kill switch not supported on this platform")
+
+// States of the monitor.
+const (
+	StateOff     = "off"     // no tunnel, or the tunnel carries IPv6
+	StateClear   = "clear"   // IPv4-only tunnel and no IPv6 egress outside it
+	StateLeak    = "leak"    // IPv6 egress outside an IPv4-only tunnel
+	StateBlocked = "blocked" // the kill switch blocks IPv6 until the tunnel stops
+)
+
+// Egress is a global IPv6 default route outside the tunnel and the
+// global addresses its interface could send from.
+type Egress struct {
+	Interface string
+	Gateway   string // "" for an interface-only route
+	Addrs     []string
+}
+
+// String renders e as "en0 via fe80::1 (2001:db8::5)".
+func (e Egress) String() string {
+	s := e.Interface
+	if e.Gateway != "" {
+		s += " via " + e.Gateway
+	}
+	return s + " (" + strings.Join(e.Addrs, ", ") + ")"
+}
+
+// Scan lists IPv6 egress outside the interface tun: default routes whose
+// interface has a global unicast address. Unique local (fc00::/7)
+// addresses do not reach the internet and are ignored.
+func Scan(tun string) ([]Egress, error) {
+	routes, err := netinfo.GetDefaultRoutes6()
+	if err != nil {
+		return nil, err
+	}
+	ifaces, err := netinfo.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	return scan(tun, routes, ifaces), nil
+}
+
+func scan(tun string, routes []netinfo.Route, ifaces []netinfo.Interface) []Egress {
+	addrs := make(map[string][]string)
+	for _, ifi := range ifaces {
+		for _, p := range ifi.Prefixes {
+			if a := p.Addr(); a.Is6() && a.IsGlobalUnicast() && !ula.Contains(a) {
+				addrs[ifi.Name] = append(addrs[ifi.Name], a.String())
+			}
+		}
+	}
+	var out []Egress
+	for _, rt := range routes {
+		if rt.Interface == "" || rt.Interface == tun || len(addrs[rt.Interface]) == 0 {
+			continue
+		}
+		e := Egress{Interface: rt.Interface, Addrs: addrs[rt.Interface]}
+		if rt.Gateway.IsValid() {
+			e.Gateway = rt.Gateway.String()
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// ula is the unique local range (RFC 4193).
+var ula = netip.MustParsePrefix("fc00::/7")
+
+// Options configures a Monitor.
+type Options struct {
+	// Tunnel reports the TUN's name, whether a tunnel is up, and whether
+	// it carries IPv6. Required.
+	Tunnel func() (tun string, active, ipv6 bool)
+	// KillSwitch blocks IPv6 outside the tunnel when a leak is found,
+	// until the tunnel stops.
+	KillSwitch bool
+	// Interval is the pause between checks in Run (default
+	// DefaultInterval).
+	Interval time.Duration
+	// Scan, Block, and Unblock default to the package functions; the
+	// agent points Block and Unblock at the firewall when it has one (see
+	// FirewallRules).
+	Scan    func(tun string) ([]Egress, error)
+	Block   func() error
+	Unblock func() error
+	// OnChange, when set, is called when the state changes.
+	OnChange func(prev, next Status)
+	// Logger receives monitor records. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Status is the monitor's state.
+type Status struct {
+	State      string
+	Egress     []Egress // uncovered IPv6 egress found by the latest check
+	KillSwitch bool
+	BlockErr   string // why the kill switch could not block
+	Err        string // why the latest check failed; the state is kept
+	Checked    time.Time
+	Since      time.Time // when State last changed
+}
+
+// Monitor watches for IPv6 egress outside an IPv4-only tunnel.
+type Monitor struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	status  Status
+	blocked bool
+}
+
+// New constructs a monitor; call Check or Run.
+func New(opts Options) *Monitor {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Scan == nil {
+		opts.Scan = Scan
+	}
+	if opts.Block == nil {
+		opts.Block = Block
+	}
+	if opts.Unblock == nil {
+		opts.Unblock = Unblock
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Monitor{opts: opts, logger: logger, status: Status{State: StateOff, KillSwitch: opts.KillSwitch}}
+}
+
+// Status returns the result of the latest check.
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.status
+	st.Egress = append([]Egress(nil), st.Egress...)
+	return st
+}
+
+// Check looks for uncovered IPv6 egress once, blocking it when the kill
+// switch is on, and lifts the block once the tunnel is down or carries
+// IPv6.
+func (m *Monitor) Check() Status {
+	tun, active, ipv6 := m.opts.Tunnel()
+	m.mu.Lock()
+	prev := m.status
+	next := Status{State: prev.State, KillSwitch: m.opts.KillSwitch, Checked: time.Now()}
+	switch {
+	case !active || ipv6:
+		if m.blocked {
+			if err := m.opts.Unblock(); err != nil {
+				m.logger.Warn("ipv6 unblock failed", "err", err)
+				next.BlockErr = "unblock: " + err.Error()
+			}
+			m.blocked = false
+		}
+		next.State = StateOff
+	default:
+		egress, err := m.opts.Scan(tun)
+		if err != nil {
+			next.Err = err.Error()
+			next.Egress = prev.Egress
+			break
+		}
+		next.Egress = egress
+		switch {
+		case m.blocked:
+			next.State = StateBlocked
+		case len(egress) == 0:
+			next.State = StateClear
+		case !m.opts.KillSwitch:
+			next.State = StateLeak
+		default:
+			if err := m.opts.Block(); err != nil {
+				next.State = StateLeak
+				next.BlockErr = err.Error()
+				break
+			}
+			m.blocked = true
+			next.State = StateBlocked
+		}
+	}
+	next.Since = prev.Since
+	if next.State != prev.State {
+		next.Since = next.Checked
+	}
+	m.status = next
+	m.mu.Unlock()
+
+	if next.State != prev.State {
+		m.logger.Info("ipv6 leak state changed", "from", prev.State, "to", next.State, "egress", fmt.Sprint(next.Egress), "block_error", next.BlockErr)
+		if m.opts.OnChange != nil {
+			m.opts.OnChange(prev, next)
+		}
+	}
+	return next
+}
+
+// Release lifts a kill switch block, e.g. when the agent exits.
+func (m *Monitor) Release() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.blocked {
+		return nil
+	}
+	m.blocked = false
+	return m.opts.Unblock()
+}
+
+// Run checks every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) {
+	t := time.NewTicker(m.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.Check()
+		}
+	}
+}
+
+// FirewallSet names the kill switch's rule set in the host firewall.
+const FirewallSet = "ipv6_leak"
+
+// FirewallRules is the kill switch as firewall rules: IPv6 may leave
+// through tun, to link-local and multicast destinations, and to loopback;
+// everything else IPv6 is dropped.
+func FirewallRules(tun string) []firewall.Rule {
+	var rules []firewall.Rule
+	if tun != "" {
+		rules = append(rules, firewall.Rule{Action: firewall.ActionAccept, Family: firewall.FamilyIPv6, Out: tun, Comment: "tunnel"})
+	}
+	for _, dst := range []string{"::1/128", "fe80::/10", "ff00::/8"} {
+		rules = append(rules, firewall.Rule{Action: firewall.ActionAccept, Dst: netip.MustParsePrefix(dst), Comment: "local"})
+	}
+	return append(rules, firewall.Rule{Action: firewall.ActionDrop, Family: firewall.FamilyIPv6, Comment: "ipv6 kill switch"})
+}
@@ -0,0 +1,14 @@
+// Package launchd installs the agent as a macOS launch daemon, the
+// counterpart of the systemd unit and package winsvc.
+//
+// A Job renders the property list launchd reads from DaemonDir: the job
+// starts at boot, is restarted when it exits with an error, and gets
+// ExitTimeOut seconds after SIGTERM to restore routes and resolvers.
+// WritePlist and RemovePlist manage the file; Load, Unload, and Query
+// drive launchctl in the system domain and return ErrUnsupported off
+// macOS. Under reports whether launchd started this process as a given
+// label, from the XPC_SERVICE_NAME it sets in the job's environment.
+//
+// Writing to DaemonDir and bootstrapping into the system domain need
+// root.
+package launchd
@@ -0,0 +1,75 @@
+//go:build darwin
+
+package launchd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Query reports whether label's plist is installed and how launchd runs
+// it ("launchctl print system/<label>").
+func Query(label string) (Status, error) {
+	if err := ValidLabel(label); err != nil {
+		return Status{}, err
+	}
+	st := Status{Label: label, PlistPath: PlistPath(label)}
+	if _, err := os.Stat(st.PlistPath); err == nil {
+		st.Installed = true
+	}
+	out, err := exec.Command("launchctl", "print", "system/"+label).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return st, nil // not loaded
+	}
+	if err != nil {
+		return st, fmt.Errorf("launchctl print: %w", err)
+	}
+	st.Loaded = true
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		// Top-level properties are indented by one tab: "\tpid = 123".
+		line := sc.Text()
+		if !strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "\t\t") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimSpace(line), " = ")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "state":
+			st.State = v
+		case "pid":
+			st.PID, _ = strconv.Atoi(v)
+		}
+	}
+	return st, nil
+}
+
+// Load bootstraps the plist at path into the system domain.
+func Load(path string) error {
+	return launchctl("bootstrap", "system", path)
+}
+
+// Unload boots label out of the system domain, stopping it.
+func Unload(label string) error {
+	if err := ValidLabel(label); err != nil {
+		return err
+	}
+	return launchctl("bootout", "system/"+label)
+}
+
+func launchctl(args ...string) error {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
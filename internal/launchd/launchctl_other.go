@@ -0,0 +1,12 @@
+//go:build !darwin
+
+package launchd
+
+// Query returns ErrUnsupported.
+func Query(string) (Status, error) { return Status{}, ErrUnsupported }
+
+// Load returns ErrUnsupported.
+func Load(string) error { return ErrUnsupported }
+
+// Unload returns ErrUnsupported.
+func Unload(string) error { return ErrUnsupported }
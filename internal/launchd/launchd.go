@@ -0,0 +1,153 @@
+package launchd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultLabel is the agent's job label.
+const DefaultLabel = "com.sanverite.simple-packet-logger"
+
+// DaemonDir holds system-wide daemon plists, loaded at boot as root.
+const DaemonDir = "/Library/LaunchDaemons"
+
+// DefaultLogPath receives the agent's stdout and stderr under launchd.
+const DefaultLogPath = "/Library/Logs/simple-packet-logger/agent.log"
+
+// exitTimeout is how long launchd waits after SIGTERM before SIGKILL,
+// long enough for the agent to restore routes and resolvers.
+const exitTimeout = 30
+
+// ErrUnsupported is returned by the launchctl operations off macOS.
+var ErrUnsupported = errors.New("launchd not available on this platform")
+
+// Job is a launchd daemon running the agent.
+type Job struct {
+	Label   string
+	Program string   // absolute path of the executable
+	Args    []string // arguments after the program
+	LogPath string   // stdout and stderr; default DefaultLogPath
+}
+
+// Status describes the job as launchd sees it.
+type Status struct {
+	Label     string
+	PlistPath string
+	Installed bool // the plist exists
+	Loaded    bool // launchd knows the job
+	State     string
+	PID       int // 0 when not running
+}
+
+// PlistPath returns where the plist for label lives.
+func PlistPath(label string) string {
+	return filepath.Join(DaemonDir, label+".plist")
+}
+
+// Under reports whether this process was started by launchd as label.
+func Under(label string) bool {
+	return os.Getenv("XPC_SERVICE_NAME") == label
+}
+
+// ValidLabel checks a reverse-DNS job label.
+func ValidLabel(label string) error {
+	if label == "" || len(label) > 128 || strings.HasPrefix(label, ".") {
+		return fmt.Errorf("invalid label %q", label)
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return fmt.Errorf("invalid label %q", label)
+		}
+	}
+	return nil
+}
+
+// Plist renders the job's property list. The job starts at boot and is
+// restarted when it exits with an error, not after a clean shutdown.
+func (j Job) Plist() []byte {
+	logPath := j.LogPath
+	if logPath == "" {
+		logPath = DefaultLogPath
+	}
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	key := func(k string) { fmt.Fprintf(&b, "\t<key>%s</key>\n", k) }
+	str := func(indent, s string) {
+		b.WriteString(indent + "<string>")
+		_ = xml.EscapeText(&b, []byte(s))
+		b.WriteString("</string>\n")
+	}
+	key("Label")
+	str("\t", j.Label)
+	key("ProgramArguments")
+	b.WriteString("\t<array>\n")
+	for _, a := range append([]string{j.Program}, j.Args...) {
+		str("\t\t", a)
+	}
+	b.WriteString("\t</array>\n")
+	key("RunAtLoad")
+	b.WriteString("\t<true/>\n")
+	key("KeepAlive")
+	b.WriteString("\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	key("ExitTimeOut")
+	fmt.Fprintf(&b, "\t<integer>%d</integer>\n", exitTimeout)
+	key("StandardOutPath")
+	str("\t", logPath)
+	key("StandardErrorPath")
+	str("\t", logPath)
+	b.WriteString("</dict>\n</plist>\n")
+	return b.Bytes()
+}
+
+// WritePlist validates j and writes its plist to PlistPath (mode 0644,
+// replaced atomically), creating the log directory. It needs root.
+func WritePlist(j Job) (string, error) {
+	if err := ValidLabel(j.Label); err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(j.Program) {
+		return "", fmt.Errorf("program %q is not absolute", j.Program)
+	}
+	for _, a := range append([]string{j.Program, j.LogPath}, j.Args...) {
+		if strings.ContainsRune(a, 0) {
+			return "", errors.New("argument contains NUL")
+		}
+	}
+	logPath := j.LogPath
+	if logPath == "" {
+		logPath = DefaultLogPath
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		return "", err
+	}
+	path := PlistPath(j.Label)
+	tmp, err := os.CreateTemp(DaemonDir, "."+j.Label+"-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(j.Plist())
+	if err = errors.Join(err, tmp.Chmod(0o644), tmp.Close()); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+// RemovePlist deletes the plist for label; a missing one is not an
+// error.
+func RemovePlist(label string) error {
+	if err := ValidLabel(label); err != nil {
+		return err
+	}
+	if err := os.Remove(PlistPath(label)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
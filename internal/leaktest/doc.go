@@ -0,0 +1,25 @@
+// Package leaktest checks actively whether DNS queries escape the tunnel.
+//
+// # Method
+//
+// Run looks up a few uniquely tagged names (spl-<random>-<n>.example.com)
+// through the system resolver, exactly as an application would. The tag
+// defeats every cache, so each name must reach a resolver that talks to
+// the internet. The tunnel's DNS forwarder hands every relayed answer to
+// an Observer; a name that was answered (NXDOMAIN counts) but never
+// relayed was resolved by something outside the tunnel: a leak.
+//
+// # Verdicts
+//
+//   - no_leak: every answered name passed the forwarder.
+//   - leak: at least one answered name did not.
+//   - inconclusive: no forwarder is running with the system resolvers
+//     pointed at it, or no name was answered (DNS is down or blocked).
+//
+// # Evidence
+//
+// Each query reports its answer, error, latency, and whether the
+// forwarder saw it. A TXT lookup of o-o.myaddr.l.google.com adds the
+// address of the resolver that actually reached the internet, which with
+// the tunnel up should belong to the DNS upstream, not to the local ISP.
+package leaktest
@@ -0,0 +1,227 @@
+package leaktest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDomain is the zone tagged names are made under. Its name servers
+// answer NXDOMAIN for them, which counts as an answer.
+const DefaultDomain = "example.com"
+
+// DefaultWhoAmI is a name whose TXT answer is the address of the resolver
+// that asked Google's name servers.
+const DefaultWhoAmI = "o-o.myaddr.l.google.com"
+
+// Query counts.
+const (
+	DefaultQueries = 3
+	MaxQueries     = 10
+)
+
+// queryTimeout bounds one lookup.
+const queryTimeout = 3 * time.Second
+
+// Verdicts of a test.
+const (
+	VerdictNoLeak       = "no_leak"      // every answered query passed the forwarder
+	VerdictLeak         = "leak"         // a query was answered without passing the forwarder
+	VerdictInconclusive = "inconclusive" // nothing observes the queries, or none was answered
+)
+
+// Options configures one test.
+type Options struct {
+	// Domain is the zone tagged names are made under (default
+	// DefaultDomain).
+	Domain string
+	// Queries is the number of tagged names looked up (default
+	// DefaultQueries, at most MaxQueries).
+	Queries int
+	// Resolver looks the names up (default net.DefaultResolver, which
+	// follows the system's resolver configuration like any application).
+	Resolver *net.Resolver
+	// Observer sees the queries the tunnel's DNS forwarder relays. Nil,
+	// or Forwarding false, makes the verdict inconclusive.
+	Observer *Observer
+	// Forwarding reports that the forwarder is running and the system
+	// resolvers point at it.
+	Forwarding bool
+	// WhoAmI, when set, is looked up for TXT to name the resolver that
+	// reached the internet (see DefaultWhoAmI).
+	WhoAmI string
+}
+
+// Query is one tagged lookup and whether the forwarder relayed it.
+type Query struct {
+	Name     string
+	Answered bool     // an answer came back, NXDOMAIN included
+	Addrs    []string // addresses answered
+	Error    string   // lookup error; NXDOMAIN is "no such host"
+	Observed bool     // the tunnel's forwarder relayed the query
+	Latency  time.Duration
+}
+
+// Result is a test's verdict and evidence.
+type Result struct {
+	Verdict   string
+	Reason    string
+	Queries   []Query
+	Resolvers []string // resolver addresses from the WhoAmI answer
+	WhoAmIErr string
+	Elapsed   time.Duration
+}
+
+// Run looks up uniquely tagged names through the system resolver and
+// checks each against what the forwarder relayed: a name answered but
+// never relayed reached some resolver outside the tunnel.
+func Run(ctx context.Context, opts Options) (Result, error) {
+	domain := strings.Trim(opts.Domain, ".")
+	if domain == "" {
+		domain = DefaultDomain
+	}
+	if !validName(domain) {
+		return Result{}, fmt.Errorf("invalid domain %q", opts.Domain)
+	}
+	n := opts.Queries
+	if n == 0 {
+		n = DefaultQueries
+	}
+	if n < 1 || n > MaxQueries {
+		return Result{}, fmt.Errorf("queries must be 1..%d", MaxQueries)
+	}
+	resolver := opts.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	tag, err := newTag()
+	if err != nil {
+		return Result{}, err
+	}
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("spl-%s-%d.%s", tag, i, domain)
+	}
+
+	start := time.Now()
+	var w *watch
+	if opts.Observer != nil {
+		w = opts.Observer.watch(names)
+		defer opts.Observer.unwatch(w)
+	}
+	res := Result{Queries: make([]Query, n)}
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Go(func() { res.Queries[i] = lookup(ctx, resolver, name) })
+	}
+	if opts.WhoAmI != "" {
+		wg.Go(func() { res.Resolvers, res.WhoAmIErr = whoAmI(ctx, resolver, opts.WhoAmI) })
+	}
+	wg.Wait()
+	res.Elapsed = time.Since(start)
+	if w != nil {
+		for i := range res.Queries {
+			res.Queries[i].Observed = w.seen(res.Queries[i].Name)
+		}
+	}
+
+	var answered, escaped int
+	for _, q := range res.Queries {
+		if q.Answered {
+			answered++
+			if !q.Observed {
+				escaped++
+			}
+		}
+	}
+	switch {
+	case opts.Observer == nil || !opts.Forwarding:
+		res.Verdict = VerdictInconclusive
+		res.Reason = "the dns forwarder is not running with the system resolvers pointed at it; nothing observes which resolver answered"
+	case answered == 0:
+		res.Verdict = VerdictInconclusive
+		res.Reason = "no tagged query was answered"
+	case escaped > 0:
+		res.Verdict = VerdictLeak
+		res.Reason = fmt.Sprintf("%d of %d answered queries never passed the tunnel's dns forwarder", escaped, answered)
+	default:
+		res.Verdict = VerdictNoLeak
+		res.Reason = fmt.Sprintf("all %d answered queries passed the tunnel's dns forwarder", answered)
+	}
+	return res, nil
+}
+
+// lookup resolves name once. The trailing dot keeps search domains out.
+func lookup(ctx context.Context, resolver *net.Resolver, name string) Query {
+	q := Query{Name: name}
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	start := time.Now()
+	addrs, err := resolver.LookupHost(ctx, name+".")
+	q.Latency = time.Since(start)
+	q.Addrs = addrs
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		q.Answered = true
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		q.Answered = true
+		q.Error = dnsErr.Err
+	default:
+		q.Error = err.Error()
+	}
+	return q
+}
+
+// whoAmI looks name up for TXT and keeps the addresses in the answer.
+func whoAmI(ctx context.Context, resolver *net.Resolver, name string) ([]string, string) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	txts, err := resolver.LookupTXT(ctx, strings.TrimSuffix(name, ".")+".")
+	if err != nil {
+		return nil, err.Error()
+	}
+	var out []string
+	for _, t := range txts {
+		for f := range strings.FieldsSeq(t) {
+			if a, err := netip.ParseAddr(f); err == nil {
+				out = append(out, a.Unmap().String())
+			}
+		}
+	}
+	return out, ""
+}
+
+// newTag returns a random label part unique to one test.
+func newTag() (string, error) {
+	var b [6]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// validName reports whether s is a plausible domain name.
+func validName(s string) bool {
+	if len(s) > 200 || !strings.Contains(s, ".") {
+		return false
+	}
+	for label := range strings.SplitSeq(s, ".") {
+		if label == "" || len(label) > 63 {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package leaktest
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync"
+)
+
+// dnsHeaderLen is the fixed DNS header size (RFC 1035).
+const dnsHeaderLen = 12
+
+// Observer records which tagged names the DNS forwarder relayed. Hand
+// ObserveDNS every answer the forwarder relays; it ignores names no test
+// is waiting for.
+type Observer struct {
+	mu      sync.Mutex
+	watches map[*watch]struct{}
+}
+
+// watch is one test's tagged names and whether each was seen.
+type watch struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+// NewObserver returns an observer with no test running.
+func NewObserver() *Observer {
+	return &Observer{watches: make(map[*watch]struct{})}
+}
+
+// ObserveDNS notes the question of a relayed DNS message. Malformed
+// messages are ignored.
+func (o *Observer) ObserveDNS(msg []byte) {
+	name, ok := questionName(msg)
+	if !ok {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for w := range o.watches {
+		w.mu.Lock()
+		if _, want := w.names[name]; want {
+			w.names[name] = true
+		}
+		w.mu.Unlock()
+	}
+}
+
+func (o *Observer) watch(names []string) *watch {
+	w := &watch{names: make(map[string]bool, len(names))}
+	for _, n := range names {
+		w.names[strings.ToLower(n)] = false
+	}
+	o.mu.Lock()
+	o.watches[w] = struct{}{}
+	o.mu.Unlock()
+	return w
+}
+
+func (o *Observer) unwatch(w *watch) {
+	o.mu.Lock()
+	delete(o.watches, w)
+	o.mu.Unlock()
+}
+
+func (w *watch) seen(name string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.names[strings.ToLower(name)]
+}
+
+// questionName returns the lower-cased name of msg's single question,
+// without the trailing dot. Question names are never compressed.
+func questionName(msg []byte) (string, bool) {
+	if len(msg) < dnsHeaderLen || binary.BigEndian.Uint16(msg[4:6]) != 1 {
+		return "", false
+	}
+	var labels []string
+	for off := dnsHeaderLen; off < len(msg); {
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return strings.ToLower(strings.Join(labels, ".")), len(labels) > 0
+		case l&0xc0 != 0 || off+1+l > len(msg):
+			return "", false
+		}
+		labels = append(labels, string(msg[off+1:off+1+l]))
+		off += 1 + l
+	}
+	return "", false
+}
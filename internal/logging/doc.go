@@ -0,0 +1,35 @@
+// Package logging builds the daemon's structured, leveled logger.
+//
+// # Overview
+//
+// All components log through log/slog. The root logger is constructed once in
+// cmd/agent from flags (level and output format) and handed to each package,
+// which derives a child logger tagged with a "component" attribute
+// (api, probe, core, orchestrator). This keeps records filterable without
+// each package inventing its own prefix scheme.
+//
+// # Formats
+//
+// - text: slog.TextHandler, key=value pairs suitable for terminals.
+// - json: slog.JSONHandler, one object per line suitable for log shippers.
+//
+// # Levels
+//
+// debug, info, warn, error (case-insensitive). Unknown values are rejected
+// by ParseLevel so misconfiguration surfaces at startup.
+//
+// # Redaction
+//
+// Loggers built by New pass every attribute, the message included, through
+// package redact: values of sensitive keys ("password", "token", ...) are
+// masked and strings and errors lose recognizable credentials. Callers
+// should still avoid logging secrets, since a password without context
+// cannot be recognized.
+//
+// # Request IDs
+//
+// The API tags each request's context with WithRequestID. Loggers built by
+// New add it as "request_id" to every record logged with that context, so
+// code on a request path logs with the *Context methods (or LogAttrs) to be
+// correlated with the request.
+package logging
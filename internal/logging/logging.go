@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// Component names used for the "component" attribute.
+const (
+	ComponentAPI          = "api"
+	ComponentProbe        = "probe"
+	ComponentCore         = "core"
+	ComponentOrchestrator = "orchestrator"
+)
+
+// Supported output formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configures the root logger.
+type Options struct {
+	// Level is one of debug, info, warn, error. Empty means info.
+	Level string
+	// Format is "text" or "json". Empty means text.
+	Format string
+	// Output receives log records. Nil means os.Stderr.
+	Output io.Writer
+}
+
+// New constructs the root logger from opts.
+// Returns an error for unknown levels or formats.
+func New(opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	hopts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+
+	var h slog.Handler
+	switch strings.ToLower(strings.TrimSpace(opts.Format)) {
+	case "", FormatText:
+		h = slog.NewTextHandler(out, hopts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(out, hopts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want text or json)", opts.Format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// redactAttr masks credentials in every record attribute, including the
+// message: sensitive keys lose their value, strings and errors go through
+// redact.String.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if redact.SensitiveKey(a.Key) {
+		return slog.String(a.Key, redact.Mask)
+	}
+	switch v := a.Value.Resolve(); v.Kind() {
+	case slog.KindString:
+		if s := redact.String(v.String()); s != v.String() {
+			return slog.String(a.Key, s)
+		}
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, redact.String(err.Error()))
+		}
+	}
+	return a
+}
+
+// ParseLevel maps a case-insensitive level name to a slog.Level.
+// Empty input yields slog.LevelInfo.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q (want debug, info, warn, or error)", s)
+	}
+}
+
+// Component returns a child logger tagged with the given component name.
+// A nil parent falls back to slog.Default().
+func Component(parent *slog.Logger, name string) *slog.Logger {
+	if parent == nil {
+		parent = slog.Default()
+	}
+	return parent.With("component", name)
+}
+
+// Discard returns a logger that drops every record. Useful as a default for
+// optional logger fields so call sites need no nil checks.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// requestIDKey carries a request ID in a context.
+type requestIDKey struct{}
+
+// WithRequestID returns ctx tagged with a request ID. Records logged with
+// that context (InfoContext, LogAttrs, ...) get a "request_id" attribute.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID in ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds context-scoped attributes (the request ID) to records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
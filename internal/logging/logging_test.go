@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+func TestRedactsAttributes(t *testing.T) {
+	const password = "hunter22"
+	tests := []struct {
+		name string
+		msg  string
+		args []any
+	}{
+		{"sensitive key", "probe", []any{"password", password}},
+		{"string value", "probe", []any{"target", "socks5://alice:" + password + "@proxy:1080"}},
+		{"error value", "probe failed", []any{"err", errors.New("auth password=" + password + " rejected")}},
+		{"message", "dial socks5://alice:" + password + "@proxy:1080 failed", nil},
+	}
+	for _, format := range []string{FormatText, FormatJSON} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				var buf bytes.Buffer
+				logger, err := New(Options{Format: format, Output: &buf})
+				if err != nil {
+					t.Fatal(err)
+				}
+				logger.Info(tt.msg, tt.args...)
+				out := buf.String()
+				if strings.Contains(out, password) {
+					t.Errorf("log line contains the password: %s", out)
+				}
+				if !strings.Contains(out, redact.Mask) {
+					t.Errorf("log line lacks %s: %s", redact.Mask, out)
+				}
+			})
+		}
+	}
+}
+
+func TestRedactsWithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(Options{Output: &buf})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.With("token", "spl_0123456789abcdefXYZ").Info("request")
+	if out := buf.String(); strings.Contains(out, "spl_0123456789abcdefXYZ") || !strings.Contains(out, redact.Mask) {
+		t.Errorf("With attribute not redacted: %s", out)
+	}
+}
@@ -0,0 +1,32 @@
+// Package metrics holds in-process counters for the control plane.
+//
+// # Overview
+//
+// The metrics package is a small, dependency-free registry. Components record
+// observations through narrow methods (e.g., ObserveRequest) and readers take
+// a deep-copied Snapshot, mirroring the core.State read model. There is no
+// exposition format baked in; the api package maps snapshots to JSON.
+//
+// # Concurrency & Safety
+//
+// Registry is safe for concurrent use. Observations hold the internal lock
+// briefly; Snapshot returns copies that callers may retain freely.
+//
+// # Request Counters
+//
+// Requests are keyed by route (the ServeMux pattern that matched, or
+// "unmatched") and track total count, bytes written, cumulative duration,
+// and a per-status-code breakdown.
+//
+// # Gauges
+//
+// InFlight counts requests currently inside a handler and OpenConns counts
+// client connections across all listeners (hijacked WebSocket connections
+// leave the gauge once hijacked). Both are read during shutdown to report
+// what had to be drained.
+//
+// # Route Repairs
+//
+// ObserveRouteRepair counts routes the route repairer re-applied after
+// they drifted, and the attempts that failed (see package routerepair).
+package metrics
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UnmatchedRoute labels requests that did not match any registered route.
+const UnmatchedRoute = "unmatched"
+
+// BudgetKind names the endpoint budget a request exceeded.
+type BudgetKind string
+
+const (
+	BudgetTime     BudgetKind = "time"     // handler ran past its deadline
+	BudgetBody     BudgetKind = "body"     // request body too large
+	BudgetResponse BudgetKind = "response" // response body too large
+)
+
+// RouteStats aggregates requests observed for a single route.
+type RouteStats struct {
+	Count      int64                // Total requests served
+	Bytes      int64                // Total response body bytes written
+	Duration   time.Duration        // Cumulative handler duration
+	ByStatus   map[int]int64        // Request count per HTTP status code
+	LastStatus int                  // Status code of the most recent request
+	Budget     map[BudgetKind]int64 // Budget violations by kind
+}
+
+// Snapshot is a point-in-time copy of all counters.
+type Snapshot struct {
+	StartedAt time.Time
+	Routes    map[string]RouteStats
+	InFlight  int64 // Requests currently being handled
+	OpenConns int64 // Client connections currently open (all listeners)
+
+	RouteRepairs        int64 // Drifted routes re-applied
+	RouteRepairFailures int64 // Drifted routes that could not be re-applied
+}
+
+// Registry stores process-wide counters.
+// Use NewRegistry; the zero value is not ready for use.
+type Registry struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	routes    map[string]*RouteStats
+
+	inFlight  atomic.Int64
+	openConns atomic.Int64
+
+	routeRepairs        atomic.Int64
+	routeRepairFailures atomic.Int64
+}
+
+// NewRegistry constructs an empty registry anchored at the current time.
+func NewRegistry() *Registry {
+	return &Registry{
+		startedAt: time.Now(),
+		routes:    make(map[string]*RouteStats),
+	}
+}
+
+// ObserveRequest records a completed HTTP request against route.
+// An empty route is recorded as UnmatchedRoute.
+func (r *Registry) ObserveRequest(route string, status int, bytes int64, dur time.Duration) {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rs := r.routeLocked(route)
+	rs.Count++
+	rs.Bytes += bytes
+	rs.Duration += dur
+	rs.ByStatus[status]++
+	rs.LastStatus = status
+}
+
+// ObserveBudgetViolation counts a request to route that exceeded its
+// endpoint budget. The request itself is still recorded by ObserveRequest.
+func (r *Registry) ObserveBudgetViolation(route string, kind BudgetKind) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routeLocked(route).Budget[kind]++
+}
+
+// routeLocked returns the stats for route, creating them. Callers hold r.mu.
+func (r *Registry) routeLocked(route string) *RouteStats {
+	rs, ok := r.routes[route]
+	if !ok {
+		rs = &RouteStats{ByStatus: make(map[int]int64), Budget: make(map[BudgetKind]int64)}
+		r.routes[route] = rs
+	}
+	return rs
+}
+
+// RequestStarted increments the in-flight gauge. Pair with RequestFinished.
+func (r *Registry) RequestStarted() { r.inFlight.Add(1) }
+
+// RequestFinished decrements the in-flight gauge.
+func (r *Registry) RequestFinished() { r.inFlight.Add(-1) }
+
+// InFlight returns the number of requests currently being handled.
+func (r *Registry) InFlight() int64 { return r.inFlight.Load() }
+
+// ConnOpened increments the open-connections gauge.
+func (r *Registry) ConnOpened() { r.openConns.Add(1) }
+
+// ConnClosed decrements the open-connections gauge.
+func (r *Registry) ConnClosed() { r.openConns.Add(-1) }
+
+// OpenConns returns the number of client connections currently open.
+func (r *Registry) OpenConns() int64 { return r.openConns.Load() }
+
+// ObserveRouteRepair counts a drifted route re-applied, or a failed
+// attempt when ok is false.
+func (r *Registry) ObserveRouteRepair(ok bool) {
+	if ok {
+		r.routeRepairs.Add(1)
+	} else {
+		r.routeRepairFailures.Add(1)
+	}
+}
+
+// Snapshot returns a deep copy of the current counters.
+func (r *Registry) Snapshot() Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	routes := make(map[string]RouteStats, len(r.routes))
+	for k, v := range r.routes {
+		byStatus := make(map[int]int64, len(v.ByStatus))
+		for code, n := range v.ByStatus {
+			byStatus[code] = n
+		}
+		budget := make(map[BudgetKind]int64, len(v.Budget))
+		for kind, n := range v.Budget {
+			budget[kind] = n
+		}
+		cp := *v
+		cp.ByStatus = byStatus
+		cp.Budget = budget
+		routes[k] = cp
+	}
+	return Snapshot{
+		StartedAt: r.startedAt,
+		Routes:    routes,
+		InFlight:  r.inFlight.Load(),
+		OpenConns: r.openConns.Load(),
+
+		RouteRepairs:        r.routeRepairs.Load(),
+		RouteRepairFailures: r.routeRepairFailures.Load(),
+	}
+}
@@ -0,0 +1,26 @@
+package netinfo
+
+import (
+	"net"
+	"syscall"
+	"time"
+)
+
+// BoundDialer returns a dialer whose sockets leave through iface rather
+// than by the routing table, so they bypass a TUN's default route. An
+// empty iface, or a platform without interface binding, dials by the
+// routing table.
+func BoundDialer(iface string, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if iface == "" {
+		return d
+	}
+	d.Control = func(network, _ string, c syscall.RawConn) error {
+		var serr error
+		if err := c.Control(func(fd uintptr) { serr = bindToInterface(fd, network, iface) }); err != nil {
+			return err
+		}
+		return serr
+	}
+	return d
+}
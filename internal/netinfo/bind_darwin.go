@@ -0,0 +1,22 @@
+package netinfo
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindToInterface pins the socket to iface (IP_BOUND_IF, or IPV6_BOUND_IF
+// for tcp6), so it leaves through that uplink rather than the TUN's
+// default route.
+func bindToInterface(fd uintptr, network, iface string) error {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(network, "6") {
+		return unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_BOUND_IF, ifi.Index)
+	}
+	return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_BOUND_IF, ifi.Index)
+}
@@ -0,0 +1,9 @@
+package netinfo
+
+import "golang.org/x/sys/unix"
+
+// bindToInterface pins the socket to iface (SO_BINDTODEVICE), so it
+// leaves through that uplink rather than the TUN's default route.
+func bindToInterface(fd uintptr, _, iface string) error {
+	return unix.BindToDevice(int(fd), iface)
+}
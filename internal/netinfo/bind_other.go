@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package netinfo
+
+// bindToInterface is a no-op: sockets follow the routing table.
+func bindToInterface(uintptr, string, string) error {
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build darwin
+
+package netinfo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// interfaceCounters runs `netstat -I NAME -b -n` and reads the link-level
+// row.
+func interfaceCounters(name string) (Counters, error) {
+	out, err := exec.Command("netstat", "-I", name, "-b", "-n").Output()
+	if err != nil {
+		return Counters{}, fmt.Errorf("netstat -I %s: %w", name, err)
+	}
+	return parseNetstatCounters(out, name)
+}
+
+// parseNetstatCounters reads netstat -ibn output. The Address column is
+// empty for point-to-point links such as utun, so columns are located
+// from the end of the header.
+func parseNetstatCounters(out []byte, name string) (Counters, error) {
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	var header []string
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if header == nil {
+			header = f
+			continue
+		}
+		if len(f) < 3 || f[0] != name || !strings.HasPrefix(f[2], "<Link#") {
+			continue
+		}
+		col := func(key string) (uint64, error) {
+			i := slices.Index(header, key)
+			if i < 0 {
+				return 0, fmt.Errorf("netstat: no %s column", key)
+			}
+			j := len(f) - (len(header) - i)
+			if j < 0 {
+				return 0, fmt.Errorf("netstat: no %s column", key)
+			}
+			return strconv.ParseUint(f[j], 10, 64)
+		}
+		var c Counters
+		for key, dst := range map[string]*uint64{
+			"Ibytes": &c.RxBytes,
+			"Obytes": &c.TxBytes,
+			"Ipkts":  &c.RxPackets,
+			"Opkts":  &c.TxPackets,
+		} {
+			n, err := col(key)
+			if err != nil {
+				return Counters{}, fmt.Errorf("interface %s %s: %w", name, key, err)
+			}
+			*dst = n
+		}
+		return c, nil
+	}
+	return Counters{}, fmt.Errorf("interface %s: no link row in netstat output", name)
+}
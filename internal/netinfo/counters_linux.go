@@ -0,0 +1,37 @@
+//go:build linux
+
+package netinfo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// interfaceCounters reads /sys/class/net/NAME/statistics.
+func interfaceCounters(name string) (Counters, error) {
+	if name == "" || strings.ContainsAny(name, "/.") {
+		return Counters{}, fmt.Errorf("interface %q: invalid name", name)
+	}
+	dir := filepath.Join("/sys/class/net", name, "statistics")
+	var c Counters
+	for file, dst := range map[string]*uint64{
+		"rx_bytes":   &c.RxBytes,
+		"tx_bytes":   &c.TxBytes,
+		"rx_packets": &c.RxPackets,
+		"tx_packets": &c.TxPackets,
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return Counters{}, fmt.Errorf("interface %s counters: %w", name, err)
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			return Counters{}, fmt.Errorf("interface %s %s: %w", name, file, err)
+		}
+		*dst = n
+	}
+	return c, nil
+}
@@ -0,0 +1,5 @@
+//go:build !linux && !darwin
+
+package netinfo
+
+func interfaceCounters(string) (Counters, error) { return Counters{}, ErrUnsupported }
@@ -0,0 +1,55 @@
+// Package netinfo reads the host's routing configuration, interfaces, and
+// interface counters.
+//
+// # Default Route
+//
+// GetDefaultRoute returns the IPv4 default route: gateway address, outgoing
+// interface, and metric. GetDefaultRoute6 does the same for IPv6. The orchestrator records the gateway as
+// RouteSnapshot.OriginalGateway before swapping routes, and recovery uses it
+// to detect a default route left pointing at a dead TUN.
+//
+// When several default routes exist, the one with the lowest metric wins,
+// matching the kernel's choice. GetDefaultRoutes and GetDefaultRoutes6 list
+// all of them, preferred first, so callers can pick a specific uplink.
+//
+// # Route Lookup
+//
+// LookupRoute returns the route the host would use for one destination,
+// with Prefix set to the destination it matched, so callers can tell a
+// pinned host route from traffic falling through to a default. Linux
+// picks the longest matching prefix of the main table (lowest metric on
+// a tie; policy routing is not consulted), as does windows; darwin asks the
+// kernel with `route -n get <dst>`.
+//
+// # Interfaces
+//
+// Interfaces lists the host's interfaces with their index, MTU, hardware
+// address, flags, and addresses as prefixes; it is portable (package net)
+// and backs GET /v1/interfaces, LAN detection, and the diagnostics bundle.
+//
+// # Interface Counters
+//
+// InterfaceCounters returns an interface's byte and packet counters as the
+// kernel keeps them; tun2socks samples the TUN's for traffic rates. Linux
+// reads /sys/class/net/NAME/statistics and darwin parses `netstat -I NAME
+// -b -n`; others return ErrUnsupported.
+//
+// # Interface Binding
+//
+// BoundDialer pins its sockets to one interface (SO_BINDTODEVICE on Linux,
+// IP_BOUND_IF on darwin), so checks that must leave outside the tunnel,
+// such as captive portal detection and the direct egress lookup, do so
+// while a TUN holds the default route. Elsewhere it dials by the routing
+// table.
+//
+// # Platforms
+//
+//   - linux:  an RTM_GETROUTE netlink dump of the main table (no /proc
+//     parsing, no external commands).
+//   - darwin: `route -n get default`; the BSD routing table has no metric, so
+//     Metric is 0. Other uplinks come from `route -n get -ifscope <if>
+//     default` and are numbered in interface order.
+//   - windows: GetIpForwardTable2 (package iphlp); Metric is the route's
+//     metric plus its interface's, the sum Windows compares.
+//   - others: ErrUnsupported.
+package netinfo
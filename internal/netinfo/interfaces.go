@@ -0,0 +1,56 @@
+package netinfo
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// Interface is a network interface and its configured addresses.
+type Interface struct {
+	Name         string
+	Index        int
+	MTU          int
+	HardwareAddr string // "" when the interface has none
+	Flags        net.Flags
+	Prefixes     []netip.Prefix // addresses with their prefix length, unmapped and without zone
+}
+
+// Interfaces lists the host's network interfaces in system order.
+func Interfaces() ([]Interface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Interface, 0, len(ifaces))
+	for _, ifc := range ifaces {
+		addrs, err := ifc.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ifc.Name, err)
+		}
+		it := Interface{
+			Name:         ifc.Name,
+			Index:        ifc.Index,
+			MTU:          ifc.MTU,
+			HardwareAddr: ifc.HardwareAddr.String(),
+			Flags:        ifc.Flags,
+		}
+		for _, a := range addrs {
+			ipn, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			addr, ok := netip.AddrFromSlice(ipn.IP)
+			if !ok {
+				continue
+			}
+			ones, _ := ipn.Mask.Size()
+			if addr.Is4In6() && ones > 32 {
+				ones -= 96
+			}
+			it.Prefixes = append(it.Prefixes, netip.PrefixFrom(addr.Unmap(), ones))
+		}
+		out = append(out, it)
+	}
+	return out, nil
+}
@@ -0,0 +1,66 @@
+package netinfo
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// ErrNoDefaultRoute is returned when the host has no default route for the
+// requested family.
+var ErrNoDefaultRoute = errors.New("no default route")
+
+// ErrNoRoute is returned by LookupRoute when no route covers the
+// destination.
+var ErrNoRoute = errors.New("no route to destination")
+
+// ErrUnsupported is returned on platforms without an implementation.
+var ErrUnsupported = errors.New("route discovery not supported on this platform")
+
+// Route describes a route, usually a default route.
+type Route struct {
+	Gateway   netip.Addr   // next hop without zone; invalid for interface-only routes (e.g., point-to-point)
+	Interface string       // outgoing interface name
+	Metric    int          // route priority; lower wins (0 where the OS has none)
+	Prefix    netip.Prefix // destination; /0 for default routes
+}
+
+// GetDefaultRoute returns the active IPv4 default route.
+func GetDefaultRoute() (Route, error) {
+	return defaultRoute(false)
+}
+
+// GetDefaultRoutes returns every IPv4 default route, preferred first. Hosts
+// with several uplinks (Wi-Fi, Ethernet, a tethered phone) have one each.
+func GetDefaultRoutes() ([]Route, error) {
+	return defaultRoutes(false)
+}
+
+// GetDefaultRoutes6 returns every IPv6 default route, preferred first.
+func GetDefaultRoutes6() ([]Route, error) {
+	return defaultRoutes(true)
+}
+
+// GetDefaultRoute6 returns the active IPv6 default route. IPv6 gateways are
+// usually link-local; pair Gateway with Interface when installing routes.
+func GetDefaultRoute6() (Route, error) {
+	return defaultRoute(true)
+}
+
+// LookupRoute returns the route the host would use for dst, with Prefix
+// set to the destination it matched.
+func LookupRoute(dst netip.Addr) (Route, error) {
+	return lookupRoute(dst.Unmap().WithZone(""))
+}
+
+// Counters are an interface's traffic counters since it was created, as
+// the kernel counts them: Rx is what the host received on it, Tx what the
+// host sent through it.
+type Counters struct {
+	RxBytes, TxBytes     uint64
+	RxPackets, TxPackets uint64
+}
+
+// InterfaceCounters returns the traffic counters of the named interface.
+func InterfaceCounters(name string) (Counters, error) {
+	return interfaceCounters(name)
+}
@@ -0,0 +1,158 @@
+//go:build darwin
+
+package netinfo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os/exec"
+	"strings"
+)
+
+func defaultRoute(v6 bool) (Route, error) {
+	args := []string{"-n", "get", "default"}
+	if v6 {
+		args = []string{"-n", "get", "-inet6", "default"}
+	}
+	out, err := exec.Command("route", args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// "route: writing to routing socket: not in table"
+		return Route{}, ErrNoDefaultRoute
+	}
+	if err != nil {
+		return Route{}, fmt.Errorf("route %s: %w", strings.Join(args, " "), err)
+	}
+	return parseRouteGet(out)
+}
+
+// defaultRoutes returns the primary default route followed by the
+// interface-scoped defaults macOS keeps for other up interfaces, which
+// get Metric 1, 2, ... in interface order.
+func defaultRoutes(v6 bool) ([]Route, error) {
+	primary, err := defaultRoute(v6)
+	if err != nil {
+		return nil, err
+	}
+	routes := []Route{primary}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return routes, nil
+	}
+	for _, ifc := range ifaces {
+		if ifc.Flags&net.FlagUp == 0 || ifc.Flags&net.FlagLoopback != 0 || ifc.Name == primary.Interface {
+			continue
+		}
+		args := []string{"-n", "get", "-ifscope", ifc.Name, "default"}
+		if v6 {
+			args = []string{"-n", "get", "-inet6", "-ifscope", ifc.Name, "default"}
+		}
+		out, err := exec.Command("route", args...).Output()
+		if err != nil {
+			continue // no scoped default on this interface
+		}
+		r, err := parseRouteGet(out)
+		if err != nil || r.Interface != ifc.Name || !r.Gateway.IsValid() {
+			continue
+		}
+		r.Metric = len(routes)
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// lookupRoute asks the kernel which route it would use for dst.
+func lookupRoute(dst netip.Addr) (Route, error) {
+	args := []string{"-n", "get", dst.String()}
+	if dst.Is6() {
+		args = []string{"-n", "get", "-inet6", dst.String()}
+	}
+	out, err := exec.Command("route", args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return Route{}, ErrNoRoute
+	}
+	if err != nil {
+		return Route{}, fmt.Errorf("route %s: %w", strings.Join(args, " "), err)
+	}
+	r, err := parseRouteGet(out)
+	if errors.Is(err, ErrNoDefaultRoute) {
+		return Route{}, ErrNoRoute
+	}
+	if err == nil && !r.Prefix.IsValid() {
+		r.Prefix = netip.PrefixFrom(dst, dst.BitLen()) // host route; no mask line
+	}
+	return r, err
+}
+
+// parseRouteGet extracts gateway, interface, and the matched destination
+// from `route -n get` output.
+func parseRouteGet(out []byte) (Route, error) {
+	var (
+		r         Route
+		dst       netip.Addr
+		isDefault bool
+		mask      netip.Addr
+		v6        bool // the queried destination is IPv6
+	)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		key, val, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		switch key {
+		case "gateway":
+			// Link-layer gateways ("link#12") leave Gateway unset.
+			if ip, err := netip.ParseAddr(val); err == nil {
+				r.Gateway = ip.WithZone("")
+			}
+		case "interface":
+			r.Interface = val
+		case "route to":
+			if a, err := netip.ParseAddr(val); err == nil {
+				v6 = a.Is6()
+			}
+		case "destination":
+			isDefault = val == "default"
+			dst, _ = netip.ParseAddr(val)
+		case "mask":
+			mask, _ = netip.ParseAddr(val)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return Route{}, err
+	}
+	switch {
+	case isDefault:
+		r.Prefix = netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+		if v6 {
+			r.Prefix = netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+		}
+	case dst.IsValid() && mask.IsValid() && mask.BitLen() == dst.BitLen():
+		r.Prefix, _ = dst.WithZone("").Prefix(maskBits(mask))
+	}
+	if r.Interface == "" && !r.Gateway.IsValid() {
+		return Route{}, ErrNoDefaultRoute
+	}
+	return r, nil
+}
+
+// maskBits counts the leading one bits of a netmask.
+func maskBits(mask netip.Addr) int {
+	n := 0
+	for _, b := range mask.AsSlice() {
+		for ; b&0x80 != 0; b <<= 1 {
+			n++
+		}
+		if b != 0 || n%8 != 0 {
+			break
+		}
+	}
+	return n
+}
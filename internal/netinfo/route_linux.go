@@ -0,0 +1,146 @@
+//go:build linux
+
+package netinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"syscall"
+)
+
+// rtmsg field offsets (struct rtmsg in linux/rtnetlink.h).
+const (
+	rtmDstLen = 1
+	rtmTable  = 4
+	rtmType   = 7
+	rtmLen    = 12
+)
+
+func defaultRoute(v6 bool) (Route, error) {
+	routes, err := defaultRoutes(v6)
+	if err != nil {
+		return Route{}, err
+	}
+	return routes[0], nil
+}
+
+func defaultRoutes(v6 bool) ([]Route, error) {
+	all, err := mainRoutes(v6)
+	if err != nil {
+		return nil, err
+	}
+	var routes []Route
+	for _, r := range all {
+		if r.Prefix.Bits() == 0 {
+			routes = append(routes, r)
+		}
+	}
+	if len(routes) == 0 {
+		return nil, ErrNoDefaultRoute
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Metric < routes[j].Metric })
+	return routes, nil
+}
+
+// lookupRoute picks the main-table route the kernel would use for dst:
+// the longest matching prefix, then the lowest metric. Policy rules and
+// other tables are not consulted.
+func lookupRoute(dst netip.Addr) (Route, error) {
+	all, err := mainRoutes(dst.Is6())
+	if err != nil {
+		return Route{}, err
+	}
+	best, found := Route{}, false
+	for _, r := range all {
+		if !r.Prefix.Contains(dst) {
+			continue
+		}
+		if !found || r.Prefix.Bits() > best.Prefix.Bits() ||
+			(r.Prefix.Bits() == best.Prefix.Bits() && r.Metric < best.Metric) {
+			best, found = r, true
+		}
+	}
+	if !found {
+		return Route{}, ErrNoRoute
+	}
+	return best, nil
+}
+
+// mainRoutes returns the unicast routes of the main table for one family.
+func mainRoutes(v6 bool) ([]Route, error) {
+	family := syscall.AF_INET
+	if v6 {
+		family = syscall.AF_INET6
+	}
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETROUTE, family)
+	if err != nil {
+		return nil, fmt.Errorf("netlink route dump: %w", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, fmt.Errorf("parse netlink: %w", err)
+	}
+
+	var routes []Route
+	for i := range msgs {
+		m := &msgs[i]
+		if m.Header.Type != syscall.RTM_NEWROUTE || len(m.Data) < rtmLen {
+			continue
+		}
+		if int(m.Data[0]) != family || m.Data[rtmType] != syscall.RTN_UNICAST {
+			continue
+		}
+		bits := int(m.Data[rtmDstLen])
+		table := uint32(m.Data[rtmTable])
+		attrs, err := syscall.ParseNetlinkRouteAttr(m)
+		if err != nil {
+			continue
+		}
+		var (
+			r   Route
+			oif uint32
+			dst = netip.IPv4Unspecified()
+		)
+		if v6 {
+			dst = netip.IPv6Unspecified()
+		}
+		for _, a := range attrs {
+			switch a.Attr.Type {
+			case syscall.RTA_DST:
+				if ip, ok := netip.AddrFromSlice(a.Value); ok {
+					dst = ip
+				}
+			case syscall.RTA_GATEWAY:
+				if ip, ok := netip.AddrFromSlice(a.Value); ok {
+					r.Gateway = ip
+				}
+			case syscall.RTA_OIF:
+				if len(a.Value) >= 4 {
+					oif = binary.NativeEndian.Uint32(a.Value)
+				}
+			case syscall.RTA_PRIORITY:
+				if len(a.Value) >= 4 {
+					r.Metric = int(binary.NativeEndian.Uint32(a.Value))
+				}
+			case syscall.RTA_TABLE:
+				if len(a.Value) >= 4 {
+					table = binary.NativeEndian.Uint32(a.Value)
+				}
+			}
+		}
+		if table != syscall.RT_TABLE_MAIN {
+			continue
+		}
+		if oif != 0 {
+			if ifc, err := net.InterfaceByIndex(int(oif)); err == nil {
+				r.Interface = ifc.Name
+			}
+		}
+		r.Prefix = netip.PrefixFrom(dst, bits)
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !windows
+
+package netinfo
+
+import "net/netip"
+
+func defaultRoute(bool) (Route, error) { return Route{}, ErrUnsupported }
+
+func defaultRoutes(bool) ([]Route, error) { return nil, ErrUnsupported }
+
+func lookupRoute(netip.Addr) (Route, error) { return Route{}, ErrUnsupported }
@@ -0,0 +1,98 @@
+//go:build windows
+
+package netinfo
+
+import (
+	"net"
+	"net/netip"
+	"sort"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/sanverite/simple-packet-logger/internal/iphlp"
+)
+
+func defaultRoute(v6 bool) (Route, error) {
+	routes, err := defaultRoutes(v6)
+	if err != nil {
+		return Route{}, err
+	}
+	return routes[0], nil
+}
+
+func defaultRoutes(v6 bool) ([]Route, error) {
+	all, err := tableRoutes(v6)
+	if err != nil {
+		return nil, err
+	}
+	var routes []Route
+	for _, r := range all {
+		if r.Prefix.Bits() == 0 {
+			routes = append(routes, r)
+		}
+	}
+	if len(routes) == 0 {
+		return nil, ErrNoDefaultRoute
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].Metric < routes[j].Metric })
+	return routes, nil
+}
+
+// lookupRoute picks the route Windows would use for dst: the longest
+// matching prefix, then the lowest effective metric.
+func lookupRoute(dst netip.Addr) (Route, error) {
+	all, err := tableRoutes(dst.Is6())
+	if err != nil {
+		return Route{}, err
+	}
+	best, found := Route{}, false
+	for _, r := range all {
+		if !r.Prefix.Contains(dst) {
+			continue
+		}
+		if !found || r.Prefix.Bits() > best.Prefix.Bits() ||
+			(r.Prefix.Bits() == best.Prefix.Bits() && r.Metric < best.Metric) {
+			best, found = r, true
+		}
+	}
+	if !found {
+		return Route{}, ErrNoRoute
+	}
+	return best, nil
+}
+
+// tableRoutes returns the routing table for one family. Metric is the
+// effective one Windows compares: the route's metric plus its interface's.
+func tableRoutes(v6 bool) ([]Route, error) {
+	rows, err := iphlp.Routes(v6)
+	if err != nil {
+		return nil, err
+	}
+	ifMetric := make(map[uint32]uint32)
+	var routes []Route
+	for i := range rows {
+		row := &rows[i]
+		ifi, err := net.InterfaceByIndex(int(row.InterfaceIndex))
+		if err != nil {
+			continue // interface went away
+		}
+		m, ok := ifMetric[row.InterfaceIndex]
+		if !ok {
+			ipif := windows.MibIpInterfaceRow{Family: row.DestinationPrefix.Prefix.Family, InterfaceIndex: row.InterfaceIndex}
+			if windows.GetIpInterfaceEntry(&ipif) == nil {
+				m = ipif.Metric
+			}
+			ifMetric[row.InterfaceIndex] = m
+		}
+		r := Route{
+			Interface: ifi.Name,
+			Metric:    int(row.Metric + m),
+			Prefix:    iphlp.Prefix(&row.DestinationPrefix),
+		}
+		if gw := iphlp.Addr(&row.NextHop); gw.IsValid() && !gw.IsUnspecified() {
+			r.Gateway = gw
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
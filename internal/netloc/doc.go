@@ -0,0 +1,30 @@
+// Package netloc identifies the network the host is attached to, so the
+// agent can pick the upstream profile for it.
+//
+// # Location
+//
+// A Location is what distinguishes one network from another without
+// asking the user: the Wi-Fi SSID, the IPv4 default gateway and its MAC
+// address, and the DNS search domains. Detect gathers them best effort;
+// a part that cannot be read is left empty and reported in the error,
+// while not being on Wi-Fi is simply an empty SSID.
+//
+//   - linux:  SSID from nmcli (NetworkManager), falling back to iwgetid;
+//     the gateway MAC from /proc/net/arp.
+//   - darwin: SSID from `networksetup -getairportnetwork` on the Wi-Fi
+//     device (recent macOS releases hide it from processes without
+//     location permission); the gateway MAC from `arp -n`.
+//   - both:   the gateway from package netinfo and search domains from
+//     /etc/resolv.conf.
+//   - others: gateway and search domains only.
+//
+// MACs are normalized to lowercase colon-separated octets ("0:1a:..." from
+// BSD arp becomes "00:1a:..."), and search domains to lowercase without a
+// trailing dot, so match rules can be compared as strings.
+//
+// # Watcher
+//
+// A Watcher runs Detect every interval (default DefaultInterval) and calls
+// Options.OnChange when the location differs from the previous check. The
+// first check reports a change from the zero Location.
+package netloc
@@ -0,0 +1,242 @@
+package netloc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// DefaultInterval is the pause between checks.
+const DefaultInterval = 10 * time.Second
+
+// ResolvConf is read for search domains.
+var ResolvConf = "/etc/resolv.conf"
+
+// Location identifies the attached network. Empty fields are unknown.
+type Location struct {
+	SSID          string
+	Gateway       netip.Addr
+	GatewayMAC    string   // normalized, see NormalizeMAC
+	SearchDomains []string // normalized, see NormalizeDomain
+}
+
+// Equal reports whether l and o describe the same network.
+func (l Location) Equal(o Location) bool {
+	return l.SSID == o.SSID && l.Gateway == o.Gateway && l.GatewayMAC == o.GatewayMAC &&
+		slices.Equal(l.SearchDomains, o.SearchDomains)
+}
+
+// IsZero reports whether nothing is known about the network.
+func (l Location) IsZero() bool { return l.Equal(Location{}) }
+
+// String summarizes l for logs and events.
+func (l Location) String() string {
+	var parts []string
+	if l.SSID != "" {
+		parts = append(parts, "ssid "+l.SSID)
+	}
+	if l.Gateway.IsValid() {
+		gw := "gateway " + l.Gateway.String()
+		if l.GatewayMAC != "" {
+			gw += " (" + l.GatewayMAC + ")"
+		}
+		parts = append(parts, gw)
+	}
+	if len(l.SearchDomains) > 0 {
+		parts = append(parts, "search "+strings.Join(l.SearchDomains, ","))
+	}
+	if len(parts) == 0 {
+		return "unknown network"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Detect reads the current location. Parts that could not be read are
+// left empty and their failures joined into the error.
+func Detect() (Location, error) {
+	var loc Location
+	var errs []error
+	ssid, err := wifiSSID()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("ssid: %w", err))
+	}
+	loc.SSID = ssid
+	r, err := netinfo.GetDefaultRoute()
+	switch {
+	case errors.Is(err, netinfo.ErrNoDefaultRoute):
+	case err != nil:
+		errs = append(errs, fmt.Errorf("gateway: %w", err))
+	case r.Gateway.IsValid():
+		loc.Gateway = r.Gateway
+		mac, err := gatewayMAC(r.Gateway)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("gateway mac: %w", err))
+		}
+		loc.GatewayMAC = mac
+	}
+	loc.SearchDomains, err = searchDomains(ResolvConf)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		errs = append(errs, fmt.Errorf("search domains: %w", err))
+	}
+	return loc, errors.Join(errs...)
+}
+
+// NormalizeMAC returns mac as lowercase two-digit octets separated by
+// colons, accepting single-digit octets ("0:1a:2b:3c:4d:5e").
+func NormalizeMAC(mac string) (string, error) {
+	parts := strings.Split(mac, ":")
+	if len(parts) == 6 {
+		for i, p := range parts {
+			if len(p) == 1 {
+				parts[i] = "0" + p
+			}
+		}
+		mac = strings.Join(parts, ":")
+	}
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return "", fmt.Errorf("invalid MAC address %q", mac)
+	}
+	return hw.String(), nil
+}
+
+// NormalizeDomain lowercases d and drops a trailing dot.
+func NormalizeDomain(d string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(d), "."))
+}
+
+// searchDomains reads "search" and "domain" lines; the last one wins, as
+// in the resolver.
+func searchDomains(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 2 || (fields[0] != "search" && fields[0] != "domain") {
+			continue
+		}
+		out = out[:0]
+		for _, d := range fields[1:] {
+			if d = NormalizeDomain(d); d != "" && !slices.Contains(out, d) {
+				out = append(out, d)
+			}
+		}
+	}
+	return out, sc.Err()
+}
+
+// Options configures a Watcher.
+type Options struct {
+	// Detect reads the location (default Detect).
+	Detect func() (Location, error)
+	// Interval is the pause between checks in Run (default DefaultInterval).
+	Interval time.Duration
+	// OnChange, when set, is called after the location changes.
+	OnChange func(prev, next Location)
+	// Logger receives watcher records. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Status is the result of the latest check.
+type Status struct {
+	Location Location
+	Checked  time.Time
+	Changed  time.Time // when Location was first seen
+	Err      string    // last detection failure, if any
+}
+
+// Watcher tracks the current location.
+type Watcher struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New constructs a watcher; call Check or Run to detect the location.
+func New(opts Options) *Watcher {
+	if opts.Detect == nil {
+		opts.Detect = Detect
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Watcher{opts: opts, logger: logger}
+}
+
+// Status returns the result of the latest check.
+func (w *Watcher) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Check detects the location once and reports a change.
+func (w *Watcher) Check() Status {
+	loc, err := w.opts.Detect()
+	now := time.Now()
+
+	w.mu.Lock()
+	prev := w.status.Location
+	first := w.status.Checked.IsZero()
+	changed := first || !loc.Equal(prev)
+	w.status.Checked = now
+	w.status.Location = loc
+	if changed {
+		w.status.Changed = now
+	}
+	prevErr := w.status.Err
+	w.status.Err = ""
+	if err != nil {
+		w.status.Err = err.Error()
+	}
+	st := w.status
+	w.mu.Unlock()
+
+	if err != nil && st.Err != prevErr {
+		w.logger.Debug("network location partly unknown", "err", err)
+	}
+	if changed {
+		w.logger.Info("network location changed", "location", loc.String())
+		if w.opts.OnChange != nil {
+			w.opts.OnChange(prev, loc)
+		}
+	}
+	return st
+}
+
+// Run checks every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	w.Check()
+	t := time.NewTicker(w.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			w.Check()
+		}
+	}
+}
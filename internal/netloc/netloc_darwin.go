@@ -0,0 +1,58 @@
+//go:build darwin
+
+package netloc
+
+import (
+	"net/netip"
+	"os/exec"
+	"strings"
+)
+
+// wifiSSID finds the Wi-Fi device and asks networksetup for its network.
+func wifiSSID() (string, error) {
+	out, err := exec.Command("networksetup", "-listallhardwareports").Output()
+	if err != nil {
+		return "", err
+	}
+	dev := ""
+	wifi := false
+	for _, line := range strings.Split(string(out), "\n") {
+		if port, ok := strings.CutPrefix(line, "Hardware Port: "); ok {
+			wifi = port == "Wi-Fi" || port == "AirPort"
+		} else if d, ok := strings.CutPrefix(line, "Device: "); ok && wifi {
+			dev = strings.TrimSpace(d)
+			break
+		}
+	}
+	if dev == "" {
+		return "", nil
+	}
+	out, err = exec.Command("networksetup", "-getairportnetwork", dev).Output()
+	if err != nil {
+		return "", err
+	}
+	// "Current Wi-Fi Network: Name", or a sentence when not associated.
+	_, ssid, ok := strings.Cut(strings.TrimSpace(string(out)), "Network: ")
+	if !ok {
+		return "", nil
+	}
+	return ssid, nil
+}
+
+// gatewayMAC parses "? (192.168.1.1) at a4:2b:b0:1:2:3 on en0 ..." from arp.
+func gatewayMAC(gw netip.Addr) (string, error) {
+	out, err := exec.Command("arp", "-n", gw.String()).Output()
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(out))
+	for i, f := range fields {
+		if f == "at" && i+1 < len(fields) {
+			if fields[i+1] == "(incomplete)" {
+				return "", nil
+			}
+			return NormalizeMAC(fields[i+1])
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package netloc
+
+import (
+	"bufio"
+	"errors"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// wifiSSID asks NetworkManager, then the wireless extensions. No Wi-Fi
+// tooling at all is not an error: the host may have no Wi-Fi.
+func wifiSSID() (string, error) {
+	out, err := exec.Command("nmcli", "-t", "-f", "active,ssid", "dev", "wifi").Output()
+	if err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			if ssid, ok := strings.CutPrefix(line, "yes:"); ok {
+				return strings.ReplaceAll(ssid, `\:`, ":"), nil
+			}
+		}
+		return "", nil
+	}
+	if !errors.Is(err, exec.ErrNotFound) {
+		return "", err
+	}
+	out, err = exec.Command("iwgetid", "-r").Output()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.As(err, &exitErr):
+		return "", nil // no tool, or not associated
+	case err != nil:
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gatewayMAC reads the neighbor entry for gw from /proc/net/arp.
+func gatewayMAC(gw netip.Addr) (string, error) {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 || fields[0] != gw.String() || fields[2] == "0x0" {
+			continue
+		}
+		return NormalizeMAC(fields[3])
+	}
+	return "", sc.Err()
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package netloc
+
+import "net/netip"
+
+func wifiSSID() (string, error)             { return "", nil }
+func gatewayMAC(netip.Addr) (string, error) { return "", nil }
@@ -0,0 +1,36 @@
+// Package persist saves core.State and the event journal through a
+// storage.Storage backend and restores them at startup.
+//
+// # Overview
+//
+// The agent mutates host networking (TUN device, default route, host routes,
+// a tun2socks child). If it crashes, those artifacts outlive the process.
+// Persisting the state that described them lets the next run detect the
+// leftovers and offer recovery instead of starting blind.
+//
+// # Record Format
+//
+// StateStore keeps a versioned JSON Record (agent state, TUN, routes
+// including the original gateway, tun2socks, last probe, warnings) under the
+// "state" key. Records are decoupled from core types so core can evolve
+// without breaking stored data. Atomicity and file modes are the backend's
+// concern (see package storage).
+//
+// # Event Journal
+//
+// EventJournal appends one JSON EventRecord per event to the "events" log
+// and is compacted to the in-memory capacity when opened.
+//
+// # Writer
+//
+// Run watches core.State.Changed, debounces bursts of mutations, and saves a
+// fresh snapshot. It performs a final save when its context is cancelled.
+//
+// # Restore Semantics
+//
+// Restore loads the record into core.State. Lifecycle timestamps are not
+// carried over. If the previous run did not end inactive, the shutdown was
+// unclean: the agent is placed in the error state with a warning so the
+// operator (or recovery logic) can inspect leftover routes/TUN devices and
+// clean up before starting again.
+package persist
@@ -0,0 +1,86 @@
+package persist
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/storage"
+)
+
+// EventsLog is the storage log name holding the event journal.
+const EventsLog = "events"
+
+// EventRecord is the JSON representation of core.Event in the journal.
+type EventRecord struct {
+	ID      uint64            `json:"id"`
+	At      time.Time         `json:"at"`
+	Type    string            `json:"type"`
+	Message string            `json:"message"`
+	Data    map[string]string `json:"data,omitempty"`
+}
+
+// EventJournal is an append-only event journal implementing core.EventSink.
+// It compacts itself to the most recent keep events every keep appends, so
+// the log holds at most twice what the agent reloads however long it runs.
+type EventJournal struct {
+	log  storage.Log
+	keep int
+
+	mu       sync.Mutex
+	appended int // since the last compaction
+}
+
+// OpenEventJournal opens the journal in st, compacts it to the most recent
+// keep events, and returns those events for seeding core.EventLog.
+func OpenEventJournal(st storage.Storage, keep int) (*EventJournal, []core.Event, error) {
+	log, err := st.Log(EventsLog)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := log.Compact(keep); err != nil {
+		return nil, nil, err
+	}
+	recs, err := log.Tail(keep)
+	if err != nil {
+		return nil, nil, err
+	}
+	events := make([]core.Event, 0, len(recs))
+	for _, b := range recs {
+		// Malformed records (e.g., a torn final write after a crash) are skipped.
+		var rec EventRecord
+		if err := json.Unmarshal(b, &rec); err != nil || rec.ID == 0 {
+			continue
+		}
+		events = append(events, rec.event())
+	}
+	return &EventJournal{log: log, keep: keep}, events, nil
+}
+
+// Append writes one event, compacting the journal every keep appends.
+func (e *EventJournal) Append(ev core.Event) error {
+	b, err := json.Marshal(toEventRecord(ev))
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.log.Append(b); err != nil {
+		return err
+	}
+	e.appended++
+	if e.keep <= 0 || e.appended < e.keep {
+		return nil
+	}
+	e.appended = 0
+	return e.log.Compact(e.keep)
+}
+
+func toEventRecord(ev core.Event) EventRecord {
+	return EventRecord{ID: ev.ID, At: ev.At.UTC(), Type: string(ev.Type), Message: ev.Message, Data: ev.Data}
+}
+
+func (r EventRecord) event() core.Event {
+	return core.Event{ID: r.ID, At: r.At, Type: core.EventType(r.Type), Message: r.Message, Data: r.Data}
+}
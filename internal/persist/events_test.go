@@ -0,0 +1,48 @@
+package persist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/storage"
+)
+
+func TestEventJournalCompactsWhileRunning(t *testing.T) {
+	const keep = 4
+	st, err := storage.NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	j, _, err := OpenEventJournal(st, keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := uint64(1); id <= 5*keep+1; id++ {
+		if err := j.Append(core.Event{ID: id, At: time.Now(), Type: core.EventWarning}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	log, err := st.Log(EventsLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recs, err := log.Tail(10 * keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) > 2*keep {
+		t.Errorf("journal holds %d records, want at most %d", len(recs), 2*keep)
+	}
+
+	_, events, err := OpenEventJournal(st, keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != keep || events[keep-1].ID != 5*keep+1 {
+		t.Errorf("reopened journal has %d events ending at %v, want %d ending at %d", len(events), events, keep, 5*keep+1)
+	}
+}
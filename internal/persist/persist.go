@@ -0,0 +1,433 @@
+package persist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/storage"
+)
+
+// RecordVersion is bumped on incompatible changes to Record.
+const RecordVersion = 1
+
+// DefaultDebounce coalesces bursts of state changes into one write.
+const DefaultDebounce = 250 * time.Millisecond
+
+// Record is the on-disk representation of core.Snapshot.
+type Record struct {
+	Version    int             `json:"version"`
+	SavedAt    time.Time       `json:"saved_at"`
+	Agent      string          `json:"agent_state"`
+	Warnings   []string        `json:"warnings"`              // read by older builds
+	WarningsV2 []WarningRecord `json:"warnings_v2,omitempty"` // preferred when present
+	TUN        TUNRecord       `json:"tun"`
+	Routes     RoutesRecord    `json:"routes"`
+	Tun2Socks  Tun2SocksRecord `json:"tun2socks"`
+	DNS        DNSRecord       `json:"dns"`
+	LastProbe  ProbeRecord     `json:"last_probe"`
+	Health     HealthRecord    `json:"health"`
+}
+
+// TUNRecord mirrors core.TUNSnapshot.
+type TUNRecord struct {
+	Name     string `json:"name"`
+	Up       bool   `json:"up"`
+	MTU      int    `json:"mtu"`
+	LocalIP  string `json:"local_ip"`
+	PeerIP   string `json:"peer_ip"`
+	LocalIP6 string `json:"local_ip6,omitempty"`
+}
+
+// RoutesRecord mirrors core.RouteSnapshot.
+type RoutesRecord struct {
+	DefaultVia       string   `json:"default_via"`
+	LanCIDRs         []string `json:"lan_cidrs"`
+	IncludeCIDRs     []string `json:"include_cidrs,omitempty"`
+	ExcludeCIDRs     []string `json:"exclude_cidrs,omitempty"`
+	BypassHosts      []string `json:"bypass_hosts"`
+	ProxyHostRoute   bool     `json:"proxy_host_route"`
+	ProxyAddrs       []string `json:"proxy_addrs,omitempty"`
+	OriginalGateway  string   `json:"original_gateway"`
+	OriginalGateway6 string   `json:"original_gateway6,omitempty"`
+}
+
+// Tun2SocksRecord mirrors core.Tun2SocksSnapshot.
+type Tun2SocksRecord struct {
+	PID int `json:"pid"`
+}
+
+// DNSRecord mirrors core.DNSSnapshot.
+type DNSRecord struct {
+	Listen            string   `json:"listen,omitempty"`
+	Upstream          string   `json:"upstream,omitempty"`
+	Resolvers         []string `json:"resolvers,omitempty"`
+	OriginalResolvers []string `json:"original_resolvers,omitempty"`
+	Rewritten         bool     `json:"rewritten,omitempty"`
+}
+
+// HealthRecord keeps the damped health across restarts; the streak
+// counters start over.
+type HealthRecord struct {
+	Status string    `json:"status,omitempty"`
+	Since  time.Time `json:"since,omitempty"`
+}
+
+// ProbeRecord mirrors core.ProbeSummary.
+type ProbeRecord struct {
+	Reachable   bool             `json:"reachable"`
+	SocksOK     bool             `json:"socks_ok"`
+	ConnectOK   bool             `json:"connect_ok"`
+	UDPOK       bool             `json:"udp_ok"`
+	LatenciesMs map[string]int64 `json:"latencies_ms"`           // read by older builds
+	LatenciesUs map[string]int64 `json:"latencies_us,omitempty"` // preferred when present
+	Auth        string           `json:"auth"`
+	IPv6        bool             `json:"ipv6"`
+	UDP         bool             `json:"udp"`
+	PathMTU     int              `json:"path_mtu,omitempty"`
+	Family      string           `json:"family,omitempty"`
+	LastChecked time.Time        `json:"last_checked"`
+	Warnings    []string         `json:"warnings"`              // read by older builds
+	WarningsV2  []WarningRecord  `json:"warnings_v2,omitempty"` // preferred when present
+	Hops        []HopRecord      `json:"hops,omitempty"`
+	Targets     []TargetRecord   `json:"targets,omitempty"`
+}
+
+// WarningRecord mirrors core.Warning.
+type WarningRecord struct {
+	Code     string    `json:"code,omitempty"`
+	Severity string    `json:"severity"`
+	Source   string    `json:"source,omitempty"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+	Count    int       `json:"count,omitempty"`
+	First    time.Time `json:"first,omitempty"`
+}
+
+// HopRecord mirrors core.ProbeHop.
+type HopRecord struct {
+	Server    string `json:"server"`
+	Type      string `json:"type"`
+	OK        bool   `json:"ok"`
+	LatencyUs int64  `json:"latency_us"`
+	Error     string `json:"error,omitempty"`
+}
+
+// TargetRecord mirrors core.ProbeTarget.
+type TargetRecord struct {
+	Target    string `json:"target"`
+	OK        bool   `json:"ok"`
+	LatencyUs int64  `json:"latency_us"`
+	Error     string `json:"error,omitempty"`
+}
+
+// FromSnapshot converts a core snapshot into a Record.
+func FromSnapshot(s core.Snapshot) Record {
+	return Record{
+		Version:    RecordVersion,
+		SavedAt:    time.Now().UTC(),
+		Agent:      string(s.AgentState),
+		Warnings:   core.WarningMessages(s.Warnings),
+		WarningsV2: warningRecords(s.Warnings),
+		TUN: TUNRecord{
+			Name:     s.TUN.Name,
+			Up:       s.TUN.Up,
+			MTU:      s.TUN.MTU,
+			LocalIP:  s.TUN.LocalIP,
+			PeerIP:   s.TUN.PeerIP,
+			LocalIP6: s.TUN.LocalIP6,
+		},
+		Routes: RoutesRecord{
+			DefaultVia:       s.Routes.DefaultVia,
+			LanCIDRs:         s.Routes.LanCIDRs,
+			IncludeCIDRs:     s.Routes.IncludeCIDRs,
+			ExcludeCIDRs:     s.Routes.ExcludeCIDRs,
+			BypassHosts:      s.Routes.BypassHosts,
+			ProxyHostRoute:   s.Routes.ProxyHostRoute,
+			ProxyAddrs:       s.Routes.ProxyAddrs,
+			OriginalGateway:  s.Routes.OriginalGateway,
+			OriginalGateway6: s.Routes.OriginalGateway6,
+		},
+		Tun2Socks: Tun2SocksRecord{PID: s.Tun2Socks.PID},
+		DNS: DNSRecord{
+			Listen:            s.DNS.Listen,
+			Upstream:          s.DNS.Upstream,
+			Resolvers:         s.DNS.Resolvers,
+			OriginalResolvers: s.DNS.OriginalResolvers,
+			Rewritten:         s.DNS.Rewritten,
+		},
+		LastProbe: ProbeRecord{
+			Reachable:   s.LastProbe.Reachable,
+			SocksOK:     s.LastProbe.SocksOK,
+			ConnectOK:   s.LastProbe.ConnectOK,
+			UDPOK:       s.LastProbe.UDPOK,
+			LatenciesMs: latencyUnits(s.LastProbe.Latencies, time.Millisecond),
+			LatenciesUs: latencyUnits(s.LastProbe.Latencies, time.Microsecond),
+			Auth:        s.LastProbe.Features.Auth,
+			IPv6:        s.LastProbe.Features.IPv6,
+			UDP:         s.LastProbe.Features.UDP,
+			PathMTU:     s.LastProbe.Features.PathMTU,
+			Family:      s.LastProbe.Features.Family,
+			LastChecked: s.LastProbe.LastChecked,
+			Warnings:    core.WarningMessages(s.LastProbe.Warnings),
+			WarningsV2:  warningRecords(s.LastProbe.Warnings),
+			Hops:        hopRecords(s.LastProbe.Hops),
+			Targets:     targetRecords(s.LastProbe.Targets),
+		},
+		Health: HealthRecord{Status: string(s.Health.Status), Since: s.Health.Since},
+	}
+}
+
+// Snapshot converts the record back into a core snapshot. Lifecycle
+// timestamps (StartedAt, tun2socks uptime, health bits) are not restored.
+func (r Record) Snapshot() core.Snapshot {
+	return core.Snapshot{
+		AgentState: core.AgentState(r.Agent),
+		Warnings:   warnings(r.Warnings, r.WarningsV2),
+		TUN: core.TUNSnapshot{
+			Name:     r.TUN.Name,
+			Up:       r.TUN.Up,
+			MTU:      r.TUN.MTU,
+			LocalIP:  r.TUN.LocalIP,
+			PeerIP:   r.TUN.PeerIP,
+			LocalIP6: r.TUN.LocalIP6,
+		},
+		Routes: core.RouteSnapshot{
+			DefaultVia:       r.Routes.DefaultVia,
+			LanCIDRs:         r.Routes.LanCIDRs,
+			IncludeCIDRs:     r.Routes.IncludeCIDRs,
+			ExcludeCIDRs:     r.Routes.ExcludeCIDRs,
+			BypassHosts:      r.Routes.BypassHosts,
+			ProxyHostRoute:   r.Routes.ProxyHostRoute,
+			ProxyAddrs:       r.Routes.ProxyAddrs,
+			OriginalGateway:  r.Routes.OriginalGateway,
+			OriginalGateway6: r.Routes.OriginalGateway6,
+		},
+		Tun2Socks: core.Tun2SocksSnapshot{PID: r.Tun2Socks.PID},
+		DNS: core.DNSSnapshot{
+			Listen:            r.DNS.Listen,
+			Upstream:          r.DNS.Upstream,
+			Resolvers:         r.DNS.Resolvers,
+			OriginalResolvers: r.DNS.OriginalResolvers,
+			Rewritten:         r.DNS.Rewritten,
+		},
+		LastProbe: core.ProbeSummary{
+			Reachable: r.LastProbe.Reachable,
+			SocksOK:   r.LastProbe.SocksOK,
+			ConnectOK: r.LastProbe.ConnectOK,
+			UDPOK:     r.LastProbe.UDPOK,
+			Latencies: r.LastProbe.latencies(),
+			Features: core.ProxyFeatures{
+				Auth:    r.LastProbe.Auth,
+				IPv6:    r.LastProbe.IPv6,
+				UDP:     r.LastProbe.UDP,
+				PathMTU: r.LastProbe.PathMTU,
+				Family:  r.LastProbe.Family,
+			},
+			LastChecked: r.LastProbe.LastChecked,
+			Warnings:    warnings(r.LastProbe.Warnings, r.LastProbe.WarningsV2),
+			Hops:        r.LastProbe.hops(),
+			Targets:     r.LastProbe.targets(),
+		},
+		Health: core.HealthSnapshot{Status: core.HealthStatus(r.Health.Status), Since: r.Health.Since},
+	}
+}
+
+// warningRecords converts warnings for storage.
+func warningRecords(ws []core.Warning) []WarningRecord {
+	out := make([]WarningRecord, len(ws))
+	for i, w := range ws {
+		out[i] = WarningRecord{Code: w.Code, Severity: w.Severity.String(), Source: w.Source, Message: w.Message, Time: w.Time, Count: w.Count, First: w.First}
+	}
+	return out
+}
+
+// warnings returns the stored warnings from v2, or from the flat messages
+// for records written before warnings were structured.
+func warnings(flat []string, v2 []WarningRecord) []core.Warning {
+	if v2 == nil {
+		if flat == nil {
+			return nil
+		}
+		out := make([]core.Warning, len(flat))
+		for i, msg := range flat {
+			out[i] = core.Warning{Severity: core.SeverityWarning, Message: msg}
+		}
+		return out
+	}
+	out := make([]core.Warning, len(v2))
+	for i, w := range v2 {
+		sev, err := core.ParseSeverity(w.Severity)
+		if err != nil {
+			sev = core.SeverityWarning
+		}
+		out[i] = core.Warning{Code: w.Code, Severity: sev, Source: w.Source, Message: w.Message, Time: w.Time, Count: w.Count, First: w.First}
+	}
+	return out
+}
+
+// latencyUnits converts durations to whole units, truncating.
+func latencyUnits(in map[string]time.Duration, unit time.Duration) map[string]int64 {
+	out := make(map[string]int64, len(in))
+	for k, v := range in {
+		out[k] = int64(v / unit)
+	}
+	return out
+}
+
+// latencies returns the probe's step durations from latencies_us, or from
+// latencies_ms for records written before microseconds were stored.
+func (p ProbeRecord) latencies() map[string]time.Duration {
+	in, unit := p.LatenciesUs, time.Microsecond
+	if in == nil {
+		in, unit = p.LatenciesMs, time.Millisecond
+	}
+	out := make(map[string]time.Duration, len(in))
+	for k, v := range in {
+		out[k] = time.Duration(v) * unit
+	}
+	return out
+}
+
+// hopRecords converts chain hops; nil stays nil.
+func hopRecords(in []core.ProbeHop) []HopRecord {
+	if in == nil {
+		return nil
+	}
+	out := make([]HopRecord, 0, len(in))
+	for _, h := range in {
+		out = append(out, HopRecord{Server: h.Server, Type: h.Type, OK: h.OK, LatencyUs: int64(h.Latency / time.Microsecond), Error: h.Error})
+	}
+	return out
+}
+
+// hops returns the probe's chain hops; nil for a single proxy.
+func (p ProbeRecord) hops() []core.ProbeHop {
+	if p.Hops == nil {
+		return nil
+	}
+	out := make([]core.ProbeHop, 0, len(p.Hops))
+	for _, h := range p.Hops {
+		out = append(out, core.ProbeHop{Server: h.Server, Type: h.Type, OK: h.OK, Latency: time.Duration(h.LatencyUs) * time.Microsecond, Error: h.Error})
+	}
+	return out
+}
+
+// targetRecords converts connect targets; nil stays nil.
+func targetRecords(in []core.ProbeTarget) []TargetRecord {
+	if in == nil {
+		return nil
+	}
+	out := make([]TargetRecord, 0, len(in))
+	for _, t := range in {
+		out = append(out, TargetRecord{Target: t.Target, OK: t.OK, LatencyUs: int64(t.Latency / time.Microsecond), Error: t.Error})
+	}
+	return out
+}
+
+// targets returns the probe's connect targets; nil for a single target.
+func (p ProbeRecord) targets() []core.ProbeTarget {
+	if p.Targets == nil {
+		return nil
+	}
+	out := make([]core.ProbeTarget, 0, len(p.Targets))
+	for _, t := range p.Targets {
+		out = append(out, core.ProbeTarget{Target: t.Target, OK: t.OK, Latency: time.Duration(t.LatencyUs) * time.Microsecond, Error: t.Error})
+	}
+	return out
+}
+
+// StateKey is the storage key holding the persisted state record.
+const StateKey = "state"
+
+// StateStore persists records under StateKey in a storage.KV.
+type StateStore struct {
+	kv storage.KV
+}
+
+// NewStateStore returns a StateStore backed by kv.
+func NewStateStore(kv storage.KV) *StateStore {
+	return &StateStore{kv: kv}
+}
+
+// Save writes rec, replacing any previous record.
+func (s *StateStore) Save(rec Record) error {
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(StateKey, append(b, '\n'))
+}
+
+// Load reads the stored record. ok is false when nothing was stored.
+func (s *StateStore) Load() (rec Record, ok bool, err error) {
+	b, ok, err := s.kv.Get(StateKey)
+	if err != nil || !ok {
+		return rec, false, err
+	}
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return rec, false, fmt.Errorf("decode %s: %w", StateKey, err)
+	}
+	if rec.Version != RecordVersion {
+		return rec, false, fmt.Errorf("unsupported state record version %d (want %d)", rec.Version, RecordVersion)
+	}
+	return rec, true, nil
+}
+
+// Restore loads the record into state and reports whether the previous run
+// ended uncleanly (any agent state other than inactive). Unclean runs are
+// restored in the error state with an explanatory warning.
+func Restore(state *core.State, rec Record) (unclean bool) {
+	snap := rec.Snapshot()
+	prev := snap.AgentState
+	unclean = prev != "" && prev != core.StateInactive
+	if unclean {
+		snap.AgentState = core.StateError
+	} else {
+		snap.AgentState = core.StateInactive
+	}
+	state.Restore(snap)
+	if unclean {
+		state.AppendWarning(core.NewWarning("persist", "unclean_shutdown", fmt.Sprintf(
+			"previous run ended uncleanly in state %q (saved %s); leftover routes or TUN devices may need recovery",
+			prev, rec.SavedAt.UTC().Format(time.RFC3339))))
+	}
+	return unclean
+}
+
+// Run saves a snapshot after each change to state, coalescing changes that
+// arrive within debounce. It writes once immediately, and once more when ctx
+// is cancelled, then returns.
+func Run(ctx context.Context, state *core.State, f *StateStore, debounce time.Duration, logger *slog.Logger) {
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+	save := func() {
+		if err := f.Save(FromSnapshot(state.GetSnapshot())); err != nil {
+			logger.Error("persist state failed", "err", err)
+			return
+		}
+		logger.Debug("state persisted")
+	}
+
+	save()
+	for {
+		select {
+		case <-ctx.Done():
+			save()
+			return
+		case <-state.Changed():
+		}
+		// Let a burst of mutations settle before writing.
+		select {
+		case <-ctx.Done():
+			save()
+			return
+		case <-time.After(debounce):
+		}
+		save()
+	}
+}
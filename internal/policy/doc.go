@@ -0,0 +1,30 @@
+// Package policy restricts which SOCKS servers and connect targets
+// non-admin API callers may probe or start against.
+//
+// # File Format
+//
+// A policy is a JSON file with two optional allowlists:
+//
+//	{
+//	  "socks_servers":   ["10.20.0.0/16", "proxy.corp.example:1080"],
+//	  "connect_targets": ["*.corp.example", "192.0.2.10:443"]
+//	}
+//
+// An omitted list leaves that field unrestricted; an empty list ([]) allows
+// nothing. Unknown keys are rejected.
+//
+// # Entries
+//
+// Each entry is an IP address, a CIDR, an exact domain, or a wildcard
+// domain ("*.corp.example" matches every name below the suffix, not the
+// suffix itself), optionally followed by ":port" ("[2001:db8::1]:1080" for
+// IPv6 addresses). Without a port every port matches.
+//
+// # Matching
+//
+// Addresses are matched as written: an IP literal only against IP and CIDR
+// entries, a hostname only against domain entries. Hostnames are never
+// resolved, because the answer at check time need not be the one used when
+// the agent connects, so a name whose addresses fall inside an allowed CIDR
+// must still be listed by name.
+package policy
@@ -0,0 +1,196 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sanverite/simple-packet-logger/internal/rules"
+)
+
+// File is the on-disk policy.
+type File struct {
+	SOCKSServers   *[]string `json:"socks_servers,omitempty"`
+	ConnectTargets *[]string `json:"connect_targets,omitempty"`
+}
+
+// Policy is a parsed policy. The zero value and nil allow everything.
+type Policy struct {
+	socks   *allowlist
+	targets *allowlist
+}
+
+// Load reads and parses the policy file at path. Unlike the config file, a
+// missing policy is an error: a deployment that names one relies on it.
+func Load(path string) (*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+	var f File
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("parse policy %s: %w", path, err)
+	}
+	p, err := New(f)
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// New validates f.
+func New(f File) (*Policy, error) {
+	var p Policy
+	var err error
+	if f.SOCKSServers != nil {
+		if p.socks, err = parseList("socks_servers", *f.SOCKSServers); err != nil {
+			return nil, err
+		}
+	}
+	if f.ConnectTargets != nil {
+		if p.targets, err = parseList("connect_targets", *f.ConnectTargets); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// AllowSOCKS reports whether addr ("host:port") may be used as a SOCKS
+// server.
+func (p *Policy) AllowSOCKS(addr string) error {
+	if p == nil {
+		return nil
+	}
+	return p.socks.check("socks server", addr)
+}
+
+// AllowTarget reports whether addr ("host:port") may be used as a connect
+// target.
+func (p *Policy) AllowTarget(addr string) error {
+	if p == nil {
+		return nil
+	}
+	return p.targets.check("connect target", addr)
+}
+
+// Summary describes the policy for logs, e.g. "socks_servers=2
+// connect_targets=any".
+func (p *Policy) Summary() string {
+	if p == nil {
+		p = &Policy{}
+	}
+	return "socks_servers=" + p.socks.size() + " connect_targets=" + p.targets.size()
+}
+
+// allowlist is one parsed list; nil allows everything.
+type allowlist struct {
+	entries []entry
+}
+
+// entry is one allowed host, with port 0 matching every port. Exactly one of
+// prefix and domain is set.
+type entry struct {
+	prefix netip.Prefix
+	domain rules.Pattern
+	port   uint16
+}
+
+func parseList(field string, in []string) (*allowlist, error) {
+	l := &allowlist{entries: make([]entry, 0, len(in))}
+	for i, raw := range in {
+		e, err := parseEntry(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d] %q: %w", field, i, raw, err)
+		}
+		l.entries = append(l.entries, e)
+	}
+	return l, nil
+}
+
+func parseEntry(raw string) (entry, error) {
+	var e entry
+	host := strings.TrimSpace(raw)
+	// A port follows a bracketed IPv6 address or the only colon; a bare
+	// IPv6 address or CIDR has several colons and no port.
+	if strings.HasPrefix(host, "[") || strings.Count(host, ":") == 1 {
+		h, port, err := net.SplitHostPort(host)
+		if err != nil {
+			return e, err
+		}
+		n, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || n == 0 {
+			return e, fmt.Errorf("invalid port %q", port)
+		}
+		host, e.port = h, uint16(n)
+	}
+	if host == "" {
+		return e, fmt.Errorf("empty host")
+	}
+	if pfx, err := netip.ParsePrefix(host); err == nil {
+		e.prefix = netip.PrefixFrom(pfx.Addr().Unmap(), unmapBits(pfx)).Masked()
+		return e, nil
+	}
+	if a, err := netip.ParseAddr(host); err == nil {
+		if a.Zone() != "" {
+			return e, fmt.Errorf("zoned addresses are not allowed")
+		}
+		a = a.Unmap()
+		e.prefix = netip.PrefixFrom(a, a.BitLen())
+		return e, nil
+	}
+	pats, err := rules.ParsePatterns([]string{host})
+	if err != nil {
+		return e, fmt.Errorf("not an IP address, CIDR, or domain")
+	}
+	e.domain = pats[0]
+	return e, nil
+}
+
+// unmapBits adjusts the prefix length of an IPv4-mapped IPv6 CIDR.
+func unmapBits(p netip.Prefix) int {
+	if p.Addr().Is4In6() {
+		return max(p.Bits()-96, 0)
+	}
+	return p.Bits()
+}
+
+func (l *allowlist) check(what, addr string) error {
+	if l == nil {
+		return nil
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", what, addr, err)
+	}
+	port, _ := strconv.ParseUint(portStr, 10, 16)
+	ip, ipErr := netip.ParseAddr(host)
+	if ipErr == nil {
+		ip = ip.WithZone("").Unmap()
+	}
+	for _, e := range l.entries {
+		if e.port != 0 && uint64(e.port) != port {
+			continue
+		}
+		if ipErr == nil && e.prefix.IsValid() && e.prefix.Contains(ip) {
+			return nil
+		}
+		if ipErr != nil && e.domain != "" && e.domain.Match(host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s is not allowed by policy", what, addr)
+}
+
+func (l *allowlist) size() string {
+	if l == nil {
+		return "any"
+	}
+	return strconv.Itoa(len(l.entries))
+}
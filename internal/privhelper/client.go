@@ -0,0 +1,177 @@
+package privhelper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/firewall"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+)
+
+// Client sends privileged operations to the helper. Each call is one
+// connection; the first that succeeds checks the helper's hello, and no
+// operation is sent until it passed.
+type Client struct {
+	socket string
+	digest string
+
+	mu    sync.Mutex
+	hello *Hello
+}
+
+// NewClient returns a client for the helper at socket. A non-empty digest
+// pins the helper executable: a helper reporting another one is refused.
+func NewClient(socket, digest string) *Client {
+	return &Client{socket: socket, digest: digest}
+}
+
+// Hello returns the helper's description, checking its protocol version
+// and digest on first contact.
+func (c *Client) Hello() (Hello, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hello != nil {
+		return *c.hello, nil
+	}
+	resp, err := c.roundTrip(request{Op: OpHello})
+	if err != nil {
+		return Hello{}, err
+	}
+	h := resp.Hello
+	switch {
+	case h == nil:
+		return Hello{}, errors.New("helper: empty hello")
+	case h.Protocol != Protocol:
+		return Hello{}, fmt.Errorf("helper speaks protocol %d, want %d", h.Protocol, Protocol)
+	case c.digest != "" && !strings.EqualFold(h.Digest, c.digest):
+		return Hello{}, fmt.Errorf("helper digest %s, want %s", h.Digest, c.digest)
+	}
+	c.hello = h
+	return *h, nil
+}
+
+// CreateTUN creates the TUN device name and returns its name.
+func (c *Client) CreateTUN(name string) (string, error) {
+	resp, err := c.call(request{Op: OpTUNCreate, Name: name})
+	return resp.Name, err
+}
+
+// ConfigureTUN assigns cfg's addresses and MTU and brings the device up.
+func (c *Client) ConfigureTUN(cfg routeplan.TUNConfig) error {
+	_, err := c.call(request{Op: OpTUNConfigure, TUN: &cfg})
+	return err
+}
+
+// RemoveTUN deletes a device made by CreateTUN.
+func (c *Client) RemoveTUN(name string) error {
+	_, err := c.call(request{Op: OpTUNRemove, Name: name})
+	return err
+}
+
+// ApplyRoute installs rt like routerepair.ApplyRoute.
+func (c *Client) ApplyRoute(rt routeplan.Route) error {
+	_, err := c.call(request{Op: OpRouteReplace, Route: &rt})
+	return err
+}
+
+// DeleteRoute removes rt like routerepair.DeleteRoute.
+func (c *Client) DeleteRoute(rt routeplan.Route) error {
+	_, err := c.call(request{Op: OpRouteDelete, Route: &rt})
+	return err
+}
+
+// BlockIPv6 installs the kill switch's blackhole routes (ipv6leak.Block).
+func (c *Client) BlockIPv6() error {
+	_, err := c.call(request{Op: OpIPv6Block})
+	return err
+}
+
+// UnblockIPv6 removes them (ipv6leak.Unblock).
+func (c *Client) UnblockIPv6() error {
+	_, err := c.call(request{Op: OpIPv6Unblock})
+	return err
+}
+
+// FirewallBackend returns a firewall.Backend that installs through the
+// helper, or ErrUnsupported wrapped when the helper has no backend.
+func (c *Client) FirewallBackend() (firewall.Backend, error) {
+	h, err := c.Hello()
+	if err != nil {
+		return nil, err
+	}
+	if h.Firewall == "" {
+		return nil, fmt.Errorf("helper: %w", firewall.ErrUnsupported)
+	}
+	local, err := firewall.ByName(h.Firewall)
+	if err != nil {
+		return nil, err
+	}
+	return helperBackend{c: c, local: local}, nil
+}
+
+// helperBackend renders rules locally and installs them through the
+// helper.
+type helperBackend struct {
+	c     *Client
+	local firewall.Backend
+}
+
+func (b helperBackend) Name() string                  { return b.local.Name() }
+func (b helperBackend) Render(r firewall.Rule) string { return b.local.Render(r) }
+
+func (b helperBackend) Apply(set string, rules []firewall.Rule) error {
+	_, err := b.c.call(request{Op: OpFirewallApply, Set: set, Rules: rules})
+	return err
+}
+
+func (b helperBackend) Remove(set string) error {
+	_, err := b.c.call(request{Op: OpFirewallRemove, Set: set})
+	return err
+}
+
+func (b helperBackend) Sets() ([]string, error) {
+	resp, err := b.c.call(request{Op: OpFirewallSets})
+	return resp.Sets, err
+}
+
+// call sends req once the helper passed Hello.
+func (c *Client) call(req request) (response, error) {
+	if _, err := c.Hello(); err != nil {
+		return response{}, err
+	}
+	return c.roundTrip(req)
+}
+
+func (c *Client) roundTrip(req request) (response, error) {
+	conn, err := net.DialTimeout("unix", c.socket, 5*time.Second)
+	if err != nil {
+		return response{}, fmt.Errorf("helper: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+	b, err := json.Marshal(req)
+	if err != nil {
+		return response{}, err
+	}
+	if _, err := conn.Write(append(b, '\n')); err != nil {
+		return response{}, fmt.Errorf("helper: %w", err)
+	}
+	line, err := bufio.NewReaderSize(conn, maxMessage).ReadSlice('\n')
+	if err != nil {
+		return response{}, fmt.Errorf("helper %s: %w", req.Op, err)
+	}
+	var resp response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return response{}, fmt.Errorf("helper %s: %w", req.Op, err)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("helper %s: %s", req.Op, resp.Error)
+	}
+	return resp, nil
+}
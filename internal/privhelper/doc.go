@@ -0,0 +1,40 @@
+// Package privhelper splits the agent's privileged operations (TUN
+// devices, route changes, firewall rules) into a small root helper, so the
+// agent and its HTTP API run unprivileged.
+//
+// # Protocol
+//
+// The helper (cmd/splhelper) listens on a unix socket, DefaultSocket
+// unless configured, created with mode 0600 and owned by the agent's
+// user. Each connection carries one request and one response, both a
+// single JSON line of at most 64 KiB: {"op": "route.replace", "route":
+// {...}} and {} or {"error": "..."}. Unknown fields are rejected. Besides
+// the socket mode, the helper reads the peer's credentials (SO_PEERCRED on
+// Linux, LOCAL_PEERCRED on macOS) and serves only the agent's uid and
+// root.
+//
+// # Validation
+//
+// The helper trusts nothing in a request. TUN names must start with its
+// prefix ("spl"); addressing must be a consistent IPv4 subnet and an MTU
+// start requests accept. Routes need a destination and either a unicast
+// gateway of the same family or an interface, which must be a helper TUN
+// or an existing non-loopback interface. Firewall sets and rules are
+// checked by package firewall before any backend runs. The kill switch's
+// blackhole routes are fixed and take no arguments. Every request is
+// logged with the peer's uid.
+//
+// # Integrity
+//
+// Verify checks the helper executable before the agent relies on it: an
+// absolute path to a regular file, owned by root and writable by no one
+// else, in directories with the same property, whose SHA-256 matches the
+// pinned digest. The helper reports its own executable's digest in hello,
+// and the Client refuses a helper with another digest or protocol
+// version, so a helper replaced after startup is noticed on the agent's
+// next connection. Install copies a build into place with those
+// properties and returns the digest to pin.
+//
+// The helper needs peer credentials and root-owned files, so it runs on
+// Linux and macOS; elsewhere Serve refuses every connection.
+package privhelper
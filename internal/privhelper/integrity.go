@@ -0,0 +1,92 @@
+package privhelper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Digest returns the SHA-256 of the file at path, hex encoded.
+func Digest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verify checks the helper executable at path before it is trusted: an
+// absolute path to a regular file that root owns and only root can
+// write, in directories only root can write, whose digest is want (when
+// set). It returns the digest.
+func Verify(path, want string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("helper path %q is not absolute", path)
+	}
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	for p := path; ; p = filepath.Dir(p) {
+		if err := rootOnly(p); err != nil {
+			return "", err
+		}
+		if p == filepath.Dir(p) {
+			break
+		}
+	}
+	got, err := Digest(path)
+	if err != nil {
+		return "", err
+	}
+	if want != "" && !strings.EqualFold(got, want) {
+		return got, fmt.Errorf("%s: sha256 %s, want %s", path, got, want)
+	}
+	return got, nil
+}
+
+// Install copies the helper executable src to dst, owned by root with
+// mode 0755, replacing dst atomically, and returns its digest after
+// verifying the result. It must run as root.
+func Install(src, dst string) (string, error) {
+	if !filepath.IsAbs(dst) {
+		return "", fmt.Errorf("install path %q is not absolute", dst)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".helper-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), in)
+	err = errors.Join(err, tmp.Chmod(0o755), tmp.Chown(0, 0), tmp.Sync(), tmp.Close())
+	if err != nil {
+		return "", fmt.Errorf("install %s: %w", dst, err)
+	}
+	want := hex.EncodeToString(h.Sum(nil))
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", err
+	}
+	return Verify(dst, want)
+}
@@ -0,0 +1,5 @@
+//go:build !unix
+
+package privhelper
+
+func rootOnly(string) error { return ErrUnsupported }
@@ -0,0 +1,29 @@
+//go:build unix
+
+package privhelper
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// rootOnly reports an error unless root owns path and neither its group
+// nor others may write it.
+func rootOnly(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%s: no ownership information", path)
+	}
+	if st.Uid != 0 {
+		return fmt.Errorf("%s is owned by uid %d, not root", path, st.Uid)
+	}
+	if fi.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("%s is writable by group or others (mode %s)", path, fi.Mode().Perm())
+	}
+	return nil
+}
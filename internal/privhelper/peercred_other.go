@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package privhelper
+
+import "net"
+
+func peerUID(*net.UnixConn) (int, error) { return -1, ErrUnsupported }
@@ -0,0 +1,64 @@
+package privhelper
+
+import (
+	"errors"
+
+	"github.com/sanverite/simple-packet-logger/internal/firewall"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+)
+
+// Protocol is the RPC version; client and helper must agree.
+const Protocol = 1
+
+// DefaultSocket is where the helper listens unless told otherwise.
+const DefaultSocket = "/run/simple-packet-logger-helper/helper.sock"
+
+// maxMessage bounds one request or response line.
+const maxMessage = 64 << 10
+
+// ErrUnsupported is returned where the helper cannot run: it needs peer
+// credentials on its socket and root-owned files, which Linux and macOS
+// provide.
+var ErrUnsupported = errors.New("privilege helper not supported on this platform")
+
+// Operations. Each request carries one; the fields it reads are listed.
+const (
+	OpHello          = "hello"           // none
+	OpTUNCreate      = "tun.create"      // Name
+	OpTUNConfigure   = "tun.configure"   // TUN
+	OpTUNRemove      = "tun.remove"      // Name
+	OpRouteReplace   = "route.replace"   // Route
+	OpRouteDelete    = "route.delete"    // Route
+	OpIPv6Block      = "ipv6.block"      // none
+	OpIPv6Unblock    = "ipv6.unblock"    // none
+	OpFirewallApply  = "firewall.apply"  // Set, Rules
+	OpFirewallRemove = "firewall.remove" // Set
+	OpFirewallSets   = "firewall.sets"   // none
+)
+
+// request is one JSON line from the agent. Unknown fields are rejected.
+type request struct {
+	Op    string               `json:"op"`
+	Name  string               `json:"name,omitempty"`
+	TUN   *routeplan.TUNConfig `json:"tun,omitempty"`
+	Route *routeplan.Route     `json:"route,omitempty"`
+	Set   string               `json:"set,omitempty"`
+	Rules []firewall.Rule      `json:"rules,omitempty"`
+}
+
+// response is the helper's JSON line back; Error is set on failure.
+type response struct {
+	Error string   `json:"error,omitempty"`
+	Name  string   `json:"name,omitempty"`
+	Sets  []string `json:"sets,omitempty"`
+	Hello *Hello   `json:"hello,omitempty"`
+}
+
+// Hello describes the running helper.
+type Hello struct {
+	Protocol int    `json:"protocol"`
+	Digest   string `json:"digest"`             // SHA-256 of the helper executable, hex
+	TUN      string `json:"tun"`                // tundev.Method
+	Routes   string `json:"routes"`             // routerepair.Method
+	Firewall string `json:"firewall,omitempty"` // backend name; "" without one
+}
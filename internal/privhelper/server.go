@@ -0,0 +1,283 @@
+package privhelper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/firewall"
+	"github.com/sanverite/simple-packet-logger/internal/ipv6leak"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+	"github.com/sanverite/simple-packet-logger/internal/routerepair"
+	"github.com/sanverite/simple-packet-logger/internal/tundev"
+)
+
+// requestTimeout bounds one connection: read, operation, and reply.
+const requestTimeout = 30 * time.Second
+
+// ServerOptions configures the helper.
+type ServerOptions struct {
+	// AllowUID is the uid the agent runs as; root is always allowed.
+	AllowUID int
+	// TUNPrefix starts every TUN name the helper creates, configures, or
+	// routes through (default "spl").
+	TUNPrefix string
+	// Digest is the helper's own executable digest, reported by hello.
+	Digest string
+	// Firewall installs rule sets; nil answers firewall operations with
+	// ErrUnsupported.
+	Firewall *firewall.Firewall
+	// FirewallBackend lists installed sets (the backend behind Firewall).
+	FirewallBackend firewall.Backend
+	// Logger receives one record per request; nil discards them.
+	Logger *slog.Logger
+}
+
+// Server answers the agent's requests on a unix socket, validating each
+// before touching the host.
+type Server struct {
+	opts ServerOptions
+}
+
+// NewServer returns a helper server.
+func NewServer(opts ServerOptions) *Server {
+	if opts.TUNPrefix == "" {
+		opts.TUNPrefix = "spl"
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.DiscardHandler)
+	}
+	return &Server{opts: opts}
+}
+
+// Listen creates the socket at path, replacing a stale one, owned by uid
+// with mode 0600, so only that user (and root) can connect.
+func Listen(path string, uid int) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Chown(path, uid, -1); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Serve handles connections on ln, one request each, until ln closes.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return
+	}
+	uid, err := peerUID(uc)
+	if err != nil {
+		s.opts.Logger.Warn("peer credentials unavailable", "err", err)
+		return
+	}
+	// The line is read before refusing a peer, so it gets the answer
+	// rather than a reset.
+	var req request
+	line, err := bufio.NewReaderSize(conn, maxMessage).ReadSlice('\n')
+	if uid != 0 && uid != s.opts.AllowUID {
+		s.opts.Logger.Warn("request refused", "uid", uid)
+		writeResponse(conn, response{Error: "permission denied"})
+		return
+	}
+	if err == nil {
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&req)
+	}
+	if err != nil {
+		writeResponse(conn, response{Error: "bad request: " + err.Error()})
+		return
+	}
+	resp, err := s.dispatch(req, uid)
+	if err != nil {
+		resp = response{Error: err.Error()}
+		s.opts.Logger.Warn("request failed", "uid", uid, "op", req.Op, "err", err)
+	} else if req.Op != OpHello {
+		s.opts.Logger.Info("request done", "uid", uid, "op", req.Op, "name", target(req))
+	}
+	writeResponse(conn, resp)
+}
+
+// dispatch validates req from the peer uid and performs it.
+func (s *Server) dispatch(req request, uid int) (response, error) {
+	switch req.Op {
+	case OpHello:
+		h := &Hello{Protocol: Protocol, Digest: s.opts.Digest, TUN: tundev.Method(), Routes: routerepair.Method()}
+		if s.opts.Firewall != nil {
+			h.Firewall = s.opts.Firewall.Backend()
+		}
+		return response{Hello: h}, nil
+	case OpTUNCreate:
+		if err := s.checkTUN(req.Name); err != nil {
+			return response{}, err
+		}
+		name, err := tundev.Create(req.Name)
+		if err == nil && uid != 0 {
+			// The agent's engines attach to the device themselves.
+			if err = tundev.SetOwner(name, uid); err != nil {
+				_ = tundev.Remove(name)
+			}
+		}
+		return response{Name: name}, err
+	case OpTUNConfigure:
+		if req.TUN == nil {
+			return response{}, errors.New("tun.configure: no tun")
+		}
+		if err := s.checkTUNConfig(*req.TUN); err != nil {
+			return response{}, err
+		}
+		return response{}, tundev.Configure(*req.TUN)
+	case OpTUNRemove:
+		if err := s.checkTUN(req.Name); err != nil {
+			return response{}, err
+		}
+		return response{}, tundev.Remove(req.Name)
+	case OpRouteReplace, OpRouteDelete:
+		if req.Route == nil {
+			return response{}, fmt.Errorf("%s: no route", req.Op)
+		}
+		if err := s.checkRoute(*req.Route); err != nil {
+			return response{}, err
+		}
+		if req.Op == OpRouteDelete {
+			return response{}, routerepair.DeleteRoute(*req.Route)
+		}
+		return response{}, routerepair.ApplyRoute(*req.Route)
+	case OpIPv6Block:
+		return response{}, ipv6leak.Block()
+	case OpIPv6Unblock:
+		return response{}, ipv6leak.Unblock()
+	case OpFirewallApply, OpFirewallRemove, OpFirewallSets:
+		if s.opts.Firewall == nil {
+			return response{}, firewall.ErrUnsupported
+		}
+		switch req.Op {
+		case OpFirewallApply:
+			return response{}, s.opts.Firewall.Install(req.Set, req.Rules)
+		case OpFirewallRemove:
+			return response{}, s.opts.Firewall.Remove(req.Set)
+		}
+		sets, err := s.opts.FirewallBackend.Sets()
+		return response{Sets: sets}, err
+	}
+	return response{}, fmt.Errorf("unknown operation %q", req.Op)
+}
+
+// checkTUN accepts only names under the TUN prefix (a "%d" lets the
+// kernel number it), so the helper never touches other interfaces.
+func (s *Server) checkTUN(name string) error {
+	rest, ok := strings.CutPrefix(name, s.opts.TUNPrefix)
+	if !ok || len(name) > 15 {
+		return fmt.Errorf("tun %q: not a %s* device", name, s.opts.TUNPrefix)
+	}
+	for _, c := range rest {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c == '%') {
+			return fmt.Errorf("tun %q: invalid name", name)
+		}
+	}
+	return nil
+}
+
+func (s *Server) checkTUNConfig(cfg routeplan.TUNConfig) error {
+	if err := s.checkTUN(cfg.Name); err != nil {
+		return err
+	}
+	if cfg.MTU < 576 || cfg.MTU > routeplan.MaxMTU { // the range start requests accept
+		return fmt.Errorf("tun %s: invalid mtu %d", cfg.Name, cfg.MTU)
+	}
+	if !cfg.Local4.Is4() || !cfg.Subnet4.IsValid() || !cfg.Subnet4.Contains(cfg.Local4) ||
+		(cfg.Peer4.IsValid() && !cfg.Subnet4.Contains(cfg.Peer4)) {
+		return fmt.Errorf("tun %s: invalid ipv4 addressing", cfg.Name)
+	}
+	if cfg.Local6.IsValid() && (!cfg.Local6.Addr().Is6() || cfg.Local6.Addr().Is4In6()) {
+		return fmt.Errorf("tun %s: invalid ipv6 address %s", cfg.Name, cfg.Local6)
+	}
+	return nil
+}
+
+// checkRoute accepts routes through the TUN, or via a unicast gateway on
+// an existing non-loopback interface.
+func (s *Server) checkRoute(rt routeplan.Route) error {
+	if !rt.Dst.IsValid() {
+		return errors.New("route without destination")
+	}
+	if rt.Via.IsValid() {
+		if rt.Via.Is4() != rt.Dst.Addr().Is4() || !usableGateway(rt.Via) {
+			return fmt.Errorf("route %s: invalid gateway %s", rt.Dst, rt.Via)
+		}
+	}
+	switch {
+	case rt.Dev == "":
+		if !rt.Via.IsValid() {
+			return fmt.Errorf("route %s: needs a gateway or an interface", rt.Dst)
+		}
+	case strings.HasPrefix(rt.Dev, s.opts.TUNPrefix):
+		return s.checkTUN(rt.Dev)
+	default:
+		ifi, err := net.InterfaceByName(rt.Dev)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", rt.Dst, err)
+		}
+		if ifi.Flags&net.FlagLoopback != 0 {
+			return fmt.Errorf("route %s: loopback interface %s", rt.Dst, rt.Dev)
+		}
+	}
+	return nil
+}
+
+func usableGateway(a netip.Addr) bool {
+	return !a.IsUnspecified() && !a.IsLoopback() && !a.IsMulticast()
+}
+
+// target picks the object a request acted on, for the audit log.
+func target(req request) string {
+	switch {
+	case req.Name != "":
+		return req.Name
+	case req.TUN != nil:
+		return req.TUN.Name
+	case req.Route != nil:
+		return req.Route.Dst.String()
+	}
+	return req.Set
+}
+
+func writeResponse(conn net.Conn, resp response) {
+	b, _ := json.Marshal(resp)
+	_, _ = conn.Write(append(b, '\n'))
+}
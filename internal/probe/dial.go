@@ -0,0 +1,91 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/happyeyeballs"
+)
+
+// DialSOCKS opens a TCP connection to target ("host:port") through the
+// SOCKS5 proxy at server using CONNECT. Hostnames in target are sent to the
+// proxy unresolved, so lookups happen on the far side of the tunnel. The
+// handshake honors ctx's deadline; the returned connection has no deadline.
+func DialSOCKS(ctx context.Context, server string, auth *Auth, target string) (net.Conn, error) {
+	serverHost, serverPort, err := splitHostPortStrict(server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid socks server: %w", err)
+	}
+	targetHost, targetPort, err := splitHostPortStrict(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+	if _, _, _, _, err := encodeSocksAddress(targetHost, targetPort); err != nil {
+		return nil, err
+	}
+
+	conn, err := happyeyeballs.Dial(ctx, net.JoinHostPort(serverHost, serverPort))
+	if err != nil {
+		return nil, err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	// Unblock reads and writes if ctx is cancelled mid-handshake.
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	fail := func(err error) (net.Conn, error) {
+		stop()
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	if _, err := doSocksGreeting(conn, auth); err != nil {
+		return fail(err)
+	}
+	if err := doSocksConnect(conn, targetHost, targetPort); err != nil {
+		return fail(err)
+	}
+	if !stop() {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// doSocksConnect sends CONNECT to host:port over a connection that
+// completed the greeting, and consumes the reply.
+func doSocksConnect(conn net.Conn, host, port string) error {
+	atyp, addrBytes, portBytes, _, err := encodeSocksAddress(host, port)
+	if err != nil {
+		return err
+	}
+	req := make([]byte, 0, 4+len(addrBytes)+2)
+	req = append(req, 0x05, 0x01, 0x00, atyp)
+	req = append(req, addrBytes...)
+	req = append(req, portBytes...)
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("write CONNECT: %w", err)
+	}
+	var hdr [4]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return fmt.Errorf("read CONNECT reply: %w", err)
+	}
+	if hdr[0] != 0x05 {
+		return errors.New("bad connect reply version")
+	}
+	if hdr[1] != 0x00 {
+		return fmt.Errorf("socks connect failed: %s", repToString(hdr[1]))
+	}
+	if err := discardReplyBindAddr(conn, hdr[3]); err != nil {
+		return fmt.Errorf("read CONNECT reply addr: %w", err)
+	}
+	return nil
+}
@@ -30,14 +30,96 @@
 //   - SocksOK:     true if greeting (and user/pass, when required) succeeded.
 //   - ConnectOK:   true if CONNECT to the target succeeded.
 //   - UDPOK:       true if a minimal UDP ASSOCIATE succeeded.
-//   - LatenciesMs: per-step timings in ms ("tcp_connect", "socks_handshake",
-//     "connect", "udp_associate" when applicable).
+//   - Latencies:   per-step durations ("tcp_connect", "socks_handshake",
+//     "connect", "udp_associate" when applicable), at full clock precision.
 //   - Features:    discovered capabilities (Auth method, IPv6 when an IPv6
-//     literal CONNECT succeeds). The UDP feature flag is reserved
-//     for richer validation and remains false in this minimal probe.
-//   - Warnings:    non-fatal anomalies collected during the run.
+//     literal CONNECT succeeds, PathMTU from the connected socket on
+//     Linux, Family: the address family the proxy was reached over). A
+//     server hostname is dialed over IPv6 and IPv4 in parallel
+//     (happyeyeballs, RFC 8305), so a broken family costs 250ms rather
+//     than the connect timeout. The UDP feature flag is reserved for
+//     richer validation and remains false in this minimal probe.
+//   - Warnings:    non-fatal anomalies collected during the run, with
+//     source "probe" and a code naming the failed step, e.g.
+//     "tcp_connect_failed", "socks_handshake_failed", "connect_failed",
+//     "udp_associate_failed".
 //   - LastChecked: wall-clock timestamp when the probe completed.
 //
+// # Chains
+//
+// Config.Type TypeHTTP makes Server an HTTP CONNECT proxy, and Config.Chain
+// lists proxies reached through it. Either runs the hop-by-hop variant: after
+// the TCP connect, each hop completes its handshake (SOCKS5 greeting, or
+// none for HTTP) and a CONNECT to the next hop, the last to ConnectTarget.
+// ProbeSummary.Hops reports every hop, entry first, with its latency and the
+// error of the one that failed; SocksOK means all handshakes succeeded. UDP
+// ASSOCIATE is not tried, since a relay behind a chain is not reachable.
+// FormatChain and ParseChain carry a chain in the socks5 probe's "chain"
+// option.
+//
+// # Connect Targets
+//
+// Config.ConnectTargets verifies several destinations (an intranet host, a
+// public site, an IPv6 literal) through the proxy in one probe. A CONNECT
+// consumes its connection, so each extra target is probed in parallel over
+// its own connection and handshake, without UDP or tracing; the first
+// target is the probe's own CONNECT test and decides its result. Each is
+// reported in ProbeSummary.Targets, and a failed extra target is a
+// connect_target_failed warning.
+//
+// # TLS Check
+//
+// Config.TLS performs a TLS handshake with the connect target over the
+// CONNECT stream, offering its SNI and ALPN. The certificate chain is
+// verified against the system roots after the handshake rather than
+// during it, so ProbeSummary.TLS reports both whether TLS passes the proxy
+// at all and whether the certificate checks out: a valid handshake with an
+// unverifiable chain suggests interception. Neither fails the probe; each
+// is a warning (tls_handshake_failed, tls_cert_invalid). The stream is
+// spent afterwards, so UDPTest is skipped. With ConnectTargets, only the
+// probe's own target is checked.
+//
+// # HTTP Check
+//
+// Config.HTTP fetches a canary URL over the CONNECT stream (after a TLS
+// handshake for https, implied when Config.TLS is unset) and checks the
+// status (any 2xx by default) and, optionally, a body substring. It
+// catches what CONNECT alone cannot: captive portals answering with their
+// own page, and proxies that accept CONNECT but blackhole the data. The
+// URL's host and port are the connect target. Unlike the TLS check, a
+// failed HTTP check fails the probe (http_check_failed).
+//
+// # Tracing
+//
+// With Config.Trace the probe connection is wrapped to record every byte
+// sent and received, from the greeting on, into ProbeSummary.Trace:
+// consecutive bytes in one direction (and hop) form one entry, hex-dumped
+// with its offset from the connect. Bytes matching a hop's password or
+// its HTTP Basic credentials are masked as "**". A trace is capped at
+// 16 KiB. FormatTrace renders it as text.
+//
+// # Probe Registry
+//
+// Probe is the plugin interface (Name, Run(ctx, Params) -> core.ProbeSummary).
+// Register adds a probe, typically from an init function in a fork or
+// extension package; the API then accepts its name in POST /v1/probe "type"
+// and lists it at GET /v1/probe/types without changes to this package.
+// Built-ins: "socks5" (ProbeSOCKS; options username, password,
+// connect_target, connect_targets, udp_test, tls, tls_server_name, tls_alpn,
+// http_url, http_expect_status, http_expect_body, proxy_type, chain, trace), "tcp" (plain connect to Params.Target), and
+// "wireguard" (a handshake with the peer at Params.Target; options
+// private_key, public_key, preshared_key).
+//
+// Callers run probes through Run, which masks the values of SecretOptions
+// (and credentials redact recognizes) in the summary's warnings and the
+// returned error, so a proxy password never reaches status, events, or logs.
+//
+// # Dialing Through the Proxy
+//
+// DialSOCKS reuses the probe's handshake to open a CONNECT stream through
+// the proxy for other subsystems (e.g., the DNS forwarder). Hostnames are
+// passed to the proxy unresolved.
+//
 // # Error Model
 //
 // Transport or protocol failures return a non-nil error; the summary still
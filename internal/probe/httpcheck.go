@@ -0,0 +1,152 @@
+package probe
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// maxHTTPCheckBody bounds the response body read by the HTTP check.
+const maxHTTPCheckBody = 64 << 10
+
+// HTTPCheck asks the probe for a full HTTP GET through the proxy once
+// CONNECT succeeded, to catch captive portals and proxies that accept
+// CONNECT but drop the data.
+type HTTPCheck struct {
+	// URL is the canary to fetch ("http://" or "https://"); its host and
+	// port are the probe's connect target.
+	URL string
+	// ExpectStatus is the status the response must have; zero accepts any
+	// 2xx.
+	ExpectStatus int
+	// ExpectBody, when set, must appear in the first 64 KiB of the body.
+	ExpectBody string
+}
+
+// HTTPTarget returns the connect target ("host:port") of an HTTP check
+// URL, with the scheme's default port.
+func HTTPTarget(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid http check url: %w", err)
+	}
+	port := u.Port()
+	switch u.Scheme {
+	case "http":
+		if port == "" {
+			port = "80"
+		}
+	case "https":
+		if port == "" {
+			port = "443"
+		}
+	default:
+		return "", fmt.Errorf("http check url %q: scheme must be http or https", rawURL)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("http check url %q: no host", rawURL)
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// withHTTPTarget points cfg.ConnectTarget at the HTTP check URL. An
+// explicit connect target must name the same endpoint.
+func withHTTPTarget(cfg Config) (Config, error) {
+	if cfg.HTTP == nil {
+		return cfg, nil
+	}
+	target, err := HTTPTarget(cfg.HTTP.URL)
+	if err != nil {
+		return cfg, err
+	}
+	if t := strings.TrimSpace(cfg.ConnectTarget); t != "" && t != target {
+		return cfg, fmt.Errorf("connect target %s differs from the http check url's %s", t, target)
+	}
+	cfg.ConnectTarget = target
+	return cfg, nil
+}
+
+// checkStream runs the checks that use the CONNECT stream after CONNECT
+// succeeded: the TLS handshake (cfg.TLS, or implied by an https HTTP
+// check) and then the HTTP GET. Only a failed HTTP check is an error; TLS
+// problems are warnings.
+func checkStream(ctx context.Context, conn net.Conn, targetHost string, cfg Config, summary *core.ProbeSummary, latencies map[string]time.Duration, warns *[]core.Warning) error {
+	tlsCheck := cfg.TLS
+	if cfg.HTTP != nil && strings.HasPrefix(cfg.HTTP.URL, "https:") && tlsCheck == nil {
+		tlsCheck = &TLSCheck{ALPN: []string{"http/1.1"}}
+	}
+	stream := conn
+	if tlsCheck != nil {
+		stream = probeTLS(ctx, conn, targetHost, tlsCheck, summary, latencies)
+		*warns = append(*warns, tlsWarnings(summary.TLS)...)
+	}
+	if cfg.HTTP == nil {
+		return nil
+	}
+	res := &core.ProbeHTTP{URL: cfg.HTTP.URL}
+	summary.HTTP = res
+	if stream == nil {
+		res.Error = "tls handshake failed"
+	} else {
+		start := time.Now()
+		err := httpGet(ctx, stream, cfg.HTTP, res)
+		latencies["http_get"] = elapsedSince(start)
+		if err != nil {
+			res.Error = err.Error()
+		}
+	}
+	if res.Error != "" {
+		*warns = append(*warns, warning("http_check_failed", "http check failed: "+res.Error))
+		return fmt.Errorf("http check: %s", res.Error)
+	}
+	res.OK = true
+	return nil
+}
+
+// httpGet fetches check.URL over stream and validates the response into
+// res.
+func httpGet(ctx context.Context, stream net.Conn, check *HTTPCheck, res *core.ProbeHTTP) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "spl-probe")
+	req.Header.Set("Connection", "close")
+	if err := req.Write(stream); err != nil {
+		return fmt.Errorf("write request: %w", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(stream), req)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	defer resp.Body.Close()
+	res.Status = resp.StatusCode
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPCheckBody))
+	res.BodyBytes = len(body)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("read body: %w", err)
+	}
+	switch {
+	case check.ExpectStatus != 0 && resp.StatusCode != check.ExpectStatus:
+		return fmt.Errorf("status %d, want %d", resp.StatusCode, check.ExpectStatus)
+	case check.ExpectStatus == 0 && resp.StatusCode/100 != 2:
+		return fmt.Errorf("status %d, want 2xx", resp.StatusCode)
+	}
+	if check.ExpectBody != "" {
+		matched := strings.Contains(string(body), check.ExpectBody)
+		res.BodyMatched = &matched
+		if !matched {
+			return fmt.Errorf("body does not contain %q", check.ExpectBody)
+		}
+	}
+	return nil
+}
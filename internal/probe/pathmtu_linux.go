@@ -0,0 +1,32 @@
+//go:build linux
+
+package probe
+
+import (
+	"net"
+	"syscall"
+)
+
+// pathMTU returns the kernel's path MTU for a connected TCP socket
+// (IP_MTU or IPV6_MTU), or 0 when it cannot be read.
+func pathMTU(conn net.Conn) int {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return 0
+	}
+	raw, err := tc.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	level, opt := syscall.IPPROTO_IP, syscall.IP_MTU
+	if a, ok := tc.RemoteAddr().(*net.TCPAddr); ok && a.IP.To4() == nil {
+		level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_MTU
+	}
+	mtu := 0
+	_ = raw.Control(func(fd uintptr) {
+		if v, err := syscall.GetsockoptInt(int(fd), level, opt); err == nil {
+			mtu = v
+		}
+	})
+	return mtu
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package probe
+
+import "net"
+
+// pathMTU returns 0: only Linux exposes a socket's path MTU.
+func pathMTU(net.Conn) int { return 0 }
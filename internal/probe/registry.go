@@ -0,0 +1,202 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// Params is the probe-agnostic input passed to Probe.Run.
+type Params struct {
+	// Target is the endpoint under test, usually "host:port".
+	Target string
+	// Timeout bounds the run; probes apply their own default when zero.
+	Timeout time.Duration
+	// Options carries probe-specific settings as strings (see each probe's
+	// documentation). Unknown keys should be ignored.
+	Options map[string]string
+	// Logger receives probe records; nil disables logging.
+	Logger *slog.Logger
+}
+
+// Probe is a named, bounded check. Implementations follow the same rules as
+// ProbeSOCKS: honor ctx, return partial results alongside errors, and never
+// leave goroutines behind.
+type Probe interface {
+	Name() string
+	Run(ctx context.Context, p Params) (core.ProbeSummary, error)
+}
+
+// Describer is optionally implemented by probes to document themselves in
+// GET /v1/probe/types.
+type Describer interface {
+	Description() string
+}
+
+var (
+	regMu  sync.RWMutex
+	probes = map[string]Probe{}
+)
+
+// Register adds p to the registry. It panics on an empty or duplicate name,
+// as registration is expected to happen in init.
+func Register(p Probe) {
+	name := p.Name()
+	if name == "" {
+		panic("probe: empty probe name")
+	}
+	regMu.Lock()
+	defer regMu.Unlock()
+	if _, dup := probes[name]; dup {
+		panic("probe: duplicate probe " + name)
+	}
+	probes[name] = p
+}
+
+// Lookup returns the probe registered under name.
+func Lookup(name string) (Probe, bool) {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	p, ok := probes[name]
+	return p, ok
+}
+
+// Registered returns all registered probes sorted by name.
+func Registered() []Probe {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	out := make([]Probe, 0, len(probes))
+	for _, p := range probes {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
+
+// SecretOptions are the Params.Options whose values are credentials.
+var SecretOptions = []string{"password", "private_key", "preshared_key"}
+
+// Run runs pr and removes the values of p's SecretOptions and other
+// credentials from its warnings and error, so callers can log, record, or
+// serve the result. Built-in probes redact themselves; Run covers plugins.
+func Run(ctx context.Context, pr Probe, p Params) (core.ProbeSummary, error) {
+	summary, err := pr.Run(ctx, p)
+	secrets := make([]string, 0, len(SecretOptions))
+	for _, k := range SecretOptions {
+		secrets = append(secrets, p.Options[k])
+	}
+	r := redact.New(secrets...)
+	summary.Warnings = redactWarnings(r, summary.Warnings)
+	return summary, r.Error(err)
+}
+
+// warning returns a probe warning with code.
+func warning(code, msg string) core.Warning {
+	return core.NewWarning("probe", code, msg)
+}
+
+// redactWarnings returns ws with r applied to each message. A nil list
+// stays nil.
+func redactWarnings(r *redact.Redactor, ws []core.Warning) []core.Warning {
+	if ws == nil {
+		return nil
+	}
+	out := make([]core.Warning, len(ws))
+	for i, w := range ws {
+		w.Message = r.String(w.Message)
+		out[i] = w
+	}
+	return out
+}
+
+// Names of the built-in probes.
+const (
+	NameSOCKS5    = "socks5"
+	NameTCP       = "tcp"
+	NameWireGuard = "wireguard"
+)
+
+func init() {
+	Register(socksProbe{})
+	Register(tcpProbe{})
+	Register(wireGuardProbe{})
+}
+
+// socksProbe adapts ProbeSOCKS to the Probe interface. Options:
+// "username", "password", "connect_target", "udp_test" ("true"/"false"),
+// "proxy_type" (Config.Type), "chain" (Config.Chain, see FormatChain),
+// "connect_targets" (Config.ConnectTargets, see FormatTargets), "tls"
+// ("true"/"false", Config.TLS) with "tls_server_name" and "tls_alpn"
+// (comma-separated), "http_url" (Config.HTTP) with "http_expect_status" and
+// "http_expect_body", and "trace" ("true"/"false", Config.Trace).
+type socksProbe struct{}
+
+func (socksProbe) Name() string { return NameSOCKS5 }
+
+func (socksProbe) Description() string {
+	return "SOCKS5 handshake, CONNECT, and optional UDP ASSOCIATE against target"
+}
+
+func (socksProbe) Run(ctx context.Context, p Params) (core.ProbeSummary, error) {
+	cfg := Config{
+		Server:        p.Target,
+		Timeout:       p.Timeout,
+		ConnectTarget: p.Options["connect_target"],
+		Type:          p.Options["proxy_type"],
+		Logger:        p.Logger,
+	}
+	if v := p.Options["chain"]; v != "" {
+		chain, err := ParseChain(v)
+		if err != nil {
+			return core.ProbeSummary{LastChecked: time.Now()}, err
+		}
+		cfg.Chain = chain
+	}
+	if u, pw := p.Options["username"], p.Options["password"]; u != "" || pw != "" {
+		cfg.Auth = &Auth{Username: u, Password: pw}
+	}
+	if v := p.Options["udp_test"]; v != "" {
+		udp, err := strconv.ParseBool(v)
+		if err != nil {
+			return core.ProbeSummary{LastChecked: time.Now()}, fmt.Errorf("invalid udp_test option %q", v)
+		}
+		cfg.UDPTest = udp
+	}
+	if v := p.Options["tls"]; v != "" {
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return core.ProbeSummary{LastChecked: time.Now()}, fmt.Errorf("invalid tls option %q", v)
+		}
+		if on {
+			cfg.TLS = &TLSCheck{ServerName: p.Options["tls_server_name"], ALPN: splitList(p.Options["tls_alpn"])}
+		}
+	}
+	if v := p.Options["http_url"]; v != "" {
+		cfg.HTTP = &HTTPCheck{URL: v, ExpectBody: p.Options["http_expect_body"]}
+		if s := p.Options["http_expect_status"]; s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 100 || n > 599 {
+				return core.ProbeSummary{LastChecked: time.Now()}, fmt.Errorf("invalid http_expect_status option %q", s)
+			}
+			cfg.HTTP.ExpectStatus = n
+		}
+	}
+	if v := p.Options["connect_targets"]; v != "" {
+		cfg.ConnectTargets = ParseTargets(v)
+	}
+	if v := p.Options["trace"]; v != "" {
+		trace, err := strconv.ParseBool(v)
+		if err != nil {
+			return core.ProbeSummary{LastChecked: time.Now()}, fmt.Errorf("invalid trace option %q", v)
+		}
+		cfg.Trace = trace
+	}
+	return ProbeSOCKS(ctx, cfg)
+}
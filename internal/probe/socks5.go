@@ -8,12 +8,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/happyeyeballs"
+	"github.com/sanverite/simple-packet-logger/internal/logging"
+	"github.com/sanverite/simple-packet-logger/internal/redact"
 )
 
 // Auth holds optional username/password credentials for SOCKS5 "user/pass" auth (method 0x02).
@@ -41,9 +45,41 @@ type Config struct {
 	// Accepts "host:port" where host may be an IP (v4/v6) or a DNS name.
 	ConnectTarget string
 
+	// ConnectTargets lists more destinations to verify, each over its own
+	// connection and handshake; ConnectTarget (or, when empty, the first
+	// of these) remains the probe's own CONNECT test. Each is reported in
+	// ProbeSummary.Targets. At most MaxConnectTargets.
+	ConnectTargets []string
+
 	// UDPTest requests a minimal UDP ASSOCIATE exchange. A success reply sets UDPOK=true.
 	// This does not perform end-to-end UDP payload verification.
 	UDPTest bool
+
+	// Type is Server's protocol: TypeSOCKS5 (also when empty) or TypeHTTP.
+	Type string
+
+	// Chain lists proxies reached through Server, in order; the CONNECT test
+	// goes through the last. Each proxy is reported in ProbeSummary.Hops.
+	Chain []Hop
+
+	// TLS, when set, performs a TLS handshake with the connect target
+	// through the proxy after CONNECT, reported in ProbeSummary.TLS. It
+	// replaces UDPTest, which would write into the TLS stream.
+	TLS *TLSCheck
+
+	// HTTP, when set, fetches a canary URL through the proxy after CONNECT
+	// (and TLS, for https) and validates the response, reported in
+	// ProbeSummary.HTTP; a failed check fails the probe. The URL's host
+	// and port are the connect target. It also replaces UDPTest.
+	HTTP *HTTPCheck
+
+	// Trace records the bytes exchanged with the proxy in ProbeSummary.Trace,
+	// hex-dumped, with passwords and Basic credentials masked.
+	Trace bool
+
+	// Logger receives debug records for each probe step and a summary record.
+	// Nil disables probe logging.
+	Logger *slog.Logger
 }
 
 // Sensible defaults for production probes.
@@ -61,17 +97,47 @@ const (
 // It returns a core.ProbeSummary with per-step latencies and discovered features.
 // Errors indicate probe execution/validation failures; the returned summary includes
 // as much signal as possible (e.g., partial latencies, warnings).
-func ProbeSOCKS(ctx context.Context, cfg Config) (core.ProbeSummary, error) {
+//
+// An HTTP proxy or a Chain is checked hop by hop instead; see probeChain.
+//
+// With ConnectTargets, each target is probed in parallel; see probeTargets.
+func ProbeSOCKS(ctx context.Context, cfg Config) (summary core.ProbeSummary, err error) {
+	if cfg, err = withHTTPTarget(cfg); err != nil {
+		return core.ProbeSummary{LastChecked: time.Now()}, err
+	}
+	if len(cfg.ConnectTargets) > 0 {
+		return probeTargets(ctx, cfg)
+	}
+	if len(cfg.Chain) > 0 || hopType(cfg.Type) != TypeSOCKS5 {
+		return probeChain(ctx, cfg)
+	}
 	var (
-		warns     []string
-		latencies = make(map[string]int64, 4)
-		summary   core.ProbeSummary
+		warns     []core.Warning
+		latencies = make(map[string]time.Duration, 4)
 	)
+	logger := cfg.Logger
+	if logger == nil {
+		logger = logging.Discard()
+	}
+	logger = logger.With("server", cfg.Server)
 	defer func() {
-		// Populate summary fields that are always set.
-		summary.LatenciesMs = latencies
-		summary.Warnings = warns
+		// Populate summary fields that are always set. Errors and warnings
+		// may quote the server or the proxy's replies; keep credentials out.
+		var r *redact.Redactor
+		if cfg.Auth != nil {
+			r = redact.New(cfg.Auth.Password)
+		}
+		err = r.Error(err)
+		summary.Latencies = latencies
+		summary.Warnings = redactWarnings(r, warns)
 		summary.LastChecked = time.Now()
+		if err != nil {
+			logger.Warn("probe failed", "err", err, "reachable", summary.Reachable,
+				"socks_ok", summary.SocksOK, "connect_ok", summary.ConnectOK)
+			return
+		}
+		logger.Info("probe completed", "connect_ok", summary.ConnectOK, "udp_ok", summary.UDPOK,
+			"auth", summary.Features.Auth)
 	}()
 
 	// Validate and normalize inputs.
@@ -87,6 +153,7 @@ func ProbeSOCKS(ctx context.Context, cfg Config) (core.ProbeSummary, error) {
 	if strings.TrimSpace(connectTarget) == "" {
 		connectTarget = DefaultConnectTarget
 	}
+	summary.Target = connectTarget
 	targetHost, targetPort, err := splitHostPortStrict(connectTarget)
 	if err != nil {
 		return summary, fmt.Errorf("invalid connect target: %w", err)
@@ -97,32 +164,43 @@ func ProbeSOCKS(ctx context.Context, cfg Config) (core.ProbeSummary, error) {
 	defer cancel()
 	deadline := time.Now().Add(timeout)
 
-	// Setup dialer and perform TCP connect.
-	dialer := &net.Dialer{}
+	// TCP connect; a hostname is dialed over both families (RFC 8305).
 	t0 := time.Now()
-	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(serverHost, serverPort))
-	latencies["tcp_connect"] = millisSince(t0)
+	conn, err := happyeyeballs.Dial(ctx, net.JoinHostPort(serverHost, serverPort))
+	latencies["tcp_connect"] = elapsedSince(t0)
 	if err != nil {
-		warns = append(warns, "tcp connect failed: "+err.Error())
+		warns = append(warns, warning("tcp_connect_failed", "tcp connect failed: "+err.Error()))
 		return summary, err
 	}
 	defer conn.Close()
 	// TCP is reachable once connect succeeded.
 	summary.Reachable = true
+	summary.Features.PathMTU = pathMTU(conn)
+	summary.Features.Family = happyeyeballs.Family(conn)
+	logger.Debug("tcp connected", "latency", latencies["tcp_connect"], "remote", conn.RemoteAddr())
+	if cfg.Trace {
+		tr := newTracer(cfg.Auth)
+		conn = &traceConn{Conn: conn, t: tr}
+		defer tr.finish(&summary, &warns)
+	}
 
-	// Ensure socket operations respect the global deadline.
+	// Ensure socket operations respect the global deadline, and unblock
+	// them if ctx is canceled (e.g. DELETE /v1/probe/active).
 	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
 
 	// Perform SOCKS5 greeting and optional auth.
 	handshakeStart := time.Now()
 	methodUsed, err := doSocksGreeting(conn, cfg.Auth)
-	latencies["socks_handshake"] = millisSince(handshakeStart)
+	latencies["socks_handshake"] = elapsedSince(handshakeStart)
 	if err != nil {
-		warns = append(warns, "socks handshake failed: "+err.Error())
+		warns = append(warns, warning("socks_handshake_failed", "socks handshake failed: "+err.Error()))
 		return summary, err
 	}
 	// Greeting (and any required auth) succeeded.
 	summary.SocksOK = true
+	logger.Debug("socks handshake ok", "method", methodUsed, "latency", latencies["socks_handshake"])
 
 	// Record features based on negotiated method.
 	switch methodUsed {
@@ -132,14 +210,14 @@ func ProbeSOCKS(ctx context.Context, cfg Config) (core.ProbeSummary, error) {
 		summary.Features.Auth = "userpass"
 	default:
 		// Should not happen because doSocksGreeting enforces methods.
-		warns = append(warns, fmt.Sprintf("unexpected method selected: 0x%02x", methodUsed))
+		warns = append(warns, warning("socks_unexpected_method", fmt.Sprintf("unexpected method selected: 0x%02x", methodUsed)))
 	}
 
 	// Build and send CONNECT request.
 	connectStart := time.Now()
 	atyp, addrBytes, portBytes, ipv6Target, err := encodeSocksAddress(targetHost, targetPort)
 	if err != nil {
-		warns = append(warns, "invalid connect target encoding: "+err.Error())
+		warns = append(warns, warning("connect_target_invalid", "invalid connect target encoding: "+err.Error()))
 		return summary, err
 	}
 	connectReq := make([]byte, 0, 3+1+len(addrBytes)+2)
@@ -148,48 +226,60 @@ func ProbeSOCKS(ctx context.Context, cfg Config) (core.ProbeSummary, error) {
 	connectReq = append(connectReq, addrBytes...)
 	connectReq = append(connectReq, portBytes...)
 	if _, err := conn.Write(connectReq); err != nil {
-		warns = append(warns, "write CONNECT failed: "+err.Error())
+		warns = append(warns, warning("connect_io_failed", "write CONNECT failed: "+err.Error()))
 		return summary, err
 	}
 	// Read CONNECT reply: VER, REP, RSV, ATYP, BND.ADDR, BND.PORT
 	// We read the fixed header first, then discard the bound address as per RFC 1928.
 	var hdr [4]byte
 	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
-		warns = append(warns, "read CONNECT reply header failed: "+err.Error())
+		warns = append(warns, warning("connect_io_failed", "read CONNECT reply header failed: "+err.Error()))
 		return summary, err
 	}
 	if hdr[0] != 0x05 {
-		warns = append(warns, fmt.Sprintf("unexpected reply version: 0x%02x", hdr[0]))
+		warns = append(warns, warning("connect_reply_invalid", fmt.Sprintf("unexpected reply version: 0x%02x", hdr[0])))
 		return summary, fmt.Errorf("bad connect reply version")
 	}
 	rep := hdr[1]
 	if rep != 0x00 {
 		msg := repToString(rep)
-		warns = append(warns, "connect failed: "+msg)
-		latencies["connect"] = millisSince(connectStart)
+		warns = append(warns, warning("connect_failed", "connect failed: "+msg))
+		latencies["connect"] = elapsedSince(connectStart)
 		// Not a transport error; return a descriptive error.
 		return summary, fmt.Errorf("socks connect failed: %s", msg)
 	}
 	// Consume the bound address in the reply based on ATYP.
 	if err := discardReplyBindAddr(conn, hdr[3]); err != nil {
-		warns = append(warns, "read CONNECT reply addr failed: "+err.Error())
+		warns = append(warns, warning("connect_io_failed", "read CONNECT reply addr failed: "+err.Error()))
 		return summary, err
 	}
-	latencies["connect"] = millisSince(connectStart)
+	latencies["connect"] = elapsedSince(connectStart)
 
 	// CONNECT succeeded.
 	summary.ConnectOK = true
+	logger.Debug("connect ok", "target", connectTarget, "latency", latencies["connect"])
 	// If we connected to an IPv6 literal successfully, we can claim IPv6 egress support.
 	summary.Features.IPv6 = ipv6Target
 
+	// Optionally check TLS and HTTP to the target; the stream is then spent.
+	if cfg.TLS != nil || cfg.HTTP != nil {
+		err := checkStream(ctx, conn, targetHost, cfg, &summary, latencies, &warns)
+		if cfg.UDPTest {
+			w := warning("udp_test_skipped", "udp test skipped: the CONNECT stream carried the TLS or HTTP check")
+			w.Severity = core.SeverityInfo
+			warns = append(warns, w)
+		}
+		return summary, err
+	}
+
 	// Optionally test UDP ASSOCIATE.
 	if cfg.UDPTest {
 		udpStart := time.Now()
 		udpOK, udpWarn := doUDPAssociate(conn)
 		if udpWarn != "" {
-			warns = append(warns, udpWarn)
+			warns = append(warns, warning("udp_associate_failed", udpWarn))
 		}
-		latencies["udp_associate"] = millisSince(udpStart)
+		latencies["udp_associate"] = elapsedSince(udpStart)
 		summary.UDPOK = udpOK
 	}
 	return summary, nil
@@ -397,11 +487,7 @@ func splitHostPortStrict(hp string) (host, port string, err error) {
 	return host, port, nil
 }
 
-// millisSince returns the elapsed milliseconds since t0, clamped at zero.
-func millisSince(t0 time.Time) int64 {
-	diff := time.Since(t0)
-	if diff < 0 {
-		return 0
-	}
-	return diff.Milliseconds()
+// elapsedSince returns the time elapsed since t0, clamped at zero.
+func elapsedSince(t0 time.Time) time.Duration {
+	return max(time.Since(t0), 0)
 }
@@ -0,0 +1,99 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// MaxConnectTargets bounds Config.ConnectTargets.
+const MaxConnectTargets = 16
+
+// FormatTargets renders targets as the "connect_targets" option of the
+// socks5 probe (comma-separated).
+func FormatTargets(targets []string) string {
+	return strings.Join(targets, ",")
+}
+
+// ParseTargets reads the form FormatTargets writes; empty entries are
+// skipped.
+func ParseTargets(s string) []string {
+	return splitList(s)
+}
+
+// splitList splits a comma-separated option, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// probeTargets runs ProbeSOCKS for cfg.ConnectTargets. The first target
+// (or cfg.ConnectTarget, when set) is the probe's own CONNECT test and
+// decides its result; every other target gets its own connection,
+// handshake, and CONNECT, all in parallel. Each is reported in
+// summary.Targets, in order; a failed extra target is a warning.
+func probeTargets(ctx context.Context, cfg Config) (core.ProbeSummary, error) {
+	var targets []string
+	for _, t := range append([]string{cfg.ConnectTarget}, cfg.ConnectTargets...) {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(targets, t) {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) > MaxConnectTargets {
+		return core.ProbeSummary{LastChecked: time.Now()}, fmt.Errorf("connect_targets: at most %d targets", MaxConnectTargets)
+	}
+	for _, t := range targets {
+		if _, _, err := splitHostPortStrict(t); err != nil {
+			return core.ProbeSummary{LastChecked: time.Now()}, fmt.Errorf("invalid connect target %q: %w", t, err)
+		}
+	}
+
+	main := cfg
+	main.ConnectTarget, main.ConnectTargets = targets[0], nil
+	var (
+		summary core.ProbeSummary
+		err     error
+		wg      sync.WaitGroup
+	)
+	results := make([]core.ProbeTarget, len(targets))
+	wg.Go(func() { summary, err = ProbeSOCKS(ctx, main) })
+	for i, t := range targets[1:] {
+		wg.Go(func() {
+			extra := cfg
+			extra.ConnectTarget, extra.ConnectTargets = t, nil
+			extra.UDPTest, extra.Trace, extra.TLS, extra.HTTP = false, false, nil, nil
+			s, err := ProbeSOCKS(ctx, extra)
+			results[i+1] = targetResult(t, s, err)
+		})
+	}
+	wg.Wait()
+
+	results[0] = targetResult(targets[0], summary, err)
+	for _, r := range results[1:] {
+		if !r.OK {
+			summary.Warnings = append(summary.Warnings, warning("connect_target_failed",
+				fmt.Sprintf("connect to %s failed: %s", r.Target, r.Error)))
+		}
+	}
+	summary.Targets = results
+	return summary, err
+}
+
+// targetResult condenses one target's probe.
+func targetResult(target string, s core.ProbeSummary, err error) core.ProbeTarget {
+	r := core.ProbeTarget{Target: target, OK: s.ConnectOK, Latency: s.Latencies["connect"]}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -0,0 +1,56 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// tcpProbe checks plain TCP reachability of target. It sets Reachable and
+// ConnectOK on success and records "tcp_connect". It takes no options.
+type tcpProbe struct{}
+
+func (tcpProbe) Name() string { return NameTCP }
+
+func (tcpProbe) Description() string { return "plain TCP connect to target" }
+
+func (tcpProbe) Run(ctx context.Context, p Params) (summary core.ProbeSummary, err error) {
+	summary.Latencies = map[string]time.Duration{}
+	logger := p.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	defer func() {
+		summary.LastChecked = time.Now()
+		if err != nil {
+			summary.Warnings = append(summary.Warnings, warning("probe_failed", err.Error()))
+		}
+		logger.Debug("tcp probe finished", "target", p.Target, "reachable", summary.Reachable, "err", err)
+	}()
+
+	if _, _, err := splitHostPortStrict(p.Target); err != nil {
+		return summary, fmt.Errorf("invalid target: %w", err)
+	}
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t0 := time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.Target)
+	if err != nil {
+		return summary, fmt.Errorf("tcp connect failed: %w", err)
+	}
+	_ = conn.Close()
+	summary.Latencies["tcp_connect"] = elapsedSince(t0)
+	summary.Reachable = true
+	summary.ConnectOK = true
+	return summary, nil
+}
@@ -0,0 +1,91 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// TLSCheck asks the probe for a TLS handshake with the connect target
+// through the proxy once CONNECT succeeded.
+type TLSCheck struct {
+	// ServerName is the SNI to send and the name the certificate must
+	// match; empty uses the target host (no SNI for an IP literal).
+	ServerName string
+	// ALPN lists protocols to offer, e.g. "h2", "http/1.1".
+	ALPN []string
+}
+
+// probeTLS runs the handshake described by check over conn, which is
+// connected to targetHost through the proxy, and records the result in
+// summary. The certificate is checked separately from the handshake, so
+// an invalid chain (e.g. an intercepting middlebox) is reported rather
+// than failing it; a failed handshake is reported in summary.TLS too. It
+// returns the TLS connection, or nil when the handshake failed.
+func probeTLS(ctx context.Context, conn net.Conn, targetHost string, check *TLSCheck, summary *core.ProbeSummary, latencies map[string]time.Duration) net.Conn {
+	name := check.ServerName
+	if name == "" {
+		name = targetHost
+	}
+	cfg := &tls.Config{
+		NextProtos: check.ALPN,
+		// Verified below, so the result can report an invalid chain.
+		InsecureSkipVerify: true,
+	}
+	if net.ParseIP(name) == nil {
+		cfg.ServerName = name
+	}
+	res := &core.ProbeTLS{ServerName: name}
+	summary.TLS = res
+
+	start := time.Now()
+	tc := tls.Client(conn, cfg)
+	err := tc.HandshakeContext(ctx)
+	latencies["tls_handshake"] = elapsedSince(start)
+	if err != nil {
+		res.Error = err.Error()
+		return nil
+	}
+	st := tc.ConnectionState()
+	res.OK = true
+	res.Version = tls.VersionName(st.Version)
+	res.CipherSuite = tls.CipherSuiteName(st.CipherSuite)
+	res.ALPN = st.NegotiatedProtocol
+	res.ChainLen = len(st.PeerCertificates)
+	if len(st.PeerCertificates) == 0 {
+		res.VerifyError = "no certificate"
+		return tc
+	}
+	leaf := st.PeerCertificates[0]
+	res.Subject = leaf.Subject.String()
+	res.Issuer = leaf.Issuer.String()
+	res.NotAfter = leaf.NotAfter
+	opts := x509.VerifyOptions{DNSName: name, Intermediates: x509.NewCertPool()}
+	for _, c := range st.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	if _, err := leaf.Verify(opts); err != nil {
+		res.VerifyError = err.Error()
+		return tc
+	}
+	res.Verified = true
+	return tc
+}
+
+// tlsWarnings returns the warnings for a TLS result: a failed handshake,
+// or a certificate that does not verify (possible interception).
+func tlsWarnings(res *core.ProbeTLS) []core.Warning {
+	switch {
+	case res == nil:
+		return nil
+	case !res.OK:
+		return []core.Warning{warning("tls_handshake_failed", "tls handshake failed: "+res.Error)}
+	case !res.Verified:
+		return []core.Warning{warning("tls_cert_invalid", "tls certificate not valid for "+res.ServerName+": "+res.VerifyError)}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// Directions of a trace entry.
+const (
+	TraceSend = "send" // to the proxy
+	TraceRecv = "recv" // from the proxy
+)
+
+// maxTraceBytes bounds the bytes a trace records; later traffic is left out
+// and a warning notes the truncation.
+const maxTraceBytes = 16 << 10
+
+// tracer records the bytes exchanged on a probe connection. Consecutive
+// reads or writes in the same direction and hop form one entry, so the
+// trace reads as the protocol's messages rather than its syscalls.
+type tracer struct {
+	start     time.Time
+	hop       int
+	secrets   [][]byte
+	entries   []traceEntry
+	total     int
+	truncated bool
+}
+
+// traceEntry is one recorded message before rendering.
+type traceEntry struct {
+	hop    int
+	dir    string
+	offset time.Duration
+	data   []byte
+}
+
+// newTracer starts a trace at the moment the connection is up. Each auth's
+// password, and the Basic credentials an HTTP hop sends, are masked.
+func newTracer(auths ...*Auth) *tracer {
+	t := &tracer{start: time.Now()}
+	for _, a := range auths {
+		if a == nil {
+			continue
+		}
+		if a.Password != "" {
+			t.secrets = append(t.secrets, []byte(a.Password))
+		}
+		cred := base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password))
+		t.secrets = append(t.secrets, []byte(cred))
+	}
+	return t
+}
+
+// add records b in direction dir.
+func (t *tracer) add(dir string, b []byte) {
+	if len(b) == 0 || t.truncated {
+		return
+	}
+	if t.total+len(b) > maxTraceBytes {
+		b = b[:maxTraceBytes-t.total]
+		t.truncated = true
+	}
+	t.total += len(b)
+	if n := len(t.entries); n > 0 && t.entries[n-1].dir == dir && t.entries[n-1].hop == t.hop {
+		t.entries[n-1].data = append(t.entries[n-1].data, b...)
+		return
+	}
+	t.entries = append(t.entries, traceEntry{
+		hop:    t.hop,
+		dir:    dir,
+		offset: time.Since(t.start),
+		data:   bytes.Clone(b),
+	})
+}
+
+// finish renders the trace into summary, masking credentials, and notes a
+// truncation in warns.
+func (t *tracer) finish(summary *core.ProbeSummary, warns *[]core.Warning) {
+	if t == nil {
+		return
+	}
+	out := make([]core.ProbeTraceEntry, 0, len(t.entries))
+	for _, e := range t.entries {
+		mask := t.mask(e.data)
+		out = append(out, core.ProbeTraceEntry{
+			Hop:    e.hop,
+			Dir:    e.dir,
+			Offset: e.offset,
+			Len:    len(e.data),
+			Hex:    traceHex(e.data, mask),
+			Text:   traceText(e.data, mask),
+		})
+	}
+	summary.Trace = out
+	if t.truncated {
+		w := warning("trace_truncated", fmt.Sprintf("trace truncated after %d bytes", maxTraceBytes))
+		w.Severity = core.SeverityInfo
+		*warns = append(*warns, w)
+	}
+}
+
+// mask marks the bytes of b that belong to a secret.
+func (t *tracer) mask(b []byte) []bool {
+	m := make([]bool, len(b))
+	for _, s := range t.secrets {
+		for i := 0; ; {
+			j := bytes.Index(b[i:], s)
+			if j < 0 {
+				break
+			}
+			for k := i + j; k < i+j+len(s); k++ {
+				m[k] = true
+			}
+			i += j + len(s)
+		}
+	}
+	return m
+}
+
+// traceHex renders b as space-separated hex pairs, masked bytes as "**".
+func traceHex(b []byte, mask []bool) string {
+	var sb strings.Builder
+	for i, c := range b {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if mask[i] {
+			sb.WriteString("**")
+			continue
+		}
+		sb.WriteString(hex.EncodeToString([]byte{c}))
+	}
+	return sb.String()
+}
+
+// traceText renders b as printable ASCII, other bytes as '.' and masked
+// ones as '*'.
+func traceText(b []byte, mask []bool) string {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		switch {
+		case mask[i]:
+			out[i] = '*'
+		case c >= 0x20 && c < 0x7f:
+			out[i] = c
+		default:
+			out[i] = '.'
+		}
+	}
+	return string(out)
+}
+
+// traceConn records a connection's traffic in a tracer.
+type traceConn struct {
+	net.Conn
+	t *tracer
+}
+
+func (c *traceConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.t.add(TraceRecv, b[:n])
+	return n, err
+}
+
+func (c *traceConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.t.add(TraceSend, b[:n])
+	return n, err
+}
+
+// FormatTrace renders a trace as text, one entry per line, e.g.
+// "+0.412ms send 3: 05 01 00".
+func FormatTrace(trace []core.ProbeTraceEntry) string {
+	var sb strings.Builder
+	for _, e := range trace {
+		fmt.Fprintf(&sb, "+%.3fms ", float64(e.Offset.Microseconds())/1000)
+		if e.Hop > 0 {
+			fmt.Fprintf(&sb, "hop %d ", e.Hop)
+		}
+		fmt.Fprintf(&sb, "%s %d: %s\n", e.Dir, e.Len, e.Hex)
+	}
+	return sb.String()
+}
@@ -0,0 +1,86 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/wireguard"
+)
+
+// wireGuardProbe performs a WireGuard handshake with the peer at target
+// and authenticates its response. Options: "private_key" (this host's,
+// base64), "public_key" (the peer's), and "preshared_key". Any answer,
+// including a cookie reply, sets Reachable; an authenticated response
+// also sets ConnectOK and records "wg_handshake". The handshake carries
+// no data and does not set up a session the peer keeps using.
+type wireGuardProbe struct{}
+
+func (wireGuardProbe) Name() string { return NameWireGuard }
+
+func (wireGuardProbe) Description() string {
+	return "WireGuard handshake with the peer at target (options: private_key, public_key, preshared_key)"
+}
+
+func (wireGuardProbe) Run(ctx context.Context, p Params) (summary core.ProbeSummary, err error) {
+	summary.Latencies = map[string]time.Duration{}
+	logger := p.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	defer func() {
+		summary.LastChecked = time.Now()
+		if err != nil {
+			summary.Warnings = append(summary.Warnings, warning("probe_failed", err.Error()))
+		}
+		logger.Debug("wireguard probe finished", "target", p.Target, "handshake", summary.ConnectOK, "err", err)
+	}()
+
+	if _, _, err := splitHostPortStrict(p.Target); err != nil {
+		return summary, fmt.Errorf("invalid target: %w", err)
+	}
+	cfg := wireguard.Config{Peer: wireguard.Peer{Endpoint: p.Target}}
+	keys := []struct {
+		opt      string
+		dst      *wireguard.Key
+		required bool
+	}{
+		{"private_key", &cfg.PrivateKey, true},
+		{"public_key", &cfg.Peer.PublicKey, true},
+		{"preshared_key", &cfg.Peer.PresharedKey, false},
+	}
+	for _, k := range keys {
+		v := p.Options[k.opt]
+		if v == "" {
+			if k.required {
+				return summary, fmt.Errorf("option %s is required", k.opt)
+			}
+			continue
+		}
+		if *k.dst, err = wireguard.ParseKey(v); err != nil {
+			return summary, fmt.Errorf("option %s: %w", k.opt, err)
+		}
+	}
+
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	res, err := wireguard.Handshake(ctx, cfg)
+	switch {
+	case errors.Is(err, wireguard.ErrCookie):
+		summary.Reachable = true
+		return summary, err
+	case err != nil:
+		return summary, err
+	}
+	summary.Reachable = true
+	summary.ConnectOK = true
+	summary.Latencies["wg_handshake"] = res.RTT
+	return summary, nil
+}
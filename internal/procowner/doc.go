@@ -0,0 +1,21 @@
+// Package procowner attributes local sockets to the process that owns them.
+//
+// # Lookup
+//
+// Lookup takes a protocol ("tcp" or "udp") and the local address of a flow
+// as seen on the TUN, and returns the owning process: PID, executable path,
+// and user. It is best effort by design:
+//
+//   - Linux reads /proc/net/{tcp,tcp6,udp,udp6} for the socket inode and
+//     owner UID, then scans /proc/<pid>/fd for the inode. Without root,
+//     other users' processes yield an Owner with UID but no PID or Exe.
+//   - macOS runs lsof for the PID and user, and ps for the executable path.
+//   - Other platforms return ErrUnsupported.
+//
+// Short-lived sockets may be gone before the lookup runs (ErrNotFound), and
+// an unbound UDP socket matches its port on the wildcard address. Callers
+// must treat a failed lookup as "unknown owner", never as a match.
+//
+// Method names the mechanism in use ("procfs", "lsof", or "unsupported") so
+// APIs can report how reliable attribution is.
+package procowner
@@ -0,0 +1,47 @@
+package procowner
+
+import (
+	"errors"
+	"net/netip"
+	"os/user"
+	"strconv"
+)
+
+// ErrNotFound is returned when no socket matches the local address.
+var ErrNotFound = errors.New("no socket owns this address")
+
+// ErrUnsupported is returned on platforms without an implementation.
+var ErrUnsupported = errors.New("process attribution not supported on this platform")
+
+// Owner is the process behind a socket. Fields the platform could not
+// determine are zero (PID 0, Exe "").
+type Owner struct {
+	PID  int
+	Exe  string // absolute executable path
+	UID  int    // -1 when unknown
+	User string // login name; "" when unknown
+}
+
+// Lookup returns the owner of the local socket proto ("tcp" or "udp") bound
+// to local.
+func Lookup(proto string, local netip.AddrPort) (Owner, error) {
+	if proto != "tcp" && proto != "udp" {
+		return Owner{}, errors.New("procowner: protocol must be tcp or udp")
+	}
+	return lookup(proto, netip.AddrPortFrom(local.Addr().Unmap(), local.Port()))
+}
+
+// Method reports the attribution mechanism on this platform.
+func Method() string { return method }
+
+// userName resolves uid to a login name, or "" if unknown.
+func userName(uid int) string {
+	if uid < 0 {
+		return ""
+	}
+	u, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return ""
+	}
+	return u.Username
+}
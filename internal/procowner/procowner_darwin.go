@@ -0,0 +1,64 @@
+//go:build darwin
+
+package procowner
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const method = "lsof"
+
+func lookup(proto string, local netip.AddrPort) (Owner, error) {
+	host := local.Addr().String()
+	if local.Addr().Is6() {
+		host = "[" + host + "]"
+	}
+	// -F pu: one "p<pid>" line per process followed by "u<uid>".
+	spec := fmt.Sprintf("%s@%s:%d", proto, host, local.Port())
+	out, err := exec.Command("lsof", "-nP", "-F", "pu", "-i", spec).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(out) == 0 {
+		return Owner{}, ErrNotFound // lsof exits 1 when nothing matches
+	}
+	if err != nil {
+		return Owner{}, fmt.Errorf("lsof -i %s: %w", spec, err)
+	}
+	o, ok := parseLsof(out)
+	if !ok {
+		return Owner{}, ErrNotFound
+	}
+	o.User = userName(o.UID)
+	if b, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(o.PID)).Output(); err == nil {
+		o.Exe = strings.TrimSpace(string(b))
+	}
+	return o, nil
+}
+
+// parseLsof reads the first process from lsof -F pu output.
+func parseLsof(out []byte) (Owner, bool) {
+	o := Owner{UID: -1}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'p':
+			if o.PID != 0 {
+				return o, true
+			}
+			o.PID, _ = strconv.Atoi(line[1:])
+		case 'u':
+			o.UID, _ = strconv.Atoi(line[1:])
+		}
+	}
+	return o, o.PID != 0
+}
@@ -0,0 +1,132 @@
+//go:build linux
+
+package procowner
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const method = "procfs"
+
+func lookup(proto string, local netip.AddrPort) (Owner, error) {
+	// Dual-stack sockets bound to :: only appear in the "6" table, so IPv4
+	// lookups search both.
+	files := []string{"/proc/net/" + proto + "6"}
+	if local.Addr().Is4() {
+		files = []string{"/proc/net/" + proto, "/proc/net/" + proto + "6"}
+	}
+	var inode uint64
+	uid := -1
+	err := ErrNotFound
+	for _, f := range files {
+		if inode, uid, err = findSocket(f, local); err != ErrNotFound {
+			break
+		}
+	}
+	if err != nil {
+		return Owner{}, err
+	}
+	o := Owner{UID: uid, User: userName(uid)}
+	if pid := findPID(inode); pid > 0 {
+		o.PID = pid
+		o.Exe, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	}
+	return o, nil
+}
+
+// findSocket scans a /proc/net table for local, falling back to a socket
+// bound to the wildcard address on the same port.
+func findSocket(file string, local netip.AddrPort) (inode uint64, uid int, err error) {
+	f, err := os.Open(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, -1, ErrNotFound // e.g. IPv6 disabled
+	}
+	if err != nil {
+		return 0, -1, err
+	}
+	defer f.Close()
+
+	var wildInode uint64
+	wildUID := -1
+	sc := bufio.NewScanner(f)
+	sc.Scan() // header
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 10 {
+			continue
+		}
+		ap, ok := parseHexAddrPort(fields[1])
+		if !ok || ap.Port() != local.Port() {
+			continue
+		}
+		u, _ := strconv.Atoi(fields[7])
+		ino, _ := strconv.ParseUint(fields[9], 10, 64)
+		if ino == 0 {
+			continue // TIME_WAIT and similar have no owner
+		}
+		switch {
+		case ap.Addr() == local.Addr():
+			return ino, u, nil
+		case ap.Addr().IsUnspecified() && wildInode == 0:
+			wildInode, wildUID = ino, u
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return 0, -1, err
+	}
+	if wildInode != 0 {
+		return wildInode, wildUID, nil
+	}
+	return 0, -1, ErrNotFound
+}
+
+// parseHexAddrPort decodes "0100007F:1F90": the address is stored as 32-bit
+// words in host (little-endian) order, the port in big-endian hex.
+func parseHexAddrPort(s string) (netip.AddrPort, bool) {
+	hexAddr, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return netip.AddrPort{}, false
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, false
+	}
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(raw[i:], binary.LittleEndian.Uint32(raw[i:]))
+	}
+	addr, _ := netip.AddrFromSlice(raw)
+	return netip.AddrPortFrom(addr.Unmap(), uint16(port)), true
+}
+
+// findPID scans /proc/<pid>/fd for the socket inode; 0 if not visible.
+func findPID(inode uint64) int {
+	target := "socket:[" + strconv.FormatUint(inode, 10) + "]"
+	procs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, dir := range procs {
+		fds, err := os.ReadDir(dir + "/fd")
+		if err != nil {
+			continue // exited, or another user's process
+		}
+		for _, fd := range fds {
+			if link, err := os.Readlink(dir + "/fd/" + fd.Name()); err == nil && link == target {
+				pid, _ := strconv.Atoi(filepath.Base(dir))
+				return pid
+			}
+		}
+	}
+	return 0
+}
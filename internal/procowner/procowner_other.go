@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package procowner
+
+import "net/netip"
+
+const method = "unsupported"
+
+func lookup(string, netip.AddrPort) (Owner, error) { return Owner{}, ErrUnsupported }
@@ -0,0 +1,27 @@
+// Package profiles stores named upstream configurations for starts.
+//
+// # Profiles
+//
+// A profile names a SOCKS server with its auth_ref (see package secrets),
+// TUN MTU, bypass hosts, and DNS settings (upstreams for the forwarder, or
+// none at all), so a start can name "work" instead of repeating them.
+// Validate checks what can be checked offline; bypass hostnames are
+// resolved only when a start uses the profile, since they may resolve only
+// on the network the profile is for.
+//
+// # Network Match
+//
+// A profile's Match lists the networks it is for: Wi-Fi SSIDs, default
+// gateway MACs, and DNS search domains (see package netloc). Select picks
+// the profile for a location: every rule list the profile sets must
+// contain the current value, and the profile matching the most lists wins,
+// so "office Wi-Fi and corp.example" beats "corp.example" alone. Profiles
+// without rules are never selected.
+//
+// # Persistence
+//
+// With a storage.KV, every change rewrites the whole list under
+// ProfilesKey. The file backend writes a temporary file and renames it, so
+// a crash leaves either the old list or the new one, never a mix. A failed
+// write leaves the in-memory list unchanged.
+package profiles
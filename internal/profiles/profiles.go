@@ -0,0 +1,330 @@
+package profiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/dnsproxy"
+	"github.com/sanverite/simple-packet-logger/internal/netloc"
+	"github.com/sanverite/simple-packet-logger/internal/secrets"
+	"github.com/sanverite/simple-packet-logger/internal/storage"
+)
+
+// Limits.
+const (
+	MaxProfiles    = 64
+	MaxNameLen     = 64
+	MaxBypassHosts = 256
+)
+
+// Selection modes: what the agent does with the profile matching the
+// current network.
+const (
+	SelectOff     = "off"     // do not watch the network
+	SelectSuggest = "suggest" // report it in events and GET /v1/profiles
+	SelectApply   = "apply"   // also use it for starts that name no server
+)
+
+// ProfilesKey is the storage key holding the persisted profile list.
+const ProfilesKey = "profiles"
+
+// Errors returned by Store.
+var (
+	ErrExists   = errors.New("profile already exists")
+	ErrNotFound = errors.New("profile not found")
+	ErrFull     = fmt.Errorf("too many profiles (max %d)", MaxProfiles)
+)
+
+// Profile is a named upstream configuration. Zero fields leave the start
+// request's own value (or the agent's default) in place.
+type Profile struct {
+	Name         string    `json:"name"`
+	SocksServer  string    `json:"socks_server"`
+	AuthRef      string    `json:"auth_ref,omitempty"`
+	MTU          int       `json:"mtu,omitempty"`
+	BypassHosts  []string  `json:"bypass_hosts,omitempty"`
+	DNSUpstreams []string  `json:"dns_upstreams,omitempty"`
+	DisableDNS   bool      `json:"disable_dns,omitempty"`
+	Match        *Match    `json:"match,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Match lists the networks a profile is for. Every non-empty list must
+// contain the current value (any one of the search domains) for the
+// profile to match; a Match with no lists never matches.
+type Match struct {
+	SSIDs         []string `json:"ssids,omitempty"`
+	GatewayMACs   []string `json:"gateway_macs,omitempty"`
+	SearchDomains []string `json:"search_domains,omitempty"`
+}
+
+// Validate checks p without network access.
+func (p Profile) Validate() error {
+	if err := ValidName(p.Name); err != nil {
+		return err
+	}
+	if _, port, err := net.SplitHostPort(p.SocksServer); err != nil {
+		return fmt.Errorf("socks_server: %w", err)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("socks_server: invalid port %q", port)
+	}
+	if p.AuthRef != "" {
+		if err := secrets.ValidName(p.AuthRef); err != nil {
+			return fmt.Errorf("auth_ref: %w", err)
+		}
+	}
+	if p.MTU < 0 || (p.MTU > 0 && (p.MTU < 576 || p.MTU > 9000)) {
+		return errors.New("mtu must be 0 or between 576 and 9000")
+	}
+	if len(p.BypassHosts) > MaxBypassHosts {
+		return fmt.Errorf("bypass_hosts: at most %d entries", MaxBypassHosts)
+	}
+	for _, h := range p.BypassHosts {
+		if h == "" {
+			return errors.New("bypass_hosts: empty entry")
+		}
+	}
+	if p.DisableDNS && len(p.DNSUpstreams) > 0 {
+		return errors.New("dns_upstreams and disable_dns are mutually exclusive")
+	}
+	if len(p.DNSUpstreams) > 0 {
+		if _, err := dnsproxy.ParseUpstreams(p.DNSUpstreams); err != nil {
+			return fmt.Errorf("dns_upstreams: %w", err)
+		}
+	}
+	if m := p.Match; m != nil {
+		for _, mac := range m.GatewayMACs {
+			if _, err := netloc.NormalizeMAC(mac); err != nil {
+				return fmt.Errorf("match.gateway_macs: %w", err)
+			}
+		}
+		for _, list := range [][]string{m.SSIDs, m.SearchDomains} {
+			if slices.Contains(list, "") {
+				return errors.New("match: empty entry")
+			}
+		}
+	}
+	return nil
+}
+
+// normalize returns m with MACs and domains normalized, or nil when it
+// has no rules. m must be valid.
+func (m *Match) normalize() *Match {
+	if m == nil || len(m.SSIDs)+len(m.GatewayMACs)+len(m.SearchDomains) == 0 {
+		return nil
+	}
+	out := &Match{SSIDs: slices.Clone(m.SSIDs)}
+	for _, mac := range m.GatewayMACs {
+		n, _ := netloc.NormalizeMAC(mac)
+		out.GatewayMACs = append(out.GatewayMACs, n)
+	}
+	for _, d := range m.SearchDomains {
+		out.SearchDomains = append(out.SearchDomains, netloc.NormalizeDomain(d))
+	}
+	return out
+}
+
+// matches reports whether loc satisfies every rule of m, and describes the
+// rules that matched.
+func (m *Match) matches(loc netloc.Location) ([]string, bool) {
+	if m == nil {
+		return nil, false
+	}
+	var why []string
+	if len(m.SSIDs) > 0 {
+		if loc.SSID == "" || !slices.Contains(m.SSIDs, loc.SSID) {
+			return nil, false
+		}
+		why = append(why, "ssid "+loc.SSID)
+	}
+	if len(m.GatewayMACs) > 0 {
+		if loc.GatewayMAC == "" || !slices.Contains(m.GatewayMACs, loc.GatewayMAC) {
+			return nil, false
+		}
+		why = append(why, "gateway mac "+loc.GatewayMAC)
+	}
+	if len(m.SearchDomains) > 0 {
+		i := slices.IndexFunc(loc.SearchDomains, func(d string) bool { return slices.Contains(m.SearchDomains, d) })
+		if i < 0 {
+			return nil, false
+		}
+		why = append(why, "search domain "+loc.SearchDomains[i])
+	}
+	return why, len(why) > 0
+}
+
+// ValidName reports whether name is 1-MaxNameLen characters of letters,
+// digits, '-', '_', or '.'.
+func ValidName(name string) error {
+	if name == "" || len(name) > MaxNameLen {
+		return fmt.Errorf("profile name must be 1-%d characters", MaxNameLen)
+	}
+	for _, c := range name {
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.'
+		if !ok {
+			return fmt.Errorf("profile name %q has invalid characters", name)
+		}
+	}
+	return nil
+}
+
+// Store holds profiles, optionally persisted in a KV.
+type Store struct {
+	kv  storage.KV
+	now func() time.Time
+
+	mu       sync.Mutex
+	profiles map[string]Profile
+}
+
+// Open loads profiles from kv (nil keeps them in memory only).
+func Open(kv storage.KV) (*Store, error) {
+	s := &Store{kv: kv, now: time.Now, profiles: map[string]Profile{}}
+	if kv == nil {
+		return s, nil
+	}
+	b, ok, err := kv.Get(ProfilesKey)
+	if err != nil || !ok {
+		return s, err
+	}
+	var list []Profile
+	if err := json.Unmarshal(b, &list); err != nil {
+		return s, fmt.Errorf("decode profiles: %w", err)
+	}
+	for _, p := range list {
+		s.profiles[p.Name] = p
+	}
+	return s, nil
+}
+
+// Get returns the named profile.
+func (s *Store) Get(name string) (Profile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.profiles[name]
+	return cloneProfile(p), ok
+}
+
+// List returns every profile sorted by name.
+func (s *Store) List() []Profile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listLocked()
+}
+
+// Select returns the profile whose match rules fit loc, and the rules that
+// matched. When several fit, the one matching the most rules wins, then
+// the first by name.
+func (s *Store) Select(loc netloc.Location) (Profile, []string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		best    Profile
+		bestWhy []string
+		found   bool
+	)
+	for _, p := range s.listLocked() {
+		why, ok := p.Match.matches(loc)
+		if ok && (!found || len(why) > len(bestWhy)) {
+			best, bestWhy, found = p, why, true
+		}
+	}
+	return best, bestWhy, found
+}
+
+// Create adds p, which must be valid and not exist yet.
+func (s *Store) Create(p Profile) (Profile, error) {
+	p, _, err := s.put(p, false)
+	return p, err
+}
+
+// Put adds p or replaces the profile with its name. It reports whether
+// the profile was new.
+func (s *Store) Put(p Profile) (Profile, bool, error) {
+	return s.put(p, true)
+}
+
+func (s *Store) put(p Profile, replace bool) (Profile, bool, error) {
+	if err := p.Validate(); err != nil {
+		return Profile{}, false, err
+	}
+	p = cloneProfile(p)
+	p.Match = p.Match.normalize()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, exists := s.profiles[p.Name]
+	switch {
+	case exists && !replace:
+		return Profile{}, false, ErrExists
+	case !exists && len(s.profiles) >= MaxProfiles:
+		return Profile{}, false, ErrFull
+	}
+	p.UpdatedAt = s.now().UTC().Truncate(time.Second)
+	s.profiles[p.Name] = p
+	if err := s.saveLocked(); err != nil {
+		if exists {
+			s.profiles[p.Name] = old
+		} else {
+			delete(s.profiles, p.Name)
+		}
+		return Profile{}, false, fmt.Errorf("persist profiles: %w", err)
+	}
+	return cloneProfile(p), !exists, nil
+}
+
+// Delete removes the named profile and returns it.
+func (s *Store) Delete(name string) (Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.profiles[name]
+	if !ok {
+		return Profile{}, ErrNotFound
+	}
+	delete(s.profiles, name)
+	if err := s.saveLocked(); err != nil {
+		s.profiles[name] = p
+		return Profile{}, fmt.Errorf("persist profiles: %w", err)
+	}
+	return p, nil
+}
+
+func (s *Store) listLocked() []Profile {
+	out := make([]Profile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		out = append(out, cloneProfile(p))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *Store) saveLocked() error {
+	if s.kv == nil {
+		return nil
+	}
+	b, err := json.Marshal(s.listLocked())
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(ProfilesKey, b)
+}
+
+func cloneProfile(p Profile) Profile {
+	p.BypassHosts = append([]string(nil), p.BypassHosts...)
+	p.DNSUpstreams = append([]string(nil), p.DNSUpstreams...)
+	if p.Match != nil {
+		p.Match = &Match{
+			SSIDs:         slices.Clone(p.Match.SSIDs),
+			GatewayMACs:   slices.Clone(p.Match.GatewayMACs),
+			SearchDomains: slices.Clone(p.Match.SearchDomains),
+		}
+	}
+	return p
+}
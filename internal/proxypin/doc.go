@@ -0,0 +1,31 @@
+// Package proxypin follows a proxy given by hostname while the agent is
+// active, moving its pinned host routes when the name resolves to new
+// addresses (CDN-fronted and DNS-balanced proxies do this).
+//
+// # Re-resolving
+//
+// The orchestrator hands the Pinner the endpoint it started with
+// (SetEndpoint): the hostname and port, the addresses pinned outside the
+// TUN, and the uplink gateways the pins go through. It clears it on stop.
+// Every check (default 60s), while Options.Active reports true, the
+// hostname is resolved again; an IP literal is never re-resolved. A
+// failed lookup keeps the current pins. IPv6 addresses are ignored unless
+// the endpoint has an IPv6 uplink.
+//
+// # Swapping
+//
+// When the address set differs, host routes for the new addresses are
+// applied first (routerepair.ApplyRoute), so the proxy stays reachable
+// outside the tunnel, and routes for addresses no longer returned are
+// then deleted (routerepair.DeleteRoute). The endpoint keeps the new set;
+// if no new route applies, the old pins stay and the next check retries.
+// Endpoint.Verify, when set, then checks the proxy at the first added
+// address, e.g. with a SOCKS handshake and CONNECT.
+//
+// # Reporting
+//
+// Each swap is passed to Options.OnChange with the old and new addresses,
+// the pins now in place, and any apply or verify error. The agent records
+// it as an event with kind=proxy_repin, updates routes.proxy_addrs, and
+// hands the pins to the route repairer.
+package proxypin
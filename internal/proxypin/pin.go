@@ -0,0 +1,274 @@
+package proxypin
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+	"github.com/sanverite/simple-packet-logger/internal/routerepair"
+)
+
+// DefaultInterval is the pause between lookups.
+const DefaultInterval = 60 * time.Second
+
+// lookupTimeout bounds one resolution.
+const lookupTimeout = 5 * time.Second
+
+// verifyTimeout bounds Endpoint.Verify after a swap.
+const verifyTimeout = 10 * time.Second
+
+// Resolver looks up a hostname; *net.Resolver implements it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Endpoint is the proxy whose host routes are kept current.
+type Endpoint struct {
+	Host    string       // as requested; an IP literal is never re-resolved
+	Port    uint16       // for Verify
+	Addrs   []netip.Addr // addresses pinned now
+	Uplink4 routeplan.Gateway
+	Uplink6 routeplan.Gateway // invalid: IPv6 addresses are not pinned
+
+	// Verify checks the proxy at a new address after a swap. Nil skips
+	// verification.
+	Verify func(context.Context, netip.AddrPort) error
+}
+
+// Options configures a Pinner.
+type Options struct {
+	// Active reports whether the pins should be kept current (the agent
+	// is active or degraded). Nil checks whenever an endpoint is set.
+	Active func() bool
+	// Resolver resolves the proxy host (default net.DefaultResolver).
+	Resolver Resolver
+	// Apply installs a host route (default routerepair.ApplyRoute).
+	Apply func(routeplan.Route) error
+	// Delete removes a stale host route (default routerepair.DeleteRoute).
+	Delete func(routeplan.Route) error
+	// Interval is the pause between lookups in Run (default
+	// DefaultInterval).
+	Interval time.Duration
+	// OnChange, when set, is called after each swap.
+	OnChange func(Change)
+	// Logger receives pinner records. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Change is one swap of the pinned addresses, or a failed attempt (no
+// Routes).
+type Change struct {
+	Host      string
+	Old       []netip.Addr
+	New       []netip.Addr
+	Routes    []routeplan.Route // host routes for New, as applied; empty: nothing moved
+	Err       error             // applying or deleting a route failed
+	Verified  bool              // Endpoint.Verify ran and succeeded
+	VerifyErr error             // Endpoint.Verify failed
+	At        time.Time
+}
+
+// Status is the pinner's state.
+type Status struct {
+	Host       string // "" when no endpoint is set
+	Addrs      []netip.Addr
+	Checked    time.Time
+	Changes    int
+	LastChange Change
+}
+
+// Pinner keeps a proxy's host routes on its current addresses.
+type Pinner struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	ep     *Endpoint
+	status Status
+}
+
+// New constructs a pinner; set an endpoint and call Check or Run.
+func New(opts Options) *Pinner {
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	if opts.Apply == nil {
+		opts.Apply = routerepair.ApplyRoute
+	}
+	if opts.Delete == nil {
+		opts.Delete = routerepair.DeleteRoute
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Pinner{opts: opts, logger: logger}
+}
+
+// SetEndpoint replaces the endpoint to follow; nil clears it.
+func (p *Pinner) SetEndpoint(ep *Endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ep = nil
+	p.status.Host, p.status.Addrs = "", nil
+	if ep != nil {
+		c := *ep
+		c.Addrs = sortedAddrs(ep.Addrs)
+		p.ep = &c
+		p.status.Host, p.status.Addrs = c.Host, c.Addrs
+	}
+}
+
+// Status returns the current state.
+func (p *Pinner) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.status
+	s.Addrs = slices.Clone(s.Addrs)
+	return s
+}
+
+// Check resolves the endpoint once and moves the pins when its addresses
+// changed. It returns the swap, or nil when nothing changed.
+func (p *Pinner) Check(ctx context.Context) *Change {
+	p.mu.Lock()
+	ep := p.ep
+	p.mu.Unlock()
+	if ep == nil || (p.opts.Active != nil && !p.opts.Active()) {
+		return nil
+	}
+	if _, err := netip.ParseAddr(ep.Host); err == nil {
+		return nil
+	}
+
+	lctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	addrs, err := p.opts.Resolver.LookupNetIP(lctx, "ip", ep.Host)
+	cancel()
+	if err != nil {
+		p.logger.Warn("proxy lookup failed; keeping pins", "host", ep.Host, "err", err)
+		return nil
+	}
+	var next []netip.Addr
+	for _, a := range addrs {
+		a = a.Unmap().WithZone("")
+		if a.Is4() || ep.Uplink6.Addr.IsValid() {
+			next = append(next, a)
+		}
+	}
+	next = sortedAddrs(next)
+	p.mu.Lock()
+	p.status.Checked = time.Now()
+	p.mu.Unlock()
+	if len(next) == 0 {
+		p.logger.Warn("proxy resolved to no routable address; keeping pins", "host", ep.Host)
+		return nil
+	}
+	if slices.Equal(next, ep.Addrs) {
+		return nil
+	}
+
+	// New pins before removing the old ones, so the proxy connection
+	// never falls into the tunnel. When none applies, the old pins stay
+	// and the next check tries again.
+	ch := Change{Host: ep.Host, Old: ep.Addrs, New: next, At: time.Now()}
+	var errs []error
+	for _, a := range next {
+		rt := pinRoute(ep, a)
+		if err := p.opts.Apply(rt); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ch.Routes = append(ch.Routes, rt)
+	}
+	if len(ch.Routes) == 0 {
+		ch.Err = errors.Join(errs...)
+		p.logger.Warn("proxy re-pin failed; keeping pins", "host", ep.Host, "old", ch.Old, "new", ch.New, "err", ch.Err)
+		p.record(ep, ch, false)
+		return &ch
+	}
+	for _, a := range ep.Addrs {
+		if slices.Contains(next, a) {
+			continue
+		}
+		if err := p.opts.Delete(pinRoute(ep, a)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	ch.Err = errors.Join(errs...)
+
+	if ep.Verify != nil {
+		target := next[0]
+		if i := slices.IndexFunc(next, func(a netip.Addr) bool { return !slices.Contains(ep.Addrs, a) }); i >= 0 {
+			target = next[i]
+		}
+		vctx, cancel := context.WithTimeout(ctx, verifyTimeout)
+		ch.VerifyErr = ep.Verify(vctx, netip.AddrPortFrom(target, ep.Port))
+		cancel()
+		ch.Verified = ch.VerifyErr == nil
+	}
+	if ch.Err != nil || ch.VerifyErr != nil {
+		p.logger.Warn("proxy re-pinned with errors", "host", ep.Host, "old", ch.Old, "new", ch.New, "err", ch.Err, "verify_err", ch.VerifyErr)
+	} else {
+		p.logger.Info("proxy re-pinned", "host", ep.Host, "old", ch.Old, "new", ch.New, "verified", ch.Verified)
+	}
+
+	p.record(ep, ch, true)
+	return &ch
+}
+
+// record notes ch, adopting its addresses when swapped, and reports it.
+func (p *Pinner) record(ep *Endpoint, ch Change, swapped bool) {
+	p.mu.Lock()
+	if swapped && p.ep == ep {
+		// Copied, not updated in place: a concurrent Check may hold ep.
+		c := *ep
+		c.Addrs = ch.New
+		p.ep = &c
+		p.status.Addrs = ch.New
+	}
+	p.status.Changes++
+	p.status.LastChange = ch
+	p.mu.Unlock()
+	if p.opts.OnChange != nil {
+		p.opts.OnChange(ch)
+	}
+}
+
+// Run checks every interval until ctx is done.
+func (p *Pinner) Run(ctx context.Context) {
+	t := time.NewTicker(p.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.Check(ctx)
+		}
+	}
+}
+
+// pinRoute is the host route keeping a outside the TUN.
+func pinRoute(ep *Endpoint, a netip.Addr) routeplan.Route {
+	g := ep.Uplink4
+	if a.Is6() {
+		g = ep.Uplink6
+	}
+	return routeplan.Route{Dst: netip.PrefixFrom(a, a.BitLen()), Via: g.Addr, Dev: g.Interface, Reason: routeplan.ReasonProxy}
+}
+
+// sortedAddrs returns a sorted copy of addrs without duplicates.
+func sortedAddrs(addrs []netip.Addr) []netip.Addr {
+	out := slices.Clone(addrs)
+	slices.SortFunc(out, netip.Addr.Compare)
+	return slices.Compact(out)
+}
@@ -0,0 +1,32 @@
+// Package recovery detects and cleans up artifacts left by a previous run.
+//
+// # Overview
+//
+// When the agent crashes while active, the host keeps the TUN interface,
+// the swapped default route, and possibly a running tun2socks child. The
+// persist package restores the snapshot describing those artifacts; this
+// package compares it with the live system and reports what is orphaned.
+//
+// # Detection
+//
+//   - tun2socks_process: a live process whose PID comes from the pidfile or
+//     the restored snapshot and whose name contains "tun2socks" (guards
+//     against PID reuse).
+//   - tun_interface: the restored TUN interface name still exists.
+//   - default_route: the default gateway still points at the TUN peer (or
+//     away from the original gateway) after the original was recorded.
+//
+// # Cleanup
+//
+// Cleanup handles orphans in dependency order: stop the process, remove the
+// interface, then restore the default route. Each action is reported
+// individually. When nothing remains, the pidfile is removed and core.State
+// is reset to inactive with the subsystem snapshots cleared.
+//
+// # Platform Access
+//
+// All host interaction goes through the System interface. OSSystem provides
+// implementations for linux, darwin, and windows (build-tagged); other
+// platforms return ErrUnsupported so detection degrades to "unknown"
+// rather than failing.
+package recovery
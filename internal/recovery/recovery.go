@@ -0,0 +1,259 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// ErrUnsupported is returned by System methods not available on this platform.
+var ErrUnsupported = errors.New("not supported on this platform")
+
+// System abstracts host inspection and mutation needed for recovery.
+type System interface {
+	// InterfaceExists reports whether a network interface named name exists.
+	InterfaceExists(name string) (bool, error)
+	// DeleteInterface removes the named interface.
+	DeleteInterface(name string) error
+	// ProcessName returns the executable name of pid, or "" if not running.
+	ProcessName(pid int) (string, error)
+	// KillProcess terminates pid, escalating to SIGKILL if needed.
+	KillProcess(ctx context.Context, pid int) error
+	// DefaultGateway returns the current IPv4 default gateway, or "" if none.
+	DefaultGateway() (string, error)
+	// SetDefaultGateway points the IPv4 default route at gw.
+	SetDefaultGateway(gw string) error
+}
+
+// Kind classifies an orphaned artifact.
+type Kind string
+
+const (
+	KindProcess      Kind = "tun2socks_process"
+	KindInterface    Kind = "tun_interface"
+	KindDefaultRoute Kind = "default_route"
+)
+
+// Orphan is one leftover artifact.
+type Orphan struct {
+	Kind     Kind   // What kind of artifact
+	Resource string // PID, interface name, or current gateway
+	Detail   string // Human-readable explanation
+	Action   string // What Cleanup will do
+}
+
+// Report is the result of a scan.
+type Report struct {
+	CheckedAt time.Time
+	Orphans   []Orphan
+	Warnings  []string // Checks that could not run (e.g., unsupported platform)
+}
+
+// ActionResult records the outcome of cleaning one orphan.
+type ActionResult struct {
+	Orphan Orphan
+	Err    error
+}
+
+// CleanupResult is the outcome of Cleanup.
+type CleanupResult struct {
+	Actions   []ActionResult
+	Remaining Report // Rescan after cleanup
+	Reset     bool   // core.State was reset to inactive
+}
+
+// Options configures a Recoverer.
+type Options struct {
+	// PIDFile holds the tun2socks PID written by the orchestrator.
+	PIDFile string
+	// Logger receives recovery records. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Recoverer scans for and cleans up orphans described by core.State.
+type Recoverer struct {
+	sys    System
+	state  *core.State
+	opts   Options
+	logger *slog.Logger
+}
+
+// New constructs a Recoverer. sys is typically OSSystem().
+func New(sys System, state *core.State, opts Options) *Recoverer {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Recoverer{sys: sys, state: state, opts: opts, logger: logger}
+}
+
+// Scan compares the restored snapshot with the live system.
+func (r *Recoverer) Scan(ctx context.Context) Report {
+	snap := r.state.GetSnapshot()
+	rep := Report{CheckedAt: time.Now()}
+
+	// tun2socks process: prefer the pidfile, fall back to the snapshot.
+	pids := map[int]string{}
+	if pid, err := readPIDFile(r.opts.PIDFile); err != nil {
+		rep.Warnings = append(rep.Warnings, "read pidfile: "+err.Error())
+	} else if pid > 0 {
+		pids[pid] = "pidfile " + r.opts.PIDFile
+	}
+	if snap.Tun2Socks.PID > 0 {
+		if _, ok := pids[snap.Tun2Socks.PID]; !ok {
+			pids[snap.Tun2Socks.PID] = "restored state"
+		}
+	}
+	for pid, source := range pids {
+		name, err := r.sys.ProcessName(pid)
+		if err != nil {
+			rep.Warnings = append(rep.Warnings, fmt.Sprintf("inspect pid %d: %v", pid, err))
+			continue
+		}
+		if name == "" || !strings.Contains(name, "tun2socks") {
+			continue // not running, or PID reused by something else
+		}
+		rep.Orphans = append(rep.Orphans, Orphan{
+			Kind:     KindProcess,
+			Resource: strconv.Itoa(pid),
+			Detail:   fmt.Sprintf("%s (pid %d from %s) is still running", name, pid, source),
+			Action:   "terminate process",
+		})
+	}
+
+	// TUN interface left behind.
+	if snap.TUN.Name != "" {
+		exists, err := r.sys.InterfaceExists(snap.TUN.Name)
+		switch {
+		case err != nil:
+			rep.Warnings = append(rep.Warnings, fmt.Sprintf("inspect interface %s: %v", snap.TUN.Name, err))
+		case exists:
+			rep.Orphans = append(rep.Orphans, Orphan{
+				Kind:     KindInterface,
+				Resource: snap.TUN.Name,
+				Detail:   fmt.Sprintf("interface %s from the previous run still exists", snap.TUN.Name),
+				Action:   "delete interface",
+			})
+		}
+	}
+
+	// Default route still swapped.
+	if orig := snap.Routes.OriginalGateway; orig != "" {
+		cur, err := r.sys.DefaultGateway()
+		switch {
+		case err != nil:
+			rep.Warnings = append(rep.Warnings, "inspect default route: "+err.Error())
+		case cur != orig && (snap.Routes.DefaultVia == "" || cur == snap.Routes.DefaultVia || cur == ""):
+			rep.Orphans = append(rep.Orphans, Orphan{
+				Kind:     KindDefaultRoute,
+				Resource: cur,
+				Detail:   fmt.Sprintf("default route via %q, original gateway was %s", cur, orig),
+				Action:   "restore default route via " + orig,
+			})
+		}
+	}
+
+	return rep
+}
+
+// Cleanup removes orphans found by a fresh scan, then rescans. If nothing
+// remains, the pidfile is removed and state is reset to inactive.
+func (r *Recoverer) Cleanup(ctx context.Context) CleanupResult {
+	rep := r.Scan(ctx)
+	var res CleanupResult
+
+	// Order matters: the process may hold the interface, and the route must
+	// not be restored while traffic is still being captured.
+	for _, kind := range []Kind{KindProcess, KindInterface, KindDefaultRoute} {
+		for _, o := range rep.Orphans {
+			if o.Kind != kind {
+				continue
+			}
+			err := r.clean(ctx, o)
+			if err != nil {
+				r.logger.Error("recovery action failed", "kind", o.Kind, "resource", o.Resource, "err", err)
+			} else {
+				r.logger.Info("recovery action succeeded", "kind", o.Kind, "resource", o.Resource)
+			}
+			res.Actions = append(res.Actions, ActionResult{Orphan: o, Err: err})
+		}
+	}
+
+	res.Remaining = r.Scan(ctx)
+	if len(res.Remaining.Orphans) == 0 {
+		if r.opts.PIDFile != "" {
+			_ = os.Remove(r.opts.PIDFile)
+		}
+		r.state.Reset(true)
+		res.Reset = true
+		r.logger.Info("recovery complete; state reset to inactive")
+	}
+	return res
+}
+
+// RestoreDefaultRoute points the IPv4 default route back at the gateway
+// snap recorded before the tunnel replaced it, unless it already goes
+// there or was changed by someone else since. Shutdown calls it once the
+// engines have stopped; it is a no-op when snap has no original gateway.
+func (r *Recoverer) RestoreDefaultRoute(snap core.Snapshot) error {
+	orig := snap.Routes.OriginalGateway
+	if orig == "" {
+		return nil
+	}
+	cur, err := r.sys.DefaultGateway()
+	if err != nil {
+		return fmt.Errorf("inspect default route: %w", err)
+	}
+	if cur == orig || (snap.Routes.DefaultVia != "" && cur != "" && cur != snap.Routes.DefaultVia) {
+		return nil
+	}
+	if err := r.sys.SetDefaultGateway(orig); err != nil {
+		return fmt.Errorf("restore default route via %s: %w", orig, err)
+	}
+	r.logger.Info("default route restored", "gateway", orig)
+	return nil
+}
+
+func (r *Recoverer) clean(ctx context.Context, o Orphan) error {
+	switch o.Kind {
+	case KindProcess:
+		pid, err := strconv.Atoi(o.Resource)
+		if err != nil {
+			return err
+		}
+		return r.sys.KillProcess(ctx, pid)
+	case KindInterface:
+		return r.sys.DeleteInterface(o.Resource)
+	case KindDefaultRoute:
+		return r.sys.SetDefaultGateway(r.state.GetSnapshot().Routes.OriginalGateway)
+	default:
+		return fmt.Errorf("unknown orphan kind %q", o.Kind)
+	}
+}
+
+// readPIDFile returns the PID stored at path, or 0 if path is empty or the
+// file does not exist.
+func readPIDFile(path string) (int, error) {
+	if path == "" {
+		return 0, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid in %s", path)
+	}
+	return pid, nil
+}
@@ -0,0 +1,54 @@
+//go:build darwin
+
+package recovery
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OSSystem returns the System implementation for this platform.
+func OSSystem() System { return darwinSystem{} }
+
+type darwinSystem struct{ unixSystem }
+
+// DeleteInterface cannot destroy utun devices directly: the kernel releases a
+// utun when the owning file descriptor closes. After the owning process is
+// gone the interface disappears on its own, so this only verifies that.
+func (d darwinSystem) DeleteInterface(name string) error {
+	exists, err := d.InterfaceExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%s is still held open by a process; utun devices are released when their owner exits", name)
+	}
+	return nil
+}
+
+func (darwinSystem) ProcessName(pid int) (string, error) {
+	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "comm=").Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", nil // ps exits non-zero when the PID does not exist
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(strings.TrimSpace(string(out))), nil
+}
+
+func (darwinSystem) SetDefaultGateway(gw string) error {
+	if out, err := exec.Command("route", "-n", "change", "default", gw).CombinedOutput(); err != nil {
+		// change fails when no default exists; fall back to add.
+		if out2, err2 := exec.Command("route", "-n", "add", "default", gw).CombinedOutput(); err2 != nil {
+			return fmt.Errorf("route change/add default %s: %v: %s / %s", gw, err2,
+				strings.TrimSpace(string(out)), strings.TrimSpace(string(out2)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build linux
+
+package recovery
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sanverite/simple-packet-logger/internal/rtnl"
+)
+
+// OSSystem returns the System implementation for this platform.
+func OSSystem() System { return linuxSystem{} }
+
+type linuxSystem struct{ unixSystem }
+
+func (linuxSystem) DeleteInterface(name string) error {
+	return rtnl.DeleteLink(name)
+}
+
+func (linuxSystem) ProcessName(pid int) (string, error) {
+	b, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/comm")
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func (linuxSystem) SetDefaultGateway(gw string) error {
+	via, err := netip.ParseAddr(gw)
+	if err != nil || !via.Is4() {
+		return fmt.Errorf("invalid IPv4 gateway %q", gw)
+	}
+	return rtnl.ReplaceRoute(rtnl.Route{Dst: netip.PrefixFrom(netip.IPv4Unspecified(), 0), Via: via})
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !windows
+
+package recovery
+
+import "context"
+
+// OSSystem returns the System implementation for this platform.
+func OSSystem() System { return unsupportedSystem{} }
+
+// unsupportedSystem reports ErrUnsupported for every operation, so scans
+// surface warnings instead of orphans.
+type unsupportedSystem struct{}
+
+func (unsupportedSystem) InterfaceExists(string) (bool, error)   { return false, ErrUnsupported }
+func (unsupportedSystem) DeleteInterface(string) error           { return ErrUnsupported }
+func (unsupportedSystem) ProcessName(int) (string, error)        { return "", ErrUnsupported }
+func (unsupportedSystem) KillProcess(context.Context, int) error { return ErrUnsupported }
+func (unsupportedSystem) DefaultGateway() (string, error)        { return "", ErrUnsupported }
+func (unsupportedSystem) SetDefaultGateway(string) error         { return ErrUnsupported }
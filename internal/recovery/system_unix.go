@@ -0,0 +1,75 @@
+//go:build unix
+
+package recovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// killGrace is how long KillProcess waits after SIGTERM before SIGKILL.
+const killGrace = 3 * time.Second
+
+// unixSystem holds the parts of System shared by unix platforms.
+type unixSystem struct{}
+
+// DefaultGateway reports the gateway of the active IPv4 default route.
+func (unixSystem) DefaultGateway() (string, error) {
+	r, err := netinfo.GetDefaultRoute()
+	if errors.Is(err, netinfo.ErrNoDefaultRoute) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !r.Gateway.IsValid() {
+		return "", nil // interface-only default route
+	}
+	return r.Gateway.String(), nil
+}
+
+func (unixSystem) InterfaceExists(name string) (bool, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return false, err
+	}
+	for _, ifc := range ifaces {
+		if ifc.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (unixSystem) KillProcess(ctx context.Context, pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return nil
+		}
+		return err
+	}
+	deadline := time.NewTimer(killGrace)
+	defer deadline.Stop()
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+				return err
+			}
+			return nil
+		case <-tick.C:
+			if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+//go:build windows
+
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/sanverite/simple-packet-logger/internal/iphlp"
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// OSSystem returns the System implementation for this platform.
+func OSSystem() System { return windowsSystem{} }
+
+type windowsSystem struct{}
+
+func (windowsSystem) InterfaceExists(name string) (bool, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return false, err
+	}
+	for _, ifc := range ifaces {
+		if ifc.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteInterface cannot delete a wintun adapter from outside: the adapter
+// goes away when its owner's handle closes. After the owning process is
+// gone it has disappeared on its own, so this only verifies that.
+func (w windowsSystem) DeleteInterface(name string) error {
+	exists, err := w.InterfaceExists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%s is still held open by a process; wintun adapters are released when their owner exits", name)
+	}
+	return nil
+}
+
+func (windowsSystem) ProcessName(pid int) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if errors.Is(err, windows.ERROR_INVALID_PARAMETER) {
+		return "", nil // no such process
+	}
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	n := uint32(len(buf))
+	if err := windows.QueryFullProcessImageName(h, 0, &buf[0], &n); err != nil {
+		return "", err
+	}
+	return filepath.Base(windows.UTF16ToString(buf[:n])), nil
+}
+
+// KillProcess terminates pid at once: Windows has no SIGTERM to send a
+// console process first.
+func (windowsSystem) KillProcess(ctx context.Context, pid int) error {
+	h, err := windows.OpenProcess(windows.PROCESS_TERMINATE|windows.SYNCHRONIZE, false, uint32(pid))
+	if errors.Is(err, windows.ERROR_INVALID_PARAMETER) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(h)
+	if err := windows.TerminateProcess(h, 1); err != nil {
+		return err
+	}
+	for {
+		ev, err := windows.WaitForSingleObject(h, 100)
+		if err != nil {
+			return err
+		}
+		if ev == windows.WAIT_OBJECT_0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// DefaultGateway reports the gateway of the active IPv4 default route.
+func (windowsSystem) DefaultGateway() (string, error) {
+	r, err := netinfo.GetDefaultRoute()
+	if errors.Is(err, netinfo.ErrNoDefaultRoute) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !r.Gateway.IsValid() {
+		return "", nil // interface-only default route
+	}
+	return r.Gateway.String(), nil
+}
+
+func (windowsSystem) SetDefaultGateway(gw string) error {
+	via, err := netip.ParseAddr(gw)
+	if err != nil || !via.Is4() {
+		return fmt.Errorf("invalid IPv4 gateway %q", gw)
+	}
+	return iphlp.ReplaceRoute(iphlp.Route{Dst: netip.PrefixFrom(netip.IPv4Unspecified(), 0), Via: via})
+}
@@ -0,0 +1,141 @@
+package redact
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Mask replaces redacted text.
+const Mask = "[REDACTED]"
+
+// MinSecretLen is the shortest known secret a Redactor masks.
+const MinSecretLen = 4
+
+var (
+	// [scheme://]user:password@ -> [scheme://]user:[REDACTED]@
+	urlUserinfo = regexp.MustCompile(`((?:[A-Za-z][A-Za-z0-9+.-]*://)?[^/\s:@"'()]*):[^/\s@"']+@`)
+	// Authorization: Bearer <token>
+	bearer = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+	// Minted token secrets (tokens.SecretPrefix).
+	mintedSecret = regexp.MustCompile(`\bspl_[A-Za-z0-9_-]{16,}`)
+	// password=..., "secret": "...", token: ...
+	keyValue = regexp.MustCompile(`(?i)\b(password|passwd|secret|token|api_key)(["']?\s*[:=]\s*["']?)([^\s"'&,;}]+)`)
+)
+
+// sensitiveKeys are attribute and option names whose values are secrets.
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"passwd":        true,
+	"pass":          true,
+	"secret":        true,
+	"token":         true,
+	"api_key":       true,
+	"authorization": true,
+}
+
+// SensitiveKey reports whether a key-value pair named key holds a secret
+// (case-insensitive), so its value should be replaced by Mask outright.
+func SensitiveKey(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// String returns s with recognizable credentials masked.
+func String(s string) string {
+	if s == "" {
+		return s
+	}
+	s = urlUserinfo.ReplaceAllString(s, "${1}:"+Mask+"@")
+	s = bearer.ReplaceAllString(s, "${1}"+Mask)
+	s = mintedSecret.ReplaceAllString(s, Mask)
+	return keyValue.ReplaceAllString(s, "${1}${2}"+Mask)
+}
+
+// Strings returns a copy of list with String applied to each element. A
+// nil list stays nil.
+func Strings(list []string) []string {
+	return New().Strings(list)
+}
+
+// Map returns a copy of m with String applied to each value and the values
+// of sensitive keys masked. A nil map stays nil.
+func Map(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if SensitiveKey(k) && v != "" {
+			out[k] = Mask
+		} else {
+			out[k] = String(v)
+		}
+	}
+	return out
+}
+
+// Redactor masks known secrets in addition to the patterns String finds.
+// The zero value and nil masks patterns only.
+type Redactor struct {
+	secrets []string
+}
+
+// New returns a Redactor for secrets; empty and short ones are ignored.
+func New(secrets ...string) *Redactor {
+	r := &Redactor{}
+	for _, s := range secrets {
+		if len(s) >= MinSecretLen {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	return r
+}
+
+// String returns s with known secrets and recognizable credentials masked.
+func (r *Redactor) String(s string) string {
+	if r != nil {
+		for _, secret := range r.secrets {
+			s = strings.ReplaceAll(s, secret, Mask)
+		}
+	}
+	return String(s)
+}
+
+// Strings returns a copy of list with String applied to each element. A
+// nil list stays nil.
+func (r *Redactor) Strings(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	out := make([]string, len(list))
+	for i, s := range list {
+		out[i] = r.String(s)
+	}
+	return out
+}
+
+// Error returns err with its message redacted, or nil. The result unwraps
+// to err, so errors.Is and errors.As still classify it; callers must not
+// print what they unwrap.
+func (r *Redactor) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := r.String(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+// Error is Redactor.Error for patterns only.
+func Error(err error) error {
+	return New().Error(err)
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
@@ -0,0 +1,32 @@
+// Package routecheck verifies the routes recorded in core.RouteSnapshot
+// against the host's routing table, for GET /v1/routes.
+//
+// # Entries
+//
+// Check turns the snapshot into one Entry per expectation and looks up
+// the route the host would use for each:
+//
+//   - default and default6: while the agent routes a full tunnel the
+//     preferred default must use the TUN; otherwise it must not, and its
+//     gateway must still be the original one recorded at start.
+//   - proxy, bypass, lan, exclude: pinned outside the TUN while the agent
+//     routes traffic. Bypass hostnames are resolved again, so an address
+//     change shows up as a missing route.
+//   - include: split tunnel prefixes routed into the TUN.
+//
+// IPv6 targets are checked only when IPv6 is routed, as the planner
+// ignores them otherwise.
+//
+// # Status
+//
+// An entry is StatusOK when the matched route is at least as specific as
+// the target and goes the expected way. It is StatusMissing when no route
+// covers the target or only a broader one does (the pinned route is gone),
+// and StatusConflict when a route as specific as the target goes the wrong
+// way, or the default gateway changed. Any entry other than StatusOK is
+// drift.
+//
+// Lookups are injected so the checks stay platform-neutral; on platforms
+// where netinfo.LookupRoute is unsupported Check returns
+// netinfo.ErrUnsupported.
+package routecheck
@@ -0,0 +1,232 @@
+package routecheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/sanverite/simple-packet-logger/internal/bypass"
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// Status is the outcome of one entry.
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusMissing  Status = "missing"
+	StatusConflict Status = "conflict"
+)
+
+// Kind names what an entry verifies.
+type Kind string
+
+const (
+	KindDefault  Kind = "default"
+	KindDefault6 Kind = "default6"
+	KindProxy    Kind = "proxy"
+	KindBypass   Kind = "bypass"
+	KindLAN      Kind = "lan"
+	KindInclude  Kind = "include"
+	KindExclude  Kind = "exclude"
+)
+
+// Where an entry's traffic is expected to go.
+const (
+	ExpectTUN     = "tun"
+	ExpectOutside = "outside"
+)
+
+var (
+	default4 = netip.MustParsePrefix("0.0.0.0/0")
+	default6 = netip.MustParsePrefix("::/0")
+)
+
+// Entry is one verified expectation.
+type Entry struct {
+	Kind   Kind
+	Target string // prefix checked
+	Host   string // bypass hostname Target was resolved from; "" otherwise
+	Expect string // ExpectTUN or ExpectOutside
+	Status Status
+	Detail string
+	Route  netinfo.Route // route the host uses for Target; zero when none
+}
+
+// Input is the recorded state to verify and the host lookups to verify it
+// with.
+type Input struct {
+	State  core.AgentState
+	Routes core.RouteSnapshot
+	TUN    string // TUN interface name; "" when none was created
+	IPv6   bool   // IPv6 is routed through the TUN
+
+	Lookup         func(netip.Addr) (netinfo.Route, error)
+	DefaultRoutes  func() ([]netinfo.Route, error)
+	DefaultRoutes6 func() ([]netinfo.Route, error)
+	Resolver       bypass.Resolver // resolves bypass hostnames
+}
+
+// Drift reports whether any entry is not StatusOK.
+func Drift(entries []Entry) bool {
+	return slices.ContainsFunc(entries, func(e Entry) bool { return e.Status != StatusOK })
+}
+
+// Check verifies in against the host's routing table. It fails only with
+// netinfo.ErrUnsupported; other lookup errors are reported per entry.
+func Check(ctx context.Context, in Input) ([]Entry, error) {
+	routing := in.State == core.StateActive || in.State == core.StateDegraded
+	split := len(in.Routes.IncludeCIDRs) > 0
+	var out []Entry
+
+	e, err := checkDefault(in, KindDefault, default4, in.DefaultRoutes, in.Routes.OriginalGateway, routing && !split)
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, e)
+	if in.IPv6 || in.Routes.OriginalGateway6 != "" {
+		e, err := checkDefault(in, KindDefault6, default6, in.DefaultRoutes6, in.Routes.OriginalGateway6, routing && !split && in.IPv6)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	if !routing {
+		return out, nil
+	}
+
+	type target struct {
+		kind  Kind
+		p     netip.Prefix
+		host  string
+		toTUN bool
+	}
+	var targets []target
+	if in.Routes.ProxyHostRoute {
+		for _, s := range in.Routes.ProxyAddrs {
+			if a, err := netip.ParseAddr(s); err == nil {
+				targets = append(targets, target{kind: KindProxy, p: netip.PrefixFrom(a.Unmap(), a.Unmap().BitLen())})
+			}
+		}
+	}
+	for _, h := range in.Routes.BypassHosts {
+		entries, err := bypass.Normalize(ctx, in.Resolver, []string{h})
+		if err != nil {
+			out = append(out, Entry{Kind: KindBypass, Target: h, Expect: ExpectOutside, Status: StatusMissing, Detail: err.Error()})
+			continue
+		}
+		for _, p := range entries[0].Prefixes {
+			t := target{kind: KindBypass, p: p}
+			if entries[0].Kind == bypass.KindHostname {
+				t.host = h
+			}
+			targets = append(targets, t)
+		}
+	}
+	for _, l := range []struct {
+		kind  Kind
+		cidrs []string
+		toTUN bool
+	}{
+		{KindLAN, in.Routes.LanCIDRs, false},
+		{KindExclude, in.Routes.ExcludeCIDRs, false},
+		{KindInclude, in.Routes.IncludeCIDRs, true},
+	} {
+		for _, s := range l.cidrs {
+			if p, err := netip.ParsePrefix(s); err == nil {
+				targets = append(targets, target{kind: l.kind, p: p.Masked(), toTUN: l.toTUN})
+			}
+		}
+	}
+
+	for _, t := range targets {
+		if t.p.Addr().Is6() && !in.IPv6 {
+			continue
+		}
+		e, err := checkPrefix(in, t.kind, t.p, t.toTUN)
+		if err != nil {
+			return nil, err
+		}
+		e.Host = t.host
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+// checkDefault verifies the preferred default route of one family: via
+// the TUN when toTUN, otherwise outside it through orig (when recorded).
+func checkDefault(in Input, kind Kind, dst netip.Prefix, list func() ([]netinfo.Route, error), orig string, toTUN bool) (Entry, error) {
+	e := Entry{Kind: kind, Target: dst.String(), Expect: ExpectOutside}
+	if toTUN {
+		e.Expect = ExpectTUN
+	}
+	routes, err := list()
+	switch {
+	case errors.Is(err, netinfo.ErrUnsupported):
+		return e, err
+	case errors.Is(err, netinfo.ErrNoDefaultRoute), err == nil && len(routes) == 0:
+		e.Status, e.Detail = StatusMissing, "no default route"
+		return e, nil
+	case err != nil:
+		e.Status, e.Detail = StatusMissing, "read default routes: "+err.Error()
+		return e, nil
+	}
+	rt := routes[0]
+	e.Route = rt
+	onTUN := in.TUN != "" && rt.Interface == in.TUN
+	switch {
+	case toTUN && onTUN, !toTUN && !onTUN && (orig == "" || !rt.Gateway.IsValid() || rt.Gateway.String() == orig):
+		e.Status, e.Detail = StatusOK, "default route via "+via(rt)
+	case toTUN && slices.ContainsFunc(routes, func(r netinfo.Route) bool { return r.Interface == in.TUN }):
+		e.Status, e.Detail = StatusConflict, fmt.Sprintf("default route via %s preferred over TUN %s", via(rt), in.TUN)
+	case toTUN:
+		e.Status, e.Detail = StatusMissing, "no default route via TUN "+in.TUN
+	case onTUN:
+		e.Status, e.Detail = StatusConflict, "default route points at TUN "+in.TUN
+	default:
+		e.Status, e.Detail = StatusConflict, fmt.Sprintf("default gateway changed from %s to %s", orig, rt.Gateway)
+	}
+	return e, nil
+}
+
+// checkPrefix verifies that p is routed into the TUN when toTUN, or
+// outside it otherwise, by a route at least as specific as p.
+func checkPrefix(in Input, kind Kind, p netip.Prefix, toTUN bool) (Entry, error) {
+	e := Entry{Kind: kind, Target: p.String(), Expect: ExpectOutside}
+	if toTUN {
+		e.Expect = ExpectTUN
+	}
+	rt, err := in.Lookup(p.Addr())
+	switch {
+	case errors.Is(err, netinfo.ErrUnsupported):
+		return e, err
+	case errors.Is(err, netinfo.ErrNoRoute):
+		e.Status, e.Detail = StatusMissing, "no route"
+		return e, nil
+	case err != nil:
+		e.Status, e.Detail = StatusMissing, "lookup: "+err.Error()
+		return e, nil
+	}
+	e.Route = rt
+	onTUN := rt.Interface == in.TUN
+	switch {
+	case rt.Prefix.Bits() < p.Bits():
+		e.Status, e.Detail = StatusMissing, fmt.Sprintf("only %s via %s covers it", rt.Prefix, via(rt))
+	case onTUN != toTUN:
+		e.Status, e.Detail = StatusConflict, fmt.Sprintf("%s routed via %s", rt.Prefix, via(rt))
+	default:
+		e.Status, e.Detail = StatusOK, fmt.Sprintf("%s routed via %s", rt.Prefix, via(rt))
+	}
+	return e, nil
+}
+
+// via renders a route's next hop.
+func via(rt netinfo.Route) string {
+	if rt.Gateway.IsValid() {
+		return rt.Gateway.String() + " on " + rt.Interface
+	}
+	return rt.Interface
+}
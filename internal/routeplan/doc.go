@@ -0,0 +1,63 @@
+// Package routeplan computes the TUN configuration and route changes needed
+// to send host traffic through the TUN device, and how to undo them.
+//
+// # Planning
+//
+// Build is pure: it takes the discovered environment (original default
+// routes, proxy addresses, LAN and bypass prefixes, probe capabilities) and
+// returns a Plan. The orchestrator applies a plan step by step, records the
+// originals in core.RouteSnapshot, and applies Plan.Restore on stop or
+// failure. Dry runs return the plan without applying it.
+//
+// # IPv4
+//
+// The TUN gets a point-to-point /30 (10.255.0.1 -> 10.255.0.2). The default
+// route moves to the TUN peer; the proxy endpoint and bypass prefixes get
+// routes via the original gateway, and LAN prefixes get on-link routes on
+// their interface, so none of them loop through the tunnel. A WireGuard
+// upstream replaces the local address with the one its peer assigned
+// (Input.Local4); its endpoint is pinned as the proxy is.
+//
+// Input.TUN4 replaces the default addressing with another local/peer pair
+// and subnet, for hosts where 10.255.0.0/30 is in use. ParseTUNAddr
+// validates a requested one, and CheckTUNSubnet refuses a subnet that
+// overlaps a live network (a detected LAN or an interface address).
+//
+// # Split Tunneling
+//
+// When Input.Include is non-empty the default routes are left alone and only
+// the included prefixes are routed to the TUN (Plan.Split, empty Restore).
+// Input.Exclude prefixes are pinned via the original gateway in both modes;
+// being more specific, they win inside an included range. ParseCIDRs
+// validates user-supplied lists.
+//
+// # Uplink Selection
+//
+// On multi-homed hosts the system default may sit on the wrong interface
+// (Wi-Fi instead of Ethernet, say). Input.Uplink4/Uplink6 move the pinned
+// routes (proxy, bypass, exclude) to another interface's gateway while
+// Restore still reinstates the system default. ChooseUplink picks the first
+// interface of a preference list that currently has a default route, which
+// gives an automatic fallback order; ParseUplinks validates such a list.
+//
+// # MTU
+//
+// ChooseMTU picks the TUN MTU when a start request leaves it at 0: the
+// path MTU toward the proxy from the last probe (unless the uplink
+// interface's MTU is lower), else the interface's MTU, else DefaultMTU,
+// less the encapsulation overhead (OverheadSOCKS, or OverheadWireGuard for
+// WireGuard upstreams) and kept within MinMTU and MaxMTU. The choice carries its source and a rationale for the
+// start response and events.
+//
+// # IPv6 (dual stack)
+//
+// When Input.IPv6 is set, the TUN also gets a ULA address (fd73:706c::1/64)
+// and, if the proxy supports IPv6 egress (probe Features.IPv6), the IPv6
+// default route moves to the TUN, with IPv6 bypass/LAN/proxy prefixes routed
+// via the original IPv6 gateway on its interface (v6 gateways are usually
+// link-local). Restore puts the original IPv6 default back.
+//
+// If IPv6 is requested but the proxy lacks IPv6 support, or the host has no
+// IPv6 default route, IPv6 is left untouched and the plan carries a warning.
+// IPv6 prefixes in bypass/LAN lists are ignored while IPv6 is not routed.
+package routeplan
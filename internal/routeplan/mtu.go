@@ -0,0 +1,78 @@
+package routeplan
+
+import "fmt"
+
+// Overheads subtracted from the underlying MTU when choosing the TUN MTU.
+const (
+	// OverheadSOCKS leaves room for relaying a full-size datagram over
+	// SOCKS5 UDP ASSOCIATE: IPv6 and UDP headers plus the SOCKS5 UDP
+	// header with an IPv6 address (40 + 8 + 22).
+	OverheadSOCKS = 70
+	// OverheadWireGuard is WireGuard's encapsulation over IPv6, as
+	// wg-quick assumes (see wireguard.DefaultMTU).
+	OverheadWireGuard = 80
+	// MinMTU is the least MTU chosen automatically, the IPv6 minimum.
+	MinMTU = 1280
+	// MaxMTU is the most chosen automatically (jumbo frames); loopback
+	// paths report far more.
+	MaxMTU = 9000
+)
+
+// MTU sources.
+const (
+	MTUFromRequest   = "request"   // the caller set it
+	MTUFromPath      = "path"      // path MTU toward the proxy, from the last probe
+	MTUFromInterface = "interface" // MTU of the uplink interface
+	MTUFromDefault   = "default"   // DefaultMTU; nothing better was known
+)
+
+// MTUInput is what ChooseMTU knows about the path to the upstream.
+type MTUInput struct {
+	Requested int    // caller's MTU; 0 chooses automatically
+	PathMTU   int    // path MTU toward the upstream; 0 when unknown
+	Interface string // uplink interface name
+	IfaceMTU  int    // its MTU; 0 when unknown
+	Overhead  int    // encapsulation to leave room for (OverheadSOCKS, OverheadWireGuard)
+}
+
+// MTUChoice is a TUN MTU and why it was chosen.
+type MTUChoice struct {
+	MTU    int
+	Source string // one of the MTUFrom constants
+	Reason string // human-readable rationale
+}
+
+// ChooseMTU picks the TUN MTU: the requested one, else the path MTU toward
+// the upstream (unless the uplink interface's MTU is lower), else the
+// interface's MTU, else DefaultMTU, minus the overhead and within MinMTU
+// and MaxMTU.
+func ChooseMTU(in MTUInput) MTUChoice {
+	if in.Requested > 0 {
+		return MTUChoice{MTU: in.Requested, Source: MTUFromRequest, Reason: "set by the request"}
+	}
+	c := MTUChoice{Source: MTUFromDefault}
+	base := DefaultMTU
+	switch {
+	case in.PathMTU > 0 && (in.IfaceMTU == 0 || in.PathMTU <= in.IfaceMTU):
+		base, c.Source = in.PathMTU, MTUFromPath
+		c.Reason = fmt.Sprintf("path MTU %d toward the proxy", base)
+	case in.IfaceMTU > 0:
+		base, c.Source = in.IfaceMTU, MTUFromInterface
+		c.Reason = fmt.Sprintf("%s MTU %d", in.Interface, base)
+	default:
+		c.Reason = fmt.Sprintf("default MTU %d", base)
+	}
+	c.MTU = base - in.Overhead
+	if in.Overhead > 0 {
+		c.Reason += fmt.Sprintf(" minus %d bytes of overhead", in.Overhead)
+	}
+	switch {
+	case c.MTU < MinMTU:
+		c.MTU = MinMTU
+		c.Reason += fmt.Sprintf(", raised to the minimum %d", MinMTU)
+	case c.MTU > MaxMTU:
+		c.MTU = MaxMTU
+		c.Reason += fmt.Sprintf(", capped at %d", MaxMTU)
+	}
+	return c
+}
@@ -0,0 +1,308 @@
+package routeplan
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// TUN addressing used by every plan.
+var (
+	TUNLocal4 = netip.MustParseAddr("10.255.0.1")
+	TUNPeer4  = netip.MustParseAddr("10.255.0.2")
+	TUNLocal6 = netip.MustParsePrefix("fd73:706c::1/64")
+
+	default4 = netip.MustParsePrefix("0.0.0.0/0")
+	default6 = netip.MustParsePrefix("::/0")
+)
+
+// DefaultMTU is used when Input.MTU is zero.
+const DefaultMTU = 1500
+
+// Gateway is an original default route.
+type Gateway struct {
+	Addr      netip.Addr // invalid when the host has no such default route
+	Interface string
+}
+
+// LANPrefix is a directly connected network and the interface it is on.
+type LANPrefix struct {
+	Prefix    netip.Prefix
+	Interface string
+}
+
+// Input describes the environment a plan is built for.
+type Input struct {
+	TUNName  string
+	MTU      int            // 0: DefaultMTU
+	Local4   netip.Addr     // TUN address; invalid: TUNLocal4 (WireGuard uses its assigned one)
+	Proxy    []netip.Addr   // resolved proxy endpoint addresses
+	Bypass   []netip.Prefix // caller-provided bypass prefixes
+	LAN      []LANPrefix    // detected LAN prefixes
+	Gateway4 Gateway
+	Gateway6 Gateway
+
+	// Uplink4 and Uplink6, when valid, carry the pinned routes (proxy,
+	// bypass, exclude) instead of Gateway4/Gateway6, e.g. to keep the proxy
+	// connection on Ethernet while Wi-Fi holds the system default. The
+	// Gateway fields are still what teardown restores.
+	Uplink4 Gateway
+	Uplink6 Gateway
+
+	// Include, when non-empty, tunnels only these destinations and leaves
+	// the default routes alone (split tunnel). Exclude prefixes are pinned
+	// outside the TUN in either mode.
+	Include []netip.Prefix
+	Exclude []netip.Prefix
+
+	IPv6      bool // caller asked for dual-stack routing
+	ProxyIPv6 bool // probe reported IPv6 egress through the proxy
+
+	// TUN4 replaces the default TUN addressing (DefaultTUNAddr) when its
+	// Local prefix is valid; see ParseTUNAddr. Local4 still wins for the
+	// local address.
+	TUN4 TUNAddr
+}
+
+// Reason explains why a route exists.
+type Reason string
+
+const (
+	ReasonDefault Reason = "default"
+	ReasonProxy   Reason = "proxy"
+	ReasonBypass  Reason = "bypass"
+	ReasonLAN     Reason = "lan"
+	ReasonInclude Reason = "include"
+	ReasonExclude Reason = "exclude"
+)
+
+// Route is one route to install (or restore).
+type Route struct {
+	Dst    netip.Prefix
+	Via    netip.Addr // invalid: on-link via Dev
+	Dev    string     // "" lets the OS pick from Via
+	Reason Reason
+}
+
+// TUNConfig is the address configuration for the TUN device.
+type TUNConfig struct {
+	Name   string
+	MTU    int
+	Local4 netip.Addr
+	Peer4  netip.Addr
+	Local6 netip.Prefix // invalid when IPv6 is not routed
+
+	Subnet4 netip.Prefix // network of Local4 and Peer4
+}
+
+// Plan is the complete change set.
+type Plan struct {
+	TUN      TUNConfig
+	Uplink4  Gateway // egress of IPv4 pinned routes
+	Uplink6  Gateway // egress of IPv6 pinned routes (set only when IPv6 is routed)
+	Routes   []Route // apply in order
+	Restore  []Route // original defaults to reinstate on teardown
+	IPv6     bool    // IPv6 traffic is routed through the TUN
+	Split    bool    // only Input.Include is tunneled; defaults are untouched
+	Warnings []string
+}
+
+// Build computes a plan. It fails only when IPv4 routing is impossible (no
+// original IPv4 gateway to pin bypass routes to).
+func Build(in Input) (Plan, error) {
+	if !in.Gateway4.Addr.IsValid() {
+		return Plan{}, errors.New("no IPv4 default gateway; cannot pin bypass routes")
+	}
+	mtu := in.MTU
+	if mtu == 0 {
+		mtu = DefaultMTU
+	}
+	addr := DefaultTUNAddr()
+	if in.TUN4.Local.IsValid() {
+		addr = in.TUN4
+	}
+	p := Plan{TUN: TUNConfig{Name: in.TUNName, MTU: mtu, Local4: addr.Local.Addr(), Peer4: addr.Peer, Subnet4: addr.Subnet()}}
+	if in.Local4.IsValid() {
+		p.TUN.Local4 = in.Local4
+	}
+	p.Uplink4 = in.Gateway4
+	if in.Uplink4.Addr.IsValid() {
+		p.Uplink4 = in.Uplink4
+	}
+
+	if in.IPv6 {
+		switch {
+		case !in.ProxyIPv6:
+			p.Warnings = append(p.Warnings, "ipv6 requested but the proxy does not report IPv6 support; IPv6 traffic is not routed")
+		case !in.Gateway6.Addr.IsValid():
+			p.Warnings = append(p.Warnings, "ipv6 requested but the host has no IPv6 default route; IPv6 traffic is not routed")
+		default:
+			p.IPv6 = true
+			p.TUN.Local6 = TUNLocal6
+			p.Uplink6 = in.Gateway6
+			if in.Uplink6.Addr.IsValid() {
+				p.Uplink6 = in.Uplink6
+			}
+		}
+	}
+
+	// Pinned routes first, so nothing is briefly routed into the TUN.
+	seen := map[netip.Prefix]bool{}
+	pin := func(dst netip.Prefix, why Reason) {
+		if seen[dst] {
+			return
+		}
+		switch {
+		case dst.Addr().Is4():
+			seen[dst] = true
+			p.Routes = append(p.Routes, Route{Dst: dst, Via: p.Uplink4.Addr, Dev: p.Uplink4.Interface, Reason: why})
+		case p.IPv6:
+			seen[dst] = true
+			p.Routes = append(p.Routes, Route{Dst: dst, Via: p.Uplink6.Addr, Dev: p.Uplink6.Interface, Reason: why})
+		}
+	}
+	for _, a := range in.Proxy {
+		a = a.Unmap()
+		pin(netip.PrefixFrom(a, a.BitLen()), ReasonProxy)
+	}
+	for _, b := range in.Bypass {
+		pin(b, ReasonBypass)
+	}
+	for _, x := range in.Exclude {
+		pin(x, ReasonExclude)
+	}
+	for _, l := range in.LAN {
+		// LAN networks are on-link: pin them to their interface, not the
+		// gateway. Link-local ranges have no single interface and are never
+		// captured by a default route, so they need no pin.
+		dst := l.Prefix
+		if seen[dst] || dst.Addr().IsLinkLocalUnicast() || l.Interface == "" || (dst.Addr().Is6() && !p.IPv6) {
+			continue
+		}
+		seen[dst] = true
+		p.Routes = append(p.Routes, Route{Dst: dst, Dev: l.Interface, Reason: ReasonLAN})
+	}
+
+	// Split tunnel: route only the included prefixes into the TUN. Longer
+	// exclude/bypass pins still win inside them.
+	if len(in.Include) > 0 {
+		p.Split = true
+		skipped6 := false
+		for _, dst := range in.Include {
+			switch {
+			case dst.Addr().Is4():
+				p.Routes = append(p.Routes, Route{Dst: dst, Via: p.TUN.Peer4, Dev: in.TUNName, Reason: ReasonInclude})
+			case p.IPv6:
+				p.Routes = append(p.Routes, Route{Dst: dst, Dev: in.TUNName, Reason: ReasonInclude})
+			default:
+				skipped6 = true
+			}
+		}
+		if skipped6 {
+			p.Warnings = append(p.Warnings, "ipv6 include prefixes are ignored while IPv6 is not routed")
+		}
+		return p, nil
+	}
+
+	p.Routes = append(p.Routes, Route{Dst: default4, Via: p.TUN.Peer4, Dev: in.TUNName, Reason: ReasonDefault})
+	p.Restore = append(p.Restore, Route{Dst: default4, Via: in.Gateway4.Addr, Dev: in.Gateway4.Interface, Reason: ReasonDefault})
+	if p.IPv6 {
+		p.Routes = append(p.Routes, Route{Dst: default6, Dev: in.TUNName, Reason: ReasonDefault})
+		p.Restore = append(p.Restore, Route{Dst: default6, Via: in.Gateway6.Addr, Dev: in.Gateway6.Interface, Reason: ReasonDefault})
+	}
+	return p, nil
+}
+
+// MaxUplinks caps an outbound interface preference list.
+const MaxUplinks = 16
+
+// ChooseUplink returns the first interface in prefs that has a default
+// route with a gateway in routes, and its position in prefs. When none
+// does, it returns ok=false and the caller keeps the system default.
+func ChooseUplink(prefs []string, routes []Gateway) (g Gateway, idx int, ok bool) {
+	for i, name := range prefs {
+		for _, r := range routes {
+			if r.Interface == name && r.Addr.IsValid() {
+				return r, i, true
+			}
+		}
+	}
+	return Gateway{}, -1, false
+}
+
+// ParseUplinks validates an outbound interface preference list: at most
+// MaxUplinks distinct, non-empty interface names. field names the list in
+// error messages.
+func ParseUplinks(field string, in []string) ([]string, error) {
+	if len(in) > MaxUplinks {
+		return nil, fmt.Errorf("%s: too many entries (%d > %d)", field, len(in), MaxUplinks)
+	}
+	out := make([]string, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	for _, raw := range in {
+		name := strings.TrimSpace(raw)
+		if name == "" || len(name) > 15 || strings.ContainsAny(name, " /\t") {
+			return nil, fmt.Errorf("%s: invalid interface name %q", field, raw)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s: duplicate interface %s", field, name)
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+// MaxCIDRs caps include/exclude lists to keep plans bounded.
+const MaxCIDRs = 256
+
+// ParseCIDRs validates a list of destination prefixes for Input.Include or
+// Input.Exclude. Bare addresses become host prefixes; prefixes are masked
+// and unmapped. Duplicates and /0 (use the full tunnel instead) are
+// rejected. field names the list in error messages.
+func ParseCIDRs(field string, in []string) ([]netip.Prefix, error) {
+	if len(in) > MaxCIDRs {
+		return nil, fmt.Errorf("%s: too many entries (%d > %d)", field, len(in), MaxCIDRs)
+	}
+	out := make([]netip.Prefix, 0, len(in))
+	seen := make(map[netip.Prefix]bool, len(in))
+	for _, raw := range in {
+		s := strings.TrimSpace(raw)
+		var p netip.Prefix
+		if a, err := netip.ParseAddr(s); err == nil {
+			a = a.Unmap()
+			p = netip.PrefixFrom(a, a.BitLen())
+		} else if p, err = netip.ParsePrefix(s); err != nil {
+			return nil, fmt.Errorf("%s: invalid CIDR %q", field, s)
+		}
+		if p.Addr().Zone() != "" {
+			return nil, fmt.Errorf("%s: invalid CIDR %q: zones are not allowed", field, s)
+		}
+		if p.Addr().Is4In6() {
+			p = netip.PrefixFrom(p.Addr().Unmap(), max(p.Bits()-96, 0))
+		}
+		p = p.Masked()
+		if p.Bits() == 0 {
+			return nil, fmt.Errorf("%s: %s covers every destination", field, p)
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("%s: duplicate entry %s", field, p)
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// String renders r like an `ip route` argument list, for logs and plans.
+func (r Route) String() string {
+	s := r.Dst.String()
+	if r.Via.IsValid() {
+		s += " via " + r.Via.String()
+	}
+	if r.Dev != "" {
+		s += " dev " + r.Dev
+	}
+	return fmt.Sprintf("%s (%s)", s, r.Reason)
+}
@@ -0,0 +1,107 @@
+package routeplan
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// TUNSubnet4 is the network of the default TUN addressing.
+var TUNSubnet4 = netip.MustParsePrefix("10.255.0.0/30")
+
+// TUNAddr is an IPv4 TUN addressing: the local address with the subnet
+// it sits in, and the point-to-point peer inside that subnet.
+type TUNAddr struct {
+	Local netip.Prefix // e.g. 10.77.0.1/30
+	Peer  netip.Addr
+}
+
+// Subnet is the masked network of the addressing.
+func (t TUNAddr) Subnet() netip.Prefix { return t.Local.Masked() }
+
+// DefaultTUNAddr is the addressing used when a start request names none.
+func DefaultTUNAddr() TUNAddr {
+	return TUNAddr{Local: netip.PrefixFrom(TUNLocal4, TUNSubnet4.Bits()), Peer: TUNPeer4}
+}
+
+// ParseTUNAddr validates a requested TUN addressing. local is an IPv4
+// address with its subnet ("10.77.0.1/30"); peer, when empty, is the
+// next usable address after local (or the one before it at the end of
+// the subnet). Subnets between /8 and /31 are accepted; the network and
+// broadcast addresses are refused except on a /31.
+func ParseTUNAddr(local, peer string) (TUNAddr, error) {
+	p, err := netip.ParsePrefix(strings.TrimSpace(local))
+	if err != nil {
+		return TUNAddr{}, fmt.Errorf("tun_address: invalid address %q: want an IPv4 address with subnet, e.g. 10.77.0.1/30", local)
+	}
+	if !p.Addr().Is4() {
+		return TUNAddr{}, fmt.Errorf("tun_address: %s is not IPv4", p)
+	}
+	if p.Bits() < 8 || p.Bits() > 31 {
+		return TUNAddr{}, fmt.Errorf("tun_address: subnet /%d out of range (/8 to /31)", p.Bits())
+	}
+	a := p.Addr()
+	if a.IsLoopback() || a.IsMulticast() || a.IsLinkLocalUnicast() || a.IsUnspecified() {
+		return TUNAddr{}, fmt.Errorf("tun_address: %s is not a unicast address", a)
+	}
+	t := TUNAddr{Local: p}
+	if !t.usable(a) {
+		return TUNAddr{}, fmt.Errorf("tun_address: %s is the network or broadcast address of %s", a, t.Subnet())
+	}
+
+	if s := strings.TrimSpace(peer); s != "" {
+		if t.Peer, err = netip.ParseAddr(s); err != nil || !t.Peer.Is4() {
+			return TUNAddr{}, fmt.Errorf("tun_peer: invalid IPv4 address %q", peer)
+		}
+	} else if next := a.Next(); t.usable(next) {
+		t.Peer = next
+	} else {
+		t.Peer = a.Prev()
+	}
+	switch {
+	case t.Peer == a:
+		return TUNAddr{}, fmt.Errorf("tun_peer: %s is the local address", t.Peer)
+	case !t.Subnet().Contains(t.Peer):
+		return TUNAddr{}, fmt.Errorf("tun_peer: %s is outside %s", t.Peer, t.Subnet())
+	case !t.usable(t.Peer):
+		return TUNAddr{}, fmt.Errorf("tun_peer: %s is the network or broadcast address of %s", t.Peer, t.Subnet())
+	}
+	return t, nil
+}
+
+// usable reports whether a is a host address of the subnet.
+func (t TUNAddr) usable(a netip.Addr) bool {
+	n := t.Subnet()
+	if !n.Contains(a) {
+		return false
+	}
+	if n.Bits() == 31 {
+		return true
+	}
+	return a != n.Addr() && a != lastAddr(n)
+}
+
+// lastAddr is the highest address of p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().As4()
+	for i := p.Bits(); i < 32; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	return netip.AddrFrom4(b)
+}
+
+// CheckTUNSubnet fails when subnet overlaps any of live, the networks
+// in use on the host (detected LAN prefixes and interface addresses):
+// routing the TUN there would shadow or capture a real network.
+func CheckTUNSubnet(subnet netip.Prefix, live []LANPrefix) error {
+	for _, l := range live {
+		if !l.Prefix.Addr().Is4() || !l.Prefix.Overlaps(subnet) {
+			continue
+		}
+		if l.Interface != "" {
+			return fmt.Errorf("tun subnet %s overlaps %s on %s", subnet, l.Prefix.Masked(), l.Interface)
+		}
+		return fmt.Errorf("tun subnet %s overlaps %s", subnet, l.Prefix.Masked())
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+//go:build darwin
+
+package routerepair
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+)
+
+const method = "route"
+
+// ApplyRoute installs rt with "route change", falling back to "route add"
+// when the prefix has no route to change.
+func ApplyRoute(rt routeplan.Route) error {
+	args := destArgs(rt)
+	switch {
+	case rt.Via.IsValid() && rt.Via.Is6() && rt.Via.IsLinkLocalUnicast() && rt.Dev != "":
+		args = append(args, rt.Via.String()+"%"+rt.Dev)
+	case rt.Via.IsValid():
+		args = append(args, rt.Via.String())
+	default:
+		args = append(args, "-interface", rt.Dev)
+	}
+	route := func(cmd string) ([]byte, error) {
+		return exec.Command("route", append([]string{"-n", cmd}, args...)...).CombinedOutput()
+	}
+	out, err := route("change")
+	if err == nil {
+		return nil
+	}
+	if out2, err2 := route("add"); err2 != nil {
+		return fmt.Errorf("route change/add %s: %v: %s / %s", rt.Dst, err2,
+			strings.TrimSpace(string(out)), strings.TrimSpace(string(out2)))
+	}
+	return nil
+}
+
+// DeleteRoute removes rt with "route delete".
+func DeleteRoute(rt routeplan.Route) error {
+	args := append([]string{"-n", "delete"}, destArgs(rt)...)
+	if out, err := exec.Command("route", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("route delete %s: %w: %s", rt.Dst, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// destArgs are the route(8) arguments naming rt's destination.
+func destArgs(rt routeplan.Route) []string {
+	args := []string{"-inet"}
+	if rt.Dst.Addr().Is6() {
+		args[0] = "-inet6"
+	}
+	switch {
+	case rt.Dst.Bits() == 0:
+		return append(args, "default")
+	case rt.Dst.IsSingleIP():
+		return append(args, "-host", rt.Dst.Addr().String())
+	}
+	return append(args, "-net", rt.Dst.String())
+}
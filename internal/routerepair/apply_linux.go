@@ -0,0 +1,21 @@
+//go:build linux
+
+package routerepair
+
+import (
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+	"github.com/sanverite/simple-packet-logger/internal/rtnl"
+)
+
+const method = "rtnetlink"
+
+// ApplyRoute installs rt over rtnetlink, overwriting any route the prefix
+// has.
+func ApplyRoute(rt routeplan.Route) error {
+	return rtnl.ReplaceRoute(rtnl.Route{Dst: rt.Dst, Via: rt.Via, Dev: rt.Dev})
+}
+
+// DeleteRoute removes rt over rtnetlink.
+func DeleteRoute(rt routeplan.Route) error {
+	return rtnl.DeleteRoute(rtnl.Route{Dst: rt.Dst, Via: rt.Via, Dev: rt.Dev})
+}
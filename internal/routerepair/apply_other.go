@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package routerepair
+
+import "github.com/sanverite/simple-packet-logger/internal/routeplan"
+
+const method = "unsupported"
+
+// ApplyRoute returns ErrUnsupported.
+func ApplyRoute(routeplan.Route) error { return ErrUnsupported }
+
+// DeleteRoute returns ErrUnsupported.
+func DeleteRoute(routeplan.Route) error { return ErrUnsupported }
@@ -0,0 +1,21 @@
+//go:build windows
+
+package routerepair
+
+import (
+	"github.com/sanverite/simple-packet-logger/internal/iphlp"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+)
+
+const method = "iphlpapi"
+
+// ApplyRoute installs rt through iphlpapi, replacing every route the
+// prefix has.
+func ApplyRoute(rt routeplan.Route) error {
+	return iphlp.ReplaceRoute(iphlp.Route{Dst: rt.Dst, Via: rt.Via, Dev: rt.Dev})
+}
+
+// DeleteRoute removes rt through iphlpapi.
+func DeleteRoute(rt routeplan.Route) error {
+	return iphlp.DeleteRoute(iphlp.Route{Dst: rt.Dst, Via: rt.Via, Dev: rt.Dev})
+}
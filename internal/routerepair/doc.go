@@ -0,0 +1,29 @@
+// Package routerepair keeps the applied route plan in the host's routing
+// table while the agent is active, re-applying routes that VPN software,
+// DHCP renewals, or a network change removed or replaced.
+//
+// # Reconciling
+//
+// The orchestrator hands the Repairer the plan it applied (SetPlan) and
+// clears it on stop or pause. Every check (default 10s), while
+// Options.Active reports true, each planned route is looked up with
+// netinfo.LookupRoute for its destination:
+//
+//   - missing: no route covers the destination, or only a broader one
+//     does (e.g. a proxy host route deleted while the default points at
+//     the TUN).
+//   - changed: a route for the same prefix leaves through another
+//     interface or gateway (e.g. a DHCP renewal reinstating the default
+//     route on Wi-Fi).
+//
+// Either way the route is re-applied with ApplyRoute, which replaces
+// whatever route the prefix has: over rtnetlink on Linux, with route(8)
+// on darwin, and through iphlpapi on Windows (Method names which). A more specific route covering the
+// destination is left alone; it may be another planned route.
+//
+// # Reporting
+//
+// Each repair, successful or not, is passed to Options.OnRepair; the agent
+// records it as an event with kind=route_repair and counts it in
+// GET /v1/metrics. Status holds the totals and the latest repair.
+package routerepair
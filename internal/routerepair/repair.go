@@ -0,0 +1,224 @@
+package routerepair
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+)
+
+// DefaultInterval is the pause between checks.
+const DefaultInterval = 10 * time.Second
+
+// ErrUnsupported is returned by ApplyRoute on platforms without an
+// implementation.
+var ErrUnsupported = errors.New("route repair not supported on this platform")
+
+// Method reports how ApplyRoute changes routes on this platform.
+func Method() string { return method }
+
+// Reason says why a route was repaired.
+type Reason string
+
+const (
+	ReasonMissing Reason = "missing" // no route as specific as the planned one
+	ReasonChanged Reason = "changed" // the prefix leaves another way
+)
+
+// Options configures a Repairer.
+type Options struct {
+	// Active reports whether the plan should be in place (the agent is
+	// active or degraded). Nil checks whenever a plan is set.
+	Active func() bool
+	// Lookup reports the route the host uses for a destination (default
+	// netinfo.LookupRoute).
+	Lookup func(netip.Addr) (netinfo.Route, error)
+	// Apply installs a planned route, replacing the prefix's current route
+	// (default ApplyRoute).
+	Apply func(routeplan.Route) error
+	// Interval is the pause between checks in Run (default DefaultInterval).
+	Interval time.Duration
+	// OnRepair, when set, is called after each repair attempt.
+	OnRepair func(Repair)
+	// Logger receives repairer records. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Repair is one re-applied route.
+type Repair struct {
+	Route  routeplan.Route
+	Reason Reason
+	Found  netinfo.Route // route the host used instead; zero when none
+	Err    error         // nil when the route was re-applied
+	At     time.Time
+}
+
+// Status is the repairer's running totals.
+type Status struct {
+	Planned    int // routes in the current plan; 0 when none is set
+	Checked    time.Time
+	Repairs    int // successful repairs
+	Failures   int // failed repair attempts
+	LastRepair Repair
+}
+
+// Repairer reconciles a route plan with the routing table.
+type Repairer struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	plan   []routeplan.Route
+	status Status
+}
+
+// New constructs a repairer; set a plan and call Check or Run.
+func New(opts Options) *Repairer {
+	if opts.Lookup == nil {
+		opts.Lookup = netinfo.LookupRoute
+	}
+	if opts.Apply == nil {
+		opts.Apply = ApplyRoute
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Repairer{opts: opts, logger: logger}
+}
+
+// SetPlan replaces the routes to keep in place with p's; nil clears them.
+func (r *Repairer) SetPlan(p *routeplan.Plan) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.plan = nil
+	if p != nil {
+		r.plan = append([]routeplan.Route(nil), p.Routes...)
+	}
+	r.status.Planned = len(r.plan)
+}
+
+// ReplaceRoutes swaps the planned routes with reason why for routes, in
+// the place of the first of them (before the rest of the plan when there
+// were none), e.g. when the proxy host resolves to new addresses. It does
+// nothing while no plan is set.
+func (r *Repairer) ReplaceRoutes(why routeplan.Reason, routes []routeplan.Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.plan == nil {
+		return
+	}
+	var out []routeplan.Route
+	placed := false
+	for _, rt := range r.plan {
+		if rt.Reason != why {
+			out = append(out, rt)
+			continue
+		}
+		if !placed {
+			out = append(out, routes...)
+			placed = true
+		}
+	}
+	if !placed {
+		out = append(append([]routeplan.Route(nil), routes...), out...)
+	}
+	r.plan = out
+	r.status.Planned = len(r.plan)
+}
+
+// Status returns the totals so far.
+func (r *Repairer) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Check looks up every planned route once and re-applies those that
+// drifted. It returns the repairs attempted.
+func (r *Repairer) Check() []Repair {
+	r.mu.Lock()
+	plan := r.plan
+	r.mu.Unlock()
+	if len(plan) == 0 || (r.opts.Active != nil && !r.opts.Active()) {
+		return nil
+	}
+
+	var repairs []Repair
+	for _, rt := range plan {
+		found, err := r.opts.Lookup(rt.Dst.Addr())
+		var reason Reason
+		switch {
+		case errors.Is(err, netinfo.ErrUnsupported):
+			return nil
+		case errors.Is(err, netinfo.ErrNoRoute):
+			reason = ReasonMissing
+		case err != nil:
+			r.logger.Warn("route lookup failed", "dst", rt.Dst, "err", err)
+			continue
+		case found.Prefix.Bits() < rt.Dst.Bits():
+			reason = ReasonMissing
+		case found.Prefix == rt.Dst && drifted(rt, found):
+			reason = ReasonChanged
+		default:
+			continue
+		}
+		rep := Repair{Route: rt, Reason: reason, Found: found, At: time.Now()}
+		rep.Err = r.opts.Apply(rt)
+		if rep.Err != nil {
+			r.logger.Warn("route repair failed", "dst", rt.Dst, "reason", reason, "err", rep.Err)
+		} else {
+			r.logger.Info("route repaired", "dst", rt.Dst, "via", rt.Via, "dev", rt.Dev, "reason", reason)
+		}
+		repairs = append(repairs, rep)
+	}
+
+	r.mu.Lock()
+	r.status.Checked = time.Now()
+	for _, rep := range repairs {
+		if rep.Err != nil {
+			r.status.Failures++
+		} else {
+			r.status.Repairs++
+		}
+		r.status.LastRepair = rep
+	}
+	r.mu.Unlock()
+	if r.opts.OnRepair != nil {
+		for _, rep := range repairs {
+			r.opts.OnRepair(rep)
+		}
+	}
+	return repairs
+}
+
+// Run checks every interval until ctx is done.
+func (r *Repairer) Run(ctx context.Context) {
+	t := time.NewTicker(r.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			r.Check()
+		}
+	}
+}
+
+// drifted reports whether found, a route for rt's prefix, leaves another
+// way than rt.
+func drifted(rt routeplan.Route, found netinfo.Route) bool {
+	if rt.Dev != "" && found.Interface != rt.Dev {
+		return true
+	}
+	return rt.Via.IsValid() && found.Gateway != rt.Via
+}
@@ -0,0 +1,15 @@
+// Package rtnl changes Linux interfaces, addresses, and routes over
+// rtnetlink, the kernel interface "ip" itself uses, so the agent needs
+// neither iproute2 nor its output format.
+//
+// Each call opens a NETLINK_ROUTE socket, sends one request with
+// NLM_F_ACK, and returns the errno of the kernel's acknowledgement
+// (EEXIST, ESRCH, EPERM without CAP_NET_ADMIN, ...). Routes go to the main
+// table: ReplaceRoute overwrites whatever route the prefix has, as
+// "ip route replace" does, and Route.Blackhole installs a route that
+// discards its traffic. AddAddr, SetLinkUp, and DeleteLink configure the
+// TUN device (see package tundev).
+//
+// Reading routes stays in package netinfo. The package builds on Linux
+// only.
+package rtnl
@@ -0,0 +1,236 @@
+//go:build linux
+
+package rtnl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync/atomic"
+	"syscall"
+)
+
+// Route is a main-table route to install or delete.
+type Route struct {
+	Dst       netip.Prefix
+	Via       netip.Addr // invalid: on-link via Dev
+	Dev       string     // "" lets the kernel pick from Via
+	Blackhole bool       // discard matching packets; Via and Dev are ignored
+}
+
+// ReplaceRoute installs rt, overwriting any route its prefix has (like
+// "ip route replace").
+func ReplaceRoute(rt Route) error {
+	body, err := routeMsg(rt)
+	if err != nil {
+		return err
+	}
+	if err := request(syscall.RTM_NEWROUTE, syscall.NLM_F_CREATE|syscall.NLM_F_REPLACE, body); err != nil {
+		return fmt.Errorf("replace route %s: %w", rt.Dst, err)
+	}
+	return nil
+}
+
+// DeleteRoute removes rt. A route that does not exist is ESRCH.
+func DeleteRoute(rt Route) error {
+	body, err := routeMsg(rt)
+	if err != nil {
+		return err
+	}
+	if err := request(syscall.RTM_DELROUTE, 0, body); err != nil {
+		return fmt.Errorf("delete route %s: %w", rt.Dst, err)
+	}
+	return nil
+}
+
+// routeMsg encodes rt as an rtmsg with its attributes.
+func routeMsg(rt Route) ([]byte, error) {
+	if !rt.Dst.IsValid() {
+		return nil, errors.New("route without destination")
+	}
+	dst := rt.Dst.Masked()
+	m := message{b: make([]byte, syscall.SizeofRtMsg)}
+	m.b[0] = family(dst.Addr())
+	m.b[1] = byte(dst.Bits())
+	m.b[4] = syscall.RT_TABLE_MAIN
+	m.b[5] = syscall.RTPROT_BOOT
+	m.b[6] = syscall.RT_SCOPE_UNIVERSE
+	m.b[7] = syscall.RTN_UNICAST
+	if dst.Bits() > 0 {
+		m.attr(syscall.RTA_DST, dst.Addr().AsSlice())
+	}
+	if rt.Blackhole {
+		m.b[7] = syscall.RTN_BLACKHOLE
+		return m.b, nil
+	}
+	if rt.Via.IsValid() {
+		if rt.Via.Is4() != dst.Addr().Is4() {
+			return nil, fmt.Errorf("route %s via %s: address families differ", dst, rt.Via)
+		}
+		m.attr(syscall.RTA_GATEWAY, rt.Via.AsSlice())
+	} else {
+		m.b[6] = syscall.RT_SCOPE_LINK
+	}
+	if rt.Dev != "" {
+		idx, err := index(rt.Dev)
+		if err != nil {
+			return nil, err
+		}
+		m.attr(syscall.RTA_OIF, uint32Bytes(idx))
+	} else if !rt.Via.IsValid() {
+		return nil, fmt.Errorf("route %s: needs a gateway or an interface", dst)
+	}
+	return m.b, nil
+}
+
+// AddAddr assigns local (address and subnet) to the interface name,
+// replacing the same address if present. A valid peer makes it a
+// point-to-point address.
+func AddAddr(name string, local netip.Prefix, peer netip.Addr) error {
+	idx, err := index(name)
+	if err != nil {
+		return err
+	}
+	m := message{b: make([]byte, syscall.SizeofIfAddrmsg)}
+	m.b[0] = family(local.Addr())
+	m.b[1] = byte(local.Bits())
+	binary.NativeEndian.PutUint32(m.b[4:], idx)
+	m.attr(syscall.IFA_LOCAL, local.Addr().AsSlice())
+	addr := local.Addr()
+	if peer.IsValid() {
+		addr = peer
+	}
+	m.attr(syscall.IFA_ADDRESS, addr.AsSlice())
+	if err := request(syscall.RTM_NEWADDR, syscall.NLM_F_CREATE|syscall.NLM_F_REPLACE, m.b); err != nil {
+		return fmt.Errorf("add address %s to %s: %w", local, name, err)
+	}
+	return nil
+}
+
+// SetLinkUp brings the interface name up, setting its MTU first when mtu
+// is positive.
+func SetLinkUp(name string, mtu int) error {
+	idx, err := index(name)
+	if err != nil {
+		return err
+	}
+	m := ifInfo(idx)
+	binary.NativeEndian.PutUint32(m.b[8:], syscall.IFF_UP)  // flags
+	binary.NativeEndian.PutUint32(m.b[12:], syscall.IFF_UP) // change
+	if mtu > 0 {
+		m.attr(syscall.IFLA_MTU, uint32Bytes(uint32(mtu)))
+	}
+	if err := request(syscall.RTM_NEWLINK, 0, m.b); err != nil {
+		return fmt.Errorf("set %s up: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteLink removes the interface name.
+func DeleteLink(name string) error {
+	idx, err := index(name)
+	if err != nil {
+		return err
+	}
+	if err := request(syscall.RTM_DELLINK, 0, ifInfo(idx).b); err != nil {
+		return fmt.Errorf("delete link %s: %w", name, err)
+	}
+	return nil
+}
+
+// ifInfo starts an ifinfomsg for the interface with index idx.
+func ifInfo(idx uint32) message {
+	m := message{b: make([]byte, syscall.SizeofIfInfomsg)}
+	m.b[0] = syscall.AF_UNSPEC
+	binary.NativeEndian.PutUint32(m.b[4:], idx)
+	return m
+}
+
+// message is a request body: a fixed header followed by attributes.
+type message struct{ b []byte }
+
+// attr appends one rtattr, padded to the netlink alignment.
+func (m *message) attr(typ uint16, data []byte) {
+	var hdr [syscall.SizeofRtAttr]byte
+	binary.NativeEndian.PutUint16(hdr[0:], uint16(syscall.SizeofRtAttr+len(data)))
+	binary.NativeEndian.PutUint16(hdr[2:], typ)
+	m.b = append(m.b, hdr[:]...)
+	m.b = append(m.b, data...)
+	for len(m.b)%syscall.NLMSG_ALIGNTO != 0 {
+		m.b = append(m.b, 0)
+	}
+}
+
+var seq atomic.Uint32
+
+// request sends one rtnetlink request and waits for the kernel's
+// acknowledgement, returning the errno it carries.
+func request(typ, flags uint16, body []byte) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(fd)
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Bind(fd, sa); err != nil {
+		return os.NewSyscallError("bind", err)
+	}
+
+	n := seq.Add(1)
+	msg := make([]byte, syscall.SizeofNlMsghdr, syscall.SizeofNlMsghdr+len(body))
+	msg = append(msg, body...)
+	binary.NativeEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.NativeEndian.PutUint16(msg[4:], typ)
+	binary.NativeEndian.PutUint16(msg[6:], flags|syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	binary.NativeEndian.PutUint32(msg[8:], n)
+	if err := syscall.Sendto(fd, msg, 0, sa); err != nil {
+		return os.NewSyscallError("sendto", err)
+	}
+
+	buf := make([]byte, os.Getpagesize())
+	for {
+		size, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return os.NewSyscallError("recvfrom", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:size])
+		if err != nil {
+			return fmt.Errorf("parse netlink: %w", err)
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != n || m.Header.Type != syscall.NLMSG_ERROR {
+				continue
+			}
+			if len(m.Data) < 4 {
+				return errors.New("short netlink acknowledgement")
+			}
+			if code := int32(binary.NativeEndian.Uint32(m.Data)); code != 0 {
+				return syscall.Errno(-code)
+			}
+			return nil
+		}
+	}
+}
+
+// index resolves an interface name.
+func index(name string) (uint32, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(ifi.Index), nil
+}
+
+func family(a netip.Addr) byte {
+	if a.Is4() {
+		return syscall.AF_INET
+	}
+	return syscall.AF_INET6
+}
+
+func uint32Bytes(v uint32) []byte {
+	return binary.NativeEndian.AppendUint32(nil, v)
+}
@@ -0,0 +1,90 @@
+package rules
+
+import (
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sanverite/simple-packet-logger/internal/procowner"
+)
+
+// MaxAppRules bounds the number of per-application bypass rules.
+const MaxAppRules = 64
+
+// AppRule exempts traffic owned by a local process from the tunnel. Exactly
+// one field is set: Path matches the executable, User matches the owning
+// account by login name or numeric UID.
+type AppRule struct {
+	Path string `json:"path,omitempty"`
+	User string `json:"user,omitempty"`
+}
+
+// String renders the rule as "path:/usr/bin/x" or "user:backup".
+func (a AppRule) String() string {
+	if a.Path != "" {
+		return "path:" + a.Path
+	}
+	return "user:" + a.User
+}
+
+// Match reports whether o satisfies the rule. Unknown owner fields never
+// match.
+func (a AppRule) Match(o procowner.Owner) bool {
+	if a.Path != "" {
+		return o.Exe != "" && o.Exe == a.Path
+	}
+	if uid, err := strconv.Atoi(a.User); err == nil {
+		return o.UID >= 0 && o.UID == uid
+	}
+	return o.User != "" && o.User == a.User
+}
+
+// ParseApps validates app rules, preserving order.
+func ParseApps(in []AppRule) ([]AppRule, error) {
+	if len(in) > MaxAppRules {
+		return nil, fmt.Errorf("too many app rules (%d > %d)", len(in), MaxAppRules)
+	}
+	out := make([]AppRule, 0, len(in))
+	seen := make(map[AppRule]bool, len(in))
+	for _, raw := range in {
+		a := AppRule{Path: strings.TrimSpace(raw.Path), User: strings.TrimSpace(raw.User)}
+		switch {
+		case (a.Path == "") == (a.User == ""):
+			return nil, fmt.Errorf("app rule must set exactly one of path or user")
+		case a.Path != "" && (!filepath.IsAbs(a.Path) || filepath.Clean(a.Path) != a.Path):
+			return nil, fmt.Errorf("invalid app rule path %q: must be absolute and clean", raw.Path)
+		case a.User != "" && strings.ContainsAny(a.User, " \t:/"):
+			return nil, fmt.Errorf("invalid app rule user %q", raw.User)
+		}
+		if seen[a] {
+			return nil, fmt.Errorf("duplicate app rule %q", a)
+		}
+		seen[a] = true
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+// ExemptFlow reports whether the local socket proto/local belongs to a
+// process matched by an app rule, and which rule matched. Attribution is
+// best effort: a failed lookup or an unknown owner is never exempt.
+func (e *Engine) ExemptFlow(proto string, local netip.AddrPort) (bool, AppRule) {
+	e.mu.Lock()
+	apps := e.apps
+	e.mu.Unlock()
+	if len(apps) == 0 || e.opts.Owner == nil {
+		return false, AppRule{}
+	}
+	o, err := e.opts.Owner(proto, local)
+	if err != nil {
+		return false, AppRule{}
+	}
+	for _, a := range apps {
+		if a.Match(o) {
+			return true, a
+		}
+	}
+	return false, AppRule{}
+}
@@ -0,0 +1,192 @@
+package rules
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// DNS wire constants (RFC 1035, RFC 3596).
+const (
+	dnsHeaderLen = 12
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsTypeAAAA  = 28
+	dnsClassIN   = 1
+	dnsPort      = 53
+	maxPointers  = 16 // compression pointer hops per name
+)
+
+var errMalformed = errors.New("malformed dns message")
+
+type addrRecord struct {
+	addr netip.Addr
+	ttl  time.Duration
+}
+
+// response is the part of a DNS answer the engine needs: the question name
+// and every address reachable from it through the answer section.
+type response struct {
+	question string
+	addrs    []addrRecord
+}
+
+type rr struct {
+	owner string
+	typ   uint16
+	ttl   time.Duration
+	addr  netip.Addr // A/AAAA
+	cname string     // CNAME
+}
+
+// parseResponse decodes a successful (NOERROR) response with one question.
+func parseResponse(msg []byte) (response, error) {
+	if len(msg) < dnsHeaderLen {
+		return response{}, errMalformed
+	}
+	flags := binary.BigEndian.Uint16(msg[2:4])
+	qd := binary.BigEndian.Uint16(msg[4:6])
+	an := binary.BigEndian.Uint16(msg[6:8])
+	if flags&0x8000 == 0 || flags&0x000f != 0 || qd != 1 {
+		return response{}, errMalformed // not a response, an error rcode, or unusual shape
+	}
+	q, off, err := readName(msg, dnsHeaderLen)
+	if err != nil || off+4 > len(msg) {
+		return response{}, errMalformed
+	}
+	off += 4 // qtype, qclass
+
+	var records []rr
+	for range an {
+		owner, next, err := readName(msg, off)
+		if err != nil || next+10 > len(msg) {
+			return response{}, errMalformed
+		}
+		typ := binary.BigEndian.Uint16(msg[next:])
+		class := binary.BigEndian.Uint16(msg[next+2:])
+		ttl := time.Duration(binary.BigEndian.Uint32(msg[next+4:])) * time.Second
+		rdlen := int(binary.BigEndian.Uint16(msg[next+8:]))
+		rdata := next + 10
+		if rdata+rdlen > len(msg) {
+			return response{}, errMalformed
+		}
+		off = rdata + rdlen
+		if class != dnsClassIN {
+			continue
+		}
+		rec := rr{owner: owner, typ: typ, ttl: ttl}
+		switch {
+		case typ == dnsTypeA && rdlen == 4:
+			rec.addr = netip.AddrFrom4([4]byte(msg[rdata : rdata+4]))
+		case typ == dnsTypeAAAA && rdlen == 16:
+			rec.addr = netip.AddrFrom16([16]byte(msg[rdata : rdata+16])).Unmap()
+		case typ == dnsTypeCNAME:
+			if rec.cname, _, err = readName(msg, rdata); err != nil {
+				return response{}, errMalformed
+			}
+		default:
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	// Follow CNAMEs from the question; answers are usually ordered, but a
+	// fixed-point loop does not depend on it.
+	names := map[string]bool{q: true}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range records {
+			if r.typ == dnsTypeCNAME && names[r.owner] && !names[r.cname] {
+				names[r.cname] = true
+				changed = true
+			}
+		}
+	}
+	resp := response{question: q}
+	for _, r := range records {
+		if r.addr.IsValid() && names[r.owner] {
+			resp.addrs = append(resp.addrs, addrRecord{addr: r.addr, ttl: r.ttl})
+		}
+	}
+	return resp, nil
+}
+
+// readName decodes a possibly compressed name at off, returning it in
+// canonical form and the offset just past it in the original position.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for hops := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errMalformed
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return canonical(strings.Join(labels, ".")), next, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(msg) || hops == maxPointers {
+				return "", 0, errMalformed
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			hops++
+		case l&0xc0 != 0:
+			return "", 0, errMalformed
+		default:
+			if off+1+l > len(msg) {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, string(msg[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
+
+// dnsPayload returns the UDP payload of an IPv4/IPv6 packet sent from port
+// 53. Fragments and IPv6 extension headers are not handled.
+func dnsPayload(pkt []byte) ([]byte, bool) {
+	if len(pkt) < 1 {
+		return nil, false
+	}
+	var udp []byte
+	switch pkt[0] >> 4 {
+	case 4:
+		if len(pkt) < 20 {
+			return nil, false
+		}
+		ihl := int(pkt[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(pkt[2:4]))
+		frag := binary.BigEndian.Uint16(pkt[6:8])
+		if pkt[9] != 17 || frag&0x3fff != 0 || ihl < 20 || total > len(pkt) || ihl > total {
+			return nil, false
+		}
+		udp = pkt[ihl:total]
+	case 6:
+		if len(pkt) < 40 || pkt[6] != 17 {
+			return nil, false
+		}
+		plen := int(binary.BigEndian.Uint16(pkt[4:6]))
+		if 40+plen > len(pkt) {
+			return nil, false
+		}
+		udp = pkt[40 : 40+plen]
+	default:
+		return nil, false
+	}
+	if len(udp) < 8 || binary.BigEndian.Uint16(udp[0:2]) != dnsPort {
+		return nil, false
+	}
+	ulen := int(binary.BigEndian.Uint16(udp[4:6]))
+	if ulen < 8 || ulen > len(udp) {
+		return nil, false
+	}
+	return udp[8:ulen], true
+}
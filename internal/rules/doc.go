@@ -0,0 +1,55 @@
+// Package rules implements domain-based split tunneling.
+//
+// # Rules
+//
+// A rule is a domain pattern: either an exact name ("git.example.org") or a
+// wildcard ("*.corp.example.com") that matches every name below the suffix,
+// but not the suffix itself. Patterns are case-insensitive and a trailing dot
+// is ignored. ParsePatterns validates a list (max MaxRules, no duplicates).
+//
+// # DNS-Driven Routes
+//
+// Routes cannot be derived from names directly, so the Engine watches DNS
+// answers instead. The data plane hands every UDP packet read from the TUN
+// to ObservePacket (or a raw DNS payload to ObserveDNS). When the question
+// name of a response matches a rule, each A/AAAA record in the answer
+// section (including those reached through a CNAME chain) gets a host route
+// into the TUN via the configured Router.
+//
+// A route lives for the record's TTL, clamped to [MinRouteTTL, MaxRouteTTL],
+// and is refreshed by later answers. Run removes expired routes; replacing
+// the rules removes routes whose pattern no longer exists.
+//
+// Without a Router (no data plane yet) routes are tracked only, which keeps
+// GET /v1/rules informative and the engine testable.
+//
+// # App Rules
+//
+// An AppRule exempts traffic from a local process, matched by executable
+// path or owning user (name or UID), from the tunnel. The data plane calls
+// ExemptFlow with the local address of each new flow; the engine asks
+// Options.Owner (procowner.Lookup) who owns the socket. Attribution is best
+// effort: sockets can close before the lookup, unprivileged agents may not
+// see other users' processes, and a failed lookup is never treated as a
+// match, so exempt traffic can occasionally still enter the tunnel.
+//
+// # Flow Attribution
+//
+// Decide names the verdict for a flow (tunnel, direct, or block) and the
+// rule behind it: an app rule exempting the local socket, the domain pattern
+// whose route covers the destination, or DefaultRule. Unmatched flows go
+// direct while domain rules exist and through the tunnel otherwise. The
+// flow producer calls Attribute when a flow ends, which also adds it to the
+// per-rule counters, and copies the Decision into the export record.
+// Options.Profile labels decisions ("default" when empty).
+//
+// Stats lists counters for every configured rule, so rules that never match
+// can be found and pruned. Counters live in memory and are dropped with
+// their rule.
+//
+// # Persistence
+//
+// SaveRules and LoadRules store the patterns and app rules under RulesKey
+// in a storage.KV so rules survive restarts. A bare pattern array written
+// by older agents still loads.
+package rules
@@ -0,0 +1,372 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/procowner"
+	"github.com/sanverite/simple-packet-logger/internal/storage"
+)
+
+// Limits and timing.
+const (
+	MaxRules           = 256
+	MinRouteTTL        = time.Minute // short TTLs would churn routes
+	MaxRouteTTL        = 24 * time.Hour
+	DefaultExpireEvery = 30 * time.Second
+)
+
+// RulesKey is the storage key holding the persisted rules.
+const RulesKey = "rules"
+
+// Pattern is a validated, lower-case domain pattern.
+type Pattern string
+
+// Wildcard reports whether p is a "*." suffix pattern.
+func (p Pattern) Wildcard() bool { return strings.HasPrefix(string(p), "*.") }
+
+// Match reports whether name (any case, optional trailing dot) matches p.
+func (p Pattern) Match(name string) bool {
+	name = canonical(name)
+	if suffix, ok := strings.CutPrefix(string(p), "*"); ok {
+		return len(name) > len(suffix) && strings.HasSuffix(name, suffix)
+	}
+	return name == string(p)
+}
+
+func canonical(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+// ParsePatterns validates and canonicalizes patterns, preserving order.
+func ParsePatterns(in []string) ([]Pattern, error) {
+	if len(in) > MaxRules {
+		return nil, fmt.Errorf("too many rules (%d > %d)", len(in), MaxRules)
+	}
+	out := make([]Pattern, 0, len(in))
+	seen := make(map[Pattern]bool, len(in))
+	for _, raw := range in {
+		s := canonical(raw)
+		host := strings.TrimPrefix(s, "*.")
+		if err := validDomain(host); err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", raw, err)
+		}
+		p := Pattern(s)
+		if seen[p] {
+			return nil, fmt.Errorf("duplicate rule %q", s)
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// validDomain checks RFC 1035 length limits and LDH labels.
+func validDomain(s string) error {
+	if s == "" {
+		return fmt.Errorf("empty domain")
+	}
+	if len(s) > 253 {
+		return fmt.Errorf("domain longer than 253 characters")
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("label %q must be 1-63 characters", label)
+		}
+		for i, c := range label {
+			ok := c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' ||
+				c == '-' && i > 0 && i < len(label)-1
+			if !ok {
+				return fmt.Errorf("label %q has invalid characters", label)
+			}
+		}
+	}
+	if !strings.Contains(s, ".") {
+		return fmt.Errorf("domain needs at least two labels")
+	}
+	return nil
+}
+
+// Router installs and removes host routes into the TUN.
+type Router interface {
+	AddHostRoute(a netip.Addr) error
+	DeleteHostRoute(a netip.Addr) error
+}
+
+// Route is a host route installed because of a DNS answer.
+type Route struct {
+	Addr      netip.Addr
+	Domain    string  // question name that produced it
+	Pattern   Pattern // rule that matched
+	ExpiresAt time.Time
+}
+
+// Options configures an Engine.
+type Options struct {
+	// Router applies routes. Nil tracks routes without installing them.
+	Router Router
+	// KV persists rules on SetRules. Nil keeps them in memory only.
+	KV storage.KV
+	// Logger receives route changes. Nil disables logging.
+	Logger *slog.Logger
+	// Owner attributes a local socket to its process for app rules,
+	// normally procowner.Lookup. Nil disables app rules.
+	Owner func(proto string, local netip.AddrPort) (procowner.Owner, error)
+	// Profile labels flow decisions (see Decide). Empty means DefaultProfile.
+	Profile string
+}
+
+// Engine matches DNS answers against rules and manages the resulting routes.
+type Engine struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	patterns []Pattern
+	apps     []AppRule // replaced, never mutated in place
+	routes   map[netip.Addr]Route
+	stats    map[string]*ruleCounter // by rule; see Stats
+	now      func() time.Time
+}
+
+// NewEngine constructs an engine with the given initial patterns and app
+// rules.
+func NewEngine(patterns []Pattern, apps []AppRule, opts Options) *Engine {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Engine{
+		opts:     opts,
+		logger:   logger,
+		patterns: append([]Pattern(nil), patterns...),
+		apps:     append([]AppRule(nil), apps...),
+		routes:   map[netip.Addr]Route{},
+		stats:    map[string]*ruleCounter{},
+		now:      time.Now,
+	}
+}
+
+// Rules returns the current patterns.
+func (e *Engine) Rules() []Pattern {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Pattern(nil), e.patterns...)
+}
+
+// Apps returns the current app rules.
+func (e *Engine) Apps() []AppRule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]AppRule(nil), e.apps...)
+}
+
+// SetRules replaces the patterns and app rules, persists them when a KV is
+// configured, and removes routes whose pattern is gone.
+func (e *Engine) SetRules(patterns []Pattern, apps []AppRule) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.opts.KV != nil {
+		if err := SaveRules(e.opts.KV, patterns, apps); err != nil {
+			return fmt.Errorf("persist rules: %w", err)
+		}
+	}
+	old, oldApps := e.patterns, e.apps
+	e.patterns = append([]Pattern(nil), patterns...)
+	e.apps = append([]AppRule(nil), apps...)
+	keep := make(map[Pattern]bool, len(patterns))
+	for _, p := range patterns {
+		keep[p] = true
+	}
+	for a, r := range e.routes {
+		if !keep[r.Pattern] {
+			e.removeLocked(a, "rule removed")
+		}
+	}
+	e.pruneStatsLocked(old, oldApps)
+	return nil
+}
+
+// Routes returns active routes sorted by address.
+func (e *Engine) Routes() []Route {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]Route, 0, len(e.routes))
+	for _, r := range e.routes {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Addr.Less(out[j].Addr) })
+	return out
+}
+
+// matchLocked returns the first pattern matching name. Callers hold e.mu.
+func (e *Engine) matchLocked(name string) (Pattern, bool) {
+	for _, p := range e.patterns {
+		if p.Match(name) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// ObserveDNS inspects a DNS response payload. Malformed or non-matching
+// messages are ignored; the returned count is the number of routes added
+// or refreshed.
+func (e *Engine) ObserveDNS(msg []byte) int {
+	ans, err := parseResponse(msg)
+	if err != nil {
+		return 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	p, ok := e.matchLocked(ans.question)
+	if !ok {
+		return 0
+	}
+	now := e.now()
+	n := 0
+	for _, rec := range ans.addrs {
+		ttl := min(max(rec.ttl, MinRouteTTL), MaxRouteTTL)
+		r := Route{Addr: rec.addr, Domain: ans.question, Pattern: p, ExpiresAt: now.Add(ttl)}
+		if old, exists := e.routes[rec.addr]; exists {
+			if r.ExpiresAt.Before(old.ExpiresAt) {
+				r.ExpiresAt = old.ExpiresAt
+			}
+			e.routes[rec.addr] = r
+			n++
+			continue
+		}
+		if e.opts.Router != nil {
+			if err := e.opts.Router.AddHostRoute(rec.addr); err != nil {
+				e.logger.Warn("rule route install failed", "addr", rec.addr, "domain", ans.question, "err", err)
+				continue
+			}
+		}
+		e.routes[rec.addr] = r
+		e.logger.Debug("rule route added", "addr", rec.addr, "domain", ans.question, "pattern", p, "ttl", ttl)
+		n++
+	}
+	return n
+}
+
+// ObservePacket extracts a DNS response (UDP source port 53) from an IPv4 or
+// IPv6 packet and passes it to ObserveDNS.
+func (e *Engine) ObservePacket(pkt []byte) int {
+	payload, ok := dnsPayload(pkt)
+	if !ok {
+		return 0
+	}
+	return e.ObserveDNS(payload)
+}
+
+// Expire removes routes past their expiry and returns how many it removed.
+func (e *Engine) Expire() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now()
+	n := 0
+	for a, r := range e.routes {
+		if !now.Before(r.ExpiresAt) {
+			e.removeLocked(a, "expired")
+			n++
+		}
+	}
+	return n
+}
+
+// Flush removes every route, e.g. when the tunnel stops.
+func (e *Engine) Flush() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for a := range e.routes {
+		e.removeLocked(a, "flush")
+	}
+}
+
+func (e *Engine) removeLocked(a netip.Addr, why string) {
+	if e.opts.Router != nil {
+		if err := e.opts.Router.DeleteHostRoute(a); err != nil {
+			e.logger.Warn("rule route removal failed", "addr", a, "err", err)
+		}
+	}
+	delete(e.routes, a)
+	e.logger.Debug("rule route removed", "addr", a, "reason", why)
+}
+
+// Run expires routes every interval until ctx is done, then flushes.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultExpireEvery
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.Flush()
+			return
+		case <-t.C:
+			e.Expire()
+		}
+	}
+}
+
+// storedRules is the persisted form. Older agents stored a bare JSON array
+// of domain patterns, which LoadRules still accepts.
+type storedRules struct {
+	Domains []Pattern `json:"domains"`
+	Apps    []AppRule `json:"apps"`
+}
+
+// SaveRules stores patterns and app rules as JSON under RulesKey.
+func SaveRules(kv storage.KV, patterns []Pattern, apps []AppRule) error {
+	rec := storedRules{Domains: patterns, Apps: apps}
+	if rec.Domains == nil {
+		rec.Domains = []Pattern{}
+	}
+	if rec.Apps == nil {
+		rec.Apps = []AppRule{}
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return kv.Put(RulesKey, b)
+}
+
+// LoadRules reads rules stored by SaveRules, revalidating them. A missing
+// key returns (nil, nil, nil).
+func LoadRules(kv storage.KV) ([]Pattern, []AppRule, error) {
+	b, ok, err := kv.Get(RulesKey)
+	if err != nil || !ok {
+		return nil, nil, err
+	}
+	var raw struct {
+		Domains []string  `json:"domains"`
+		Apps    []AppRule `json:"apps"`
+	}
+	if trimmed := strings.TrimSpace(string(b)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(b, &raw.Domains)
+	} else {
+		err = json.Unmarshal(b, &raw)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	patterns, err := ParsePatterns(raw.Domains)
+	if err != nil {
+		return nil, nil, err
+	}
+	apps, err := ParseApps(raw.Apps)
+	if err != nil {
+		return nil, nil, err
+	}
+	return patterns, apps, nil
+}
@@ -0,0 +1,181 @@
+package rules
+
+import (
+	"net/netip"
+	"slices"
+	"time"
+)
+
+// Verdict is what happened to a flow.
+type Verdict string
+
+const (
+	VerdictTunnel Verdict = "tunnel" // sent through the TUN
+	VerdictDirect Verdict = "direct" // kept outside the TUN
+	VerdictBlock  Verdict = "block"  // dropped; set by producers that filter
+)
+
+// DefaultRule names the decision taken when no rule matched.
+const DefaultRule = "default"
+
+// DefaultProfile labels decisions when Options.Profile is empty.
+const DefaultProfile = "default"
+
+// Decision is the verdict for one flow and what decided it. Rule is a
+// domain pattern, an app rule ("path:..." or "user:..."), or DefaultRule.
+type Decision struct {
+	Verdict Verdict
+	Rule    string
+	Profile string
+}
+
+// RuleStats counts the flows one rule decided.
+type RuleStats struct {
+	Rule      string
+	Kind      string // "domain", "app", "default", or "other"
+	Verdict   Verdict
+	Flows     uint64
+	Packets   uint64
+	Bytes     uint64
+	LastMatch time.Time // zero if it never matched
+}
+
+// ruleCounter accumulates RuleStats; keyed by rule in Engine.stats. The
+// verdict is kept only for rules the engine does not know.
+type ruleCounter struct {
+	verdict   Verdict
+	flows     uint64
+	packets   uint64
+	bytes     uint64
+	lastMatch time.Time
+}
+
+// Decide returns the verdict for a flow from local to dst without counting
+// it. App rules win over domain routes. Unmatched traffic goes direct while
+// domain rules are configured (split tunneling) and through the tunnel
+// otherwise.
+func (e *Engine) Decide(proto uint8, local netip.AddrPort, dst netip.Addr) Decision {
+	profile := e.Profile()
+	if name := protoName(proto); name != "" {
+		if ok, a := e.ExemptFlow(name, local); ok {
+			return Decision{Verdict: VerdictDirect, Rule: a.String(), Profile: profile}
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if r, ok := e.routes[dst.Unmap()]; ok {
+		return Decision{Verdict: VerdictTunnel, Rule: string(r.Pattern), Profile: profile}
+	}
+	return Decision{Verdict: e.defaultVerdictLocked(), Rule: DefaultRule, Profile: profile}
+}
+
+// Profile returns the label put on decisions.
+func (e *Engine) Profile() string {
+	if e.opts.Profile == "" {
+		return DefaultProfile
+	}
+	return e.opts.Profile
+}
+
+// Attribute decides a finished flow's verdict and adds it to the per-rule
+// counters. The flow producer tags its export record with the result.
+func (e *Engine) Attribute(proto uint8, local, dst netip.AddrPort, packets, bytes uint64) Decision {
+	d := e.Decide(proto, local, dst.Addr())
+	e.Count(d, packets, bytes)
+	return d
+}
+
+// Count adds one flow to the counters of d.Rule, e.g. for a verdict the
+// producer decided itself (VerdictBlock under its own rule name).
+func (e *Engine) Count(d Decision, packets, bytes uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.stats[d.Rule]
+	if c == nil {
+		c = &ruleCounter{}
+		e.stats[d.Rule] = c
+	}
+	c.verdict = d.Verdict
+	c.flows++
+	c.packets += packets
+	c.bytes += bytes
+	c.lastMatch = e.now()
+}
+
+// Stats returns counters for every configured rule, including rules that
+// never matched, in rule order: domain patterns, app rules, the default,
+// then rules only producers counted (kind "other", sorted). Counters reset
+// when a rule is removed.
+func (e *Engine) Stats() []RuleStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]RuleStats, 0, len(e.patterns)+len(e.apps)+1)
+	add := func(rule, kind string, v Verdict) {
+		s := RuleStats{Rule: rule, Kind: kind, Verdict: v}
+		if c := e.stats[rule]; c != nil {
+			s.Flows, s.Packets, s.Bytes, s.LastMatch = c.flows, c.packets, c.bytes, c.lastMatch
+		}
+		out = append(out, s)
+	}
+	for _, p := range e.patterns {
+		add(string(p), "domain", VerdictTunnel)
+	}
+	for _, a := range e.apps {
+		add(a.String(), "app", VerdictDirect)
+	}
+	add(DefaultRule, "default", e.defaultVerdictLocked())
+	seen := make(map[string]bool, len(out))
+	for _, s := range out {
+		seen[s.Rule] = true
+	}
+	var other []string
+	for rule := range e.stats {
+		if !seen[rule] {
+			other = append(other, rule)
+		}
+	}
+	slices.Sort(other)
+	for _, rule := range other {
+		add(rule, "other", e.stats[rule].verdict)
+	}
+	return out
+}
+
+// pruneStatsLocked drops counters of domain and app rules no longer
+// configured. Callers hold e.mu.
+func (e *Engine) pruneStatsLocked(old []Pattern, oldApps []AppRule) {
+	keep := map[string]bool{}
+	for _, p := range e.patterns {
+		keep[string(p)] = true
+	}
+	for _, a := range e.apps {
+		keep[a.String()] = true
+	}
+	for _, p := range old {
+		if !keep[string(p)] {
+			delete(e.stats, string(p))
+		}
+	}
+	for _, a := range oldApps {
+		if !keep[a.String()] {
+			delete(e.stats, a.String())
+		}
+	}
+}
+
+func (e *Engine) defaultVerdictLocked() Verdict {
+	if len(e.patterns) > 0 {
+		return VerdictDirect
+	}
+	return VerdictTunnel
+}
+
+func protoName(proto uint8) string {
+	switch proto {
+	case 6:
+		return "tcp"
+	case 17:
+		return "udp"
+	}
+	return ""
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package sdactivate
+
+import "net"
+
+// Listeners returns none: there is no systemd.
+func Listeners() ([]net.Listener, error) { return nil, nil }
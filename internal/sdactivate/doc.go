@@ -0,0 +1,12 @@
+// Package sdactivate receives listening sockets from systemd socket
+// activation, so a .socket unit can bind the API addresses and start the
+// agent on the first connection.
+//
+// systemd passes the sockets as file descriptors from 3 up, their count
+// in $LISTEN_FDS and the receiving pid in $LISTEN_PID; Listeners wraps
+// them as net.Listeners and clears the variables so child processes do
+// not claim them. Stream sockets only: TCP and unix. Without $LISTEN_FDS
+// for this process, or on other platforms, Listeners returns none. The
+// API serves an activated socket in place of the configured listener
+// with the same address (see api.ServerOptions.Activated).
+package sdactivate
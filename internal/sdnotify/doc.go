@@ -0,0 +1,10 @@
+// Package sdnotify implements the systemd service notification protocol,
+// so the agent can run as a Type=notify unit.
+//
+// systemd passes the socket in $NOTIFY_SOCKET; Notify sends newline
+// separated KEY=VALUE states to it as one datagram. The agent sends Ready
+// with a Status once the API listeners serve (so units ordered after it
+// start only then), Stopping when shutdown begins, and, when the unit sets
+// WatchdogSec=, Watchdog every WatchdogInterval until it exits. Without
+// $NOTIFY_SOCKET, or on other platforms, Notify does nothing.
+package sdnotify
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package sdnotify
+
+import "time"
+
+// Notify does nothing and reports false: there is no systemd.
+func Notify(state string) (bool, error) { return false, nil }
+
+// WatchdogInterval returns 0.
+func WatchdogInterval() time.Duration { return 0 }
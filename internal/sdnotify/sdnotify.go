@@ -0,0 +1,17 @@
+package sdnotify
+
+import "strconv"
+
+// States sent with Notify; several can be joined with newlines.
+const (
+	Ready    = "READY=1"    // startup finished; the API is serving
+	Stopping = "STOPPING=1" // shutdown began
+	Watchdog = "WATCHDOG=1" // keep-alive for WatchdogSec=
+)
+
+// Status is the one-line status "systemctl status" shows.
+func Status(s string) string { return "STATUS=" + s }
+
+// MainPID names the process systemd should treat as the service from now
+// on; sent by an agent handing over to its replacement.
+func MainPID(pid int) string { return "MAINPID=" + strconv.Itoa(pid) }
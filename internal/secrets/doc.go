@@ -0,0 +1,25 @@
+// Package secrets keeps SOCKS credentials in the operating system's secret
+// store, so API callers can name them instead of sending passwords.
+//
+// # Stores
+//
+// OSStore returns the platform store:
+//
+//   - macOS: the Keychain of the user running the agent, through the
+//     security tool (generic passwords under Service, account = name).
+//     Passwords are passed to security on stdin in hex, never in argv.
+//   - Linux: the Secret Service (GNOME Keyring, KWallet) through
+//     secret-tool from libsecret, with attributes service=Service and
+//     name=<name>. It needs a D-Bus session bus, which system services
+//     usually lack.
+//   - Other platforms: every call returns ErrUnsupported.
+//
+// Each entry holds a Credential (username and password) encoded as JSON.
+// Names follow ValidName, so they are safe to pass to the tools as-is.
+//
+// # Errors
+//
+// Get and Delete return ErrNotFound for unknown names. Tool failures come
+// back with the tool's output folded in; callers decide whether to show
+// them, since the output never includes the stored password.
+package secrets
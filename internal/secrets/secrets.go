@@ -0,0 +1,115 @@
+package secrets
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Service groups this program's entries in the platform store.
+const Service = "simple-packet-logger"
+
+// MaxNameLen bounds credential names.
+const MaxNameLen = 64
+
+// ErrNotFound is returned when no credential has the name.
+var ErrNotFound = errors.New("credential not found")
+
+// ErrUnsupported is returned on platforms without a secret store.
+var ErrUnsupported = errors.New("credential store not supported on this platform")
+
+// Credential is a SOCKS username and password.
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Store reads and writes named credentials.
+type Store interface {
+	Get(name string) (Credential, error)
+	Set(name string, c Credential) error
+	Delete(name string) error
+}
+
+// OSStore returns the Store for this platform.
+func OSStore() Store { return osStore{} }
+
+// Method names the platform store ("keychain", "secret-service", or
+// "unsupported").
+func Method() string { return method }
+
+// ValidName reports whether name can name a credential: 1-MaxNameLen of
+// A-Z a-z 0-9 . _ -.
+func ValidName(name string) error {
+	if name == "" || len(name) > MaxNameLen {
+		return fmt.Errorf("credential name must be 1-%d characters", MaxNameLen)
+	}
+	for _, c := range name {
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.'
+		if !ok {
+			return fmt.Errorf("credential name %q has invalid characters", name)
+		}
+	}
+	return nil
+}
+
+// encode and decode convert a Credential to and from the stored value.
+func encode(c Credential) []byte {
+	b, _ := json.Marshal(c)
+	return b
+}
+
+func decode(name string, b []byte) (Credential, error) {
+	b = []byte(strings.TrimSpace(string(b)))
+	var c Credential
+	if err := json.Unmarshal(b, &c); err == nil {
+		return c, nil
+	}
+	// security prints values with non-printable bytes in hex.
+	if raw, err := hex.DecodeString(string(b)); err == nil && json.Unmarshal(raw, &c) == nil {
+		return c, nil
+	}
+	return Credential{}, fmt.Errorf("credential %s: stored value is not a credential", name)
+}
+
+// toolError is a store tool that exited with a failure code.
+type toolError struct {
+	Cmd    string // tool and subcommand
+	Code   int
+	Stderr string
+}
+
+func (e *toolError) Error() string {
+	return fmt.Sprintf("%s: exit status %d: %s", e.Cmd, e.Code, e.Stderr)
+}
+
+// exitCode returns the exit code of a failed tool, or -1.
+func exitCode(err error) int {
+	var te *toolError
+	if errors.As(err, &te) {
+		return te.Code
+	}
+	return -1
+}
+
+// run executes a store tool with stdin and returns its stdout. A failure
+// exit becomes a *toolError carrying its stderr.
+func run(stdin string, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return out, &toolError{Cmd: name + " " + args[0], Code: exitErr.ExitCode(), Stderr: strings.TrimSpace(stderr.String())}
+	}
+	if err != nil {
+		return out, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
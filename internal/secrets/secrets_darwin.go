@@ -0,0 +1,52 @@
+//go:build darwin
+
+package secrets
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+const method = "keychain"
+
+// errItemNotFound is security's exit code for a missing item.
+const errItemNotFound = 44
+
+type osStore struct{}
+
+func (osStore) Get(name string) (Credential, error) {
+	if err := ValidName(name); err != nil {
+		return Credential{}, err
+	}
+	out, err := run("", "security", "find-generic-password", "-s", Service, "-a", name, "-w")
+	if exitCode(err) == errItemNotFound {
+		return Credential{}, ErrNotFound
+	}
+	if err != nil {
+		return Credential{}, err
+	}
+	return decode(name, out)
+}
+
+// Set runs security in interactive mode so the password arrives on stdin
+// rather than on a command line other users can read.
+func (osStore) Set(name string, c Credential) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -l %s -X %s\n",
+		Service, name, Service+"/"+name, hex.EncodeToString(encode(c)))
+	_, err := run(cmd, "security", "-i")
+	return err
+}
+
+func (osStore) Delete(name string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	_, err := run("", "security", "delete-generic-password", "-s", Service, "-a", name)
+	if exitCode(err) == errItemNotFound {
+		return ErrNotFound
+	}
+	return err
+}
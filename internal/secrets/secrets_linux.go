@@ -0,0 +1,47 @@
+//go:build linux
+
+package secrets
+
+import "errors"
+
+const method = "secret-service"
+
+type osStore struct{}
+
+// Get treats a silent failure as not found: secret-tool exits 1 both for a
+// missing item and for errors, but explains only the latter on stderr.
+func (osStore) Get(name string) (Credential, error) {
+	if err := ValidName(name); err != nil {
+		return Credential{}, err
+	}
+	out, err := run("", "secret-tool", "lookup", "service", Service, "name", name)
+	var te *toolError
+	if errors.As(err, &te) && te.Code == 1 && te.Stderr == "" {
+		return Credential{}, ErrNotFound
+	}
+	if err != nil {
+		return Credential{}, err
+	}
+	return decode(name, out)
+}
+
+// Set passes the value on stdin, which secret-tool reads when it is not a
+// terminal.
+func (osStore) Set(name string, c Credential) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	_, err := run(string(encode(c)), "secret-tool", "store",
+		"--label="+Service+"/"+name, "service", Service, "name", name)
+	return err
+}
+
+// Delete looks the name up first, since secret-tool clear succeeds whether
+// or not anything matched.
+func (s osStore) Delete(name string) error {
+	if _, err := s.Get(name); err != nil {
+		return err
+	}
+	_, err := run("", "secret-tool", "clear", "service", Service, "name", name)
+	return err
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package secrets
+
+const method = "unsupported"
+
+type osStore struct{}
+
+func (osStore) Get(string) (Credential, error) { return Credential{}, ErrUnsupported }
+func (osStore) Set(string, Credential) error   { return ErrUnsupported }
+func (osStore) Delete(string) error            { return ErrUnsupported }
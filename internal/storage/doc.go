@@ -0,0 +1,30 @@
+// Package storage defines the persistence backend used by every subsystem.
+//
+// # Overview
+//
+// Subsystems (state persistence, the event journal, shutdown reports, probe
+// history, stats) persist through the Storage interface instead of touching
+// files directly. A Storage offers two primitives:
+//
+//   - KV: small named blobs replaced atomically (state snapshot, reports).
+//   - Log: named append-only record streams (events, history) with tail
+//     reads and compaction.
+//
+// Values are opaque bytes; callers own their encoding (JSON throughout this
+// repo), so backends stay trivial and new ones (e.g., encrypted storage) only
+// implement byte movement.
+//
+// # Backends
+//
+//   - memory: process-local, nothing survives restart.
+//   - file:   one file per key (<dir>/<key>.json, atomic rename) and one
+//     line-delimited file per log (<dir>/<name>.jsonl).
+//   - sqlite: single database file; built only with -tags sqlite so the
+//     default binary stays dependency-free.
+//
+// Open selects a backend by name; backends register themselves in init.
+//
+// # Concurrency
+//
+// All implementations are safe for concurrent use.
+package storage
@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// BackendFile stores each key and log as a file in a directory.
+const BackendFile = "file"
+
+func init() {
+	Register(BackendFile, func(dir string) (Storage, error) { return NewFile(dir) })
+}
+
+// File is a directory-backed Storage: KV values live in <dir>/<key>.json and
+// logs in <dir>/<name>.jsonl (one record per line). Callers in this repo
+// store JSON, so the files stay readable with standard tools.
+type File struct {
+	dir string
+
+	mu   sync.Mutex
+	logs map[string]*fileLog
+}
+
+// NewFile creates dir (0700) if needed and returns a backend rooted there.
+func NewFile(dir string) (*File, error) {
+	if dir == "" {
+		return nil, errors.New("storage: file backend requires a directory")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &File{dir: dir, logs: map[string]*fileLog{}}, nil
+}
+
+// Dir returns the backend's root directory.
+func (f *File) Dir() string { return f.dir }
+
+func (f *File) Get(key string) ([]byte, bool, error) {
+	if err := validName(key); err != nil {
+		return nil, false, err
+	}
+	b, err := os.ReadFile(filepath.Join(f.dir, key+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+func (f *File) Put(key string, val []byte) error {
+	if err := validName(key); err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(f.dir, key+".json"), val, 0o600)
+}
+
+func (f *File) Delete(key string) error {
+	if err := validName(key); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(f.dir, key+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (f *File) Log(name string) (Log, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if l, ok := f.logs[name]; ok {
+		return l, nil
+	}
+	l := &fileLog{path: filepath.Join(f.dir, name+".jsonl")}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	f.logs[name] = l
+	return l, nil
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for _, l := range f.logs {
+		errs = append(errs, l.close())
+	}
+	f.logs = map[string]*fileLog{}
+	return errors.Join(errs...)
+}
+
+type fileLog struct {
+	mu   sync.Mutex
+	path string
+	w    *os.File
+}
+
+func (l *fileLog) open() error {
+	w, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	l.w = w
+	return nil
+}
+
+func (l *fileLog) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.w == nil {
+		return nil
+	}
+	err := l.w.Close()
+	l.w = nil
+	return err
+}
+
+func (l *fileLog) Append(rec []byte) error {
+	if bytes.IndexByte(rec, '\n') >= 0 {
+		return errors.New("storage: log record contains a newline")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.w == nil {
+		return os.ErrClosed
+	}
+	_, err := l.w.Write(append(append([]byte(nil), rec...), '\n'))
+	return err
+}
+
+func (l *fileLog) Tail(n int) ([][]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return readTail(l.path, n)
+}
+
+func (l *fileLog) Compact(keep int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recs, err := readTail(l.path, keep)
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	for _, r := range recs {
+		buf = append(append(buf, r...), '\n')
+	}
+	if l.w != nil {
+		_ = l.w.Close()
+		l.w = nil
+	}
+	if err := WriteFileAtomic(l.path, buf, 0o600); err != nil {
+		return err
+	}
+	return l.open()
+}
+
+// readTail returns the last n non-empty lines of path (all if n <= 0).
+// A torn final line left by a crash is kept as-is; decoders skip it.
+func readTail(path string, n int) ([][]byte, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ring [][]byte
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		ring = append(ring, append([]byte(nil), sc.Bytes()...))
+		if n > 0 && len(ring) > n {
+			ring = ring[1:]
+		}
+	}
+	return ring, sc.Err()
+}
+
+// WriteFileAtomic writes data to a temp file in the target directory and
+// renames it over path, so readers never observe a partial file. The parent
+// directory is created (0700) if missing.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,93 @@
+package storage
+
+import "sync"
+
+// BackendMemory keeps everything in process memory.
+const BackendMemory = "memory"
+
+func init() {
+	Register(BackendMemory, func(string) (Storage, error) { return NewMemory(), nil })
+}
+
+// Memory is a process-local Storage; nothing survives restart.
+type Memory struct {
+	mu   sync.Mutex
+	kv   map[string][]byte
+	logs map[string]*memLog
+}
+
+// NewMemory returns an empty in-memory backend.
+func NewMemory() *Memory {
+	return &Memory{kv: map[string][]byte{}, logs: map[string]*memLog{}}
+}
+
+func (m *Memory) Get(key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.kv[key]
+	return append([]byte(nil), v...), ok, nil
+}
+
+func (m *Memory) Put(key string, val []byte) error {
+	if err := validName(key); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kv[key] = append([]byte(nil), val...)
+	return nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.kv, key)
+	return nil
+}
+
+func (m *Memory) Log(name string) (Log, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.logs[name]
+	if !ok {
+		l = &memLog{}
+		m.logs[name] = l
+	}
+	return l, nil
+}
+
+func (m *Memory) Close() error { return nil }
+
+type memLog struct {
+	mu   sync.Mutex
+	recs [][]byte
+}
+
+func (l *memLog) Append(rec []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recs = append(l.recs, append([]byte(nil), rec...))
+	return nil
+}
+
+func (l *memLog) Tail(n int) ([][]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start := 0
+	if n > 0 && len(l.recs) > n {
+		start = len(l.recs) - n
+	}
+	return append([][]byte(nil), l.recs[start:]...), nil
+}
+
+func (l *memLog) Compact(keep int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if keep >= 0 && len(l.recs) > keep {
+		l.recs = append([][]byte(nil), l.recs[len(l.recs)-keep:]...)
+	}
+	return nil
+}
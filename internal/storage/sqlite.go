@@ -0,0 +1,145 @@
+//go:build sqlite
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	_ "modernc.org/sqlite" // pure-Go driver registered as "sqlite"
+)
+
+// BackendSQLite stores KV values and logs in a single SQLite database.
+const BackendSQLite = "sqlite"
+
+func init() {
+	Register(BackendSQLite, func(path string) (Storage, error) { return NewSQLite(path) })
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS kv (
+	key   TEXT PRIMARY KEY,
+	value BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS log (
+	name TEXT    NOT NULL,
+	seq  INTEGER PRIMARY KEY AUTOINCREMENT,
+	data BLOB    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS log_name_seq ON log (name, seq);
+`
+
+// SQLite is a database-backed Storage.
+type SQLite struct {
+	db *sql.DB
+
+	mu   sync.Mutex
+	logs map[string]*sqliteLog
+}
+
+// NewSQLite opens (creating if needed) the database at path.
+func NewSQLite(path string) (*SQLite, error) {
+	if path == "" {
+		return nil, errors.New("storage: sqlite backend requires a database path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// A single connection serializes writers and avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	_ = os.Chmod(path, 0o600)
+	return &SQLite{db: db, logs: map[string]*sqliteLog{}}, nil
+}
+
+func (s *SQLite) Get(key string) ([]byte, bool, error) {
+	var v []byte
+	err := s.db.QueryRow(`SELECT value FROM kv WHERE key = ?`, key).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+func (s *SQLite) Put(key string, val []byte) error {
+	if err := validName(key); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`INSERT INTO kv (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, val)
+	return err
+}
+
+func (s *SQLite) Delete(key string) error {
+	_, err := s.db.Exec(`DELETE FROM kv WHERE key = ?`, key)
+	return err
+}
+
+func (s *SQLite) Log(name string) (Log, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.logs[name]
+	if !ok {
+		l = &sqliteLog{db: s.db, name: name}
+		s.logs[name] = l
+	}
+	return l, nil
+}
+
+func (s *SQLite) Close() error { return s.db.Close() }
+
+type sqliteLog struct {
+	db   *sql.DB
+	name string
+}
+
+func (l *sqliteLog) Append(rec []byte) error {
+	_, err := l.db.Exec(`INSERT INTO log (name, data) VALUES (?, ?)`, l.name, rec)
+	return err
+}
+
+func (l *sqliteLog) Tail(n int) ([][]byte, error) {
+	limit := -1 // SQLite: negative LIMIT means no limit
+	if n > 0 {
+		limit = n
+	}
+	rows, err := l.db.Query(`SELECT data FROM (
+		SELECT seq, data FROM log WHERE name = ? ORDER BY seq DESC LIMIT ?
+	) ORDER BY seq ASC`, l.name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][]byte
+	for rows.Next() {
+		var b []byte
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+func (l *sqliteLog) Compact(keep int) error {
+	_, err := l.db.Exec(`DELETE FROM log WHERE name = ? AND seq NOT IN (
+		SELECT seq FROM log WHERE name = ? ORDER BY seq DESC LIMIT ?
+	)`, l.name, l.name, keep)
+	return err
+}
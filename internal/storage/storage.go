@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// KV stores small named values, each replaced atomically.
+type KV interface {
+	// Get returns the value for key; ok is false if it does not exist.
+	Get(key string) (val []byte, ok bool, err error)
+	// Put replaces the value for key.
+	Put(key string, val []byte) error
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+// Log is a named append-only record stream.
+type Log interface {
+	// Append adds one record. Records must not contain newlines for
+	// backends that store them line-delimited; JSON encodings satisfy this.
+	Append(rec []byte) error
+	// Tail returns up to n most recent records, oldest first (n <= 0: all).
+	Tail(n int) ([][]byte, error)
+	// Compact discards all but the newest keep records.
+	Compact(keep int) error
+}
+
+// Storage is a persistence backend.
+type Storage interface {
+	KV
+	// Log opens (creating if needed) the named log. Repeated calls with the
+	// same name return logs backed by the same data.
+	Log(name string) (Log, error)
+	// Close releases backend resources.
+	Close() error
+}
+
+// Opener constructs a backend rooted at location (a directory for file, a
+// database path for sqlite; ignored by memory).
+type Opener func(location string) (Storage, error)
+
+var (
+	regMu    sync.RWMutex
+	backends = map[string]Opener{}
+)
+
+// Register makes a backend available to Open. It panics on duplicates, as
+// registration happens in init.
+func Register(name string, open Opener) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	if _, dup := backends[name]; dup {
+		panic("storage: duplicate backend " + name)
+	}
+	backends[name] = open
+}
+
+// Backends returns the registered backend names, sorted.
+func Backends() []string {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for n := range backends {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open constructs the named backend.
+func Open(backend, location string) (Storage, error) {
+	regMu.RLock()
+	open, ok := backends[backend]
+	regMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q (available: %s)", backend, strings.Join(Backends(), ", "))
+	}
+	return open(location)
+}
+
+// validName restricts keys and log names to a portable file-name subset.
+func validName(name string) error {
+	if name == "" || len(name) > 128 {
+		return fmt.Errorf("storage: invalid name %q", name)
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return fmt.Errorf("storage: invalid character %q in name %q", r, name)
+		}
+	}
+	if strings.HasPrefix(name, ".") {
+		return fmt.Errorf("storage: name %q must not start with a dot", name)
+	}
+	return nil
+}
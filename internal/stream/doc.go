@@ -0,0 +1,22 @@
+// Package stream fans events and flow records out to streaming API clients.
+//
+// # Hub
+//
+// Hub holds one buffer per subscriber. Run feeds it core.State events and
+// flow producers call Publish alongside export.WriteAll, so every
+// subscriber sees one ordered sequence of export.Records.
+//
+// # Backpressure
+//
+// Publish never blocks. Each subscriber has a bounded ring buffer; when a
+// slow subscriber's buffer is full the oldest record is dropped and the
+// subscriber's Dropped counter grows. A stalled client therefore costs at
+// most its buffer in memory and never delays the others.
+//
+// # Filters
+//
+// Filtering happens before buffering, so records a client did not ask for
+// never take buffer space. A Filter selects events by type and minimum
+// severity (core.Event.Severity) and flows by protocol, address prefix,
+// port, verdict, and rule.
+package stream
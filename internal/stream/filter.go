@@ -0,0 +1,60 @@
+package stream
+
+import (
+	"net/netip"
+	"slices"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/export"
+)
+
+// Filter selects the records a subscriber receives.
+type Filter struct {
+	Events      bool             // deliver events
+	Types       []core.EventType // event types to deliver; all when empty
+	MinSeverity core.Severity    // least severe event to deliver
+	Flows       bool             // deliver flow records
+	Flow        FlowFilter
+}
+
+// FlowFilter narrows flow records; zero fields match everything.
+type FlowFilter struct {
+	Proto   uint8        // IP protocol number (6 TCP, 17 UDP)
+	Prefix  netip.Prefix // source or destination address inside
+	Port    uint16       // source or destination port
+	Verdict string       // tunnel, direct, or block
+	Rule    string       // deciding rule, as in rules.Decision
+}
+
+// Match reports whether rec passes f.
+func (f Filter) Match(rec export.Record) bool {
+	switch rec.Kind {
+	case export.KindEvent:
+		if !f.Events || rec.Event == nil {
+			return false
+		}
+		if len(f.Types) > 0 && !slices.Contains(f.Types, rec.Event.Type) {
+			return false
+		}
+		return rec.Event.Severity() >= f.MinSeverity
+	case export.KindFlow:
+		return f.Flows && rec.Flow != nil && f.Flow.match(rec.Flow)
+	}
+	return false
+}
+
+func (f FlowFilter) match(fl *export.Flow) bool {
+	if f.Proto != 0 && fl.Proto != f.Proto {
+		return false
+	}
+	if f.Prefix.IsValid() && !f.Prefix.Contains(fl.Src.Addr().Unmap()) && !f.Prefix.Contains(fl.Dst.Addr().Unmap()) {
+		return false
+	}
+	if f.Port != 0 && fl.Src.Port() != f.Port && fl.Dst.Port() != f.Port {
+		return false
+	}
+	if f.Verdict != "" && fl.Verdict != f.Verdict {
+		return false
+	}
+	return f.Rule == "" || fl.Rule == f.Rule
+}
@@ -0,0 +1,160 @@
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/export"
+)
+
+// Subscriber buffer bounds, in records.
+const (
+	DefaultBuffer = 256
+	MinBuffer     = 16
+	MaxBuffer     = 4096
+)
+
+// Hub fans records out to subscribers. The zero value is not usable; call
+// NewHub.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+	lost uint64 // events Run missed because core's buffer overflowed
+}
+
+// Stats summarizes the hub for metrics and status.
+type Stats struct {
+	Subscribers int
+	Dropped     uint64 // records dropped from current subscribers' buffers
+	Lost        uint64 // events the hub itself missed
+}
+
+// NewHub constructs a hub with no subscribers.
+func NewHub() *Hub {
+	return &Hub{subs: map[*Subscription]struct{}{}}
+}
+
+// Run publishes every new event in state until ctx is done. It blocks; run
+// it in a goroutine.
+func (h *Hub) Run(ctx context.Context, state *core.State) {
+	var seen uint64
+	for ev := range state.Subscribe(ctx) {
+		if ev.Dropped > seen {
+			h.mu.Lock()
+			h.lost += ev.Dropped - seen
+			h.mu.Unlock()
+			seen = ev.Dropped
+		}
+		e := ev.Event
+		h.Publish(export.Record{Kind: export.KindEvent, Event: &e})
+	}
+}
+
+// Publish delivers rec to every subscriber whose filter matches. It never
+// blocks: a full buffer drops its oldest record.
+func (h *Hub) Publish(rec export.Record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if sub.filter.Match(rec) {
+			sub.push(rec)
+		}
+	}
+}
+
+// Subscribe registers a subscriber with filter f and a buffer of buffer
+// records (DefaultBuffer if <= 0, clamped to MinBuffer..MaxBuffer). It is
+// removed when ctx is done.
+func (h *Hub) Subscribe(ctx context.Context, f Filter, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	buffer = min(max(buffer, MinBuffer), MaxBuffer)
+	sub := &Subscription{
+		filter: f,
+		buf:    make([]export.Record, buffer),
+		ready:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(h.subs, sub)
+		h.mu.Unlock()
+		close(sub.done)
+	}()
+	return sub
+}
+
+// Stats returns current counters.
+func (h *Hub) Stats() Stats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st := Stats{Subscribers: len(h.subs), Lost: h.lost}
+	for sub := range h.subs {
+		st.Dropped += sub.Dropped()
+	}
+	return st
+}
+
+// Subscription is one subscriber's ring buffer.
+type Subscription struct {
+	filter Filter
+	ready  chan struct{} // signaled when records are buffered
+	done   chan struct{} // closed when unsubscribed
+
+	mu      sync.Mutex
+	buf     []export.Record
+	head    int // index of the oldest record
+	n       int // records buffered
+	dropped uint64
+}
+
+// Ready is signaled when records are waiting to be drained.
+func (s *Subscription) Ready() <-chan struct{} { return s.ready }
+
+// Done is closed once the subscription's context ends.
+func (s *Subscription) Done() <-chan struct{} { return s.done }
+
+// Drain removes and returns the buffered records, oldest first, with the
+// total number dropped so far.
+func (s *Subscription) Drain() ([]export.Record, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]export.Record, s.n)
+	for i := range out {
+		j := (s.head + i) % len(s.buf)
+		out[i] = s.buf[j]
+		s.buf[j] = export.Record{}
+	}
+	s.head, s.n = 0, 0
+	return out, s.dropped
+}
+
+// Dropped returns how many records were discarded because the buffer was
+// full.
+func (s *Subscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+func (s *Subscription) push(rec export.Record) {
+	s.mu.Lock()
+	if s.n == len(s.buf) {
+		s.head = (s.head + 1) % len(s.buf)
+		s.n--
+		s.dropped++
+	}
+	s.buf[(s.head+s.n)%len(s.buf)] = rec
+	s.n++
+	s.mu.Unlock()
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
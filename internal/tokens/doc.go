@@ -0,0 +1,26 @@
+// Package tokens manages named API tokens minted at runtime.
+//
+// # Tokens
+//
+// A token has a name, a scope (the API's "admin", "operate", or "read"), a
+// creation time, and an expiry. Mint generates a random 256-bit secret,
+// returns it once, and keeps only its SHA-256 digest; the secret cannot be
+// recovered later. Authenticate hashes a presented secret and compares digests in
+// constant time, rejecting expired tokens. Revoke deletes a token by name.
+//
+// Secrets have full entropy, so a fast hash suffices: there is nothing to
+// brute-force that is cheaper than guessing the secret itself.
+//
+// # Configured Tokens
+//
+// Configure adds tokens defined in the config file by name, scope, and
+// digest (NewSecret and Digest produce a pair). They authenticate like
+// minted tokens but never expire, are not persisted, and cannot be revoked;
+// HasConfigured tells the API that every listener must require a token.
+//
+// # Persistence
+//
+// With a storage.KV, every change rewrites the token list (names, scopes,
+// times, digests) under TokensKey; the backend's file modes keep it private.
+// Expired tokens are dropped on load and on each change.
+package tokens
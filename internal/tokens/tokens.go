@@ -0,0 +1,288 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/storage"
+)
+
+// Limits and defaults.
+const (
+	MaxTokens  = 64
+	MaxNameLen = 64
+	DefaultTTL = 30 * 24 * time.Hour
+	MaxTTL     = 365 * 24 * time.Hour
+)
+
+// TokensKey is the storage key holding the persisted token list.
+const TokensKey = "tokens"
+
+// SecretPrefix marks minted secrets so they are recognizable in configs
+// and secret scanners.
+const SecretPrefix = "spl_"
+
+// Errors returned by Store.
+var (
+	ErrExists   = errors.New("token name already exists")
+	ErrNotFound = errors.New("token not found")
+	ErrFull     = fmt.Errorf("too many tokens (max %d)", MaxTokens)
+	// ErrConfigured is returned when revoking a token from the config file.
+	ErrConfigured = errors.New("token is defined in the config file")
+)
+
+// Token is a minted token's metadata. The secret itself is never stored.
+type Token struct {
+	Name      string    `json:"name"`
+	Scope     string    `json:"scope"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Digest    string    `json:"digest"` // hex SHA-256 of the secret
+
+	// Configured marks tokens from the config file (see Configure); they
+	// have zero CreatedAt and ExpiresAt and are never persisted.
+	Configured bool `json:"-"`
+}
+
+// Store holds minted tokens, optionally persisted in a KV.
+type Store struct {
+	kv  storage.KV
+	now func() time.Time
+
+	mu         sync.Mutex
+	tokens     map[string]Token // minted
+	configured map[string]Token // from the config file, by name
+}
+
+// Open loads tokens from kv (nil keeps them in memory only). Expired tokens
+// are dropped.
+func Open(kv storage.KV) (*Store, error) {
+	s := &Store{kv: kv, now: time.Now, tokens: map[string]Token{}, configured: map[string]Token{}}
+	if kv == nil {
+		return s, nil
+	}
+	b, ok, err := kv.Get(TokensKey)
+	if err != nil || !ok {
+		return s, err
+	}
+	var list []Token
+	if err := json.Unmarshal(b, &list); err != nil {
+		return s, fmt.Errorf("decode tokens: %w", err)
+	}
+	now := s.now()
+	for _, t := range list {
+		if now.Before(t.ExpiresAt) {
+			s.tokens[t.Name] = t
+		}
+	}
+	return s, nil
+}
+
+// ValidName reports whether name is 1-MaxNameLen characters of letters,
+// digits, '-', '_', or '.'.
+func ValidName(name string) error {
+	if name == "" || len(name) > MaxNameLen {
+		return fmt.Errorf("token name must be 1-%d characters", MaxNameLen)
+	}
+	for _, c := range name {
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.'
+		if !ok {
+			return fmt.Errorf("token name %q has invalid characters", name)
+		}
+	}
+	return nil
+}
+
+// Configure replaces the tokens defined in the config file. Each needs a
+// valid name and Digest (hex SHA-256 of its secret, see Digest); other
+// times and Configured are set here. Configured tokens never expire, are
+// not persisted, and cannot be revoked; minted tokens may not reuse their
+// names.
+func (s *Store) Configure(list []Token) error {
+	next := make(map[string]Token, len(list))
+	for _, t := range list {
+		if err := ValidName(t.Name); err != nil {
+			return err
+		}
+		if _, dup := next[t.Name]; dup {
+			return fmt.Errorf("token %q: %w", t.Name, ErrExists)
+		}
+		if b, err := hex.DecodeString(t.Digest); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("token %q: digest must be %d hex characters (SHA-256 of the secret)", t.Name, 2*sha256.Size)
+		}
+		next[t.Name] = Token{Name: t.Name, Scope: t.Scope, Digest: strings.ToLower(t.Digest), Configured: true}
+	}
+	if len(next) > MaxTokens {
+		return ErrFull
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range next {
+		if _, ok := s.tokens[name]; ok {
+			return fmt.Errorf("token %q: %w (minted)", name, ErrExists)
+		}
+	}
+	s.configured = next
+	return nil
+}
+
+// HasConfigured reports whether the config file defines any tokens.
+func (s *Store) HasConfigured() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.configured) > 0
+}
+
+// NewSecret returns a random secret in the format Mint hands out.
+func NewSecret() (string, error) {
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", err
+	}
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(raw[:]), nil
+}
+
+// Mint creates a token and returns its secret, which is not retrievable
+// afterwards. ttl must be in (0, MaxTTL]; scope is stored as given.
+func (s *Store) Mint(name, scope string, ttl time.Duration) (string, Token, error) {
+	if err := ValidName(name); err != nil {
+		return "", Token{}, err
+	}
+	if ttl <= 0 || ttl > MaxTTL {
+		return "", Token{}, fmt.Errorf("ttl must be between 1s and %s", MaxTTL)
+	}
+	secret, err := NewSecret()
+	if err != nil {
+		return "", Token{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.pruneLocked(now)
+	if _, ok := s.tokens[name]; ok {
+		return "", Token{}, ErrExists
+	}
+	if _, ok := s.configured[name]; ok {
+		return "", Token{}, ErrExists
+	}
+	if len(s.tokens) >= MaxTokens {
+		return "", Token{}, ErrFull
+	}
+	t := Token{
+		Name:      name,
+		Scope:     scope,
+		CreatedAt: now.UTC().Truncate(time.Second),
+		ExpiresAt: now.Add(ttl).UTC().Truncate(time.Second),
+		Digest:    Digest(secret),
+	}
+	s.tokens[name] = t
+	if err := s.saveLocked(); err != nil {
+		delete(s.tokens, name)
+		return "", Token{}, fmt.Errorf("persist tokens: %w", err)
+	}
+	return secret, t, nil
+}
+
+// Revoke deletes the named minted token.
+func (s *Store) Revoke(name string) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.configured[name]; ok {
+		return Token{}, ErrConfigured
+	}
+	t, ok := s.tokens[name]
+	if !ok {
+		return Token{}, ErrNotFound
+	}
+	delete(s.tokens, name)
+	s.pruneLocked(s.now())
+	if err := s.saveLocked(); err != nil {
+		s.tokens[name] = t
+		return Token{}, fmt.Errorf("persist tokens: %w", err)
+	}
+	return t, nil
+}
+
+// List returns configured and unexpired minted tokens sorted by name.
+func (s *Store) List() []Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	out := make([]Token, 0, len(s.tokens)+len(s.configured))
+	for _, t := range s.tokens {
+		if now.Before(t.ExpiresAt) {
+			out = append(out, t)
+		}
+	}
+	for _, t := range s.configured {
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Authenticate returns the configured or unexpired minted token whose
+// secret is secret.
+func (s *Store) Authenticate(secret string) (Token, bool) {
+	d := []byte(Digest(secret))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var found Token
+	ok := false
+	for _, t := range s.tokens {
+		// Compare against every entry so timing does not depend on position.
+		if subtle.ConstantTimeCompare(d, []byte(t.Digest)) == 1 && now.Before(t.ExpiresAt) {
+			found, ok = t, true
+		}
+	}
+	for _, t := range s.configured {
+		if subtle.ConstantTimeCompare(d, []byte(t.Digest)) == 1 {
+			found, ok = t, true
+		}
+	}
+	return found, ok
+}
+
+// pruneLocked drops expired tokens from memory; the next save persists it.
+func (s *Store) pruneLocked(now time.Time) {
+	for name, t := range s.tokens {
+		if !now.Before(t.ExpiresAt) {
+			delete(s.tokens, name)
+		}
+	}
+}
+
+func (s *Store) saveLocked() error {
+	if s.kv == nil {
+		return nil
+	}
+	list := make([]Token, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	b, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return s.kv.Put(TokensKey, b)
+}
+
+// Digest returns the hex SHA-256 of secret, as stored for every token.
+func Digest(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
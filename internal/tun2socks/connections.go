@@ -0,0 +1,147 @@
+package tun2socks
+
+import (
+	"context"
+	"io"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// DefaultConnectionsInterval is how often RunConnections reports.
+const DefaultConnectionsInterval = time.Second
+
+// ConnectionReporter is optionally implemented by engines that relay
+// flows themselves and can list them. External processes and the
+// WireGuard engine carry packets without seeing connections.
+type ConnectionReporter interface {
+	// Connections returns the flows being relayed, in no particular
+	// order; nil when the engine is stopped.
+	Connections() []core.Connection
+}
+
+// RunConnections reports the connections of every engine that implements
+// ConnectionReporter to state.UpdateConnections each interval, until ctx
+// is canceled. Nil engines are skipped.
+func RunConnections(ctx context.Context, state *core.State, interval time.Duration, engines ...Engine) {
+	if interval <= 0 {
+		interval = DefaultConnectionsInterval
+	}
+	var reporters []ConnectionReporter
+	for _, e := range engines {
+		if r, ok := e.(ConnectionReporter); ok {
+			reporters = append(reporters, r)
+		}
+	}
+	if len(reporters) == 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		var conns []core.Connection
+		for _, r := range reporters {
+			conns = append(conns, r.Connections()...)
+		}
+		state.UpdateConnections(core.ConnectionsSnapshot{UpdatedAt: time.Now(), Connections: conns})
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// connIDs numbers connections across engines and runs.
+var connIDs atomic.Uint64
+
+// connTracker is the set of flows an in-process engine is relaying.
+type connTracker struct {
+	engine string
+
+	mu    sync.Mutex
+	conns map[uint64]*trackedConn
+}
+
+// trackedConn is one flow in a connTracker. Relays count bytes with
+// countWriter as they copy.
+type trackedConn struct {
+	t         *connTracker
+	id        uint64
+	proto     string
+	src, dst  netip.AddrPort
+	startedAt time.Time
+	state     string // guarded by t.mu
+
+	up, down atomic.Uint64
+}
+
+// add registers a new flow in state ConnConnecting.
+func (t *connTracker) add(proto string, src, dst netip.AddrPort) *trackedConn {
+	c := &trackedConn{
+		t:         t,
+		id:        connIDs.Add(1),
+		proto:     proto,
+		src:       src,
+		dst:       dst,
+		startedAt: time.Now(),
+		state:     core.ConnConnecting,
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns == nil {
+		t.conns = make(map[uint64]*trackedConn)
+	}
+	t.conns[c.id] = c
+	return c
+}
+
+// list returns a core.Connection per flow.
+func (t *connTracker) list() []core.Connection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]core.Connection, 0, len(t.conns))
+	for _, c := range t.conns {
+		out = append(out, core.Connection{
+			ID:        c.id,
+			Engine:    t.engine,
+			Proto:     c.proto,
+			Src:       c.src,
+			Dst:       c.dst,
+			State:     c.state,
+			BytesUp:   c.up.Load(),
+			BytesDown: c.down.Load(),
+			StartedAt: c.startedAt,
+		})
+	}
+	return out
+}
+
+// setState moves c to state.
+func (c *trackedConn) setState(state string) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+	c.state = state
+}
+
+// done removes c from its tracker.
+func (c *trackedConn) done() {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+	delete(c.t.conns, c.id)
+}
+
+// countWriter adds the bytes written through it to n.
+type countWriter struct {
+	w io.Writer
+	n *atomic.Uint64
+}
+
+func (cw countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n.Add(uint64(n))
+	return n, err
+}
@@ -0,0 +1,60 @@
+package tun2socks
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// EngineEmbedded names the in-process engine; start requests select it
+// with engine "embedded".
+const EngineEmbedded = "embedded"
+
+// ErrEmbeddedUnavailable is returned by NewEmbedded when the agent was
+// built without the netstack tag or for a platform other than Linux.
+var ErrEmbeddedUnavailable = errors.New("tun2socks: embedded engine not built in (build with -tags netstack on linux)")
+
+// Defaults for EmbeddedOptions.
+const (
+	DefaultEmbeddedDialTimeout = 10 * time.Second
+	DefaultEmbeddedUDPIdle     = time.Minute
+)
+
+// EmbeddedOptions configures the embedded engine.
+type EmbeddedOptions struct {
+	// DialTimeout bounds each SOCKS5 CONNECT or UDP ASSOCIATE, or HTTP
+	// CONNECT.
+	DialTimeout time.Duration
+	// UDPIdle closes a UDP flow with no traffic in either direction.
+	UDPIdle time.Duration
+	// Logs receives one line per failed flow, as a child's stderr would.
+	Logs *LogBuffer
+	// Logger receives start and stop records; nil discards them.
+	Logger *slog.Logger
+}
+
+// newEmbedded is set by the netstack build.
+var newEmbedded func(EmbeddedOptions) Engine
+
+// EmbeddedAvailable reports whether this build includes the embedded
+// engine.
+func EmbeddedAvailable() bool { return newEmbedded != nil }
+
+// NewEmbedded returns a stopped engine that runs a user-space TCP/IP stack
+// (gVisor netstack) on the TUN device inside the agent and relays its TCP
+// and UDP flows to the SOCKS5 proxy, so no tun2socks binary is needed.
+func NewEmbedded(opts EmbeddedOptions) (Engine, error) {
+	if newEmbedded == nil {
+		return nil, ErrEmbeddedUnavailable
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultEmbeddedDialTimeout
+	}
+	if opts.UDPIdle <= 0 {
+		opts.UDPIdle = DefaultEmbeddedUDPIdle
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.DiscardHandler)
+	}
+	return newEmbedded(opts), nil
+}
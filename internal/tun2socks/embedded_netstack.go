@@ -0,0 +1,476 @@
+//go:build netstack && linux
+
+package tun2socks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/adapters/gonet"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/fdbased"
+	"gvisor.dev/gvisor/pkg/tcpip/link/tun"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/tcp"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/udp"
+	"gvisor.dev/gvisor/pkg/waiter"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+func init() {
+	newEmbedded = func(opts EmbeddedOptions) Engine {
+		return &netstackEngine{
+			opts:  opts,
+			stats: Stats{Engine: EngineEmbedded},
+			conns: connTracker{engine: EngineEmbedded},
+		}
+	}
+}
+
+// Netstack parameters.
+const (
+	netstackNIC         tcpip.NICID = 1
+	netstackDefaultMTU              = 1500
+	netstackMaxInFlight             = 1024 // pending TCP handshakes
+	netstackUDPBuffer               = 64 << 10
+)
+
+// netstackEngine is the embedded Engine: a gVisor stack attached to the
+// TUN device whose TCP and UDP forwarders relay each flow through SOCKS5
+// (TCP only through an HTTP proxy).
+type netstackEngine struct {
+	opts EmbeddedOptions
+
+	mu       sync.Mutex
+	st       *stack.Stack
+	fd       int
+	cfg      Config
+	socks    *socksClient
+	ctx      context.Context // canceled by Stop; ends relays
+	cancel   context.CancelFunc
+	flows    sync.WaitGroup
+	stopping bool
+	stats    Stats
+	conns    connTracker
+
+	tcpFlows, udpFlows, failed atomic.Uint64
+}
+
+func (e *netstackEngine) Name() string { return EngineEmbedded }
+
+// Start opens the TUN device and attaches the stack to it. The device is
+// created if it does not exist; orchestration still assigns its
+// addresses and routes.
+func (e *netstackEngine) Start(ctx context.Context, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.WireGuard != nil {
+		return errors.New("tun2socks: embedded engine relays to SOCKS5 and HTTP proxies only, not wireguard")
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.st != nil {
+		return ErrRunning
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	mtu := cfg.MTU
+	if mtu == 0 {
+		mtu = netstackDefaultMTU
+	}
+	fd, err := tun.Open(cfg.Device)
+	if err != nil {
+		return fmt.Errorf("tun2socks: open %s: %w", cfg.Device, err)
+	}
+	st, err := e.newStack(fd, uint32(mtu), cfg.UDP && !cfg.UDPUnsupported())
+	if err != nil {
+		unix.Close(fd)
+		return err
+	}
+
+	sc := &socksClient{cfg: cfg}
+	if cfg.Interface != "" {
+		dev := cfg.Interface
+		sc.dialer.Dialer.Control = func(_, _ string, c syscall.RawConn) error {
+			var serr error
+			if err := c.Control(func(fd uintptr) {
+				serr = unix.BindToDevice(int(fd), dev)
+			}); err != nil {
+				return err
+			}
+			return serr
+		}
+	}
+	e.st, e.fd, e.cfg, e.socks, e.stopping = st, fd, cfg, sc, false
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.stats.PID = os.Getpid()
+	e.stats.StartedAt = time.Now()
+	e.stats.Starts++
+	if b := e.opts.Logs; b != nil {
+		b.Reset()
+	}
+	e.opts.Logger.Info("tun2socks started", "engine", EngineEmbedded, "device", cfg.Device, "mtu", mtu, "udp", cfg.UDP)
+	return nil
+}
+
+// newStack builds a stack that accepts every address on the TUN
+// (promiscuous, spoofing) and hands new flows to the forwarders.
+func (e *netstackEngine) newStack(fd int, mtu uint32, withUDP bool) (*stack.Stack, error) {
+	ep, err := fdbased.New(&fdbased.Options{
+		FDs: []int{fd},
+		MTU: mtu,
+		ClosedFunc: func(err tcpip.Error) {
+			e.exited(errors.New("tun device closed: " + err.String()))
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tun2socks: link endpoint: %w", err)
+	}
+	st := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{ipv4.NewProtocol, ipv6.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{tcp.NewProtocol, udp.NewProtocol},
+	})
+	steps := []func() tcpip.Error{
+		func() tcpip.Error { return st.CreateNIC(netstackNIC, ep) },
+		func() tcpip.Error { return st.SetPromiscuousMode(netstackNIC, true) },
+		func() tcpip.Error { return st.SetSpoofing(netstackNIC, true) },
+	}
+	for _, step := range steps {
+		if terr := step(); terr != nil {
+			st.Close()
+			st.Wait()
+			return nil, fmt.Errorf("tun2socks: netstack: %s", terr)
+		}
+	}
+	st.SetRouteTable([]tcpip.Route{
+		{Destination: header.IPv4EmptySubnet, NIC: netstackNIC},
+		{Destination: header.IPv6EmptySubnet, NIC: netstackNIC},
+	})
+	st.SetTransportProtocolHandler(tcp.ProtocolNumber, tcp.NewForwarder(st, 0, netstackMaxInFlight, e.handleTCP).HandlePacket)
+	if withUDP {
+		st.SetTransportProtocolHandler(udp.ProtocolNumber, udp.NewForwarder(st, e.handleUDP).HandlePacket)
+	}
+	return st, nil
+}
+
+// handleTCP runs on its own goroutine per SYN. The handshake with the
+// local client completes only after the proxy accepted the CONNECT, so a
+// refused destination is seen as a reset rather than an open-then-close.
+func (e *netstackEngine) handleTCP(r *tcp.ForwarderRequest) {
+	ctx, socks, ok := e.flowStart()
+	if !ok {
+		r.Complete(true)
+		return
+	}
+	defer e.flows.Done()
+	dst := endpointAddr(r.ID().LocalAddress, r.ID().LocalPort)
+	c := e.conns.add("tcp", endpointAddr(r.ID().RemoteAddress, r.ID().RemotePort), dst)
+	defer c.done()
+	dctx, cancel := context.WithTimeout(ctx, e.opts.DialTimeout)
+	up, err := socks.connect(dctx, dst)
+	cancel()
+	if err != nil {
+		e.flowFailed("tcp", dst, err)
+		r.Complete(true)
+		return
+	}
+	var wq waiter.Queue
+	ep, terr := r.CreateEndpoint(&wq)
+	if terr != nil {
+		up.Close()
+		r.Complete(true)
+		return
+	}
+	r.Complete(false)
+	e.tcpFlows.Add(1)
+	c.setState(core.ConnEstablished)
+	relayTCP(ctx, gonet.NewTCPConn(&wq, ep), up, c)
+}
+
+// handleUDP is called on the stack's dispatch goroutine, so the relay
+// runs on its own. Each local 4-tuple is one flow and one association.
+func (e *netstackEngine) handleUDP(r *udp.ForwarderRequest) bool {
+	ctx, socks, ok := e.flowStart()
+	if !ok {
+		return true
+	}
+	var wq waiter.Queue
+	ep, terr := r.CreateEndpoint(&wq)
+	if terr != nil {
+		e.flows.Done()
+		return true
+	}
+	dst := endpointAddr(r.ID().LocalAddress, r.ID().LocalPort)
+	local := gonet.NewUDPConn(&wq, ep)
+	c := e.conns.add("udp", endpointAddr(r.ID().RemoteAddress, r.ID().RemotePort), dst)
+	go func() {
+		defer e.flows.Done()
+		defer c.done()
+		defer local.Close()
+		if err := e.relayUDP(ctx, socks, local, dst, c); err != nil {
+			e.flowFailed("udp", dst, err)
+		}
+	}()
+	e.udpFlows.Add(1)
+	return true
+}
+
+// flowStart registers a flow unless the engine is stopping.
+func (e *netstackEngine) flowStart() (context.Context, *socksClient, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.st == nil || e.stopping {
+		return nil, nil, false
+	}
+	e.flows.Add(1)
+	return e.ctx, e.socks, true
+}
+
+// flowFailed records a flow the proxy could not carry in the output
+// buffer, where the error lines reach status like a child's stderr.
+func (e *netstackEngine) flowFailed(proto string, dst netip.AddrPort, err error) {
+	e.failed.Add(1)
+	if b := e.opts.Logs; b != nil {
+		e.mu.Lock()
+		pws := e.cfg.passwords()
+		e.mu.Unlock()
+		line := fmt.Sprintf("%s [ERROR] %s %s: %s\n", time.Now().UTC().Format(time.RFC3339), proto, dst, redact.New(pws...).String(err.Error()))
+		_, _ = b.Stderr().Write([]byte(line))
+	}
+}
+
+// relayTCP copies both ways until both directions end or ctx is canceled,
+// counting bytes in c, which is closing once either direction ends.
+func relayTCP(ctx context.Context, local *gonet.TCPConn, up net.Conn, c *trackedConn) {
+	stop := context.AfterFunc(ctx, func() {
+		local.Close()
+		up.Close()
+	})
+	defer stop()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(countWriter{up, &c.up}, local)
+		c.setState(core.ConnClosing)
+		if tc, ok := up.(*net.TCPConn); ok {
+			_ = tc.CloseWrite()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(countWriter{local, &c.down}, up)
+		c.setState(core.ConnClosing)
+		_ = local.CloseWrite()
+	}()
+	wg.Wait()
+	local.Close()
+	up.Close()
+}
+
+// relayUDP associates with the proxy and shuttles datagrams between the
+// local flow and dst until either side is idle for UDPIdle, the
+// association's control connection closes, or ctx is canceled. Payload
+// bytes are counted in c.
+func (e *netstackEngine) relayUDP(ctx context.Context, socks *socksClient, local net.Conn, dst netip.AddrPort, c *trackedConn) error {
+	dctx, cancel := context.WithTimeout(ctx, e.opts.DialTimeout)
+	ctrl, relay, err := socks.associate(dctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer ctrl.Close()
+	up, err := socks.dialer.DialContext(ctx, "udp", relay.String())
+	if err != nil {
+		return err
+	}
+	defer up.Close()
+	c.setState(core.ConnEstablished)
+
+	done := make(chan struct{})
+	var once sync.Once
+	finish := func() {
+		once.Do(func() {
+			close(done)
+			local.Close()
+			up.Close()
+			ctrl.Close()
+		})
+	}
+	defer finish()
+	stop := context.AfterFunc(ctx, finish)
+	defer stop()
+	go func() {
+		// The association ends when its TCP connection does.
+		_, _ = io.Copy(io.Discard, ctrl)
+		finish()
+	}()
+	var last atomic.Int64
+	touch := func() { last.Store(time.Now().UnixNano()) }
+	touch()
+	go func() {
+		t := time.NewTicker(e.opts.UDPIdle / 4)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if time.Since(time.Unix(0, last.Load())) >= e.opts.UDPIdle {
+					finish()
+					return
+				}
+			}
+		}
+	}()
+	go func() {
+		defer finish()
+		buf := make([]byte, netstackUDPBuffer)
+		for {
+			n, err := up.Read(buf)
+			if err != nil {
+				return
+			}
+			_, payload, err := parseSocksUDP(buf[:n])
+			if err != nil {
+				continue
+			}
+			touch()
+			if _, err := local.Write(payload); err != nil {
+				return
+			}
+			c.down.Add(uint64(len(payload)))
+		}
+	}()
+	hdr := socksUDPHeader(dst)
+	buf := make([]byte, netstackUDPBuffer)
+	for {
+		n, err := local.Read(buf[len(hdr):])
+		if err != nil {
+			return nil
+		}
+		touch()
+		copy(buf, hdr)
+		if _, err := up.Write(buf[:len(hdr)+n]); err != nil {
+			return nil
+		}
+		c.up.Add(uint64(n))
+	}
+}
+
+// exited records the TUN going away underneath a running stack.
+func (e *netstackEngine) exited(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.st == nil || e.stopping {
+		return
+	}
+	e.stats.Exits++
+	e.stats.LastExit = time.Now()
+	e.stats.LastExitErr = err.Error()
+	e.opts.Logger.Warn("tun2socks exited unexpectedly", "engine", EngineEmbedded, "err", err)
+}
+
+// Stop ends the relays and detaches the stack from the TUN. Relays still
+// running when ctx ends are abandoned; their connections are closed.
+func (e *netstackEngine) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	st, fd, cancel := e.st, e.fd, e.cancel
+	if st == nil {
+		e.mu.Unlock()
+		return nil
+	}
+	e.stopping = true
+	e.mu.Unlock()
+
+	cancel()
+	// Removing the NIC stops the dispatcher reading fd, so fd can be
+	// closed once the stack is gone.
+	_ = st.RemoveNIC(netstackNIC)
+	done := make(chan struct{})
+	go func() {
+		e.flows.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	st.Close()
+	st.Wait()
+	unix.Close(fd)
+
+	e.mu.Lock()
+	e.st, e.socks = nil, nil
+	e.stats.PID = 0
+	e.stats.StartedAt = time.Time{}
+	e.mu.Unlock()
+	e.opts.Logger.Info("tun2socks stopped", "engine", EngineEmbedded, "tcp_flows", e.tcpFlows.Load(), "udp_flows", e.udpFlows.Load(), "failed_flows", e.failed.Load())
+	return err
+}
+
+// Health reports the agent's PID, since the engine runs in-process, and
+// whether the proxy accepts TCP connections. UDP is reported healthy when
+// TCP is and the config asked for it, and unsupported through an HTTP
+// proxy.
+func (e *netstackEngine) Health(ctx context.Context) core.Tun2SocksSnapshot {
+	e.mu.Lock()
+	pid, started, cfg, socks := e.stats.PID, e.stats.StartedAt, e.cfg, e.socks
+	e.mu.Unlock()
+	if pid == 0 {
+		return core.Tun2SocksSnapshot{}
+	}
+	snap := core.Tun2SocksSnapshot{PID: pid, UptimeSec: int64(time.Since(started).Seconds())}
+	ctx, cancel := context.WithTimeout(ctx, healthDialTimeout)
+	defer cancel()
+	if conn, err := socks.dialer.DialContext(ctx, "tcp", cfg.Proxy); err == nil {
+		conn.Close()
+		snap.TCPOk = true
+	}
+	snap.UDPUnsupported = cfg.UDPUnsupported()
+	snap.UDPOk = snap.TCPOk && cfg.UDP && !snap.UDPUnsupported
+	return snap
+}
+
+// Connections lists the TCP and UDP flows being relayed.
+func (e *netstackEngine) Connections() []core.Connection {
+	e.mu.Lock()
+	running := e.st != nil
+	e.mu.Unlock()
+	if !running {
+		return nil
+	}
+	return e.conns.list()
+}
+
+// Stats reports starts and TUN losses; Binary and Args are empty.
+func (e *netstackEngine) Stats() Stats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.stats
+}
+
+// endpointAddr converts a stack address to a netip.AddrPort.
+func endpointAddr(a tcpip.Address, port uint16) netip.AddrPort {
+	ip, _ := netip.AddrFromSlice(a.AsSlice())
+	return netip.AddrPortFrom(ip.Unmap(), port)
+}
@@ -0,0 +1,153 @@
+package tun2socks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/wireguard"
+)
+
+// Errors returned by engines.
+var (
+	ErrRunning    = errors.New("tun2socks: already running")
+	ErrNotRunning = errors.New("tun2socks: not running")
+)
+
+// Proxy types for Config.ProxyType.
+const (
+	ProxySOCKS5 = "socks5"
+	ProxyHTTP   = "http" // HTTP CONNECT; carries TCP only
+)
+
+// Config is what an engine needs to bridge a TUN device to a SOCKS5 proxy.
+type Config struct {
+	Device string     // TUN interface name
+	MTU    int        // 0 leaves the engine's default
+	Local  netip.Addr // the host's TUN address
+	Peer   netip.Addr // the engine's side of the point-to-point TUN pair
+	Proxy  string     // proxy server, "host:port"
+	// ProxyType is ProxySOCKS5 (also when empty) or ProxyHTTP.
+	ProxyType string
+	Username  string
+	Password  string
+	UDP       bool // relay UDP through SOCKS5 UDP ASSOCIATE
+	// Chain lists proxies reached through Proxy, in order; destinations
+	// are connected through the last. Only the embedded engine chains.
+	Chain []Hop
+	// Interface binds the proxy connection to an outbound interface; empty
+	// leaves the choice to the routing table.
+	Interface string
+	// LogLevel is debug, info, warn, or error; empty uses the engine's
+	// default.
+	LogLevel string
+	// WireGuard, when set, replaces the SOCKS5 fields with a WireGuard
+	// upstream; only the WireGuard engine accepts it.
+	WireGuard *wireguard.Config
+}
+
+// Hop is a proxy of Config.Chain.
+type Hop struct {
+	Proxy     string // "host:port"
+	ProxyType string // ProxySOCKS5 (also when empty) or ProxyHTTP
+	Username  string
+	Password  string
+}
+
+// hops returns Proxy followed by the chain.
+func (c Config) hops() []Hop {
+	entry := Hop{Proxy: c.Proxy, ProxyType: c.ProxyType, Username: c.Username, Password: c.Password}
+	return append([]Hop{entry}, c.Chain...)
+}
+
+// passwords returns the credentials of every hop, for redaction.
+func (c Config) passwords() []string {
+	out := []string{c.Password}
+	for _, h := range c.Chain {
+		out = append(out, h.Password)
+	}
+	return out
+}
+
+// Validate checks the fields every engine needs.
+func (c Config) Validate() error {
+	if c.Device == "" {
+		return errors.New("tun2socks: device is required")
+	}
+	if c.WireGuard != nil {
+		if err := c.WireGuard.Validate(); err != nil {
+			return errors.New("tun2socks: " + err.Error())
+		}
+	} else if _, _, err := net.SplitHostPort(c.Proxy); err != nil {
+		return fmt.Errorf("tun2socks: proxy %q: %w", c.Proxy, err)
+	}
+	for i, h := range c.hops() {
+		if i > 0 {
+			if _, _, err := net.SplitHostPort(h.Proxy); err != nil {
+				return fmt.Errorf("tun2socks: chain hop %d %q: %w", i, h.Proxy, err)
+			}
+		}
+		switch h.ProxyType {
+		case "", ProxySOCKS5, ProxyHTTP:
+		default:
+			return fmt.Errorf("tun2socks: proxy type %q: want %s or %s", h.ProxyType, ProxySOCKS5, ProxyHTTP)
+		}
+	}
+	switch c.LogLevel {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("tun2socks: log level %q: want debug, info, warn, or error", c.LogLevel)
+	}
+	return nil
+}
+
+// UDPUnsupported reports whether c asks for UDP through a proxy that
+// cannot carry it: an HTTP proxy, or a chain (SOCKS5 UDP relays are
+// reached directly, not through the previous hop). Engines leave UDP
+// unrelayed and say so in Health.
+func (c Config) UDPUnsupported() bool {
+	return c.UDP && (c.ProxyType == ProxyHTTP || len(c.Chain) > 0)
+}
+
+// Stats describes an engine's process history.
+type Stats struct {
+	Engine      string
+	Binary      string
+	PID         int       // 0 when not running
+	StartedAt   time.Time // zero when not running
+	Starts      int       // successful launches
+	Exits       int       // exits not requested by Stop
+	LastExit    time.Time
+	LastExitErr string   // redacted
+	Args        []string // of the current or last launch, credentials masked
+}
+
+// Engine runs the user-space network stack that bridges the TUN device to
+// the SOCKS5 proxy. Implementations must be safe for concurrent use.
+type Engine interface {
+	// Name identifies the engine (e.g. the Flavor name).
+	Name() string
+	// Start launches the engine for cfg and returns once it is running,
+	// or ErrRunning.
+	Start(ctx context.Context, cfg Config) error
+	// Stop asks the engine to exit and forces it when ctx ends. Stopping
+	// an engine that is not running returns nil.
+	Stop(ctx context.Context) error
+	// Health checks the running engine. The supervisor passes the result
+	// through LogBuffer.Annotate before core.State.UpdateTun2Socks.
+	Health(ctx context.Context) core.Tun2SocksSnapshot
+	// Stats reports launches and exits.
+	Stats() Stats
+}
+
+// Commander is optionally implemented by engines that run an external
+// command, so a dry run can show it.
+type Commander interface {
+	// Command returns the command line Start would run for cfg, with
+	// credentials masked.
+	Command(cfg Config) ([]string, error)
+}
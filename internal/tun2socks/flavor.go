@@ -0,0 +1,141 @@
+package tun2socks
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultFlavor is the flavor used when the config names none.
+const DefaultFlavor = "xjasonlyu"
+
+// Flavor describes the command line of one tun2socks implementation.
+type Flavor struct {
+	Name        string
+	Binary      string // default executable, looked up in PATH
+	Description string
+	UDP         bool // can relay UDP
+	// Args builds the arguments (without the binary) for cfg. Options the
+	// implementation lacks are errors rather than silently dropped.
+	Args func(cfg Config) ([]string, error)
+}
+
+var (
+	flavorMu sync.RWMutex
+	flavors  = map[string]Flavor{}
+)
+
+// RegisterFlavor adds f. It panics on an empty or duplicate name, as
+// registration is expected to happen in init.
+func RegisterFlavor(f Flavor) {
+	if f.Name == "" || f.Args == nil {
+		panic("tun2socks: flavor needs a name and Args")
+	}
+	flavorMu.Lock()
+	defer flavorMu.Unlock()
+	if _, dup := flavors[f.Name]; dup {
+		panic("tun2socks: duplicate flavor " + f.Name)
+	}
+	flavors[f.Name] = f
+}
+
+// LookupFlavor returns the flavor registered under name.
+func LookupFlavor(name string) (Flavor, bool) {
+	flavorMu.RLock()
+	defer flavorMu.RUnlock()
+	f, ok := flavors[name]
+	return f, ok
+}
+
+// Flavors returns the registered flavor names, sorted.
+func Flavors() []string {
+	flavorMu.RLock()
+	defer flavorMu.RUnlock()
+	out := make([]string, 0, len(flavors))
+	for name := range flavors {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func init() {
+	RegisterFlavor(Flavor{
+		Name:        "xjasonlyu",
+		Binary:      "tun2socks",
+		Description: "github.com/xjasonlyu/tun2socks v2 (gVisor stack)",
+		UDP:         true,
+		Args:        xjasonlyuArgs,
+	})
+	RegisterFlavor(Flavor{
+		Name:        "badvpn",
+		Binary:      "badvpn-tun2socks",
+		Description: "badvpn-tun2socks (lwIP stack)",
+		UDP:         true,
+		Args:        badvpnArgs,
+	})
+}
+
+// xjasonlyuArgs: tun2socks -device tun://NAME -proxy socks5://[user:pass@]host:port,
+// or http:// for an HTTP CONNECT proxy.
+func xjasonlyuArgs(cfg Config) ([]string, error) {
+	proxy := url.URL{Scheme: ProxySOCKS5, Host: cfg.Proxy}
+	if cfg.ProxyType == ProxyHTTP {
+		proxy.Scheme = ProxyHTTP
+	}
+	if cfg.Username != "" {
+		proxy.User = url.UserPassword(cfg.Username, cfg.Password)
+	}
+	args := []string{"-device", "tun://" + cfg.Device, "-proxy", proxy.String()}
+	if cfg.MTU > 0 {
+		args = append(args, "-mtu", strconv.Itoa(cfg.MTU))
+	}
+	if cfg.Interface != "" {
+		args = append(args, "-interface", cfg.Interface)
+	}
+	if cfg.LogLevel != "" {
+		level := cfg.LogLevel
+		if level == "warn" {
+			level = "warning"
+		}
+		args = append(args, "-loglevel", level)
+	}
+	return args, nil
+}
+
+// badvpnArgs: badvpn-tun2socks --tundev NAME --netif-ipaddr PEER
+// --netif-netmask MASK --socks-server-addr host:port. The TUN pair is
+// point-to-point, so the engine's address sits in the host's /30.
+func badvpnArgs(cfg Config) ([]string, error) {
+	if cfg.ProxyType == ProxyHTTP {
+		return nil, errors.New("badvpn relays to SOCKS5 proxies only, not http")
+	}
+	if cfg.Interface != "" {
+		return nil, errors.New("badvpn cannot bind the proxy connection to an interface")
+	}
+	if !cfg.Peer.Is4() {
+		return nil, errors.New("badvpn needs an IPv4 peer address for its virtual router")
+	}
+	args := []string{
+		"--tundev", cfg.Device,
+		"--netif-ipaddr", cfg.Peer.String(),
+		"--netif-netmask", "255.255.255.252",
+		"--socks-server-addr", cfg.Proxy,
+	}
+	if cfg.Username != "" {
+		args = append(args, "--username", cfg.Username, "--password", cfg.Password)
+	}
+	if cfg.UDP {
+		args = append(args, "--socks5-udp")
+	}
+	if cfg.LogLevel != "" {
+		level := cfg.LogLevel
+		if level == "warn" {
+			level = "warning"
+		}
+		args = append(args, "--loglevel", level)
+	}
+	return args, nil
+}
@@ -0,0 +1,52 @@
+package tun2socks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// maxConnectHeader bounds an HTTP CONNECT response header.
+const maxConnectHeader = 8 << 10
+
+// httpHandshake asks HTTP proxy hop h for a CONNECT tunnel to target
+// ("host:port"). The response is read a byte at a time, so bytes the
+// target sends right after it stay on the connection.
+func httpHandshake(conn net.Conn, h Hop, target string) error {
+	var req bytes.Buffer
+	fmt.Fprintf(&req, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", target, target)
+	if h.Username != "" {
+		cred := base64.StdEncoding.EncodeToString([]byte(h.Username + ":" + h.Password))
+		fmt.Fprintf(&req, "Proxy-Authorization: Basic %s\r\n", cred)
+	}
+	req.WriteString("\r\n")
+	if _, err := conn.Write(req.Bytes()); err != nil {
+		return fmt.Errorf("http connect: %w", err)
+	}
+
+	var hdr []byte
+	var b [1]byte
+	for !bytes.HasSuffix(hdr, []byte("\r\n\r\n")) {
+		if len(hdr) >= maxConnectHeader {
+			return errors.New("http connect: response header too large")
+		}
+		if _, err := io.ReadFull(conn, b[:]); err != nil {
+			return fmt.Errorf("http connect: %w", err)
+		}
+		hdr = append(hdr, b[0])
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(hdr)), nil)
+	if err != nil {
+		return fmt.Errorf("http connect: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("http connect: proxy answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package tun2socks
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// Defaults for LogBuffer and Annotate.
+const (
+	DefaultLogLines   = 1000 // lines kept by NewLogBuffer(0)
+	DefaultErrorLines = 5    // error lines Annotate copies into a snapshot
+	MaxLineLen        = 2048 // longer lines are truncated
+)
+
+// Output streams.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// Line is one captured output line.
+type Line struct {
+	At     time.Time
+	Stream string // StreamStdout or StreamStderr
+	Text   string
+	Error  bool // see package doc, "Error Lines"
+}
+
+// LogBuffer is a bounded ring of output lines, safe for concurrent use.
+type LogBuffer struct {
+	mu      sync.Mutex
+	cap     int
+	lines   []Line // oldest first
+	total   uint64
+	dropped uint64
+	partial map[string]string // unterminated tail per stream
+	now     func() time.Time
+}
+
+// NewLogBuffer returns a buffer keeping the last capacity lines
+// (DefaultLogLines if <= 0).
+func NewLogBuffer(capacity int) *LogBuffer {
+	if capacity <= 0 {
+		capacity = DefaultLogLines
+	}
+	return &LogBuffer{cap: capacity, partial: map[string]string{}, now: time.Now}
+}
+
+// Stdout returns a writer that captures the process's standard output.
+func (b *LogBuffer) Stdout() io.Writer { return streamWriter{b, StreamStdout} }
+
+// Stderr returns a writer that captures the process's standard error.
+func (b *LogBuffer) Stderr() io.Writer { return streamWriter{b, StreamStderr} }
+
+type streamWriter struct {
+	b      *LogBuffer
+	stream string
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w.b.write(w.stream, string(p))
+	return len(p), nil
+}
+
+func (b *LogBuffer) write(stream, s string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s = b.partial[stream] + s
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			break
+		}
+		b.appendLocked(stream, s[:i])
+		s = s[i+1:]
+	}
+	if len(s) > MaxLineLen {
+		b.appendLocked(stream, s)
+		s = ""
+	}
+	b.partial[stream] = s
+}
+
+// Flush records unterminated output as a line, e.g. after the process
+// exits.
+func (b *LogBuffer) Flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, stream := range []string{StreamStdout, StreamStderr} {
+		if s := b.partial[stream]; s != "" {
+			b.appendLocked(stream, s)
+			b.partial[stream] = ""
+		}
+	}
+}
+
+func (b *LogBuffer) appendLocked(stream, text string) {
+	text = strings.TrimRight(text, "\r")
+	if len(text) > MaxLineLen {
+		text = text[:MaxLineLen]
+	}
+	text = redact.String(text)
+	if len(b.lines) == b.cap {
+		copy(b.lines, b.lines[1:])
+		b.lines = b.lines[:b.cap-1]
+		b.dropped++
+	}
+	b.lines = append(b.lines, Line{At: b.now(), Stream: stream, Text: text, Error: isError(stream, text)})
+	b.total++
+}
+
+// isError classifies a line; see package doc, "Error Lines".
+func isError(stream, text string) bool {
+	l := strings.ToLower(text)
+	if strings.Contains(l, "error") || strings.Contains(l, "fatal") || strings.Contains(l, "panic") {
+		return true
+	}
+	return stream == StreamStderr && (strings.Contains(l, "[err") || strings.Contains(l, "level=err"))
+}
+
+// Tail returns up to the last n lines, oldest first; n <= 0 returns all.
+func (b *LogBuffer) Tail(n int) []Line {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	start := 0
+	if n > 0 && n < len(b.lines) {
+		start = len(b.lines) - n
+	}
+	return append([]Line(nil), b.lines[start:]...)
+}
+
+// ErrorLines returns the text of up to the last n error lines, oldest
+// first.
+func (b *LogBuffer) ErrorLines(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []string
+	for i := len(b.lines) - 1; i >= 0 && len(out) < n; i-- {
+		if b.lines[i].Error {
+			out = append(out, b.lines[i].Text)
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Stats returns the buffer capacity, lines captured since start, and lines
+// evicted to make room.
+func (b *LogBuffer) Stats() (capacity int, total, dropped uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cap, b.total, b.dropped
+}
+
+// Reset drops every line, e.g. when a new process starts. Counters keep
+// running.
+func (b *LogBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = b.lines[:0]
+	clear(b.partial)
+}
+
+// Annotate returns snap with LastErrors set from b when a health check
+// failed (running with an unhealthy TCP path) and cleared otherwise. The
+// supervisor passes each health result through it before
+// core.State.UpdateTun2Socks. A nil b only clears.
+func (b *LogBuffer) Annotate(snap core.Tun2SocksSnapshot) core.Tun2SocksSnapshot {
+	snap.LastErrors = nil
+	if b != nil && snap.PID != 0 && !snap.TCPOk {
+		snap.LastErrors = b.ErrorLines(DefaultErrorLines)
+	}
+	return snap
+}
@@ -0,0 +1,201 @@
+package tun2socks
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/happyeyeballs"
+	"github.com/sanverite/simple-packet-logger/internal/redact"
+)
+
+// healthDialTimeout bounds the proxy dial in Process.Health.
+const healthDialTimeout = 2 * time.Second
+
+// ProcessOptions configures a Process.
+type ProcessOptions struct {
+	// Binary overrides the flavor's executable (a path or a name looked
+	// up in PATH).
+	Binary string
+	// ExtraArgs are appended to the flavor's arguments.
+	ExtraArgs []string
+	// Logs captures the child's output; nil discards it.
+	Logs *LogBuffer
+	// Logger receives start and exit records; nil discards them.
+	Logger *slog.Logger
+}
+
+// Process is an Engine that runs an external tun2socks binary of one
+// Flavor as a child process.
+type Process struct {
+	flavor Flavor
+	opts   ProcessOptions
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	cfg      Config
+	done     chan struct{} // closed when the current child exits
+	stopping bool
+	stats    Stats
+}
+
+// NewProcess returns a stopped engine for f.
+func NewProcess(f Flavor, opts ProcessOptions) *Process {
+	if opts.Binary == "" {
+		opts.Binary = f.Binary
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.DiscardHandler)
+	}
+	return &Process{flavor: f, opts: opts, stats: Stats{Engine: f.Name, Binary: opts.Binary}}
+}
+
+// Name returns the flavor name.
+func (p *Process) Name() string { return p.flavor.Name }
+
+// Command returns the command line for cfg with credentials masked.
+func (p *Process) Command(cfg Config) ([]string, error) {
+	args, err := p.args(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return redact.New(cfg.Password).Strings(append([]string{p.opts.Binary}, args...)), nil
+}
+
+func (p *Process) args(cfg Config) ([]string, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.WireGuard != nil {
+		return nil, errors.New("tun2socks: " + p.flavor.Name + " relays to SOCKS5 only, not wireguard")
+	}
+	if len(cfg.Chain) > 0 {
+		return nil, errors.New("tun2socks: " + p.flavor.Name + " cannot chain proxies; use the embedded engine")
+	}
+	args, err := p.flavor.Args(cfg)
+	if err != nil {
+		return nil, errors.New("tun2socks: " + err.Error())
+	}
+	return append(args, p.opts.ExtraArgs...), nil
+}
+
+// Start launches the binary. The output buffer is reset so it holds this
+// process's output only.
+func (p *Process) Start(ctx context.Context, cfg Config) error {
+	args, err := p.args(cfg)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd != nil {
+		return ErrRunning
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	cmd := exec.Command(p.opts.Binary, args...)
+	if b := p.opts.Logs; b != nil {
+		b.Reset()
+		cmd.Stdout, cmd.Stderr = b.Stdout(), b.Stderr()
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.cmd, p.cfg, p.done, p.stopping = cmd, cfg, make(chan struct{}), false
+	p.stats.PID = cmd.Process.Pid
+	p.stats.StartedAt = time.Now()
+	p.stats.Starts++
+	p.stats.Args = redact.New(cfg.Password).Strings(args)
+	p.opts.Logger.Info("tun2socks started", "engine", p.flavor.Name, "pid", p.stats.PID)
+	go p.wait(cmd, p.done)
+	return nil
+}
+
+// wait reaps cmd and records an exit that Stop did not ask for.
+func (p *Process) wait(cmd *exec.Cmd, done chan struct{}) {
+	err := cmd.Wait()
+	if b := p.opts.Logs; b != nil {
+		b.Flush()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.stopping {
+		p.stats.Exits++
+		p.stats.LastExit = time.Now()
+		msg := "exited"
+		if err != nil {
+			msg = redact.New(p.cfg.Password).String(err.Error())
+		}
+		p.stats.LastExitErr = msg
+		p.opts.Logger.Warn("tun2socks exited unexpectedly", "engine", p.flavor.Name, "pid", cmd.Process.Pid, "err", msg)
+	}
+	if p.cmd == cmd {
+		p.cmd = nil
+		p.stats.PID = 0
+		p.stats.StartedAt = time.Time{}
+	}
+	close(done)
+}
+
+// Stop sends SIGTERM (a kill where signals are unsupported) and kills the
+// process if it has not exited when ctx ends.
+func (p *Process) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	cmd, done := p.cmd, p.done
+	if cmd == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	p.stopping = true
+	p.mu.Unlock()
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		_ = cmd.Process.Kill()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Health reports the process and whether the proxy accepts TCP
+// connections from the host. UDP is reported healthy when TCP is and the
+// flavor relays UDP for a config that asked for it; the proxy's UDP path
+// itself is covered by probes. An HTTP proxy reports UDP unsupported.
+func (p *Process) Health(ctx context.Context) core.Tun2SocksSnapshot {
+	p.mu.Lock()
+	pid, started, cfg := p.stats.PID, p.stats.StartedAt, p.cfg
+	p.mu.Unlock()
+	if pid == 0 {
+		return core.Tun2SocksSnapshot{}
+	}
+	snap := core.Tun2SocksSnapshot{PID: pid, UptimeSec: int64(time.Since(started).Seconds())}
+	ctx, cancel := context.WithTimeout(ctx, healthDialTimeout)
+	defer cancel()
+	if conn, err := happyeyeballs.Dial(ctx, cfg.Proxy); err == nil {
+		conn.Close()
+		snap.TCPOk = true
+	}
+	snap.UDPUnsupported = cfg.UDPUnsupported()
+	snap.UDPOk = snap.TCPOk && cfg.UDP && p.flavor.UDP && !snap.UDPUnsupported
+	return snap
+}
+
+// Stats reports launches and exits.
+func (p *Process) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := p.stats
+	st.Args = append([]string(nil), st.Args...)
+	return st
+}
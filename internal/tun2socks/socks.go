@@ -0,0 +1,287 @@
+package tun2socks
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/happyeyeballs"
+)
+
+// SOCKS5 commands and address types used by the embedded engine.
+const (
+	socksConnect      = 0x01
+	socksUDPAssociate = 0x03
+
+	socksATYPv4     = 0x01
+	socksATYPDomain = 0x03
+	socksATYPv6     = 0x04
+)
+
+// socksHandshakeTimeout bounds the greeting, auth, and command exchange.
+const socksHandshakeTimeout = 10 * time.Second
+
+// socksClient opens SOCKS5 sessions to cfg.Proxy for the embedded engine,
+// or HTTP CONNECT tunnels when cfg.ProxyType is ProxyHTTP. With a chain,
+// the session is with the last hop, tunneled through the others. A proxy
+// hostname is dialed over both address families (see happyeyeballs).
+type socksClient struct {
+	cfg    Config
+	dialer happyeyeballs.Dialer
+}
+
+// connect dials the proxy and asks it to CONNECT to dst.
+func (c *socksClient) connect(ctx context.Context, dst netip.AddrPort) (net.Conn, error) {
+	conn, _, err := c.command(ctx, socksConnect, dst)
+	return conn, err
+}
+
+// associate opens a UDP ASSOCIATE session. The returned TCP connection
+// keeps the association alive; relay is where datagrams go.
+func (c *socksClient) associate(ctx context.Context) (net.Conn, netip.AddrPort, error) {
+	conn, relay, err := c.command(ctx, socksUDPAssociate, netip.AddrPortFrom(netip.IPv4Unspecified(), 0))
+	if err != nil {
+		return nil, netip.AddrPort{}, err
+	}
+	// Proxies commonly answer 0.0.0.0 meaning "the address you dialed".
+	if relay.Addr().IsUnspecified() {
+		if ap, err := netip.ParseAddrPort(conn.RemoteAddr().String()); err == nil {
+			relay = netip.AddrPortFrom(ap.Addr().Unmap(), relay.Port())
+		}
+	}
+	return conn, relay, nil
+}
+
+// command dials the proxy, tunnels through the chain, authenticates with
+// the last hop, and sends cmd for dst. It returns the connection and the
+// reply's bound address (invalid from an HTTP proxy, which has none).
+func (c *socksClient) command(ctx context.Context, cmd byte, dst netip.AddrPort) (net.Conn, netip.AddrPort, error) {
+	hops := c.cfg.hops()
+	conn, err := c.dialer.DialContext(ctx, "tcp", hops[0].Proxy)
+	if err != nil {
+		return nil, netip.AddrPort{}, err
+	}
+	deadline := time.Now().Add(socksHandshakeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+	bound, err := tunnel(conn, hops, cmd, dst)
+	if err != nil {
+		conn.Close()
+		return nil, netip.AddrPort{}, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, bound, nil
+}
+
+// tunnel has each hop but the last CONNECT to the next one, then sends
+// cmd for dst to the last.
+func tunnel(conn net.Conn, hops []Hop, cmd byte, dst netip.AddrPort) (netip.AddrPort, error) {
+	for i, h := range hops[:len(hops)-1] {
+		next := hops[i+1].Proxy
+		var err error
+		if h.ProxyType == ProxyHTTP {
+			err = httpHandshake(conn, h, next)
+		} else {
+			var addr []byte
+			if addr, err = appendSocksHost(nil, next); err == nil {
+				_, err = handshake(conn, h, socksConnect, addr)
+			}
+		}
+		if err != nil {
+			return netip.AddrPort{}, fmt.Errorf("chain hop %d (%s): %w", i, h.Proxy, err)
+		}
+	}
+	last := hops[len(hops)-1]
+	if last.ProxyType == ProxyHTTP {
+		if cmd != socksConnect {
+			return netip.AddrPort{}, errors.New("http connect: only TCP is supported")
+		}
+		return netip.AddrPort{}, httpHandshake(conn, last, dst.String())
+	}
+	return handshake(conn, last, cmd, appendSocksAddr(nil, dst))
+}
+
+// handshake greets hop h, authenticates, and sends cmd for the encoded
+// address addr.
+func handshake(conn net.Conn, h Hop, cmd byte, addr []byte) (netip.AddrPort, error) {
+	methods := []byte{0x00}
+	if h.Username != "" {
+		methods = append(methods, 0x02)
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return netip.AddrPort{}, fmt.Errorf("socks5 greeting: %w", err)
+	}
+	var sel [2]byte
+	if _, err := io.ReadFull(conn, sel[:]); err != nil {
+		return netip.AddrPort{}, fmt.Errorf("socks5 greeting: %w", err)
+	}
+	switch {
+	case sel[0] != 0x05:
+		return netip.AddrPort{}, fmt.Errorf("socks5 greeting: unexpected version 0x%02x", sel[0])
+	case sel[1] == 0x02 && h.Username != "":
+		if err := auth(conn, h); err != nil {
+			return netip.AddrPort{}, err
+		}
+	case sel[1] != 0x00:
+		return netip.AddrPort{}, fmt.Errorf("socks5 greeting: proxy selected method 0x%02x", sel[1])
+	}
+
+	req := append([]byte{0x05, cmd, 0x00}, addr...)
+	if _, err := conn.Write(req); err != nil {
+		return netip.AddrPort{}, fmt.Errorf("socks5 request: %w", err)
+	}
+	var hdr [3]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return netip.AddrPort{}, fmt.Errorf("socks5 reply: %w", err)
+	}
+	if hdr[0] != 0x05 {
+		return netip.AddrPort{}, fmt.Errorf("socks5 reply: unexpected version 0x%02x", hdr[0])
+	}
+	if hdr[1] != 0x00 {
+		return netip.AddrPort{}, fmt.Errorf("socks5 reply: %s", socksReplyText(hdr[1]))
+	}
+	return readSocksAddr(conn)
+}
+
+// auth performs RFC 1929 username/password authentication.
+func auth(conn net.Conn, h Hop) error {
+	u, p := h.Username, h.Password
+	if len(u) > 255 || len(p) > 255 {
+		return errors.New("socks5 auth: username and password are limited to 255 bytes")
+	}
+	req := append([]byte{0x01, byte(len(u))}, u...)
+	req = append(append(req, byte(len(p))), p...)
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("socks5 auth: %w", err)
+	}
+	var rep [2]byte
+	if _, err := io.ReadFull(conn, rep[:]); err != nil {
+		return fmt.Errorf("socks5 auth: %w", err)
+	}
+	if rep[1] != 0x00 {
+		return errors.New("socks5 auth: rejected")
+	}
+	return nil
+}
+
+// appendSocksAddr appends ATYP, ADDR, and PORT for ap.
+func appendSocksAddr(b []byte, ap netip.AddrPort) []byte {
+	a := ap.Addr().Unmap()
+	if a.Is4() {
+		b = append(b, socksATYPv4)
+	} else {
+		b = append(b, socksATYPv6)
+	}
+	b = append(b, a.AsSlice()...)
+	return binary.BigEndian.AppendUint16(b, ap.Port())
+}
+
+// appendSocksHost appends ATYP, ADDR, and PORT for a "host:port" whose
+// host may be a name, which the proxy then resolves.
+func appendSocksHost(b []byte, hostport string) ([]byte, error) {
+	if ap, err := netip.ParseAddrPort(hostport); err == nil {
+		return appendSocksAddr(b, ap), nil
+	}
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, err
+	}
+	pn, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("port %q: %w", port, err)
+	}
+	if len(host) == 0 || len(host) > 255 {
+		return nil, fmt.Errorf("host %q: want 1 to 255 bytes", host)
+	}
+	b = append(b, socksATYPDomain, byte(len(host)))
+	b = append(b, host...)
+	return binary.BigEndian.AppendUint16(b, uint16(pn)), nil
+}
+
+// readSocksAddr reads ATYP, ADDR, and PORT. Domain names, which proxies
+// rarely bind to, yield an unspecified address with the port.
+func readSocksAddr(r io.Reader) (netip.AddrPort, error) {
+	var atyp [1]byte
+	if _, err := io.ReadFull(r, atyp[:]); err != nil {
+		return netip.AddrPort{}, err
+	}
+	var n int
+	switch atyp[0] {
+	case socksATYPv4:
+		n = 4
+	case socksATYPv6:
+		n = 16
+	case socksATYPDomain:
+		var l [1]byte
+		if _, err := io.ReadFull(r, l[:]); err != nil {
+			return netip.AddrPort{}, err
+		}
+		n = int(l[0])
+	default:
+		return netip.AddrPort{}, fmt.Errorf("socks5: unknown address type 0x%02x", atyp[0])
+	}
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return netip.AddrPort{}, err
+	}
+	port := binary.BigEndian.Uint16(buf[n:])
+	if atyp[0] == socksATYPDomain {
+		return netip.AddrPortFrom(netip.IPv4Unspecified(), port), nil
+	}
+	a, _ := netip.AddrFromSlice(buf[:n])
+	return netip.AddrPortFrom(a, port), nil
+}
+
+// socksUDPHeader returns the RFC 1928 UDP request header for dst.
+func socksUDPHeader(dst netip.AddrPort) []byte {
+	return appendSocksAddr([]byte{0x00, 0x00, 0x00}, dst)
+}
+
+// parseSocksUDP splits a relayed datagram into its source and payload.
+// Fragments (FRAG != 0) are not supported and are reported as errors.
+func parseSocksUDP(b []byte) (netip.AddrPort, []byte, error) {
+	if len(b) < 4 {
+		return netip.AddrPort{}, nil, errors.New("socks5 udp: short datagram")
+	}
+	if b[2] != 0x00 {
+		return netip.AddrPort{}, nil, errors.New("socks5 udp: fragmented datagram")
+	}
+	r := bytes.NewReader(b[3:])
+	src, err := readSocksAddr(r)
+	if err != nil {
+		return netip.AddrPort{}, nil, fmt.Errorf("socks5 udp: %w", err)
+	}
+	return src, b[len(b)-r.Len():], nil
+}
+
+// socksReplyText names a SOCKS5 reply code.
+func socksReplyText(rep byte) string {
+	switch rep {
+	case 0x01:
+		return "general failure"
+	case 0x02:
+		return "connection not allowed by ruleset"
+	case 0x03:
+		return "network unreachable"
+	case 0x04:
+		return "host unreachable"
+	case 0x05:
+		return "connection refused"
+	case 0x06:
+		return "TTL expired"
+	case 0x07:
+		return "command not supported"
+	case 0x08:
+		return "address type not supported"
+	}
+	return fmt.Sprintf("reply 0x%02x", rep)
+}
@@ -0,0 +1,89 @@
+package tun2socks
+
+import (
+	"context"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// Traffic sampling defaults for RunTraffic.
+const (
+	DefaultTrafficInterval = time.Second
+	// TrafficWindow is the span rates are averaged over.
+	TrafficWindow = 5 * time.Second
+)
+
+// RunTraffic samples the TUN device's counters (core.TUNSnapshot.Name)
+// each interval and reports them with state.UpdateTraffic until ctx is
+// canceled. The counters belong to the device, so they cover every
+// engine. While there is no device, or it cannot be read, the traffic is
+// zero.
+func RunTraffic(ctx context.Context, state *core.State, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultTrafficInterval
+	}
+	var w trafficWindow
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		name := state.GetSnapshot().TUN.Name
+		var c netinfo.Counters
+		err := netinfo.ErrUnsupported
+		if name != "" {
+			c, err = netinfo.InterfaceCounters(name)
+		}
+		if err != nil {
+			w = trafficWindow{}
+			state.UpdateTraffic(core.TrafficSnapshot{})
+			continue
+		}
+		state.UpdateTraffic(w.add(name, c, time.Now()))
+	}
+}
+
+// trafficSample is one reading of a device's counters.
+type trafficSample struct {
+	at time.Time
+	c  netinfo.Counters
+}
+
+// trafficWindow keeps the samples of the last TrafficWindow.
+type trafficWindow struct {
+	device  string
+	samples []trafficSample // oldest first
+}
+
+// add records c and returns the totals with rates against the oldest
+// sample in the window. A new device or counters that went backwards (the
+// device was recreated) restart the window.
+func (w *trafficWindow) add(device string, c netinfo.Counters, now time.Time) core.TrafficSnapshot {
+	if n := len(w.samples); device != w.device || n > 0 && (c.RxBytes < w.samples[n-1].c.RxBytes || c.TxBytes < w.samples[n-1].c.TxBytes) {
+		w.device, w.samples = device, nil
+	}
+	w.samples = append(w.samples, trafficSample{at: now, c: c})
+	for len(w.samples) > 2 && now.Sub(w.samples[1].at) >= TrafficWindow {
+		w.samples = w.samples[1:]
+	}
+	// A TUN's kernel side sends what the host routes into the tunnel and
+	// receives what the engine writes back.
+	snap := core.TrafficSnapshot{
+		BytesIn:    c.RxBytes,
+		BytesOut:   c.TxBytes,
+		PacketsIn:  c.RxPackets,
+		PacketsOut: c.TxPackets,
+		SampledAt:  now,
+	}
+	first := w.samples[0]
+	if secs := now.Sub(first.at).Seconds(); secs > 0 {
+		snap.RateIn = float64(c.RxBytes-first.c.RxBytes) / secs
+		snap.RateOut = float64(c.TxBytes-first.c.TxBytes) / secs
+	}
+	return snap
+}
@@ -0,0 +1,198 @@
+package tun2socks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+	"github.com/sanverite/simple-packet-logger/internal/wireguard"
+)
+
+// EngineWireGuard names the userspace WireGuard engine; start requests
+// select it by naming a WireGuard upstream instead of a SOCKS5 proxy.
+const EngineWireGuard = "wireguard"
+
+// wireGuardFresh is how recent the last handshake must be for Health to
+// report the tunnel up; WireGuard rejects sessions older than this.
+const wireGuardFresh = 180 * time.Second
+
+// WireGuardOptions configures a WireGuard engine.
+type WireGuardOptions struct {
+	// Logs receives the device's error lines, as a child's stderr would.
+	Logs *LogBuffer
+	// Logger receives start and stop records and the device's verbose
+	// log at debug level; nil discards them.
+	Logger *slog.Logger
+}
+
+// WireGuard is an Engine that carries the TUN's packets to a WireGuard
+// peer with wireguard-go, in-process, instead of translating them to
+// SOCKS5. Config.WireGuard must be set.
+type WireGuard struct {
+	opts WireGuardOptions
+
+	mu    sync.Mutex
+	dev   *device.Device
+	cfg   Config
+	stats Stats
+}
+
+// NewWireGuard returns a stopped WireGuard engine.
+func NewWireGuard(opts WireGuardOptions) *WireGuard {
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.DiscardHandler)
+	}
+	return &WireGuard{opts: opts, stats: Stats{Engine: EngineWireGuard}}
+}
+
+// Name returns EngineWireGuard.
+func (w *WireGuard) Name() string { return EngineWireGuard }
+
+// Start creates the TUN device, configures the peer, and brings the
+// device up. The first handshake happens when traffic (or the keepalive)
+// needs it, so Start succeeds before the peer has answered.
+func (w *WireGuard) Start(ctx context.Context, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	wg := cfg.WireGuard
+	if wg == nil {
+		return fmt.Errorf("tun2socks: %s engine needs a wireguard upstream", EngineWireGuard)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dev != nil {
+		return ErrRunning
+	}
+	ep, err := wireguard.ResolveEndpoint(ctx, nil, wg.Peer.Endpoint)
+	if err != nil {
+		return fmt.Errorf("tun2socks: wireguard endpoint: %w", err)
+	}
+	mtu := cfg.MTU
+	if mtu == 0 {
+		mtu = wireguard.DefaultMTU
+	}
+	tdev, err := tun.CreateTUN(cfg.Device, mtu)
+	if err != nil {
+		return fmt.Errorf("tun2socks: create %s: %w", cfg.Device, err)
+	}
+	dev := device.NewDevice(tdev, conn.NewDefaultBind(), w.logger())
+	if err := dev.IpcSet(wg.UAPI(ep)); err != nil {
+		dev.Close()
+		return fmt.Errorf("tun2socks: wireguard config: %w", err)
+	}
+	if err := dev.Up(); err != nil {
+		dev.Close()
+		return fmt.Errorf("tun2socks: wireguard up: %w", err)
+	}
+	if b := w.opts.Logs; b != nil {
+		b.Reset()
+	}
+	w.dev, w.cfg = dev, cfg
+	w.stats.PID = os.Getpid()
+	w.stats.StartedAt = time.Now()
+	w.stats.Starts++
+	w.opts.Logger.Info("tun2socks started", "engine", EngineWireGuard, "device", cfg.Device, "endpoint", ep, "mtu", mtu)
+	go w.wait(dev)
+	return nil
+}
+
+// logger routes the device's verbose lines to the debug log and its
+// errors to the output buffer.
+func (w *WireGuard) logger() *device.Logger {
+	return &device.Logger{
+		Verbosef: func(format string, args ...any) {
+			w.opts.Logger.Debug(fmt.Sprintf(format, args...), "engine", EngineWireGuard)
+		},
+		Errorf: func(format string, args ...any) {
+			msg := fmt.Sprintf(format, args...)
+			w.opts.Logger.Warn(msg, "engine", EngineWireGuard)
+			if b := w.opts.Logs; b != nil {
+				line := time.Now().UTC().Format(time.RFC3339) + " [ERROR] " + msg + "\n"
+				_, _ = b.Stderr().Write([]byte(line))
+			}
+		},
+	}
+}
+
+// wait records the device closing without Stop, e.g. when the TUN is
+// removed underneath it.
+func (w *WireGuard) wait(dev *device.Device) {
+	<-dev.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dev != dev {
+		return
+	}
+	w.dev = nil
+	w.stats.PID = 0
+	w.stats.StartedAt = time.Time{}
+	w.stats.Exits++
+	w.stats.LastExit = time.Now()
+	w.stats.LastExitErr = "device closed"
+	w.opts.Logger.Warn("tun2socks exited unexpectedly", "engine", EngineWireGuard, "err", "device closed")
+}
+
+// Stop closes the device, which removes the TUN it created.
+func (w *WireGuard) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	dev := w.dev
+	w.dev = nil
+	w.stats.PID = 0
+	w.stats.StartedAt = time.Time{}
+	w.mu.Unlock()
+	if dev == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		dev.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		w.opts.Logger.Info("tun2socks stopped", "engine", EngineWireGuard)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Health reports the agent's PID, since the device runs in-process, and
+// TCP and UDP as working while the last handshake is younger than three
+// minutes. An idle tunnel without a keepalive therefore reads as down
+// until traffic causes a new handshake.
+func (w *WireGuard) Health(ctx context.Context) core.Tun2SocksSnapshot {
+	w.mu.Lock()
+	dev, started := w.dev, w.stats.StartedAt
+	w.mu.Unlock()
+	if dev == nil {
+		return core.Tun2SocksSnapshot{}
+	}
+	snap := core.Tun2SocksSnapshot{PID: os.Getpid(), UptimeSec: int64(time.Since(started).Seconds())}
+	uapi, err := dev.IpcGet()
+	if err != nil {
+		return snap
+	}
+	st := wireguard.ParsePeerStats(uapi)
+	if !st.LastHandshake.IsZero() && time.Since(st.LastHandshake) < wireGuardFresh {
+		snap.TCPOk, snap.UDPOk = true, true
+	}
+	return snap
+}
+
+// Stats reports starts and unexpected device closes; Binary and Args are
+// empty.
+func (w *WireGuard) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats
+}
@@ -0,0 +1,34 @@
+// Package tundev creates and configures the TUN device the tunnel runs
+// on, for the tun_created step of a start and tun_removed of a stop.
+// Method names the mechanism (tuntap, wintun, or unsupported), as listed
+// at GET /v1/version.
+//
+// # Linux
+//
+// Create opens /dev/net/tun, attaches with TUNSETIFF (IFF_TUN |
+// IFF_NO_PI), and marks the device persistent, so it stays after the
+// descriptor closes; the tun2socks process, the embedded netstack, or
+// WireGuard then attach to it by name. Configure assigns the plan's
+// point-to-point IPv4 address (and IPv6 address when IPv6 is routed),
+// sets the MTU, and brings the link up over rtnetlink (see package rtnl).
+// Remove clears the persistent flag, which deletes the device once no
+// engine holds it. All three need CAP_NET_ADMIN; SetOwner lets an
+// unprivileged user attach to the device, for engines of an agent that
+// leaves those calls to the privileged helper (see package privhelper).
+//
+// # Windows
+//
+// Create opens a wintun adapter (wintun.dll must sit next to the
+// executable) and keeps its handle, since the adapter is deleted when the
+// handle closes; Remove closes it. An in-process engine takes the open
+// device from Device instead of opening the adapter again. Configure
+// assigns the IPv4 address (and IPv6 address when IPv6 is routed) and the
+// MTU through iphlpapi (see package iphlp). All of it needs an
+// administrator.
+//
+// # Other Platforms
+//
+// Create, Configure, and Remove return ErrUnsupported. On macOS the
+// engine opens a utun control socket itself, so the kernel assigns the
+// utunN name and the device lives as long as the engine.
+package tundev
@@ -0,0 +1,10 @@
+package tundev
+
+import "errors"
+
+// ErrUnsupported is returned on platforms where the agent does not manage
+// the device itself.
+var ErrUnsupported = errors.New("tun device management not supported on this platform")
+
+// Method reports how Create makes the device on this platform.
+func Method() string { return method }
@@ -0,0 +1,98 @@
+//go:build linux
+
+package tundev
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+	"github.com/sanverite/simple-packet-logger/internal/rtnl"
+)
+
+const method = "tuntap"
+
+// cloneDevice is the TUN clone device.
+const cloneDevice = "/dev/net/tun"
+
+// Create creates the persistent TUN device name (a pattern such as
+// "spl%d" lets the kernel number it) and returns its name. The device
+// outlives the call; engines attach to it by name. Creating a device that
+// exists attaches to it, which succeeds for an unused TUN.
+func Create(name string) (string, error) {
+	return setPersist(name, true)
+}
+
+// Remove deletes a device made by Create.
+func Remove(name string) error {
+	_, err := setPersist(name, false)
+	return err
+}
+
+// SetOwner lets uid attach to the persistent device name without
+// CAP_NET_ADMIN, for engines of an unprivileged agent (see package
+// privhelper).
+func SetOwner(name string, uid int) error {
+	fd, ifr, err := attach(name)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	if err := unix.IoctlSetInt(fd, unix.TUNSETOWNER, uid); err != nil {
+		return fmt.Errorf("tun %s: %w", ifr.Name(), os.NewSyscallError("TUNSETOWNER", err))
+	}
+	return nil
+}
+
+// attach opens the clone device and attaches it to the device name.
+func attach(name string) (int, *unix.Ifreq, error) {
+	fd, err := unix.Open(cloneDevice, unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, nil, fmt.Errorf("open %s: %w", cloneDevice, err)
+	}
+	ifr, err := unix.NewIfreq(name)
+	if err != nil {
+		unix.Close(fd)
+		return -1, nil, fmt.Errorf("tun %q: %w", name, err)
+	}
+	ifr.SetUint16(unix.IFF_TUN | unix.IFF_NO_PI)
+	if err := unix.IoctlIfreq(fd, unix.TUNSETIFF, ifr); err != nil {
+		unix.Close(fd)
+		return -1, nil, fmt.Errorf("tun %s: %w", name, os.NewSyscallError("TUNSETIFF", err))
+	}
+	return fd, ifr, nil
+}
+
+func setPersist(name string, persist bool) (string, error) {
+	fd, ifr, err := attach(name)
+	if err != nil {
+		return "", err
+	}
+	defer unix.Close(fd)
+	v := 0
+	if persist {
+		v = 1
+	}
+	if err := unix.IoctlSetInt(fd, unix.TUNSETPERSIST, v); err != nil {
+		return "", fmt.Errorf("tun %s: %w", ifr.Name(), os.NewSyscallError("TUNSETPERSIST", err))
+	}
+	return ifr.Name(), nil
+}
+
+// Configure assigns cfg's addresses to the device, sets its MTU, and
+// brings it up.
+func Configure(cfg routeplan.TUNConfig) error {
+	local := netip.PrefixFrom(cfg.Local4, cfg.Subnet4.Bits())
+	if err := rtnl.AddAddr(cfg.Name, local, cfg.Peer4); err != nil {
+		return err
+	}
+	if cfg.Local6.IsValid() {
+		if err := rtnl.AddAddr(cfg.Name, cfg.Local6, netip.Addr{}); err != nil {
+			return err
+		}
+	}
+	return rtnl.SetLinkUp(cfg.Name, cfg.MTU)
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !windows
+
+package tundev
+
+import "github.com/sanverite/simple-packet-logger/internal/routeplan"
+
+const method = "unsupported"
+
+// Create returns ErrUnsupported: the engine creates the device (utunN on
+// macOS).
+func Create(name string) (string, error) { return "", ErrUnsupported }
+
+// Remove returns ErrUnsupported.
+func Remove(name string) error { return ErrUnsupported }
+
+// SetOwner returns ErrUnsupported.
+func SetOwner(name string, uid int) error { return ErrUnsupported }
+
+// Configure returns ErrUnsupported.
+func Configure(cfg routeplan.TUNConfig) error { return ErrUnsupported }
@@ -0,0 +1,98 @@
+//go:build windows
+
+package tundev
+
+import (
+	"fmt"
+	"net/netip"
+	"sync"
+
+	"golang.zx2c4.com/wireguard/tun"
+
+	"github.com/sanverite/simple-packet-logger/internal/iphlp"
+	"github.com/sanverite/simple-packet-logger/internal/routeplan"
+)
+
+const method = "wintun"
+
+// A wintun adapter lives as long as its handle, so Create keeps the
+// devices it made open here until Remove.
+var (
+	mu      sync.Mutex
+	devices = make(map[string]*tun.NativeTun)
+)
+
+// Create creates the wintun adapter name (wintun.dll must sit next to the
+// executable) and returns its name. Creating a device the agent already
+// holds returns it unchanged.
+func Create(name string) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := devices[name]; ok {
+		return name, nil
+	}
+	dev, err := tun.CreateTUN(name, routeplan.DefaultMTU)
+	if err != nil {
+		return "", fmt.Errorf("wintun %s: %w", name, err)
+	}
+	nt := dev.(*tun.NativeTun)
+	real, err := nt.Name()
+	if err != nil {
+		nt.Close()
+		return "", fmt.Errorf("wintun %s: %w", name, err)
+	}
+	devices[real] = nt
+	return real, nil
+}
+
+// Device returns the open adapter name, for an in-process engine to read
+// and write packets on; wintun allows one session per adapter, so an
+// engine cannot open it again by name.
+func Device(name string) (tun.Device, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	dev, ok := devices[name]
+	return dev, ok
+}
+
+// Remove closes a device made by Create, which deletes the adapter.
+func Remove(name string) error {
+	mu.Lock()
+	dev, ok := devices[name]
+	delete(devices, name)
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("wintun %s: not created by the agent", name)
+	}
+	return dev.Close()
+}
+
+// SetOwner returns ErrUnsupported: the adapter belongs to the process
+// holding it.
+func SetOwner(name string, uid int) error { return ErrUnsupported }
+
+// Configure assigns cfg's addresses to the adapter and sets its MTU.
+// Wintun adapters are up while open, and point-to-point, so the peer needs
+// no address of its own: the subnet is on-link.
+func Configure(cfg routeplan.TUNConfig) error {
+	dev, ok := Device(cfg.Name)
+	if !ok {
+		return fmt.Errorf("wintun %s: not created by the agent", cfg.Name)
+	}
+	luid := dev.(*tun.NativeTun).LUID()
+	if err := iphlp.AddAddr(luid, netip.PrefixFrom(cfg.Local4, cfg.Subnet4.Bits())); err != nil {
+		return err
+	}
+	if err := iphlp.SetMTU(luid, false, cfg.MTU); err != nil {
+		return err
+	}
+	if cfg.Local6.IsValid() {
+		if err := iphlp.AddAddr(luid, cfg.Local6); err != nil {
+			return err
+		}
+		if err := iphlp.SetMTU(luid, true, cfg.MTU); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// Package uplink watches the host's physical uplinks and keeps one active
+// for the upstream proxy connection, failing over to a standby.
+//
+// # Usable Links
+//
+// Every check lists the default routes (netinfo.GetDefaultRoutes) and the
+// state of each route's interface. A link is usable while it has a default
+// route with a gateway and its interface is up and running; a pulled
+// cable, a dropped Wi-Fi association, or an unplugged LTE dongle makes it
+// unusable within one check interval (default 2s).
+//
+// # Active/Standby Policy
+//
+// With a preference list (the agent's outbound_interfaces), the active
+// link is the first usable entry; the rest are standbys in order. When the
+// primary comes back it becomes active again. Links not in the list are
+// never used.
+//
+// Without a preference list every default route is a candidate in the
+// system's metric order, and the active link is sticky: it is kept while
+// usable, and replaced by the best remaining link when it fails.
+//
+// # Switches
+//
+// Each change of active link is reported to Options.OnSwitch with the old
+// and new interface and a reason ("en7 is down", "en7 has no default
+// route", "en7 recovered"). The agent records it as an orchestration event
+// and orders start plans by the active link; the tunnel re-pins its proxy
+// route from the same callback. An empty To means no link is usable.
+package uplink
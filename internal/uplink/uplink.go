@@ -0,0 +1,264 @@
+package uplink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/netinfo"
+)
+
+// DefaultInterval is the pause between checks.
+const DefaultInterval = 2 * time.Second
+
+// Options configures a Monitor.
+type Options struct {
+	// Preferred is the fallback order of interfaces (e.g. en7, en0). Empty
+	// uses every default route in metric order with a sticky active link.
+	Preferred []string
+	// Routes lists default routes, preferred first (default
+	// netinfo.GetDefaultRoutes).
+	Routes func() ([]netinfo.Route, error)
+	// LinkUp reports whether an interface is up and running (default: the
+	// interface flags from package net).
+	LinkUp func(name string) (bool, error)
+	// Interval is the pause between checks in Run (default DefaultInterval).
+	Interval time.Duration
+	// OnSwitch, when set, is called after the active link changes.
+	OnSwitch func(Switch)
+	// Logger receives monitor records. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Link is one candidate uplink.
+type Link struct {
+	Interface string
+	Gateway   netip.Addr // invalid when the interface has no default route
+	Metric    int
+	Usable    bool
+	Reason    string // why the link is unusable
+}
+
+// Switch is a change of active link. From or To is empty when no link was
+// or is usable.
+type Switch struct {
+	From   string
+	To     string
+	Reason string
+	At     time.Time
+}
+
+// Status is the result of the latest check.
+type Status struct {
+	Active     string
+	Links      []Link // candidates in preference order
+	Checked    time.Time
+	Err        string // last route listing failure, if any
+	Switches   int
+	LastSwitch Switch
+}
+
+// Monitor tracks the active uplink.
+type Monitor struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New constructs a monitor; call Check or Run to evaluate the links.
+func New(opts Options) *Monitor {
+	if opts.Routes == nil {
+		opts.Routes = netinfo.GetDefaultRoutes
+	}
+	if opts.LinkUp == nil {
+		opts.LinkUp = linkUp
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Monitor{opts: opts, logger: logger}
+}
+
+// Status returns the result of the latest check.
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.status
+	st.Links = append([]Link(nil), st.Links...)
+	return st
+}
+
+// Order returns the active link followed by the usable standbys, the
+// preference to plan the proxy route with. Empty when none is usable.
+func (m *Monitor) Order() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []string
+	if m.status.Active != "" {
+		out = append(out, m.status.Active)
+	}
+	for _, l := range m.status.Links {
+		if l.Usable && l.Interface != m.status.Active {
+			out = append(out, l.Interface)
+		}
+	}
+	return out
+}
+
+// Check lists the links once and switches the active link if needed.
+// A route listing failure keeps the previous links and active link.
+func (m *Monitor) Check() Status {
+	routes, err := m.opts.Routes()
+	if errors.Is(err, netinfo.ErrNoDefaultRoute) {
+		routes, err = nil, nil
+	}
+	now := time.Now()
+
+	m.mu.Lock()
+	m.status.Checked = now
+	if err != nil {
+		m.status.Err = err.Error()
+		st := m.status
+		m.mu.Unlock()
+		m.logger.Warn("uplink check failed", "err", err)
+		return st
+	}
+	m.status.Err = ""
+	links := m.candidates(routes)
+	prev := m.status.Active
+	next, reason := m.choose(prev, links)
+	m.status.Links = links
+	var sw *Switch
+	if next != prev {
+		m.status.Active = next
+		m.status.Switches++
+		m.status.LastSwitch = Switch{From: prev, To: next, Reason: reason, At: now}
+		sw = &m.status.LastSwitch
+	}
+	st := m.status
+	st.Links = append([]Link(nil), links...)
+	m.mu.Unlock()
+
+	if sw != nil {
+		m.logger.Info("uplink switched", "from", sw.From, "to", sw.To, "reason", sw.Reason)
+		if m.opts.OnSwitch != nil {
+			m.opts.OnSwitch(*sw)
+		}
+	}
+	return st
+}
+
+// Run checks every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context) {
+	m.Check()
+	t := time.NewTicker(m.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.Check()
+		}
+	}
+}
+
+// candidates builds the link list in preference order.
+func (m *Monitor) candidates(routes []netinfo.Route) []Link {
+	byIf := make(map[string]netinfo.Route, len(routes))
+	var order []string
+	for _, r := range routes {
+		if _, ok := byIf[r.Interface]; ok {
+			continue
+		}
+		byIf[r.Interface] = r
+		order = append(order, r.Interface)
+	}
+	if len(m.opts.Preferred) > 0 {
+		order = m.opts.Preferred
+	}
+
+	links := make([]Link, 0, len(order))
+	for _, name := range order {
+		l := Link{Interface: name}
+		r, ok := byIf[name]
+		switch {
+		case !ok || !r.Gateway.IsValid():
+			l.Reason = name + " has no default route"
+		default:
+			l.Gateway, l.Metric = r.Gateway, r.Metric
+			up, err := m.opts.LinkUp(name)
+			switch {
+			case err != nil:
+				l.Reason = fmt.Sprintf("%s: %v", name, err)
+			case !up:
+				l.Reason = name + " is down"
+			default:
+				l.Usable = true
+			}
+		}
+		links = append(links, l)
+	}
+	return links
+}
+
+// choose applies the active/standby policy and explains a change.
+func (m *Monitor) choose(prev string, links []Link) (string, string) {
+	var first *Link
+	var prevLink *Link
+	for i := range links {
+		l := &links[i]
+		if l.Usable && first == nil {
+			first = l
+		}
+		if l.Interface == prev {
+			prevLink = l
+		}
+	}
+	// Sticky without a preference list: keep a healthy active link.
+	if len(m.opts.Preferred) == 0 && prevLink != nil && prevLink.Usable {
+		return prev, ""
+	}
+	switch {
+	case first == nil && prev == "":
+		return "", ""
+	case first == nil:
+		return "", reasonFor(prev, prevLink)
+	case first.Interface == prev:
+		return prev, ""
+	case prev == "":
+		return first.Interface, first.Interface + " is usable"
+	case prevLink != nil && prevLink.Usable:
+		return first.Interface, first.Interface + " recovered"
+	default:
+		return first.Interface, reasonFor(prev, prevLink)
+	}
+}
+
+func reasonFor(name string, l *Link) string {
+	if l == nil {
+		return name + " has no default route"
+	}
+	return l.Reason
+}
+
+// linkUp reports whether the interface is administratively up and has
+// carrier.
+func linkUp(name string) (bool, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return false, err
+	}
+	return ifi.Flags&net.FlagUp != 0 && ifi.Flags&net.FlagRunning != 0, nil
+}
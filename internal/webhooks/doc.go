@@ -0,0 +1,30 @@
+// Package webhooks POSTs agent events to HTTP endpoints, so lifecycle
+// changes and probe failures can reach chat rooms or user automation.
+//
+// # Webhooks
+//
+// A Webhook names a URL, an optional secret, and an event filter: the
+// event types it wants (all when empty) and the least severe event it
+// wants, as for GET /v1/events/stream. Run subscribes to the event log
+// and queues each matching event for every webhook; each webhook has its
+// own goroutine and queue (QueueSize), so events reach one endpoint in
+// order and a slow endpoint delays no other.
+//
+// # Requests
+//
+// Each request carries a JSON Payload: the webhook name, a "text" line
+// for Slack-compatible receivers, and the event as served by
+// GET /v1/events. HeaderEvent names the event type and HeaderDelivery
+// its ID, which stays the same across retries so receivers can discard
+// duplicates. With a secret, HeaderSignature is "sha256=" followed by
+// the hex HMAC-SHA256 of HeaderTimestamp, a dot, and the body.
+//
+// # Retries
+//
+// A network error, a timeout, or a 408, 429, or 5xx response is retried
+// up to the webhook's MaxRetries times (DefaultMaxRetries, at most the
+// package's MaxRetries), waiting RetryBackoff before the first retry and
+// doubling up to MaxBackoff. Other responses are not retried. A delivery
+// that fails for good is recorded as a warning event with kind=webhook;
+// such events are never delivered themselves.
+package webhooks
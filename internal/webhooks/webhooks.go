@@ -0,0 +1,269 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sanverite/simple-packet-logger/internal/core"
+)
+
+// Delivery bounds.
+const (
+	DefaultTimeout    = 10 * time.Second
+	MaxTimeout        = time.Minute
+	DefaultMaxRetries = 3
+	MaxRetries        = 10
+	// RetryBackoff is the wait before the first retry; it doubles per
+	// retry up to MaxBackoff.
+	RetryBackoff = time.Second
+	MaxBackoff   = time.Minute
+	// QueueSize bounds the events waiting per webhook; newer events are
+	// dropped while it is full.
+	QueueSize = 256
+)
+
+// Request headers.
+const (
+	HeaderEvent     = "X-SPL-Event"     // event type
+	HeaderDelivery  = "X-SPL-Delivery"  // event ID, the same across retries
+	HeaderTimestamp = "X-SPL-Timestamp" // Unix seconds when the attempt was signed
+	HeaderSignature = "X-SPL-Signature" // "sha256=" + hex HMAC; only with a secret
+)
+
+// EventTypes are the event types a webhook may name.
+var EventTypes = []core.EventType{core.EventStateChange, core.EventProbeResult, core.EventOrchestration, core.EventWarning}
+
+// Webhook POSTs matching events to URL.
+type Webhook struct {
+	Name        string // label in events and logs; defaults to the URL's host
+	URL         string
+	Secret      string           // HMAC-SHA256 key for HeaderSignature; empty sends no signature
+	Types       []core.EventType // event types to deliver; all when empty
+	MinSeverity core.Severity    // least severe event to deliver
+	Timeout     time.Duration    // per attempt; 0 uses DefaultTimeout
+	MaxRetries  int              // retries after the first attempt; 0 uses DefaultMaxRetries, -1 none
+}
+
+// Validate checks w and fills defaults.
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if w.Name == "" && err == nil {
+		w.Name = u.Host
+	}
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook %q: url must be an http or https URL", w.Name)
+	}
+	for _, t := range w.Types {
+		if !slices.Contains(EventTypes, t) {
+			return fmt.Errorf("webhook %q: unknown event type %q", w.Name, t)
+		}
+	}
+	if w.Timeout < 0 || w.Timeout > MaxTimeout {
+		return fmt.Errorf("webhook %q: timeout must be between 0 and %s", w.Name, MaxTimeout)
+	}
+	if w.Timeout == 0 {
+		w.Timeout = DefaultTimeout
+	}
+	if w.MaxRetries < -1 || w.MaxRetries > MaxRetries {
+		return fmt.Errorf("webhook %q: max_retries must be between -1 and %d", w.Name, MaxRetries)
+	}
+	if w.MaxRetries == 0 {
+		w.MaxRetries = DefaultMaxRetries
+	}
+	return nil
+}
+
+// Match reports whether w delivers ev. Events about webhook deliveries
+// are never delivered, so a failing endpoint cannot feed itself.
+func (w Webhook) Match(ev core.Event) bool {
+	if ev.Data["kind"] == "webhook" {
+		return false
+	}
+	if len(w.Types) > 0 && !slices.Contains(w.Types, ev.Type) {
+		return false
+	}
+	return ev.Severity() >= w.MinSeverity
+}
+
+// Payload is the JSON body of each request. Text repeats the message with
+// its severity so Slack-compatible incoming webhooks can render it as is.
+type Payload struct {
+	Webhook string `json:"webhook"`
+	Text    string `json:"text"`
+	Event   Event  `json:"event"`
+}
+
+// Event is an event as served by GET /v1/events.
+type Event struct {
+	ID       uint64            `json:"id"`
+	At       string            `json:"at"` // RFC 3339
+	Type     string            `json:"type"`
+	Severity string            `json:"severity"`
+	Message  string            `json:"message"`
+	Data     map[string]string `json:"data"`
+}
+
+// NewPayload builds the body w sends for ev.
+func NewPayload(w Webhook, ev core.Event) Payload {
+	sev := ev.Severity().String()
+	return Payload{
+		Webhook: w.Name,
+		Text:    "[" + sev + "] " + ev.Message,
+		Event: Event{
+			ID:       ev.ID,
+			At:       ev.At.UTC().Format(time.RFC3339),
+			Type:     string(ev.Type),
+			Severity: sev,
+			Message:  ev.Message,
+			Data:     ev.Data,
+		},
+	}
+}
+
+// Sign returns the HeaderSignature value for body sent at ts: the hex
+// HMAC-SHA256 of "<ts>.<body>" keyed with secret. Receivers should
+// recompute it, compare in constant time, and reject stale timestamps.
+func Sign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run delivers the events in state to each webhook until ctx is done.
+// Each webhook has its own queue and goroutine, so a slow or failing
+// endpoint delays only itself; events for one webhook are delivered in
+// order. A delivery that still fails after its retries is recorded as a
+// warning event with kind=webhook. Run blocks; run it in a goroutine.
+func Run(ctx context.Context, state *core.State, hooks []Webhook, client *http.Client, logger *slog.Logger) {
+	if client == nil {
+		client = &http.Client{}
+	}
+	queues := make([]chan core.Event, len(hooks))
+	done := make(chan struct{})
+	for i, w := range hooks {
+		queues[i] = make(chan core.Event, QueueSize)
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for ev := range queues[i] {
+				deliver(ctx, state, client, w, ev, logger)
+			}
+		}()
+	}
+
+	var dropped uint64
+	for ev := range state.Subscribe(ctx) {
+		if ev.Dropped > dropped {
+			logger.Warn("webhooks fell behind; events dropped", "dropped", ev.Dropped-dropped)
+			dropped = ev.Dropped
+		}
+		for i, w := range hooks {
+			if !w.Match(ev.Event) {
+				continue
+			}
+			select {
+			case queues[i] <- ev.Event:
+			default:
+				logger.Warn("webhook queue full; event dropped", "webhook", w.Name, "event_id", ev.Event.ID)
+			}
+		}
+	}
+	for _, q := range queues {
+		close(q)
+	}
+	for range hooks {
+		<-done
+	}
+}
+
+// deliver sends ev to w, retrying failed attempts with backoff.
+func deliver(ctx context.Context, state *core.State, client *http.Client, w Webhook, ev core.Event, logger *slog.Logger) {
+	body, err := json.Marshal(NewPayload(w, ev))
+	if err != nil {
+		logger.Error("webhook payload", "webhook", w.Name, "err", err)
+		return
+	}
+	retries := max(w.MaxRetries, 0)
+	backoff := RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := Send(ctx, client, w, ev, body)
+		if err == nil {
+			logger.Debug("webhook delivered", "webhook", w.Name, "event_id", ev.ID, "attempts", attempt+1)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !retry || attempt >= retries {
+			logger.Warn("webhook delivery failed", "webhook", w.Name, "event_id", ev.ID, "attempts", attempt+1, "err", err)
+			state.RecordEvent(core.EventWarning, "webhook "+w.Name+" failed to deliver event "+strconv.FormatUint(ev.ID, 10)+": "+err.Error(), map[string]string{
+				"kind":     "webhook",
+				"webhook":  w.Name,
+				"event_id": strconv.FormatUint(ev.ID, 10),
+				"attempts": strconv.Itoa(attempt + 1),
+			})
+			return
+		}
+		logger.Debug("webhook attempt failed; retrying", "webhook", w.Name, "event_id", ev.ID, "in", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, MaxBackoff)
+	}
+}
+
+// Send makes one delivery attempt of body for ev. retry reports whether a
+// failure may succeed later: network errors, 408, 429, and 5xx responses.
+func Send(ctx context.Context, client *http.Client, w Webhook, ev core.Event, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(ev.Type))
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(ev.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if w.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(w.Secret, ts, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			// The URL may carry a token (e.g. Slack); name only its host.
+			err = fmt.Errorf("%s: %w", req.URL.Host, uerr.Err)
+		}
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	err = fmt.Errorf("%s: %s", req.URL.Host, strings.TrimSpace(resp.Status))
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, err
+	}
+	return false, err
+}
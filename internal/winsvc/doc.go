@@ -0,0 +1,14 @@
+// Package winsvc runs the agent as a Windows service, the counterpart of
+// package sdnotify.
+//
+// When the service control manager started the process, Start connects
+// to it and reports the service running; the agent calls it once the API
+// listeners serve. A stop request, or system shutdown, is handed to the
+// agent's stop callback as the shutdown reason, the way a signal is
+// elsewhere; the agent reports Stopping when shutdown begins and Exit when
+// it is done. Started from a console, or on other platforms, Start
+// returns nil and every method does nothing.
+//
+// The service is registered with sc.exe under Name; see
+// docs/operations.md.
+package winsvc
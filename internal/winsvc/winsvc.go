@@ -0,0 +1,5 @@
+package winsvc
+
+// Name is the service name the agent registers under (see
+// docs/operations.md).
+const Name = "simple-packet-logger"
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package winsvc
+
+// Service does nothing outside Windows.
+type Service struct{}
+
+// Start returns nil: there is no service control manager.
+func Start(name string, stop func(reason string)) (*Service, error) { return nil, nil }
+
+// Stopping does nothing.
+func (*Service) Stopping() {}
+
+// Exit does nothing.
+func (*Service) Exit() error { return nil }
@@ -0,0 +1,88 @@
+//go:build windows
+
+package winsvc
+
+import "golang.org/x/sys/windows/svc"
+
+// Service is the connection to the service control manager of an agent
+// started as a Windows service. A nil *Service (not a service) is valid
+// and does nothing.
+type Service struct {
+	stopping chan struct{}
+	exit     chan struct{}
+	done     chan struct{}
+	err      error
+}
+
+// Start connects to the service control manager when the process runs as
+// a service, reporting it running, and returns nil otherwise. stop is
+// called with a shutdown reason when the manager asks the service to stop.
+// Call it once the API serves, within the manager's 30s start timeout.
+func Start(name string, stop func(reason string)) (*Service, error) {
+	ok, err := svc.IsWindowsService()
+	if err != nil || !ok {
+		return nil, err
+	}
+	s := &Service{
+		stopping: make(chan struct{}, 1),
+		exit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		s.err = svc.Run(name, &handler{s: s, stop: stop})
+	}()
+	return s, nil
+}
+
+// Stopping reports that shutdown began.
+func (s *Service) Stopping() {
+	if s == nil {
+		return
+	}
+	select {
+	case s.stopping <- struct{}{}:
+	default:
+	}
+}
+
+// Exit reports the service stopped and waits for the dispatcher to
+// return, with its error.
+func (s *Service) Exit() error {
+	if s == nil {
+		return nil
+	}
+	close(s.exit)
+	<-s.done
+	return s.err
+}
+
+type handler struct {
+	s    *Service
+	stop func(reason string)
+}
+
+// Execute implements svc.Handler. Returning reports SERVICE_STOPPED.
+func (h *handler) Execute(_ []string, reqs <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.Running, Accepts: accepts}
+	for {
+		select {
+		case <-h.s.exit:
+			return false, 0
+		case <-h.s.stopping:
+			status <- svc.Status{State: svc.StopPending}
+		case req := <-reqs:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop:
+				status <- svc.Status{State: svc.StopPending}
+				h.stop("service control: stop")
+			case svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				h.stop("service control: system shutdown")
+			}
+		}
+	}
+}
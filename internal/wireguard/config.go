@@ -0,0 +1,137 @@
+package wireguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// DefaultMTU leaves room for WireGuard's overhead on a 1500-byte path
+// over IPv6 (80 bytes), as wg-quick does.
+const DefaultMTU = 1420
+
+// Config is a tunnel with a single peer that carries all traffic.
+type Config struct {
+	PrivateKey Key
+	// Address is this host's tunnel address, assigned by the peer's
+	// operator (the Address line of a wg-quick config).
+	Address netip.Addr
+	Peer    Peer
+}
+
+// Peer is the remote end of the tunnel.
+type Peer struct {
+	PublicKey    Key
+	PresharedKey Key    // zero: none
+	Endpoint     string // "host:port"
+	// Keepalive sends a keepalive this often when idle, to hold NAT
+	// mappings open; zero disables it.
+	Keepalive time.Duration
+}
+
+// Validate checks c without network access.
+func (c Config) Validate() error {
+	switch {
+	case c.PrivateKey.IsZero():
+		return errors.New("wireguard: private_key is required")
+	case c.Peer.PublicKey.IsZero():
+		return errors.New("wireguard: public_key is required")
+	case !c.Address.IsValid():
+		return errors.New("wireguard: address is required")
+	case c.Peer.Keepalive < 0 || c.Peer.Keepalive > 65535*time.Second:
+		return errors.New("wireguard: keepalive must be between 0 and 65535 seconds")
+	}
+	if _, port, err := net.SplitHostPort(c.Peer.Endpoint); err != nil {
+		return fmt.Errorf("wireguard: endpoint %q: %w", c.Peer.Endpoint, err)
+	} else if port == "0" || port == "" {
+		return fmt.Errorf("wireguard: endpoint %q: port is required", c.Peer.Endpoint)
+	}
+	return nil
+}
+
+// ResolveEndpoint returns the peer endpoint's address, resolving a host
+// name with r (net.DefaultResolver when nil) and preferring IPv4.
+func ResolveEndpoint(ctx context.Context, r *net.Resolver, endpoint string) (netip.AddrPort, error) {
+	if ap, err := netip.ParseAddrPort(endpoint); err == nil {
+		return ap, nil
+	}
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	pn, err := net.LookupPort("udp", port)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	addrs, err := r.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	best := addrs[0]
+	for _, a := range addrs {
+		if a.Unmap().Is4() {
+			best = a
+			break
+		}
+	}
+	return netip.AddrPortFrom(best.Unmap(), uint16(pn)), nil
+}
+
+// UAPI renders c in the configuration protocol of wireguard-go
+// (Device.IpcSet) for a peer reached at endpoint. The peer is allowed
+// every address, since it is the default route.
+func (c Config) UAPI(endpoint netip.AddrPort) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "private_key=%s\nreplace_peers=true\n", c.PrivateKey.hex())
+	fmt.Fprintf(&b, "public_key=%s\n", c.Peer.PublicKey.hex())
+	if !c.Peer.PresharedKey.IsZero() {
+		fmt.Fprintf(&b, "preshared_key=%s\n", c.Peer.PresharedKey.hex())
+	}
+	fmt.Fprintf(&b, "endpoint=%s\n", endpoint)
+	if c.Peer.Keepalive > 0 {
+		fmt.Fprintf(&b, "persistent_keepalive_interval=%d\n", int(c.Peer.Keepalive/time.Second))
+	}
+	b.WriteString("replace_allowed_ips=true\nallowed_ip=0.0.0.0/0\nallowed_ip=::/0\n")
+	return b.String()
+}
+
+// PeerStats is the peer's state as reported by the device.
+type PeerStats struct {
+	LastHandshake time.Time // zero before the first handshake
+	RxBytes       uint64
+	TxBytes       uint64
+}
+
+// ParsePeerStats reads the first peer's counters from a UAPI "get"
+// response (Device.IpcGet).
+func ParsePeerStats(uapi string) PeerStats {
+	var st PeerStats
+	var sec, nsec int64
+	for _, line := range strings.Split(uapi, "\n") {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "last_handshake_time_sec":
+			fmt.Sscan(v, &sec)
+		case "last_handshake_time_nsec":
+			fmt.Sscan(v, &nsec)
+		case "rx_bytes":
+			fmt.Sscan(v, &st.RxBytes)
+		case "tx_bytes":
+			fmt.Sscan(v, &st.TxBytes)
+		}
+	}
+	if sec > 0 {
+		st.LastHandshake = time.Unix(sec, nsec)
+	}
+	return st
+}
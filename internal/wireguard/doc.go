@@ -0,0 +1,20 @@
+// Package wireguard holds what the agent needs to use a WireGuard peer as
+// its upstream: keys, the tunnel configuration, and a handshake check.
+//
+// # Configuration
+//
+// Config is one tunnel with a single peer that carries all traffic. It
+// comes from a start request or a wg-quick(8) file (ParseQuick) and is
+// rendered for wireguard-go with UAPI; the tun2socks package's WireGuard
+// engine runs the device.
+//
+// # Handshake Check
+//
+// Handshake sends a handshake initiation (Noise IKpsk2, as in the
+// WireGuard paper) and authenticates the peer's response, which proves
+// the endpoint is up and accepts this host's key and preshared key. No
+// transport keys are derived and nothing is sent afterwards, so the peer
+// keeps no session. Peers drop initiations they cannot authenticate
+// without replying, so wrong keys and an unreachable endpoint both end in
+// ErrNoResponse.
+package wireguard
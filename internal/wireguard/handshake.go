@@ -0,0 +1,268 @@
+package wireguard
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net"
+	"net/netip"
+	"time"
+
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+)
+
+// Errors returned by Handshake.
+var (
+	// ErrNoResponse means the peer did not answer. WireGuard drops
+	// handshakes it cannot authenticate without replying, so this is
+	// also what wrong keys look like.
+	ErrNoResponse = errors.New("wireguard: no handshake response (peer unreachable, or it does not accept this key)")
+	// ErrCookie means the peer answered with a cookie reply: it is up but
+	// under load and wants the handshake retried.
+	ErrCookie = errors.New("wireguard: peer is under load (cookie reply)")
+)
+
+// Message types and sizes from the WireGuard paper, section 5.4.
+const (
+	msgInitiation   = 1
+	msgResponse     = 2
+	msgCookieReply  = 3
+	initiationSize  = 148
+	responseSize    = 92
+	cookieReplySize = 64
+)
+
+var (
+	construction = []byte("Noise_IKpsk2_25519_ChaChaPoly_BLAKE2s")
+	identifier   = []byte("WireGuard v1 zx2c4 Jason@zx2c4.com")
+	labelMAC1    = []byte("mac1----")
+)
+
+// HandshakeResult describes a completed handshake.
+type HandshakeResult struct {
+	Endpoint netip.AddrPort // the address the initiation was sent to
+	RTT      time.Duration  // initiation sent to response received
+}
+
+// Handshake performs the initiator's half of a WireGuard handshake with
+// cfg.Peer and authenticates the response, without setting up a tunnel.
+// It returns ErrNoResponse when ctx ends without a valid response, and
+// ErrCookie when the peer asks to retry under load.
+func Handshake(ctx context.Context, cfg Config) (HandshakeResult, error) {
+	if cfg.PrivateKey.IsZero() || cfg.Peer.PublicKey.IsZero() {
+		return HandshakeResult{}, errors.New("wireguard: private and peer public keys are required")
+	}
+	ep, err := ResolveEndpoint(ctx, nil, cfg.Peer.Endpoint)
+	if err != nil {
+		return HandshakeResult{}, fmt.Errorf("wireguard: resolve endpoint: %w", err)
+	}
+	res := HandshakeResult{Endpoint: ep}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", ep.String())
+	if err != nil {
+		return res, err
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	var hs initiator
+	msg, err := hs.initiation(cfg)
+	if err != nil {
+		return res, err
+	}
+	t0 := time.Now()
+	if _, err := conn.Write(msg); err != nil {
+		return res, err
+	}
+	buf := make([]byte, 256)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return res, ErrNoResponse
+			}
+			return res, err
+		}
+		switch {
+		case n == cookieReplySize && buf[0] == msgCookieReply:
+			return res, ErrCookie
+		case n == responseSize && buf[0] == msgResponse:
+			if err := hs.consumeResponse(cfg, buf[:n]); err != nil {
+				// Not ours (or forged); keep waiting for the real one.
+				continue
+			}
+			res.RTT = time.Since(t0)
+			return res, nil
+		}
+	}
+}
+
+// initiator holds the handshake state between the two messages.
+type initiator struct {
+	sender    uint32
+	chainKey  [blake2s.Size]byte
+	hash      [blake2s.Size]byte
+	ephemeral Key
+}
+
+// initiation builds the first handshake message.
+func (h *initiator) initiation(cfg Config) ([]byte, error) {
+	var err error
+	if h.ephemeral, err = GenerateKey(); err != nil {
+		return nil, err
+	}
+	var idx [4]byte
+	if _, err := rand.Read(idx[:]); err != nil {
+		return nil, err
+	}
+	h.sender = binary.LittleEndian.Uint32(idx[:])
+	peer := cfg.Peer.PublicKey
+
+	h.chainKey = blake2s.Sum256(construction)
+	h.hash = mixHash(h.chainKey, identifier)
+	h.hash = mixHash(h.hash, peer[:])
+
+	msg := make([]byte, initiationSize)
+	msg[0] = msgInitiation
+	binary.LittleEndian.PutUint32(msg[4:8], h.sender)
+	epub := h.ephemeral.Public()
+	copy(msg[8:40], epub[:])
+	h.chainKey = kdf1(h.chainKey[:], epub[:])
+	h.hash = mixHash(h.hash, epub[:])
+
+	ss, err := dh(h.ephemeral, peer)
+	if err != nil {
+		return nil, err
+	}
+	var key [chacha20poly1305.KeySize]byte
+	h.chainKey, key = kdf2(h.chainKey[:], ss[:])
+	spub := cfg.PrivateKey.Public()
+	static := seal(key, spub[:], h.hash[:])
+	copy(msg[40:88], static)
+	h.hash = mixHash(h.hash, static)
+
+	ss, err = dh(cfg.PrivateKey, peer)
+	if err != nil {
+		return nil, err
+	}
+	h.chainKey, key = kdf2(h.chainKey[:], ss[:])
+	ts := seal(key, tai64n(time.Now()), h.hash[:])
+	copy(msg[88:116], ts)
+	h.hash = mixHash(h.hash, ts)
+
+	mac1Key := blake2s.Sum256(append(append([]byte(nil), labelMAC1...), peer[:]...))
+	copy(msg[116:132], mac(mac1Key[:], msg[:116]))
+	// mac2 stays zero: no cookie has been received.
+	return msg, nil
+}
+
+// consumeResponse authenticates the peer's response to our initiation.
+func (h *initiator) consumeResponse(cfg Config, msg []byte) error {
+	if binary.LittleEndian.Uint32(msg[8:12]) != h.sender {
+		return errors.New("wireguard: response for another handshake")
+	}
+	var epub Key
+	copy(epub[:], msg[12:44])
+	ck := kdf1(h.chainKey[:], epub[:])
+	hs := mixHash(h.hash, epub[:])
+	ss, err := dh(h.ephemeral, epub)
+	if err != nil {
+		return err
+	}
+	ck = kdf1(ck[:], ss[:])
+	ss, err = dh(cfg.PrivateKey, epub)
+	if err != nil {
+		return err
+	}
+	ck = kdf1(ck[:], ss[:])
+	_, tau, key := kdf3(ck[:], cfg.Peer.PresharedKey[:])
+	hs = mixHash(hs, tau[:])
+	aead, _ := chacha20poly1305.New(key[:])
+	var nonce [chacha20poly1305.NonceSize]byte
+	if _, err := aead.Open(nil, nonce[:], msg[44:60], hs[:]); err != nil {
+		return errors.New("wireguard: response does not authenticate")
+	}
+	return nil
+}
+
+func newBlake2s() hash.Hash {
+	h, _ := blake2s.New256(nil)
+	return h
+}
+
+func hmacSum(key []byte, in ...[]byte) [blake2s.Size]byte {
+	m := hmac.New(newBlake2s, key)
+	for _, b := range in {
+		m.Write(b)
+	}
+	var out [blake2s.Size]byte
+	m.Sum(out[:0])
+	return out
+}
+
+func kdf1(key, in []byte) [blake2s.Size]byte {
+	t0 := hmacSum(key, in)
+	return hmacSum(t0[:], []byte{1})
+}
+
+func kdf2(key, in []byte) (t1, t2 [blake2s.Size]byte) {
+	t0 := hmacSum(key, in)
+	t1 = hmacSum(t0[:], []byte{1})
+	t2 = hmacSum(t0[:], t1[:], []byte{2})
+	return t1, t2
+}
+
+func kdf3(key, in []byte) (t1, t2, t3 [blake2s.Size]byte) {
+	t0 := hmacSum(key, in)
+	t1 = hmacSum(t0[:], []byte{1})
+	t2 = hmacSum(t0[:], t1[:], []byte{2})
+	t3 = hmacSum(t0[:], t2[:], []byte{3})
+	return t1, t2, t3
+}
+
+func mixHash(h [blake2s.Size]byte, data []byte) [blake2s.Size]byte {
+	return blake2s.Sum256(append(h[:], data...))
+}
+
+func mac(key, data []byte) []byte {
+	m, _ := blake2s.New128(key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func dh(priv, pub Key) (Key, error) {
+	var out Key
+	b, err := curve25519.X25519(priv[:], pub[:])
+	if err != nil {
+		return out, fmt.Errorf("wireguard: %w", err)
+	}
+	copy(out[:], b)
+	return out, nil
+}
+
+// seal encrypts with a zero nonce, as every handshake AEAD uses a fresh
+// key.
+func seal(key [chacha20poly1305.KeySize]byte, plain, ad []byte) []byte {
+	aead, _ := chacha20poly1305.New(key[:])
+	var nonce [chacha20poly1305.NonceSize]byte
+	return aead.Seal(nil, nonce[:], plain, ad)
+}
+
+// tai64n encodes t as a TAI64N label, the handshake's replay counter.
+func tai64n(t time.Time) []byte {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint64(b, uint64(0x400000000000000a+t.Unix()))
+	binary.BigEndian.PutUint32(b[8:], uint32(t.Nanosecond()))
+	return b
+}
@@ -0,0 +1,61 @@
+package wireguard
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// KeyLen is the length of Curve25519 keys and preshared keys.
+const KeyLen = 32
+
+// Key is a WireGuard private, public, or preshared key.
+type Key [KeyLen]byte
+
+// ParseKey decodes a base64 key as printed by "wg genkey" and "wg pubkey".
+func ParseKey(s string) (Key, error) {
+	var k Key
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return k, errors.New("wireguard: key is not base64")
+	}
+	if len(b) != KeyLen {
+		return k, fmt.Errorf("wireguard: key is %d bytes, want %d", len(b), KeyLen)
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// GenerateKey returns a new private key.
+func GenerateKey() (Key, error) {
+	var k Key
+	if _, err := rand.Read(k[:]); err != nil {
+		return k, err
+	}
+	// Clamp as "wg genkey" does; X25519 clamps too, but stored keys
+	// should match what other tools produce.
+	k[0] &= 248
+	k[31] = (k[31] & 127) | 64
+	return k, nil
+}
+
+// Public returns the public key of private key k.
+func (k Key) Public() Key {
+	var pub Key
+	b, _ := curve25519.X25519(k[:], curve25519.Basepoint)
+	copy(pub[:], b)
+	return pub
+}
+
+// IsZero reports whether k is unset.
+func (k Key) IsZero() bool { return k == Key{} }
+
+// String returns k in base64.
+func (k Key) String() string { return base64.StdEncoding.EncodeToString(k[:]) }
+
+// hex returns k in the hex form of the UAPI.
+func (k Key) hex() string { return hex.EncodeToString(k[:]) }
@@ -0,0 +1,86 @@
+package wireguard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseQuick reads the keys, address, and first peer of a wg-quick(8)
+// configuration. Keys it does not use (DNS, AllowedIPs, PostUp, ...) are
+// ignored: the agent routes everything through the peer and runs its own
+// DNS forwarder. Only the first IPv4 Address is kept.
+func ParseQuick(r io.Reader) (Config, error) {
+	var c Config
+	section := ""
+	peers := 0
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			if section == "peer" {
+				peers++
+			}
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return c, fmt.Errorf("wireguard: line %d: want key = value", n)
+		}
+		k, v = strings.ToLower(strings.TrimSpace(k)), strings.TrimSpace(v)
+		if section == "peer" && peers > 1 {
+			continue
+		}
+		var err error
+		switch section + "." + k {
+		case "interface.privatekey":
+			c.PrivateKey, err = ParseKey(v)
+		case "interface.address":
+			if !c.Address.IsValid() {
+				c.Address = firstIPv4(v)
+			}
+		case "peer.publickey":
+			c.Peer.PublicKey, err = ParseKey(v)
+		case "peer.presharedkey":
+			c.Peer.PresharedKey, err = ParseKey(v)
+		case "peer.endpoint":
+			c.Peer.Endpoint = v
+		case "peer.persistentkeepalive":
+			var sec int
+			if sec, err = strconv.Atoi(v); err == nil {
+				c.Peer.Keepalive = time.Duration(sec) * time.Second
+			}
+		}
+		if err != nil {
+			return c, fmt.Errorf("wireguard: line %d: %s: %w", n, k, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return c, err
+	}
+	return c, c.Validate()
+}
+
+// firstIPv4 returns the first IPv4 address of a comma-separated list of
+// addresses or prefixes.
+func firstIPv4(list string) netip.Addr {
+	for _, f := range strings.Split(list, ",") {
+		f = strings.TrimSpace(f)
+		if p, err := netip.ParsePrefix(f); err == nil && p.Addr().Is4() {
+			return p.Addr()
+		}
+		if a, err := netip.ParseAddr(f); err == nil && a.Is4() {
+			return a
+		}
+	}
+	return netip.Addr{}
+}